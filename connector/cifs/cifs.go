@@ -0,0 +1,195 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+// Package cifs to mount or unmount CIFS/SMB shares
+package cifs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"huawei-csi-driver/connector"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// CIFS to mount the CIFS shares with the credentials of the node stage secret
+type CIFS struct {
+}
+
+type connectorInfo struct {
+	sourcePath string
+	targetPath string
+	mountFlags string
+	username   string
+	password   string
+	domain     string
+}
+
+func init() {
+	connector.RegisterConnector(connector.CIFSDriver, &CIFS{})
+}
+
+var readFile = ioutil.ReadFile
+
+// ConnectVolume to mount the share to target path
+// Example:
+//    mount -t cifs //<portal>/<share> /<target-path> -o credentials=<credentials-file>
+func (cifs *CIFS) ConnectVolume(ctx context.Context, conn map[string]interface{}) (string, error) {
+	info, err := parseCIFSInfo(ctx, conn)
+	if err != nil {
+		return "", err
+	}
+
+	// the credentials must never be logged
+	log.AddContext(ctx).Infof("CIFS Start to connect volume ==> source path: %s, target path: %s",
+		info.sourcePath, info.targetPath)
+	return "", mountShare(ctx, info)
+}
+
+// DisConnectVolume to unmount the target path
+func (cifs *CIFS) DisConnectVolume(ctx context.Context, targetPath string) error {
+	log.AddContext(ctx).Infof("CIFS Start to disconnect volume ==> target path is: %v", targetPath)
+	_, err := os.Stat(targetPath)
+	if err != nil && os.IsNotExist(err) {
+		return nil
+	}
+
+	output, err := utils.ExecShellCmd(ctx, "umount %s", targetPath)
+	if err != nil && !(strings.Contains(output, "not mounted") || strings.Contains(output, "not found")) {
+		log.AddContext(ctx).Errorf("Unmount %s error: %s", targetPath, output)
+		return err
+	}
+
+	if err := os.RemoveAll(targetPath); err != nil {
+		return utils.Errorf(ctx, "remove target path %s error %v", targetPath, err)
+	}
+	return nil
+}
+
+func parseCIFSInfo(ctx context.Context, conn map[string]interface{}) (*connectorInfo, error) {
+	var info connectorInfo
+	info.sourcePath, _ = conn["sourcePath"].(string)
+	if info.sourcePath == "" {
+		return nil, utils.Errorf(ctx, "there are no source path in the connection info")
+	}
+
+	info.targetPath, _ = conn["targetPath"].(string)
+	if info.targetPath == "" {
+		return nil, utils.Errorf(ctx, "there are no target path in the connection info")
+	}
+
+	info.username, _ = conn["username"].(string)
+	info.password, _ = conn["password"].(string)
+	if info.username == "" || info.password == "" {
+		return nil, utils.Errorf(ctx, "the username and password of the node stage secret must be provided "+
+			"to mount cifs share %s", info.sourcePath)
+	}
+
+	info.domain, _ = conn["domain"].(string)
+	mountFlags, _ := conn["mountFlags"].(string)
+	info.mountFlags = strings.TrimSpace(mountFlags)
+	return &info, nil
+}
+
+func mountShare(ctx context.Context, info *connectorInfo) error {
+	if err := os.MkdirAll(info.targetPath, 0750); err != nil {
+		return utils.Errorf(ctx, "can not create the target path %s: %v", info.targetPath, err)
+	}
+
+	mounted, err := isMounted(ctx, info)
+	if err != nil || mounted {
+		return err
+	}
+
+	credentials, err := writeCredentials(info)
+	if err != nil {
+		return utils.Errorf(ctx, "Write the credentials of cifs share %s error: %v", info.sourcePath, err)
+	}
+	defer func() {
+		if err := os.Remove(credentials); err != nil {
+			log.AddContext(ctx).Warningf("Remove the credentials file %s error: %v", credentials, err)
+		}
+	}()
+
+	options := "credentials=" + credentials
+	if info.mountFlags != "" {
+		options = fmt.Sprintf("%s,%s", options, info.mountFlags)
+	}
+
+	output, err := utils.ExecShellCmd(ctx, "mount -t cifs %s %s -o %s", info.sourcePath, info.targetPath, options)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Mount %s to %s error: %s", info.sourcePath, info.targetPath, output)
+		return err
+	}
+
+	return nil
+}
+
+func isMounted(ctx context.Context, info *connectorInfo) (bool, error) {
+	data, err := readFile("/proc/mounts")
+	if err != nil {
+		return false, utils.Errorf(ctx, "Read the mount file error: %v", err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[1] != info.targetPath {
+			continue
+		}
+
+		if !strings.EqualFold(fields[0], info.sourcePath) {
+			return false, utils.Errorf(ctx, "The mount %s is already exist, but the source path is not %s, "+
+				"instead of %s", info.targetPath, info.sourcePath, fields[0])
+		}
+
+		log.AddContext(ctx).Infof("Mount %s to %s is already exist", info.sourcePath, info.targetPath)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// writeCredentials writes the credentials into a file readable by root only, so they appear neither on the
+// command line nor in the logs. The file is created beside the target path, because the mount runs in the
+// mount namespace of the host, which shares the kubelet directory with the plugin but not its /tmp.
+func writeCredentials(info *connectorInfo) (string, error) {
+	file, err := ioutil.TempFile(filepath.Dir(info.targetPath), ".cifs-credentials-")
+	if err != nil {
+		return "", err
+	}
+
+	content := fmt.Sprintf("username=%s\npassword=%s\n", info.username, info.password)
+	if info.domain != "" {
+		content += fmt.Sprintf("domain=%s\n", info.domain)
+	}
+
+	_, err = file.WriteString(content)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+
+	return file.Name(), nil
+}
@@ -0,0 +1,117 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package cifs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	logDir  = "/var/log/huawei/"
+	logName = "cifsTest.log"
+)
+
+func TestConnectVolume(t *testing.T) {
+	targetPath := filepath.Join(t.TempDir(), "globalmount")
+	conn := map[string]interface{}{
+		"sourcePath": "//127.0.0.1/pvc_test",
+		"targetPath": targetPath,
+		"mountFlags": "vers=3.0",
+		"username":   "user",
+		"password":   "secret",
+		"domain":     "example",
+	}
+
+	var command, credentials string
+	stubs := gostub.StubFunc(&readFile, []byte("/dev/sda1 / ext4 rw 0 0\n"), nil)
+	defer stubs.Reset()
+	stubs.Stub(&utils.ExecShellCmd, func(_ context.Context, format string, args ...interface{}) (string, error) {
+		command = format
+		options := args[len(args)-1].(string)
+		credentialsFile := strings.TrimPrefix(strings.Split(options, ",")[0], "credentials=")
+		data, err := ioutil.ReadFile(credentialsFile)
+		credentials = string(data)
+		return "", err
+	})
+
+	_, err := (&CIFS{}).ConnectVolume(context.TODO(), conn)
+	assert.NoError(t, err)
+	assert.Equal(t, "mount -t cifs %s %s -o %s", command)
+	assert.Equal(t, "username=user\npassword=secret\ndomain=example\n", credentials)
+
+	// the credentials file is removed once mounted
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(targetPath), ".cifs-credentials-*"))
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestConnectVolumeAlreadyMounted(t *testing.T) {
+	targetPath := filepath.Join(t.TempDir(), "globalmount")
+	conn := map[string]interface{}{
+		"sourcePath": "//127.0.0.1/pvc_test",
+		"targetPath": targetPath,
+		"username":   "user",
+		"password":   "secret",
+	}
+
+	stubs := gostub.StubFunc(&readFile, []byte("//127.0.0.1/pvc_test "+targetPath+" cifs rw 0 0\n"), nil)
+	defer stubs.Reset()
+	stubs.Stub(&utils.ExecShellCmd, func(context.Context, string, ...interface{}) (string, error) {
+		t.Fatal("the mounted share must not be mounted again")
+		return "", nil
+	})
+
+	_, err := (&CIFS{}).ConnectVolume(context.TODO(), conn)
+	assert.NoError(t, err)
+}
+
+func TestConnectVolumeWithoutCredentials(t *testing.T) {
+	conn := map[string]interface{}{
+		"sourcePath": "//127.0.0.1/pvc_test",
+		"targetPath": filepath.Join(t.TempDir(), "globalmount"),
+	}
+
+	_, err := (&CIFS{}).ConnectVolume(context.TODO(), conn)
+	assert.Error(t, err)
+}
+
+func TestMain(m *testing.M) {
+	if err := log.InitLogging(logName); err != nil {
+		log.Errorf("init logging: %s failed. error: %v", logName, err)
+		os.Exit(1)
+	}
+	logFile := path.Join(logDir, logName)
+	defer func() {
+		if err := os.RemoveAll(logFile); err != nil {
+			log.Errorf("Remove file: %s failed. error: %s", logFile, err)
+		}
+	}()
+
+	m.Run()
+}
@@ -19,6 +19,7 @@ package connector
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"huawei-csi-driver/utils/log"
@@ -31,9 +32,11 @@ const (
 	RoCEDriver   = "RoCE"
 	LocalDriver  = "Local"
 	NFSDriver    = "NFS"
+	CIFSDriver   = "CIFS"
 
-	MountFSType    = "fs"
-	MountBlockType = "block"
+	MountFSType     = "fs"
+	MountBlockType  = "block"
+	MountDeviceType = "device"
 
 	deviceTypeSCSI = "SCSI"
 	deviceTypeNVMe = "NVMe"
@@ -61,16 +64,64 @@ const (
 	PingCommand = "ping -c 3 -i 0.001 -w 1 %s"
 )
 
+var connectors = map[string]Connector{}
+
+// Settings are the settings of the connectors, which can be changed at runtime by the driver config
+type Settings struct {
+	// ScanVolumeTimeout is the maximum time to wait for the devices of a volume to appear
+	ScanVolumeTimeout time.Duration
+	// FCRequirePathPerFabric requires at least one path in each FC fabric of the node
+	// before a multipath FC volume is attached
+	FCRequirePathPerFabric bool
+	// UltraPathDetection is whether to check if the SCSI devices are managed by UltraPath
+	UltraPathDetection bool
+	// UltraPathNVMeMinPaths is the number of normal paths the UltraPath-NVMe device must have
+	// before a multipath RoCE or FC-NVMe volume is attached
+	UltraPathNVMeMinPaths int
+	// DisabledProtocols are the protocols which are never used on the node, so their tools and
+	// devices are not probed
+	DisabledProtocols []string
+	// MountOptionAllowlist is the names of the mount options the volumes may be mounted with, any option
+	// but the dangerous ones is allowed if it is empty
+	MountOptionAllowlist []string
+}
+
 var (
-	connectors        = map[string]Connector{}
-	ScanVolumeTimeout = 3 * time.Second
+	settingsMutex   sync.RWMutex
+	currentSettings = Settings{
+		ScanVolumeTimeout:     3 * time.Second,
+		UltraPathDetection:    true,
+		UltraPathNVMeMinPaths: 1,
+	}
 )
 
+// SetSettings replaces the settings of the connectors
+func SetSettings(settings Settings) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	currentSettings = settings
+}
+
+// GetSettings returns the current settings of the connectors
+func GetSettings() Settings {
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+	return currentSettings
+}
+
 type Connector interface {
 	ConnectVolume(context.Context, map[string]interface{}) (string, error)
 	DisConnectVolume(context.Context, string) error
 }
 
+// SensitiveString is a secret in the connection info, such as a CHAP password, which is masked
+// when the connection info is logged
+type SensitiveString string
+
+func (s SensitiveString) String() string {
+	return "***"
+}
+
 // DisConnectInfo defines the fields of disconnect volume
 type DisConnectInfo struct {
 	Conn   Connector
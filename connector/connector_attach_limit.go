@@ -0,0 +1,214 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	scsiModuleMaxLunsFile = "/sys/module/scsi_mod/parameters/max_luns"
+	scsiDeviceDir         = "/sys/class/scsi_device"
+)
+
+// protocolHostClasses are the sysfs classes of the SCSI hosts the protocols attach LUNs through
+var protocolHostClasses = map[string]string{
+	"fc":    "/sys/class/fc_host",
+	"iscsi": "/sys/class/iscsi_host",
+}
+
+// hbaMaxLunsFiles are the files of the per-host LUN limits of the HBA and iSCSI drivers, keyed by the proc_name of
+// the SCSI host. %s is the host name.
+var hbaMaxLunsFiles = map[string]string{
+	"lpfc":      "/sys/class/scsi_host/%s/lpfc_max_luns",
+	"qla2xxx":   "/sys/module/qla2xxx/parameters/ql2xmaxlun",
+	"iscsi_tcp": "/sys/module/iscsi_tcp/parameters/max_lun",
+}
+
+// UltraPathMaxVLuns is the maximum number of virtual LUNs UltraPath manages on a host
+var UltraPathMaxVLuns int64 = 2048
+
+var readSysfsValue = func(ctx context.Context, file string) (string, error) {
+	output, err := utils.ExecShellCmd(ctx, "cat %s", file)
+	if err != nil {
+		return "", fmt.Errorf("read %s error: %s", file, output)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+var listSysfsDir = func(ctx context.Context, dir string) ([]string, error) {
+	output, err := utils.ExecShellCmd(ctx, "ls %s", dir)
+	if err != nil {
+		if strings.Contains(output, "No such file or directory") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("list %s error: %s", dir, output)
+	}
+	return strings.Fields(output), nil
+}
+
+func readSysfsInt(ctx context.Context, file string) (int64, bool) {
+	value, err := readSysfsValue(ctx, file)
+	if err != nil {
+		return 0, false
+	}
+
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || number <= 0 {
+		return 0, false
+	}
+	return number, true
+}
+
+func minLimit(limit, other int64) int64 {
+	if limit == 0 || (other > 0 && other < limit) {
+		return other
+	}
+	return limit
+}
+
+// getHostLunLimit returns the number of LUNs a SCSI host can attach, limited by the SCSI layer and
+// the HBA driver. 0 means unknown.
+func getHostLunLimit(ctx context.Context, host string) int64 {
+	limit, _ := readSysfsInt(ctx, scsiModuleMaxLunsFile)
+
+	procName, err := readSysfsValue(ctx, path.Join("/sys/class/scsi_host", host, "proc_name"))
+	if err != nil {
+		return limit
+	}
+
+	if file, exist := hbaMaxLunsFiles[procName]; exist {
+		if hbaLimit, ok := readSysfsInt(ctx, fmt.Sprintf(file, host)); ok {
+			limit = minLimit(limit, hbaLimit)
+		}
+	}
+	return limit
+}
+
+// getProtocolHosts returns the SCSI hosts the protocol attaches LUNs through
+func getProtocolHosts(ctx context.Context, protocol string) ([]string, error) {
+	class, exist := protocolHostClasses[protocol]
+	if !exist {
+		return nil, nil
+	}
+	return listSysfsDir(ctx, class)
+}
+
+// getProtocolAttachLimit returns the number of volumes the node can attach through the protocol. The
+// volumes are attached through all the hosts when multipath is used, so the smallest host limit
+// applies. 0 means unlimited or unknown.
+func getProtocolAttachLimit(ctx context.Context, protocol string, useMultiPath bool,
+	scsiMultiPathType string) (int64, error) {
+	hosts, err := getProtocolHosts(ctx, protocol)
+	if err != nil {
+		return 0, err
+	}
+
+	var limit int64
+	for _, host := range hosts {
+		limit = minLimit(limit, getHostLunLimit(ctx, host))
+	}
+
+	if len(hosts) != 0 && useMultiPath && scsiMultiPathType == HWUltraPath {
+		limit = minLimit(limit, UltraPathMaxVLuns)
+	}
+	return limit, nil
+}
+
+// GetAttachLimit returns the number of volumes the node can attach through the usable protocols
+// checked by Preflight, derived from the HBA and session limits of the node. 0 means unlimited.
+func GetAttachLimit(ctx context.Context, useMultiPath bool, scsiMultiPathType string) int64 {
+	var limit int64
+	for protocol, err := range PreflightResults() {
+		if err != nil {
+			continue
+		}
+
+		protocolLimit, err := getProtocolAttachLimit(ctx, protocol, useMultiPath, scsiMultiPathType)
+		if err != nil {
+			log.AddContext(ctx).Warningf("Get attach limit of protocol %s error: %v", protocol, err)
+			continue
+		}
+
+		if protocolLimit > 0 {
+			log.AddContext(ctx).Infof("The attach limit of protocol %s is %d", protocol, protocolLimit)
+		}
+		limit = minLimit(limit, protocolLimit)
+	}
+
+	return limit
+}
+
+// countHostLuns returns the number of LUNs attached through each SCSI host, the SCSI devices are
+// named host:channel:target:lun
+func countHostLuns(ctx context.Context) (map[string]int64, error) {
+	devices, err := listSysfsDir(ctx, scsiDeviceDir)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64)
+	for _, device := range devices {
+		hctl := strings.Split(device, ":")
+		if len(hctl) != 4 {
+			continue
+		}
+		counts["host"+hctl[0]]++
+	}
+	return counts, nil
+}
+
+// CheckAttachLimit refuses to attach a new volume through the protocol if every host of the protocol has
+// reached its LUN limit, so that the new LUN could not be discovered on any path. A volume already attached,
+// whose device with the WWN exists, is not refused so that a retried stage can go on.
+func CheckAttachLimit(ctx context.Context, protocol, lunWWN string) error {
+	hosts, err := getProtocolHosts(ctx, protocol)
+	if err != nil || len(hosts) == 0 {
+		return err
+	}
+
+	counts, err := countHostLuns(ctx)
+	if err != nil {
+		return err
+	}
+
+	var full []string
+	for _, host := range hosts {
+		limit := getHostLunLimit(ctx, host)
+		if limit == 0 || counts[host] < limit {
+			return nil
+		}
+		full = append(full, fmt.Sprintf("%s(%d/%d)", host, counts[host], limit))
+	}
+
+	if lunWWN != "" {
+		link, err := getDeviceLink(ctx, lunWWN)
+		if err == nil && link != "" {
+			return nil
+		}
+	}
+
+	return utils.KindErrorf(ctx, utils.ErrResourceExhausted,
+		"all %s hosts of the node reached their LUN limits %v, cannot attach more volumes", protocol, full)
+}
@@ -0,0 +1,94 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/utils"
+)
+
+func stubSysfs(files map[string]string, dirs map[string][]string) *gostub.Stubs {
+	stubs := gostub.Stub(&readSysfsValue, func(ctx context.Context, file string) (string, error) {
+		value, exist := files[file]
+		if !exist {
+			return "", fmt.Errorf("read %s error", file)
+		}
+		return value, nil
+	})
+	stubs.Stub(&listSysfsDir, func(ctx context.Context, dir string) ([]string, error) {
+		return dirs[dir], nil
+	})
+	return stubs
+}
+
+func TestGetAttachLimit(t *testing.T) {
+	ctx := context.Background()
+	stubs := stubSysfs(map[string]string{
+		scsiModuleMaxLunsFile:                       "512",
+		"/sys/class/scsi_host/host1/proc_name":      "lpfc",
+		"/sys/class/scsi_host/host1/lpfc_max_luns":  "255",
+		"/sys/class/scsi_host/host2/proc_name":      "qla2xxx",
+		"/sys/module/qla2xxx/parameters/ql2xmaxlun": "300",
+		"/sys/class/scsi_host/host3/proc_name":      "iscsi_tcp",
+	}, map[string][]string{
+		"/sys/class/fc_host":    {"host1", "host2"},
+		"/sys/class/iscsi_host": {"host3"},
+	})
+	defer stubs.Reset()
+	stubs.Stub(&preflightResults, map[string]error{
+		"fc":    nil,
+		"iscsi": nil,
+		"roce":  errors.New("missing kernel module nvme_rdma"),
+		"nfs":   nil,
+	})
+
+	assert.Equal(t, int64(255), GetAttachLimit(ctx, true, DMMultiPath))
+
+	stubs.Stub(&UltraPathMaxVLuns, int64(128))
+	assert.Equal(t, int64(128), GetAttachLimit(ctx, true, HWUltraPath))
+	assert.Equal(t, int64(255), GetAttachLimit(ctx, false, HWUltraPath))
+
+	stubs.Stub(&preflightResults, map[string]error{"nfs": nil})
+	assert.Equal(t, int64(0), GetAttachLimit(ctx, true, HWUltraPath))
+}
+
+func TestCheckAttachLimit(t *testing.T) {
+	ctx := context.Background()
+	dirs := map[string][]string{
+		"/sys/class/fc_host": {"host1", "host2"},
+		scsiDeviceDir:        {"1:0:0:0", "1:0:0:1", "2:0:0:0", "2:0:0:1"},
+	}
+	stubs := stubSysfs(map[string]string{
+		scsiModuleMaxLunsFile:                  "2",
+		"/sys/class/scsi_host/host1/proc_name": "lpfc",
+	}, dirs)
+	defer stubs.Reset()
+
+	err := CheckAttachLimit(ctx, "fc", "")
+	assert.True(t, errors.Is(err, utils.ErrResourceExhausted))
+	assert.NoError(t, CheckAttachLimit(ctx, "nfs", ""))
+
+	dirs[scsiDeviceDir] = []string{"1:0:0:0", "1:0:0:1", "2:0:0:0"}
+	assert.NoError(t, CheckAttachLimit(ctx, "fc", ""))
+}
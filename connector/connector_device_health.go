@@ -0,0 +1,106 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+const scsiDeviceStateRunning = "running"
+
+// getBlockDeviceName returns the kernel name of the block device of the path, such as dm-3 or sdb, which can be
+// the device node, a symlink to it, or a file the device is bind mounted onto. Empty is returned if the path is
+// not a block device.
+var getBlockDeviceName = func(ctx context.Context, devPath string) (string, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(devPath, &stat); err != nil {
+		return "", fmt.Errorf("stat %s error: %v", devPath, err)
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFBLK {
+		return "", nil
+	}
+
+	sysPath := fmt.Sprintf("/sys/dev/block/%d:%d", unix.Major(stat.Rdev), unix.Minor(stat.Rdev))
+	realPath, err := filepath.EvalSymlinks(sysPath)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s error: %v", sysPath, err)
+	}
+	return path.Base(realPath), nil
+}
+
+// CheckDevicePaths returns the description of the lost paths of the block device, empty if all of its paths are
+// running. The paths of DM-multipath devices and SCSI devices are checked, the NVMe native multipath and
+// UltraPath devices are reported normal as their paths are managed by the kernel and UltraPath.
+var CheckDevicePaths = func(ctx context.Context, devPath string) (string, error) {
+	name, err := getBlockDeviceName(ctx, devPath)
+	if err != nil || name == "" {
+		return "", err
+	}
+
+	paths := []string{name}
+	if strings.HasPrefix(name, "dm-") {
+		paths, err = listSysfsDir(ctx, path.Join("/sys/block", name, "slaves"))
+		if err != nil {
+			return "", err
+		}
+		if len(paths) == 0 {
+			return fmt.Sprintf("The multipath device %s of %s has no path", name, devPath), nil
+		}
+	}
+
+	var lostPaths []string
+	for _, p := range paths {
+		if !strings.HasPrefix(p, "sd") {
+			continue
+		}
+
+		state, err := readSysfsValue(ctx, path.Join("/sys/block", p, "device", "state"))
+		if err != nil {
+			log.AddContext(ctx).Warningf("Get state of device %s error: %v", p, err)
+			continue
+		}
+		if state != scsiDeviceStateRunning {
+			lostPaths = append(lostPaths, fmt.Sprintf("%s(%s)", p, state))
+		}
+	}
+
+	if len(lostPaths) == 0 {
+		return "", nil
+	}
+	if len(lostPaths) == len(paths) {
+		return fmt.Sprintf("All the paths %v of device %s are lost", lostPaths, name), nil
+	}
+	return fmt.Sprintf("The paths %v of device %s are lost", lostPaths, name), nil
+}
+
+// GetMountSource returns the source of the mount the path is on, such as /dev/mapper/mpatha or host:/share
+var GetMountSource = func(ctx context.Context, mountPath string) (string, error) {
+	output, err := utils.ExecShellCmd(ctx, "findmnt -o source --noheadings --target %s", mountPath)
+	if err != nil {
+		return "", fmt.Errorf("findmnt %s error: %s", mountPath, output)
+	}
+	return strings.TrimSpace(output), nil
+}
@@ -0,0 +1,61 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDevicePaths(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		device   string
+		slaves   []string
+		states   map[string]string
+		abnormal bool
+	}{
+		{"notBlockDevice", "", nil, nil, false},
+		{"multipathNormal", "dm-3", []string{"sdb", "sdc"},
+			map[string]string{"sdb": "running", "sdc": "running"}, false},
+		{"multipathPathLost", "dm-3", []string{"sdb", "sdc"},
+			map[string]string{"sdb": "running", "sdc": "offline"}, true},
+		{"multipathNoPath", "dm-3", nil, nil, true},
+		{"scsiBlocked", "sdb", nil, map[string]string{"sdb": "blocked"}, true},
+		{"nvme", "nvme0n1", nil, nil, false},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			files := map[string]string{}
+			for device, state := range c.states {
+				files["/sys/block/"+device+"/device/state"] = state
+			}
+			stubs := stubSysfs(files, map[string][]string{"/sys/block/dm-3/slaves": c.slaves})
+			defer stubs.Reset()
+			stubs.Stub(&getBlockDeviceName, func(ctx context.Context, devPath string) (string, error) {
+				return c.device, nil
+			})
+
+			message, err := CheckDevicePaths(context.Background(), "/dev/mapper/mpatha")
+			assert.NoError(t, err)
+			assert.Equal(t, c.abnormal, message != "", message)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	// luksMapperPrefix names the dm-crypt mappings of the volumes. The mappings are named after the
+	// volumes instead of the WWNs, so they are not taken as devices of the LUNs.
+	luksMapperPrefix = "luks-"
+	// luksCryptUUIDPrefix is the prefix of the dm uuid of the dm-crypt mappings
+	luksCryptUUIDPrefix = "CRYPT-"
+)
+
+var sysBlockPath = "/sys/block"
+
+// LUKSMapperPath returns the device path of the dm-crypt mapping of the volume
+func LUKSMapperPath(volume string) string {
+	return path.Join("/dev/mapper", luksMapperPrefix+volume)
+}
+
+// OpenLUKSDevice opens the dm-crypt mapping of the volume on the device with the passphrase, and returns
+// the path of the mapping. The device is formatted with LUKS first if it contains no data, a device
+// containing other data is refused. The key file is written in keyDir, which must be shared with the host.
+func OpenLUKSDevice(ctx context.Context, devPath, volume, passphrase, keyDir string) (string, error) {
+	mapperPath := LUKSMapperPath(volume)
+	if exist, _ := utils.PathExist(mapperPath); exist {
+		log.AddContext(ctx).Infof("LUKS mapping %s of volume %s is already open", mapperPath, volume)
+		return mapperPath, nil
+	}
+
+	keyFile, err := writeLUKSKey(keyDir, passphrase)
+	if err != nil {
+		return "", utils.Errorf(ctx, "Write the LUKS key of volume %s error: %v", volume, err)
+	}
+	defer func() {
+		if err := os.Remove(keyFile); err != nil {
+			log.AddContext(ctx).Warningf("Remove the LUKS key file %s error: %v", keyFile, err)
+		}
+	}()
+
+	if _, err := utils.ExecShellCmd(ctx, "cryptsetup isLuks %s", devPath); err != nil {
+		formatted, err := IsDeviceFormatted(ctx, devPath)
+		if err != nil {
+			return "", err
+		}
+		if formatted {
+			return "", utils.Errorf(ctx, "Device %s of volume %s contains data but is not LUKS encrypted",
+				devPath, volume)
+		}
+
+		output, err := utils.ExecShellCmd(ctx, "cryptsetup luksFormat --batch-mode --type luks2 --key-file %s %s",
+			keyFile, devPath)
+		if err != nil {
+			return "", utils.Errorf(ctx, "Format device %s with LUKS error: %v, output: %s", devPath, err, output)
+		}
+	}
+
+	// The volume key is kept in the dm-crypt target instead of the kernel keyring, so the mapping can be
+	// resized without the passphrase
+	output, err := utils.ExecShellCmd(ctx, "cryptsetup luksOpen --disable-keyring --key-file %s %s %s",
+		keyFile, devPath, luksMapperPrefix+volume)
+	if err != nil {
+		return "", utils.Errorf(ctx, "Open LUKS device %s error: %v, output: %s", devPath, err, output)
+	}
+
+	log.AddContext(ctx).Infof("Opened LUKS mapping %s of device %s", mapperPath, devPath)
+	return mapperPath, nil
+}
+
+// CloseLUKSDevice closes the dm-crypt mapping of the volume if it is open
+func CloseLUKSDevice(ctx context.Context, volume string) error {
+	mapperPath := LUKSMapperPath(volume)
+	if exist, _ := utils.PathExist(mapperPath); !exist {
+		return nil
+	}
+
+	output, err := utils.ExecShellCmd(ctx, "cryptsetup luksClose %s", luksMapperPrefix+volume)
+	if err != nil {
+		return utils.Errorf(ctx, "Close LUKS mapping %s error: %v, output: %s", mapperPath, err, output)
+	}
+	return nil
+}
+
+// ResizeLUKSDevice grows the dm-crypt mapping of the volume to its device if it is open
+func ResizeLUKSDevice(ctx context.Context, volume string) error {
+	mapperPath := LUKSMapperPath(volume)
+	if exist, _ := utils.PathExist(mapperPath); !exist {
+		return nil
+	}
+
+	output, err := utils.ExecShellCmd(ctx, "cryptsetup resize %s", luksMapperPrefix+volume)
+	if err != nil {
+		return utils.Errorf(ctx, "Resize LUKS mapping %s error: %v, output: %s", mapperPath, err, output)
+	}
+	return nil
+}
+
+// getLUKSBackingDevice returns the device under the dm-crypt mapping, other devices are returned as they are
+func getLUKSBackingDevice(device string) string {
+	uuid, err := ioutil.ReadFile(path.Join(sysBlockPath, device, "dm", "uuid"))
+	if err != nil || !strings.HasPrefix(string(uuid), luksCryptUUIDPrefix) {
+		return device
+	}
+
+	slaves, err := ioutil.ReadDir(path.Join(sysBlockPath, device, "slaves"))
+	if err != nil || len(slaves) != 1 {
+		return device
+	}
+	return slaves[0].Name()
+}
+
+// writeLUKSKey writes the passphrase into a file readable by root only, so it appears neither on the
+// command line nor in the logs
+func writeLUKSKey(keyDir, passphrase string) (string, error) {
+	file, err := ioutil.TempFile(keyDir, ".luks-key-")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = file.WriteString(passphrase)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+
+	return file.Name(), nil
+}
@@ -0,0 +1,44 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLUKSBackingDevice(t *testing.T) {
+	dir := t.TempDir()
+	stubs := gostub.Stub(&sysBlockPath, dir)
+	defer stubs.Reset()
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "dm-1", "dm"), 0750))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "dm-1", "slaves", "dm-0"), 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "dm-1", "dm", "uuid"),
+		[]byte("CRYPT-LUKS2-0123456789abcdef-luks-pvc-test\n"), 0640))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "dm-0", "dm"), 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "dm-0", "dm", "uuid"),
+		[]byte("mpath-36d0000000000000000000000000000ff\n"), 0640))
+
+	assert.Equal(t, "dm-0", getLUKSBackingDevice("dm-1"))
+	assert.Equal(t, "dm-0", getLUKSBackingDevice("dm-0"))
+	assert.Equal(t, "sdb", getLUKSBackingDevice("sdb"))
+}
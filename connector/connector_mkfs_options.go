@@ -0,0 +1,76 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// mkfsOptionArgs are the mkfs options the users may tune for each filesystem type, mapped to whether
+// the option takes an argument. The options forcing the format or setting the type are added by the driver.
+var mkfsOptionArgs = map[string]map[string]bool{
+	"ext": {
+		"-b": true, "-C": true, "-E": true, "-g": true, "-G": true, "-i": true, "-I": true, "-j": false,
+		"-J": true, "-L": true, "-m": true, "-N": true, "-O": true, "-T": true, "-U": true,
+	},
+	"xfs": {
+		"-b": true, "-d": true, "-i": true, "-K": false, "-l": true, "-L": true, "-m": true, "-n": true,
+		"-r": true, "-s": true,
+	},
+}
+
+// mkfsArgPattern matches the arguments of the mkfs options, the shell metacharacters are excluded since
+// mkfs runs in a shell
+var mkfsArgPattern = regexp.MustCompile(`^[A-Za-z0-9_.,:=+/%^]+$`)
+
+func mkfsFamily(fsType string) string {
+	switch fsType {
+	case "ext2", "ext3", "ext4":
+		return "ext"
+	default:
+		return fsType
+	}
+}
+
+// SplitMkfsOptions verifies the space separated mkfs options against the filesystem type and splits them,
+// e.g. "-b size=4096 -m crc=1" of xfs
+func SplitMkfsOptions(fsType, options string) ([]string, error) {
+	optionArgs, exist := mkfsOptionArgs[mkfsFamily(fsType)]
+	if !exist {
+		return nil, fmt.Errorf("mkfs options are not supported by filesystem %s", fsType)
+	}
+
+	fields := strings.Fields(options)
+	for i := 0; i < len(fields); i++ {
+		takesArg, exist := optionArgs[fields[i]]
+		if !exist {
+			return nil, fmt.Errorf("mkfs option %s is not supported by filesystem %s", fields[i], fsType)
+		}
+		if !takesArg {
+			continue
+		}
+
+		if i+1 == len(fields) || !mkfsArgPattern.MatchString(fields[i+1]) {
+			return nil, fmt.Errorf("mkfs option %s of filesystem %s requires a valid argument", fields[i], fsType)
+		}
+		i++
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,50 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitMkfsOptions(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		fsType  string
+		options string
+		fields  []string
+		valid   bool
+	}{
+		{"xfs", "xfs", "-b size=4096  -m crc=1", []string{"-b", "size=4096", "-m", "crc=1"}, true},
+		{"ext4", "ext4", "-i 65536 -m 1 -O ^has_journal -j", []string{"-i", "65536", "-m", "1", "-O",
+			"^has_journal", "-j"}, true},
+		{"extOptionOfXFS", "ext4", "-d agcount=4", nil, false},
+		{"missingArgument", "xfs", "-b", nil, false},
+		{"shellMetacharacters", "ext4", "-L data;reboot", nil, false},
+		{"forceFormat", "xfs", "-f", nil, false},
+		{"unsupportedFilesystem", "btrfs", "-n 16k", nil, false},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			fields, err := SplitMkfsOptions(c.fsType, c.options)
+			assert.Equal(t, c.valid, err == nil)
+			assert.Equal(t, c.fields, fields)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"fmt"
+	"strings"
+
+	"huawei-csi-driver/utils"
+)
+
+// dangerousMountOptions are never allowed in the mount flags of the volumes, they expose the node to the
+// contents of the volume or mount other things than the volume
+var dangerousMountOptions = map[string]string{
+	"loop":    "it mounts a file of the volume as a block device",
+	"suid":    "it honors the set-user-ID bits of the files of the volume",
+	"dev":     "it allows the device files of the volume to be opened",
+	"remount": "it changes an existing mount instead of mounting the volume",
+	"bind":    "it mounts another directory of the node",
+	"rbind":   "it mounts another directory tree of the node",
+}
+
+// conflictingMountOptions are pairs of mount options which cancel each other
+var conflictingMountOptions = [][2]string{
+	{"ro", "rw"},
+	{"sync", "async"},
+	{"hard", "soft"},
+	{"lock", "nolock"},
+}
+
+// nfsSecurityFlavors are the security flavors of the sec option of NFS, several of them are separated by ":"
+var nfsSecurityFlavors = []string{"none", "sys", "krb5", "krb5i", "krb5p"}
+
+func mountOptionName(option string) string {
+	return strings.SplitN(option, "=", 2)[0]
+}
+
+// isNFSv4Option tells whether the option selects NFS version 4.x, the locks of which are part of the
+// protocol and can't be disabled
+func isNFSv4Option(option string) bool {
+	for _, prefix := range []string{"vers=", "nfsvers="} {
+		if strings.HasPrefix(option, prefix) {
+			return strings.HasPrefix(strings.TrimPrefix(option, prefix), "4")
+		}
+	}
+
+	return false
+}
+
+// VerifyMountOptions checks the comma separated mount flags of a volume before they are passed to
+// "mount -o", it rejects the dangerous options, the options out of MountOptionAllowlist and the
+// options conflicting with each other
+func VerifyMountOptions(mountFlags []string) error {
+	names := make(map[string]bool)
+	var nfsV4 bool
+	for _, mountFlag := range mountFlags {
+		for _, option := range strings.Split(mountFlag, ",") {
+			option = strings.TrimSpace(option)
+			if option == "" {
+				continue
+			}
+
+			name := mountOptionName(option)
+			if reason, exist := dangerousMountOptions[name]; exist {
+				return fmt.Errorf("mount option %s is not allowed since %s", option, reason)
+			}
+			allowlist := GetSettings().MountOptionAllowlist
+			if len(allowlist) != 0 && !utils.IsContain(name, allowlist) {
+				return fmt.Errorf("mount option %s is not in the allowlist %v", option, allowlist)
+			}
+
+			if name == "sec" {
+				err := verifyNFSSecurityFlavors(option)
+				if err != nil {
+					return err
+				}
+			}
+
+			names[name] = true
+			nfsV4 = nfsV4 || isNFSv4Option(option)
+		}
+	}
+
+	for _, pair := range conflictingMountOptions {
+		if names[pair[0]] && names[pair[1]] {
+			return fmt.Errorf("mount options %s and %s conflict", pair[0], pair[1])
+		}
+	}
+
+	if nfsV4 && names["nolock"] {
+		return fmt.Errorf("mount option nolock conflicts with NFS version 4, whose locks can't be disabled")
+	}
+
+	return nil
+}
+
+// verifyNFSSecurityFlavors checks the flavors of the sec option, which the mount of NFS rejects with an
+// obscure error
+func verifyNFSSecurityFlavors(option string) error {
+	flavors := strings.TrimPrefix(option, "sec=")
+	for _, flavor := range strings.Split(flavors, ":") {
+		if !utils.IsContain(flavor, nfsSecurityFlavors) {
+			return fmt.Errorf("security flavor %q of mount option %s must be one of %v", flavor, option,
+				nfsSecurityFlavors)
+		}
+	}
+	return nil
+}
+
+// VerifyMountOptionAllowlist checks that the allowlist contains no dangerous mount options
+func VerifyMountOptionAllowlist(allowlist []string) error {
+	for _, name := range allowlist {
+		if name == "" || strings.ContainsAny(name, ",=") {
+			return fmt.Errorf("invalid mount option name %q", name)
+		}
+		if reason, exist := dangerousMountOptions[name]; exist {
+			return fmt.Errorf("mount option %s can't be allowed since %s", name, reason)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyMountOptions(t *testing.T) {
+	var testCases = []struct {
+		name       string
+		mountFlags []string
+		valid      bool
+	}{
+		{"empty", nil, true},
+		{"nfsOptions", []string{"vers=3,nolock", "hard", "noatime"}, true},
+		{"loop", []string{"loop"}, false},
+		{"suid", []string{"noatime,suid"}, false},
+		{"readOnlyAndReadWrite", []string{"rw", "ro"}, false},
+		{"nolockWithV4", []string{"nfsvers=4.1", "nolock"}, false},
+		{"nolockWithV3", []string{"nfsvers=3", "nolock"}, true},
+		{"kerberos", []string{"sec=krb5p:krb5i", "vers=4.1"}, true},
+		{"unknownSecurity", []string{"sec=krb6"}, false},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.valid, VerifyMountOptions(c.mountFlags) == nil)
+		})
+	}
+}
+
+func TestVerifyMountOptionsAllowlist(t *testing.T) {
+	settings := GetSettings()
+	settings.MountOptionAllowlist = []string{"vers", "hard"}
+	stubs := gostub.Stub(&currentSettings, settings)
+	defer stubs.Reset()
+
+	assert.NoError(t, VerifyMountOptions([]string{"vers=3,hard"}))
+	assert.Error(t, VerifyMountOptions([]string{"vers=3,noatime"}))
+
+	assert.NoError(t, VerifyMountOptionAllowlist([]string{"noatime", "vers"}))
+	assert.Error(t, VerifyMountOptionAllowlist([]string{"loop"}))
+	assert.Error(t, VerifyMountOptionAllowlist([]string{"vers=3"}))
+}
@@ -0,0 +1,182 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	// multipathConfigDir is where multipathd reads the config snippets besides /etc/multipath.conf
+	multipathConfigDir = "/etc/multipath/conf.d"
+	// ManagedMultipathConfig is the config snippet applied by the driver
+	ManagedMultipathConfig = multipathConfigDir + "/huawei-csi.conf"
+
+	huaweiVendor  = "HUAWEI"
+	huaweiProduct = "XSG1"
+)
+
+type multipathSetting struct {
+	key   string
+	value string
+}
+
+var (
+	recommendedMultipathDefaults = []multipathSetting{
+		{"user_friendly_names", "yes"},
+		{"find_multipaths", "no"},
+	}
+	recommendedHuaweiDevice = []multipathSetting{
+		{"path_checker", "tur"},
+		{"failback", "immediate"},
+		{"no_path_retry", "15"},
+		{"fast_io_fail_tmo", "5"},
+		{"dev_loss_tmo", "30"},
+	}
+)
+
+// multipathConfig is the defaults section and the device sections of the DM-multipath config
+type multipathConfig struct {
+	defaults map[string]string
+	devices  []map[string]string
+}
+
+// parseMultipathConfig parses the sections of the DM-multipath config, the later settings
+// override the earlier ones like multipathd does
+func parseMultipathConfig(data string) multipathConfig {
+	config := multipathConfig{defaults: map[string]string{}}
+	var sections []string
+	var device map[string]string
+	for _, line := range strings.Split(data, "\n") {
+		if index := strings.IndexAny(line, "#!"); index >= 0 {
+			line = line[:index]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[len(fields)-1] == "{" {
+			sections = append(sections, fields[0])
+			if fields[0] == "device" {
+				device = map[string]string{}
+			}
+			continue
+		}
+
+		if fields[0] == "}" {
+			if len(sections) != 0 && sections[len(sections)-1] == "device" && device != nil {
+				config.devices = append(config.devices, device)
+				device = nil
+			}
+			if len(sections) != 0 {
+				sections = sections[:len(sections)-1]
+			}
+			continue
+		}
+
+		if len(sections) == 0 {
+			continue
+		}
+		value := strings.Trim(strings.Join(fields[1:], " "), `"`)
+		switch {
+		case len(sections) == 1 && sections[0] == "defaults":
+			config.defaults[fields[0]] = value
+		case len(sections) == 2 && sections[0] == "devices" && device != nil:
+			device[fields[0]] = value
+		}
+	}
+
+	return config
+}
+
+// checkMultipathConfig returns how the config drifts from the recommended settings
+func checkMultipathConfig(config multipathConfig) []string {
+	var drifts []string
+	for _, setting := range recommendedMultipathDefaults {
+		if config.defaults[setting.key] != setting.value {
+			drifts = append(drifts, fmt.Sprintf("defaults %s is %q instead of %q",
+				setting.key, config.defaults[setting.key], setting.value))
+		}
+	}
+
+	var huaweiDevice map[string]string
+	for _, device := range config.devices {
+		if device["vendor"] == huaweiVendor && device["product"] == huaweiProduct {
+			huaweiDevice = device
+		}
+	}
+	if huaweiDevice == nil {
+		return append(drifts, fmt.Sprintf("device section of vendor %s product %s is missing",
+			huaweiVendor, huaweiProduct))
+	}
+
+	for _, setting := range recommendedHuaweiDevice {
+		if huaweiDevice[setting.key] != setting.value {
+			drifts = append(drifts, fmt.Sprintf("device %s is %q instead of %q",
+				setting.key, huaweiDevice[setting.key], setting.value))
+		}
+	}
+	return drifts
+}
+
+// CheckMultipathConfig returns how the DM-multipath config of the node, including the config
+// snippets, drifts from the settings recommended for Huawei storage
+func CheckMultipathConfig(ctx context.Context) ([]string, error) {
+	output, err := utils.ExecShellCmd(ctx, "cat /etc/multipath.conf %s/*.conf 2>/dev/null; true",
+		multipathConfigDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkMultipathConfig(parseMultipathConfig(output)), nil
+}
+
+// managedMultipathConfig returns the config snippet of the recommended settings
+func managedMultipathConfig() string {
+	var b strings.Builder
+	b.WriteString("# Managed by huawei-csi, changes will be overwritten\ndefaults {\n")
+	for _, setting := range recommendedMultipathDefaults {
+		fmt.Fprintf(&b, "\t%s %s\n", setting.key, setting.value)
+	}
+	fmt.Fprintf(&b, "}\ndevices {\n\tdevice {\n\t\tvendor \"%s\"\n\t\tproduct \"%s\"\n", huaweiVendor, huaweiProduct)
+	for _, setting := range recommendedHuaweiDevice {
+		fmt.Fprintf(&b, "\t\t%s %s\n", setting.key, setting.value)
+	}
+	b.WriteString("\t}\n}\n")
+	return b.String()
+}
+
+// ApplyMultipathConfig writes the recommended settings as the managed config snippet, which
+// overrides /etc/multipath.conf, and reloads multipathd
+func ApplyMultipathConfig(ctx context.Context) error {
+	output, err := utils.ExecShellCmd(ctx, "mkdir -p %s && printf '%%s' '%s' > %s && multipathd reconfigure",
+		multipathConfigDir, managedMultipathConfig(), ManagedMultipathConfig)
+	if err != nil {
+		return utils.Errorf(ctx, "apply multipath config %s error: %v, output: %s",
+			ManagedMultipathConfig, err, output)
+	}
+
+	log.AddContext(ctx).Infof("Applied the recommended multipath config %s and reloaded multipathd",
+		ManagedMultipathConfig)
+	return nil
+}
@@ -0,0 +1,58 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckMultipathConfig(t *testing.T) {
+	const drifted = `
+defaults {
+	user_friendly_names yes
+	find_multipaths yes  # not recommended
+}
+blacklist {
+	devnode "^sda"
+}
+devices {
+	device {
+		vendor "HUAWEI"
+		product "XSG1"
+		path_checker tur
+		failback immediate
+		no_path_retry fail
+		fast_io_fail_tmo 5
+		dev_loss_tmo 30
+	}
+}
+`
+	drifts := checkMultipathConfig(parseMultipathConfig(drifted))
+	assert.Equal(t, []string{
+		`defaults find_multipaths is "yes" instead of "no"`,
+		`device no_path_retry is "fail" instead of "15"`,
+	}, drifts)
+
+	// The managed snippet is read after /etc/multipath.conf and overrides it
+	drifts = checkMultipathConfig(parseMultipathConfig(drifted + managedMultipathConfig()))
+	assert.Empty(t, drifts)
+
+	drifts = checkMultipathConfig(parseMultipathConfig(""))
+	assert.Contains(t, drifts, "device section of vendor HUAWEI product XSG1 is missing")
+}
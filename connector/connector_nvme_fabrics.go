@@ -0,0 +1,354 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	nvmeDiscoveryNQN  = "nqn.2014-08.org.nvmexpress.discovery"
+	nvmeDefaultPort   = "4420"
+	nvmeHostNQNFile   = "/etc/nvme/hostnqn"
+	nvmeHostIDFile    = "/etc/nvme/hostid"
+	nvmeSubsystemType = 2
+
+	nvmeAdminGetLogPage       = 0x02
+	nvmeDiscoveryLogPage      = 0x70
+	nvmeDiscoveryLogEntrySize = 1024
+	nvmeDiscoveryLogRetries   = 3
+	// nvmeIoctlAdminCmd is _IOWR('N', 0x41, struct nvme_admin_cmd)
+	nvmeIoctlAdminCmd = 0xC0484E41
+)
+
+var (
+	nvmeFabricsDevice      = "/dev/nvme-fabrics"
+	nvmeClassPath          = "/sys/class/nvme"
+	nvmeSubsystemClassPath = "/sys/class/nvme-subsystem"
+
+	nvmeControllerPattern = regexp.MustCompile(`^nvme[0-9]+$`)
+	nvmeInstancePattern   = regexp.MustCompile(`instance=([0-9]+)`)
+)
+
+// NVMeFabricsTarget is the target of an NVMe over fabrics connection, the default host NQN is used
+// if HostNQN is empty, and the kernel chooses the host address if HostAddress is empty
+type NVMeFabricsTarget struct {
+	Transport   string
+	Address     string
+	Port        string
+	NQN         string
+	HostNQN     string
+	HostAddress string
+}
+
+// NVMeDiscoveryEntry is an entry of the discovery log page of an NVMe over fabrics discovery controller
+type NVMeDiscoveryEntry struct {
+	SubType uint8
+	Port    string
+	NQN     string
+	Address string
+}
+
+// nvmeAdminCmd is the struct nvme_admin_cmd of the NVMe ioctl
+type nvmeAdminCmd struct {
+	opcode      uint8
+	flags       uint8
+	rsvd1       uint16
+	nsid        uint32
+	cdw2        uint32
+	cdw3        uint32
+	metadata    uint64
+	addr        uint64
+	metadataLen uint32
+	dataLen     uint32
+	cdw10       uint32
+	cdw11       uint32
+	cdw12       uint32
+	cdw13       uint32
+	cdw14       uint32
+	cdw15       uint32
+	timeoutMs   uint32
+	result      uint32
+}
+
+// IsNVMeAlreadyConnected is whether the connection error means the controller is connected already
+func IsNVMeAlreadyConnected(err error) bool {
+	return errors.Is(err, syscall.EALREADY) || errors.Is(err, syscall.EIO)
+}
+
+func writeSysfsValue(ctx context.Context, file, value string) error {
+	output, err := utils.ExecShellCmd(ctx, "echo %s > %s", value, file)
+	if err != nil {
+		return fmt.Errorf("write %s error: %s", file, output)
+	}
+	return nil
+}
+
+// fabricsOptions returns the options written to /dev/nvme-fabrics to connect the target
+func fabricsOptions(ctx context.Context, target NVMeFabricsTarget, transportOptions *NVMeTransportOptions) string {
+	port := target.Port
+	if port == "" {
+		port = nvmeDefaultPort
+	}
+	options := []string{"nqn=" + target.NQN, "transport=" + target.Transport, "traddr=" + target.Address,
+		"trsvcid=" + port}
+
+	// the kernel generates a random host NQN if none is given, which is not mapped on the storage
+	hostNQN := target.HostNQN
+	if hostNQN == "" {
+		hostNQN, _ = readSysfsValue(ctx, nvmeHostNQNFile)
+	}
+	if hostNQN != "" {
+		options = append(options, "hostnqn="+hostNQN)
+	}
+	if hostID, err := readSysfsValue(ctx, nvmeHostIDFile); err == nil && hostID != "" {
+		options = append(options, "hostid="+hostID)
+	}
+	if target.HostAddress != "" {
+		options = append(options, "host_traddr="+target.HostAddress)
+	}
+
+	options = append(options, transportOptions.FabricsArgs()...)
+	return strings.Join(options, ",")
+}
+
+// writeNVMeFabrics creates a controller with the options and returns the answer of the kernel,
+// which is like "instance=1,cntlid=2"
+var writeNVMeFabrics = func(ctx context.Context, options string) (string, error) {
+	if exist, _ := utils.PathExist(nvmeFabricsDevice); !exist {
+		if output, err := utils.ExecShellCmd(ctx, "modprobe nvme-fabrics"); err != nil {
+			return "", fmt.Errorf("load module nvme-fabrics error: %s", output)
+		}
+	}
+
+	file, err := os.OpenFile(nvmeFabricsDevice, os.O_RDWR, 0)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err = file.WriteString(options); err != nil {
+		return "", err
+	}
+
+	answer := make([]byte, 128)
+	n, err := file.Read(answer)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(answer[:n])), nil
+}
+
+// ConnectNVMeFabrics connects the NVMe over fabrics target through /dev/nvme-fabrics and returns
+// the name of the created controller, such as nvme1
+var ConnectNVMeFabrics = func(ctx context.Context, target NVMeFabricsTarget,
+	transportOptions *NVMeTransportOptions) (string, error) {
+	options := fabricsOptions(ctx, target, transportOptions)
+	log.AddContext(ctx).Infof("Connect NVMe target with options %s", options)
+	answer, err := writeNVMeFabrics(ctx, options)
+	if err != nil {
+		return "", err
+	}
+
+	match := nvmeInstancePattern.FindStringSubmatch(answer)
+	if len(match) != 2 {
+		return "", utils.Errorf(ctx, "unexpected answer %s of connecting NVMe target %s", answer, target.Address)
+	}
+	return "nvme" + match[1], nil
+}
+
+// DisconnectNVMeController deletes the NVMe over fabrics controller, such as nvme1
+var DisconnectNVMeController = func(ctx context.Context, controller string) error {
+	return writeSysfsValue(ctx, path.Join(nvmeClassPath, controller, "delete_controller"), "1")
+}
+
+// nvmeAdminGetLog reads the log page of the controller into the buffer with an admin command
+var nvmeAdminGetLog = func(ctx context.Context, controller string, logID uint8, buf []byte) error {
+	file, err := os.OpenFile(path.Join("/dev", controller), os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// the number of dwords is 0's based
+	numd := uint32(len(buf)/4 - 1)
+	cmd := nvmeAdminCmd{
+		opcode:  nvmeAdminGetLogPage,
+		addr:    uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		dataLen: uint32(len(buf)),
+		cdw10:   uint32(logID) | numd<<16,
+		cdw11:   numd >> 16,
+	}
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, file.Fd(), nvmeIoctlAdminCmd, uintptr(unsafe.Pointer(&cmd)))
+	runtime.KeepAlive(buf)
+	if errno != 0 {
+		return errno
+	}
+	if cmd.result != 0 {
+		return fmt.Errorf("get log page %#x of %s returns status %#x", logID, controller, cmd.result)
+	}
+	return nil
+}
+
+func trimNullString(data []byte) string {
+	return strings.TrimSpace(strings.TrimRight(string(data), "\x00"))
+}
+
+// parseDiscoveryLog parses the entries of the discovery log page
+func parseDiscoveryLog(data []byte) []NVMeDiscoveryEntry {
+	var entries []NVMeDiscoveryEntry
+	for offset := nvmeDiscoveryLogEntrySize; offset+nvmeDiscoveryLogEntrySize <= len(data); offset += nvmeDiscoveryLogEntrySize {
+		entry := data[offset : offset+nvmeDiscoveryLogEntrySize]
+		entries = append(entries, NVMeDiscoveryEntry{
+			SubType: entry[2],
+			Port:    trimNullString(entry[32:64]),
+			NQN:     trimNullString(entry[256:512]),
+			Address: trimNullString(entry[512:768]),
+		})
+	}
+	return entries
+}
+
+func readDiscoveryLog(ctx context.Context, controller string) ([]NVMeDiscoveryEntry, error) {
+	for i := 0; i < nvmeDiscoveryLogRetries; i++ {
+		header := make([]byte, nvmeDiscoveryLogEntrySize)
+		if err := nvmeAdminGetLog(ctx, controller, nvmeDiscoveryLogPage, header); err != nil {
+			return nil, err
+		}
+
+		generation := binary.LittleEndian.Uint64(header[0:8])
+		records := binary.LittleEndian.Uint64(header[8:16])
+		data := make([]byte, nvmeDiscoveryLogEntrySize*(records+1))
+		if err := nvmeAdminGetLog(ctx, controller, nvmeDiscoveryLogPage, data); err != nil {
+			return nil, err
+		}
+
+		// the records are consistent only if they are not changed between the two reads
+		if binary.LittleEndian.Uint64(data[0:8]) == generation {
+			return parseDiscoveryLog(data), nil
+		}
+	}
+
+	return nil, utils.Errorf(ctx, "discovery log of %s keeps changing", controller)
+}
+
+// DiscoverNVMeSubsystems returns the NVMe subsystems of the discovery log page of the target
+var DiscoverNVMeSubsystems = func(ctx context.Context, target NVMeFabricsTarget) ([]NVMeDiscoveryEntry, error) {
+	target.NQN = nvmeDiscoveryNQN
+	controller, err := ConnectNVMeFabrics(ctx, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := DisconnectNVMeController(ctx, controller); err != nil {
+			log.AddContext(ctx).Warningf("Disconnect discovery controller %s error: %v", controller, err)
+		}
+	}()
+
+	entries, err := readDiscoveryLog(ctx, controller)
+	if err != nil {
+		return nil, err
+	}
+
+	var subsystems []NVMeDiscoveryEntry
+	for _, entry := range entries {
+		if entry.SubType == nvmeSubsystemType {
+			subsystems = append(subsystems, entry)
+		}
+	}
+	return subsystems, nil
+}
+
+// listNVMeSubsystems lists the NVMe subsystems and their controllers from sysfs, in the format
+// of nvme list-subsys -o json
+func listNVMeSubsystems(ctx context.Context) (map[string]interface{}, error) {
+	subsystemNames, err := listSysfsDir(ctx, nvmeSubsystemClassPath)
+	if err != nil {
+		return nil, err
+	}
+
+	subsystems := make([]interface{}, 0, len(subsystemNames))
+	for _, subsystemName := range subsystemNames {
+		subsystemPath := path.Join(nvmeSubsystemClassPath, subsystemName)
+		nqn, err := readSysfsValue(ctx, path.Join(subsystemPath, "subsysnqn"))
+		if err != nil {
+			log.AddContext(ctx).Warningf("Get NQN of NVMe subsystem %s error: %v", subsystemName, err)
+			continue
+		}
+
+		entries, err := listSysfsDir(ctx, subsystemPath)
+		if err != nil {
+			log.AddContext(ctx).Warningf("List controllers of NVMe subsystem %s error: %v", subsystemName, err)
+			continue
+		}
+
+		paths := make([]interface{}, 0)
+		for _, controller := range entries {
+			if !nvmeControllerPattern.MatchString(controller) {
+				continue
+			}
+
+			controllerPath := path.Join(nvmeClassPath, controller)
+			transport, _ := readSysfsValue(ctx, path.Join(controllerPath, "transport"))
+			address, _ := readSysfsValue(ctx, path.Join(controllerPath, "address"))
+			state, _ := readSysfsValue(ctx, path.Join(controllerPath, "state"))
+			paths = append(paths, map[string]interface{}{
+				"Name":      controller,
+				"Transport": transport,
+				"Address":   strings.ReplaceAll(address, ",", " "),
+				"State":     state,
+			})
+		}
+
+		subsystems = append(subsystems, map[string]interface{}{
+			"Name":  subsystemName,
+			"NQN":   nqn,
+			"Paths": paths,
+		})
+	}
+
+	return map[string]interface{}{"Subsystems": subsystems}, nil
+}
+
+// getNVMeNGUID returns the NGUID of the NVMe namespace, such as nvme0n1, without the dashes
+func getNVMeNGUID(ctx context.Context, namespace string) (string, error) {
+	nguid, err := readSysfsValue(ctx, path.Join("/sys/block", namespace, "nguid"))
+	if err != nil {
+		return "", err
+	}
+
+	nguid = strings.ReplaceAll(nguid, "-", "")
+	if strings.Trim(nguid, "0") == "" {
+		return "", fmt.Errorf("there is no nguid of namespace %s", namespace)
+	}
+	return nguid, nil
+}
@@ -0,0 +1,133 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"encoding/binary"
+	"syscall"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectNVMeFabrics(t *testing.T) {
+	ctx := context.Background()
+	stubs := stubSysfs(map[string]string{nvmeHostNQNFile: "nqn.2014-08.org.nvmexpress:uuid:a08ce5a6"}, nil)
+	defer stubs.Reset()
+	var written string
+	stubs.Stub(&writeNVMeFabrics, func(ctx context.Context, options string) (string, error) {
+		written = options
+		return "instance=3,cntlid=1", nil
+	})
+
+	controller, err := ConnectNVMeFabrics(ctx, NVMeFabricsTarget{Transport: NVMeTransportRDMA,
+		Address: "192.168.1.1", NQN: "nqn.2020-02.huawei.nvme:nvm-subsystem-sn-1", HostAddress: "192.168.1.2"},
+		&NVMeTransportOptions{QueueSize: 128})
+	assert.NoError(t, err)
+	assert.Equal(t, "nvme3", controller)
+	assert.Equal(t, "nqn=nqn.2020-02.huawei.nvme:nvm-subsystem-sn-1,transport=rdma,traddr=192.168.1.1,"+
+		"trsvcid=4420,hostnqn=nqn.2014-08.org.nvmexpress:uuid:a08ce5a6,host_traddr=192.168.1.2,queue_size=128",
+		written)
+
+	stubs.Stub(&writeNVMeFabrics, func(ctx context.Context, options string) (string, error) {
+		return "", syscall.EALREADY
+	})
+	_, err = ConnectNVMeFabrics(ctx, NVMeFabricsTarget{Transport: NVMeTransportRDMA, Address: "192.168.1.1"}, nil)
+	assert.True(t, IsNVMeAlreadyConnected(err))
+}
+
+func TestDiscoverNVMeSubsystems(t *testing.T) {
+	entry := func(subType uint8, nqn, address string) []byte {
+		data := make([]byte, nvmeDiscoveryLogEntrySize)
+		data[2] = subType
+		copy(data[32:], "4420")
+		copy(data[256:], nqn)
+		copy(data[512:], address)
+		return data
+	}
+	discoveryLog := make([]byte, nvmeDiscoveryLogEntrySize)
+	binary.LittleEndian.PutUint64(discoveryLog[0:8], 7)
+	binary.LittleEndian.PutUint64(discoveryLog[8:16], 2)
+	discoveryLog = append(discoveryLog, entry(3, nvmeDiscoveryNQN, "192.168.1.1")...)
+	discoveryLog = append(discoveryLog, entry(nvmeSubsystemType, "nqn.2020-02.huawei.nvme:sn-1", "192.168.1.1")...)
+
+	stubs := gostub.Stub(&ConnectNVMeFabrics, func(ctx context.Context, target NVMeFabricsTarget,
+		transportOptions *NVMeTransportOptions) (string, error) {
+		assert.Equal(t, nvmeDiscoveryNQN, target.NQN)
+		return "nvme5", nil
+	})
+	defer stubs.Reset()
+	var disconnected string
+	stubs.Stub(&DisconnectNVMeController, func(ctx context.Context, controller string) error {
+		disconnected = controller
+		return nil
+	})
+	stubs.Stub(&nvmeAdminGetLog, func(ctx context.Context, controller string, logID uint8, buf []byte) error {
+		copy(buf, discoveryLog)
+		return nil
+	})
+
+	subsystems, err := DiscoverNVMeSubsystems(context.Background(), NVMeFabricsTarget{
+		Transport: NVMeTransportRDMA, Address: "192.168.1.1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []NVMeDiscoveryEntry{{SubType: nvmeSubsystemType, Port: "4420",
+		NQN: "nqn.2020-02.huawei.nvme:sn-1", Address: "192.168.1.1"}}, subsystems)
+	assert.Equal(t, "nvme5", disconnected)
+}
+
+func TestGetSubSysInfo(t *testing.T) {
+	stubs := stubSysfs(map[string]string{
+		"/sys/class/nvme-subsystem/nvme-subsys0/subsysnqn": "nqn.2020-02.huawei.nvme:sn-1",
+		"/sys/class/nvme/nvme0/transport":                  "rdma",
+		"/sys/class/nvme/nvme0/address":                    "traddr=192.168.1.1,trsvcid=4420",
+		"/sys/class/nvme/nvme0/state":                      "live",
+	}, map[string][]string{
+		"/sys/class/nvme-subsystem":              {"nvme-subsys0"},
+		"/sys/class/nvme-subsystem/nvme-subsys0": {"iopolicy", "nvme0", "nvme0n1", "subsysnqn"},
+	})
+	defer stubs.Reset()
+
+	info, err := GetSubSysInfo(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"Subsystems": []interface{}{map[string]interface{}{
+		"Name": "nvme-subsys0",
+		"NQN":  "nqn.2020-02.huawei.nvme:sn-1",
+		"Paths": []interface{}{map[string]interface{}{
+			"Name":      "nvme0",
+			"Transport": "rdma",
+			"Address":   "traddr=192.168.1.1 trsvcid=4420",
+			"State":     "live",
+		}},
+	}}}, info)
+}
+
+func TestGetNVMeWwn(t *testing.T) {
+	stubs := stubSysfs(map[string]string{
+		"/sys/block/nvme0n1/nguid": "7100e98b-8e19-b76d-00e4-069a00000003",
+		"/sys/block/nvme1n1/nguid": "00000000-0000-0000-0000-000000000000",
+	}, nil)
+	defer stubs.Reset()
+
+	wwn, err := GetNVMeWwn(context.Background(), "/dev/nvme0n1")
+	assert.NoError(t, err)
+	assert.Equal(t, "7100e98b8e19b76d00e4069a00000003", wwn)
+
+	_, err = GetNVMeWwn(context.Background(), "/dev/nvme1n1")
+	assert.Error(t, err)
+}
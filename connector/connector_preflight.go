@@ -0,0 +1,176 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// protocolRequirement lists the commands and kernel modules a protocol needs on the node
+type protocolRequirement struct {
+	commands []string
+	modules  []string
+	// nvme means the protocol uses the nvme multipath type instead of the scsi one
+	nvme bool
+}
+
+var protocolRequirements = map[string]protocolRequirement{
+	"iscsi":   {commands: []string{"iscsiadm"}, modules: []string{"iscsi_tcp"}},
+	"fc":      {modules: []string{"scsi_transport_fc"}},
+	"roce":    {modules: []string{"nvme_rdma"}, nvme: true},
+	"fc-nvme": {modules: []string{"nvme_fc"}, nvme: true},
+	"nfs":     {commands: []string{"mount.nfs"}, modules: []string{"nfs"}},
+	"cifs":    {commands: []string{"mount.cifs"}, modules: []string{"cifs"}},
+}
+
+var multiPathCommands = map[string]string{
+	DMMultiPath:     "multipath",
+	HWUltraPath:     "upadmin",
+	HWUltraPathNVMe: "upadmin",
+}
+
+var (
+	preflightMutex   sync.RWMutex
+	preflightResults = map[string]error{}
+)
+
+var commandExists = func(ctx context.Context, command string) bool {
+	_, err := utils.ExecShellCmd(ctx, "command -v %s", command)
+	return err == nil
+}
+
+// kernelModuleProbes are the checks which pass once the kernel module is loaded or built in, they detect
+// the modules whose /sys/module entry is missing and which modinfo cannot find without /lib/modules
+var kernelModuleProbes = map[string]string{
+	"iscsi_tcp":         "test -d /sys/class/iscsi_transport/tcp",
+	"scsi_transport_fc": "test -d /sys/class/fc_transport",
+	"nvme_fc":           "test -d /sys/class/fc/fc_udev_device",
+	"nfs":               "grep -qw nfs /proc/filesystems",
+	"cifs":              "grep -qw cifs /proc/filesystems",
+}
+
+var kernelModuleExists = func(ctx context.Context, module string) bool {
+	checks := []string{"test -d /sys/module/" + module, "modprobe -n " + module, "modinfo " + module}
+	if probe, exist := kernelModuleProbes[module]; exist {
+		checks = append(checks, probe)
+	}
+
+	_, err := utils.ExecShellCmd(ctx, "%s", strings.Join(checks, " || "))
+	return err == nil
+}
+
+// VerifyDisabledProtocols checks that the disabled protocols are supported ones
+func VerifyDisabledProtocols(protocols []string) error {
+	for _, protocol := range protocols {
+		if _, exist := protocolRequirements[protocol]; !exist {
+			return fmt.Errorf("disabled protocol %s is not one of iscsi, fc, roce, fc-nvme, nfs and cifs", protocol)
+		}
+	}
+
+	return nil
+}
+
+// Preflight detects whether the commands and kernel modules required by the protocols are
+// available on the node. The result is returned by PreflightResults, and a protocol with
+// missing tools is refused by VerifyProtocol. A missing kernel module is only warned, since
+// it may be autoloaded on first use.
+func Preflight(ctx context.Context, protocols []string,
+	useMultiPath bool, scsiMultiPathType, nvmeMultiPathType string) {
+	results := make(map[string]error, len(protocols))
+	for _, protocol := range protocols {
+		if utils.IsContain(protocol, GetSettings().DisabledProtocols) {
+			results[protocol] = utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+				"protocol %s is disabled on this node", protocol)
+			continue
+		}
+
+		requirement, exist := protocolRequirements[protocol]
+		if !exist {
+			results[protocol] = nil
+			continue
+		}
+
+		commands := requirement.commands
+		if useMultiPath {
+			multiPathType := scsiMultiPathType
+			if requirement.nvme {
+				multiPathType = nvmeMultiPathType
+			}
+			if command, exist := multiPathCommands[multiPathType]; exist {
+				commands = append(append([]string{}, commands...), command)
+			}
+		}
+
+		var missing []string
+		for _, command := range commands {
+			if !commandExists(ctx, command) {
+				missing = append(missing, "missing tool "+command)
+			}
+		}
+		for _, module := range requirement.modules {
+			if !kernelModuleExists(ctx, module) {
+				log.AddContext(ctx).Warningf("Kernel module %s of protocol %s is not found, "+
+					"the protocol does not work if the module cannot be loaded", module, protocol)
+			}
+		}
+
+		if len(missing) > 0 {
+			results[protocol] = utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+				"protocol %s is not usable on this node: %s", protocol, strings.Join(missing, ", "))
+			continue
+		}
+
+		log.AddContext(ctx).Infof("Protocol %s is usable on this node", protocol)
+		results[protocol] = nil
+	}
+
+	preflightMutex.Lock()
+	defer preflightMutex.Unlock()
+	preflightResults = results
+}
+
+// PreflightResults returns the checked protocols, mapped to the reason why they are not
+// usable, or nil for the usable ones
+func PreflightResults() map[string]error {
+	preflightMutex.RLock()
+	defer preflightMutex.RUnlock()
+
+	results := make(map[string]error, len(preflightResults))
+	for protocol, err := range preflightResults {
+		results[protocol] = err
+	}
+	return results
+}
+
+// VerifyProtocol returns the preflight error of the protocol, nil if the protocol is usable
+// or has not been checked
+func VerifyProtocol(ctx context.Context, protocol string) error {
+	preflightMutex.RLock()
+	defer preflightMutex.RUnlock()
+
+	err := preflightResults[protocol]
+	if err != nil {
+		log.AddContext(ctx).Errorf("Verify protocol %s error: %v", protocol, err)
+	}
+	return err
+}
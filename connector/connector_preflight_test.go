@@ -0,0 +1,107 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/utils"
+)
+
+func TestPreflight(t *testing.T) {
+	ctx := context.Background()
+	stubs := gostub.Stub(&commandExists, func(ctx context.Context, command string) bool {
+		return command != "upadmin"
+	})
+	defer stubs.Reset()
+	stubs.Stub(&kernelModuleExists, func(ctx context.Context, module string) bool {
+		return module != "nfs"
+	})
+	defer func() {
+		preflightResults = map[string]error{}
+	}()
+
+	Preflight(ctx, []string{"iscsi", "roce", "nfs", "scsi"}, true, DMMultiPath, HWUltraPathNVMe)
+
+	results := PreflightResults()
+	assert.Len(t, results, 4)
+	assert.NoError(t, results["iscsi"])
+	assert.NoError(t, results["scsi"])
+	assert.Contains(t, results["roce"].Error(), "protocol roce is not usable on this node: missing tool upadmin")
+	// a missing kernel module may be autoloaded, so it does not make the protocol unusable
+	assert.NoError(t, results["nfs"])
+
+	assert.NoError(t, VerifyProtocol(ctx, "iscsi"))
+	assert.NoError(t, VerifyProtocol(ctx, "fc"))
+	assert.Error(t, VerifyProtocol(ctx, "roce"))
+}
+
+func TestKernelModuleExists(t *testing.T) {
+	var command string
+	stubs := gostub.Stub(&utils.ExecShellCmd, func(ctx context.Context, format string, args ...interface{}) (
+		string, error) {
+		command = fmt.Sprintf(format, args...)
+		return "", nil
+	})
+	defer stubs.Reset()
+
+	assert.True(t, kernelModuleExists(context.Background(), "nfs"))
+	assert.Equal(t, "test -d /sys/module/nfs || modprobe -n nfs || modinfo nfs || "+
+		"grep -qw nfs /proc/filesystems", command)
+
+	stubs.Stub(&utils.ExecShellCmd, func(ctx context.Context, format string, args ...interface{}) (
+		string, error) {
+		return "", errors.New("exit status 1")
+	})
+	assert.False(t, kernelModuleExists(context.Background(), "nvme_rdma"))
+}
+
+func TestPreflightDisabledProtocols(t *testing.T) {
+	ctx := context.Background()
+	var probed []string
+	stubs := gostub.Stub(&commandExists, func(ctx context.Context, command string) bool {
+		probed = append(probed, command)
+		return true
+	})
+	defer stubs.Reset()
+	stubs.Stub(&kernelModuleExists, func(ctx context.Context, module string) bool {
+		probed = append(probed, module)
+		return true
+	})
+	settings := GetSettings()
+	settings.DisabledProtocols = []string{"fc", "roce"}
+	stubs.Stub(&currentSettings, settings)
+	defer func() {
+		preflightResults = map[string]error{}
+	}()
+
+	Preflight(ctx, []string{"iscsi", "fc", "roce"}, false, DMMultiPath, HWUltraPathNVMe)
+
+	assert.Equal(t, []string{"iscsiadm", "iscsi_tcp"}, probed)
+	assert.NoError(t, VerifyProtocol(ctx, "iscsi"))
+	assert.Contains(t, VerifyProtocol(ctx, "fc").Error(), "protocol fc is disabled on this node")
+	assert.Error(t, VerifyProtocol(ctx, "roce"))
+
+	assert.NoError(t, VerifyDisabledProtocols([]string{"fc", "fc-nvme"}))
+	assert.Error(t, VerifyDisabledProtocols([]string{"fcoe"}))
+}
@@ -0,0 +1,93 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// readOnlyMapperPrefix names the read-only device-mapper nodes of the raw block volumes published read-only,
+// which are named after the hash of the target paths, as a volume may be published to several targets
+const readOnlyMapperPrefix = "ro-"
+
+var readOnlyMapperDir = "/dev/mapper"
+
+func readOnlyMapperName(targetPath string) string {
+	hash := sha256.Sum256([]byte(targetPath))
+	return readOnlyMapperPrefix + hex.EncodeToString(hash[:16])
+}
+
+// OpenReadOnlyDevice creates a read-only linear device-mapper node on the device for the target path, and
+// returns the path of the node. Unlike the read-only flag of the device, the node leaves the other targets
+// of the device writable.
+func OpenReadOnlyDevice(ctx context.Context, devPath, targetPath string) (string, error) {
+	name := readOnlyMapperName(targetPath)
+	mapperPath := path.Join(readOnlyMapperDir, name)
+	if exist, _ := utils.PathExist(mapperPath); exist {
+		log.AddContext(ctx).Infof("Read-only device %s of target %s already exists", mapperPath, targetPath)
+		return mapperPath, nil
+	}
+
+	realPath, err := filepath.EvalSymlinks(devPath)
+	if err != nil {
+		return "", utils.Errorf(ctx, "Get real path of device %s error: %v", devPath, err)
+	}
+
+	output, err := utils.ExecShellCmd(ctx, "blockdev --getsz %s", realPath)
+	if err != nil {
+		return "", utils.Errorf(ctx, "Get size of device %s error: %v, output: %s", realPath, err, output)
+	}
+	sectors, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil {
+		return "", utils.Errorf(ctx, "Parse size %s of device %s error: %v", output, realPath, err)
+	}
+
+	output, err = utils.ExecShellCmd(ctx, "dmsetup create %s --readonly --table \"0 %d linear %s 0\"",
+		name, sectors, realPath)
+	if err != nil {
+		return "", utils.Errorf(ctx, "Create read-only device %s on %s error: %v, output: %s",
+			name, realPath, err, output)
+	}
+
+	log.AddContext(ctx).Infof("Created read-only device %s of device %s for target %s", mapperPath, realPath,
+		targetPath)
+	return mapperPath, nil
+}
+
+// CloseReadOnlyDevice removes the read-only device-mapper node of the target path if it exists
+func CloseReadOnlyDevice(ctx context.Context, targetPath string) error {
+	name := readOnlyMapperName(targetPath)
+	if exist, _ := utils.PathExist(path.Join(readOnlyMapperDir, name)); !exist {
+		return nil
+	}
+
+	output, err := utils.ExecShellCmd(ctx, "dmsetup remove %s", name)
+	if err != nil {
+		return utils.Errorf(ctx, "Remove read-only device %s of target %s error: %v, output: %s",
+			name, targetPath, err, output)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/utils"
+)
+
+func TestReadOnlyDevice(t *testing.T) {
+	dir := t.TempDir()
+	device := filepath.Join(dir, "sdb")
+	assert.NoError(t, os.WriteFile(device, nil, 0640))
+	link := filepath.Join(dir, "pvc-1")
+	assert.NoError(t, os.Symlink(device, link))
+
+	var cmds []string
+	stubs := gostub.Stub(&readOnlyMapperDir, dir)
+	defer stubs.Reset()
+	stubs.Stub(&utils.ExecShellCmd, func(_ context.Context, format string, args ...interface{}) (string, error) {
+		cmds = append(cmds, fmt.Sprintf(format, args...))
+		return "2048\n", nil
+	})
+
+	targetPath := "/var/lib/kubelet/pods/1/volumeDevices/kubernetes.io~csi/pvc-1"
+	name := readOnlyMapperName(targetPath)
+	mapperPath, err := OpenReadOnlyDevice(context.Background(), link, targetPath)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, name), mapperPath)
+	assert.Equal(t, []string{"blockdev --getsz " + device,
+		fmt.Sprintf("dmsetup create %s --readonly --table \"0 2048 linear %s 0\"", name, device)}, cmds)
+	assert.NotEqual(t, name, readOnlyMapperName(targetPath+"-2"))
+
+	cmds = nil
+	assert.NoError(t, CloseReadOnlyDevice(context.Background(), targetPath))
+	assert.Empty(t, cmds)
+
+	assert.NoError(t, os.WriteFile(mapperPath, nil, 0640))
+	_, err = OpenReadOnlyDevice(context.Background(), link, targetPath)
+	assert.NoError(t, err)
+	assert.Empty(t, cmds)
+	assert.NoError(t, CloseReadOnlyDevice(context.Background(), targetPath))
+	assert.Equal(t, []string{"dmsetup remove " + name}, cmds)
+}
@@ -0,0 +1,131 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	huaweiDeviceVendor = "HUAWEI"
+	naaWWIDPrefix      = "naa."
+)
+
+// FindStaleSCSIDevices returns the SCSI devices of the Huawei LUNs by WWN, none of whose devices is readable
+// since the LUN is no longer mapped to the node. The LUNs of inUseWWNs are never returned.
+var FindStaleSCSIDevices = func(ctx context.Context, inUseWWNs map[string]bool) (map[string][]string, error) {
+	devices, err := listSysfsDir(ctx, "/sys/block")
+	if err != nil {
+		return nil, err
+	}
+
+	staleDevices := make(map[string][]string)
+	readableWWNs := make(map[string]bool)
+	for _, device := range devices {
+		if !strings.HasPrefix(device, "sd") {
+			continue
+		}
+
+		vendor, err := readSysfsValue(ctx, path.Join("/sys/block", device, "device", "vendor"))
+		if err != nil || vendor != huaweiDeviceVendor {
+			continue
+		}
+
+		// the wwid of sysfs is available even if the device is not readable
+		wwid, err := readSysfsValue(ctx, path.Join("/sys/block", device, "device", "wwid"))
+		if err != nil || !strings.HasPrefix(wwid, naaWWIDPrefix) {
+			log.AddContext(ctx).Warningf("Get wwid of device %s error: %v, wwid: %s", device, err, wwid)
+			continue
+		}
+
+		wwn := strings.TrimPrefix(wwid, naaWWIDPrefix)
+		if inUseWWNs[wwn] || readableWWNs[wwn] {
+			continue
+		}
+
+		if readable, _ := IsDeviceReadable(ctx, "/dev/"+device); readable {
+			readableWWNs[wwn] = true
+			delete(staleDevices, wwn)
+			continue
+		}
+		staleDevices[wwn] = append(staleDevices[wwn], device)
+	}
+
+	return staleDevices, nil
+}
+
+// RemoveStaleSCSIDevices flushes the multipath device of the LUN and removes its SCSI devices, the devices
+// held by other devices such as a LUKS mapping are kept
+var RemoveStaleSCSIDevices = func(ctx context.Context, lunWWN string) error {
+	return DisConnectVolumeCommon(ctx, lunWWN, "scsi", removeStaleSCSIDevices)
+}
+
+func removeStaleSCSIDevices(ctx context.Context, lunWWN string) error {
+	virtualDevice, devType, err := GetVirtualDevice(ctx, lunWWN)
+	if err != nil || virtualDevice == "" {
+		return err
+	}
+
+	holders, err := listSysfsDir(ctx, path.Join("/sys/block", virtualDevice, "holders"))
+	if err != nil {
+		return err
+	}
+	if len(holders) != 0 {
+		return utils.Errorf(ctx, "stale device %s of LUN %s is held by %v", virtualDevice, lunWWN, holders)
+	}
+
+	phyDevices, err := GetPhysicalDevices(ctx, virtualDevice, devType)
+	if err != nil {
+		return err
+	}
+
+	multiPathName, err := RemoveAllDevice(ctx, virtualDevice, phyDevices, devType)
+	if err != nil {
+		return err
+	}
+
+	if multiPathName != "" {
+		return FlushDMDevice(ctx, virtualDevice)
+	}
+	return nil
+}
+
+// RescanNVMeNamespaces rescans the namespaces of the NVMe controllers of Huawei storage, so the kernel
+// removes the namespaces which are no longer mapped to the node
+var RescanNVMeNamespaces = func(ctx context.Context) {
+	controllers, err := listSysfsDir(ctx, "/sys/class/nvme")
+	if err != nil {
+		log.AddContext(ctx).Warningf("List NVMe controllers error: %v", err)
+		return
+	}
+
+	for _, controller := range controllers {
+		model, err := readSysfsValue(ctx, path.Join("/sys/class/nvme", controller, "model"))
+		if err != nil || !strings.Contains(model, huaweiDeviceVendor) {
+			continue
+		}
+
+		if err := DoScanNVMeDevice(ctx, controller); err != nil {
+			log.AddContext(ctx).Warningf("Rescan namespaces of %s error: %v", controller, err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindStaleSCSIDevices(t *testing.T) {
+	stubs := stubSysfs(map[string]string{
+		"/sys/block/sdb/device/vendor": "HUAWEI",
+		"/sys/block/sdb/device/wwid":   "naa.6000000000000000000000000000000a",
+		"/sys/block/sdc/device/vendor": "HUAWEI",
+		"/sys/block/sdc/device/wwid":   "naa.6000000000000000000000000000000a",
+		"/sys/block/sdd/device/vendor": "HUAWEI",
+		"/sys/block/sdd/device/wwid":   "naa.6000000000000000000000000000000b",
+		"/sys/block/sde/device/vendor": "HUAWEI",
+		"/sys/block/sde/device/wwid":   "naa.6000000000000000000000000000000c",
+		"/sys/block/sdf/device/vendor": "HUAWEI",
+		"/sys/block/sdf/device/wwid":   "naa.6000000000000000000000000000000c",
+		"/sys/block/sdg/device/vendor": "OTHER",
+		"/sys/block/sdg/device/wwid":   "naa.6000000000000000000000000000000d",
+	}, map[string][]string{"/sys/block": {"dm-0", "nvme0n1", "sdb", "sdc", "sdd", "sde", "sdf", "sdg"}})
+	defer stubs.Reset()
+	// sdf of LUN c is still readable
+	stubs.Stub(&IsDeviceReadable, func(ctx context.Context, devicePath string) (bool, error) {
+		return devicePath == "/dev/sdf", nil
+	})
+
+	staleDevices, err := FindStaleSCSIDevices(context.Background(),
+		map[string]bool{"6000000000000000000000000000000b": true})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"6000000000000000000000000000000a": {"sdb", "sdc"}}, staleDevices)
+}
@@ -96,6 +96,34 @@ func TestGetConnector(t *testing.T) {
 	}
 }
 
+func TestSplitPortal(t *testing.T) {
+	cases := []struct {
+		portal   string
+		wantHost string
+		wantPort string
+	}{
+		{"192.168.125.25:3260", "192.168.125.25", "3260"},
+		{"[FE80:0:0::1]:3260", "fe80::1", "3260"},
+		{"[fe80::1]", "fe80::1", ""},
+		{"fe80::1", "fe80::1", ""},
+		{"192.168.125.25", "192.168.125.25", ""},
+	}
+
+	for _, c := range cases {
+		host, port := SplitPortal(c.portal)
+		if host != c.wantHost || port != c.wantPort {
+			t.Errorf("SplitPortal(%s) = %s, %s, want %s, %s", c.portal, host, port, c.wantHost, c.wantPort)
+		}
+	}
+
+	if !SamePortal("[fe80::0:1]:3260", "[FE80::1]:3260") {
+		t.Errorf("IPv6 portals in different notations should match")
+	}
+	if SamePortal("[fe80::1]:3260", "[fe80::1]:3261") {
+		t.Errorf("Portals with different ports should not match")
+	}
+}
+
 func TestMain(m *testing.M) {
 	if err := log.InitLogging(logName); err != nil {
 		log.Errorf("init logging: %s failed. error: %v", logName, err)
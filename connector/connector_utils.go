@@ -20,7 +20,6 @@ package connector
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -30,6 +29,7 @@ import (
 	"strings"
 	"time"
 
+	connutils "huawei-csi-driver/connector/utils"
 	"huawei-csi-driver/utils"
 	"huawei-csi-driver/utils/log"
 )
@@ -48,6 +48,10 @@ const (
 var (
 	DisconnectVolumeTimeOut      = time.Minute
 	DisconnectVolumeTimeInterval = time.Second
+	// WatchDMPollInterval is the fallback poll interval of WatchDMDevice when no block uevent is received
+	WatchDMPollInterval = 500 * time.Millisecond
+	// volumeRemovalTimeout is how long to wait for the devices of a volume to be deleted
+	volumeRemovalTimeout = 30 * time.Second
 )
 
 type deviceInfo struct {
@@ -223,6 +227,10 @@ func getVirtualDevice(ctx context.Context, virtualDevices []string, phyDevices [
 }
 
 func isUltraPathDevice(ctx context.Context, device string) bool {
+	if !GetSettings().UltraPathDetection {
+		return false
+	}
+
 	output, err := utils.ExecShellCmd(ctx, "upadmin show vlun | grep -w %s", device)
 	if err != nil {
 		return false
@@ -244,6 +252,49 @@ var GetDevicesByGUID = func(ctx context.Context, tgtLunGUID string) ([]string, e
 	return devices, nil
 }
 
+// VerifyDeviceWWN checks the device, which may be a symlink, is one of the devices linked to the WWN/GUID
+// in /dev/disk/by-id/, so that a device taken over by another LUN after rescan is never formatted or mounted
+var VerifyDeviceWWN = func(ctx context.Context, devPath, lunWWN string) error {
+	if lunWWN == "" {
+		return utils.Errorf(ctx, "The WWN of device %s to verify is empty", devPath)
+	}
+
+	realPath, err := filepath.EvalSymlinks(devPath)
+	if err != nil {
+		return utils.Errorf(ctx, "Resolve device %s error: %v", devPath, err)
+	}
+
+	devices, err := GetDevicesByGUID(ctx, lunWWN)
+	if err != nil {
+		return utils.Errorf(ctx, "Get devices of WWN %s error: %v", lunWWN, err)
+	}
+
+	// The device of an encrypted volume is the dm-crypt mapping on the device of the LUN
+	device := getLUKSBackingDevice(filepath.Base(realPath))
+	if !utils.IsContain(device, devices) {
+		return utils.Errorf(ctx, "Device %s doesn't belong to LUN %s, devices of the LUN are %v",
+			realPath, lunWWN, devices)
+	}
+
+	return nil
+}
+
+// SetDeviceReadOnly makes the block device read-only or writable. The read-only flag of a mount does not
+// stop the writes to a device node, so the raw block volumes are protected by the flag of the device.
+var SetDeviceReadOnly = func(ctx context.Context, devPath string, readOnly bool) error {
+	flag := "--setrw"
+	if readOnly {
+		flag = "--setro"
+	}
+
+	output, err := utils.ExecShellCmd(ctx, "blockdev %s %s", flag, devPath)
+	if err != nil {
+		return utils.Errorf(ctx, "Set read-only flag of device %s to %v error: %v, output: %s",
+			devPath, readOnly, err, output)
+	}
+	return nil
+}
+
 func reScanNVMe(ctx context.Context, device string) error {
 	if match, _ := regexp.MatchString(`nvme[0-9]+n[0-9]+`, device); match {
 		output, err := utils.ExecShellCmd(ctx, "echo 1 > /sys/block/%s/device/rescan_controller", device)
@@ -252,9 +303,9 @@ func reScanNVMe(ctx context.Context, device string) error {
 			return err
 		}
 	} else if match, _ := regexp.MatchString(`nvme[0-9]+$`, device); match {
-		output, err := utils.ExecShellCmd(ctx, "nvme ns-rescan /dev/%s", device)
+		err := DoScanNVMeDevice(ctx, device)
 		if err != nil {
-			log.AddContext(ctx).Warningf("rescan nvme path error: %s", output)
+			log.AddContext(ctx).Warningf("rescan nvme path error: %v", err)
 			return err
 		}
 	}
@@ -346,9 +397,12 @@ func removeSCSIDevice(ctx context.Context, sd string) error {
 	return nil
 }
 
+// waitVolumeRemoval waits up to 30 seconds for the devices to be deleted, they are re-checked whenever a block
+// device uevent arrives and at least every second
 func waitVolumeRemoval(ctx context.Context, devPaths []string) {
 	existPath := devPaths
-	for index := 0; index <= 30; index++ {
+	deadline := time.Now().Add(volumeRemovalTimeout)
+	for {
 		var exist []string
 		for _, dev := range existPath {
 			_, err := os.Stat(dev)
@@ -360,16 +414,12 @@ func waitVolumeRemoval(ctx context.Context, devPaths []string) {
 		}
 
 		existPath = exist
-		if len(existPath) == 0 {
+		if len(existPath) == 0 || !time.Now().Before(deadline) {
 			return
 		}
 
-		if index < 30 {
-			time.Sleep(time.Second)
-		}
+		connutils.WaitBlockDeviceEvent(ctx, time.Second)
 	}
-
-	return
 }
 
 func removeSymlinks(devices []string, realPath, link string) error {
@@ -424,10 +474,11 @@ func getSessionIdByDevice(devPath string) (string, error) {
 	return strings.Split(file[1], "/")[0], nil
 }
 
-// WatchDMDevice is an aggregate drive letter monitor.
+// WatchDMDevice is an aggregate drive letter monitor. It re-checks the DM disk whenever a block
+// device uevent arrives and falls back to polling every WatchDMPollInterval when no event is received.
 func WatchDMDevice(ctx context.Context, lunWWN string, expectPathNumber int) (DMDeviceInfo, error) {
 	log.AddContext(ctx).Infof("Watch DM Disk Generation. lunWWN: %s,expectPathNumber: %d", lunWWN, expectPathNumber)
-	var timeout = time.After(ScanVolumeTimeout)
+	var timeout = time.After(GetSettings().ScanVolumeTimeout)
 	var dm DMDeviceInfo
 	var err = errors.New(VolumeNotFound)
 	for {
@@ -435,7 +486,7 @@ func WatchDMDevice(ctx context.Context, lunWWN string, expectPathNumber int) (DM
 		case <-timeout:
 			return dm, err
 		default:
-			<-time.After(100 * time.Millisecond)
+			connutils.WaitBlockDeviceEvent(ctx, WatchDMPollInterval)
 		}
 
 		dm, err = findDMDeviceByWWN(ctx, lunWWN)
@@ -472,6 +523,24 @@ func findDMDeviceByWWN(ctx context.Context, lunWWN string) (dm DMDeviceInfo, err
 	return
 }
 
+// ReloadDMMultiPath reloads the DM-multipath device of the LUN, so its paths are regrouped by their current
+// priorities. It does nothing if the LUN has no DM-multipath device.
+func ReloadDMMultiPath(ctx context.Context, lunWWN string) error {
+	dm, err := findDMDeviceByWWN(ctx, lunWWN)
+	if err != nil {
+		if err.Error() == VolumeNotFound {
+			return nil
+		}
+		return err
+	}
+
+	output, err := utils.ExecShellCmd(ctx, "multipathd reload map %s", dm.Name)
+	if err != nil {
+		return fmt.Errorf("reload multipath %s error: %s", dm.Name, output)
+	}
+	return nil
+}
+
 func getDMDeviceInfo(line string) (dm DMDeviceInfo, err error) {
 	const colWidth = 3
 	column := strings.Fields(line)
@@ -606,24 +675,13 @@ var GetSCSIWwn = func(ctx context.Context, hostDevice string) (string, error) {
 
 // GetNVMeWwn get the unique id of the device
 var GetNVMeWwn = func(ctx context.Context, device string) (string, error) {
-	cmd := fmt.Sprintf("nvme id-ns %s -o json", device)
-	output, err := utils.ExecShellCmdFilterLog(ctx, cmd)
+	uuid, err := getNVMeNGUID(ctx, filepath.Base(device))
 	if err != nil {
 		log.AddContext(ctx).Errorf("Failed to get nvme id of device %s, err is %v", device, err)
 		return "", err
 	}
 
-	var deviceInfo map[string]interface{}
-	if err = json.Unmarshal([]byte(output), &deviceInfo); err != nil {
-		log.AddContext(ctx).Errorf("Failed to unmarshal input %s", output)
-		return "", errors.New("failed to unmarshal device info")
-	}
-
-	if uuid, exist := deviceInfo["nguid"]; exist {
-		return uuid.(string), nil
-	}
-
-	return "", errors.New("there is no nguid in device info")
+	return uuid, nil
 }
 
 // ReadDevice is to check whether the device is readable
@@ -1290,7 +1348,7 @@ func VerifyDeviceAvailableOfDM(ctx context.Context, tgtLunWWN string, expectPath
 
 	start := time.Now()
 	dm, err := WatchDMDevice(ctx, tgtLunWWN, expectPathNumber)
-	log.AddContext(ctx).Infof("WatchDMDevice-%s:%-36s%-8d%-20s%v", ScanVolumeTimeout,
+	log.AddContext(ctx).Infof("WatchDMDevice-%s:%-36s%-8d%-20s%v", GetSettings().ScanVolumeTimeout,
 		tgtLunWWN, expectPathNumber, time.Now().Sub(start), err)
 	if err == nil {
 		var dev string
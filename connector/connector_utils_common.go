@@ -19,6 +19,7 @@ package connector
 
 import (
 	"context"
+	"net"
 	"strings"
 
 	"huawei-csi-driver/connector/utils/lock"
@@ -109,17 +110,86 @@ func DisConnectVolumeCommon(ctx context.Context,
 
 // CheckHostConnectivity used to check host connectivity
 func CheckHostConnectivity(ctx context.Context, portal string) bool {
-	const addrLength = 2
-	addr := strings.Split(portal, ":")
-	if len(addr) != addrLength {
+	host, port := SplitPortal(portal)
+	if host == "" || port == "" {
 		log.AddContext(ctx).Errorf("the portal format is incorrect. %s", portal)
 		return false
 	}
 
-	_, err := utils.ExecShellCmd(ctx, PingCommand, addr[0])
+	_, err := utils.ExecShellCmd(ctx, PingCommand, host)
 	return err == nil
 }
 
+// SplitPortal splits a portal such as 192.168.1.1:3260 or [fe80::1]:3260 into the IP address
+// and the port. The port is empty if the portal has none, e.g. 192.168.1.1 or [fe80::1].
+func SplitPortal(portal string) (string, string) {
+	host, port, err := net.SplitHostPort(portal)
+	if err != nil {
+		return NormalizeIP(portal), ""
+	}
+
+	return NormalizeIP(host), port
+}
+
+// NormalizeIP returns the canonical form of an IP address, which may be in brackets, so
+// different notations of an IPv6 address match. Other addresses are returned unbracketed.
+func NormalizeIP(addr string) string {
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	if ip := net.ParseIP(addr); ip != nil {
+		return ip.String()
+	}
+
+	return addr
+}
+
+// interfaceAddrs returns the addresses of the NIC
+var interfaceAddrs = func(nic string) ([]net.Addr, error) {
+	iface, err := net.InterfaceByName(nic)
+	if err != nil {
+		return nil, err
+	}
+
+	return iface.Addrs()
+}
+
+// GetInterfaceIPs returns the addresses of the NICs which are of the same IP family as the portal
+func GetInterfaceIPs(ctx context.Context, nics []string, portal string) []string {
+	host, _ := SplitPortal(portal)
+	portalIP := net.ParseIP(host)
+	if portalIP == nil {
+		log.AddContext(ctx).Errorf("the portal format is incorrect. %s", portal)
+		return nil
+	}
+
+	var ips []string
+	for _, nic := range nics {
+		addrs, err := interfaceAddrs(nic)
+		if err != nil {
+			log.AddContext(ctx).Warningf("Get addresses of NIC %s error: %v", nic, err)
+			continue
+		}
+
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.IsLinkLocalUnicast() != portalIP.IsLinkLocalUnicast() ||
+				(ipNet.IP.To4() == nil) != (portalIP.To4() == nil) {
+				continue
+			}
+			ips = append(ips, ipNet.IP.String())
+			break
+		}
+	}
+
+	return ips
+}
+
+// SamePortal checks whether two portals are the same address and port
+func SamePortal(portal, other string) bool {
+	host, port := SplitPortal(portal)
+	otherHost, otherPort := SplitPortal(other)
+	return strings.EqualFold(host, otherHost) && port == otherPort
+}
+
 // ConnectVolumeCommon used for connect volume for all protocol
 func ConnectVolumeCommon(ctx context.Context,
 	conn map[string]interface{},
@@ -19,84 +19,37 @@ package connector
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"fmt"
 	"io/ioutil"
 	"path"
 	"regexp"
-	"strconv"
 	"strings"
 
 	"huawei-csi-driver/utils"
 	"huawei-csi-driver/utils/log"
 )
 
-// DoScanNVMeDevice used to scan device by command nvme ns-rescan
+// DoScanNVMeDevice used to scan the namespaces of the NVMe controller, such as nvme1
 var DoScanNVMeDevice = func(ctx context.Context, devicePort string) error {
-	output, err := utils.ExecShellCmd(ctx, "nvme ns-rescan /dev/%s", devicePort)
+	err := writeSysfsValue(ctx, path.Join(nvmeClassPath, devicePort, "rescan_controller"), "1")
 	if err != nil {
-		log.AddContext(ctx).Errorf("Scan nvme port failed. output:%s, error:%v", output, err)
+		log.AddContext(ctx).Errorf("Scan nvme port failed. error:%v", err)
 		return err
 	}
 	return nil
 }
 
-// GetSubSysInfo used to get subsys info by command nvme list-subsys
+// GetSubSysInfo used to get subsys info from sysfs, in the format of nvme list-subsys
 var GetSubSysInfo = func(ctx context.Context) (map[string]interface{}, error) {
-	err := checkNVMeVersion(ctx)
+	nvmeConnectInfo, err := listNVMeSubsystems(ctx)
 	if err != nil {
-		return nil, utils.Errorf(ctx, "Failed to check the NVMe version. err:%v", err)
-	}
-
-	output, err := utils.ExecShellCmdFilterLog(ctx, "nvme list-subsys -o json")
-	if err != nil {
-		log.AddContext(ctx).Errorf("Get exist nvme connect info failed, output:%s, error:%v", output, err)
+		log.AddContext(ctx).Errorf("Get exist nvme connect info failed, error:%v", err)
 		return nil, errors.New("get nvme connect port failed")
 	}
 
-	var nvmeConnectInfo map[string]interface{}
-	if err = json.Unmarshal([]byte(output), &nvmeConnectInfo); err != nil {
-		return nil, errors.New("unmarshal nvme connect info failed")
-	}
-
 	return nvmeConnectInfo, nil
 }
 
-func checkNVMeVersion(ctx context.Context) error {
-	output, err := utils.ExecShellCmd(ctx, "nvme version")
-	if err != nil {
-		return fmt.Errorf("failed to query the NVMe version. err: %v output: %s", err, output)
-	}
-
-	pattern := regexp.MustCompile(`version\s+([\d]).([\d]+)[\d.]*`)
-	version := pattern.FindStringSubmatch(output)
-	const versionLength = 3
-	if len(version) != versionLength {
-		return fmt.Errorf("the format of the data returned by the NVME command is incorrect. output:%s", output)
-	}
-
-	const majorPosition = 1
-	major, err := strconv.Atoi(version[majorPosition])
-	if err != nil {
-		return fmt.Errorf("failed to parse the NVMe major version number. err: %v version: %v", err, version)
-	}
-
-	const minorPosition = 2
-	minor, err := strconv.Atoi(version[minorPosition])
-	if err != nil {
-		return fmt.Errorf("failed to parse the NVMe minor version number. err: %v version: %v", err, version)
-	}
-
-	const minimumMajor = 1
-	const minimumMinor = 9
-	if major <= minimumMajor && minor < minimumMinor {
-		return fmt.Errorf("the current NVMe CLI version is not supported. Please upgrade it. %v", version)
-	}
-
-	return nil
-}
-
 // GetNVMeDevice used to get device name by channel
 func GetNVMeDevice(ctx context.Context, devicePort string, tgtLunGUID string) (string, error) {
 	nvmePortPath := path.Join("/sys/devices/virtual/nvme-fabrics/ctl/", devicePort)
@@ -104,13 +57,12 @@ func GetNVMeDevice(ctx context.Context, devicePort string, tgtLunGUID string) (s
 		return "", utils.Errorf(ctx, "NVMe device path %s is not exist.", nvmePortPath)
 	}
 
-	output, err := utils.ExecShellCmd(ctx, fmt.Sprintf("ls %s |grep nvme", nvmePortPath))
+	outputLines, err := listSysfsDir(ctx, nvmePortPath)
 	if err != nil {
 		log.AddContext(ctx).Errorf("get nvme device failed, error:%v", err)
 		return "", err
 	}
 
-	outputLines := strings.Split(output, "\n")
 	for _, dev := range outputLines {
 		match, err := regexp.MatchString(`nvme[0-9]+n[0-9]+`, dev)
 		if err != nil {
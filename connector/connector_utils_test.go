@@ -20,6 +20,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -414,6 +416,44 @@ func TestGetVirtualDevice(t *testing.T) {
 	}
 }
 
+func TestVerifyDeviceWWN(t *testing.T) {
+	dir := t.TempDir()
+	device := filepath.Join(dir, "dm-2")
+	if err := os.WriteFile(device, nil, 0600); err != nil {
+		t.Fatal("can not create a device file")
+	}
+	link := filepath.Join(dir, "wwn-link")
+	if err := os.Symlink(device, link); err != nil {
+		t.Fatal("can not create a device link")
+	}
+
+	var cases = []struct {
+		name    string
+		devPath string
+		lunWWN  string
+		devices []string
+		wantErr bool
+	}{
+		{"Match", device, "6582575100bc510f", []string{"sdb", "sdc", "dm-2"}, false},
+		{"MatchSymlink", link, "6582575100bc510f", []string{"dm-2"}, false},
+		{"Mismatch", device, "6582575100bc510f", []string{"sdb", "dm-3"}, true},
+		{"NoDevice", device, "6582575100bc510f", nil, true},
+		{"EmptyWWN", device, "", []string{"dm-2"}, true},
+		{"DeviceNotExist", filepath.Join(dir, "dm-9"), "6582575100bc510f", []string{"dm-9"}, true},
+	}
+
+	stubs := gostub.New()
+	defer stubs.Reset()
+
+	for _, c := range cases {
+		stubs.StubFunc(&GetDevicesByGUID, c.devices, nil)
+		t.Run(c.name, func(t *testing.T) {
+			err := VerifyDeviceWWN(context.TODO(), c.devPath, c.lunWWN)
+			assert.Equal(t, c.wantErr, err != nil)
+		})
+	}
+}
+
 func TestWatchDMDevice(t *testing.T) {
 	var cases = []struct {
 		name             string
@@ -457,8 +497,11 @@ func TestWatchDMDevice(t *testing.T) {
 		},
 	}
 
-	stubs := gostub.Stub(&ScanVolumeTimeout, 10*time.Millisecond)
+	settings := GetSettings()
+	settings.ScanVolumeTimeout = 10 * time.Millisecond
+	stubs := gostub.Stub(&currentSettings, settings)
 	defer stubs.Reset()
+	stubs.Stub(&WatchDMPollInterval, 100*time.Millisecond)
 
 	for _, c := range cases {
 		var startTime = time.Now()
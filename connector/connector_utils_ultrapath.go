@@ -24,7 +24,9 @@ import (
 	"path"
 	"regexp"
 	"strings"
+	"time"
 
+	connutils "huawei-csi-driver/connector/utils"
 	"huawei-csi-driver/utils"
 	"huawei-csi-driver/utils/log"
 )
@@ -290,6 +292,79 @@ func setIOSuspensionTimeByPath(ctx context.Context, upDevice string) error {
 	return SetUltraPathIOSuspensionTime(ctx, UltraPathNVMeCommand, vLunID, "0")
 }
 
+// countNormalUltraPathPaths counts the paths of the vlun details whose status is Normal, e.g.
+//
+//	Path 0 [0:0:0:1] (nvme0n1) : Normal
+func countNormalUltraPathPaths(output string) int {
+	paths, err := getFieldFromUltraPathInfo(output, "Path")
+	if err != nil {
+		return 0
+	}
+
+	var count int
+	for _, path := range paths {
+		index := strings.LastIndex(path, ":")
+		if strings.TrimSpace(path[index+1:]) == diskStatusNormal {
+			count++
+		}
+	}
+	return count
+}
+
+// WaitUltraPathNVMeDevice waits for the UltraPath-NVMe device to aggregate UltraPathNVMeMinPaths normal
+// paths within the ScanVolumeTimeout, and verifies the status of the vlun
+var WaitUltraPathNVMeDevice = func(ctx context.Context, upDevice string) error {
+	var normalPaths int
+	settings := GetSettings()
+	timeout := time.After(settings.ScanVolumeTimeout)
+	for normalPaths < settings.UltraPathNVMeMinPaths {
+		output, err := GetUltraPathDetailsByPath(ctx, UltraPathNVMeCommand, upDevice)
+		if err != nil {
+			return err
+		}
+
+		normalPaths = countNormalUltraPathPaths(output)
+		if normalPaths >= settings.UltraPathNVMeMinPaths {
+			break
+		}
+
+		select {
+		case <-timeout:
+			return utils.Errorf(ctx, "UltraPath-NVMe device %s has %d normal paths, less than the required %d",
+				upDevice, normalPaths, settings.UltraPathNVMeMinPaths)
+		default:
+			connutils.WaitBlockDeviceEvent(ctx, time.Second)
+		}
+	}
+
+	log.AddContext(ctx).Infof("UltraPath-NVMe device %s has %d normal paths", upDevice, normalPaths)
+	_, err := VerifyDeviceAvailableOfUltraPath(ctx, UltraPathNVMeCommand, upDevice)
+	return err
+}
+
+// RemoveUltraPathNVMeVirtualDevice waits for the UltraPath-NVMe device to disappear once its paths are
+// removed, and deletes the virtual device which is left behind
+func RemoveUltraPathNVMeVirtualDevice(ctx context.Context, virtualDevice, lunWWN string) error {
+	timeout := time.After(GetSettings().ScanVolumeTimeout)
+	for {
+		isTakeOver, err := isTakeOverByUltraPath(ctx, UltraPathNVMeCommand, lunWWN)
+		if err != nil {
+			return err
+		}
+		if !isTakeOver {
+			return nil
+		}
+
+		select {
+		case <-timeout:
+			log.AddContext(ctx).Warningf("UltraPath-NVMe device %s of WWN %s is left behind, delete it",
+				virtualDevice, lunWWN)
+			return deleteVirtualDevice(ctx, virtualDevice)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
 // RemoveUltraPathNVMeDevice to remove the ultrapath device through virtual device and physical device
 func RemoveUltraPathNVMeDevice(ctx context.Context, virtualDevice string, phyDevices []string) error {
 	err := setIOSuspensionTimeByPath(ctx, virtualDevice)
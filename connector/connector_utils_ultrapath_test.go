@@ -18,8 +18,8 @@ package connector
 
 import (
 	"context"
-	"errors"
 	"testing"
+	"time"
 
 	"github.com/prashantv/gostub"
 	"github.com/stretchr/testify/assert"
@@ -99,30 +99,31 @@ func TestRunUpCommand(t *testing.T) {
 	}
 }
 
-func TestCheckNVMeVersion(t *testing.T) {
-	type stubVal struct {
-		output string
-		err    error
-	}
+func TestWaitUltraPathNVMeDevice(t *testing.T) {
+	const details = "Vlun ID : 1\nStatus : Normal\n" +
+		"Path 0 [0:0:0:1] (nvme0n1) : Normal\nPath 1 [0:0:1:1] (nvme1n1) : Fault\n"
 
 	tests := []struct {
-		name string
-		stubVal
-		wantErr bool
+		name     string
+		minPaths int
+		wantErr  bool
 	}{
-		{"Supported NVMe Version", stubVal{"nvme version 1.9", nil}, false},
-		{"Supported NVMe Version", stubVal{"nvme version 2.1", nil}, false},
-		{"Unsupported NVMe Version", stubVal{"nvme version 1.8", nil}, true},
-		{"NVMe is not installed", stubVal{"-bash: nvme: command not found", errors.New("exit status 127")}, true},
+		{"EnoughPaths", 1, false},
+		{"NotEnoughPaths", 2, true},
 	}
 
-	stub := gostub.New()
-	defer func() { stub.Reset() }()
+	stubs := gostub.StubFunc(&utils.ExecShellCmd, details, nil)
+	defer stubs.Reset()
+	settings := GetSettings()
+	settings.ScanVolumeTimeout = 10 * time.Millisecond
 
-	for _, test := range tests {
-		stub.StubFunc(&utils.ExecShellCmd, test.output, test.err)
-
-		err := checkNVMeVersion(context.TODO())
-		assert.Equal(t, test.wantErr, err != nil, "%s, err:%v", test.name, err)
+	assert.Equal(t, 1, countNormalUltraPathPaths(details))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings.UltraPathNVMeMinPaths = tt.minPaths
+			stubs.Stub(&currentSettings, settings)
+			err := WaitUltraPathNVMeDevice(context.TODO(), "ultrapathb")
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
 	}
 }
@@ -0,0 +1,80 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package fibrechannel
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"huawei-csi-driver/connector"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// getFabricName returns the WWN of the fabric the HBA port is logged in, or empty if the port
+// is not in a fabric, e.g. it is directly connected to the storage
+func getFabricName(ctx context.Context, host string) string {
+	output, err := utils.ExecShellCmd(ctx, "cat /sys/class/fc_host/%s/fabric_name", host)
+	if err != nil {
+		log.AddContext(ctx).Debugf("Get fabric name of host %s error: %s", host, output)
+		return ""
+	}
+
+	fabric := strings.TrimPrefix(strings.TrimSpace(output), "0x")
+	if strings.Trim(fabric, "0") == "" {
+		return ""
+	}
+	return fabric
+}
+
+// checkFabricPaths verifies that every fabric the online HBAs of the node are in has at least
+// one path to the volume, so a zoning mistake does not silently leave it on a single fabric
+func checkFabricPaths(ctx context.Context, hbas []map[string]string, conn *connectorInfo) error {
+	if !connector.GetSettings().FCRequirePathPerFabric || !conn.volumeUseMultiPath {
+		return nil
+	}
+
+	var missing []string
+	for _, fabric := range getFabrics(hbas) {
+		if conn.fabricPaths[fabric] == 0 {
+			missing = append(missing, fabric)
+		}
+	}
+
+	log.AddContext(ctx).Infof("Paths of volume %s in each fabric: %v", conn.tgtLunWWN, conn.fabricPaths)
+	if len(missing) != 0 {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"volume %s has no path in fabrics %v, please check the zoning", conn.tgtLunWWN, missing)
+	}
+
+	return nil
+}
+
+// getFabrics returns the sorted fabrics of the HBAs, the ports not in a fabric are ignored
+func getFabrics(hbas []map[string]string) []string {
+	var fabrics []string
+	for _, hba := range hbas {
+		fabric := hba["fabric_name"]
+		if fabric != "" && !utils.IsContain(fabric, fabrics) {
+			fabrics = append(fabrics, fabric)
+		}
+	}
+
+	sort.Strings(fabrics)
+	return fabrics
+}
@@ -0,0 +1,80 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package fibrechannel
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/connector"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	logDir  = "/var/log/huawei/"
+	logName = "fcTest.log"
+)
+
+func TestCheckFabricPaths(t *testing.T) {
+	settings := connector.GetSettings()
+	defer connector.SetSettings(settings)
+	requirePathPerFabric := settings
+	requirePathPerFabric.FCRequirePathPerFabric = true
+	connector.SetSettings(requirePathPerFabric)
+
+	hbas := []map[string]string{
+		{"host_device": "host1", "fabric_name": "100000051e0c2b01"},
+		{"host_device": "host2", "fabric_name": "100000051e0c2b02"},
+		{"host_device": "host3", "fabric_name": ""},
+	}
+	conn := &connectorInfo{
+		tgtLunWWN:          "6a8ffba1005d5f8c0d3c6f2b00000025",
+		volumeUseMultiPath: true,
+		fabricPaths:        map[string]int{"100000051e0c2b01": 2, "": 1},
+	}
+
+	err := checkFabricPaths(context.Background(), hbas, conn)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "100000051e0c2b02")
+
+	conn.fabricPaths["100000051e0c2b02"] = 1
+	assert.NoError(t, checkFabricPaths(context.Background(), hbas, conn))
+
+	// Without multipath a single path is expected
+	conn.volumeUseMultiPath = false
+	conn.fabricPaths = map[string]int{"100000051e0c2b01": 1}
+	assert.NoError(t, checkFabricPaths(context.Background(), hbas, conn))
+}
+
+func TestMain(m *testing.M) {
+	if err := log.InitLogging(logName); err != nil {
+		log.Errorf("init logging: %s failed. error: %v", logName, err)
+		os.Exit(1)
+	}
+	logFile := path.Join(logDir, logName)
+	defer func() {
+		if err := os.RemoveAll(logFile); err != nil {
+			log.Errorf("Remove file: %s failed. error: %s", logFile, err)
+		}
+	}()
+
+	m.Run()
+}
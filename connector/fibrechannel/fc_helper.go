@@ -59,6 +59,8 @@ type connectorInfo struct {
 	volumeUseMultiPath bool
 	multiPathType      string
 	pathCount          int
+	// fabricPaths is the number of paths scanned in each fabric
+	fabricPaths map[string]int
 }
 
 const (
@@ -150,6 +152,11 @@ func tryConnectVolume(ctx context.Context, connMap map[string]interface{}) (stri
 		return "", errors.New(connector.VolumeNotFound)
 	}
 
+	err = checkFabricPaths(ctx, hbas, conn)
+	if err != nil {
+		return "", err
+	}
+
 	return checkPathAvailable(ctx, *conn, devInfo)
 }
 
@@ -324,6 +331,7 @@ func getFcHbaInfo(ctx context.Context, host string) (map[string]string, error) {
 		"node_name":   nodeName,
 		"host_device": host,
 		"device_path": classDevicePath,
+		"fabric_name": getFabricName(ctx, host),
 	}
 	return hba, nil
 }
@@ -519,8 +527,10 @@ func rescanHosts(ctx context.Context, hbas []map[string]string, conn *connectorI
 	}
 
 	var pathCount int
+	fabricPaths := make(map[string]int)
 	defer func() {
 		conn.pathCount = pathCount
+		conn.fabricPaths = fabricPaths
 	}()
 	for _, p := range process {
 		pro, ok := p.([]interface{})
@@ -549,6 +559,10 @@ func rescanHosts(ctx context.Context, hbas []map[string]string, conn *connectorI
 		for _, c := range ctls {
 			scanFC(ctx, c, hba["host_device"])
 			pathCount++
+			// The wildcard scans of the skipped HBAs do not prove a path to the targets
+			if c[0] != "-" {
+				fabricPaths[hba["fabric_name"]]++
+			}
 			if !conn.volumeUseMultiPath {
 				break
 			}
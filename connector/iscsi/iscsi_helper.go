@@ -31,6 +31,7 @@ import (
 
 	"huawei-csi-driver/connector"
 	connutils "huawei-csi-driver/connector/utils"
+	"huawei-csi-driver/proto"
 	"huawei-csi-driver/utils"
 	"huawei-csi-driver/utils/log"
 )
@@ -39,6 +40,9 @@ type chapInfo struct {
 	authUserName string
 	authPassword string
 	authMethod   string
+	// authUserNameIn and authPasswordIn authenticate the target for the mutual CHAP
+	authUserNameIn string
+	authPasswordIn string
 }
 
 type connectorInfo struct {
@@ -50,6 +54,10 @@ type connectorInfo struct {
 	tgtChapInfo        chapInfo
 	volumeUseMultiPath bool
 	multiPathType      string
+	// storageInterfaces are the NICs which carry the iSCSI traffic, empty means any
+	storageInterfaces []string
+	// ifaces are the iscsiadm interfaces to log in over
+	ifaces []string
 }
 
 type singleConnectorInfo struct {
@@ -111,56 +119,60 @@ func parseISCSIInfo(ctx context.Context,
 		log.AddContext(ctx).Infoln("key authUserName does not exist in connectionProperties")
 	}
 
-	info.tgtChapInfo.authPassword, exist = connectionProperties["authPassword"].(string)
+	authPassword, exist := connectionProperties["authPassword"].(connector.SensitiveString)
 	if !exist {
 		log.AddContext(ctx).Infoln("key authPassword does not exist in connectionProperties")
 	}
+	info.tgtChapInfo.authPassword = string(authPassword)
 
 	info.tgtChapInfo.authMethod, exist = connectionProperties["authMethod"].(string)
 	if !exist {
 		log.AddContext(ctx).Infoln("key authMethod does not exist in connectionProperties")
 	}
 
+	info.tgtChapInfo.authUserNameIn, _ = connectionProperties["authUserNameIn"].(string)
+	authPasswordIn, _ := connectionProperties["authPasswordIn"].(connector.SensitiveString)
+	info.tgtChapInfo.authPasswordIn = string(authPasswordIn)
+
+	info.storageInterfaces, _ = connectionProperties["storageInterfaces"].([]string)
+
 	info.volumeUseMultiPath, info.multiPathType, err = connutils.GetMultiPathInfo(connectionProperties)
 
 	return info, err
 }
 
+// runISCSIAdmin runs iscsiadm on the node record of the target, the failure is returned only if its exit
+// code is one of failureCodes
 func runISCSIAdmin(ctx context.Context,
 	tgtPortal, targetIQN string,
 	iSCSICommand string,
-	checkExitCode []string) error {
+	failureCodes []int) error {
 	iSCSICmd := fmt.Sprintf("iscsiadm -m node -T %s -p %s %s", targetIQN, tgtPortal, iSCSICommand)
-	output, err := utils.ExecShellCmdFilterLog(ctx, iSCSICmd)
-	if err != nil {
-		if err.Error() == "timeout" {
-			return err
-		}
-
-		err2 := utils.CheckExistCode(err, checkExitCode)
-		if err2 != nil {
-			log.AddContext(ctx).Warningf("Run %s: output=%s, err=%v", utils.MaskSensitiveInfo(iSCSICmd),
-				utils.MaskSensitiveInfo(output), err2)
-			return err
-		}
-	}
+	_, err := execISCSIAdmin(ctx, iSCSICmd, failureCodes)
+	return err
+}
 
-	return nil
+func runISCSIBare(ctx context.Context, iSCSICommand string, failureCodes []int) (string, error) {
+	return execISCSIAdmin(ctx, "iscsiadm "+iSCSICommand, failureCodes)
 }
 
-func runISCSIBare(ctx context.Context, iSCSICommand string, checkExitCode []string) (string, error) {
-	iSCSICmd := fmt.Sprintf("iscsiadm %s", iSCSICommand)
-	output, err := utils.ExecShellCmdFilterLog(ctx, iSCSICmd)
-	if err != nil {
-		if err.Error() == "timeout" {
-			return "", err
-		}
+// execISCSIAdmin runs the iscsiadm command in the C locale, and returns the failure as an iscsiAdmError
+// if its exit code is one of failureCodes. The command is not used as the format, since the CHAP
+// passwords may contain the percent sign.
+func execISCSIAdmin(ctx context.Context, iSCSICmd string, failureCodes []int) (string, error) {
+	output, err := utils.ExecShellCmdFilterLog(ctx, "LC_ALL=C %s", iSCSICmd)
+	if err == nil {
+		return output, nil
+	}
+	if err.Error() == "timeout" {
+		return "", err
+	}
 
-		err2 := utils.CheckExistCode(err, checkExitCode)
-		if err2 != nil {
-			log.AddContext(ctx).Errorf("Run bare %s: output=%s, err=%v",
-				utils.MaskSensitiveInfo(iSCSICmd),
-				utils.MaskSensitiveInfo(output), err2)
+	err = newISCSIAdmError(err, output)
+	for _, code := range failureCodes {
+		if isISCSIAdmError(err, code) {
+			log.AddContext(ctx).Warningf("Run %s error: %v", utils.MaskSensitiveInfo(iSCSICmd),
+				utils.MaskSensitiveInfo(err.Error()))
 			return "", err
 		}
 	}
@@ -171,8 +183,8 @@ func runISCSIBare(ctx context.Context, iSCSICommand string, checkExitCode []stri
 func updateISCSIAdminWithExitCode(ctx context.Context,
 	tgtPortal, targetIQN string,
 	iscsiCMD string,
-	checkExitCode []string) error {
-	return runISCSIAdmin(ctx, tgtPortal, targetIQN, iscsiCMD, checkExitCode)
+	failureCodes []int) error {
+	return runISCSIAdmin(ctx, tgtPortal, targetIQN, iscsiCMD, failureCodes)
 }
 
 func iscsiCMD(propertyKey, propertyValue string) string {
@@ -197,7 +209,7 @@ func updateChapInfo(ctx context.Context, tgtPortal, targetIQN string, tgtChapInf
 		}
 
 		err = updateISCSIAdmin(ctx, tgtPortal, targetIQN,
-			"node.session.auth.username", tgtChapInfo.authUserName)
+			"node.session.auth.username", quoteISCSIValue(tgtChapInfo.authUserName))
 		if err != nil {
 			log.AddContext(ctx).Errorf("Update node session auth username %s error, reason: %v",
 				tgtChapInfo.authUserName, err)
@@ -205,69 +217,92 @@ func updateChapInfo(ctx context.Context, tgtPortal, targetIQN string, tgtChapInf
 		}
 
 		err = updateISCSIAdmin(ctx, tgtPortal, targetIQN,
-			"node.session.auth.password", tgtChapInfo.authPassword)
+			"node.session.auth.password", quoteISCSIValue(tgtChapInfo.authPassword))
 		if err != nil {
-			log.AddContext(ctx).Errorf("Update node session auth password %s error, reason: %v",
-				utils.MaskSensitiveInfo(tgtChapInfo.authPassword), err)
+			log.AddContext(ctx).Errorf("Update node session auth password error, reason: %v", err)
 			return err
 		}
-	}
-	return nil
-}
-
-func getAllISCSISession(ctx context.Context) [][]string {
-	checkExitCode := []string{"exit status 0", "exit status 21", "exit status 255"}
-	allSessions, err := runISCSIBare(ctx, "-m session", checkExitCode)
-	if err != nil {
-		log.AddContext(ctx).Warningf("Get all iSCSI session error , reason: %v", err)
-		return nil
-	}
 
-	var iSCSIInfo [][]string
-	for _, iscsi := range strings.Split(allSessions, "\n") {
-		if iscsi != "" {
-			splitInfo := strings.Split(iscsi, " ")
-			if len(splitInfo) < 4 {
-				log.AddContext(ctx).Warningf("iscsi session %s error", splitInfo)
-				continue
-			}
+		// the empty incoming credentials turn the mutual CHAP off, which was maybe configured before
+		err = updateISCSIAdmin(ctx, tgtPortal, targetIQN,
+			"node.session.auth.username_in", quoteISCSIValue(tgtChapInfo.authUserNameIn))
+		if err != nil {
+			log.AddContext(ctx).Errorf("Update node session auth username_in %s error, reason: %v",
+				tgtChapInfo.authUserNameIn, err)
+			return err
+		}
 
-			sid := splitInfo[1][1 : len(splitInfo[1])-1]
-			tgtInfo := strings.Split(splitInfo[2], ",")
-			if len(tgtInfo) < 2 {
-				continue
-			}
-			portal, tpgt := tgtInfo[0], tgtInfo[1]
-			iSCSIInfo = append(iSCSIInfo, []string{splitInfo[0], sid, portal, tpgt, splitInfo[3]})
+		err = updateISCSIAdmin(ctx, tgtPortal, targetIQN,
+			"node.session.auth.password_in", quoteISCSIValue(tgtChapInfo.authPasswordIn))
+		if err != nil {
+			log.AddContext(ctx).Errorf("Update node session auth password_in error, reason: %v", err)
+			return err
 		}
 	}
+	return nil
+}
 
-	return iSCSIInfo
+// quoteISCSIValue quotes the value for the shell, so that the CHAP passwords may have special characters
+// and the empty value is not missing from the iscsiadm command line
+func quoteISCSIValue(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
 }
 
-func connectISCSIPortal(ctx context.Context,
-	tgtPortal, targetIQN string,
-	tgtChapInfo chapInfo) (string, bool) {
-	checkExitCode := []string{"exit status 0", "exit status 21", "exit status 255"}
+// ensureISCSINode creates the node record of the target on the iscsiadm interface
+func ensureISCSINode(ctx context.Context, tgtPortal, targetIQN, iface string) error {
+	failureCodes := []int{iscsiErrNoObjectsFound, iscsiErrNoRecord}
 	// If the host already discovery the target, we do not need to run --op new.
 	// Therefore, we check to see if the target exists, and if we get 255(Not Found), should run --op new.
 	// It will return 21 for No records Found after version 2.0-871
-	err := runISCSIAdmin(ctx, tgtPortal, targetIQN, "", checkExitCode)
+	err := runISCSIAdmin(ctx, tgtPortal, targetIQN, "--interface "+iface, failureCodes)
 	if err != nil {
 		if err.Error() == "timeout" {
-			return "", false
+			return err
 		}
 
 		err := runISCSIAdmin(ctx, tgtPortal, targetIQN,
-			"--interface default --op new", nil)
+			"--interface "+iface+" --op new", nil)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Create new portal %s on interface %s error , reason: %v",
+				tgtPortal, iface, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loginISCSIPortal logs in the target over each of the interfaces, it fails only if none of
+// the logins succeeds. The rejected CHAP credentials fail the login at once rather than being retried.
+func loginISCSIPortal(ctx context.Context, tgtPortal, targetIQN string, ifaces []string) bool {
+	var loggedIn bool
+	failureCodes := []int{iscsiErrSessionExists, iscsiErrLoginAuthFailed, iscsiErrNoRecord}
+	for _, iface := range ifaces {
+		err := runISCSIAdmin(ctx, tgtPortal, targetIQN, "--interface "+iface+" --login", failureCodes)
 		if err != nil {
-			log.AddContext(ctx).Errorf("Create new portal %s error , reason: %v", tgtPortal, err)
-			return "", false
+			log.AddContext(ctx).Warningf("Login iSCSI session %s on interface %s error, reason: %v",
+				tgtPortal, iface, err)
+			continue
+		}
+		loggedIn = true
+	}
+
+	return loggedIn
+}
+
+// connectISCSIPortal logs in the target over the given interfaces, and returns the IDs of the sessions
+func connectISCSIPortal(ctx context.Context,
+	tgtPortal, targetIQN string,
+	tgtChapInfo chapInfo, ifaces []string) ([]string, bool) {
+	for _, iface := range ifaces {
+		err := ensureISCSINode(ctx, tgtPortal, targetIQN, iface)
+		if err != nil {
+			return nil, false
 		}
 	}
 
 	var manualScan bool
-	err = updateISCSIAdmin(ctx, tgtPortal, targetIQN, "node.session.scan", "manual")
+	err := updateISCSIAdmin(ctx, tgtPortal, targetIQN, "node.session.scan", "manual")
 	if err != nil {
 		log.AddContext(ctx).Warningf("Update node session scan mode to manual error, reason: %v",
 			tgtPortal, err)
@@ -276,37 +311,38 @@ func connectISCSIPortal(ctx context.Context,
 
 	err = updateChapInfo(ctx, tgtPortal, targetIQN, tgtChapInfo)
 	if err != nil {
-		log.AddContext(ctx).Errorf("Update chap %s error, reason: %v",
-			utils.MaskSensitiveInfo(tgtChapInfo), err)
-		return "", false
+		log.AddContext(ctx).Errorf("Update chap of portal %s error, reason: %v", tgtPortal, err)
+		return nil, false
 	}
 
 	for i := 0; i < 60; i++ {
-		sessions := getAllISCSISession(ctx)
-		for _, s := range sessions {
-			if s[0] == "tcp:" && strings.ToLower(tgtPortal) == strings.ToLower(s[2]) && targetIQN == s[4] {
-				log.AddContext(ctx).Infof("Login iSCSI session success. Session: %s, manualScan: %s",
-					s[1], manualScan)
-				return s[1], manualScan
+		var sessionIDs []string
+		for _, s := range listISCSISessions(ctx) {
+			if s.transport == iscsiTCPTransport && connector.SamePortal(tgtPortal, s.portal) &&
+				targetIQN == s.targetIQN {
+				sessionIDs = append(sessionIDs, s.id)
 			}
 		}
 
-		checkExitCode := []string{"exit status 0", "exit status 15", "exit status 255"}
-		err := runISCSIAdmin(ctx, tgtPortal, targetIQN, "--login", checkExitCode)
-		if err != nil {
-			log.AddContext(ctx).Warningf("Login iSCSI session %s error, reason: %v", tgtPortal, err)
-			return "", false
+		if len(sessionIDs) >= len(ifaces) || (len(sessionIDs) > 0 && i > 0) {
+			log.AddContext(ctx).Infof("Login iSCSI session success. Sessions: %v, manualScan: %v",
+				sessionIDs, manualScan)
+			return sessionIDs, manualScan
+		}
+
+		if !loginISCSIPortal(ctx, tgtPortal, targetIQN, ifaces) {
+			return nil, false
 		}
 
 		err = updateISCSIAdmin(ctx, tgtPortal, targetIQN, "node.startup", "automatic")
 		if err != nil {
 			log.AddContext(ctx).Warningf("Update node startUp error, reason: %v", err)
-			return "", false
+			return nil, false
 		}
 
 		time.Sleep(time.Second * 2)
 	}
-	return "", false
+	return nil, false
 }
 
 func getHostChannelTargetLun(session, tgtLun string) []string {
@@ -377,8 +413,9 @@ func getDeviceByHCTL(session string, hostChannelTargetLun []string) string {
 }
 
 type deviceScan struct {
-	numRescans     int
-	secondNextScan int
+	numRescans int
+	// nextScan is when the iSCSI host is rescanned next, a block uevent before it only re-checks the device
+	nextScan time.Time
 }
 
 func (s *deviceScan) scan(ctx context.Context,
@@ -391,10 +428,10 @@ func (s *deviceScan) scan(ctx context.Context,
 		}
 
 		if len(req.hostChannelTargetLun) != 0 {
-			if s.secondNextScan <= 0 {
+			if !time.Now().Before(s.nextScan) {
 				s.numRescans++
 				scanISCSI(ctx, req.hostChannelTargetLun)
-				s.secondNextScan = int(math.Pow(float64(s.numRescans+2), 2.0))
+				s.nextScan = time.Now().Add(time.Second * time.Duration(math.Pow(float64(s.numRescans+2), 2.0)))
 			}
 
 			device = getDeviceByHCTL(req.sessionId, req.hostChannelTargetLun)
@@ -406,8 +443,7 @@ func (s *deviceScan) scan(ctx context.Context,
 
 		doScans = s.numRescans <= deviceScanAttemptsDefault && !(device != "" || req.iSCSIShareData.stopConnecting)
 		if doScans {
-			time.Sleep(time.Second)
-			s.secondNextScan--
+			connutils.WaitBlockDeviceEvent(ctx, time.Second)
 		}
 	}
 	return device
@@ -417,24 +453,38 @@ func connectVol(ctx context.Context,
 	tgt singleConnectorInfo,
 	conn connectorInfo,
 	iSCSIShareData *shareData) {
-	var device string
+	loginStart := time.Now()
+	sessions, manualScan := connectISCSIPortal(ctx, tgt.tgtPortal, tgt.tgtIQN, conn.tgtChapInfo, conn.ifaces)
+	recordPortalLogin(tgt.tgtPortal, time.Since(loginStart), len(sessions) != 0)
+	if len(sessions) == 0 {
+		log.AddContext(ctx).Warningf("build iSCSI session %s error", tgt.tgtPortal)
+		if conn.volumeUseMultiPath {
+			addPendingPortal(ctx, tgt, conn)
+		}
+		iSCSIShareData.failedLogin += int64(len(conn.ifaces))
+		iSCSIShareData.stoppedThreads += 1
+		return
+	}
 
-	session, manualScan := connectISCSIPortal(ctx, tgt.tgtPortal, tgt.tgtIQN, conn.tgtChapInfo)
-	if session != "" {
-		var numRescans, secondNextScan int
+	// The interfaces which failed to log in count as failed paths
+	iSCSIShareData.failedLogin += int64(len(conn.ifaces) - len(sessions))
+	for _, session := range sessions {
+		var device string
+		var numRescans int
+		var nextScan time.Time
 		var hostChannelTargetLun []string
 		if manualScan {
 			numRescans = -1
-			secondNextScan = 0
+			nextScan = time.Now()
 		} else {
 			numRescans = 0
-			secondNextScan = 4
+			nextScan = time.Now().Add(time.Second * 4)
 		}
 
 		iSCSIShareData.numLogin += 1
 		dScan := deviceScan{
-			numRescans:     numRescans,
-			secondNextScan: secondNextScan,
+			numRescans: numRescans,
+			nextScan:   nextScan,
 		}
 		device = dScan.scan(ctx, scanRequest{sessionId: session, tgtHostLun: tgt.tgtHostLun,
 			tgtLunWWN: conn.tgtLunWWN, hostChannelTargetLun: hostChannelTargetLun, iSCSIShareData: iSCSIShareData})
@@ -447,32 +497,42 @@ func connectVol(ctx context.Context,
 			iSCSIShareData.foundDevices = append(iSCSIShareData.foundDevices, device)
 			iSCSIShareData.justAddedDevices = append(iSCSIShareData.justAddedDevices, device)
 		}
-	} else {
-		log.AddContext(ctx).Warningf("build iSCSI session %s error", tgt.tgtPortal)
-		iSCSIShareData.failedLogin += 1
 	}
 
 	iSCSIShareData.stoppedThreads += 1
 	return
 }
 
-func constructISCSIInfo(ctx context.Context, conn connectorInfo) []singleConnectorInfo {
-	var iSCSIInfoList []singleConnectorInfo
+// constructISCSIInfo returns the reachable targets, ordered by the health of their portals, and the
+// unreachable ones. Without multipath only the first reachable target is needed.
+func constructISCSIInfo(ctx context.Context, conn connectorInfo) ([]singleConnectorInfo, []singleConnectorInfo) {
+	var candidates []singleConnectorInfo
 	for index, portal := range conn.tgtPortals {
-		ok := connector.CheckHostConnectivity(ctx, portal)
-		if !ok {
-			log.AddContext(ctx).Errorf("failed to check the host connectivity. %s", portal)
-			continue
-		}
-
 		var iSCSIInfo singleConnectorInfo
 		iSCSIInfo.tgtPortal = portal
 		iSCSIInfo.tgtIQN = conn.tgtIQNs[index]
 		iSCSIInfo.tgtHostLun = conn.tgtHostLUNs[index]
+		candidates = append(candidates, iSCSIInfo)
+	}
+
+	var iSCSIInfoList, unreachableList []singleConnectorInfo
+	for _, iSCSIInfo := range sortByPortalHealth(candidates) {
+		checkStart := time.Now()
+		ok := connector.CheckHostConnectivity(ctx, iSCSIInfo.tgtPortal)
+		if !ok {
+			log.AddContext(ctx).Warningf("failed to check the host connectivity. %s", iSCSIInfo.tgtPortal)
+			recordPortalLogin(iSCSIInfo.tgtPortal, time.Since(checkStart), false)
+			unreachableList = append(unreachableList, iSCSIInfo)
+			continue
+		}
+
 		iSCSIInfoList = append(iSCSIInfoList, iSCSIInfo)
+		if !conn.volumeUseMultiPath {
+			break
+		}
 	}
 
-	return iSCSIInfoList
+	return iSCSIInfoList, unreachableList
 }
 
 func tryConnectVolume(ctx context.Context, connMap map[string]interface{}) (string, error) {
@@ -481,15 +541,31 @@ func tryConnectVolume(ctx context.Context, connMap map[string]interface{}) (stri
 		return "", err
 	}
 
-	constructInfos := constructISCSIInfo(ctx, conn)
+	constructInfos, unreachableInfos := constructISCSIInfo(ctx, conn)
+	if len(constructInfos) == 0 {
+		return "", utils.Errorf(ctx, "none of iSCSI portals %v is reachable", conn.tgtPortals)
+	}
+
+	conn.ifaces, err = getStorageNetworkIfaces(ctx, conn.storageInterfaces)
+	if err != nil {
+		return "", err
+	}
+
 	lenIndex := len(constructInfos)
 	if !conn.volumeUseMultiPath {
 		lenIndex = 1
+		conn.ifaces = conn.ifaces[:1]
+	} else {
+		conn.ifaces = append(conn.ifaces, getISCSIInterfaces(ctx)...)
+		for _, tgt := range unreachableInfos {
+			addPendingPortal(ctx, tgt, conn)
+		}
 	}
 
 	var wait sync.WaitGroup
 	iSCSIShareData := connectVolume(ctx, &wait, constructInfos[:lenIndex], conn)
-	diskName, err := findDevice(ctx, conn, iSCSIShareData, lenIndex)
+	// Each target is logged in over each of the interfaces
+	diskName, err := findDevice(ctx, conn, iSCSIShareData, lenIndex*len(conn.ifaces))
 	if err != nil {
 		log.AddContext(ctx).Errorf("failed to find a disk. %v", err)
 	}
@@ -499,6 +575,22 @@ func tryConnectVolume(ctx context.Context, connMap map[string]interface{}) (stri
 	return checkDeviceAvailable(ctx, conn, iSCSIShareData, diskName, int(iSCSIShareData.numLogin))
 }
 
+// getISCSIInterfaces returns the iscsiadm interfaces with their own initiator names, which are
+// registered on the storage as well
+func getISCSIInterfaces(ctx context.Context) []string {
+	ifaces, err := proto.GetISCSIInterfaces(ctx)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Get iSCSI interfaces error, only log in over the default one: %v", err)
+		return nil
+	}
+
+	var names []string
+	for _, iface := range ifaces {
+		names = append(names, iface.Name)
+	}
+	return names
+}
+
 func catchConnectError(ctx context.Context) {
 	if r := recover(); r != nil {
 		log.AddContext(ctx).Errorf("runtime error caught in loop routine: %v", r)
@@ -528,7 +620,7 @@ func findDevice(ctx context.Context,
 	iSCSIShareData *shareData,
 	lenIndex int) (string, error) {
 	if !conn.volumeUseMultiPath {
-		scanSingle(iSCSIShareData)
+		scanSingle(ctx, iSCSIShareData)
 		return "", nil
 	}
 
@@ -592,12 +684,13 @@ func checkSinglePathAvailable(ctx context.Context, iSCSIShareData *shareData, tg
 	return device, nil
 }
 
-func scanSingle(iSCSIShareData *shareData) {
-	for i := 0; i < 15; i++ {
+func scanSingle(ctx context.Context, iSCSIShareData *shareData) {
+	deadline := time.Now().Add(time.Second * 30)
+	for time.Now().Before(deadline) {
 		if len(iSCSIShareData.foundDevices) != 0 {
 			break
 		}
-		time.Sleep(time.Second * 2)
+		connutils.WaitBlockDeviceEvent(ctx, time.Second*2)
 	}
 }
 
@@ -766,13 +859,13 @@ func findDiskOfDM(ctx context.Context, lenIndex int, LunWWN string, iSCSIShareDa
 
 func getISCSISession(ctx context.Context, devSessionIds []string) []singleConnectorInfo {
 	var devConnectorInfos []singleConnectorInfo
-	sessions := getAllISCSISession(ctx)
+	sessions := listISCSISessions(ctx)
 	for _, devSessionId := range devSessionIds {
 		var devConnectorInfo singleConnectorInfo
 		for _, s := range sessions {
-			if devSessionId == s[1] {
-				devConnectorInfo.tgtPortal = s[2]
-				devConnectorInfo.tgtIQN = s[4]
+			if devSessionId == s.id {
+				devConnectorInfo.tgtPortal = s.portal
+				devConnectorInfo.tgtIQN = s.targetIQN
 				devConnectorInfos = append(devConnectorInfos, devConnectorInfo)
 				break
 			}
@@ -782,20 +875,22 @@ func getISCSISession(ctx context.Context, devSessionIds []string) []singleConnec
 }
 
 func disconnectFromISCSIPortal(ctx context.Context, tgtPortal, targetIQN string) {
-	checkExitCode := []string{"exit status 0", "exit status 15", "exit status 255"}
+	removePendingPortals(tgtPortal, targetIQN)
+
+	failureCodes := []int{iscsiErrSessionExists, iscsiErrNoRecord}
 	err := updateISCSIAdminWithExitCode(ctx, tgtPortal, targetIQN,
 		iscsiCMD("node.startup", "manual"),
-		checkExitCode)
+		failureCodes)
 	if err != nil {
 		log.AddContext(ctx).Warningf("Update node startUp error, reason: %v", err)
 	}
 
-	err = runISCSIAdmin(ctx, tgtPortal, targetIQN, "--logout", checkExitCode)
+	err = runISCSIAdmin(ctx, tgtPortal, targetIQN, "--logout", failureCodes)
 	if err != nil {
 		log.AddContext(ctx).Warningf("Logout iSCSI node error, reason: %v", err)
 	}
 
-	err = runISCSIAdmin(ctx, tgtPortal, targetIQN, "--op delete", checkExitCode)
+	err = runISCSIAdmin(ctx, tgtPortal, targetIQN, "--op delete", failureCodes)
 	if err != nil {
 		log.AddContext(ctx).Warningf("Delete iSCSI node error, reason: %v", err)
 	}
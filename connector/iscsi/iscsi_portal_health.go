@@ -0,0 +1,104 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package iscsi
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// portalFailureWindow is how long a login failure counts against a portal
+	portalFailureWindow = 10 * time.Minute
+	// portalFailurePenalty is the latency a login failure is weighted as
+	portalFailurePenalty = 30 * time.Second
+	// portalLatencyWeight is the weight of the latest login latency in the smoothed latency
+	portalLatencyWeight = 0.3
+)
+
+// portalHealth is the recent login history of a portal on this node
+type portalHealth struct {
+	failures    int
+	lastFailure time.Time
+	latency     time.Duration
+}
+
+var (
+	portalHealthMutex sync.Mutex
+	portalHealths     = map[string]*portalHealth{}
+)
+
+var now = time.Now
+
+// recordPortalLogin updates the health of the portal with the result of a login attempt
+func recordPortalLogin(portal string, latency time.Duration, success bool) {
+	portalHealthMutex.Lock()
+	defer portalHealthMutex.Unlock()
+
+	health, exist := portalHealths[portal]
+	if !exist {
+		health = &portalHealth{latency: latency}
+		portalHealths[portal] = health
+	}
+
+	if !success {
+		if now().Sub(health.lastFailure) > portalFailureWindow {
+			health.failures = 0
+		}
+		health.failures++
+		health.lastFailure = now()
+		return
+	}
+
+	health.failures = 0
+	health.latency = time.Duration(portalLatencyWeight*float64(latency) +
+		(1-portalLatencyWeight)*float64(health.latency))
+}
+
+// portalScore weights the recent login failures and the login latency of the portal, the
+// lower the better. Portals without history score 0, so they are preferred to slow ones.
+func portalScore(portal string) time.Duration {
+	health, exist := portalHealths[portal]
+	if !exist {
+		return 0
+	}
+
+	score := health.latency
+	if health.failures > 0 && now().Sub(health.lastFailure) <= portalFailureWindow {
+		score += time.Duration(health.failures) * portalFailurePenalty
+	}
+	return score
+}
+
+// sortByPortalHealth orders the targets by the health of their portals, so logins are tried
+// on healthy portals first. Targets of the same score keep the configured order.
+func sortByPortalHealth(infos []singleConnectorInfo) []singleConnectorInfo {
+	portalHealthMutex.Lock()
+	scores := make(map[string]time.Duration, len(infos))
+	for _, info := range infos {
+		scores[info.tgtPortal] = portalScore(info.tgtPortal)
+	}
+	portalHealthMutex.Unlock()
+
+	sorted := make([]singleConnectorInfo, len(infos))
+	copy(sorted, infos)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return scores[sorted[i].tgtPortal] < scores[sorted[j].tgtPortal]
+	})
+	return sorted
+}
@@ -0,0 +1,60 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package iscsi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortByPortalHealth(t *testing.T) {
+	current := time.Now()
+	stubs := gostub.Stub(&now, func() time.Time { return current })
+	defer stubs.Reset()
+	defer func() {
+		portalHealths = map[string]*portalHealth{}
+	}()
+
+	infos := []singleConnectorInfo{
+		{tgtPortal: "192.168.125.25:3260"},
+		{tgtPortal: "192.168.125.26:3260"},
+		{tgtPortal: "192.168.125.27:3260"},
+		{tgtPortal: "192.168.125.28:3260"},
+	}
+
+	recordPortalLogin("192.168.125.25:3260", time.Second, false)
+	recordPortalLogin("192.168.125.26:3260", 2*time.Second, true)
+	recordPortalLogin("192.168.125.27:3260", 100*time.Millisecond, true)
+
+	sorted := sortByPortalHealth(infos)
+	assert.Equal(t, []singleConnectorInfo{
+		{tgtPortal: "192.168.125.28:3260"},
+		{tgtPortal: "192.168.125.27:3260"},
+		{tgtPortal: "192.168.125.26:3260"},
+		{tgtPortal: "192.168.125.25:3260"},
+	}, sorted)
+
+	// The failure does not count any more once it is out of the window
+	current = current.Add(portalFailureWindow + time.Minute)
+	sorted = sortByPortalHealth(infos)
+	assert.Equal(t, "192.168.125.25:3260", sorted[2].tgtPortal)
+	assert.Equal(t, "192.168.125.26:3260", sorted[3].tgtPortal)
+	assert.Equal(t, time.Second, portalScore("192.168.125.25:3260"))
+}
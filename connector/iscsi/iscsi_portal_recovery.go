@@ -0,0 +1,150 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package iscsi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"huawei-csi-driver/connector"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	// portalRecoveryInterval is the interval to retry the logins on the skipped portals
+	portalRecoveryInterval = time.Minute
+	// portalRecoveryTimeout is how long the logins on a skipped portal are retried
+	portalRecoveryTimeout = time.Hour
+)
+
+// pendingPortal is a target of a multipath volume which was skipped because its portal was dead
+type pendingPortal struct {
+	tgt   singleConnectorInfo
+	conn  connectorInfo
+	since time.Time
+}
+
+var (
+	pendingPortalsMutex sync.Mutex
+	pendingPortals      = map[string]*pendingPortal{}
+	portalRecoveryOnce  sync.Once
+)
+
+func pendingPortalKey(tgt singleConnectorInfo) string {
+	return tgt.tgtPortal + "," + tgt.tgtIQN + "," + tgt.tgtHostLun
+}
+
+// addPendingPortal retries the login on the skipped target periodically, until the portal recovers
+// or the volume is disconnected
+func addPendingPortal(ctx context.Context, tgt singleConnectorInfo, conn connectorInfo) {
+	pendingPortalsMutex.Lock()
+	defer pendingPortalsMutex.Unlock()
+
+	key := pendingPortalKey(tgt)
+	if _, exist := pendingPortals[key]; !exist {
+		log.AddContext(ctx).Warningf("Skip the dead iSCSI portal %s, retry adding its session every %v",
+			tgt.tgtPortal, portalRecoveryInterval)
+		pendingPortals[key] = &pendingPortal{tgt: tgt, conn: conn, since: now()}
+	}
+
+	portalRecoveryOnce.Do(func() {
+		go retryPendingPortalsPeriodically()
+	})
+}
+
+// removePendingPortals stops retrying the logins on the target
+func removePendingPortals(tgtPortal, targetIQN string) {
+	pendingPortalsMutex.Lock()
+	defer pendingPortalsMutex.Unlock()
+
+	for key, pending := range pendingPortals {
+		if pending.tgt.tgtPortal == tgtPortal && pending.tgt.tgtIQN == targetIQN {
+			delete(pendingPortals, key)
+		}
+	}
+}
+
+func retryPendingPortalsPeriodically() {
+	ticker := time.NewTicker(portalRecoveryInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		func() {
+			ctx := context.Background()
+			defer utils.RecoverPanic(ctx)
+			retryPendingPortals(ctx)
+		}()
+	}
+}
+
+// retryPendingPortals adds the sessions of the skipped portals which recovered
+func retryPendingPortals(ctx context.Context) {
+	pendingPortalsMutex.Lock()
+	pendings := make(map[string]*pendingPortal, len(pendingPortals))
+	for key, pending := range pendingPortals {
+		pendings[key] = pending
+	}
+	pendingPortalsMutex.Unlock()
+
+	for key, pending := range pendings {
+		if now().Sub(pending.since) > portalRecoveryTimeout {
+			log.AddContext(ctx).Warningf("iSCSI portal %s did not recover in %v, stop retrying it",
+				pending.tgt.tgtPortal, portalRecoveryTimeout)
+		} else if !recoverPortal(ctx, pending) {
+			continue
+		}
+
+		pendingPortalsMutex.Lock()
+		delete(pendingPortals, key)
+		pendingPortalsMutex.Unlock()
+	}
+}
+
+// recoverPortal logs in the target of the skipped portal and scans the LUN over the new sessions. It
+// returns false if the portal is still dead.
+var recoverPortal = func(ctx context.Context, pending *pendingPortal) bool {
+	device, _, err := connector.GetVirtualDevice(ctx, pending.conn.tgtLunWWN)
+	if err == nil && device == "" {
+		log.AddContext(ctx).Infof("Volume %s is disconnected, stop retrying iSCSI portal %s",
+			pending.conn.tgtLunWWN, pending.tgt.tgtPortal)
+		return true
+	}
+
+	if !connector.CheckHostConnectivity(ctx, pending.tgt.tgtPortal) {
+		return false
+	}
+
+	loginStart := time.Now()
+	sessions, _ := connectISCSIPortal(ctx, pending.tgt.tgtPortal, pending.tgt.tgtIQN,
+		pending.conn.tgtChapInfo, pending.conn.ifaces)
+	recordPortalLogin(pending.tgt.tgtPortal, time.Since(loginStart), len(sessions) != 0)
+	if len(sessions) == 0 {
+		return false
+	}
+
+	for _, session := range sessions {
+		hostChannelTargetLun := getHostChannelTargetLun(session, pending.tgt.tgtHostLun)
+		if hostChannelTargetLun != nil {
+			scanISCSI(ctx, hostChannelTargetLun)
+		}
+	}
+
+	log.AddContext(ctx).Infof("iSCSI portal %s recovered, added sessions %v", pending.tgt.tgtPortal, sessions)
+	return true
+}
@@ -0,0 +1,81 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package iscsi
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	logDir  = "/var/log/huawei/"
+	logName = "iscsiTest.log"
+)
+
+func TestRetryPendingPortals(t *testing.T) {
+	current := time.Now()
+	stubs := gostub.Stub(&now, func() time.Time { return current })
+	defer stubs.Reset()
+	defer func() {
+		pendingPortals = map[string]*pendingPortal{}
+	}()
+
+	recovered := map[string]bool{"192.168.125.25:3260": true}
+	stubs.Stub(&recoverPortal, func(ctx context.Context, pending *pendingPortal) bool {
+		return recovered[pending.tgt.tgtPortal]
+	})
+
+	ctx := context.Background()
+	pendingPortals = map[string]*pendingPortal{}
+	for _, portal := range []string{"192.168.125.25:3260", "192.168.125.26:3260", "192.168.125.27:3260"} {
+		tgt := singleConnectorInfo{tgtPortal: portal, tgtIQN: "iqn.xxx", tgtHostLun: "1"}
+		pendingPortals[pendingPortalKey(tgt)] = &pendingPortal{tgt: tgt, since: current}
+	}
+	// The portal which has been dead for too long is not retried any more
+	pendingPortals[pendingPortalKey(singleConnectorInfo{tgtPortal: "192.168.125.27:3260",
+		tgtIQN: "iqn.xxx", tgtHostLun: "1"})].since = current.Add(-portalRecoveryTimeout - time.Minute)
+
+	retryPendingPortals(ctx)
+	assert.Len(t, pendingPortals, 1)
+	assert.Contains(t, pendingPortals, "192.168.125.26:3260,iqn.xxx,1")
+
+	removePendingPortals("192.168.125.26:3260", "iqn.xxx")
+	assert.Empty(t, pendingPortals)
+}
+
+func TestMain(m *testing.M) {
+	if err := log.InitLogging(logName); err != nil {
+		log.Errorf("init logging: %s failed. error: %v", logName, err)
+		os.Exit(1)
+	}
+	logFile := path.Join(logDir, logName)
+	defer func() {
+		if err := os.RemoveAll(logFile); err != nil {
+			log.Errorf("Remove file: %s failed. error: %s", logFile, err)
+		}
+	}()
+
+	m.Run()
+}
@@ -0,0 +1,161 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package iscsi
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"huawei-csi-driver/utils/log"
+)
+
+// the exit codes of iscsiadm, see include/iscsi_err.h of open-iscsi
+const (
+	iscsiErrTransportTimeout = 8
+	iscsiErrSessionExists    = 15
+	iscsiErrNoObjectsFound   = 21
+	iscsiErrLoginAuthFailed  = 24
+	iscsiErrNoRecord         = 255
+
+	iscsiTCPTransport = "iscsi_tcp"
+)
+
+var iscsiErrDescriptions = map[int]string{
+	iscsiErrTransportTimeout: "transport timeout",
+	iscsiErrSessionExists:    "session exists",
+	iscsiErrNoObjectsFound:   "no records found",
+	iscsiErrLoginAuthFailed:  "login authentication failed",
+	iscsiErrNoRecord:         "no record",
+}
+
+// iscsiAdmError is the failure of an iscsiadm command with its exit code, so the callers tell the failures
+// by the code instead of the text of the output, which varies with the locale and the version
+type iscsiAdmError struct {
+	exitCode int
+	output   string
+}
+
+func newISCSIAdmError(err error, output string) error {
+	var exitCode int
+	if _, scanErr := fmt.Sscanf(err.Error(), "exit status %d", &exitCode); scanErr != nil {
+		return err
+	}
+	return &iscsiAdmError{exitCode: exitCode, output: strings.TrimSpace(output)}
+}
+
+func (e *iscsiAdmError) Error() string {
+	description, exist := iscsiErrDescriptions[e.exitCode]
+	if !exist {
+		description = "unknown error"
+	}
+	return fmt.Sprintf("iscsiadm exit status %d (%s): %s", e.exitCode, description, e.output)
+}
+
+// isISCSIAdmError tells whether the error is the failure of iscsiadm with the exit code
+func isISCSIAdmError(err error, exitCode int) bool {
+	admErr, ok := err.(*iscsiAdmError)
+	return ok && admErr.exitCode == exitCode
+}
+
+// iscsiSession is an iSCSI session of the node
+type iscsiSession struct {
+	id        string
+	transport string
+	portal    string
+	tpgt      string
+	targetIQN string
+	iface     string
+}
+
+var (
+	iscsiSessionClassPath    = "/sys/class/iscsi_session"
+	iscsiConnectionClassPath = "/sys/class/iscsi_connection"
+	scsiHostClassPath        = "/sys/class/scsi_host"
+)
+
+func readSysfsAttr(dir, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// listISCSISessions reads the iSCSI sessions of the node from sysfs, where the kernel exposes them
+// with their targets and connections, instead of parsing the output of "iscsiadm -m session"
+func listISCSISessions(ctx context.Context) []iscsiSession {
+	entries, err := ioutil.ReadDir(iscsiSessionClassPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.AddContext(ctx).Warningf("List iSCSI sessions error: %v", err)
+		}
+		return nil
+	}
+
+	var sessions []iscsiSession
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "session") {
+			continue
+		}
+
+		session, err := readISCSISession(entry.Name())
+		if err != nil {
+			log.AddContext(ctx).Warningf("Read iSCSI %s error: %v", entry.Name(), err)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions
+}
+
+func readISCSISession(name string) (iscsiSession, error) {
+	sessionDir := filepath.Join(iscsiSessionClassPath, name)
+	id := strings.TrimPrefix(name, "session")
+	session := iscsiSession{
+		id:        id,
+		tpgt:      readSysfsAttr(sessionDir, "tpgt"),
+		targetIQN: readSysfsAttr(sessionDir, "targetname"),
+		iface:     readSysfsAttr(sessionDir, "ifacename"),
+	}
+	if session.targetIQN == "" {
+		return session, fmt.Errorf("no target name of session %s", id)
+	}
+
+	// the connections of a session are numbered from 0
+	connectionDir := filepath.Join(iscsiConnectionClassPath, "connection"+id+":0")
+	address := readSysfsAttr(connectionDir, "persistent_address")
+	port := readSysfsAttr(connectionDir, "persistent_port")
+	if address == "" || port == "" {
+		return session, fmt.Errorf("no persistent address of session %s", id)
+	}
+	session.portal = net.JoinHostPort(address, port)
+
+	// the session device is under the SCSI host of the transport, such as .../host3/session1
+	realPath, err := filepath.EvalSymlinks(filepath.Join(sessionDir, "device"))
+	if err == nil {
+		session.transport = readSysfsAttr(filepath.Join(scsiHostClassPath, filepath.Base(filepath.Dir(realPath))),
+			"proc_name")
+	}
+
+	return session, nil
+}
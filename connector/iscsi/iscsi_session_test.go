@@ -0,0 +1,67 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package iscsi
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSysfsAttrs(t *testing.T, dir string, attrs map[string]string) {
+	assert.NoError(t, os.MkdirAll(dir, 0750))
+	for name, value := range attrs {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(value+"\n"), 0640))
+	}
+}
+
+func TestListISCSISessions(t *testing.T) {
+	root := t.TempDir()
+	stubs := gostub.Stub(&iscsiSessionClassPath, filepath.Join(root, "class", "iscsi_session"))
+	defer stubs.Reset()
+	stubs.Stub(&iscsiConnectionClassPath, filepath.Join(root, "class", "iscsi_connection"))
+	stubs.Stub(&scsiHostClassPath, filepath.Join(root, "class", "scsi_host"))
+
+	sessionDevice := filepath.Join(root, "devices", "host3", "session1")
+	assert.NoError(t, os.MkdirAll(sessionDevice, 0750))
+	writeSysfsAttrs(t, filepath.Join(iscsiSessionClassPath, "session1"),
+		map[string]string{"targetname": "iqn.2006-08.com.huawei:oceanstor:21", "tpgt": "4", "ifacename": "default"})
+	assert.NoError(t, os.Symlink(sessionDevice, filepath.Join(iscsiSessionClassPath, "session1", "device")))
+	writeSysfsAttrs(t, filepath.Join(iscsiConnectionClassPath, "connection1:0"),
+		map[string]string{"persistent_address": "fe80::1", "persistent_port": "3260"})
+	writeSysfsAttrs(t, filepath.Join(scsiHostClassPath, "host3"), map[string]string{"proc_name": "iscsi_tcp"})
+	// the session being torn down has no connection
+	writeSysfsAttrs(t, filepath.Join(iscsiSessionClassPath, "session2"),
+		map[string]string{"targetname": "iqn.2006-08.com.huawei:oceanstor:22"})
+
+	assert.Equal(t, []iscsiSession{{id: "1", transport: iscsiTCPTransport, portal: "[fe80::1]:3260", tpgt: "4",
+		targetIQN: "iqn.2006-08.com.huawei:oceanstor:21", iface: "default"}}, listISCSISessions(context.TODO()))
+}
+
+func TestISCSIAdmError(t *testing.T) {
+	err := newISCSIAdmError(errors.New("exit status 24"), "iscsiadm: Login failed\n")
+	assert.True(t, isISCSIAdmError(err, iscsiErrLoginAuthFailed))
+	assert.Equal(t, "iscsiadm exit status 24 (login authentication failed): iscsiadm: Login failed", err.Error())
+
+	err = newISCSIAdmError(errors.New("signal: killed"), "")
+	assert.False(t, isISCSIAdmError(err, iscsiErrLoginAuthFailed))
+}
@@ -0,0 +1,63 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package iscsi
+
+import (
+	"context"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// storageIfacePrefix is the prefix of the iscsiadm interfaces bound to the storage NICs
+const storageIfacePrefix = "csi-"
+
+// getStorageNetworkIfaces returns the iscsiadm interfaces bound to the storage NICs, which are
+// created if needed. The default interface is used if no storage NIC is specified.
+func getStorageNetworkIfaces(ctx context.Context, nics []string) ([]string, error) {
+	if len(nics) == 0 {
+		return []string{"default"}, nil
+	}
+
+	var ifaces []string
+	for _, nic := range nics {
+		iface := storageIfacePrefix + nic
+		err := ensureStorageIface(ctx, iface, nic)
+		if err != nil {
+			log.AddContext(ctx).Warningf("Bind iSCSI interface %s to NIC %s error: %v", iface, nic, err)
+			continue
+		}
+		ifaces = append(ifaces, iface)
+	}
+
+	if len(ifaces) == 0 {
+		return nil, utils.Errorf(ctx, "failed to bind iSCSI interfaces to storage NICs %v", nics)
+	}
+	return ifaces, nil
+}
+
+// ensureStorageIface creates the iscsiadm interface bound to the NIC. The exit codes of iscsiadm
+// are not checked, as creating an existing interface fails, a broken one fails to log in later.
+func ensureStorageIface(ctx context.Context, iface, nic string) error {
+	_, err := runISCSIBare(ctx, "-m iface -I "+iface+" --op new", nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = runISCSIBare(ctx, "-m iface -I "+iface+" --op update -n iface.net_ifacename -v "+nic, nil)
+	return err
+}
@@ -38,10 +38,15 @@ import (
 type connectorInfo struct {
 	srcType    string
 	sourcePath string
+	lunWWN     string
 	targetPath string
 	fsType     string
 	mntFlags   mountParam
 	accessMode csi.VolumeCapability_AccessMode_Mode
+	// mkfsOptions are the extra options of formatting the block device
+	mkfsOptions string
+	// checkFs is whether to check the existing filesystem of the block device before mounting it
+	checkFs bool
 }
 
 type mountParam struct {
@@ -73,12 +78,21 @@ func parseNFSInfo(ctx context.Context,
 		return nil, errors.New(msg)
 	}
 
+	lunWWN, _ := connectionProperties["lunWWN"].(string)
+	if srcType == connector.MountBlockType && lunWWN == "" {
+		msg := "there are no lun WWN in the connection info"
+		log.AddContext(ctx).Errorln(msg)
+		return nil, errors.New(msg)
+	}
+
 	fsType, _ := connectionProperties["fsType"].(string)
 	if fsType == "" {
 		fsType = "ext4"
 	}
 
 	accessMode, _ := connectionProperties["accessMode"].(csi.VolumeCapability_AccessMode_Mode)
+	checkFs, _ := connectionProperties["checkFsBeforeMount"].(bool)
+	mkfsOptions, _ := connectionProperties["mkfsOptions"].(string)
 	mntDashO, _ := connectionProperties["mountFlags"].(string)
 	protocol, _ := connectionProperties["protocol"].(string)
 	var mntDashT string
@@ -88,9 +102,12 @@ func parseNFSInfo(ctx context.Context,
 
 	con.srcType = srcType
 	con.sourcePath = sourcePath
+	con.lunWWN = lunWWN
 	con.targetPath = targetPath
 	con.fsType = fsType
 	con.accessMode = accessMode
+	con.checkFs = checkFs
+	con.mkfsOptions = strings.TrimSpace(mkfsOptions)
 	con.mntFlags = mountParam{dashO: strings.TrimSpace(mntDashO), dashT: mntDashT}
 
 	return &con, nil
@@ -109,7 +126,7 @@ func tryConnectVolume(ctx context.Context, connMap map[string]interface{}) (stri
 			return "", err
 		}
 
-		err = mountDisk(ctx, conn.sourcePath, conn.targetPath, conn.fsType, conn.mntFlags, conn.accessMode)
+		err = mountDisk(ctx, conn)
 		if err != nil {
 			return "", err
 		}
@@ -118,6 +135,11 @@ func tryConnectVolume(ctx context.Context, connMap map[string]interface{}) (stri
 		if err != nil {
 			return "", err
 		}
+	case connector.MountDeviceType:
+		err = bindDevice(ctx, conn.sourcePath, conn.targetPath, conn.mntFlags)
+		if err != nil {
+			return "", err
+		}
 	default:
 		return "", errors.New("not support source type")
 	}
@@ -144,6 +166,71 @@ func mountFS(ctx context.Context, sourcePath, targetPath string, flags mountPara
 	return mountUnix(ctx, sourcePath, targetPath, flags, false)
 }
 
+// bindDevice bind mounts the block device the source path links to onto the target file, the way raw block
+// volumes are published into the pods, so no filesystem is created or mounted on the device
+func bindDevice(ctx context.Context, sourcePath, targetPath string, flags mountParam) error {
+	devPath, err := filepath.EvalSymlinks(sourcePath)
+	if err != nil {
+		return utils.Errorf(ctx, "Resolve the device of %s error: %v", sourcePath, err)
+	}
+
+	info, err := os.Stat(devPath)
+	if err != nil {
+		return utils.Errorf(ctx, "Stat device %s error: %v", devPath, err)
+	}
+	if info.Mode()&os.ModeDevice == 0 || info.Mode()&os.ModeCharDevice != 0 {
+		return utils.Errorf(ctx, "%s is not a block device", devPath)
+	}
+
+	err = preMountFile(targetPath)
+	if err != nil {
+		return utils.Errorf(ctx, "Create the target file %s error: %v", targetPath, err)
+	}
+
+	mountMap, err := readMountPoints(ctx)
+	if err != nil {
+		return err
+	}
+	if _, exist := mountMap[targetPath]; exist {
+		log.AddContext(ctx).Infof("Device %s is already bind to %s", devPath, targetPath)
+		return nil
+	}
+
+	output, err := utils.ExecShellCmd(ctx, "mount --bind %s %s", devPath, targetPath)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Bind device %s to %s error: %s", devPath, targetPath, output)
+		return err
+	}
+
+	if !utils.IsContain("ro", strings.Split(flags.dashO, ",")) {
+		return nil
+	}
+
+	// the read-only flag is ignored by the bind mount of the older util-linux, so remount it
+	output, err = utils.ExecShellCmd(ctx, "mount -o remount,bind,ro %s", targetPath)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Remount %s read-only error: %s", targetPath, output)
+		return err
+	}
+	return nil
+}
+
+func preMountFile(targetPath string) error {
+	if _, err := os.Stat(targetPath); err == nil || !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0750); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(targetPath, os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
 var readFile = ioutil.ReadFile
 
 func readMountPoints(ctx context.Context) (map[string]string, error) {
@@ -250,6 +337,7 @@ func mountUnix(ctx context.Context, sourcePath, targetPath string, flags mountPa
 	}
 
 	flags = appendXFSMountFlags(ctx, sourcePath, flags)
+	options := strings.Split(flags.dashO, ",")
 
 	if flags.dashT != "" {
 		flags.dashT = fmt.Sprintf("-t %s", flags.dashT)
@@ -265,6 +353,16 @@ func mountUnix(ctx context.Context, sourcePath, targetPath string, flags mountPa
 		return err
 	}
 
+	if !utils.IsContain("bind", options) || !utils.IsContain("ro", options) {
+		return nil
+	}
+
+	// the read-only flag is ignored by the bind mount of the older util-linux, so remount it
+	output, err = utils.ExecShellCmd(ctx, "mount -o remount,bind,ro %s", targetPath)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Remount %s read-only error: %s", targetPath, output)
+		return err
+	}
 	return nil
 }
 
@@ -301,26 +399,45 @@ func getFSType(ctx context.Context, sourcePath string) (string, error) {
 	return "", errors.New("get fsType failed")
 }
 
-func formatDisk(ctx context.Context, sourcePath, fsType, diskSizeType string) error {
+func formatDisk(ctx context.Context, sourcePath, fsType, diskSizeType, mkfsOptions string) error {
+	var options []string
+	if mkfsOptions != "" {
+		var err error
+		options, err = connector.SplitMkfsOptions(fsType, mkfsOptions)
+		if err != nil {
+			return utils.KindErrorf(ctx, utils.ErrFailedPrecondition, "invalid mkfs options %q: %v",
+				mkfsOptions, err)
+		}
+	}
+
 	var cmd string
 	if "xfs" == fsType {
-		cmd = fmt.Sprintf("mkfs -t %s -f %s", fsType, sourcePath)
+		cmd = fmt.Sprintf("mkfs -t %s -f", fsType)
+	} else if utils.IsContain("-T", options) {
+		// the usage type given by the options takes the place of the one of the disk size
+		cmd = fmt.Sprintf("mkfs -t %s -F", fsType)
 	} else {
 		// Handle ext types
 		switch diskSizeType {
 		case "default":
-			cmd = fmt.Sprintf("mkfs -t %s -F %s", fsType, sourcePath)
+			cmd = fmt.Sprintf("mkfs -t %s -F", fsType)
 		case "big":
-			cmd = fmt.Sprintf("mkfs -t %s -T big -F %s", fsType, sourcePath)
+			cmd = fmt.Sprintf("mkfs -t %s -T big -F", fsType)
 		case "huge":
-			cmd = fmt.Sprintf("mkfs -t %s -T huge -F %s", fsType, sourcePath)
+			cmd = fmt.Sprintf("mkfs -t %s -T huge -F", fsType)
 		case "large":
-			cmd = fmt.Sprintf("mkfs -t %s -T largefile -F %s", fsType, sourcePath)
+			cmd = fmt.Sprintf("mkfs -t %s -T largefile -F", fsType)
 		case "veryLarge":
-			cmd = fmt.Sprintf("mkfs -t %s -T largefile4 -F %s", fsType, sourcePath)
+			cmd = fmt.Sprintf("mkfs -t %s -T largefile4 -F", fsType)
 		}
 	}
-	output, err := utils.ExecShellCmd(ctx, cmd)
+	if len(options) != 0 {
+		cmd = fmt.Sprintf("%s %s", cmd, strings.Join(options, " "))
+	}
+	cmd = fmt.Sprintf("%s %s", cmd, sourcePath)
+
+	// the options may contain the percent sign, so the command is not used as the format
+	output, err := utils.ExecShellCmd(ctx, "%s", cmd)
 	if err != nil {
 		if strings.Contains(output, "in use by the system") {
 			log.AddContext(ctx).Infof("The disk %s is in formatting, wait for 10 second", sourcePath)
@@ -333,6 +450,58 @@ func formatDisk(ctx context.Context, sourcePath, fsType, diskSizeType string) er
 	return nil
 }
 
+// checkFilesystem checks the filesystem on the device read-only, the corruption is returned as an error.
+// A dirty journal or log is left to be replayed by the mount, since the read-only check can't replay it.
+var checkFilesystem = func(ctx context.Context, sourcePath, fsType string) error {
+	var cmd string
+	var corruptedCode int
+	var dirtyLog string
+	switch fsType {
+	case "ext2", "ext3", "ext4":
+		// e2fsck exits with 4 if the errors are left uncorrected
+		cmd, corruptedCode, dirtyLog = "e2fsck -n %s", 4, "skipping journal recovery"
+	case "xfs":
+		// xfs_repair exits with 1 if the corruption is detected in the no modify mode
+		cmd, corruptedCode, dirtyLog = "xfs_repair -n %s", 1, "metadata changes in a log"
+	default:
+		log.AddContext(ctx).Infof("Checking filesystem %s of %s is not supported, skip it", fsType, sourcePath)
+		return nil
+	}
+
+	output, err := utils.ExecShellCmd(ctx, cmd, sourcePath)
+	if err == nil {
+		return nil
+	}
+
+	if strings.Contains(output, dirtyLog) {
+		log.AddContext(ctx).Warningf("Filesystem %s of %s was not cleanly unmounted, leave it to the mount "+
+			"to replay, output: %s", fsType, sourcePath, output)
+		return nil
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == corruptedCode {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition, "filesystem %s of %s is corrupted, "+
+			"repair it before mounting, output: %s", fsType, sourcePath, output)
+	}
+
+	return utils.Errorf(ctx, "check filesystem %s of %s error: %v, output: %s", fsType, sourcePath, err, output)
+}
+
+// checkFsBeforeMount checks the filesystem of the device unless it is already mounted to the target path,
+// checking a mounted filesystem reports false errors
+func checkFsBeforeMount(ctx context.Context, sourcePath, targetPath, fsType string) error {
+	mountMap, err := readMountPoints(ctx)
+	if err != nil {
+		return err
+	}
+	if _, exist := mountMap[targetPath]; exist {
+		return nil
+	}
+
+	log.AddContext(ctx).Infof("Check filesystem %s of %s before mounting it to %s", fsType, sourcePath, targetPath)
+	return checkFilesystem(ctx, sourcePath, fsType)
+}
+
 func getDiskSizeType(ctx context.Context, sourcePath string) (string, error) {
 	size, err := connector.GetDeviceSize(ctx, sourcePath)
 	if err != nil {
@@ -357,8 +526,8 @@ func getDiskSizeType(ctx context.Context, sourcePath string) (string, error) {
 	return "", errors.New("the disk size does not support")
 }
 
-func mountDisk(ctx context.Context, sourcePath, targetPath, fsType string, flags mountParam,
-	accessMode csi.VolumeCapability_AccessMode_Mode) error {
+func mountDisk(ctx context.Context, conn *connectorInfo) error {
+	sourcePath, targetPath, fsType, flags := conn.sourcePath, conn.targetPath, conn.fsType, conn.mntFlags
 	var err error
 	existFsType, err := getFSType(ctx, sourcePath)
 	if err != nil {
@@ -383,7 +552,13 @@ func mountDisk(ctx context.Context, sourcePath, targetPath, fsType string, flags
 			return err
 		}
 
-		err = formatDisk(ctx, sourcePath, fsType, diskSizeType)
+		// Verify right before formatting, the device may be taken over by another LUN after a rescan
+		err = connector.VerifyDeviceWWN(ctx, sourcePath, conn.lunWWN)
+		if err != nil {
+			return err
+		}
+
+		err = formatDisk(ctx, sourcePath, fsType, diskSizeType, conn.mkfsOptions)
 		if err != nil {
 			return err
 		}
@@ -393,17 +568,29 @@ func mountDisk(ctx context.Context, sourcePath, targetPath, fsType string, flags
 			return err
 		}
 	} else {
+		err = connector.VerifyDeviceWWN(ctx, sourcePath, conn.lunWWN)
+		if err != nil {
+			return err
+		}
+
+		if conn.checkFs {
+			err = checkFsBeforeMount(ctx, sourcePath, targetPath, existFsType)
+			if err != nil {
+				return err
+			}
+		}
+
 		err = mountUnix(ctx, sourcePath, targetPath, flags, true)
 		if err != nil {
 			return err
 		}
 
-		if accessMode == csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER {
+		if conn.accessMode == csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER {
 			log.AddContext(ctx).Infoln("PVC accessMode is ReadWriteMany, not support to expend filesystem")
 			return nil
 		}
 
-		if accessMode == csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY {
+		if conn.accessMode == csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY {
 			log.AddContext(ctx).Infoln("PVC accessMode is ReadOnlyMany, no need to expend filesystem")
 			return nil
 		}
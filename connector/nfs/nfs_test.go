@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"os"
+	"os/exec"
 	"path"
 	"testing"
 
@@ -59,13 +60,28 @@ func TestConnectVolume(t *testing.T) {
 	var blockConnMap = map[string]interface{}{
 		"srcType":    "block",
 		"sourcePath": "test-sourcePath",
+		"lunWWN":     "test-wwn",
 		"targetPath": "test-targetPath",
 		"fsType":     "",
 		"mountFlags": "",
 	}
+	var wwnMismatchMap = map[string]interface{}{
+		"srcType":    "block",
+		"sourcePath": "test-sourcePath",
+		"lunWWN":     "other-wwn",
+		"targetPath": "test-targetPath",
+		"fsType":     "",
+		"mountFlags": "",
+	}
+	var emptyLunWWNMap = map[string]interface{}{
+		"srcType":    "block",
+		"sourcePath": "test-sourcePath",
+		"targetPath": "test-targetPath",
+	}
 	var existFsTypeIsEmptyMap = map[string]interface{}{
 		"srcType":    "block",
 		"sourcePath": "sourcePath",
+		"lunWWN":     "test-wwn",
 		"targetPath": "test-targetPath",
 		"fsType":     "",
 		"mountFlags": "",
@@ -112,6 +128,8 @@ func TestConnectVolume(t *testing.T) {
 
 		{"SrcTypeIsBlock", args{ctx, blockConnMap}, "", false},
 		{"ExistFsTypeIsEmpty", args{ctx, existFsTypeIsEmptyMap}, "", true},
+		{"LunWWNMismatch", args{ctx, wwnMismatchMap}, "", true},
+		{"EmptyLunWWN", args{ctx, emptyLunWWNMap}, "", true},
 	}
 
 	stubs := gostub.StubFunc(&connector.ReadDevice, []byte{}, nil)
@@ -123,6 +141,12 @@ func TestConnectVolume(t *testing.T) {
 	stubs.StubFunc(&connector.IsInFormatting, false, nil)
 	stubs.StubFunc(&connector.GetDeviceSize, int64(halfTiSizeBytes), nil)
 	stubs.Stub(&utils.ExecShellCmd, testExecShellCmd)
+	stubs.Stub(&connector.VerifyDeviceWWN, func(_ context.Context, devPath, lunWWN string) error {
+		if lunWWN != "test-wwn" {
+			return errors.New("device doesn't belong to the LUN")
+		}
+		return nil
+	})
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -178,6 +202,99 @@ func TestDisConnectVolume(t *testing.T) {
 	}
 }
 
+func TestBindDevice(t *testing.T) {
+	dir := t.TempDir()
+	regularFile := path.Join(dir, "regular")
+	if err := os.WriteFile(regularFile, nil, 0640); err != nil {
+		t.Fatalf("create regular file error: %v", err)
+	}
+	if err := os.Symlink(regularFile, path.Join(dir, "link")); err != nil {
+		t.Fatalf("create symlink error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		sourcePath string
+	}{
+		{"SourceNotExist", path.Join(dir, "notExist")},
+		{"NotBlockDevice", path.Join(dir, "link")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targetPath := path.Join(dir, "target", tt.name)
+			if err := bindDevice(context.TODO(), tt.sourcePath, targetPath, mountParam{}); err == nil {
+				t.Errorf("bindDevice() of %s expects error", tt.sourcePath)
+			}
+		})
+	}
+}
+
+func TestMountUnixReadOnlyBind(t *testing.T) {
+	dir := t.TempDir()
+	var commands []string
+	stubs := gostub.Stub(&utils.ExecShellCmd, func(_ context.Context, format string, _ ...interface{}) (string, error) {
+		commands = append(commands, format)
+		return "", nil
+	})
+	defer stubs.Reset()
+	stubs.StubFunc(&readFile, []byte{}, nil)
+
+	err := mountFS(context.TODO(), dir, path.Join(dir, "target"), mountParam{dashO: "bind,ro"})
+	if err != nil {
+		t.Fatalf("mountFS() error = %v", err)
+	}
+	if len(commands) != 2 || commands[1] != "mount -o remount,bind,ro %s" {
+		t.Errorf("mountFS() should remount the read-only bind mount, commands: %v", commands)
+	}
+}
+
+func TestCheckFilesystem(t *testing.T) {
+	exitErr := func(code string) error {
+		return exec.Command("sh", "-c", "exit "+code).Run()
+	}
+
+	tests := []struct {
+		name    string
+		fsType  string
+		output  string
+		err     error
+		wantErr bool
+	}{
+		{"Clean", "ext4", "", nil, false},
+		{"ExtCorrupted", "ext4", "Inode 12 has illegal blocks", exitErr("4"), true},
+		{"ExtDirtyJournal", "ext4", "Warning: skipping journal recovery", exitErr("4"), false},
+		{"XFSCorrupted", "xfs", "agi unlinked bucket 1 is 2 in ag 0", exitErr("1"), true},
+		{"XFSDirtyLog", "xfs", "The filesystem has valuable metadata changes in a log", exitErr("2"), false},
+		{"Unsupported", "btrfs", "", exitErr("1"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubs := gostub.StubFunc(&utils.ExecShellCmd, tt.output, tt.err)
+			defer stubs.Reset()
+
+			if err := checkFilesystem(context.TODO(), "/dev/sdb", tt.fsType); (err != nil) != tt.wantErr {
+				t.Errorf("checkFilesystem() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPreMountFile(t *testing.T) {
+	targetPath := path.Join(t.TempDir(), "publish", "volume")
+	for i := 0; i < 2; i++ {
+		if err := preMountFile(targetPath); err != nil {
+			t.Fatalf("preMountFile() error = %v", err)
+		}
+	}
+
+	info, err := os.Stat(targetPath)
+	if err != nil || !info.Mode().IsRegular() {
+		t.Errorf("preMountFile() should create the regular file %s, error: %v", targetPath, err)
+	}
+}
+
 func TestMain(m *testing.M) {
 	if err := log.InitLogging(logName); err != nil {
 		log.Errorf("init logging: %s failed. error: %v", logName, err)
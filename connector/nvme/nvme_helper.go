@@ -24,12 +24,16 @@ import (
 	"time"
 
 	"huawei-csi-driver/connector"
+	connutils "huawei-csi-driver/connector/utils"
 	"huawei-csi-driver/utils"
 	"huawei-csi-driver/utils/log"
 )
 
 var flushTimeInterval = 3 * time.Second
 
+// virtualDeviceTimeout is how long UltraPath is given to create the virtual device of the NVMe LUN
+const virtualDeviceTimeout = 5 * time.Second
+
 // PortWWNPair contains initiator wwn and target wwn
 type PortWWNPair struct {
 	InitiatorPortWWN string
@@ -65,6 +69,13 @@ func tryDisConnectVolume(ctx context.Context, tgtLunWWN string) error {
 		return err
 	}
 
+	if devType == connector.UseUltraPathNVMe {
+		err = connector.RemoveUltraPathNVMeVirtualDevice(ctx, virtualDevice, tgtLunWWN)
+		if err != nil {
+			return err
+		}
+	}
+
 	if multiPathName != "" {
 		time.Sleep(flushTimeInterval)
 		err = connector.FlushDMDevice(ctx, virtualDevice)
@@ -120,7 +131,9 @@ func getVirtualDevice(ctx context.Context, conn connectorInfo, channels []string
 func getVirtualDeviceUseMultipath(ctx context.Context, conn connectorInfo) (string, error) {
 	var virtualDevice string
 	var err error
-	for i := 0; i < 5; i++ {
+	// the uevents of the other devices wake the wait early, so the wait is bounded by time, not by attempts
+	deadline := time.Now().Add(virtualDeviceTimeout)
+	for time.Now().Before(deadline) {
 		virtualDevice, err = connector.GetDevNameByLunWWN(ctx, connector.UltraPathNVMeCommand, conn.tgtLunGUID)
 		if err != nil && virtualDevice != "" {
 			log.AddContext(ctx).Errorf("Get virtual device failed. error:%v", err)
@@ -130,16 +143,14 @@ func getVirtualDeviceUseMultipath(ctx context.Context, conn connectorInfo) (stri
 			abnormalDev, err := connector.IsUpNVMeResidualPath(ctx, virtualDevice, conn.tgtLunGUID)
 			if err != nil {
 				log.AddContext(ctx).Warningf("Verify fc-nvme device:%s failed. error:%v", virtualDevice, err)
-				continue
-			}
-			if abnormalDev {
+			} else if abnormalDev {
 				log.AddContext(ctx).Warningf("Verify fc-nvme device:%s failed.", virtualDevice)
-				continue
+			} else {
+				return virtualDevice, connector.WaitUltraPathNVMeDevice(ctx, virtualDevice)
 			}
-			return virtualDevice, nil
 		}
 
-		time.Sleep(time.Second)
+		connutils.WaitBlockDeviceEvent(ctx, time.Second)
 	}
 	log.AddContext(ctx).Warningln("Get virtual device failed.")
 	return virtualDevice, nil
@@ -101,6 +101,7 @@ func TestConnectVolume(t *testing.T) {
 	stubs.StubFunc(&connector.DoScanNVMeDevice, nil)
 	stubs.StubFunc(&connector.GetDevNameByLunWWN, "NVMeVirtualDevice", nil)
 	stubs.StubFunc(&connector.IsUpNVMeResidualPath, false, nil)
+	stubs.StubFunc(&connector.WaitUltraPathNVMeDevice, nil)
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
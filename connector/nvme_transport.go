@@ -0,0 +1,119 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"fmt"
+)
+
+const (
+	nvmeMinQueueSize = 16
+	nvmeMaxQueueSize = 1024
+	nvmeMaxIOQueues  = 1024
+
+	// NVMeTransportRDMA is the NVMe transport of RoCE
+	NVMeTransportRDMA = "rdma"
+	// NVMeTransportTCP is the NVMe transport of NVMe/TCP
+	NVMeTransportTCP = "tcp"
+)
+
+// NVMeTransportOptions are the parameters of the NVMe over fabrics connections to a backend,
+// a zero value leaves the parameter to the default of the kernel
+type NVMeTransportOptions struct {
+	QueueSize    int
+	NrIOQueues   int
+	HeaderDigest bool
+	DataDigest   bool
+}
+
+func getPositiveInt(config map[string]interface{}, key string) (int, error) {
+	value, exist := config[key]
+	if !exist {
+		return 0, nil
+	}
+
+	number, ok := value.(float64)
+	if !ok || number != float64(int(number)) || number < 1 {
+		return 0, fmt.Errorf("%s %v must be a positive integer", key, value)
+	}
+	return int(number), nil
+}
+
+// ParseNVMeTransportOptions parses and validates the nvmeTransport config of a backend for the transport
+func ParseNVMeTransportOptions(config map[string]interface{}, transport string) (*NVMeTransportOptions, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	var options NVMeTransportOptions
+	var err error
+	if options.QueueSize, err = getPositiveInt(config, "queueSize"); err != nil {
+		return nil, err
+	}
+	if options.QueueSize != 0 && (options.QueueSize < nvmeMinQueueSize || options.QueueSize > nvmeMaxQueueSize) {
+		return nil, fmt.Errorf("queueSize %d must range from %d to %d", options.QueueSize,
+			nvmeMinQueueSize, nvmeMaxQueueSize)
+	}
+
+	if options.NrIOQueues, err = getPositiveInt(config, "nrIOQueues"); err != nil {
+		return nil, err
+	}
+	if options.NrIOQueues > nvmeMaxIOQueues {
+		return nil, fmt.Errorf("nrIOQueues %d must not be greater than %d", options.NrIOQueues, nvmeMaxIOQueues)
+	}
+
+	for key, digest := range map[string]*bool{"headerDigest": &options.HeaderDigest,
+		"dataDigest": &options.DataDigest} {
+		value, exist := config[key]
+		if !exist {
+			continue
+		}
+
+		enabled, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s %v must be true or false", key, value)
+		}
+		if enabled && transport != NVMeTransportTCP {
+			return nil, fmt.Errorf("%s is only supported by NVMe/TCP, not by transport %s", key, transport)
+		}
+		*digest = enabled
+	}
+
+	return &options, nil
+}
+
+// FabricsArgs returns the arguments of the options written to /dev/nvme-fabrics to connect a controller
+func (o *NVMeTransportOptions) FabricsArgs() []string {
+	if o == nil {
+		return nil
+	}
+
+	var args []string
+	if o.QueueSize != 0 {
+		args = append(args, fmt.Sprintf("queue_size=%d", o.QueueSize))
+	}
+	if o.NrIOQueues != 0 {
+		args = append(args, fmt.Sprintf("nr_io_queues=%d", o.NrIOQueues))
+	}
+	if o.HeaderDigest {
+		args = append(args, "hdr_digest")
+	}
+	if o.DataDigest {
+		args = append(args, "data_digest")
+	}
+	return args
+}
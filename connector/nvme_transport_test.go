@@ -0,0 +1,55 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package connector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNVMeTransportOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    map[string]interface{}
+		transport string
+		args      []string
+		wantErr   bool
+	}{
+		{"Default", map[string]interface{}{}, NVMeTransportRDMA, nil, false},
+		{"Queues", map[string]interface{}{"queueSize": float64(256), "nrIOQueues": float64(8)},
+			NVMeTransportRDMA, []string{"queue_size=256", "nr_io_queues=8"}, false},
+		{"Digests", map[string]interface{}{"headerDigest": true, "dataDigest": true},
+			NVMeTransportTCP, []string{"hdr_digest", "data_digest"}, false},
+		{"DigestsOverRDMA", map[string]interface{}{"dataDigest": true}, NVMeTransportRDMA, nil, true},
+		{"QueueSizeTooSmall", map[string]interface{}{"queueSize": float64(8)}, NVMeTransportRDMA, nil, true},
+		{"NotInteger", map[string]interface{}{"nrIOQueues": 1.5}, NVMeTransportRDMA, nil, true},
+		{"NotBool", map[string]interface{}{"headerDigest": "yes"}, NVMeTransportTCP, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := ParseNVMeTransportOptions(tt.config, tt.transport)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.args, options.FabricsArgs())
+		})
+	}
+}
@@ -81,8 +81,8 @@ func disconnectSessions(ctx context.Context, sessionPorts []string) error {
 }
 
 func disconnectRoCEController(ctx context.Context, devPath string) {
-	output, err := utils.ExecShellCmd(ctx, "nvme disconnect -d %s", devPath)
-	if err != nil || output != "" {
+	err := connector.DisconnectNVMeController(ctx, devPath)
+	if err != nil {
 		log.AddContext(ctx).Warningf("Disconnect controller %s error %v", devPath, err)
 	}
 }
@@ -28,6 +28,7 @@ import (
 
 	"huawei-csi-driver/connector"
 	connutils "huawei-csi-driver/connector/utils"
+	"huawei-csi-driver/proto"
 	"huawei-csi-driver/utils"
 	"huawei-csi-driver/utils/log"
 )
@@ -37,6 +38,10 @@ type connectorInfo struct {
 	tgtLunGUID         string
 	volumeUseMultiPath bool
 	multiPathType      string
+	// storageInterfaces are the NICs which carry the NVMe traffic, empty means any
+	storageInterfaces []string
+	// transportOptions are the parameters of the connections configured for the backend
+	transportOptions *connector.NVMeTransportOptions
 }
 
 type shareData struct {
@@ -62,7 +67,9 @@ func parseRoCEInfo(ctx context.Context, connectionProperties map[string]interfac
 	}
 
 	var availablePortals []string
-	for _, portal := range tgtPortals {
+	for _, tgtPortal := range tgtPortals {
+		// the traddr of /dev/nvme-fabrics expects the IPv6 addresses without brackets
+		portal := connector.NormalizeIP(tgtPortal)
 		_, err = utils.ExecShellCmd(ctx, connector.PingCommand, portal)
 		if err != nil {
 			log.AddContext(ctx).Errorf("failed to check the host connectivity. %s", portal)
@@ -81,70 +88,108 @@ func parseRoCEInfo(ctx context.Context, connectionProperties map[string]interfac
 		return con, utils.Errorln(ctx, "key tgtLunGuid does not exist in connectionProperties")
 	}
 
+	con.storageInterfaces, _ = connectionProperties["storageInterfaces"].([]string)
+	con.transportOptions, _ = connectionProperties["nvmeTransport"].(*connector.NVMeTransportOptions)
 	con.volumeUseMultiPath, con.multiPathType, err = connutils.GetMultiPathInfo(connectionProperties)
 
 	return con, err
 }
 
 func getTargetNQN(ctx context.Context, tgtPortal string) (string, error) {
-	output, err := utils.ExecShellCmdFilterLog(ctx, "nvme discover -t rdma -a %s", tgtPortal)
+	subsystems, err := connector.DiscoverNVMeSubsystems(ctx, connector.NVMeFabricsTarget{
+		Transport: connector.NVMeTransportRDMA,
+		Address:   tgtPortal,
+	})
 	if err != nil {
-		log.AddContext(ctx).Errorf("Cannot discover nvme target %s, reason: %v", tgtPortal, output)
+		log.AddContext(ctx).Errorf("Cannot discover nvme target %s, reason: %v", tgtPortal, err)
 		return "", err
 	}
 
-	var tgtNqn string
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "subnqn") {
-			splits := strings.SplitN(line, ":", 2)
-			if len(splits) == 2 && splits[0] == "subnqn" {
-				tgtNqn = strings.Trim(splits[1], " ")
-				break
-			}
+	for _, subsystem := range subsystems {
+		if subsystem.NQN != "" {
+			return subsystem.NQN, nil
 		}
 	}
-
-	if tgtNqn == "" {
-		return "", errors.New("cannot find nvme target NQN")
-	}
-	return tgtNqn, nil
+	return "", errors.New("cannot find nvme target NQN")
 }
 
 func connectRoCEPortal(ctx context.Context,
 	existSessions map[string]bool,
-	tgtPortal, targetNQN string) error {
+	tgtPortal, targetNQN string, storageInterfaces []string,
+	transportOptions *connector.NVMeTransportOptions) error {
 	if value, exist := existSessions[tgtPortal]; exist && value {
 		log.AddContext(ctx).Infof("RoCE target %s has already login, no need login again", tgtPortal)
 		return nil
 	}
 
-	checkExitCode := []string{"exit status 0", "exit status 70"}
-	iSCSICmd := fmt.Sprintf("nvme connect -t rdma -a %s -n %s", tgtPortal, targetNQN)
-	output, err := utils.ExecShellCmdFilterLog(ctx, iSCSICmd)
-	if strings.Contains(output, "Input/output error") {
-		log.AddContext(ctx).Infof("RoCE target %s has already login, no need login again", tgtPortal)
-		return nil
+	// An empty host address lets the kernel route the connection
+	hostAddrs := []string{""}
+	if len(storageInterfaces) != 0 {
+		hostAddrs = connector.GetInterfaceIPs(ctx, storageInterfaces, tgtPortal)
+		if len(hostAddrs) == 0 {
+			return utils.Errorf(ctx, "none of storage NICs %v has an address to connect RoCE target %s",
+				storageInterfaces, tgtPortal)
+		}
 	}
 
+	// The node may have several host NQNs, e.g. one per RoCE NIC, the first one is the default
+	hostNQNs := []string{""}
+	initiators, err := proto.GetRoCEInitiators(ctx)
 	if err != nil {
-		if err.Error() == "timeout" {
-			return err
+		log.AddContext(ctx).Warningf("Get host NQNs error, only log in with the default one: %v", err)
+	} else if len(initiators) > 1 {
+		hostNQNs = append(hostNQNs, initiators[1:]...)
+	}
+
+	var connected bool
+	var connectErr error
+	for _, hostAddr := range hostAddrs {
+		for _, hostNQN := range hostNQNs {
+			err := runNVMeConnect(ctx, tgtPortal, targetNQN, hostNQN, hostAddr, transportOptions)
+			if err != nil {
+				log.AddContext(ctx).Warningf("Login RoCE target %s with host NQN %s from address %s error: %v",
+					tgtPortal, hostNQN, hostAddr, err)
+				connectErr = err
+				continue
+			}
+			connected = true
 		}
+	}
 
-		err2 := utils.IgnoreExistCode(err, checkExitCode)
-		if err2 != nil {
-			log.AddContext(ctx).Warningf("Run %s: output=%s, err=%v", utils.MaskSensitiveInfo(iSCSICmd),
-				utils.MaskSensitiveInfo(output), err2)
-			return err
-		}
+	if !connected {
+		return connectErr
+	}
+	return nil
+}
+
+// runNVMeConnect connects the target with the host NQN from the host address and the transport options,
+// the default host NQN is used if it is empty, and the kernel chooses the host address if it is empty
+func runNVMeConnect(ctx context.Context, tgtPortal, targetNQN, hostNQN, hostAddr string,
+	transportOptions *connector.NVMeTransportOptions) error {
+	controller, err := connector.ConnectNVMeFabrics(ctx, connector.NVMeFabricsTarget{
+		Transport:   connector.NVMeTransportRDMA,
+		Address:     tgtPortal,
+		NQN:         targetNQN,
+		HostNQN:     hostNQN,
+		HostAddress: hostAddr,
+	}, transportOptions)
+	if connector.IsNVMeAlreadyConnected(err) {
+		log.AddContext(ctx).Infof("RoCE target %s has already login, no need login again", tgtPortal)
+		return nil
+	}
+	if err != nil {
+		return err
 	}
+
+	log.AddContext(ctx).Infof("Login RoCE target %s by controller %s", tgtPortal, controller)
 	return nil
 }
 
 func connectVol(ctx context.Context,
 	existSessions map[string]bool,
 	tgtPortal, tgtLunGUID string,
+	storageInterfaces []string,
+	transportOptions *connector.NVMeTransportOptions,
 	nvmeShareData *shareData) {
 	log.AddContext(ctx).Infof("Enter function:connectVol, portal:%s, LunGUID:%s", tgtPortal, tgtLunGUID)
 	targetNQN, err := getTargetNQN(ctx, tgtPortal)
@@ -155,7 +200,7 @@ func connectVol(ctx context.Context,
 		return
 	}
 
-	err = connectRoCEPortal(ctx, existSessions, tgtPortal, targetNQN)
+	err = connectRoCEPortal(ctx, existSessions, tgtPortal, targetNQN, storageInterfaces, transportOptions)
 	if err != nil {
 		log.AddContext(ctx).Errorf("connect roce portal %s error, reason: %v", tgtPortal, err)
 		nvmeShareData.failedLogin += 1
@@ -199,11 +244,12 @@ func connectVol(ctx context.Context,
 
 func scanSingle(ctx context.Context, nvmeShareData *shareData) {
 	log.AddContext(ctx).Infoln("Enter function:scanSingle")
-	for i := 0; i < 15; i++ {
+	deadline := time.Now().Add(time.Second * intNumTwo * 15)
+	for time.Now().Before(deadline) {
 		if len(nvmeShareData.foundDevices) != 0 {
 			break
 		}
-		time.Sleep(time.Second * intNumTwo)
+		connutils.WaitBlockDeviceEvent(ctx, time.Second*intNumTwo)
 	}
 }
 
@@ -279,7 +325,8 @@ func tryConnectVolume(ctx context.Context, connMap map[string]interface{}) (stri
 				log.Flush()
 			}()
 
-			connectVol(ctx, existSessions, portal, lunGUID, nvmeShareData)
+			connectVol(ctx, existSessions, portal, lunGUID, conn.storageInterfaces, conn.transportOptions,
+				nvmeShareData)
 		}(tgtPortal, conn.tgtLunGUID)
 	}
 
@@ -323,7 +370,7 @@ func scanUpNVMeMultiPath(ctx context.Context, conn connectorInfo, nvmeShareData
 		if err != nil {
 			log.AddContext(ctx).Warningf("get disk name by wwn failed. error:%v", err)
 		}
-		time.Sleep(time.Second)
+		connutils.WaitBlockDeviceEvent(ctx, time.Second)
 	}
 
 	return device
@@ -369,6 +416,11 @@ func verifyDevice(ctx context.Context,
 			return "", utils.Errorf(ctx, "Verify multipath device:%s failed.", mPath)
 		}
 
+		err = connector.WaitUltraPathNVMeDevice(ctx, mPath)
+		if err != nil {
+			return "", err
+		}
+
 		return fmt.Sprintf("/dev/%s", mPath), nil
 	}
 
@@ -393,7 +445,8 @@ func getSubSysPaths(ctx context.Context,
 			continue
 		}
 
-		if strings.Contains(subSystem["NQN"].(string), targetNqn) {
+		nqn, ok := subSystem["NQN"].(string)
+		if ok && strings.Contains(nqn, targetNqn) {
 			allSubPaths, ok = subSystem["Paths"].([]interface{})
 			if !ok {
 				continue
@@ -438,7 +491,8 @@ func getSubPathInfo(ctx context.Context, p interface{}) (string, string) {
 		}
 
 		if splitPortal[0] == "traddr" {
-			return splitPortal[1], path["Name"].(string)
+			name, _ := path["Name"].(string)
+			return connector.NormalizeIP(splitPortal[1]), name
 		}
 	}
 
@@ -510,6 +564,13 @@ func tryDisConnectVolume(ctx context.Context, tgtLunWWN string) error {
 		return err
 	}
 
+	if devType == connector.UseUltraPathNVMe {
+		err = connector.RemoveUltraPathNVMeVirtualDevice(ctx, virtualDevice, tgtLunWWN)
+		if err != nil {
+			return err
+		}
+	}
+
 	if multiPathName != "" {
 		time.Sleep(time.Second * intNumThree)
 		err = connector.FlushDMDevice(ctx, virtualDevice)
@@ -0,0 +1,205 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	// kernelUeventGroup is the netlink multicast group the kernel broadcasts uevents to
+	kernelUeventGroup = 1
+	ueventBufferSize  = 64 * 1024
+	blockSubsystem    = "block"
+)
+
+// Uevent is a kernel object event received from the netlink socket
+type Uevent struct {
+	Action    string
+	DevPath   string
+	Subsystem string
+	DevName   string
+	Env       map[string]string
+}
+
+type ueventMonitor struct {
+	mutex   sync.Mutex
+	started bool
+	enabled bool
+	waiters []chan struct{}
+}
+
+var blockMonitor = &ueventMonitor{}
+
+// ParseUevent parses a raw kernel uevent message like "add@/devices/...\x00ACTION=add\x00SUBSYSTEM=block\x00..."
+func ParseUevent(msg []byte) (*Uevent, bool) {
+	fields := strings.Split(string(msg), "\x00")
+	if len(fields) < 2 || !strings.Contains(fields[0], "@") {
+		return nil, false
+	}
+
+	header := strings.SplitN(fields[0], "@", 2)
+	event := &Uevent{
+		Action:  header[0],
+		DevPath: header[1],
+		Env:     make(map[string]string),
+	}
+
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		event.Env[kv[0]] = kv[1]
+	}
+
+	event.Subsystem = event.Env["SUBSYSTEM"]
+	event.DevName = event.Env["DEVNAME"]
+	return event, true
+}
+
+func (m *ueventMonitor) start() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.started {
+		return
+	}
+	m.started = true
+
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		log.Warningf("Create uevent netlink socket error: %v, fall back to polling for device discovery", err)
+		return
+	}
+
+	err = unix.Bind(fd, &unix.SockaddrNetlink{
+		Family: unix.AF_NETLINK,
+		Groups: kernelUeventGroup,
+	})
+	if err != nil {
+		log.Warningf("Bind uevent netlink socket error: %v, fall back to polling for device discovery", err)
+		unix.Close(fd)
+		return
+	}
+
+	m.enabled = true
+	log.Infoln("Subscribed to kernel block device uevents")
+	go m.receive(fd)
+}
+
+func (m *ueventMonitor) receive(fd int) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("Runtime error caught in uevent monitor: %v", r)
+		}
+
+		unix.Close(fd)
+		m.mutex.Lock()
+		m.enabled = false
+		m.notify()
+		m.mutex.Unlock()
+	}()
+
+	buf := make([]byte, ueventBufferSize)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err == unix.EINTR || err == unix.ENOBUFS {
+			continue
+		}
+		if err != nil {
+			log.Warningf("Receive uevent error: %v, fall back to polling for device discovery", err)
+			return
+		}
+
+		event, ok := ParseUevent(buf[:n])
+		if !ok || event.Subsystem != blockSubsystem {
+			continue
+		}
+
+		log.Debugf("Receive block uevent %s of %s", event.Action, event.DevPath)
+		m.mutex.Lock()
+		m.notify()
+		m.mutex.Unlock()
+	}
+}
+
+// notify wakes all registered waiters, the mutex must be held by the caller
+func (m *ueventMonitor) notify() {
+	for _, waiter := range m.waiters {
+		close(waiter)
+	}
+	m.waiters = nil
+}
+
+func (m *ueventMonitor) register() (chan struct{}, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !m.enabled {
+		return nil, false
+	}
+
+	waiter := make(chan struct{})
+	m.waiters = append(m.waiters, waiter)
+	return waiter, true
+}
+
+func (m *ueventMonitor) unregister(waiter chan struct{}) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for i, w := range m.waiters {
+		if w == waiter {
+			m.waiters = append(m.waiters[:i], m.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// WaitBlockDeviceEvent blocks until a block device uevent is received, the fallback poll interval
+// expires or the context is done. It returns true only when woken up by a uevent. If the netlink
+// socket is unavailable, it degrades to a plain sleep of the poll interval.
+func WaitBlockDeviceEvent(ctx context.Context, pollInterval time.Duration) bool {
+	blockMonitor.start()
+
+	timer := time.NewTimer(pollInterval)
+	defer timer.Stop()
+
+	waiter, ok := blockMonitor.register()
+	if !ok {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		return false
+	}
+
+	select {
+	case <-waiter:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	blockMonitor.unregister(waiter)
+	return false
+}
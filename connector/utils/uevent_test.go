@@ -0,0 +1,44 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUevent(t *testing.T) {
+	msg := []byte("add@/devices/virtual/block/dm-3\x00ACTION=add\x00DEVPATH=/devices/virtual/block/dm-3\x00" +
+		"SUBSYSTEM=block\x00DEVNAME=dm-3\x00SEQNUM=4711\x00")
+
+	event, ok := ParseUevent(msg)
+	assert.True(t, ok)
+	assert.Equal(t, "add", event.Action)
+	assert.Equal(t, "/devices/virtual/block/dm-3", event.DevPath)
+	assert.Equal(t, "block", event.Subsystem)
+	assert.Equal(t, "dm-3", event.DevName)
+	assert.Equal(t, "4711", event.Env["SEQNUM"])
+}
+
+func TestParseUeventInvalid(t *testing.T) {
+	_, ok := ParseUevent([]byte("libudev\x00\xfe\xed"))
+	assert.False(t, ok)
+
+	_, ok = ParseUevent([]byte(""))
+	assert.False(t, ok)
+}
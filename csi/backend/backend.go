@@ -24,8 +24,10 @@ import (
 	"reflect"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"huawei-csi-driver/csi/backend/plugin"
 	fsUtils "huawei-csi-driver/storage/fusionstorage/utils"
@@ -39,12 +41,21 @@ const (
 	Topology = "topology"
 	// supported topology key in CSI plugin configuration
 	supportedTopologiesKey = "supportedTopologies"
+	// topologies of the pools in CSI plugin configuration, which override the supported topologies of the backend
+	poolTopologiesKey = "poolTopologies"
 )
 
 var (
 	mutex       sync.Mutex
 	csiBackends = make(map[string]*Backend)
 
+	// pendingBackends are the backends failed to be registered, which are registered again periodically
+	pendingBackends []pendingBackend
+	pendingMutex    sync.Mutex
+
+	timeoutsMutex   sync.RWMutex
+	currentTimeouts = Timeouts{InitBackend: 2 * time.Minute, UpdateCapabilities: 2 * time.Minute}
+
 	primaryFilterFuncs = [][]interface{}{
 		{"backend", filterByBackendName},
 		{"pool", filterByStoragePool},
@@ -54,10 +65,17 @@ var (
 		{"hyperMetro", filterByMetro},
 		{"replication", filterByReplication},
 		{"applicationType", filterByApplicationType},
+		{"cachePartition", filterBySmartCache},
+		{"dedup", filterByDedupe},
+		{"compression", filterByCompression},
+		{"smarttier", filterBySmartTier},
 		{"storageQuota", filterByStorageQuota},
 		{"sourceVolumeName", filterBySupportClone},
 		{"sourceSnapshotName", filterBySupportClone},
+		{"sourceBackend", filterBySourceArray},
 		{"nfsProtocol", filterByNFSProtocol},
+		{"shareProtocol", filterByShareProtocol},
+		{"workloadHint", filterByWorkloadHint},
 	}
 
 	secondaryFilterFuncs = [][]interface{}{
@@ -66,6 +84,10 @@ var (
 		{"qos", filterByQos},
 		{"replication", filterByReplication},
 		{"applicationType", filterByApplicationType},
+		{"cachePartition", filterBySmartCache},
+		{"dedup", filterByDedupe},
+		{"compression", filterByCompression},
+		{"smarttier", filterBySmartTier},
 	}
 )
 
@@ -81,6 +103,12 @@ type StoragePool struct {
 	Parent       string
 	Capabilities map[string]interface{}
 	Plugin       plugin.Plugin
+	// Reserve is the share of the pool kept free, nil if the pool can be filled up
+	Reserve *PoolReserve
+	// WorkloadHints are the workload hints the pool is meant for, empty if it is for any workload
+	WorkloadHints []string
+	// SupportedTopologies are the topologies the pool is accessible from, empty if it follows the backend
+	SupportedTopologies []map[string]string
 }
 
 type Backend struct {
@@ -110,7 +138,7 @@ type SelectPoolPair struct {
 func analyzePools(backend *Backend, config map[string]interface{}) error {
 	var pools []*StoragePool
 
-	if backend.Storage != "OceanStor-9000" {
+	if backend.Storage != "OceanStor-9000" && backend.Storage != "oceanstor-dtree" {
 		configPools, _ := config["pools"].([]interface{})
 		for _, i := range configPools {
 			name := i.(string)
@@ -118,12 +146,25 @@ func analyzePools(backend *Backend, config map[string]interface{}) error {
 				continue
 			}
 
+			reserve, err := getPoolReserve(config, name)
+			if err != nil {
+				return err
+			}
+
+			topologies, err := getPoolTopologies(config, name)
+			if err != nil {
+				return err
+			}
+
 			pool := &StoragePool{
-				Storage:      backend.Storage,
-				Name:         name,
-				Parent:       backend.Name,
-				Plugin:       backend.Plugin,
-				Capabilities: make(map[string]interface{}),
+				Storage:             backend.Storage,
+				Name:                name,
+				Parent:              backend.Name,
+				Plugin:              backend.Plugin,
+				Capabilities:        make(map[string]interface{}),
+				Reserve:             reserve,
+				WorkloadHints:       getPoolWorkloadHints(config, name),
+				SupportedTopologies: topologies,
 			}
 
 			pools = append(pools, pool)
@@ -132,6 +173,14 @@ func analyzePools(backend *Backend, config map[string]interface{}) error {
 		if len(pools) == 0 {
 			return fmt.Errorf("No valid pools configured for backend %s", backend.Name)
 		}
+
+		poolTopologies, _ := config[poolTopologiesKey].(map[string]interface{})
+		for name := range poolTopologies {
+			if !utils.IsContain(name, getPoolNames(pools)) {
+				return fmt.Errorf("topologies are configured for pool %s which is not in the pools of backend %s",
+					name, backend.Name)
+			}
+		}
 	} else {
 		pool := &StoragePool{
 			Storage:      backend.Storage,
@@ -198,13 +247,42 @@ func newBackend(backendName string, config map[string]interface{}) (*Backend, er
 }
 
 func getSupportedTopologies(config map[string]interface{}) ([]map[string]string, error) {
-	supportedTopologies := make([]map[string]string, 0)
-
 	topologies, exist := config[supportedTopologiesKey]
 	if !exist {
-		return supportedTopologies, nil
+		return make([]map[string]string, 0), nil
+	}
+
+	return parseTopologies(topologies)
+}
+
+// getPoolTopologies returns the topologies of the pool in the backend configuration, so that the pools of
+// one backend can be spread over zones. Nil is returned if the pool follows the topologies of the backend.
+func getPoolTopologies(config map[string]interface{}, poolName string) ([]map[string]string, error) {
+	poolTopologies, _ := config[poolTopologiesKey].(map[string]interface{})
+	topologies, exist := poolTopologies[poolName]
+	if !exist {
+		return nil, nil
 	}
 
+	supportedTopologies, err := parseTopologies(topologies)
+	if err != nil {
+		return nil, fmt.Errorf("topologies of pool %s are invalid: %v", poolName, err)
+	}
+
+	return supportedTopologies, nil
+}
+
+func getPoolNames(pools []*StoragePool) []string {
+	var names []string
+	for _, pool := range pools {
+		names = append(names, pool.Name)
+	}
+	return names
+}
+
+func parseTopologies(topologies interface{}) ([]map[string]string, error) {
+	supportedTopologies := make([]map[string]string, 0)
+
 	// populate configured topologies
 	topologyArray, ok := topologies.([]interface{})
 	if !ok {
@@ -236,32 +314,51 @@ func addProtocolTopology(backend *Backend, driverName string) error {
 			"in backend configuration")
 	}
 
-	protocolTopologyKey := k8sutils.ProtocolTopologyPrefix + protocol
+	protocols := []string{protocol}
+	fallbacks, _ := backend.Parameters["fallbackProtocols"].([]interface{})
+	for _, fallback := range fallbacks {
+		if fallbackProtocol, ok := fallback.(string); ok {
+			protocols = append(protocols, fallbackProtocol)
+		}
+	}
+
+	backend.SupportedTopologies = combineProtocolTopologies(backend.SupportedTopologies, protocols, driverName)
+	for _, pool := range backend.Pools {
+		if len(pool.SupportedTopologies) != 0 {
+			pool.SupportedTopologies = combineProtocolTopologies(pool.SupportedTopologies, protocols, driverName)
+		}
+	}
+
+	return nil
+}
 
-	// add combination of protocol support
-	if len(backend.SupportedTopologies) > 0 {
-		protocolTopologyCombination := make([]map[string]string, 0)
+// combineProtocolTopologies returns the supported topologies with their combinations with the protocols appended
+func combineProtocolTopologies(supportedTopologies []map[string]string, protocols []string,
+	driverName string) []map[string]string {
+	combinedTopologies := supportedTopologies
+	for _, protocol := range protocols {
+		protocolTopologyKey := k8sutils.ProtocolTopologyPrefix + protocol
 
-		for _, supportedTopology := range backend.SupportedTopologies {
+		// add combination of protocol support
+		for _, supportedTopology := range supportedTopologies {
 			copyofProtocolTopology := make(map[string]string, 0)
 			for key, value := range supportedTopology {
 				copyofProtocolTopology[key] = value
 			}
 			copyofProtocolTopology[protocolTopologyKey] = driverName
-			protocolTopologyCombination = append(protocolTopologyCombination, copyofProtocolTopology)
+			combinedTopologies = append(combinedTopologies, copyofProtocolTopology)
 		}
-		backend.SupportedTopologies = append(backend.SupportedTopologies, protocolTopologyCombination...)
-	}
 
-	// add support for protocol topology only
-	backend.SupportedTopologies = append(backend.SupportedTopologies, map[string]string{
-		protocolTopologyKey: driverName,
-	})
+		// add support for protocol topology only
+		combinedTopologies = append(combinedTopologies, map[string]string{
+			protocolTopologyKey: driverName,
+		})
+	}
 
-	return nil
+	return combinedTopologies
 }
 
-func analyzeBackend(config map[string]interface{}) (*Backend, error) {
+func analyzeBackend(config map[string]interface{}, analyzed map[string]bool) (*Backend, error) {
 	backendName, exist := config["name"].(string)
 	if !exist {
 		return nil, errors.New("Name must be configured for backend")
@@ -272,7 +369,7 @@ func analyzeBackend(config map[string]interface{}) (*Backend, error) {
 		return nil, fmt.Errorf("backend name %v is invalid, support upper&lower characters, numeric and [-_]", backendName)
 	}
 
-	if _, exist := csiBackends[backendName]; exist {
+	if _, exist := csiBackends[backendName]; exist || analyzed[backendName] {
 		return nil, fmt.Errorf("Backend name %s is duplicated", backendName)
 	}
 
@@ -286,6 +383,7 @@ func analyzeBackend(config map[string]interface{}) (*Backend, error) {
 		return nil, err
 	}
 
+	analyzed[backendName] = true
 	return backend, nil
 }
 
@@ -332,49 +430,205 @@ func updateReplicaBackends() {
 	}
 }
 
-func RegisterBackend(backendConfigs []map[string]interface{}, keepLogin bool, driverName string) error {
-	for _, i := range backendConfigs {
-		backend, err := analyzeBackend(i)
+// pendingBackend is the configuration of a backend failed to be registered
+type pendingBackend struct {
+	config     map[string]interface{}
+	keepLogin  bool
+	driverName string
+}
+
+// Timeouts are the timeouts of the backend operations, which can be changed at runtime by the driver config
+type Timeouts struct {
+	// InitBackend is the maximum time to log in to and initialize a single backend
+	InitBackend time.Duration
+	// UpdateCapabilities is the maximum time to update the capabilities of a single backend
+	UpdateCapabilities time.Duration
+}
+
+// SetTimeouts replaces the timeouts of the backend operations
+func SetTimeouts(timeouts Timeouts) {
+	timeoutsMutex.Lock()
+	defer timeoutsMutex.Unlock()
+	currentTimeouts = timeouts
+}
+
+func getTimeouts() Timeouts {
+	timeoutsMutex.RLock()
+	defer timeoutsMutex.RUnlock()
+	return currentTimeouts
+}
+
+// initBackend logs in to and initializes the backend within the InitBackend timeout. A plugin whose init
+// times out logs out once the init completes, so that its session is not left on storage.
+func initBackend(backend *Backend, config map[string]interface{}, keepLogin bool, driverName string) error {
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				result <- fmt.Errorf("runtime error caught while init backend %s: %v", backend.Name, r)
+			}
+		}()
+
+		result <- backend.Plugin.Init(config, backend.Parameters, keepLogin)
+	}()
+
+	timeout := getTimeouts().InitBackend
+	select {
+	case err := <-result:
 		if err != nil {
-			log.Errorf("Analyze backend error: %v", err)
-			return err
+			return fmt.Errorf("init backend plugin error: %v", err)
 		}
+	case <-time.After(timeout):
+		go func() {
+			if err := <-result; err == nil {
+				log.Infof("Log out of backend %s whose init is abandoned", backend.Name)
+				backend.Plugin.Logout(context.Background())
+			}
+		}()
+		return fmt.Errorf("init backend plugin timeout after %v", timeout)
+	}
+
+	// Note: Protocol is considered as special topological parameter.
+	// The protocol topology is populated internally by plugin using protocol name.
+	// If configured protocol for backend is "iscsi", CSI plugin internally add
+	// topology.kubernetes.io/protocol.iscsi = csi.huawei.com in supportedTopologies.
+	//
+	// Now users can opt to provision volumes based on protocol by
+	// 1. Labeling kubernetes nodes with protocol specific label (ie topology.kubernetes.io/protocol.iscsi = csi.huawei.com)
+	// 2. Configure topology support in plugin
+	// 3. Configure protocol topology in allowedTopologies fo Storage class
+	// addProtocolTopology is called after backend plugin init as init takes care of protocol validation
+	err := addProtocolTopology(backend, driverName)
+	if err != nil {
+		backend.Plugin.Logout(context.Background())
+		return fmt.Errorf("add protocol topology error: %v", err)
+	}
+
+	return nil
+}
 
-		err = backend.Plugin.Init(i, backend.Parameters, keepLogin)
+// RegisterBackend analyzes all configured backends, then logs in to and initializes them concurrently,
+// each bounded by the InitBackend timeout. A backend that cannot be initialized is skipped so that an
+// unreachable array does not block the others, and is registered again by RegisterPendingBackends;
+// an error is returned only if none can be registered.
+func RegisterBackend(backendConfigs []map[string]interface{}, keepLogin bool, driverName string) error {
+	analyzed := make(map[string]bool)
+	backends := make([]*Backend, len(backendConfigs))
+	for i, config := range backendConfigs {
+		backend, err := analyzeBackend(config, analyzed)
 		if err != nil {
-			log.Errorf("Init backend plugin error: %v", err)
+			log.Errorf("Analyze backend error: %v", err)
 			return err
 		}
+		backends[i] = backend
+	}
 
-		// Note: Protocol is considered as special topological parameter.
-		// The protocol topology is populated internally by plugin using protocol name.
-		// If configured protocol for backend is "iscsi", CSI plugin internally add
-		// topology.kubernetes.io/protocol.iscsi = csi.huawei.com in supportedTopologies.
-		//
-		// Now users can opt to provision volumes based on protocol by
-		// 1. Labeling kubernetes nodes with protocol specific label (ie topology.kubernetes.io/protocol.iscsi = csi.huawei.com)
-		// 2. Configure topology support in plugin
-		// 3. Configure protocol topology in allowedTopologies fo Storage class
-		// addProtocolTopology is called after backend plugin init as init takes care of protocol validation
-		err = addProtocolTopology(backend, driverName)
-		if err != nil {
-			log.Errorf("Add protocol topology error: %v", err)
-			return err
+	var wait sync.WaitGroup
+	initErrors := make([]error, len(backends))
+	for i, backend := range backends {
+		wait.Add(1)
+		go func(i int, b *Backend) {
+			defer wait.Done()
+			start := time.Now()
+			initErrors[i] = initBackend(b, backendConfigs[i], keepLogin, driverName)
+			log.Infof("Init backend %s cost %v, error: %v", b.Name, time.Since(start), initErrors[i])
+		}(i, backend)
+	}
+	wait.Wait()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	pendingMutex.Lock()
+	defer pendingMutex.Unlock()
+
+	var failedBackends []string
+	for i, backend := range backends {
+		if initErrors[i] != nil {
+			log.Errorf("Register backend %s error: %v", backend.Name, initErrors[i])
+			failedBackends = append(failedBackends, backend.Name)
+			pendingBackends = append(pendingBackends, pendingBackend{
+				config:     backendConfigs[i],
+				keepLogin:  keepLogin,
+				driverName: driverName,
+			})
+			continue
 		}
 
 		csiBackends[backend.Name] = backend
 	}
 
+	if len(backends) > 0 && len(failedBackends) == len(backends) {
+		return fmt.Errorf("none of the backends %v can be registered", failedBackends)
+	}
+
 	updateMetroBackends()
 	updateReplicaBackends()
 
 	return nil
 }
 
-func GetBackend(backendName string) *Backend {
+// RegisterPendingBackends registers the backends failed to be registered again, a backend still failing
+// is kept to be registered next time
+func RegisterPendingBackends() {
+	pendingMutex.Lock()
+	defer pendingMutex.Unlock()
+
+	var stillPending []pendingBackend
+	for _, pending := range pendingBackends {
+		backend, err := analyzeBackend(pending.config, make(map[string]bool))
+		if err != nil {
+			log.Errorf("Analyze pending backend error: %v", err)
+			continue
+		}
+
+		err = initBackend(backend, pending.config, pending.keepLogin, pending.driverName)
+		if err != nil {
+			log.Warningf("Register pending backend %s error: %v", backend.Name, err)
+			stillPending = append(stillPending, pending)
+			continue
+		}
+
+		addBackend(backend)
+		log.Infof("Pending backend %s is registered", backend.Name)
+	}
+	pendingBackends = stillPending
+}
+
+// addBackend adds the initialized backend to the registered ones. The map of the backends is replaced
+// rather than changed, as it is read without the lock.
+func addBackend(backend *Backend) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	backends := make(map[string]*Backend, len(csiBackends)+1)
+	for name, b := range csiBackends {
+		backends[name] = b
+	}
+	backends[backend.Name] = backend
+	csiBackends = backends
+
+	updateMetroBackends()
+	updateReplicaBackends()
+}
+
+// GetBackend returns the registered backend of the name, nil if it is not registered. It is a variable
+// so that the tests stub the registered backends.
+var GetBackend = func(backendName string) *Backend {
 	return csiBackends[backendName]
 }
 
+// GetBackendNames returns the sorted names of the registered backends
+var GetBackendNames = func() []string {
+	names := make([]string, 0, len(csiBackends))
+	for name := range csiBackends {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
 func GetMetroDomain(backendName string) string {
 	return csiBackends[backendName].MetroDomain
 }
@@ -428,7 +682,14 @@ func selectOnePool(ctx context.Context,
 	// filter the storage pool by capacity
 	filterPools = filterByCapacity(requestSize, allocType, filterPools)
 	if len(filterPools) == 0 {
-		return nil, fmt.Errorf("failed to select pool, the capacity filter failed, capacity: %d", requestSize)
+		return nil, fmt.Errorf("%w: failed to select pool, the capacity filter failed, capacity: %d",
+			utils.ErrResourceExhausted, requestSize)
+	}
+
+	// filter the storage pool by reserve
+	filterPools, err = filterByReserve(ctx, requestSize, allocType, filterPools)
+	if err != nil {
+		return nil, err
 	}
 
 	return filterPools, nil
@@ -589,6 +850,10 @@ func filterByVolumeType(ctx context.Context, volumeType string, candidatePools [
 			if pool.Storage == "oceanstor-nas" || pool.Storage == "oceanstor-9000" || pool.Storage == "fusionstorage-nas" {
 				filterPools = append(filterPools, pool)
 			}
+		} else if volumeType == "dtree" {
+			if pool.Storage == "oceanstor-dtree" {
+				filterPools = append(filterPools, pool)
+			}
 		}
 	}
 
@@ -727,8 +992,9 @@ func filterByTopology(parameters map[string]interface{},
 	return sortPoolsByPreferredTopologies(filterPools, topology.PreferredTopologies), nil
 }
 
-// isTopologySupportedByBackend returns whether the specific backend can create volumes accessible by the given topology
-func isTopologySupportedByBackend(backend *Backend, topology map[string]string) bool {
+// isTopologySupported returns whether volumes accessible by the given topology can be created with the supported
+// topologies of a pool
+func isTopologySupported(supportedTopologies []map[string]string, topology map[string]string) bool {
 	requisiteFound := false
 
 	// extract protocol
@@ -737,7 +1003,7 @@ func isTopologySupportedByBackend(backend *Backend, topology map[string]string)
 
 	// check for each topology key in backend supported topologies except protocol
 	// The check is an "and" operation on each topology key and value
-	for _, supported := range backend.SupportedTopologies {
+	for _, supported := range supportedTopologies {
 		eachFound := true
 
 		if len(protocolTopology) != 0 {
@@ -803,14 +1069,15 @@ func filterPoolsOnTopology(candidatePools []*StoragePool, requisiteTopologies []
 			continue
 		}
 
-		// when backend is not configured with supported topology
-		if len(backend.SupportedTopologies) == 0 {
+		// when neither the pool nor the backend is configured with supported topology
+		supportedTopologies := pool.getSupportedTopologies(backend)
+		if len(supportedTopologies) == 0 {
 			filteredPools = append(filteredPools, pool)
 			continue
 		}
 
 		for _, topology := range requisiteTopologies {
-			if isTopologySupportedByBackend(backend, topology) {
+			if isTopologySupported(supportedTopologies, topology) {
 				filteredPools = append(filteredPools, pool)
 				break
 			}
@@ -839,7 +1106,7 @@ func sortPoolsByPreferredTopologies(candidatePools []*StoragePool, preferredTopo
 			}
 			// If it supports topology, pop it and add to bucket. Otherwise, add it to newRemaining pools to be
 			// addressed in future loop iterations.
-			if isTopologySupportedByBackend(backend, preferred) {
+			if isTopologySupported(pool.getSupportedTopologies(backend), preferred) {
 				poolBucket = append(poolBucket, pool)
 			} else {
 				newRemainingPools = append(newRemainingPools, pool)
@@ -886,6 +1153,43 @@ func filterByCapability(
 	return candidatePools, nil
 }
 
+// getPoolWorkloadHints returns the workload hints of the pool in the backend configuration
+func getPoolWorkloadHints(config map[string]interface{}, poolName string) []string {
+	poolHints, _ := config["poolWorkloadHints"].(map[string]interface{})
+	configHints, _ := poolHints[poolName].([]interface{})
+
+	var hints []string
+	for _, hint := range configHints {
+		if value, ok := hint.(string); ok && value != "" {
+			hints = append(hints, value)
+		}
+	}
+	return hints
+}
+
+// filterByWorkloadHint selects the pools meant for the workload hint, or else the pools meant for
+// any workload
+func filterByWorkloadHint(ctx context.Context, workloadHint string, candidatePools []*StoragePool) (
+	[]*StoragePool, error) {
+	if workloadHint == "" {
+		return candidatePools, nil
+	}
+
+	var hintPools, anyPools []*StoragePool
+	for _, pool := range candidatePools {
+		if len(pool.WorkloadHints) == 0 {
+			anyPools = append(anyPools, pool)
+		} else if utils.IsContain(workloadHint, pool.WorkloadHints) {
+			hintPools = append(hintPools, pool)
+		}
+	}
+
+	if len(hintPools) > 0 {
+		return hintPools, nil
+	}
+	return anyPools, nil
+}
+
 func filterByNFSProtocol(ctx context.Context, nfsProtocol string, candidatePools []*StoragePool) ([]*StoragePool,
 	error) {
 	if nfsProtocol == "" {
@@ -894,18 +1198,50 @@ func filterByNFSProtocol(ctx context.Context, nfsProtocol string, candidatePools
 
 	var filterPools []*StoragePool
 	for _, pool := range candidatePools {
+		// the backends configured with an NFS version only serve that version
+		if backend, exist := csiBackends[pool.Parent]; exist {
+			version, _ := backend.Parameters["nfsVersion"].(string)
+			if protocol, _ := utils.GetNFSProtocol(version); version != "" && protocol != nfsProtocol {
+				continue
+			}
+		}
+
 		if nfsProtocol == "nfs3" && pool.Capabilities["SupportNFS3"].(bool) {
 			filterPools = append(filterPools, pool)
 		} else if nfsProtocol == "nfs4" && pool.Capabilities["SupportNFS4"].(bool) {
 			filterPools = append(filterPools, pool)
 		} else if nfsProtocol == "nfs41" && pool.Capabilities["SupportNFS41"].(bool) {
 			filterPools = append(filterPools, pool)
+		} else if nfsProtocol == "nfs42" && pool.Capabilities["SupportNFS42"] == true {
+			filterPools = append(filterPools, pool)
 		}
 	}
 
 	return filterPools, nil
 }
 
+// filterByShareProtocol keeps the pools of the backends exporting their filesystems by the share
+// protocol, the pools of the CIFS backends are only selected when the cifs share protocol is requested
+func filterByShareProtocol(ctx context.Context, shareProtocol string, candidatePools []*StoragePool) (
+	[]*StoragePool, error) {
+	if shareProtocol != "" && shareProtocol != "nfs" && shareProtocol != "cifs" {
+		return nil, fmt.Errorf("share protocol %s is not nfs or cifs", shareProtocol)
+	}
+
+	var filterPools []*StoragePool
+	for _, pool := range candidatePools {
+		var protocol string
+		if backend, exist := csiBackends[pool.Parent]; exist {
+			protocol, _ = backend.Parameters["protocol"].(string)
+		}
+
+		if (protocol == "cifs") == (shareProtocol == "cifs") {
+			filterPools = append(filterPools, pool)
+		}
+	}
+	return filterPools, nil
+}
+
 func filterBySupportClone(ctx context.Context, cloneSource string, candidatePools []*StoragePool) ([]*StoragePool,
 	error) {
 	if cloneSource == "" {
@@ -920,6 +1256,33 @@ func filterBySupportClone(ctx context.Context, cloneSource string, candidatePool
 	return filterPools, nil
 }
 
+// filterBySourceArray keeps the pools of the backends on the same array as the backend of the clone source
+func filterBySourceArray(ctx context.Context, sourceBackend string, candidatePools []*StoragePool) ([]*StoragePool,
+	error) {
+	if sourceBackend == "" {
+		return candidatePools, nil
+	}
+
+	source, exist := csiBackends[sourceBackend]
+	if !exist {
+		return nil, fmt.Errorf("source backend %s doesn't exist", sourceBackend)
+	}
+	sourceIdentifier, ok := source.Plugin.(plugin.ArrayIdentifier)
+	if !ok {
+		return nil, fmt.Errorf("backend %s of storage %s doesn't support cloning across backends",
+			sourceBackend, source.Storage)
+	}
+
+	var filterPools []*StoragePool
+	for _, pool := range candidatePools {
+		identifier, ok := pool.Plugin.(plugin.ArrayIdentifier)
+		if ok && identifier.GetArrayID() == sourceIdentifier.GetArrayID() {
+			filterPools = append(filterPools, pool)
+		}
+	}
+	return filterPools, nil
+}
+
 func filterByCapacity(requestSize int64, allocType string, candidatePools []*StoragePool) []*StoragePool {
 	var filterPools []*StoragePool
 	for _, pool := range candidatePools {
@@ -965,6 +1328,14 @@ func (pool *StoragePool) GetSupportedTopologies(ctx context.Context) []map[strin
 		return make([]map[string]string, 0)
 	}
 
+	return pool.getSupportedTopologies(backend)
+}
+
+// getSupportedTopologies returns the topologies configured for the pool, or those of its backend if none is
+func (pool *StoragePool) getSupportedTopologies(backend *Backend) []map[string]string {
+	if len(pool.SupportedTopologies) != 0 {
+		return pool.SupportedTopologies
+	}
 	return backend.SupportedTopologies
 }
 
@@ -984,6 +1355,73 @@ func filterByApplicationType(ctx context.Context, appType string, candidatePools
 	return filterPools, nil
 }
 
+func filterBySmartCache(ctx context.Context, cachePartition string, candidatePools []*StoragePool) (
+	[]*StoragePool, error) {
+	if cachePartition == "" {
+		return candidatePools, nil
+	}
+
+	var filterPools []*StoragePool
+	for _, pool := range candidatePools {
+		supportSmartCache, _ := pool.Capabilities["SupportSmartCache"].(bool)
+		if pool.Storage == "oceanstor-san" && supportSmartCache {
+			filterPools = append(filterPools, pool)
+		}
+	}
+	return filterPools, nil
+}
+
+// filterByDataReduction keeps the pools whose SmartDedupe or SmartCompression of the capability is licensed
+// for their volume type if it is enabled
+func filterByDataReduction(enabled, capability string, candidatePools []*StoragePool) []*StoragePool {
+	if enabled != "true" {
+		return candidatePools
+	}
+
+	var filterPools []*StoragePool
+	for _, pool := range candidatePools {
+		key := capability
+		if pool.Storage == "oceanstor-nas" {
+			key += "NAS"
+		}
+		if supported, _ := pool.Capabilities[key].(bool); supported {
+			filterPools = append(filterPools, pool)
+		}
+	}
+	return filterPools
+}
+
+func filterByDedupe(ctx context.Context, dedup string, candidatePools []*StoragePool) ([]*StoragePool, error) {
+	return filterByDataReduction(dedup, "SupportDedupe", candidatePools), nil
+}
+
+func filterByCompression(ctx context.Context, compression string, candidatePools []*StoragePool) (
+	[]*StoragePool, error) {
+	return filterByDataReduction(compression, "SupportCompression", candidatePools), nil
+}
+
+// filterBySmartTier keeps the pools of the hybrid arrays with SmartTier licensed if a policy is requested
+func filterBySmartTier(ctx context.Context, smartTier string, candidatePools []*StoragePool) (
+	[]*StoragePool, error) {
+	if smartTier == "" {
+		return candidatePools, nil
+	}
+
+	var filterPools []*StoragePool
+	for _, pool := range candidatePools {
+		supportSmartTier, _ := pool.Capabilities["SupportSmartTier"].(bool)
+		if pool.Storage == "oceanstor-san" && supportSmartTier {
+			filterPools = append(filterPools, pool)
+		}
+	}
+
+	if len(filterPools) == 0 {
+		return nil, fmt.Errorf("smarttier %s is only supported by the LUNs of hybrid arrays with SmartTier "+
+			"licensed, the all-flash arrays don't support it", smartTier)
+	}
+	return filterPools, nil
+}
+
 func filterByStorageQuota(ctx context.Context, storageQuota string, candidatePools []*StoragePool) ([]*StoragePool,
 	error) {
 	var filterPools []*StoragePool
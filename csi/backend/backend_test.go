@@ -25,6 +25,7 @@ import (
 
 	"github.com/prashantv/gostub"
 
+	"huawei-csi-driver/csi/backend/plugin"
 	"huawei-csi-driver/utils/log"
 )
 
@@ -176,6 +177,15 @@ func TestAddProtocolTopology(t *testing.T) {
 				{"topology.kubernetes.io/protocol.iscsi": "csi.huawei.com", "key1": "val1"},
 				{"topology.kubernetes.io/protocol.iscsi": "csi.huawei.com"},
 			}},
+		{"FallbackProtocols",
+			&Backend{Parameters: map[string]interface{}{"protocol": "fc",
+				"fallbackProtocols": []interface{}{"iscsi"}},
+				SupportedTopologies: []map[string]string{}},
+			"csi.huawei.com",
+			false,
+			[]map[string]string{{"topology.kubernetes.io/protocol.fc": "csi.huawei.com"},
+				{"topology.kubernetes.io/protocol.iscsi": "csi.huawei.com"},
+			}},
 	}
 
 	for _, tt := range tests {
@@ -189,6 +199,75 @@ func TestAddProtocolTopology(t *testing.T) {
 	}
 }
 
+func TestGetPoolTopologies(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    map[string]interface{}
+		expectErr bool
+		expect    []map[string]string
+	}{
+		{"Normal",
+			map[string]interface{}{"poolTopologies": map[string]interface{}{"pool1": []interface{}{
+				map[string]interface{}{"topology.kubernetes.io/zone": "az1"}}}},
+			false,
+			[]map[string]string{{"topology.kubernetes.io/zone": "az1"}}},
+		{"OtherPool",
+			map[string]interface{}{"poolTopologies": map[string]interface{}{"pool2": []interface{}{
+				map[string]interface{}{"topology.kubernetes.io/zone": "az2"}}}},
+			false,
+			nil},
+		{"TopoIsNotList",
+			map[string]interface{}{"poolTopologies": map[string]interface{}{"pool1": "az1"}},
+			true,
+			nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getPoolTopologies(tt.config, "pool1")
+			if (err != nil) != tt.expectErr || !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("test getPoolTopologies faild. got: %v, expect: %v, err: %v, expectErr: %v",
+					got, tt.expect, err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestFilterPoolsOnPoolTopology(t *testing.T) {
+	backend := &Backend{Name: "pacific", Storage: "fusionstorage-san",
+		Parameters: map[string]interface{}{"protocol": "iscsi"}}
+	err := analyzePools(backend, map[string]interface{}{
+		"pools": []interface{}{"pool1", "pool2", "pool3"},
+		"poolTopologies": map[string]interface{}{
+			"pool1": []interface{}{map[string]interface{}{"topology.kubernetes.io/zone": "az1"}},
+			"pool2": []interface{}{map[string]interface{}{"topology.kubernetes.io/zone": "az2"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("test analyzePools faild. err: %v", err)
+	}
+	if err = addProtocolTopology(backend, "csi.huawei.com"); err != nil {
+		t.Fatalf("test addProtocolTopology faild. err: %v", err)
+	}
+
+	stub := gostub.Stub(&csiBackends, map[string]*Backend{"pacific": backend})
+	defer stub.Reset()
+
+	got := filterPoolsOnTopology(backend.Pools, []map[string]string{{"topology.kubernetes.io/zone": "az1",
+		"topology.kubernetes.io/protocol.iscsi": "csi.huawei.com"}})
+	if len(got) != 1 || got[0].Name != "pool1" {
+		t.Errorf("test filterPoolsOnTopology faild. got: %v, expect: pool1", getPoolNames(got))
+	}
+
+	err = analyzePools(backend, map[string]interface{}{
+		"pools":          []interface{}{"pool1"},
+		"poolTopologies": map[string]interface{}{"pool2": []interface{}{}},
+	})
+	if err == nil {
+		t.Errorf("test analyzePools faild. expect error for topologies of unknown pool")
+	}
+}
+
 func TestFilterByBackendName(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -272,6 +351,10 @@ func TestFilterByVolumeType(t *testing.T) {
 			"fs",
 			[]*StoragePool{{Storage: "oceanstor-san"}, {Storage: "oceanstor-nas"}, {Storage: "oceanstor-9000"}},
 			[]*StoragePool{{Storage: "oceanstor-nas"}, {Storage: "oceanstor-9000"}}},
+		{"dtree",
+			"dtree",
+			[]*StoragePool{{Storage: "oceanstor-nas"}, {Storage: "oceanstor-dtree"}},
+			[]*StoragePool{{Storage: "oceanstor-dtree"}}},
 	}
 
 	for _, tt := range tests {
@@ -533,12 +616,30 @@ func TestFilterByNFSProtocol(t *testing.T) {
 				{Capabilities: map[string]interface{}{"SupportNFS41": true}},
 				{Capabilities: map[string]interface{}{"SupportNFS41": false}}},
 			1},
+		{"NFS42",
+			"nfs42",
+			[]*StoragePool{
+				{Capabilities: map[string]interface{}{"SupportNFS42": true}},
+				{Capabilities: map[string]interface{}{"SupportNFS41": true}}},
+			1},
+		{"BackendVersionMismatch",
+			"nfs3",
+			[]*StoragePool{
+				{Parent: "nfs41", Capabilities: map[string]interface{}{"SupportNFS3": true}},
+				{Parent: "nfs3", Capabilities: map[string]interface{}{"SupportNFS3": true}}},
+			1},
 		{"ProtocolEmpty",
 			"",
 			nil,
 			0},
 	}
 
+	stub := gostub.Stub(&csiBackends, map[string]*Backend{
+		"nfs41": {Name: "nfs41", Parameters: map[string]interface{}{"nfsVersion": "4.1"}},
+		"nfs3":  {Name: "nfs3", Parameters: map[string]interface{}{"nfsVersion": "3"}},
+	})
+	defer stub.Reset()
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			if got, _ := filterByNFSProtocol(ctx, tt.nfsProtocol, tt.candidatePools); int64(len(got)) != tt.expect {
@@ -730,6 +831,57 @@ func TestFilterByApplicationType(t *testing.T) {
 	}
 }
 
+func TestFilterByDedupe(t *testing.T) {
+	candidatePools := []*StoragePool{
+		{Name: "san", Storage: "oceanstor-san", Capabilities: map[string]interface{}{"SupportDedupe": true}},
+		{Name: "nas", Storage: "oceanstor-nas", Capabilities: map[string]interface{}{"SupportDedupe": true}},
+		{Name: "nasDedupe", Storage: "oceanstor-nas", Capabilities: map[string]interface{}{"SupportDedupeNAS": true}},
+		{Name: "none", Storage: "oceanstor-san", Capabilities: map[string]interface{}{}},
+	}
+
+	tests := []struct {
+		name   string
+		dedup  string
+		expect []string
+	}{
+		{"NotRequested", "", []string{"san", "nas", "nasDedupe", "none"}},
+		{"Disabled", "false", []string{"san", "nas", "nasDedupe", "none"}},
+		{"Enabled", "true", []string{"san", "nasDedupe"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := filterByDedupe(ctx, tt.dedup, candidatePools)
+			var names []string
+			for _, pool := range got {
+				names = append(names, pool.Name)
+			}
+			if !reflect.DeepEqual(names, tt.expect) {
+				t.Errorf("test filterByDedupe faild. got: %v expect: %v", names, tt.expect)
+			}
+		})
+	}
+}
+
+func TestFilterBySmartTier(t *testing.T) {
+	hybridPool := &StoragePool{Storage: "oceanstor-san", Capabilities: map[string]interface{}{"SupportSmartTier": true}}
+	allFlashPool := &StoragePool{Storage: "oceanstor-san", Capabilities: map[string]interface{}{"SupportSmartTier": false}}
+
+	got, err := filterBySmartTier(ctx, "", []*StoragePool{hybridPool, allFlashPool})
+	if err != nil || len(got) != 2 {
+		t.Errorf("test filterBySmartTier without policy faild. got: %v, err: %v", got, err)
+	}
+
+	got, err = filterBySmartTier(ctx, "automatic", []*StoragePool{hybridPool, allFlashPool})
+	if err != nil || !reflect.DeepEqual(got, []*StoragePool{hybridPool}) {
+		t.Errorf("test filterBySmartTier faild. got: %v, err: %v", got, err)
+	}
+
+	if _, err = filterBySmartTier(ctx, "automatic", []*StoragePool{allFlashPool}); err == nil {
+		t.Errorf("test filterBySmartTier on all-flash pools faild, expect an error")
+	}
+}
+
 func TestFilterByStorageQuota(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -786,6 +938,140 @@ func TestFilterByStorageQuota(t *testing.T) {
 	}
 }
 
+func TestFilterBySmartCache(t *testing.T) {
+	sanWithCache := &StoragePool{Name: "pool1", Storage: "oceanstor-san",
+		Capabilities: map[string]interface{}{"SupportSmartCache": true}}
+	sanWithoutCache := &StoragePool{Name: "pool2", Storage: "oceanstor-san",
+		Capabilities: map[string]interface{}{"SupportSmartCache": false}}
+	nasWithCache := &StoragePool{Name: "pool3", Storage: "oceanstor-nas",
+		Capabilities: map[string]interface{}{"SupportSmartCache": true}}
+	candidatePools := []*StoragePool{sanWithCache, sanWithoutCache, nasWithCache}
+
+	tests := []struct {
+		name           string
+		cachePartition string
+		expect         []*StoragePool
+	}{
+		{"noPartition", "", candidatePools},
+		{"withPartition", "cache01", []*StoragePool{sanWithCache}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := filterBySmartCache(ctx, tt.cachePartition, candidatePools)
+			if !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("test filterBySmartCache faild. got: %v, expect: %v", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestFilterByWorkloadHint(t *testing.T) {
+	ssdPool := &StoragePool{Name: "ssd", WorkloadHints: []string{"latency-sensitive"}}
+	nlPool := &StoragePool{Name: "nl", WorkloadHints: []string{"capacity"}}
+	anyPool := &StoragePool{Name: "any"}
+	pools := []*StoragePool{ssdPool, nlPool, anyPool}
+
+	tests := []struct {
+		name   string
+		hint   string
+		expect []*StoragePool
+	}{
+		{"NoHint", "", pools},
+		{"HintPool", "latency-sensitive", []*StoragePool{ssdPool}},
+		{"NoHintPool", "throughput", []*StoragePool{anyPool}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := filterByWorkloadHint(ctx, tt.hint, pools); !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("test filterByWorkloadHint faild. got: %v expect: %v", got, tt.expect)
+			}
+		})
+	}
+}
+
+type arrayPlugin struct {
+	plugin.Plugin
+	arrayID string
+}
+
+func (p *arrayPlugin) GetArrayID() string {
+	return p.arrayID
+}
+
+func TestFilterBySourceArray(t *testing.T) {
+	sourcePlugin := &arrayPlugin{arrayID: "sn1/"}
+	stub := gostub.Stub(&csiBackends, map[string]*Backend{
+		"source":  {Name: "source", Plugin: sourcePlugin},
+		"nas":     {Name: "nas", Storage: "oceanstor-nas"},
+		"another": {Name: "another", Plugin: &arrayPlugin{arrayID: "sn2/"}},
+	})
+	defer stub.Reset()
+
+	samePool := &StoragePool{Name: "pool1", Parent: "source", Plugin: sourcePlugin}
+	sameArrayPool := &StoragePool{Name: "pool2", Parent: "sameArray", Plugin: &arrayPlugin{arrayID: "sn1/"}}
+	otherArrayPool := &StoragePool{Name: "pool3", Parent: "another", Plugin: &arrayPlugin{arrayID: "sn2/"}}
+	nasPool := &StoragePool{Name: "pool4", Parent: "nas"}
+	pools := []*StoragePool{samePool, sameArrayPool, otherArrayPool, nasPool}
+
+	tests := []struct {
+		name          string
+		sourceBackend string
+		expectErr     bool
+		expect        []*StoragePool
+	}{
+		{"NotSpecified", "", false, pools},
+		{"SameArray", "source", false, []*StoragePool{samePool, sameArrayPool}},
+		{"NotSupported", "nas", true, nil},
+		{"NotExist", "none", true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filterBySourceArray(ctx, tt.sourceBackend, pools)
+			if (err != nil) != tt.expectErr || !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("test filterBySourceArray faild. got: %v, %v expect: %v", got, err, tt.expect)
+			}
+		})
+	}
+}
+
+func TestFilterByShareProtocol(t *testing.T) {
+	stub := gostub.Stub(&csiBackends, map[string]*Backend{
+		"nfs":  {Name: "nfs", Parameters: map[string]interface{}{"protocol": "nfs"}},
+		"cifs": {Name: "cifs", Parameters: map[string]interface{}{"protocol": "cifs"}},
+		"san":  {Name: "san", Parameters: map[string]interface{}{"protocol": "iscsi"}},
+	})
+	defer stub.Reset()
+
+	nfsPool := &StoragePool{Name: "pool1", Parent: "nfs"}
+	cifsPool := &StoragePool{Name: "pool2", Parent: "cifs"}
+	sanPool := &StoragePool{Name: "pool3", Parent: "san"}
+	pools := []*StoragePool{nfsPool, cifsPool, sanPool}
+
+	tests := []struct {
+		name          string
+		shareProtocol string
+		expectErr     bool
+		expect        []*StoragePool
+	}{
+		{"NotSpecified", "", false, []*StoragePool{nfsPool, sanPool}},
+		{"NFS", "nfs", false, []*StoragePool{nfsPool, sanPool}},
+		{"CIFS", "cifs", false, []*StoragePool{cifsPool}},
+		{"Invalid", "smb", true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filterByShareProtocol(ctx, tt.shareProtocol, pools)
+			if (err != nil) != tt.expectErr || !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("test filterByShareProtocol faild. got: %v, %v expect: %v", got, err, tt.expect)
+			}
+		})
+	}
+}
+
 func TestMain(m *testing.M) {
 	if err := log.InitLogging(logName); err != nil {
 		log.Errorf("Init logging: %s failed. error: %v", logName, err)
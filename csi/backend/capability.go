@@ -24,15 +24,28 @@ import (
 	"reflect"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
 
 	"huawei-csi-driver/utils/log"
 )
 
-func updateBackendCapabilities(backend *Backend, sync bool) error {
+// fetchedCapabilities is the capabilities of a backend and of its pools fetched from storage
+type fetchedCapabilities struct {
+	backend map[string]interface{}
+	pools   map[string]interface{}
+}
+
+// fetchBackendCapabilities queries the capabilities of the backend and its pools from storage, the pools
+// are not queried once the context is done
+func fetchBackendCapabilities(ctx context.Context, backend *Backend, sync bool) (*fetchedCapabilities, error) {
 	backendCapabilities, err := backend.Plugin.UpdateBackendCapabilities()
 	if err != nil {
 		log.Errorf("Cannot update backend %s capabilities: %v", backend.Name, err)
-		return err
+		return nil, err
+	}
+
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("update backend %s capabilities is abandoned: %v", backend.Name, ctx.Err())
 	}
 
 	var poolNames []string
@@ -43,17 +56,22 @@ func updateBackendCapabilities(backend *Backend, sync bool) error {
 	poolCapabilities, err := backend.Plugin.UpdatePoolCapabilities(poolNames)
 	if err != nil {
 		log.Errorf("Cannot update pool capabilities of backend %s: %v", backend.Name, err)
-		return err
+		return nil, err
 	}
 
 	if sync && len(poolCapabilities) < len(poolNames) {
 		msg := fmt.Sprintf("There're pools not available for backend %s", backend.Name)
 		log.Errorln(msg)
-		return errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
+	return &fetchedCapabilities{backend: backendCapabilities, pools: poolCapabilities}, nil
+}
+
+// applyBackendCapabilities sets the fetched capabilities to the pools of the backend
+func applyBackendCapabilities(backend *Backend, fetched *fetchedCapabilities) {
 	for _, pool := range backend.Pools {
-		for k, v := range backendCapabilities {
+		for k, v := range fetched.backend {
 			if cur, exist := pool.Capabilities[k]; !exist || cur != v {
 				log.Infof("Update backend capability [%s] of pool [%s] of backend [%s] from %v to %v",
 					k, pool.Name, pool.Parent, cur, v)
@@ -61,7 +79,7 @@ func updateBackendCapabilities(backend *Backend, sync bool) error {
 			}
 		}
 
-		capabilities, exist := poolCapabilities[pool.Name].(map[string]interface{})
+		capabilities, exist := fetched.pools[pool.Name].(map[string]interface{})
 		if exist {
 			for k, v := range capabilities {
 				if cur, exist := pool.Capabilities[k]; !exist || !reflect.DeepEqual(cur, v) {
@@ -76,18 +94,85 @@ func updateBackendCapabilities(backend *Backend, sync bool) error {
 			pool.Capabilities["FreeCapacity"] = 0
 		}
 	}
+}
 
+func updateBackendCapabilities(backend *Backend, sync bool) error {
+	fetched, err := fetchBackendCapabilities(context.Background(), backend, sync)
+	if err != nil {
+		return err
+	}
+
+	applyBackendCapabilities(backend, fetched)
 	return nil
 }
 
+// syncUpdateBackendCapabilities updates the capabilities of the backend within the UpdateCapabilities timeout.
+// The capabilities are fetched by a worker and set by the caller, so that a worker which times out never
+// changes the pools afterwards.
+func syncUpdateBackendCapabilities(backend *Backend) error {
+	timeout := getTimeouts().UpdateCapabilities
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	type fetchResult struct {
+		fetched *fetchedCapabilities
+		err     error
+	}
+	result := make(chan fetchResult, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Errorf("Runtime error caught in loop routine: %v", r)
+				log.Errorf("%s", debug.Stack())
+				result <- fetchResult{err: fmt.Errorf("update backend %s capabilities panic: %v", backend.Name, r)}
+			}
+		}()
+
+		fetched, err := fetchBackendCapabilities(ctx, backend, true)
+		result <- fetchResult{fetched: fetched, err: err}
+	}()
+
+	select {
+	case r := <-result:
+		if r.err != nil {
+			return r.err
+		}
+		applyBackendCapabilities(backend, r.fetched)
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("update backend %s capabilities timeout after %v", backend.Name, timeout)
+	}
+}
+
+// SyncUpdateCapabilities updates the capabilities of all backends concurrently. A backend that fails
+// or times out is set unavailable and left to the periodic update, an error is returned only if no
+// backend is available.
 func SyncUpdateCapabilities() error {
+	var wait sync.WaitGroup
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var failed int32
 	for _, backend := range csiBackends {
-		err := updateBackendCapabilities(backend, true)
-		if err != nil {
-			return err
-		}
+		wait.Add(1)
+		go func(b *Backend) {
+			defer wait.Done()
+			err := syncUpdateBackendCapabilities(b)
+			if err != nil {
+				log.Errorf("Update %s capabilities error: %v, set it unavailable", b.Name, err)
+				atomic.AddInt32(&failed, 1)
+				b.Available = false
+				return
+			}
+
+			b.Available = true
+		}(backend)
+	}
+	wait.Wait()
 
-		backend.Available = true
+	if len(csiBackends) > 0 && int(failed) == len(csiBackends) {
+		return errors.New("capabilities of all backends cannot be updated")
 	}
 
 	return nil
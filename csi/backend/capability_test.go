@@ -0,0 +1,125 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package backend
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prashantv/gostub"
+
+	"huawei-csi-driver/csi/backend/plugin"
+)
+
+// blockingPlugin is a plugin whose storage doesn't respond until it is released
+type blockingPlugin struct {
+	plugin.Plugin
+	release     chan struct{}
+	poolQueried int32
+	loggedOut   int32
+}
+
+func (p *blockingPlugin) Init(map[string]interface{}, map[string]interface{}, bool) error {
+	<-p.release
+	return nil
+}
+
+func (p *blockingPlugin) Logout(context.Context) {
+	atomic.AddInt32(&p.loggedOut, 1)
+}
+
+func (p *blockingPlugin) UpdateBackendCapabilities() (map[string]interface{}, error) {
+	<-p.release
+	return map[string]interface{}{"SupportThin": false}, nil
+}
+
+func (p *blockingPlugin) UpdatePoolCapabilities([]string) (map[string]interface{}, error) {
+	atomic.AddInt32(&p.poolQueried, 1)
+	return map[string]interface{}{"pool1": map[string]interface{}{"FreeCapacity": int64(0)}}, nil
+}
+
+func waitFor(condition func() bool) bool {
+	for i := 0; i < 100; i++ {
+		if condition() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestSyncUpdateBackendCapabilitiesTimeout(t *testing.T) {
+	stub := gostub.Stub(&currentTimeouts, Timeouts{InitBackend: time.Minute, UpdateCapabilities: 50 * time.Millisecond})
+	defer stub.Reset()
+
+	p := &blockingPlugin{release: make(chan struct{})}
+	pool := &StoragePool{Name: "pool1", Parent: "san1",
+		Capabilities: map[string]interface{}{"SupportThin": true, "FreeCapacity": int64(100)}}
+	backend := &Backend{Name: "san1", Plugin: p, Pools: []*StoragePool{pool}}
+
+	if err := syncUpdateBackendCapabilities(backend); err == nil {
+		t.Fatalf("test syncUpdateBackendCapabilities failed. expect timeout error")
+	}
+
+	// the worker finishing after the timeout neither queries the pools nor changes them
+	close(p.release)
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&p.poolQueried) != 0 {
+		t.Errorf("test syncUpdateBackendCapabilities failed. pools are queried after the timeout")
+	}
+	if pool.Capabilities["SupportThin"] != true || pool.Capabilities["FreeCapacity"] != int64(100) {
+		t.Errorf("test syncUpdateBackendCapabilities failed. capabilities changed after the timeout: %v",
+			pool.Capabilities)
+	}
+}
+
+func TestInitBackendTimeoutLogout(t *testing.T) {
+	stub := gostub.Stub(&currentTimeouts, Timeouts{InitBackend: 50 * time.Millisecond, UpdateCapabilities: time.Minute})
+	defer stub.Reset()
+
+	p := &blockingPlugin{release: make(chan struct{})}
+	backend := &Backend{Name: "san1", Plugin: p, Parameters: map[string]interface{}{"protocol": "iscsi"}}
+
+	if err := initBackend(backend, nil, true, "csi.huawei.com"); err == nil {
+		t.Fatalf("test initBackend failed. expect timeout error")
+	}
+
+	// the abandoned login is logged out once it completes
+	close(p.release)
+	if !waitFor(func() bool { return atomic.LoadInt32(&p.loggedOut) == 1 }) {
+		t.Errorf("test initBackend failed. the abandoned login is not logged out")
+	}
+}
+
+func TestRegisterPendingBackends(t *testing.T) {
+	stubs := gostub.Stub(&csiBackends, map[string]*Backend{})
+	defer stubs.Reset()
+	unreachable := map[string]interface{}{"name": "san1", "storage": "oceanstor-san",
+		"parameters": map[string]interface{}{"protocol": "iscsi"}, "pools": []interface{}{"pool1"}}
+	stubs.Stub(&pendingBackends, []pendingBackend{
+		{config: map[string]interface{}{"name": "san2"}, keepLogin: true, driverName: "csi.huawei.com"},
+		{config: unreachable, keepLogin: true, driverName: "csi.huawei.com"},
+	})
+
+	// a backend failed to init is retried next time, one whose configuration is invalid is dropped
+	RegisterPendingBackends()
+	if len(pendingBackends) != 1 || pendingBackends[0].config["name"] != "san1" || len(csiBackends) != 0 {
+		t.Errorf("test RegisterPendingBackends failed. pending: %v, registered: %v", pendingBackends, csiBackends)
+	}
+}
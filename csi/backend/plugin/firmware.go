@@ -0,0 +1,106 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package plugin
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// Features gated by the firmware of the OceanStor arrays
+const (
+	ClonePairFeature          = "clonePair"
+	HyperCDPFeature           = "hyperCDP"
+	ProtectionGroupFeature    = "protectionGroup"
+	HyperMetroOptionsFeature  = "hyperMetroOptions"
+	ReplicationOptionsFeature = "replicationOptions"
+	// VStoreSANFeature is the LUNs, hosts and mappings in vStores, the vStores of the OceanStor Dorado
+	// arrays before 6.1.0 only have filesystems, and the HyperMetro vStore pairs only pair their filesystems
+	VStoreSANFeature = "vStoreSAN"
+)
+
+// featureMinFirmware is the minimum firmware of the products supporting the features. A product
+// not listed supports the feature whatever its firmware is.
+var featureMinFirmware = map[string]map[string]string{
+	ClonePairFeature:          {utils.OceanStorDoradoV6: "6.1.0"},
+	HyperCDPFeature:           {utils.OceanStorDoradoV6: "6.1.0"},
+	ProtectionGroupFeature:    {utils.OceanStorDoradoV6: "6.1.0"},
+	HyperMetroOptionsFeature:  {utils.OceanStorDoradoV6: "6.1.0"},
+	ReplicationOptionsFeature: {utils.OceanStorDoradoV6: "6.1.0"},
+	VStoreSANFeature:          {utils.OceanStorDoradoV6: "6.1.0"},
+}
+
+// getFirmware returns the firmware version of the array such as 6.1.0, empty if the array does
+// not report it
+func getFirmware(system map[string]interface{}) string {
+	firmware, _ := system["pointRelease"].(string)
+	return firmware
+}
+
+// compareFirmware compares the numeric parts of the firmware versions, suffixes such as .SPH1
+// are ignored. It returns -1, 0 or 1 if a is older, the same or newer than b.
+func compareFirmware(a, b string) int {
+	partsA, partsB := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var numA, numB int
+		if i < len(partsA) {
+			numA, _ = strconv.Atoi(partsA[i])
+		}
+		if i < len(partsB) {
+			numB, _ = strconv.Atoi(partsB[i])
+		}
+
+		if numA < numB {
+			return -1
+		} else if numA > numB {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// checkFirmware returns an error if the firmware of the array is older than the feature requires.
+// Arrays not reporting their firmware are not checked.
+func checkFirmware(ctx context.Context, product, firmware, feature string) error {
+	minFirmware, exist := featureMinFirmware[feature][product]
+	if !exist || firmware == "" {
+		return nil
+	}
+
+	if compareFirmware(firmware, minFirmware) < 0 {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition, "%s requires %s, array is %s",
+			feature, minFirmware, firmware)
+	}
+
+	return nil
+}
+
+// logUnsupportedFeatures warns about the features the firmware of the array is too old for
+func logUnsupportedFeatures(product, firmware string) {
+	for feature, products := range featureMinFirmware {
+		minFirmware, exist := products[product]
+		if exist && firmware != "" && compareFirmware(firmware, minFirmware) < 0 {
+			log.Warningf("%s requires %s, array is %s, the operations using it will be refused",
+				feature, minFirmware, firmware)
+		}
+	}
+}
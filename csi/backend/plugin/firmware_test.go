@@ -0,0 +1,49 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/utils"
+)
+
+func TestCompareFirmware(t *testing.T) {
+	assert.Equal(t, -1, compareFirmware("6.0.1", "6.1.0"))
+	assert.Equal(t, 0, compareFirmware("6.1.0", "6.1.0"))
+	assert.Equal(t, 0, compareFirmware("6.1", "6.1.0"))
+	assert.Equal(t, 1, compareFirmware("6.1.2", "6.1.0"))
+	assert.Equal(t, 1, compareFirmware("6.10.0", "6.9.0"))
+}
+
+func TestCheckFirmware(t *testing.T) {
+	ctx := context.Background()
+	err := checkFirmware(ctx, utils.OceanStorDoradoV6, "6.0.1", ClonePairFeature)
+	assert.True(t, errors.Is(err, utils.ErrFailedPrecondition))
+	assert.Contains(t, err.Error(), "clonePair requires 6.1.0, array is 6.0.1")
+
+	assert.NoError(t, checkFirmware(ctx, utils.OceanStorDoradoV6, "6.1.2", ClonePairFeature))
+	assert.NoError(t, checkFirmware(ctx, utils.OceanStorDoradoV6, "", ClonePairFeature))
+	assert.NoError(t, checkFirmware(ctx, utils.OceanStorV5, "", ClonePairFeature))
+	assert.Error(t, checkFirmware(ctx, utils.OceanStorDoradoV6, "6.0.1", VStoreSANFeature))
+	assert.NoError(t, checkFirmware(ctx, utils.OceanStorDoradoV6, "6.1.0", VStoreSANFeature))
+	assert.NoError(t, checkFirmware(ctx, utils.OceanStorV5, "", VStoreSANFeature))
+}
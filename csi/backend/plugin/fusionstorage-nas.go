@@ -66,6 +66,15 @@ func (p *FusionStorageNasPlugin) Init(config, parameters map[string]interface{},
 	if err != nil {
 		return err
 	}
+
+	// the filesystems of the backend belong to its account, which is checked where the session is kept
+	if accountName, _ := config["accountName"].(string); accountName != "" && keepLogin {
+		_, err = p.cli.GetAccountIdByName(context.Background(), accountName)
+		if err != nil {
+			return fmt.Errorf("account %s of fusionstorage-nas backend is invalid: %v", accountName, err)
+		}
+	}
+
 	p.portal = portal
 	return nil
 }
@@ -161,10 +170,36 @@ func (p *FusionStorageNasPlugin) DeleteSnapshot(ctx context.Context,
 	return fmt.Errorf("unimplemented")
 }
 
+// ExpandVolume raises the quota of the filesystem, which its clients see as the capacity without
+// expanding anything on the node
 func (p *FusionStorageNasPlugin) ExpandVolume(ctx context.Context,
 	name string,
 	size int64) (bool, error) {
-	return false, fmt.Errorf("unimplemented")
+	// for fusionStorage filesystem, the unit is KiB
+	if !utils.IsCapacityAvailable(size, fileCapacityUnit) {
+		return false, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"Expand Volume: the capacity %d is not an integer multiple of %d.", size, fileCapacityUnit)
+	}
+
+	nas := volume.NewNAS(p.cli)
+	return false, nas.Expand(ctx, name, utils.RoundUpSize(size, fileCapacityUnit))
+}
+
+// GetQuotaUsage returns the hard quota of the filesystem and the capacity it uses in bytes
+func (p *FusionStorageNasPlugin) GetQuotaUsage(ctx context.Context, name string) (int64, int64, error) {
+	err := p.getNodeClient(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer p.releaseNodeClient(ctx)
+
+	nas := volume.NewNAS(p.cli)
+	return nas.GetQuotaUsage(ctx, name)
+}
+
+// AlwaysLimitedByQuota returns false, as only the filesystems setting hardQuota are limited by quotas
+func (p *FusionStorageNasPlugin) AlwaysLimitedByQuota() bool {
+	return false
 }
 
 func (p *FusionStorageNasPlugin) UpdatePoolCapabilities(poolNames []string) (map[string]interface{}, error) {
@@ -181,6 +216,7 @@ func (p *FusionStorageNasPlugin) updateNFS4Capability(capabilities map[string]in
 	capabilities["SupportNFS3"] = true
 	capabilities["SupportNFS4"] = false
 	capabilities["SupportNFS41"] = false
+	capabilities["SupportNFS42"] = false
 
 	if nfsServiceSetting["SupportNFS41"] {
 		capabilities["SupportNFS41"] = true
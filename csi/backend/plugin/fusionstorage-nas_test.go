@@ -0,0 +1,117 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"bou.ke/monkey"
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/storage/fusionstorage/client"
+	"huawei-csi-driver/utils"
+)
+
+// quotaUnlimited is the value storage reports for the unset limits of quotas
+const quotaUnlimited float64 = 18446744073709551615
+
+func TestFusionStorageExpandVolume(t *testing.T) {
+	cases := []struct {
+		name    string
+		size    int64
+		quota   map[string]interface{}
+		wantErr error
+		updated map[string]interface{}
+	}{
+		{"Hard quota", 2 * 1024 * 1024 * 1024,
+			map[string]interface{}{"id": "10", "space_unit_type": float64(1),
+				"space_hard_quota": float64(1024 * 1024), "space_soft_quota": quotaUnlimited},
+			nil, map[string]interface{}{"id": "10", "space_unit_type": 1, "space_hard_quota": int64(2 * 1024 * 1024)}},
+		{"Soft quota", 2 * 1024 * 1024 * 1024,
+			map[string]interface{}{"id": "10", "space_unit_type": float64(1),
+				"space_hard_quota": quotaUnlimited, "space_soft_quota": float64(1024 * 1024)},
+			nil, map[string]interface{}{"id": "10", "space_unit_type": 1, "space_soft_quota": int64(2 * 1024 * 1024)}},
+		{"Already expanded", 1024 * 1024 * 1024,
+			map[string]interface{}{"id": "10", "space_unit_type": float64(3), "space_hard_quota": float64(2)},
+			nil, nil},
+		{"Not aligned", 1000, nil, utils.ErrFailedPrecondition, nil},
+		{"No quota", 1024 * 1024 * 1024, nil, utils.ErrFailedPrecondition, nil},
+	}
+
+	cli := &client.Client{}
+	p := &FusionStorageNasPlugin{FusionStoragePlugin: FusionStoragePlugin{cli: cli}}
+	defer monkey.UnpatchAll()
+	monkey.PatchInstanceMethod(reflect.TypeOf(cli), "GetFileSystemByName",
+		func(*client.Client, context.Context, string) (map[string]interface{}, error) {
+			return map[string]interface{}{"id": float64(1)}, nil
+		})
+	for _, c := range cases {
+		var updated map[string]interface{}
+		monkey.PatchInstanceMethod(reflect.TypeOf(cli), "GetQuotaByFileSystem",
+			func(*client.Client, context.Context, string) (map[string]interface{}, error) {
+				return c.quota, nil
+			})
+		monkey.PatchInstanceMethod(reflect.TypeOf(cli), "UpdateQuota",
+			func(_ *client.Client, _ context.Context, params map[string]interface{}) error {
+				updated = params
+				return nil
+			})
+
+		nodeExpansion, err := p.ExpandVolume(context.Background(), "pvc-1", c.size)
+		if c.wantErr == nil {
+			assert.NoError(t, err, c.name)
+		} else {
+			assert.True(t, errors.Is(err, c.wantErr), c.name)
+		}
+		assert.False(t, nodeExpansion, c.name)
+		assert.Equal(t, c.updated, updated, c.name)
+	}
+}
+
+func TestFusionStorageGetQuotaUsage(t *testing.T) {
+	cli := &client.Client{}
+	p := &FusionStorageNasPlugin{FusionStoragePlugin: FusionStoragePlugin{cli: cli}}
+	defer monkey.UnpatchAll()
+	monkey.PatchInstanceMethod(reflect.TypeOf(cli), "GetFileSystemByName",
+		func(*client.Client, context.Context, string) (map[string]interface{}, error) {
+			return map[string]interface{}{"id": float64(1)}, nil
+		})
+	monkey.PatchInstanceMethod(reflect.TypeOf(cli), "GetQuotaByFileSystem",
+		func(*client.Client, context.Context, string) (map[string]interface{}, error) {
+			return map[string]interface{}{"id": "10", "space_unit_type": float64(1),
+				"space_hard_quota": float64(1024), "space_used": float64(256)}, nil
+		})
+	var sessions, logins int
+	monkey.PatchInstanceMethod(reflect.TypeOf(cli), "Login", func(*client.Client, context.Context) error {
+		sessions++
+		logins++
+		return nil
+	})
+	monkey.PatchInstanceMethod(reflect.TypeOf(cli), "Logout", func(*client.Client, context.Context) {
+		sessions--
+	})
+
+	limit, used, err := p.GetQuotaUsage(context.Background(), "pvc-1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024*1024), limit)
+	assert.Equal(t, int64(256*1024), used)
+	assert.Equal(t, 1, logins)
+	assert.Equal(t, 0, sessions)
+}
@@ -28,6 +28,7 @@ import (
 	"huawei-csi-driver/proto"
 	"huawei-csi-driver/storage/fusionstorage/attacher"
 	"huawei-csi-driver/storage/fusionstorage/client"
+	"huawei-csi-driver/storage/fusionstorage/smartx"
 	"huawei-csi-driver/storage/fusionstorage/volume"
 	"huawei-csi-driver/utils"
 	"huawei-csi-driver/utils/log"
@@ -39,6 +40,10 @@ type FusionStorageSanPlugin struct {
 	protocol string
 	portals  []string
 	alua     map[string]interface{}
+	// nvmeTransport are the parameters of the RoCE connections to the storage
+	nvmeTransport *connector.NVMeTransportOptions
+	// reclaimSpace discards the unused blocks of filesystems before they are unstaged
+	reclaimSpace bool
 
 	storageOnline bool
 	clientCount   int
@@ -90,13 +95,29 @@ func (p *FusionStorageSanPlugin) Init(config, parameters map[string]interface{},
 		p.portals = portals
 		p.protocol = "iscsi"
 		p.alua, _ = parameters["ALUA"].(map[string]interface{})
+	} else if strings.ToLower(protocol) == "roce" {
+		portals, err := proto.VerifyIscsiPortals(portals)
+		if err != nil {
+			return err
+		}
+
+		p.portals = portals
+		p.protocol = "roce"
 	} else {
-		msg := fmt.Sprintf("protocol %s configured is error. Just support iscsi and scsi", protocol)
+		msg := fmt.Sprintf("protocol %s configured is error. Just support iscsi, scsi and roce", protocol)
 		log.Errorln(msg)
 		return errors.New(msg)
 	}
 
-	err := p.init(config, keepLogin)
+	var err error
+	p.nvmeTransport, err = getNVMeTransportOptions(parameters, []string{p.protocol})
+	if err != nil {
+		return err
+	}
+
+	p.reclaimSpace, _ = parameters[reclaimSpaceKey].(bool)
+
+	err = p.init(config, keepLogin)
 	if err != nil {
 		return err
 	}
@@ -171,6 +192,35 @@ func (p *FusionStorageSanPlugin) ExpandVolume(ctx context.Context, name string,
 	return isAttach, err
 }
 
+// UpdateQoS sets the QoS of the volume, only the MAXBANDWIDTH and MAXIOPS limits are supported by FusionStorage
+func (p *FusionStorageSanPlugin) UpdateQoS(ctx context.Context, name, qosConfig string) error {
+	qos, err := smartx.VerifyQos(ctx, qosConfig)
+	if err != nil {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition, "Verify qos %s error: %v", qosConfig, err)
+	}
+
+	san := volume.NewSAN(p.cli)
+	return san.UpdateQoS(ctx, name, qos)
+}
+
+// QueryVolumeState returns the state of the volume on storage, the QoS of volumes is not reported
+func (p *FusionStorageSanPlugin) QueryVolumeState(ctx context.Context, name string) (*VolumeState, error) {
+	vol, err := p.cli.GetVolumeByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if vol == nil {
+		return &VolumeState{}, nil
+	}
+
+	volSize, err := utils.GetInt64Field(vol, "volSize")
+	if err != nil {
+		return nil, err
+	}
+
+	return &VolumeState{Exist: true, Capacity: volSize * CAPACITY_UNIT}, nil
+}
+
 func (p *FusionStorageSanPlugin) DetachVolume(ctx context.Context,
 	name string,
 	parameters map[string]interface{}) error {
@@ -184,6 +234,21 @@ func (p *FusionStorageSanPlugin) DetachVolume(ctx context.Context,
 	return nil
 }
 
+// GetOtherMappedHosts returns the hostnames of the nodes other than the node in parameters,
+// which the volume is mapped to
+func (p *FusionStorageSanPlugin) GetOtherMappedHosts(ctx context.Context,
+	name string,
+	parameters map[string]interface{}) ([]string, error) {
+	cli, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.releaseClient(ctx, cli)
+
+	localAttacher := attacher.NewAttacher(cli, p.protocol, "csi", p.portals, p.hosts, p.alua)
+	return localAttacher.GetOtherMappedHosts(ctx, name, parameters)
+}
+
 func (p *FusionStorageSanPlugin) mutexGetClient(ctx context.Context) (*client.Client, error) {
 	p.clientMutex.Lock()
 	var err error
@@ -272,15 +337,30 @@ func (p *FusionStorageSanPlugin) makeLunInfo(ctx context.Context, name string, c
 
 func (p *FusionStorageSanPlugin) StageVolume(ctx context.Context, name string,
 	parameters map[string]interface{}) error {
+	err := connector.VerifyProtocol(ctx, p.protocol)
+	if err != nil {
+		return err
+	}
+
+	if p.nvmeTransport != nil {
+		parameters["nvmeTransport"] = p.nvmeTransport
+	}
+
 	connectInfo, err := p.getStageVolumeInfo(ctx, name, parameters)
 	if err != nil {
 		return err
 	}
+
+	err = connector.CheckAttachLimit(ctx, p.protocol, getMappingLunWWN(connectInfo))
+	if err != nil {
+		return err
+	}
+
 	devPath, err := p.lunConnectVolume(ctx, connectInfo)
 	if err != nil {
 		return err
 	}
-	return p.lunStageVolume(ctx, name, devPath, parameters)
+	return p.lunStageVolume(ctx, name, devPath, getMappingLunWWN(connectInfo), parameters)
 }
 
 func (p *FusionStorageSanPlugin) getUnStageVolumeInfo(ctx context.Context, name string,
@@ -304,11 +384,20 @@ func (p *FusionStorageSanPlugin) getUnStageVolumeInfo(ctx context.Context, name
 func (p *FusionStorageSanPlugin) UnstageVolume(ctx context.Context,
 	name string,
 	parameters map[string]interface{}) error {
+	if p.reclaimSpace {
+		reclaimSpace(ctx, name, parameters)
+	}
+
 	err := p.unstageVolume(ctx, name, parameters)
 	if err != nil {
 		return err
 	}
 
+	err = connector.CloseLUKSDevice(ctx, name)
+	if err != nil {
+		return err
+	}
+
 	disconnectInfo, err := p.getUnStageVolumeInfo(ctx, name, parameters)
 	if err != nil {
 		return err
@@ -358,6 +447,11 @@ func (p *FusionStorageSanPlugin) NodeExpandVolume(ctx context.Context,
 		return err
 	}
 
+	err = connector.ResizeLUKSDevice(ctx, name)
+	if err != nil {
+		return err
+	}
+
 	if !isBlock {
 		err = connector.ResizeMountPath(ctx, volumePath)
 		if err != nil {
@@ -395,6 +489,13 @@ func (p *FusionStorageSanPlugin) DeleteSnapshot(ctx context.Context,
 	return nil
 }
 
+// QuerySnapshot returns the volume snapshot on storage, nil if it does not exist
+func (p *FusionStorageSanPlugin) QuerySnapshot(ctx context.Context,
+	parentID, snapshotName string) (map[string]interface{}, error) {
+	san := volume.NewSAN(p.cli)
+	return san.QuerySnapshot(ctx, parentID, utils.GetFusionStorageSnapshotName(snapshotName))
+}
+
 func (p *FusionStorageSanPlugin) UpdatePoolCapabilities(poolNames []string) (map[string]interface{}, error) {
 	return p.updatePoolCapabilities(poolNames, FusionStorageSan)
 }
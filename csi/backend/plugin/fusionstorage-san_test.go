@@ -0,0 +1,112 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"bou.ke/monkey"
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/connector"
+	"huawei-csi-driver/storage/fusionstorage/attacher"
+	"huawei-csi-driver/storage/fusionstorage/client"
+	"huawei-csi-driver/utils"
+)
+
+func TestFusionStorageRoCENodeStage(t *testing.T) {
+	cli := &client.Client{}
+	defer monkey.UnpatchAll()
+	hosts := map[string]map[string]interface{}{"node-1": {"hostName": "node-1"}}
+	monkey.PatchInstanceMethod(reflect.TypeOf(cli), "GetHostByName",
+		func(_ *client.Client, _ context.Context, hostName string) (map[string]interface{}, error) {
+			return hosts[hostName], nil
+		})
+	monkey.PatchInstanceMethod(reflect.TypeOf(cli), "QueryHostOfVolume",
+		func(*client.Client, context.Context, string) ([]map[string]interface{}, error) {
+			return nil, nil
+		})
+	var addedLuns []string
+	monkey.PatchInstanceMethod(reflect.TypeOf(cli), "AddLunToHost",
+		func(_ *client.Client, _ context.Context, lunName, _ string) error {
+			addedLuns = append(addedLuns, lunName)
+			return nil
+		})
+
+	lunInfo := utils.NewVolume("pvc-1")
+	lunInfo.SetLunWWN("6a8ffba1005d5eaa1aa0bdc300000001")
+	localAttacher := attacher.NewAttacher(cli, "roce", "csi", []string{"192.168.1.10"}, nil, nil)
+	connectInfo, err := localAttacher.NodeStage(context.Background(), lunInfo, map[string]interface{}{
+		"HostName":           "node-1",
+		"volumeUseMultiPath": true,
+		"nvmeMultiPathType":  "HW-UltraPath-NVMe",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pvc-1"}, addedLuns)
+	assert.Equal(t, connector.GetConnector(context.Background(), connector.RoCEDriver), connectInfo.Conn)
+	assert.Equal(t, []string{"192.168.1.10"}, connectInfo.MappingInfo["tgtPortals"])
+	assert.Equal(t, "6a8ffba1005d5eaa1aa0bdc300000001", connectInfo.MappingInfo["tgtLunGuid"])
+	assert.Equal(t, "HW-UltraPath-NVMe", connectInfo.MappingInfo["multiPathType"])
+
+	_, err = localAttacher.NodeStage(context.Background(), lunInfo, map[string]interface{}{
+		"HostName":           "node-2",
+		"volumeUseMultiPath": true,
+		"nvmeMultiPathType":  "HW-UltraPath-NVMe",
+	})
+	assert.True(t, errors.Is(err, utils.ErrFailedPrecondition))
+	assert.Equal(t, []string{"pvc-1"}, addedLuns)
+}
+
+func TestFusionStorageQoSParameters(t *testing.T) {
+	p := &FusionStorageSanPlugin{}
+	ctx := context.Background()
+	assert.NoError(t, p.SupportQoSParameters(ctx, `{"IOTYPE": 2, "MAXBANDWIDTH": 100, "MAXIOPS": 1000}`))
+	assert.NoError(t, p.SupportQoSParameters(ctx, `{"maxMBPS": 100}`))
+	assert.Error(t, p.SupportQoSParameters(ctx, `{"IOTYPE": 0, "MAXIOPS": 1000}`))
+	assert.Error(t, p.SupportQoSParameters(ctx, `{"MINIOPS": 1000}`))
+	assert.Error(t, p.SupportQoSParameters(ctx, `{"MAXIOPS": 1000, "maxIOPS": 1000}`))
+	assert.Error(t, p.SupportQoSParameters(ctx, `{"MAXBANDWIDTH": 1.5}`))
+	assert.Error(t, p.SupportQoSParameters(ctx, `{"IOTYPE": 2}`))
+}
+
+func TestFusionStorageUpdateQoS(t *testing.T) {
+	cli := &client.Client{}
+	defer monkey.UnpatchAll()
+	monkey.PatchInstanceMethod(reflect.TypeOf(cli), "GetVolumeByName",
+		func(*client.Client, context.Context, string) (map[string]interface{}, error) {
+			return map[string]interface{}{"volName": "pvc-1"}, nil
+		})
+	monkey.PatchInstanceMethod(reflect.TypeOf(cli), "GetQoSNameByVolume",
+		func(*client.Client, context.Context, string) (string, error) {
+			return "k8s_volume_20221010101010", nil
+		})
+	var updated map[string]int
+	monkey.PatchInstanceMethod(reflect.TypeOf(cli), "UpdateQoS",
+		func(_ *client.Client, _ context.Context, _ string, qos map[string]int) error {
+			updated = qos
+			return nil
+		})
+
+	p := &FusionStorageSanPlugin{}
+	p.cli = cli
+	err := p.UpdateQoS(context.Background(), "pvc-1", `{"IOTYPE": 2, "MAXBANDWIDTH": 100}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"maxMBPS": 100}, updated)
+}
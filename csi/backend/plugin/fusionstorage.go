@@ -20,8 +20,10 @@ import (
 	"context"
 	"errors"
 	"strings"
+	"sync"
 
 	"huawei-csi-driver/storage/fusionstorage/client"
+	"huawei-csi-driver/storage/fusionstorage/smartx"
 	"huawei-csi-driver/utils"
 	"huawei-csi-driver/utils/log"
 )
@@ -39,6 +41,12 @@ const (
 type FusionStoragePlugin struct {
 	basePlugin
 	cli *client.Client
+	// keepLogin is whether the client stays logged in after Init, which it does not on the nodes
+	keepLogin bool
+	// nodeClientMutex guards nodeClientCount, the number of the node requests using the client, which
+	// is logged out once the last one is done
+	nodeClientMutex sync.Mutex
+	nodeClientCount int
 }
 
 func (p *FusionStoragePlugin) init(config map[string]interface{}, keepLogin bool) error {
@@ -71,9 +79,42 @@ func (p *FusionStoragePlugin) init(config map[string]interface{}, keepLogin bool
 	}
 
 	p.cli = cli
+	p.keepLogin = keepLogin
 	return nil
 }
 
+// getNodeClient logs the client in for a request of the node, the client is kept logged in on the controller
+func (p *FusionStoragePlugin) getNodeClient(ctx context.Context) error {
+	if p.keepLogin {
+		return nil
+	}
+
+	p.nodeClientMutex.Lock()
+	defer p.nodeClientMutex.Unlock()
+	if p.nodeClientCount == 0 {
+		err := p.cli.Login(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	p.nodeClientCount++
+	return nil
+}
+
+// releaseNodeClient logs the client out once the last request of the node using it is done
+func (p *FusionStoragePlugin) releaseNodeClient(ctx context.Context) {
+	if p.keepLogin {
+		return
+	}
+
+	p.nodeClientMutex.Lock()
+	defer p.nodeClientMutex.Unlock()
+	p.nodeClientCount--
+	if p.nodeClientCount == 0 {
+		p.cli.Logout(ctx)
+	}
+}
+
 func (p *FusionStoragePlugin) getParams(name string,
 	parameters map[string]interface{}) (map[string]interface{}, error) {
 	params := map[string]interface{}{
@@ -89,6 +130,11 @@ func (p *FusionStoragePlugin) getParams(name string,
 		"accountName",
 		"allSquash",
 		"rootSquash",
+		"anonUid",
+		"anonGid",
+		"nfsAclMode",
+		"nfsSecurityFlavors",
+		"sectorSize",
 		"fsPermission",
 		"snapshotDirectoryVisibility",
 	}
@@ -142,7 +188,10 @@ func (p *FusionStoragePlugin) updatePoolCapabilities(poolNames []string, storage
 			usedCapacity := int64(pool["usedCapacity"].(float64))
 			freeCapacity := (totalCapacity - usedCapacity) * CAPACITY_UNIT
 
-			capability := map[string]interface{}{"FreeCapacity": freeCapacity}
+			capability := map[string]interface{}{
+				"FreeCapacity":  freeCapacity,
+				"TotalCapacity": totalCapacity * CAPACITY_UNIT,
+			}
 			if storageType == FusionStorageNas {
 				capability["Accounts"] = accounts
 			}
@@ -155,8 +204,8 @@ func (p *FusionStoragePlugin) updatePoolCapabilities(poolNames []string, storage
 
 // SupportQoSParameters checks requested QoS parameters support by FusionStorage plugin
 func (p *FusionStoragePlugin) SupportQoSParameters(ctx context.Context, qosConfig string) error {
-	// do not verify qos parameter for FusionStorage
-	return nil
+	_, err := smartx.VerifyQos(ctx, qosConfig)
+	return err
 }
 
 // Logout is to logout the storage session
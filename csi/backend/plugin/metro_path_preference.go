@@ -0,0 +1,82 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"huawei-csi-driver/connector"
+	"huawei-csi-driver/utils/log"
+)
+
+// metroPreferredHostsKey is the backend parameter listing the regexps of the hostnames of the nodes at the
+// site of the storage, which prefer the paths to it for HyperMetro volumes
+const metroPreferredHostsKey = "hyperMetroPreferredHosts"
+
+func getMetroPreferredHosts(parameters map[string]interface{}) ([]string, error) {
+	hosts, exist := parameters[metroPreferredHostsKey].([]interface{})
+	if !exist {
+		return nil, nil
+	}
+
+	var patterns []string
+	for _, i := range hosts {
+		pattern, ok := i.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s %v is invalid, must be a regexp string", metroPreferredHostsKey, i)
+		}
+
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("%s %s is invalid: %v", metroPreferredHostsKey, pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns, nil
+}
+
+// hasMetroPathPreference returns whether the nodes prefer the paths to one of the HyperMetro sites
+func (p *OceanstorSanPlugin) hasMetroPathPreference() bool {
+	return p.metroRemotePlugin != nil &&
+		(len(p.metroPreferredHosts) != 0 || len(p.metroRemotePlugin.metroPreferredHosts) != 0)
+}
+
+// RefreshPathPreference regroups the DM-multipath paths of the HyperMetro volume by their current ALUA
+// priorities, so the paths to the site of the node are used again after the site recovers. UltraPath and
+// the NVMe multipath follow the ALUA states by themselves.
+func (p *OceanstorSanPlugin) RefreshPathPreference(ctx context.Context, name string,
+	parameters map[string]interface{}) error {
+	if !p.hasMetroPathPreference() || (p.protocol != "iscsi" && p.protocol != "fc") {
+		return nil
+	}
+
+	useMultiPath, _ := parameters["volumeUseMultiPath"].(bool)
+	multiPathType, _ := parameters["scsiMultiPathType"].(string)
+	if !useMultiPath || multiPathType != connector.DMMultiPath {
+		return nil
+	}
+
+	disconnectInfo, err := p.getUnStageVolumeInfo(ctx, name, parameters)
+	if err != nil || disconnectInfo == nil {
+		return err
+	}
+
+	log.AddContext(ctx).Infof("Refresh the path preference of volume %s", name)
+	return connector.ReloadDMMultiPath(ctx, disconnectInfo.TgtLun)
+}
@@ -0,0 +1,60 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/connector"
+)
+
+func TestGetMetroPreferredHosts(t *testing.T) {
+	hosts, err := getMetroPreferredHosts(map[string]interface{}{
+		metroPreferredHostsKey: []interface{}{"^site-a-.*", "node-1"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"^site-a-.*", "node-1"}, hosts)
+
+	hosts, err = getMetroPreferredHosts(map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Nil(t, hosts)
+
+	_, err = getMetroPreferredHosts(map[string]interface{}{metroPreferredHostsKey: []interface{}{"site-("}})
+	assert.Error(t, err)
+
+	_, err = getMetroPreferredHosts(map[string]interface{}{metroPreferredHostsKey: []interface{}{1}})
+	assert.Error(t, err)
+}
+
+func TestRefreshPathPreferenceSkipped(t *testing.T) {
+	ctx := context.Background()
+	parameters := map[string]interface{}{
+		"volumeUseMultiPath": true,
+		"scsiMultiPathType":  connector.HWUltraPath,
+	}
+
+	p := &OceanstorSanPlugin{protocol: "iscsi"}
+	assert.NoError(t, p.RefreshPathPreference(ctx, "pvc-1", parameters))
+
+	p.metroRemotePlugin = &OceanstorSanPlugin{metroPreferredHosts: []string{"node-1"}}
+	assert.True(t, p.hasMetroPathPreference())
+	// UltraPath follows the ALUA states by itself, so the storage is not queried
+	assert.NoError(t, p.RefreshPathPreference(ctx, "pvc-1", parameters))
+}
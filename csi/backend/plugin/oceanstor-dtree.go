@@ -0,0 +1,220 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"huawei-csi-driver/storage/oceanstor/volume"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// OceanstorDTreePlugin provisions the volumes as dtrees of a parent filesystem shared by NFS, the only
+// pool of the backend is the parent filesystem
+type OceanstorDTreePlugin struct {
+	OceanstorPlugin
+	portal     string
+	parentName string
+	nfsVersion string
+}
+
+func init() {
+	RegPlugin("oceanstor-dtree", &OceanstorDTreePlugin{})
+}
+
+func (p *OceanstorDTreePlugin) NewPlugin() Plugin {
+	return &OceanstorDTreePlugin{}
+}
+
+func (p *OceanstorDTreePlugin) Init(config, parameters map[string]interface{}, keepLogin bool) error {
+	protocol, exist := parameters["protocol"].(string)
+	if !exist || protocol != volume.ShareProtocolNFS {
+		return errors.New("protocol must be provided and be \"nfs\" for oceanstor-dtree backend")
+	}
+
+	portals, exist := parameters["portals"].([]interface{})
+	if !exist || len(portals) != 1 {
+		return errors.New("portals must be provided for oceanstor-dtree backend and just support one portal")
+	}
+
+	portal, ok := portals[0].(string)
+	if !ok || portal == "" {
+		return errors.New("portal of oceanstor-dtree backend must be a non-empty string")
+	}
+
+	parentName, _ := parameters["parentName"].(string)
+	if parentName == "" {
+		return errors.New("parentName of the filesystem holding the dtrees must be provided for " +
+			"oceanstor-dtree backend")
+	}
+
+	nfsVersion, _ := parameters["nfsVersion"].(string)
+	if _, exist := utils.GetNFSProtocol(nfsVersion); nfsVersion != "" && !exist {
+		return fmt.Errorf("nfsVersion %s of oceanstor-dtree backend must be 3, 4, 4.0, 4.1 or 4.2", nfsVersion)
+	}
+
+	err := p.init(config, keepLogin)
+	if err != nil {
+		return err
+	}
+
+	p.portal = portal
+	p.parentName = parentName
+	p.nfsVersion = nfsVersion
+	return nil
+}
+
+func (p *OceanstorDTreePlugin) getDTreeObj() *volume.DTree {
+	return volume.NewDTree(p.cli, p.product, p.parentName)
+}
+
+func (p *OceanstorDTreePlugin) CreateVolume(ctx context.Context, name string,
+	parameters map[string]interface{}) (utils.Volume, error) {
+	size, ok := parameters["size"].(int64)
+	if !ok || !utils.Capacity(size).IsSectorAligned() {
+		msg := fmt.Sprintf("Create Volume: the capacity %d is not an integer multiple of 512.", size)
+		log.AddContext(ctx).Errorln(msg)
+		return nil, errors.New(msg)
+	}
+
+	params := p.getParams(ctx, name, parameters)
+	dTree := p.getDTreeObj()
+	return dTree.Create(ctx, params)
+}
+
+func (p *OceanstorDTreePlugin) DeleteVolume(ctx context.Context, name string) error {
+	dTree := p.getDTreeObj()
+	return dTree.Delete(ctx, name)
+}
+
+func (p *OceanstorDTreePlugin) ExpandVolume(ctx context.Context, name string, size int64) (bool, error) {
+	if !utils.Capacity(size).IsSectorAligned() {
+		msg := fmt.Sprintf("Expand Volume: the capacity %d is not an integer multiple of 512.", size)
+		log.AddContext(ctx).Errorln(msg)
+		return false, errors.New(msg)
+	}
+
+	dTree := p.getDTreeObj()
+	return false, dTree.Expand(ctx, name, utils.Capacity(size).Sectors())
+}
+
+// UpdateQuota sets the quota of the dtree of the size
+func (p *OceanstorDTreePlugin) UpdateQuota(ctx context.Context, name string, size int64,
+	softQuota, hardQuota, gracePeriod string) error {
+	quota, err := volume.NewQuota(size, softQuota, hardQuota, gracePeriod)
+	if err != nil || quota == nil {
+		return err
+	}
+
+	dTree := p.getDTreeObj()
+	return dTree.UpdateQuota(ctx, name, quota)
+}
+
+// GetQuotaUsage returns the hard limit of the quota of the dtree and its used capacity, the node reports
+// them rather than the capacity of the parent filesystem
+func (p *OceanstorDTreePlugin) GetQuotaUsage(ctx context.Context, name string) (int64, int64, error) {
+	err := p.getNodeClient(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer p.releaseNodeClient(ctx)
+
+	dTree := p.getDTreeObj()
+	return dTree.GetQuotaUsage(ctx, name)
+}
+
+// AlwaysLimitedByQuota returns true, as every dtree is limited by its directory quota
+func (p *OceanstorDTreePlugin) AlwaysLimitedByQuota() bool {
+	return true
+}
+
+func (p *OceanstorDTreePlugin) StageVolume(ctx context.Context, name string,
+	parameters map[string]interface{}) error {
+	if version, _ := parameters["nfsVersion"].(string); version == "" {
+		parameters["nfsVersion"] = p.nfsVersion
+	}
+	return p.fsStageVolume(ctx, p.parentName+"/"+name, p.portal, parameters)
+}
+
+func (p *OceanstorDTreePlugin) UnstageVolume(ctx context.Context, name string,
+	parameters map[string]interface{}) error {
+	return p.unstageVolume(ctx, name, parameters)
+}
+
+func (p *OceanstorDTreePlugin) NodeExpandVolume(context.Context, string, string, bool, int64) error {
+	return nil
+}
+
+func (p *OceanstorDTreePlugin) CreateSnapshot(ctx context.Context, name, snapshotName string) (
+	map[string]interface{}, error) {
+	return nil, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+		"Dtree %s can't be snapshotted, snapshots are taken of whole filesystems", name)
+}
+
+func (p *OceanstorDTreePlugin) DeleteSnapshot(ctx context.Context, snapshotParentID, snapshotName string) error {
+	log.AddContext(ctx).Infof("Dtree backends have no snapshot %s to delete", snapshotName)
+	return nil
+}
+
+// UpdateBackendCapabilities reports the dtrees thin without any feature of the filesystems, such as
+// QoS, clone or pairs
+func (p *OceanstorDTreePlugin) UpdateBackendCapabilities() (map[string]interface{}, error) {
+	capabilities := map[string]interface{}{
+		"SupportThin":            true,
+		"SupportThick":           false,
+		"SupportQoS":             false,
+		"SupportMetro":           false,
+		"SupportReplication":     false,
+		"SupportApplicationType": false,
+		"SupportClone":           false,
+	}
+
+	err := updateNFSCapabilities(p.cli, capabilities)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkNFSVersion(p.nfsVersion, capabilities)
+	if err != nil {
+		return nil, err
+	}
+
+	p.capabilities = capabilities
+	return capabilities, nil
+}
+
+// UpdatePoolCapabilities reports the capacity of the parent filesystem as the capacity of the pool
+func (p *OceanstorDTreePlugin) UpdatePoolCapabilities(poolNames []string) (map[string]interface{}, error) {
+	dTree := p.getDTreeObj()
+	total, free, err := dTree.GetParentCapacity(context.Background())
+	if err != nil {
+		log.Errorf("Get capacity of parent filesystem %s error: %v", p.parentName, err)
+		return nil, err
+	}
+
+	capabilities := make(map[string]interface{})
+	for _, name := range poolNames {
+		capabilities[name] = map[string]interface{}{
+			"FreeCapacity":  free.Bytes(),
+			"TotalCapacity": total.Bytes(),
+		}
+	}
+	return capabilities, nil
+}
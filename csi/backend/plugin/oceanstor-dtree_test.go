@@ -0,0 +1,67 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package plugin
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"bou.ke/monkey"
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/storage/oceanstor/client"
+)
+
+func TestDTreeInit(t *testing.T) {
+	config := map[string]interface{}{"urls": []interface{}{"*.*.*.*"}, "user": "testUser",
+		"password": "2e0273ba51d5c30866", "keyText": "0NuSPbY4r6rANmmAipqPTMRpSlz3OULX"}
+	tests := []struct {
+		name       string
+		parameters map[string]interface{}
+		wantErr    bool
+	}{
+		{"Normal", map[string]interface{}{"protocol": "nfs", "portals": []interface{}{"*.*.*.*"},
+			"parentName": "parent"}, false},
+		{"CIFS", map[string]interface{}{"protocol": "cifs", "portals": []interface{}{"*.*.*.*"},
+			"parentName": "parent"}, true},
+		{"NoParent", map[string]interface{}{"protocol": "nfs", "portals": []interface{}{"*.*.*.*"}}, true},
+		{"PortalNotString", map[string]interface{}{"protocol": "nfs", "portals": []interface{}{1},
+			"parentName": "parent"}, true},
+		{"NFSVersionErr", map[string]interface{}{"protocol": "nfs", "portals": []interface{}{"*.*.*.*"},
+			"parentName": "parent", "nfsVersion": "3.0"}, true},
+	}
+
+	var cli *client.BaseClient
+	monkey.PatchInstanceMethod(reflect.TypeOf(cli), "Logout", func(*client.BaseClient, context.Context) {})
+	monkey.PatchInstanceMethod(reflect.TypeOf(cli), "Login", func(*client.BaseClient, context.Context) error {
+		return nil
+	})
+	monkey.PatchInstanceMethod(reflect.TypeOf(cli), "GetSystem", func(*client.BaseClient, context.Context) (map[string]interface{}, error) {
+		return map[string]interface{}{"PRODUCTVERSION": "Test"}, nil
+	})
+	defer monkey.UnpatchAll()
+
+	for _, tt := range tests {
+		p := &OceanstorDTreePlugin{}
+		err := p.Init(config, tt.parameters, false)
+		assert.Equal(t, tt.wantErr, err != nil, "%s: %v", tt.name, err)
+		if err == nil {
+			assert.Equal(t, "parent", p.parentName)
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"huawei-csi-driver/storage/oceanstor/client"
 	"huawei-csi-driver/storage/oceanstor/volume"
@@ -37,6 +38,8 @@ const (
 type OceanstorNasPlugin struct {
 	OceanstorPlugin
 	portal        string
+	protocol      string
+	nfsVersion    string
 	vStorePairID  string
 	metroDomainID string
 
@@ -55,8 +58,8 @@ func (p *OceanstorNasPlugin) NewPlugin() Plugin {
 
 func (p *OceanstorNasPlugin) Init(config, parameters map[string]interface{}, keepLogin bool) error {
 	protocol, exist := parameters["protocol"].(string)
-	if !exist || protocol != "nfs" {
-		return errors.New("protocol must be provided and be \"nfs\" for oceanstor-nas backend")
+	if !exist || (protocol != volume.ShareProtocolNFS && protocol != volume.ShareProtocolCIFS) {
+		return errors.New("protocol must be provided and be \"nfs\" or \"cifs\" for oceanstor-nas backend")
 	}
 
 	portals, exist := parameters["portals"].([]interface{})
@@ -64,12 +67,42 @@ func (p *OceanstorNasPlugin) Init(config, parameters map[string]interface{}, kee
 		return errors.New("portals must be provided for oceanstor-nas backend and just support one portal")
 	}
 
-	err := p.init(config, keepLogin)
+	missingApplicationType, err := getMissingApplicationType(parameters)
 	if err != nil {
 		return err
 	}
 
+	hyperMetroOptions, err := getHyperMetroOptions(parameters)
+	if err != nil {
+		return err
+	}
+
+	replicationLinkLatency, err := getReplicationLinkLatency(parameters)
+	if err != nil {
+		return err
+	}
+
+	recycleBinRetention, err := getRecycleBinRetention(parameters)
+	if err != nil {
+		return err
+	}
+
+	err = p.init(config, keepLogin)
+	if err != nil {
+		return err
+	}
+
+	p.missingApplicationType = missingApplicationType
+	p.hyperMetroOptions = hyperMetroOptions
+	p.replicationLinkLatency = replicationLinkLatency
+	p.recycleBinRetention = recycleBinRetention
+	p.nfsVersion, _ = parameters["nfsVersion"].(string)
+	if _, exist := utils.GetNFSProtocol(p.nfsVersion); p.nfsVersion != "" && !exist {
+		return fmt.Errorf("nfsVersion %s of oceanstor-nas backend must be 3, 4, 4.0, 4.1 or 4.2", p.nfsVersion)
+	}
+
 	p.portal = portals[0].(string)
+	p.protocol = protocol
 	p.vStorePairID, exist = config["metrovStorePairID"].(string)
 	if exist {
 		log.Infof("The metro vStorePair ID is %s", p.vStorePairID)
@@ -89,20 +122,27 @@ func (p *OceanstorNasPlugin) getNasObj() *volume.NAS {
 		replicaRemoteCli = p.replicaRemotePlugin.cli
 	}
 
-	return volume.NewNAS(p.cli, metroRemoteCli, replicaRemoteCli, p.product, p.nasHyperMetro)
+	return volume.NewNAS(p.cli, metroRemoteCli, replicaRemoteCli, p.product, p.nasHyperMetro, p.protocol)
 }
 
 func (p *OceanstorNasPlugin) CreateVolume(ctx context.Context, name string, parameters map[string]interface{}) (
 	utils.Volume, error) {
 
 	size, ok := parameters["size"].(int64)
-	if !ok || !utils.IsCapacityAvailable(size, SectorSize) {
+	if !ok || !utils.Capacity(size).IsSectorAligned() {
 		msg := fmt.Sprintf("Create Volume: the capacity %d is not an integer multiple of 512.", size)
 		log.AddContext(ctx).Errorln(msg)
 		return nil, errors.New(msg)
 	}
 
 	params := p.getParams(ctx, name, parameters)
+	p.setDataReductionParams(ctx, params, parameters, "NAS")
+	if err := p.checkHyperMetroOptions(ctx, params, true); err != nil {
+		return nil, err
+	}
+	if err := p.checkReplicationOptions(ctx, params); err != nil {
+		return nil, err
+	}
 	params["metroDomainID"] = p.metroDomainID
 	nas := p.getNasObj()
 	volObj, err := nas.Create(ctx, params)
@@ -123,29 +163,130 @@ func (p *OceanstorNasPlugin) getClient() (client.BaseClientInterface, client.Bas
 
 func (p *OceanstorNasPlugin) DeleteVolume(ctx context.Context, name string) error {
 	nas := p.getNasObj()
+	if p.recycleBinRetention > 0 {
+		return nas.Recycle(ctx, name)
+	}
 	return nas.Delete(ctx, name)
 }
 
+// PurgeRecycledVolumes deletes the filesystems kept in the recycle bin longer than the retention
+func (p *OceanstorNasPlugin) PurgeRecycledVolumes(ctx context.Context) error {
+	if p.recycleBinRetention <= 0 {
+		return nil
+	}
+
+	nas := p.getNasObj()
+	return nas.PurgeRecycled(ctx, p.recycleBinRetention)
+}
+
 func (p *OceanstorNasPlugin) ExpandVolume(ctx context.Context, name string, size int64) (bool, error) {
-	if !utils.IsCapacityAvailable(size, SectorSize) {
+	if !utils.Capacity(size).IsSectorAligned() {
 		msg := fmt.Sprintf("Expand Volume: the capacity %d is not an integer multiple of 512.", size)
 		log.AddContext(ctx).Errorln(msg)
 		return false, errors.New(msg)
 	}
-	newSize := utils.TransVolumeCapacity(size, SectorSize)
+	newSize := utils.Capacity(size).Sectors()
 	nas := p.getNasObj()
 	return false, nas.Expand(ctx, name, newSize)
 }
 
+// UpdateShareAccess sets the NFS clients allowed to access the filesystem
+func (p *OceanstorNasPlugin) UpdateShareAccess(ctx context.Context, name, authClient string) error {
+	nas := p.getNasObj()
+	return nas.UpdateShareAccess(ctx, name, authClient)
+}
+
+// ShrinkVolume reduces the capacity of the filesystem
+func (p *OceanstorNasPlugin) ShrinkVolume(ctx context.Context, name string, size int64) error {
+	if !utils.Capacity(size).IsSectorAligned() {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"Shrink Volume: the capacity %d is not an integer multiple of 512.", size)
+	}
+
+	nas := p.getNasObj()
+	return nas.Shrink(ctx, name, utils.Capacity(size))
+}
+
+// FailoverVolume lets the replica of the filesystem on the replication storage be written
+func (p *OceanstorNasPlugin) FailoverVolume(ctx context.Context, name string) error {
+	if p.replicaRemotePlugin == nil {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"The backend of filesystem %s has no replication backend to fail over to", name)
+	}
+
+	nas := p.getNasObj()
+	return nas.Failover(ctx, name)
+}
+
+// UpdateQoS sets the QoS parameters of the filesystem
+func (p *OceanstorNasPlugin) UpdateQoS(ctx context.Context, name, qosConfig string) error {
+	qos, err := p.parseQoS(ctx, qosConfig)
+	if err != nil {
+		return err
+	}
+
+	nas := p.getNasObj()
+	return nas.UpdateQoS(ctx, name, qos)
+}
+
+// UpdateQuota sets the quota of the filesystem of the size
+func (p *OceanstorNasPlugin) UpdateQuota(ctx context.Context, name string, size int64,
+	softQuota, hardQuota, gracePeriod string) error {
+	quota, err := volume.NewQuota(size, softQuota, hardQuota, gracePeriod)
+	if err != nil || quota == nil {
+		return err
+	}
+
+	nas := p.getNasObj()
+	return nas.UpdateQuota(ctx, name, quota)
+}
+
+// GetQuotaUsage returns the hard limit of the quota of the filesystem and its used capacity
+func (p *OceanstorNasPlugin) GetQuotaUsage(ctx context.Context, name string) (int64, int64, error) {
+	err := p.getNodeClient(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer p.releaseNodeClient(ctx)
+
+	nas := p.getNasObj()
+	return nas.GetQuotaUsage(ctx, name)
+}
+
+// AlwaysLimitedByQuota returns false, as only the filesystems setting hardQuota are limited by quotas
+func (p *OceanstorNasPlugin) AlwaysLimitedByQuota() bool {
+	return false
+}
+
+// QueryVolumeState returns the state of the filesystem on storage
+func (p *OceanstorNasPlugin) QueryVolumeState(ctx context.Context, name string) (*VolumeState, error) {
+	fs, err := p.cli.GetFileSystemByName(ctx, utils.GetFileSystemName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return p.getVolumeState(fs)
+}
+
 func (p *OceanstorNasPlugin) StageVolume(ctx context.Context,
 	name string,
 	parameters map[string]interface{}) error {
+	if p.protocol == volume.ShareProtocolCIFS {
+		return p.cifsStageVolume(ctx, name, p.portal, parameters)
+	}
+
+	if version, _ := parameters["nfsVersion"].(string); version == "" {
+		parameters["nfsVersion"] = p.nfsVersion
+	}
 	return p.fsStageVolume(ctx, name, p.portal, parameters)
 }
 
 func (p *OceanstorNasPlugin) UnstageVolume(ctx context.Context,
 	name string,
 	parameters map[string]interface{}) error {
+	if p.protocol == volume.ShareProtocolCIFS {
+		return p.cifsUnstageVolume(ctx, name, parameters)
+	}
 	return p.unstageVolume(ctx, name, parameters)
 }
 
@@ -190,6 +331,126 @@ func (p *OceanstorNasPlugin) DeleteSnapshot(ctx context.Context, snapshotParentI
 	return nil
 }
 
+// RollbackSnapshot starts rolling the filesystem back to its snapshot
+func (p *OceanstorNasPlugin) RollbackSnapshot(ctx context.Context,
+	name, snapshotParentID, snapshotName string, speed int) (int64, error) {
+	nas := p.getNasObj()
+	size, err := nas.RollbackSnapshot(ctx, utils.GetFileSystemName(name), snapshotParentID,
+		utils.GetFSSnapshotName(snapshotName), speed)
+	if err != nil {
+		return 0, err
+	}
+
+	return size.Bytes(), nil
+}
+
+// IsRollingBack returns whether the filesystem is still rolling back to its snapshot
+func (p *OceanstorNasPlugin) IsRollingBack(ctx context.Context, snapshotParentID, snapshotName string) (bool, error) {
+	nas := p.getNasObj()
+	return nas.IsRollingBack(ctx, snapshotParentID, utils.GetFSSnapshotName(snapshotName))
+}
+
+// QuerySnapshot returns the filesystem snapshot on storage, nil if it does not exist. The snapshots created
+// by the driver are named with "_" in place of "-", which the adopted snapshots are not, so the name is
+// queried as is if no snapshot of the converted name exists.
+func (p *OceanstorNasPlugin) QuerySnapshot(ctx context.Context,
+	parentID, snapshotName string) (map[string]interface{}, error) {
+	nas := p.getNasObj()
+	fsSnapshotName := utils.GetFSSnapshotName(snapshotName)
+	snapshot, err := nas.QuerySnapshot(ctx, parentID, fsSnapshotName)
+	if err != nil || snapshot != nil || fsSnapshotName == snapshotName {
+		return snapshot, err
+	}
+
+	return nas.QuerySnapshot(ctx, parentID, snapshotName)
+}
+
+// ListSnapshots returns a page of the snapshots of the filesystem. Listing the snapshots of all the
+// filesystems is not supported, as storage queries the filesystem snapshots by their parent.
+func (p *OceanstorNasPlugin) ListSnapshots(ctx context.Context, name string, offset, limit int) (
+	[]map[string]interface{}, int, error) {
+	if name == "" {
+		log.AddContext(ctx).Debugf("Listing the snapshots of all the filesystems is not supported")
+		return nil, 0, nil
+	}
+
+	nas := p.getNasObj()
+	return nas.ListSnapshots(ctx, utils.GetFileSystemName(name), offset, limit)
+}
+
+// GetSnapshotVolume returns the path of the snapshot in the snapshot directory of the filesystem
+func (p *OceanstorNasPlugin) GetSnapshotVolume(ctx context.Context, parentID, snapshotName string) (string, error) {
+	nas := p.getNasObj()
+	fsName, err := nas.GetSnapshotDirectoryParent(ctx, parentID, utils.GetFSSnapshotName(snapshotName))
+	if err != nil {
+		return "", err
+	}
+
+	return fsName + SnapshotVolumeSeparator + utils.GetFSSnapshotName(snapshotName), nil
+}
+
+// EnsureProtectionGroup keeps the filesystems as a protection group. Storage has no protection group
+// for filesystems, so the group is kept by the driver only.
+func (p *OceanstorNasPlugin) EnsureProtectionGroup(ctx context.Context, group string, volumes []string) error {
+	return nil
+}
+
+// RemoveFromProtectionGroup removes the filesystems from the protection group kept by the driver
+func (p *OceanstorNasPlugin) RemoveFromProtectionGroup(ctx context.Context, group string, volumes []string) error {
+	return nil
+}
+
+// DeleteProtectionGroup deletes the protection group kept by the driver, the filesystems are kept
+func (p *OceanstorNasPlugin) DeleteProtectionGroup(ctx context.Context, group string, volumes []string) error {
+	return nil
+}
+
+// CreateGroupSnapshot snapshots the filesystems of the protection group as a unit
+func (p *OceanstorNasPlugin) CreateGroupSnapshot(ctx context.Context,
+	group, snapshot string, volumes []string) (map[string]string, error) {
+	fsNames := make([]string, 0, len(volumes))
+	for _, volume := range volumes {
+		fsNames = append(fsNames, utils.GetFileSystemName(volume))
+	}
+
+	nas := p.getNasObj()
+	members, err := nas.CreateGroupSnapshot(ctx, utils.GetFSSnapshotName(snapshot), fsNames)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make(map[string]string, len(volumes))
+	for _, volume := range volumes {
+		for _, member := range members {
+			if member.ParentName == utils.GetFileSystemName(volume) {
+				snapshots[volume] = member.ParentID + "." + member.Name
+			}
+		}
+	}
+	return snapshots, nil
+}
+
+// DeleteGroupSnapshot deletes the snapshots of the filesystems taken by the group snapshot
+func (p *OceanstorNasPlugin) DeleteGroupSnapshot(ctx context.Context, snapshot string, volumeSnapshots []string) error {
+	members := make([]volume.GroupSnapshotMember, 0, len(volumeSnapshots))
+	for _, volumeSnapshot := range volumeSnapshots {
+		parentID, name := utils.SplitVolumeId(volumeSnapshot)
+		if name == "" {
+			return utils.Errorf(ctx, "invalid snapshot %s of group snapshot %s", volumeSnapshot, snapshot)
+		}
+		members = append(members, volume.GroupSnapshotMember{ParentID: parentID, Name: name})
+	}
+
+	nas := p.getNasObj()
+	return nas.DeleteGroupSnapshot(ctx, members)
+}
+
+// EnsureReplicationGroup refuses to replicate the filesystems as a unit, which storage does not support
+func (p *OceanstorNasPlugin) EnsureReplicationGroup(ctx context.Context, group string, volumes []string) error {
+	return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+		"protection group %s of filesystems cannot be replicated as a consistency group", group)
+}
+
 func (p *OceanstorNasPlugin) UpdateBackendCapabilities() (map[string]interface{}, error) {
 	capabilities, err := p.OceanstorPlugin.UpdateBackendCapabilities()
 	if err != nil {
@@ -273,7 +534,20 @@ func (p *OceanstorNasPlugin) updateReplicationCapability(capabilities map[string
 }
 
 func (p *OceanstorNasPlugin) updateNFS4Capability(capabilities map[string]interface{}) error {
-	nfsServiceSetting, err := p.cli.GetNFSServiceSetting(context.Background())
+	err := updateNFSCapabilities(p.cli, capabilities)
+	if err != nil {
+		return err
+	}
+
+	if p.protocol != volume.ShareProtocolNFS {
+		return nil
+	}
+	return checkNFSVersion(p.nfsVersion, capabilities)
+}
+
+// updateNFSCapabilities sets the NFS versions enabled on the storage in the capabilities
+func updateNFSCapabilities(cli client.BaseClientInterface, capabilities map[string]interface{}) error {
+	nfsServiceSetting, err := cli.GetNFSServiceSetting(context.Background())
 	if err != nil {
 		return err
 	}
@@ -282,6 +556,7 @@ func (p *OceanstorNasPlugin) updateNFS4Capability(capabilities map[string]interf
 	capabilities["SupportNFS3"] = true
 	capabilities["SupportNFS4"] = false
 	capabilities["SupportNFS41"] = false
+	capabilities["SupportNFS42"] = false
 
 	if !nfsServiceSetting["SupportNFS3"] {
 		capabilities["SupportNFS3"] = false
@@ -295,6 +570,26 @@ func (p *OceanstorNasPlugin) updateNFS4Capability(capabilities map[string]interf
 		capabilities["SupportNFS41"] = true
 	}
 
+	if nfsServiceSetting["SupportNFS42"] {
+		capabilities["SupportNFS42"] = true
+	}
+
+	return nil
+}
+
+// checkNFSVersion rejects the backend whose configured NFS version is not enabled on the storage, so
+// that the volumes fail to provision instead of failing to mount
+func checkNFSVersion(nfsVersion string, capabilities map[string]interface{}) error {
+	if nfsVersion == "" {
+		return nil
+	}
+
+	protocol, _ := utils.GetNFSProtocol(nfsVersion)
+	capability := "SupportNFS" + strings.TrimPrefix(protocol, "nfs")
+	if capabilities[capability] != true {
+		return fmt.Errorf("nfs version %s configured for the backend is not enabled on the storage", nfsVersion)
+	}
+
 	return nil
 }
 
@@ -22,6 +22,7 @@ import (
 	"testing"
 
 	"bou.ke/monkey"
+	"github.com/stretchr/testify/assert"
 
 	"huawei-csi-driver/storage/oceanstor/client"
 )
@@ -44,6 +45,16 @@ func TestInit(t *testing.T) {
 			map[string]interface{}{"protocol": "wrong", "portals": []interface{}{"*.*.*.1"}},
 			false, true,
 		},
+		{"NFSVersion",
+			map[string]interface{}{"urls": []interface{}{"*.*.*.*"}, "user": "testUser", "password": "2e0273ba51d5c30866", "keyText": "0NuSPbY4r6rANmmAipqPTMRpSlz3OULX"},
+			map[string]interface{}{"protocol": "nfs", "portals": []interface{}{"*.*.*.*"}, "nfsVersion": "4.1"},
+			false, false,
+		},
+		{"NFSVersionErr",
+			map[string]interface{}{"urls": []interface{}{"*.*.*.*"}, "user": "testUser", "password": "2e0273ba51d5c30866", "keyText": "0NuSPbY4r6rANmmAipqPTMRpSlz3OULX"},
+			map[string]interface{}{"protocol": "nfs", "portals": []interface{}{"*.*.*.*"}, "nfsVersion": "3.0"},
+			false, true,
+		},
 		{"PortNotUnique",
 			map[string]interface{}{"urls": []interface{}{"*.*.*.*"}, "user": "testUser", "password": "2e0273ba51d5c30866", "keyText": "0NuSPbY4r6rANmmAipqPTMRpSlz3OULX"},
 			map[string]interface{}{"protocol": "wrong", "portals": []interface{}{"*.*.*.1", "*.*.*.2"}},
@@ -70,3 +81,73 @@ func TestInit(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryFilesystemSnapshot(t *testing.T) {
+	cases := []struct {
+		name         string
+		snapshotName string
+		arrayName    string
+		exist        bool
+	}{
+		{"Created by driver", "snapshot-1", "snapshot_1", true},
+		{"Adopted with hyphen", "daily-0001", "daily-0001", true},
+		{"Adopted with underscore", "daily_0001", "daily_0001", true},
+		{"Not exist", "snapshot-2", "snapshot_1", false},
+	}
+
+	cli := &client.BaseClient{}
+	p := &OceanstorNasPlugin{OceanstorPlugin: OceanstorPlugin{cli: cli}}
+	defer monkey.UnpatchAll()
+	monkey.PatchInstanceMethod(reflect.TypeOf(cli), "GetFileSystemByID",
+		func(*client.BaseClient, context.Context, string) (map[string]interface{}, error) {
+			return map[string]interface{}{"ID": "1", "CAPACITY": "2048"}, nil
+		})
+	for _, c := range cases {
+		monkey.PatchInstanceMethod(reflect.TypeOf(cli), "GetFSSnapshotByName",
+			func(_ *client.BaseClient, _ context.Context, _, name string) (map[string]interface{}, error) {
+				if name != c.arrayName {
+					return nil, nil
+				}
+				return map[string]interface{}{"NAME": name, "PARENTID": "1", "PARENTNAME": "pvc_1"}, nil
+			})
+
+		snapshot, err := p.QuerySnapshot(context.Background(), "1", c.snapshotName)
+		assert.NoError(t, err, c.name)
+		assert.Equal(t, c.exist, snapshot != nil, c.name)
+	}
+}
+
+func TestNodeClientSession(t *testing.T) {
+	cases := []struct {
+		name      string
+		keepLogin bool
+		logins    int
+		logouts   int
+	}{
+		{"Node", false, 1, 1},
+		{"Controller", true, 0, 0},
+	}
+
+	cli := &client.BaseClient{}
+	defer monkey.UnpatchAll()
+	for _, c := range cases {
+		var logins, logouts int
+		monkey.PatchInstanceMethod(reflect.TypeOf(cli), "Login", func(*client.BaseClient, context.Context) error {
+			logins++
+			return nil
+		})
+		monkey.PatchInstanceMethod(reflect.TypeOf(cli), "Logout", func(*client.BaseClient, context.Context) {
+			logouts++
+		})
+
+		p := &OceanstorPlugin{cli: cli, keepLogin: c.keepLogin}
+		ctx := context.Background()
+		assert.NoError(t, p.getNodeClient(ctx), c.name)
+		assert.NoError(t, p.getNodeClient(ctx), c.name)
+		p.releaseNodeClient(ctx)
+		assert.Equal(t, 0, logouts, c.name)
+		p.releaseNodeClient(ctx)
+		assert.Equal(t, c.logins, logins, c.name)
+		assert.Equal(t, c.logouts, logouts, c.name)
+	}
+}
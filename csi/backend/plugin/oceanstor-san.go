@@ -44,6 +44,17 @@ type OceanstorSanPlugin struct {
 	protocol string
 	portals  []string
 	alua     map[string]interface{}
+	// reclaimSpace discards the unused blocks of filesystems before they are unstaged
+	reclaimSpace bool
+	// metroPreferredHosts are the regexps of the hostnames of the nodes at the site of the storage
+	metroPreferredHosts []string
+	// nvmeTransport are the parameters of the RoCE connections to the storage
+	nvmeTransport *connector.NVMeTransportOptions
+	// recommendedALUA sets the ALUA recommended for the multipath software of the nodes whose hosts have
+	// no ALUA configured
+	recommendedALUA bool
+	// chap is the CHAP credentials of the iSCSI initiators, nil if the CHAP is not used
+	chap *attacher.CHAP
 
 	replicaRemotePlugin *OceanstorSanPlugin
 	metroRemotePlugin   *OceanstorSanPlugin
@@ -70,14 +81,52 @@ func (p *OceanstorSanPlugin) NewPlugin() Plugin {
 
 func (p *OceanstorSanPlugin) Init(config, parameters map[string]interface{}, keepLogin bool) error {
 	protocol, exist := parameters["protocol"].(string)
-	if !exist || (protocol != "iscsi" && protocol != "fc" && protocol != "roce" && protocol != "fc-nvme") {
+	if !exist || !isOceanstorSanProtocol(protocol) {
 		return errors.New("protocol must be provided as 'iscsi', 'fc', " +
 			"'roce' or 'fc-nvme' for oceanstor-san backend")
 	}
 
+	protocols, err := getFallbackProtocols(parameters)
+	if err != nil {
+		return err
+	}
+	protocols = append([]string{protocol}, protocols...)
+
+	p.reclaimSpace, _ = parameters[reclaimSpaceKey].(bool)
 	p.alua, _ = parameters["ALUA"].(map[string]interface{})
+	p.recommendedALUA, _ = parameters["recommendedALUA"].(bool)
+	p.metroPreferredHosts, err = getMetroPreferredHosts(parameters)
+	if err != nil {
+		return err
+	}
+
+	p.nvmeTransport, err = getNVMeTransportOptions(parameters, protocols)
+	if err != nil {
+		return err
+	}
+
+	p.missingApplicationType, err = getMissingApplicationType(parameters)
+	if err != nil {
+		return err
+	}
+
+	p.hyperMetroOptions, err = getHyperMetroOptions(parameters)
+	if err != nil {
+		return err
+	}
+	p.hyperMetroGroup, _ = parameters["hyperMetroGroup"].(string)
 
-	if protocol == "iscsi" || protocol == "roce" {
+	p.replicationLinkLatency, err = getReplicationLinkLatency(parameters)
+	if err != nil {
+		return err
+	}
+
+	p.recycleBinRetention, err = getRecycleBinRetention(parameters)
+	if err != nil {
+		return err
+	}
+
+	if utils.IsContain("iscsi", protocols) || utils.IsContain("roce", protocols) {
 		portals, exist := parameters["portals"].([]interface{})
 		if !exist {
 			return errors.New("portals are required to configure for iSCSI or RoCE backend")
@@ -89,25 +138,151 @@ func (p *OceanstorSanPlugin) Init(config, parameters map[string]interface{}, kee
 		}
 
 		p.portals = IPs
+
+		p.chap, err = getChap(config)
+		if err != nil {
+			return err
+		}
 	}
 
-	err := p.init(config, keepLogin)
+	err = p.init(config, keepLogin)
 	if err != nil {
 		return err
 	}
 
-	if (protocol == "roce" || protocol == "fc-nvme") && p.product != "DoradoV6" {
-		msg := fmt.Sprintf("The storage backend %s does not support NVME protocol", p.product)
-		log.Errorln(msg)
-		return errors.New(msg)
+	// The LUNs, hosts and mappings of a vStore are only visible to the backends of the vStore
+	if p.cli.GetvStoreName() != "" {
+		err = p.checkFirmware(context.Background(), VStoreSANFeature)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, protocol := range protocols {
+		if (protocol == "roce" || protocol == "fc-nvme") && p.product != "DoradoV6" {
+			msg := fmt.Sprintf("The storage backend %s does not support NVME protocol", p.product)
+			log.Errorln(msg)
+			return errors.New(msg)
+		}
 	}
 
 	p.protocol = protocol
+	if !keepLogin && len(protocols) > 1 {
+		// The node plugin attaches volumes, so it uses the protocol negotiated for the node
+		p.protocol = negotiateProtocol(context.Background(), protocols)
+	}
 	p.storageOnline = true
 
 	return nil
 }
 
+// getChap returns the CHAP credentials in the backend secret, the mutual CHAP requires the one-way CHAP
+func getChap(config map[string]interface{}) (*attacher.CHAP, error) {
+	chap := &attacher.CHAP{}
+	chap.User, _ = config["chapUser"].(string)
+	chap.Password, _ = config["chapPassword"].(string)
+	chap.MutualUser, _ = config["chapMutualUser"].(string)
+	chap.MutualPassword, _ = config["chapMutualPassword"].(string)
+
+	if (chap.User == "") != (chap.Password == "") {
+		return nil, errors.New("chapUser and chapPassword must be provided together")
+	}
+	if (chap.MutualUser == "") != (chap.MutualPassword == "") {
+		return nil, errors.New("chapMutualUser and chapMutualPassword must be provided together")
+	}
+	if chap.MutualUser != "" && chap.User == "" {
+		return nil, errors.New("the mutual CHAP requires chapUser and chapPassword")
+	}
+
+	if chap.User == "" {
+		return nil, nil
+	}
+	return chap, nil
+}
+
+func isOceanstorSanProtocol(protocol string) bool {
+	return protocol == "iscsi" || protocol == "fc" || protocol == "roce" || protocol == "fc-nvme"
+}
+
+// getFallbackProtocols returns the protocols which are used in order by the nodes where the
+// configured protocol is not usable
+func getFallbackProtocols(parameters map[string]interface{}) ([]string, error) {
+	fallbacks, exist := parameters["fallbackProtocols"].([]interface{})
+	if !exist {
+		return nil, nil
+	}
+
+	var protocols []string
+	for _, i := range fallbacks {
+		protocol, ok := i.(string)
+		if !ok || !isOceanstorSanProtocol(protocol) {
+			return nil, fmt.Errorf("fallback protocol %v is invalid, "+
+				"must be 'iscsi', 'fc', 'roce' or 'fc-nvme'", i)
+		}
+		protocols = append(protocols, protocol)
+	}
+
+	return protocols, nil
+}
+
+// getNVMeTransportOptions returns the validated nvmeTransport parameter, which is only used by RoCE
+func getNVMeTransportOptions(parameters map[string]interface{},
+	protocols []string) (*connector.NVMeTransportOptions, error) {
+	config, exist := parameters["nvmeTransport"].(map[string]interface{})
+	if !exist {
+		return nil, nil
+	}
+
+	if !utils.IsContain("roce", protocols) {
+		return nil, errors.New("nvmeTransport is only supported by the roce protocol")
+	}
+
+	options, err := connector.ParseNVMeTransportOptions(config, connector.NVMeTransportRDMA)
+	if err != nil {
+		return nil, fmt.Errorf("nvmeTransport is invalid: %v", err)
+	}
+	return options, nil
+}
+
+// hasInitiator checks whether the node has an initiator of the protocol
+var hasInitiator = func(ctx context.Context, protocol string) bool {
+	var err error
+	switch protocol {
+	case "iscsi":
+		_, err = proto.GetISCSIInitiator(ctx)
+	case "fc", "fc-nvme":
+		var initiators []string
+		initiators, err = proto.GetFCInitiator(ctx)
+		if err == nil && len(initiators) == 0 {
+			err = errors.New("no FC initiator exists")
+		}
+	case "roce":
+		_, err = proto.GetRoCEInitiator(ctx)
+	default:
+		err = fmt.Errorf("unsupported protocol %s", protocol)
+	}
+
+	return err == nil
+}
+
+// negotiateProtocol returns the first protocol whose tools and initiators are available on
+// the node. The first protocol is returned if none of them is usable, so that attaching
+// reports why it is not.
+func negotiateProtocol(ctx context.Context, protocols []string) string {
+	for _, protocol := range protocols {
+		if connector.VerifyProtocol(ctx, protocol) != nil || !hasInitiator(ctx, protocol) {
+			log.AddContext(ctx).Infof("Protocol %s is not usable on this node", protocol)
+			continue
+		}
+
+		log.AddContext(ctx).Infof("Negotiate protocol %s from %v", protocol, protocols)
+		return protocol
+	}
+
+	log.AddContext(ctx).Warningf("None of protocols %v is usable on this node", protocols)
+	return protocols[0]
+}
+
 func (p *OceanstorSanPlugin) getSanObj() *volume.SAN {
 	var metroRemoteCli client.BaseClientInterface
 	var replicaRemoteCli client.BaseClientInterface
@@ -126,15 +301,31 @@ func (p *OceanstorSanPlugin) CreateVolume(ctx context.Context,
 	name string,
 	parameters map[string]interface{}) (utils.Volume, error) {
 	size, ok := parameters["size"].(int64)
-	if !ok || !utils.IsCapacityAvailable(size, SectorSize) {
+	if !ok || !utils.Capacity(size).IsSectorAligned() {
 		msg := fmt.Sprintf("Create Volume: the capacity %d is not an integer multiple of 512.", size)
 		log.AddContext(ctx).Errorln(msg)
 		return nil, errors.New(msg)
 	}
 
 	params := p.getParams(ctx, name, parameters)
-	san := p.getSanObj()
+	p.setDataReductionParams(ctx, params, parameters, "")
+	if err := p.checkHyperMetroOptions(ctx, params, false); err != nil {
+		return nil, err
+	}
+	if err := p.checkReplicationOptions(ctx, params); err != nil {
+		return nil, err
+	}
+	// The LUNs are cloned by clone pairs on Dorado V6
+	_, cloneExist := params["clonefrom"]
+	_, srcVolumeExist := params["sourcevolumename"]
+	_, srcSnapshotExist := params["sourcesnapshotname"]
+	if cloneExist || srcVolumeExist || srcSnapshotExist {
+		if err := p.checkFirmware(ctx, ClonePairFeature); err != nil {
+			return nil, err
+		}
+	}
 
+	san := p.getSanObj()
 	volObl, err := san.Create(ctx, params)
 	if err != nil {
 		return nil, err
@@ -144,21 +335,82 @@ func (p *OceanstorSanPlugin) CreateVolume(ctx context.Context,
 
 func (p *OceanstorSanPlugin) DeleteVolume(ctx context.Context, name string) error {
 	san := p.getSanObj()
+	if p.recycleBinRetention > 0 {
+		return san.Recycle(ctx, name)
+	}
 	return san.Delete(ctx, name)
 }
 
+// PurgeRecycledVolumes deletes the LUNs kept in the recycle bin longer than the retention
+func (p *OceanstorSanPlugin) PurgeRecycledVolumes(ctx context.Context) error {
+	if p.recycleBinRetention <= 0 {
+		return nil
+	}
+
+	san := p.getSanObj()
+	return san.PurgeRecycled(ctx, p.recycleBinRetention)
+}
+
 func (p *OceanstorSanPlugin) ExpandVolume(ctx context.Context, name string, size int64) (bool, error) {
-	if !utils.IsCapacityAvailable(size, SectorSize) {
+	if !utils.Capacity(size).IsSectorAligned() {
 		msg := fmt.Sprintf("Expand Volume: the capacity %d is not an integer multiple of 512.", size)
 		log.AddContext(ctx).Errorln(msg)
 		return false, errors.New(msg)
 	}
 	san := p.getSanObj()
-	newSize := utils.TransVolumeCapacity(size, 512)
+	newSize := utils.Capacity(size).Sectors()
 	isAttach, err := san.Expand(ctx, name, newSize)
 	return isAttach, err
 }
 
+// GetArrayID returns the ID of the array and the vStore of the backend
+func (p *OceanstorSanPlugin) GetArrayID() string {
+	return p.arrayID
+}
+
+// MigrateVolume starts moving the LUN to the storage pool by SmartMigration
+func (p *OceanstorSanPlugin) MigrateVolume(ctx context.Context, name, pool string, speed int) error {
+	san := p.getSanObj()
+	return san.Migrate(ctx, name, pool, speed)
+}
+
+// IsMigrating returns whether the LUN is still moving to the storage pool
+func (p *OceanstorSanPlugin) IsMigrating(ctx context.Context, name, pool string) (bool, error) {
+	san := p.getSanObj()
+	return san.IsMigrating(ctx, name, pool)
+}
+
+// UpdateQoS sets the QoS parameters of the LUN
+func (p *OceanstorSanPlugin) UpdateQoS(ctx context.Context, name, qosConfig string) error {
+	qos, err := p.parseQoS(ctx, qosConfig)
+	if err != nil {
+		return err
+	}
+
+	san := p.getSanObj()
+	return san.UpdateQoS(ctx, name, qos)
+}
+
+// UpdateSmartTier sets the SmartTier relocation policy of the LUN, which only the hybrid arrays support
+func (p *OceanstorSanPlugin) UpdateSmartTier(ctx context.Context, name, policy string) error {
+	if supported, _ := p.capabilities["SupportSmartTier"].(bool); !supported {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"SmartTier is not supported by the storage %s, it needs a hybrid array with SmartTier licensed",
+			p.product)
+	}
+
+	san := p.getSanObj()
+	return san.UpdateSmartTier(ctx, name, policy)
+}
+
+// CompleteClone checks the clone pair or luncopy of a LUN created by asynchronous copy, which is deleted
+// once the data is copied
+func (p *OceanstorSanPlugin) CompleteClone(ctx context.Context, name string) (CloneProgress, error) {
+	san := p.getSanObj()
+	progress, err := san.CompleteCopy(ctx, name)
+	return CloneProgress(progress), err
+}
+
 func (p *OceanstorSanPlugin) isHyperMetro(lun map[string]interface{}) bool {
 	var rss map[string]string
 	rssStr := lun["HASRSSOBJECT"].(string)
@@ -189,11 +441,12 @@ func (p *OceanstorSanPlugin) metroHandler(ctx context.Context, req handlerReques
 		}
 	}
 
-	localAttacher := attacher.NewAttacher(p.product, req.localCli, p.protocol, "csi", p.portals, p.alua)
+	localAttacher := attacher.NewAttacher(p.product, req.localCli, p.protocol, "csi", p.portals, p.alua, p.chap)
 	remoteAttacher := attacher.NewAttacher(p.metroRemotePlugin.product, req.metroCli, p.metroRemotePlugin.protocol,
-		"csi", p.metroRemotePlugin.portals, p.metroRemotePlugin.alua)
+		"csi", p.metroRemotePlugin.portals, p.metroRemotePlugin.alua, p.metroRemotePlugin.chap)
 
 	metroAttacher := attacher.NewMetroAttacher(localAttacher, remoteAttacher, p.protocol)
+	metroAttacher.SetPathPreference(p.metroPreferredHosts, p.metroRemotePlugin.metroPreferredHosts)
 	lunName := req.lun["NAME"].(string)
 	out := utils.ReflectCall(metroAttacher, req.method, ctx, lunName, req.parameters)
 
@@ -204,7 +457,7 @@ func (p *OceanstorSanPlugin) commonHandler(ctx context.Context,
 	plugin *OceanstorSanPlugin, lun, parameters map[string]interface{},
 	method string) ([]reflect.Value, error) {
 	commonAttacher := attacher.NewAttacher(plugin.product, plugin.cli, plugin.protocol, "csi",
-		plugin.portals, plugin.alua)
+		plugin.portals, plugin.alua, plugin.chap)
 
 	lunName, ok := lun["NAME"].(string)
 	if !ok {
@@ -276,6 +529,95 @@ func (p *OceanstorSanPlugin) DetachVolume(ctx context.Context, name string, para
 	return nil
 }
 
+// FenceVolume unmaps the LUN from the lost node in parameters. Unlike DetachVolume, which detaches a
+// HyperMetro LUN from the storage online only, both storages must be online, since the node could still
+// write the LUN through the storage whose mapping is left.
+func (p *OceanstorSanPlugin) FenceVolume(ctx context.Context, name string, parameters map[string]interface{}) error {
+	lunName := utils.GetLunName(name)
+	if !p.storageOnline {
+		return utils.Errorf(ctx, "storage of LUN %s is offline, the node cannot be fenced off it", lunName)
+	}
+
+	lun, err := p.cli.GetLunByName(ctx, lunName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun %s error: %v", lunName, err)
+		return err
+	}
+	if lun == nil {
+		log.AddContext(ctx).Warningf("LUN %s to fence doesn't exist", lunName)
+		return nil
+	}
+
+	plugins := []*OceanstorSanPlugin{p}
+	if p.isHyperMetro(lun) {
+		if p.metroRemotePlugin == nil || !p.metroRemotePlugin.storageOnline {
+			return utils.Errorf(ctx, "remote storage of hypermetro LUN %s is offline, "+
+				"the node cannot be fenced off it", lunName)
+		}
+		plugins = append(plugins, p.metroRemotePlugin)
+	}
+
+	for _, plugin := range plugins {
+		fenceAttacher := attacher.NewAttacher(plugin.product, plugin.cli, plugin.protocol, "csi",
+			plugin.portals, plugin.alua, plugin.chap)
+		_, err = fenceAttacher.ControllerDetach(ctx, lunName, parameters)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Fence node off LUN %s error: %v", lunName, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetOtherMappedHosts returns the hostnames of the nodes other than the node in parameters,
+// which the volume is mapped to
+func (p *OceanstorSanPlugin) GetOtherMappedHosts(ctx context.Context,
+	name string,
+	parameters map[string]interface{}) ([]string, error) {
+	cli, metroCli, err := p.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.releaseClient(ctx, cli, metroCli)
+
+	lunName := utils.GetLunName(name)
+	lun, err := p.getLunInfo(ctx, cli, metroCli, lunName)
+	if err != nil {
+		return nil, err
+	}
+	if lun == nil {
+		return nil, nil
+	}
+
+	out, err := p.handler(ctx, handlerRequest{localCli: cli, metroCli: metroCli,
+		lun: lun, parameters: parameters, method: "GetOtherMappedHosts"})
+	if err != nil {
+		return nil, err
+	}
+	if len(out) != reflectResultLength {
+		return nil, fmt.Errorf("get mapped hosts of volume %s error", lunName)
+	}
+
+	result := out[1].Interface()
+	if result != nil {
+		return nil, result.(error)
+	}
+
+	hosts, _ := out[0].Interface().([]string)
+	return hosts, nil
+}
+
+// QueryVolumeState returns the state of the LUN on storage
+func (p *OceanstorSanPlugin) QueryVolumeState(ctx context.Context, name string) (*VolumeState, error) {
+	lun, err := p.cli.GetLunByName(ctx, utils.GetLunName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return p.getVolumeState(lun)
+}
+
 func (p *OceanstorSanPlugin) mutexReleaseClient(ctx context.Context,
 	plugin *OceanstorSanPlugin,
 	cli client.BaseClientInterface) {
@@ -352,15 +694,33 @@ func (p *OceanstorSanPlugin) getStageVolumeInfo(ctx context.Context,
 func (p *OceanstorSanPlugin) StageVolume(ctx context.Context,
 	name string,
 	parameters map[string]interface{}) error {
+	err := connector.VerifyProtocol(ctx, p.protocol)
+	if err != nil {
+		return err
+	}
+
+	if p.nvmeTransport != nil {
+		parameters["nvmeTransport"] = p.nvmeTransport
+	}
+	if p.recommendedALUA {
+		parameters["recommendedALUA"] = true
+	}
+
 	connectInfo, err := p.getStageVolumeInfo(ctx, name, parameters)
 	if err != nil {
 		return err
 	}
+
+	err = connector.CheckAttachLimit(ctx, p.protocol, getMappingLunWWN(connectInfo))
+	if err != nil {
+		return err
+	}
+
 	devPath, err := p.lunConnectVolume(ctx, connectInfo)
 	if err != nil {
 		return err
 	}
-	return p.lunStageVolume(ctx, name, devPath, parameters)
+	return p.lunStageVolume(ctx, name, devPath, getMappingLunWWN(connectInfo), parameters)
 }
 
 func (p *OceanstorSanPlugin) getUnStageVolumeInfo(ctx context.Context,
@@ -409,11 +769,20 @@ func (p *OceanstorSanPlugin) getUnStageVolumeInfo(ctx context.Context,
 func (p *OceanstorSanPlugin) UnstageVolume(ctx context.Context,
 	name string,
 	parameters map[string]interface{}) error {
+	if p.reclaimSpace {
+		reclaimSpace(ctx, name, parameters)
+	}
+
 	err := p.unstageVolume(ctx, name, parameters)
 	if err != nil {
 		return err
 	}
 
+	err = connector.CloseLUKSDevice(ctx, name)
+	if err != nil {
+		return err
+	}
+
 	disconnectInfo, err := p.getUnStageVolumeInfo(ctx, name, parameters)
 	if err != nil {
 		return err
@@ -471,6 +840,11 @@ func (p *OceanstorSanPlugin) NodeExpandVolume(ctx context.Context,
 		return err
 	}
 
+	err = connector.ResizeLUKSDevice(ctx, name)
+	if err != nil {
+		return err
+	}
+
 	if !isBlock {
 		err = connector.ResizeMountPath(ctx, volumePath)
 		if err != nil {
@@ -508,6 +882,61 @@ func (p *OceanstorSanPlugin) DeleteSnapshot(ctx context.Context,
 	return nil
 }
 
+// CreateTypedSnapshot creates a HyperCDP object of the LUN if the type is hypercdp
+func (p *OceanstorSanPlugin) CreateTypedSnapshot(ctx context.Context,
+	lunName, snapshotName, snapshotType string) (map[string]interface{}, error) {
+	if snapshotType != HyperCDPSnapshotType {
+		return nil, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"Snapshot type %s is not supported by OceanStor SAN", snapshotType)
+	}
+
+	err := p.checkFirmware(ctx, HyperCDPFeature)
+	if err != nil {
+		return nil, err
+	}
+
+	san := p.getSanObj()
+	return san.CreateHyperCDP(ctx, lunName, utils.GetSnapshotName(snapshotName))
+}
+
+// QuerySnapshot returns the LUN snapshot on storage, nil if it does not exist
+func (p *OceanstorSanPlugin) QuerySnapshot(ctx context.Context,
+	parentID, snapshotName string) (map[string]interface{}, error) {
+	san := p.getSanObj()
+	return san.QuerySnapshot(ctx, parentID, utils.GetSnapshotName(snapshotName))
+}
+
+// ListSnapshots returns a page of the snapshots of the LUN, or of all the LUNs if name is empty
+func (p *OceanstorSanPlugin) ListSnapshots(ctx context.Context, name string, offset, limit int) (
+	[]map[string]interface{}, int, error) {
+	var lunName string
+	if name != "" {
+		lunName = utils.GetLunName(name)
+	}
+
+	san := p.getSanObj()
+	return san.ListSnapshots(ctx, lunName, offset, limit)
+}
+
+// RollbackSnapshot starts rolling the LUN back to its snapshot
+func (p *OceanstorSanPlugin) RollbackSnapshot(ctx context.Context,
+	name, snapshotParentID, snapshotName string, speed int) (int64, error) {
+	san := p.getSanObj()
+	size, err := san.RollbackSnapshot(ctx, utils.GetLunName(name), snapshotParentID,
+		utils.GetSnapshotName(snapshotName), speed)
+	if err != nil {
+		return 0, err
+	}
+
+	return size.Bytes(), nil
+}
+
+// IsRollingBack returns whether the LUN is still rolling back to its snapshot
+func (p *OceanstorSanPlugin) IsRollingBack(ctx context.Context, snapshotParentID, snapshotName string) (bool, error) {
+	san := p.getSanObj()
+	return san.IsRollingBack(ctx, utils.GetSnapshotName(snapshotName))
+}
+
 func (p *OceanstorSanPlugin) mutexGetClient(ctx context.Context) (client.BaseClientInterface, error) {
 	p.clientMutex.Lock()
 	defer p.clientMutex.Unlock()
@@ -606,3 +1035,82 @@ func (p *OceanstorSanPlugin) UnstageVolumeWithWWN(ctx context.Context, tgtLunWWN
 	}
 	return conn.DisConnectVolume(ctx, tgtLunWWN)
 }
+
+func getLunNames(volumes []string) []string {
+	lunNames := make([]string, 0, len(volumes))
+	for _, volume := range volumes {
+		lunNames = append(lunNames, utils.GetLunName(volume))
+	}
+	return lunNames
+}
+
+// EnsureProtectionGroup creates the protection group if it does not exist, and adds the LUNs to it
+func (p *OceanstorSanPlugin) EnsureProtectionGroup(ctx context.Context, group string, volumes []string) error {
+	err := p.checkFirmware(ctx, ProtectionGroupFeature)
+	if err != nil {
+		return err
+	}
+
+	san := p.getSanObj()
+	return san.EnsureProtectGroup(ctx, group, getLunNames(volumes))
+}
+
+// RemoveFromProtectionGroup removes the LUNs from the protection group
+func (p *OceanstorSanPlugin) RemoveFromProtectionGroup(ctx context.Context, group string, volumes []string) error {
+	san := p.getSanObj()
+	return san.RemoveFromProtectGroup(ctx, group, getLunNames(volumes))
+}
+
+// DeleteProtectionGroup deletes the protection group and its replication group
+func (p *OceanstorSanPlugin) DeleteProtectionGroup(ctx context.Context, group string, volumes []string) error {
+	san := p.getSanObj()
+	return san.DeleteProtectGroup(ctx, group, getLunNames(volumes))
+}
+
+// CreateGroupSnapshot snapshots the LUNs of the protection group with a snapshot consistency group
+func (p *OceanstorSanPlugin) CreateGroupSnapshot(ctx context.Context,
+	group, snapshot string, volumes []string) (map[string]string, error) {
+	err := p.checkFirmware(ctx, ProtectionGroupFeature)
+	if err != nil {
+		return nil, err
+	}
+
+	san := p.getSanObj()
+	members, err := san.CreateGroupSnapshot(ctx, group, utils.GetSnapshotName(snapshot))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make(map[string]string, len(volumes))
+	for _, volume := range volumes {
+		for _, member := range members {
+			if member.ParentName == utils.GetLunName(volume) {
+				snapshots[volume] = member.ParentID + "." + member.Name
+			}
+		}
+	}
+	return snapshots, nil
+}
+
+// DeleteGroupSnapshot deletes the snapshot consistency group with the snapshots of the LUNs
+func (p *OceanstorSanPlugin) DeleteGroupSnapshot(ctx context.Context, snapshot string, _ []string) error {
+	san := p.getSanObj()
+	return san.DeleteGroupSnapshot(ctx, utils.GetSnapshotName(snapshot))
+}
+
+// EnsureReplicationGroup puts the replication pairs of the LUNs in a replication consistency group
+func (p *OceanstorSanPlugin) EnsureReplicationGroup(ctx context.Context, group string, volumes []string) error {
+	err := p.checkFirmware(ctx, ProtectionGroupFeature)
+	if err != nil {
+		return err
+	}
+
+	san := p.getSanObj()
+	return san.EnsureReplicationGroup(ctx, group, getLunNames(volumes))
+}
+
+// GetCapacityAlarms returns the capacity alarms of the LUN and of its storage pool
+func (p *OceanstorSanPlugin) GetCapacityAlarms(ctx context.Context, name string) (map[string]string, error) {
+	san := p.getSanObj()
+	return san.GetCapacityAlarms(ctx, name)
+}
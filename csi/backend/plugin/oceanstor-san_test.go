@@ -0,0 +1,191 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/storage/oceanstor/volume"
+	"huawei-csi-driver/utils"
+)
+
+func TestNegotiateProtocol(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name       string
+		initiators []string
+		expect     string
+	}{
+		{"PrimaryUsable", []string{"fc", "iscsi"}, "fc"},
+		{"FallbackUsable", []string{"iscsi"}, "iscsi"},
+		{"NoneUsable", []string{}, "fc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubs := gostub.Stub(&hasInitiator, func(ctx context.Context, protocol string) bool {
+				for _, initiator := range tt.initiators {
+					if initiator == protocol {
+						return true
+					}
+				}
+				return false
+			})
+			defer stubs.Reset()
+
+			assert.Equal(t, tt.expect, negotiateProtocol(ctx, []string{"fc", "iscsi"}))
+		})
+	}
+}
+
+func TestGetFallbackProtocols(t *testing.T) {
+	protocols, err := getFallbackProtocols(map[string]interface{}{
+		"fallbackProtocols": []interface{}{"iscsi", "roce"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"iscsi", "roce"}, protocols)
+
+	_, err = getFallbackProtocols(map[string]interface{}{"fallbackProtocols": []interface{}{"nfs"}})
+	assert.Error(t, err)
+}
+
+func TestGetNVMeTransportOptions(t *testing.T) {
+	config := map[string]interface{}{"nvmeTransport": map[string]interface{}{"queueSize": float64(128)}}
+	options, err := getNVMeTransportOptions(config, []string{"roce"})
+	assert.NoError(t, err)
+	assert.Equal(t, 128, options.QueueSize)
+
+	_, err = getNVMeTransportOptions(config, []string{"iscsi"})
+	assert.Error(t, err)
+
+	options, err = getNVMeTransportOptions(map[string]interface{}{}, []string{"roce"})
+	assert.NoError(t, err)
+	assert.Nil(t, options)
+}
+
+func TestGetMissingApplicationType(t *testing.T) {
+	missing, err := getMissingApplicationType(map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Equal(t, volume.MissingApplicationTypeFail, missing)
+
+	missing, err = getMissingApplicationType(map[string]interface{}{"missingApplicationType": "create"})
+	assert.NoError(t, err)
+	assert.Equal(t, volume.MissingApplicationTypeCreate, missing)
+
+	_, err = getMissingApplicationType(map[string]interface{}{"missingApplicationType": "ignore"})
+	assert.Error(t, err)
+}
+
+func TestGetHyperMetroOptions(t *testing.T) {
+	options, err := getHyperMetroOptions(map[string]interface{}{"hyperMetroSpeed": "low"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"hyperMetroSpeed": "low"}, options)
+
+	_, err = getHyperMetroOptions(map[string]interface{}{"hyperMetroSyncPolicy": "never"})
+	assert.Error(t, err)
+}
+
+func TestCheckHyperMetroOptions(t *testing.T) {
+	ctx := context.Background()
+	p := &OceanstorPlugin{product: utils.OceanStorDoradoV6, firmware: "6.1.2"}
+	fullSync := map[string]interface{}{"hypermetro": true, "hypermetrosyncpolicy": "full"}
+	assert.NoError(t, p.checkHyperMetroOptions(ctx, fullSync, false))
+	assert.Error(t, p.checkHyperMetroOptions(ctx, fullSync, true))
+
+	p.firmware = "6.0.1"
+	assert.Error(t, p.checkHyperMetroOptions(ctx, map[string]interface{}{"hypermetro": true,
+		"hypermetrospeed": "low"}, false))
+	assert.NoError(t, p.checkHyperMetroOptions(ctx, map[string]interface{}{"hypermetro": true}, false))
+}
+
+func TestCheckReplicationOptions(t *testing.T) {
+	ctx := context.Background()
+	p := &OceanstorPlugin{product: utils.OceanStorDoradoV6, firmware: "6.1.2"}
+	syncModel := map[string]interface{}{"replication": true, "replicationModel": "sync"}
+	assert.NoError(t, p.checkReplicationOptions(ctx, syncModel))
+
+	p.firmware = "6.0.1"
+	assert.Error(t, p.checkReplicationOptions(ctx, syncModel))
+	assert.NoError(t, p.checkReplicationOptions(ctx, map[string]interface{}{"replication": true}))
+	assert.NoError(t, p.checkReplicationOptions(ctx, map[string]interface{}{"replicationModel": "sync"}))
+}
+
+func TestGetReplicationLinkLatency(t *testing.T) {
+	latency, err := getReplicationLinkLatency(map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Equal(t, "low", latency)
+
+	_, err = getReplicationLinkLatency(map[string]interface{}{"replicationLinkLatency": "medium"})
+	assert.Error(t, err)
+
+	ctx := context.Background()
+	p := &OceanstorPlugin{product: utils.OceanStorDoradoV6, firmware: "6.1.2", replicationLinkLatency: "high"}
+	assert.Error(t, p.checkReplicationOptions(ctx, map[string]interface{}{"replication": true,
+		"replicationModel": "sync"}))
+	assert.NoError(t, p.checkReplicationOptions(ctx, map[string]interface{}{"replication": true,
+		"replicationModel": "async"}))
+}
+
+func TestGetRecycleBinRetention(t *testing.T) {
+	retention, err := getRecycleBinRetention(map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), retention)
+
+	retention, err = getRecycleBinRetention(map[string]interface{}{"recycleBinRetention": "72h"})
+	assert.NoError(t, err)
+	assert.Equal(t, 72*time.Hour, retention)
+
+	for _, value := range []interface{}{"3d", "-1h", "0s", 72} {
+		_, err = getRecycleBinRetention(map[string]interface{}{"recycleBinRetention": value})
+		assert.Error(t, err, value)
+	}
+}
+
+func TestGetChap(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    map[string]interface{}
+		expectNil bool
+		expectErr bool
+	}{
+		{"NoChap", map[string]interface{}{}, true, false},
+		{"OneWay", map[string]interface{}{"chapUser": "user", "chapPassword": "pwd"}, false, false},
+		{"Mutual", map[string]interface{}{"chapUser": "user", "chapPassword": "pwd",
+			"chapMutualUser": "target", "chapMutualPassword": "targetPwd"}, false, false},
+		{"MissingPassword", map[string]interface{}{"chapUser": "user"}, true, true},
+		{"MutualWithoutOneWay", map[string]interface{}{"chapMutualUser": "target",
+			"chapMutualPassword": "targetPwd"}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chap, err := getChap(tt.config)
+			assert.Equal(t, tt.expectErr, err != nil)
+			assert.Equal(t, tt.expectNil, chap == nil)
+		})
+	}
+}
+
+func TestFenceVolumeStorageOffline(t *testing.T) {
+	// the node cannot be fenced off a LUN whose storage is not reachable
+	p := &OceanstorSanPlugin{}
+	assert.Error(t, p.FenceVolume(context.Background(), "pvc-1", map[string]interface{}{"HostName": "node1"}))
+}
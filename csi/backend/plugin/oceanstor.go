@@ -19,26 +19,186 @@ package plugin
 import (
 	"context"
 	"errors"
-	"strconv"
+	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"huawei-csi-driver/storage/oceanstor/client"
 	"huawei-csi-driver/storage/oceanstor/clientv6"
 	"huawei-csi-driver/storage/oceanstor/smartx"
+	"huawei-csi-driver/storage/oceanstor/volume"
 	"huawei-csi-driver/utils"
 	"huawei-csi-driver/utils/log"
 )
 
 const (
 	DORADO_V6_POOL_USAGE_TYPE = "0"
+
+	healthStatusNormal = "1"
 )
 
+var healthStatusDescriptions = map[string]string{
+	"2": "fault",
+	"3": "about to fail",
+	"5": "degraded",
+}
+
 type OceanstorPlugin struct {
 	basePlugin
 
-	cli          client.BaseClientInterface
-	product      string
+	cli     client.BaseClientInterface
+	product string
+	// firmware is the firmware version of the array, empty if the array does not report it
+	firmware     string
 	capabilities map[string]interface{}
+	// arrayID is the serial number of the array followed by the vStore of the backend
+	arrayID string
+	// missingApplicationType is how the applicationType missing on storage is handled
+	missingApplicationType string
+	// hyperMetroOptions are the HyperMetro parameters of the backend, which the StorageClass overrides
+	hyperMetroOptions map[string]string
+	// hyperMetroGroup is the HyperMetro consistency group of the LUNs of the backend, which the
+	// StorageClass overrides
+	hyperMetroGroup string
+	// replicationLinkLatency is the latency class of the links to the replication array of the backend
+	replicationLinkLatency string
+	// recycleBinRetention is how long the deleted volumes are kept in the recycle bin, 0 if they are
+	// destroyed at once
+	recycleBinRetention time.Duration
+	// keepLogin is whether the client stays logged in after Init, which it does not on the nodes
+	keepLogin bool
+	// nodeClientMutex guards nodeClientCount, the number of the node requests using the client, which
+	// is logged out once the last one is done
+	nodeClientMutex sync.Mutex
+	nodeClientCount int
+}
+
+// getHyperMetroOptions returns the HyperMetro parameters of the backend, see volume.HyperMetroOptionKeys
+func getHyperMetroOptions(parameters map[string]interface{}) (map[string]string, error) {
+	options := map[string]string{}
+	for _, key := range volume.HyperMetroOptionKeys {
+		value, exist := parameters[key]
+		if !exist {
+			continue
+		}
+
+		option, _ := value.(string)
+		if err := volume.CheckHyperMetroOption(key, option); err != nil {
+			return nil, err
+		}
+		options[key] = option
+	}
+	return options, nil
+}
+
+// checkHyperMetroOptions checks the HyperMetro parameters of a HyperMetro volume are supported by the array.
+// The filesystem HyperMetro pairs of Dorado V6 are not synchronized after they are created.
+func (p *OceanstorPlugin) checkHyperMetroOptions(ctx context.Context, params map[string]interface{},
+	fileSystem bool) error {
+	if hyperMetro, _ := params["hypermetro"].(bool); !hyperMetro {
+		return nil
+	}
+
+	customized := false
+	for _, key := range volume.HyperMetroOptionKeys {
+		if _, exist := params[strings.ToLower(key)]; exist {
+			customized = true
+		}
+	}
+	if !customized {
+		return nil
+	}
+
+	if err := p.checkFirmware(ctx, HyperMetroOptionsFeature); err != nil {
+		return err
+	}
+
+	if fileSystem && p.product == utils.OceanStorDoradoV6 &&
+		params["hypermetrosyncpolicy"] == volume.HyperMetroSyncPolicyFull {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"hyperMetroSyncPolicy %s is not supported by the filesystems of %s", volume.HyperMetroSyncPolicyFull,
+			p.product)
+	}
+	return nil
+}
+
+// checkReplicationOptions checks the replication parameters of a replication volume are supported by the
+// array and the links to the replication array. The replication pairs are created asynchronous at the
+// highest speed unless they are set.
+func (p *OceanstorPlugin) checkReplicationOptions(ctx context.Context, params map[string]interface{}) error {
+	if replication, _ := params["replication"].(bool); !replication {
+		return nil
+	}
+
+	if params["replicationModel"] == volume.ReplicationModelSync &&
+		p.replicationLinkLatency == volume.ReplicationLinkLatencyHigh {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"replicationModel %s is not allowed by the replicationLinkLatency %s of the backend",
+			volume.ReplicationModelSync, p.replicationLinkLatency)
+	}
+
+	customized := false
+	for _, key := range volume.ReplicationOptionKeys {
+		if _, exist := params[key]; exist {
+			customized = true
+		}
+	}
+	if !customized {
+		return nil
+	}
+
+	return p.checkFirmware(ctx, ReplicationOptionsFeature)
+}
+
+// getMissingApplicationType returns the missingApplicationType backend parameter, which is one of fail,
+// create and default
+func getMissingApplicationType(parameters map[string]interface{}) (string, error) {
+	value, exist := parameters["missingApplicationType"]
+	if !exist {
+		return volume.MissingApplicationTypeFail, nil
+	}
+
+	missing, _ := value.(string)
+	switch missing {
+	case volume.MissingApplicationTypeFail, volume.MissingApplicationTypeCreate, volume.MissingApplicationTypeDefault:
+		return missing, nil
+	}
+	return "", fmt.Errorf("missingApplicationType %v must be %s, %s or %s", value,
+		volume.MissingApplicationTypeFail, volume.MissingApplicationTypeCreate, volume.MissingApplicationTypeDefault)
+}
+
+// getReplicationLinkLatency returns the latency class of the links to the replication array, which
+// decides whether synchronous replication is allowed
+func getReplicationLinkLatency(parameters map[string]interface{}) (string, error) {
+	value, exist := parameters["replicationLinkLatency"]
+	if !exist {
+		return volume.ReplicationLinkLatencyLow, nil
+	}
+
+	latency, _ := value.(string)
+	switch latency {
+	case volume.ReplicationLinkLatencyLow, volume.ReplicationLinkLatencyHigh:
+		return latency, nil
+	}
+	return "", fmt.Errorf("replicationLinkLatency %v must be %s or %s", value, volume.ReplicationLinkLatencyLow,
+		volume.ReplicationLinkLatencyHigh)
+}
+
+// getRecycleBinRetention returns how long the deleted volumes are kept in the recycle bin before purged,
+// 0 if the recycle bin is disabled
+func getRecycleBinRetention(parameters map[string]interface{}) (time.Duration, error) {
+	value, exist := parameters["recycleBinRetention"]
+	if !exist {
+		return 0, nil
+	}
+
+	str, _ := value.(string)
+	retention, err := time.ParseDuration(str)
+	if err != nil || retention <= 0 {
+		return 0, fmt.Errorf("recycleBinRetention %v must be a positive duration, e.g. 72h", value)
+	}
+	return retention, nil
 }
 
 func (p *OceanstorPlugin) init(config map[string]interface{}, keepLogin bool) error {
@@ -83,6 +243,12 @@ func (p *OceanstorPlugin) init(config map[string]interface{}, keepLogin bool) er
 		return err
 	}
 
+	p.firmware = getFirmware(system)
+	sn, _ := system["ID"].(string)
+	p.arrayID = sn + "/" + vstoreName
+	logUnsupportedFeatures(p.product, p.firmware)
+
+	p.keepLogin = keepLogin
 	if !keepLogin {
 		cli.Logout(context.Background())
 	}
@@ -114,6 +280,12 @@ func (p *OceanstorPlugin) UpdateBackendCapabilities() (map[string]interface{}, e
 	supportReplication := utils.IsSupportFeature(features, "HyperReplication")
 	supportClone := utils.IsSupportFeature(features, "HyperClone") || utils.IsSupportFeature(features, "HyperCopy")
 	supportApplicationType := p.product == "DoradoV6"
+	supportSmartCache := utils.IsSupportFeature(features, "SmartCache")
+	supportSmartTier := supportThick && utils.IsSupportFeature(features, "SmartTier")
+	supportDedupe := utils.IsSupportFeature(features, "SmartDedupe (for LUN)")
+	supportDedupeNAS := utils.IsSupportFeature(features, "SmartDedupe (for FS)")
+	supportCompression := utils.IsSupportFeature(features, "SmartCompression (for LUN)")
+	supportCompressionNAS := utils.IsSupportFeature(features, "SmartCompression (for FS)")
 
 	capabilities := map[string]interface{}{
 		"SupportThin":            supportThin,
@@ -124,6 +296,12 @@ func (p *OceanstorPlugin) UpdateBackendCapabilities() (map[string]interface{}, e
 		"SupportApplicationType": supportApplicationType,
 		"SupportClone":           supportClone,
 		"SupportMetroNAS":        supportMetroNAS,
+		"SupportSmartCache":      supportSmartCache,
+		"SupportSmartTier":       supportSmartTier,
+		"SupportDedupe":          supportDedupe,
+		"SupportDedupeNAS":       supportDedupeNAS,
+		"SupportCompression":     supportCompression,
+		"SupportCompressionNAS":  supportCompressionNAS,
 	}
 
 	p.capabilities = capabilities
@@ -135,8 +313,8 @@ func (p *OceanstorPlugin) getParams(ctx context.Context, name string,
 
 	params := map[string]interface{}{
 		"name":        name,
-		"description": "Created from Kubernetes CSI",
-		"capacity":    utils.RoundUpSize(parameters["size"].(int64), 512),
+		"description": utils.GetVolumeDescription(name),
+		"capacity":    utils.Capacity(parameters["size"].(int64)).Sectors(),
 	}
 
 	paramKeys := []string{
@@ -152,10 +330,26 @@ func (p *OceanstorPlugin) getParams(ctx context.Context, name string,
 		"sourceVolumeName",
 		"snapshotParentId",
 		"applicationType",
+		"cachePartition",
 		"allSquash",
 		"rootSquash",
+		"anonUid",
+		"anonGid",
+		"nfsAclMode",
+		"nfsSecurityFlavors",
+		"sectorSize",
 		"fsPermission",
 		"snapshotDirectoryVisibility",
+		"poolReserve",
+		"hintApplicationType",
+		"prefetchPolicy",
+		"cifsUser",
+		"cifsPermission",
+		"smarttier",
+		"capacityAlarmThreshold",
+		"softQuota",
+		"hardQuota",
+		"gracePeriod",
 	}
 
 	for _, key := range paramKeys {
@@ -164,6 +358,22 @@ func (p *OceanstorPlugin) getParams(ctx context.Context, name string,
 		}
 	}
 
+	params["missingapplicationtype"] = p.missingApplicationType
+
+	for _, key := range volume.HyperMetroOptionKeys {
+		if v, exist := parameters[key].(string); exist && v != "" {
+			params[strings.ToLower(key)] = v
+		} else if v, exist := p.hyperMetroOptions[key]; exist {
+			params[strings.ToLower(key)] = v
+		}
+	}
+
+	if v, exist := parameters["hyperMetroGroup"].(string); exist && v != "" {
+		params["hypermetrogroup"] = v
+	} else if p.hyperMetroGroup != "" {
+		params["hypermetrogroup"] = p.hyperMetroGroup
+	}
+
 	if v, exist := parameters["hyperMetro"].(string); exist && v != "" {
 		params["hypermetro"] = utils.StrToBool(ctx, v)
 	}
@@ -171,6 +381,8 @@ func (p *OceanstorPlugin) getParams(ctx context.Context, name string,
 	// Add new bool parameter here
 	for _, i := range []string{
 		"replication",
+		"qosShared",
+		"asyncCopy",
 	} {
 		if v, exist := parameters[i].(string); exist && v != "" {
 			params[i] = utils.StrToBool(ctx, v)
@@ -181,6 +393,11 @@ func (p *OceanstorPlugin) getParams(ctx context.Context, name string,
 	for _, i := range []string{
 		"replicationSyncPeriod",
 		"vStorePairID",
+		"replicationModel",
+		"replicationSyncType",
+		"replicationSpeed",
+		"replicationBandwidth",
+		"replicationGroup",
 	} {
 		if v, exist := parameters[i].(string); exist && v != "" {
 			params[i] = v
@@ -190,11 +407,32 @@ func (p *OceanstorPlugin) getParams(ctx context.Context, name string,
 	return params
 }
 
+// setDataReductionParams sets the dedup and compression parameters of the volume. Data reduction which is
+// not licensed for the volume type, whose capabilities end with the suffix, is never disabled explicitly.
+func (p *OceanstorPlugin) setDataReductionParams(ctx context.Context, params, parameters map[string]interface{},
+	capabilitySuffix string) {
+	for key, capability := range map[string]string{
+		"dedup":       "SupportDedupe",
+		"compression": "SupportCompression",
+	} {
+		v, exist := parameters[key].(string)
+		if !exist || v == "" {
+			continue
+		}
+
+		enabled := utils.StrToBool(ctx, v)
+		supported, _ := p.capabilities[capability+capabilitySuffix].(bool)
+		if enabled || supported {
+			params[key] = enabled
+		}
+	}
+}
+
 func (p *OceanstorPlugin) updatePoolCapabilities(poolNames []string,
 	usageType string) (map[string]interface{}, error) {
-	pools, err := p.cli.GetAllPools(context.Background())
+	pools, err := p.cli.GetPoolsByNames(context.Background(), poolNames)
 	if err != nil {
-		log.Errorf("Get all pools error: %v", err)
+		log.Errorf("Get pools %v error: %v", poolNames, err)
 		return nil, err
 	}
 
@@ -224,16 +462,58 @@ func (p *OceanstorPlugin) analyzePoolsCapacity(pools []map[string]interface{}) m
 
 	for _, pool := range pools {
 		name := pool["NAME"].(string)
-		freeCapacity, _ := strconv.ParseInt(pool["USERFREECAPACITY"].(string), 10, 64)
+		freeCapacity, err := utils.ParseSectors(pool, "USERFREECAPACITY")
+		if err != nil {
+			log.Warningf("Get free capacity of pool %s error: %v", name, err)
+			continue
+		}
+		totalCapacity, err := utils.ParseSectors(pool, "USERTOTALCAPACITY")
+		if err != nil {
+			log.Warningf("Get total capacity of pool %s error: %v", name, err)
+			continue
+		}
 
 		capabilities[name] = map[string]interface{}{
-			"FreeCapacity": freeCapacity * 512,
+			"FreeCapacity":  freeCapacity.Bytes(),
+			"TotalCapacity": totalCapacity.Bytes(),
 		}
 	}
 
 	return capabilities
 }
 
+// getNodeClient logs the client in for a request of the node, the client is kept logged in on the controller
+func (p *OceanstorPlugin) getNodeClient(ctx context.Context) error {
+	if p.keepLogin {
+		return nil
+	}
+
+	p.nodeClientMutex.Lock()
+	defer p.nodeClientMutex.Unlock()
+	if p.nodeClientCount == 0 {
+		err := p.cli.Login(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	p.nodeClientCount++
+	return nil
+}
+
+// releaseNodeClient logs the client out once the last request of the node using it is done
+func (p *OceanstorPlugin) releaseNodeClient(ctx context.Context) {
+	if p.keepLogin {
+		return
+	}
+
+	p.nodeClientMutex.Lock()
+	defer p.nodeClientMutex.Unlock()
+	p.nodeClientCount--
+	if p.nodeClientCount == 0 {
+		p.cli.Logout(ctx)
+	}
+}
+
 func (p *OceanstorPlugin) duplicateClient(ctx context.Context) (client.BaseClientInterface, error) {
 	err := p.cli.Login(ctx)
 	if err != nil {
@@ -243,11 +523,74 @@ func (p *OceanstorPlugin) duplicateClient(ctx context.Context) (client.BaseClien
 	return p.cli, nil
 }
 
+// getVolumeState returns the state of a LUN or a filesystem, whose capacities are in sectors
+func (p *OceanstorPlugin) getVolumeState(obj map[string]interface{}) (*VolumeState, error) {
+	if obj == nil {
+		return &VolumeState{}, nil
+	}
+
+	capacity, err := utils.ParseSectors(obj, "CAPACITY")
+	if err != nil {
+		return nil, err
+	}
+
+	var consumed utils.Capacity
+	if _, exist := obj["ALLOCCAPACITY"]; exist {
+		consumed, err = utils.ParseSectors(obj, "ALLOCCAPACITY")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	qosID, _ := obj["IOCLASSID"].(string)
+	pool, _ := obj["PARENTNAME"].(string)
+	state := &VolumeState{
+		Exist:    true,
+		Capacity: capacity.Bytes(),
+		Consumed: consumed.Bytes(),
+		QoSID:    qosID,
+		Pool:     pool,
+	}
+
+	if healthStatus, exist := obj["HEALTHSTATUS"].(string); exist && healthStatus != healthStatusNormal {
+		description, exist := healthStatusDescriptions[healthStatus]
+		if !exist {
+			description = "abnormal"
+		}
+
+		state.Abnormal = true
+		state.Message = fmt.Sprintf("The health status of %s on storage is %s (%s)", obj["NAME"], description,
+			healthStatus)
+	}
+	return state, nil
+}
+
 // SupportQoSParameters checks requested QoS parameters support by Oceanstor plugin
 func (p *OceanstorPlugin) SupportQoSParameters(ctx context.Context, qosConfig string) error {
 	return smartx.CheckQoSParameterSupport(ctx, p.product, qosConfig)
 }
 
+// checkFirmware returns an error if the firmware of the array is older than the feature requires
+func (p *OceanstorPlugin) checkFirmware(ctx context.Context, feature string) error {
+	return checkFirmware(ctx, p.product, p.firmware, feature)
+}
+
+// parseQoS checks the QoS parameters are supported by the product, and converts them to the
+// parameters of a SmartQoS policy
+func (p *OceanstorPlugin) parseQoS(ctx context.Context, qosConfig string) (map[string]int, error) {
+	err := smartx.CheckQoSParameterSupport(ctx, p.product, qosConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	qos, err := smartx.ExtractQoSParameters(ctx, p.product, qosConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return smartx.ValidateQoSParameters(p.product, qos)
+}
+
 // Logout is to logout the storage session
 func (p *OceanstorPlugin) Logout(ctx context.Context) {
 	if p.cli != nil {
@@ -19,10 +19,14 @@ package plugin
 import (
 	"context"
 	"errors"
+	"path/filepath"
+	"strings"
+
 	"github.com/container-storage-interface/spec/lib/go/csi"
 
 	"huawei-csi-driver/connector"
 	// init the nfs connector
+	_ "huawei-csi-driver/connector/cifs"
 	_ "huawei-csi-driver/connector/nfs"
 	"huawei-csi-driver/utils"
 	"huawei-csi-driver/utils/log"
@@ -56,13 +60,222 @@ type SmartXQoSQuery interface {
 	SupportQoSParameters(ctx context.Context, qos string) error
 }
 
+// VolumeMappingQuery is implemented by plugins which map volumes to hosts on storage
+type VolumeMappingQuery interface {
+	// GetOtherMappedHosts returns the hostnames of the nodes other than the node in parameters,
+	// which the volume is mapped to
+	GetOtherMappedHosts(ctx context.Context, name string, parameters map[string]interface{}) ([]string, error)
+}
+
+// VolumeFencer is implemented by plugins which can fence a lost node off a volume
+type VolumeFencer interface {
+	// FenceVolume removes the mapping of the volume to the node in parameters from every storage the
+	// volume is mapped through, and fails if any of them cannot be reached
+	FenceVolume(ctx context.Context, name string, parameters map[string]interface{}) error
+}
+
+// VolumeState describes a volume as it is on storage
+type VolumeState struct {
+	// Exist is false if the volume is not found on storage
+	Exist bool
+	// Capacity is the capacity in bytes
+	Capacity int64
+	// Consumed is the capacity in bytes consumed in the storage pool, which is less than Capacity
+	// for thin volumes. It is 0 if the storage does not report it.
+	Consumed int64
+	// QoSID is the ID of the QoS policy associated with the volume, empty if there is none
+	QoSID string
+	// Pool is the name of the storage pool holding the volume, empty if the storage does not report it
+	Pool string
+	// Abnormal is true if storage reports the volume unhealthy, which Message describes
+	Abnormal bool
+	Message  string
+}
+
+// VolumeStateQuery is implemented by plugins which can report the state of volumes on storage
+type VolumeStateQuery interface {
+	// QueryVolumeState returns the state of the volume on storage
+	QueryVolumeState(ctx context.Context, name string) (*VolumeState, error)
+}
+
+// SnapshotQuery is implemented by plugins which can look up snapshots on storage, including
+// the ones not created by the driver, such as the snapshots of array schedules
+type SnapshotQuery interface {
+	// QuerySnapshot returns the snapshot of the parent volume in the format of CreateSnapshot,
+	// nil if the snapshot does not exist
+	QuerySnapshot(ctx context.Context, parentID, name string) (map[string]interface{}, error)
+}
+
+// SnapshotLister is implemented by plugins which can list the snapshots on storage page by page
+type SnapshotLister interface {
+	// ListSnapshots returns the snapshots from the offset in the format of QuerySnapshot with their Name,
+	// of the volume if it is not empty or of all the volumes otherwise, and the offset of the next page,
+	// 0 if no more snapshot exists
+	ListSnapshots(ctx context.Context, volName string, offset, limit int) ([]map[string]interface{}, int, error)
+}
+
+// TypedSnapshotCreator is implemented by plugins which can create snapshots of types other than
+// the classic snapshot, such as the HyperCDP objects of Dorado V6
+type TypedSnapshotCreator interface {
+	// CreateTypedSnapshot creates a snapshot of the type in the format of CreateSnapshot
+	CreateTypedSnapshot(ctx context.Context, volName, snapshotName, snapshotType string) (map[string]interface{}, error)
+}
+
+// ProtectionGroupManager is implemented by plugins which can protect a group of volumes as a unit
+// with the protection groups of the storage
+type ProtectionGroupManager interface {
+	// EnsureProtectionGroup creates the protection group if it does not exist, and adds the volumes to it
+	EnsureProtectionGroup(ctx context.Context, group string, volumes []string) error
+	// RemoveFromProtectionGroup removes the volumes from the protection group
+	RemoveFromProtectionGroup(ctx context.Context, group string, volumes []string) error
+	// DeleteProtectionGroup deletes the protection group and its replication group, the volumes are kept
+	DeleteProtectionGroup(ctx context.Context, group string, volumes []string) error
+	// CreateGroupSnapshot snapshots the volumes of the protection group at the same point in time, and
+	// returns the snapshot of each volume as "<parent ID>.<snapshot name>", the suffix of a snapshot ID
+	CreateGroupSnapshot(ctx context.Context, group, snapshot string, volumes []string) (map[string]string, error)
+	// DeleteGroupSnapshot deletes the group snapshot together with the snapshots of the volumes, which
+	// are the ones returned by CreateGroupSnapshot
+	DeleteGroupSnapshot(ctx context.Context, snapshot string, volumeSnapshots []string) error
+	// EnsureReplicationGroup replicates the volumes of the protection group as a consistency group
+	EnsureReplicationGroup(ctx context.Context, group string, volumes []string) error
+}
+
+// SnapshotVolumeProvider is implemented by plugins which can publish snapshots read-only as volumes,
+// instead of cloning them
+type SnapshotVolumeProvider interface {
+	// GetSnapshotVolume returns the name of the volume publishing the snapshot, which contains
+	// SnapshotVolumeSeparator
+	GetSnapshotVolume(ctx context.Context, parentID, snapshotName string) (string, error)
+}
+
+// IsSnapshotVolume tells whether the volume publishes a snapshot, which is kept when the volume is deleted
+func IsSnapshotVolume(name string) bool {
+	return strings.Contains(name, SnapshotVolumeSeparator)
+}
+
+// SnapshotRollbacker is implemented by plugins which can roll volumes back to their snapshots in place
+type SnapshotRollbacker interface {
+	// RollbackSnapshot starts rolling the volume back to the snapshot, and returns the size in bytes
+	// the volume had when the snapshot was taken
+	RollbackSnapshot(ctx context.Context, name, snapshotParentID, snapshotName string, speed int) (int64, error)
+	// IsRollingBack returns whether the volume is still rolling back to the snapshot
+	IsRollingBack(ctx context.Context, snapshotParentID, snapshotName string) (bool, error)
+}
+
+// VolumeShrinker is implemented by plugins which can reduce the capacity of volumes
+type VolumeShrinker interface {
+	// ShrinkVolume reduces the capacity of the volume to the size in bytes, the size must not be
+	// less than the capacity the volume uses
+	ShrinkVolume(ctx context.Context, name string, size int64) error
+}
+
+// VolumeFailoverTrigger is implemented by plugins which replicate volumes to a remote storage for disaster recovery
+type VolumeFailoverTrigger interface {
+	// FailoverVolume lets the replica of the volume on the remote storage be written, and stops its replication
+	FailoverVolume(ctx context.Context, name string) error
+}
+
+// VolumeMigrator is implemented by plugins which can move volumes to other storage pools online
+type VolumeMigrator interface {
+	// MigrateVolume starts moving the volume to the storage pool at the speed from 1 to 4
+	MigrateVolume(ctx context.Context, name, pool string, speed int) error
+	// IsMigrating returns whether the volume is still moving to the storage pool
+	IsMigrating(ctx context.Context, name, pool string) (bool, error)
+}
+
+// CapacityAlarmChecker is implemented by plugins which report the capacity alarms of volumes and their pools
+type CapacityAlarmChecker interface {
+	// GetCapacityAlarms returns the messages of the capacity alarms raised for the volume by alarm
+	GetCapacityAlarms(ctx context.Context, name string) (map[string]string, error)
+}
+
+// VolumeRecycler is implemented by plugins which can keep the deleted volumes in a recycle bin
+type VolumeRecycler interface {
+	// PurgeRecycledVolumes destroys the volumes kept in the recycle bin longer than the retention
+	PurgeRecycledVolumes(ctx context.Context) error
+}
+
+// PathPreferenceRefresher is implemented by the plugins whose volumes prefer some of their paths on the node
+type PathPreferenceRefresher interface {
+	// RefreshPathPreference lets the paths of the attached volume be used by the current preference
+	RefreshPathPreference(ctx context.Context, name string, parameters map[string]interface{}) error
+}
+
+// QoSUpdater is implemented by plugins which can change the QoS of an existing volume
+type QoSUpdater interface {
+	// UpdateQoS sets the QoS parameters of the volume, in the format of the qos StorageClass parameter
+	UpdateQoS(ctx context.Context, name, qos string) error
+}
+
+// ShareAccessUpdater is implemented by plugins which can change the NFS clients of an existing volume
+type ShareAccessUpdater interface {
+	// UpdateShareAccess sets the NFS clients allowed to access the volume, in the format of the
+	// authClient StorageClass parameter
+	UpdateShareAccess(ctx context.Context, name, authClient string) error
+}
+
+// QuotaUpdater is implemented by plugins which can change the quota of an existing volume
+type QuotaUpdater interface {
+	// UpdateQuota sets the quota of the volume of the size in bytes, in the format of the softQuota,
+	// hardQuota and gracePeriod StorageClass parameters
+	UpdateQuota(ctx context.Context, name string, size int64, softQuota, hardQuota, gracePeriod string) error
+}
+
+// QuotaUsageGetter is implemented by plugins whose volumes are limited by quotas rather than by their size
+type QuotaUsageGetter interface {
+	// GetQuotaUsage returns the hard limit of the quota of the volume and its used capacity in bytes,
+	// the limit is 0 if the volume has no hard quota
+	GetQuotaUsage(ctx context.Context, name string) (int64, int64, error)
+	// AlwaysLimitedByQuota returns whether every volume has a hard quota, otherwise only the volumes
+	// setting the hardQuota StorageClass parameter have
+	AlwaysLimitedByQuota() bool
+}
+
+// SmartTierUpdater is implemented by plugins which can change the SmartTier policy of an existing volume
+type SmartTierUpdater interface {
+	// UpdateSmartTier sets the relocation policy of the volume, in the format of the smarttier StorageClass parameter
+	UpdateSmartTier(ctx context.Context, name, policy string) error
+}
+
+// CloneProgress is the progress of the copy of a volume created from a source
+type CloneProgress struct {
+	// Copying is true while the data is being copied from the source
+	Copying bool
+	// Usable is true if the volume can be used while it is copying
+	Usable bool
+	// Completed is only true for the check which finds the copy finished and cleans it up
+	Completed bool
+}
+
+// CloneCompleter is implemented by plugins which can create volumes from a source before the data is copied
+type CloneCompleter interface {
+	// CompleteClone checks the copy of the volume, which is cleaned up once the data is copied
+	CompleteClone(ctx context.Context, name string) (CloneProgress, error)
+}
+
+// ArrayIdentifier is implemented by plugins which can clone the volumes of other backends on the same array
+type ArrayIdentifier interface {
+	// GetArrayID returns the ID of the array and the tenant of the backend, backends of the same ID can
+	// clone the volumes of each other
+	GetArrayID() string
+}
+
 var (
 	plugins = map[string]Plugin{}
 )
 
 const (
-	// SectorSize means Sector size
-	SectorSize int64 = 512
+	// SnapshotVolumeSeparator separates the parent volume and the snapshot in the name of a volume
+	// publishing a snapshot
+	SnapshotVolumeSeparator = "/.snapshot/"
+
+	// ClassicSnapshotType is the snapshot type of the snapshots created by CreateSnapshot
+	ClassicSnapshotType = "snapshot"
+	// HyperCDPSnapshotType is the snapshot type of the HyperCDP objects of Dorado V6
+	HyperCDPSnapshotType = "hypercdp"
+
+	// encryptionPassphraseKey is the key of the LUKS passphrase in the node stage secret of encrypted volumes
+	encryptionPassphraseKey = "encryptionPassphrase"
 )
 
 func RegPlugin(storageType string, plugin Plugin) {
@@ -109,22 +322,59 @@ func (p *basePlugin) stageVolume(ctx context.Context, connectInfo map[string]int
 func (p *basePlugin) fsStageVolume(ctx context.Context,
 	name, portal string,
 	parameters map[string]interface{}) error {
+	protocol, _ := parameters["protocol"].(string)
+	err := connector.VerifyProtocol(ctx, protocol)
+	if err != nil {
+		return err
+	}
+
 	sourcePath := portal + ":/" + name
+	mountFlags, _ := parameters["mountFlags"].(string)
 	if parameters["protocol"] == "dpc" {
 		sourcePath = "/" + name
+	} else {
+		if version, _ := parameters["nfsVersion"].(string); version != "" {
+			mountFlags = appendNFSVersion(mountFlags, version)
+		}
+		if flavors, _ := parameters["nfsSecurityFlavors"].(string); flavors != "" {
+			mountFlags = appendMountFlag(mountFlags, "sec="+flavors, "sec")
+		}
 	}
 
 	connectInfo := map[string]interface{}{
 		"srcType":    connector.MountFSType,
 		"sourcePath": sourcePath,
 		"targetPath": parameters["targetPath"],
-		"mountFlags": parameters["mountFlags"],
+		"mountFlags": mountFlags,
 		"protocol":   parameters["protocol"],
 	}
 
 	return p.stageVolume(ctx, connectInfo)
 }
 
+// appendNFSVersion adds the vers option of the NFS version to the mount flags, unless the flags already
+// specify a version
+func appendNFSVersion(mountFlags, version string) string {
+	return appendMountFlag(mountFlags, "vers="+version, "vers", "nfsvers")
+}
+
+// appendMountFlag adds the flag to the mount flags, unless the flags already set any of the option names
+func appendMountFlag(mountFlags, flag string, names ...string) string {
+	for _, option := range strings.Split(mountFlags, ",") {
+		option = strings.TrimSpace(option)
+		for _, name := range names {
+			if strings.HasPrefix(option, name+"=") {
+				return mountFlags
+			}
+		}
+	}
+
+	if mountFlags == "" {
+		return flag
+	}
+	return mountFlags + "," + flag
+}
+
 func (p *basePlugin) unstageVolume(ctx context.Context,
 	name string,
 	parameters map[string]interface{}) error {
@@ -143,9 +393,63 @@ func (p *basePlugin) unstageVolume(ctx context.Context,
 	return nil
 }
 
+// cifsStageVolume mounts the CIFS share of the filesystem with the credentials of the node stage secret
+func (p *basePlugin) cifsStageVolume(ctx context.Context,
+	name, portal string,
+	parameters map[string]interface{}) error {
+	err := connector.VerifyProtocol(ctx, "cifs")
+	if err != nil {
+		return err
+	}
+
+	secrets, _ := parameters["secrets"].(map[string]string)
+	connectInfo := map[string]interface{}{
+		"sourcePath": "//" + portal + "/" + utils.GetFileSystemName(name),
+		"targetPath": parameters["targetPath"],
+		"mountFlags": parameters["mountFlags"],
+		"username":   secrets["username"],
+		"password":   secrets["password"],
+		"domain":     secrets["domain"],
+	}
+
+	conn := connector.GetConnector(ctx, connector.CIFSDriver)
+	_, err = conn.ConnectVolume(ctx, connectInfo)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Mount cifs share of %s error: %v", name, err)
+		return err
+	}
+
+	return nil
+}
+
+func (p *basePlugin) cifsUnstageVolume(ctx context.Context,
+	name string,
+	parameters map[string]interface{}) error {
+	targetPath, exist := parameters["targetPath"].(string)
+	if !exist {
+		return errors.New("unstageVolume parameter targetPath does not exist")
+	}
+
+	conn := connector.GetConnector(ctx, connector.CIFSDriver)
+	err := conn.DisConnectVolume(ctx, targetPath)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Cannot unmount %s error: %v", name, err)
+		return err
+	}
+
+	return nil
+}
+
 func (p *basePlugin) lunStageVolume(ctx context.Context,
-	name, devPath string,
+	name, devPath, lunWWN string,
 	parameters map[string]interface{}) error {
+	if encrypted, _ := parameters["encrypted"].(bool); encrypted {
+		var err error
+		devPath, err = openEncryptedVolume(ctx, name, devPath, lunWWN, parameters)
+		if err != nil {
+			return err
+		}
+	}
 
 	// If the request to stage is for volumeDevice of type Block and the devicePath
 	// is provided then do not format and create FS and mount it. Simply create a
@@ -158,7 +462,18 @@ func (p *basePlugin) lunStageVolume(ctx context.Context,
 			log.AddContext(ctx).Errorln(errMsg)
 			return errors.New(errMsg)
 		}
-		err := utils.CreateSymlink(ctx, devPath, mountpoint)
+		err := connector.VerifyDeviceWWN(ctx, devPath, lunWWN)
+		if err != nil {
+			return err
+		}
+
+		accessMode, _ := parameters["accessMode"].(csi.VolumeCapability_AccessMode_Mode)
+		err = connector.SetDeviceReadOnly(ctx, devPath, utils.GetAccessModeType(accessMode) == "ReadOnly")
+		if err != nil {
+			return err
+		}
+
+		err = utils.CreateSymlink(ctx, devPath, mountpoint)
 		if err != nil {
 			log.AddContext(ctx).Errorln("Error in staging device")
 			return err
@@ -170,10 +485,17 @@ func (p *basePlugin) lunStageVolume(ctx context.Context,
 		"fsType":     parameters["fsType"].(string),
 		"srcType":    connector.MountBlockType,
 		"sourcePath": devPath,
+		"lunWWN":     lunWWN,
 		"targetPath": parameters["targetPath"].(string),
 		"mountFlags": parameters["mountFlags"].(string),
 		"accessMode": parameters["accessMode"].(csi.VolumeCapability_AccessMode_Mode),
 	}
+	if checkFs, _ := parameters["checkFsBeforeMount"].(bool); checkFs {
+		connectInfo["checkFsBeforeMount"] = true
+	}
+	if mkfsOptions, _ := parameters["mkfsOptions"].(string); mkfsOptions != "" {
+		connectInfo["mkfsOptions"] = mkfsOptions
+	}
 
 	err := p.stageVolume(ctx, connectInfo)
 	if err != nil {
@@ -190,6 +512,42 @@ func (p *basePlugin) lunStageVolume(ctx context.Context,
 	return nil
 }
 
+// openEncryptedVolume opens the LUKS mapping on the device of the encrypted volume with the passphrase of
+// the node stage secret, and returns the path of the mapping
+func openEncryptedVolume(ctx context.Context,
+	name, devPath, lunWWN string,
+	parameters map[string]interface{}) (string, error) {
+	secrets, _ := parameters["secrets"].(map[string]string)
+	passphrase := secrets[encryptionPassphraseKey]
+	if passphrase == "" {
+		return "", utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"%s of the node stage secret must be provided to stage encrypted volume %s",
+			encryptionPassphraseKey, name)
+	}
+
+	err := connector.VerifyDeviceWWN(ctx, devPath, lunWWN)
+	if err != nil {
+		return "", err
+	}
+
+	// The key file is written beside the staging path, which is shared with the host
+	keyDir := filepath.Dir(parameters["targetPath"].(string))
+	if stagingPath, ok := parameters["stagingPath"].(string); ok {
+		keyDir = filepath.Dir(stagingPath)
+	}
+	return connector.OpenLUKSDevice(ctx, devPath, name, passphrase, keyDir)
+}
+
+// getMappingLunWWN returns the WWN of SCSI LUNs or the GUID of NVMe namespaces to connect
+func getMappingLunWWN(connectInfo *connector.ConnectInfo) string {
+	if wwn, ok := connectInfo.MappingInfo["tgtLunWWN"].(string); ok {
+		return wwn
+	}
+
+	guid, _ := connectInfo.MappingInfo["tgtLunGuid"].(string)
+	return guid
+}
+
 func (p *basePlugin) lunConnectVolume(ctx context.Context,
 	connectInfo *connector.ConnectInfo) (string, error) {
 	device, err := connectInfo.Conn.ConnectVolume(ctx, connectInfo.MappingInfo)
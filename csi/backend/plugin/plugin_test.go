@@ -21,6 +21,8 @@ import (
 	"path"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
 	"huawei-csi-driver/utils/log"
 )
 
@@ -29,6 +31,17 @@ const (
 	logName string = "csi-backend-plugin-test.log"
 )
 
+func TestAppendNFSVersion(t *testing.T) {
+	assert.Equal(t, "vers=4.1", appendNFSVersion("", "4.1"))
+	assert.Equal(t, "hard,vers=4.1", appendNFSVersion("hard", "4.1"))
+	assert.Equal(t, "hard, nfsvers=3", appendNFSVersion("hard, nfsvers=3", "4.1"))
+}
+
+func TestAppendMountFlag(t *testing.T) {
+	assert.Equal(t, "vers=4.1,sec=krb5p:krb5i", appendMountFlag("vers=4.1", "sec=krb5p:krb5i", "sec"))
+	assert.Equal(t, "sec=krb5", appendMountFlag("sec=krb5", "sec=krb5p", "sec"))
+}
+
 func TestMain(m *testing.M) {
 	if err := log.InitLogging(logName); err != nil {
 		log.Errorf("Init logging: %s failed. error: %v", logName, err)
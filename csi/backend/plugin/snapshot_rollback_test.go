@@ -0,0 +1,90 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"bou.ke/monkey"
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/storage/oceanstor/client"
+	"huawei-csi-driver/utils"
+)
+
+func TestRollbackSnapshot(t *testing.T) {
+	cases := []struct {
+		name       string
+		lun        map[string]interface{}
+		snapshot   map[string]interface{}
+		wantErr    error
+		wantSize   int64
+		rolledBack bool
+	}{
+		{"Rolled back",
+			map[string]interface{}{"ID": "1", "HASRSSOBJECT": `{"HyperMetro":"FALSE"}`},
+			map[string]interface{}{"ID": "10", "PARENTID": "1", "USERCAPACITY": "2048", "RUNNINGSTATUS": "43"},
+			nil, 2048 * 512, true},
+		{"Already rolling back",
+			map[string]interface{}{"ID": "1", "HASRSSOBJECT": `{"HyperMetro":"FALSE"}`},
+			map[string]interface{}{"ID": "10", "PARENTID": "1", "USERCAPACITY": "2048", "RUNNINGSTATUS": "44"},
+			nil, 2048 * 512, false},
+		{"Snapshot of another lun",
+			map[string]interface{}{"ID": "2", "HASRSSOBJECT": `{"HyperMetro":"FALSE"}`},
+			map[string]interface{}{"ID": "10", "PARENTID": "1", "USERCAPACITY": "2048", "RUNNINGSTATUS": "43"},
+			utils.ErrFailedPrecondition, 0, false},
+		{"HyperMetro lun",
+			map[string]interface{}{"ID": "1", "HASRSSOBJECT": `{"HyperMetro":"TRUE"}`},
+			map[string]interface{}{"ID": "10", "PARENTID": "1", "USERCAPACITY": "2048", "RUNNINGSTATUS": "43"},
+			utils.ErrFailedPrecondition, 0, false},
+		{"Snapshot not exist",
+			map[string]interface{}{"ID": "1", "HASRSSOBJECT": `{"HyperMetro":"FALSE"}`}, nil,
+			utils.ErrNotFound, 0, false},
+	}
+
+	cli := &client.BaseClient{}
+	p := &OceanstorSanPlugin{OceanstorPlugin: OceanstorPlugin{cli: cli}}
+	defer monkey.UnpatchAll()
+	for _, c := range cases {
+		rolledBack := false
+		monkey.PatchInstanceMethod(reflect.TypeOf(cli), "GetLunByName",
+			func(*client.BaseClient, context.Context, string) (map[string]interface{}, error) {
+				return c.lun, nil
+			})
+		monkey.PatchInstanceMethod(reflect.TypeOf(cli), "GetLunSnapshotByName",
+			func(*client.BaseClient, context.Context, string) (map[string]interface{}, error) {
+				return c.snapshot, nil
+			})
+		monkey.PatchInstanceMethod(reflect.TypeOf(cli), "RollbackLunSnapshot",
+			func(*client.BaseClient, context.Context, string, int) error {
+				rolledBack = true
+				return nil
+			})
+
+		size, err := p.RollbackSnapshot(context.Background(), "pvc-1", "1", "snapshot-1", 2)
+		if c.wantErr == nil {
+			assert.NoError(t, err, c.name)
+		} else {
+			assert.True(t, errors.Is(err, c.wantErr), c.name)
+		}
+		assert.Equal(t, c.wantSize, size, c.name)
+		assert.Equal(t, c.rolledBack, rolledBack, c.name)
+	}
+}
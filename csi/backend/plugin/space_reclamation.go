@@ -0,0 +1,64 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package plugin
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// reclaimSpaceKey is the backend parameter enabling the space reclamation of LUNs on detach
+const reclaimSpaceKey = "reclaimSpaceOnDetach"
+
+var trimmedBytesRegexp = regexp.MustCompile(`\((\d+) bytes\)`)
+
+// reclaimSpace discards the unused blocks of the filesystem staged at the target path before it
+// is unmounted, so the thin LUN returns the blocks of the deleted files to the storage pool. The
+// data of raw block volumes is unknown, so they are not discarded. The reclaimed bytes are returned,
+// a failure only logs a warning and does not fail the detachment.
+func reclaimSpace(ctx context.Context, name string, parameters map[string]interface{}) int64 {
+	targetPath, _ := parameters["targetPath"].(string)
+	if targetPath == "" {
+		return 0
+	}
+
+	output, err := utils.ExecShellCmd(ctx, "fstrim -v %s", targetPath)
+	if err != nil {
+		if strings.Contains(output, "not a mount point") || strings.Contains(output, "No such file") {
+			log.AddContext(ctx).Infof("No filesystem of volume %s is mounted at %s, skip reclaiming space",
+				name, targetPath)
+		} else {
+			log.AddContext(ctx).Warningf("Reclaim space of volume %s error: %s", name, output)
+		}
+		return 0
+	}
+
+	match := trimmedBytesRegexp.FindStringSubmatch(output)
+	if len(match) < 2 {
+		log.AddContext(ctx).Infof("Reclaimed space of volume %s: %s", name, output)
+		return 0
+	}
+
+	reclaimed, _ := strconv.ParseInt(match[1], 10, 64)
+	log.AddContext(ctx).Infof("Reclaimed %d bytes of volume %s", reclaimed, name)
+	return reclaimed
+}
@@ -0,0 +1,51 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/utils"
+)
+
+func TestReclaimSpace(t *testing.T) {
+	cases := []struct {
+		name   string
+		output string
+		err    error
+		want   int64
+	}{
+		{"Filesystem trimmed", "/mnt/stage: 1.2 GiB (1288490188 bytes) trimmed", nil, 1288490188},
+		{"Raw block volume", "fstrim: /mnt/stage: not a mount point", errors.New("exit status 1"), 0},
+		{"Discard not supported", "fstrim: /mnt/stage: the discard operation is not supported",
+			errors.New("exit status 1"), 0},
+	}
+
+	for _, c := range cases {
+		stubs := gostub.Stub(&utils.ExecShellCmd, func(context.Context, string, ...interface{}) (string, error) {
+			return c.output, c.err
+		})
+		reclaimed := reclaimSpace(context.Background(), "pvc-1", map[string]interface{}{"targetPath": "/mnt/stage"})
+		assert.Equal(t, c.want, reclaimed, c.name)
+		stubs.Reset()
+	}
+}
@@ -0,0 +1,94 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"bou.ke/monkey"
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/storage/oceanstor/client"
+	"huawei-csi-driver/utils"
+)
+
+func TestFailoverVolume(t *testing.T) {
+	cases := []struct {
+		name     string
+		fs       map[string]interface{}
+		pair     map[string]interface{}
+		wantErr  error
+		split    bool
+		unlocked bool
+	}{
+		{"Failed over", map[string]interface{}{"ID": "1", "REMOTEREPLICATIONIDS": `["10"]`},
+			map[string]interface{}{"RUNNINGSTATUS": "1", "ISPRIMARY": "false", "SECRESACCESS": "2"},
+			nil, true, true},
+		{"Interrupted pair", map[string]interface{}{"ID": "1", "REMOTEREPLICATIONIDS": `["10"]`},
+			map[string]interface{}{"RUNNINGSTATUS": "34", "ISPRIMARY": "false", "SECRESACCESS": "2"},
+			nil, false, true},
+		{"Already primary", map[string]interface{}{"ID": "1", "REMOTEREPLICATIONIDS": `["10"]`},
+			map[string]interface{}{"RUNNINGSTATUS": "1", "ISPRIMARY": "true", "SECRESACCESS": "2"},
+			nil, false, false},
+		{"Not in pairs", map[string]interface{}{"ID": "1", "REMOTEREPLICATIONIDS": "[]"}, nil,
+			utils.ErrFailedPrecondition, false, false},
+		{"Replica not exist", nil, nil, utils.ErrNotFound, false, false},
+	}
+
+	cli := &client.BaseClient{}
+	p := &OceanstorNasPlugin{replicaRemotePlugin: &OceanstorNasPlugin{OceanstorPlugin: OceanstorPlugin{cli: cli}}}
+	defer monkey.UnpatchAll()
+	for _, c := range cases {
+		split, unlocked := false, false
+		monkey.PatchInstanceMethod(reflect.TypeOf(cli), "GetFileSystemByName",
+			func(*client.BaseClient, context.Context, string) (map[string]interface{}, error) {
+				return c.fs, nil
+			})
+		monkey.PatchInstanceMethod(reflect.TypeOf(cli), "GetReplicationPairByID",
+			func(*client.BaseClient, context.Context, string) (map[string]interface{}, error) {
+				return c.pair, nil
+			})
+		monkey.PatchInstanceMethod(reflect.TypeOf(cli), "SplitReplicationPair",
+			func(*client.BaseClient, context.Context, string) error {
+				split = true
+				return nil
+			})
+		monkey.PatchInstanceMethod(reflect.TypeOf(cli), "CancelReplicationSecondaryWriteLock",
+			func(*client.BaseClient, context.Context, string) error {
+				unlocked = true
+				return nil
+			})
+
+		err := p.FailoverVolume(context.Background(), "pvc-1")
+		if c.wantErr == nil {
+			assert.NoError(t, err, c.name)
+		} else {
+			assert.True(t, errors.Is(err, c.wantErr), c.name)
+		}
+		assert.Equal(t, c.split, split, c.name)
+		assert.Equal(t, c.unlocked, unlocked, c.name)
+	}
+}
+
+func TestFailoverVolumeWithoutReplication(t *testing.T) {
+	p := &OceanstorNasPlugin{}
+	err := p.FailoverVolume(context.Background(), "pvc-1")
+	assert.True(t, errors.Is(err, utils.ErrFailedPrecondition))
+}
@@ -0,0 +1,82 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"bou.ke/monkey"
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/storage/oceanstor/client"
+	"huawei-csi-driver/utils"
+)
+
+func TestShrinkVolume(t *testing.T) {
+	cases := []struct {
+		name    string
+		size    int64
+		fs      map[string]interface{}
+		wantErr error
+		updated bool
+	}{
+		{"Shrunk", 1024 * 1024,
+			map[string]interface{}{"ID": "1", "CAPACITY": "4096", "ALLOCCAPACITY": "1024"}, nil, true},
+		{"Same size", 2 * 1024 * 1024,
+			map[string]interface{}{"ID": "1", "CAPACITY": "4096", "ALLOCCAPACITY": "1024"}, nil, false},
+		{"Not sector aligned", 1000,
+			map[string]interface{}{"ID": "1", "CAPACITY": "4096", "ALLOCCAPACITY": "1024"},
+			utils.ErrFailedPrecondition, false},
+		{"Larger than current", 4 * 1024 * 1024,
+			map[string]interface{}{"ID": "1", "CAPACITY": "4096", "ALLOCCAPACITY": "1024"},
+			utils.ErrFailedPrecondition, false},
+		{"Less than used", 256 * 1024,
+			map[string]interface{}{"ID": "1", "CAPACITY": "4096", "ALLOCCAPACITY": "1024"},
+			utils.ErrFailedPrecondition, false},
+		{"HyperMetro pair", 1024 * 1024,
+			map[string]interface{}{"ID": "1", "CAPACITY": "4096", "ALLOCCAPACITY": "1024",
+				"HYPERMETROPAIRIDS": `["10"]`}, utils.ErrFailedPrecondition, false},
+		{"Filesystem not exist", 1024 * 1024, nil, utils.ErrNotFound, false},
+	}
+
+	cli := &client.BaseClient{}
+	p := &OceanstorNasPlugin{OceanstorPlugin: OceanstorPlugin{cli: cli}}
+	defer monkey.UnpatchAll()
+	for _, c := range cases {
+		updated := false
+		monkey.PatchInstanceMethod(reflect.TypeOf(cli), "GetFileSystemByName",
+			func(*client.BaseClient, context.Context, string) (map[string]interface{}, error) {
+				return c.fs, nil
+			})
+		monkey.PatchInstanceMethod(reflect.TypeOf(cli), "UpdateFileSystem",
+			func(*client.BaseClient, context.Context, string, map[string]interface{}) error {
+				updated = true
+				return nil
+			})
+
+		err := p.ShrinkVolume(context.Background(), "pvc-1", c.size)
+		if c.wantErr == nil {
+			assert.NoError(t, err, c.name)
+		} else {
+			assert.True(t, errors.Is(err, c.wantErr), c.name)
+		}
+		assert.Equal(t, c.updated, updated, c.name)
+	}
+}
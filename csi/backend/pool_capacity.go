@@ -0,0 +1,81 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package backend
+
+import (
+	"context"
+
+	"huawei-csi-driver/utils/log"
+)
+
+// PoolCapacity is the capacity available to new volumes in the pools meeting a storage class
+type PoolCapacity struct {
+	// Available is the free capacity of all the pools, less their reserve
+	Available int64
+	// MaximumVolumeSize is the free capacity of the largest pool, as a volume can not span pools
+	MaximumVolumeSize int64
+}
+
+// GetPoolCapacity returns the capacity of the pools meeting the parameters of the storage class and accessible
+// from the topology segment, which the scheduler compares with the requested size through CSIStorageCapacity.
+// No capacity is returned if no pool meets them.
+func GetPoolCapacity(ctx context.Context, parameters map[string]interface{},
+	topology map[string]string) *PoolCapacity {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var pools []*StoragePool
+	for _, backend := range csiBackends {
+		if backend.Available {
+			pools = append(pools, backend.Pools...)
+		}
+	}
+
+	capacity := &PoolCapacity{}
+	pools, err := filterByCapability(ctx, parameters, pools, primaryFilterFuncs)
+	if err != nil {
+		log.AddContext(ctx).Debugf("No pool meets the parameters %v: %v", parameters, err)
+		return capacity
+	}
+
+	if len(topology) > 0 {
+		pools = filterPoolsOnTopology(pools, []map[string]string{topology})
+	}
+
+	for _, pool := range pools {
+		free := pool.getAvailableCapacity()
+		capacity.Available += free
+		if free > capacity.MaximumVolumeSize {
+			capacity.MaximumVolumeSize = free
+		}
+	}
+	return capacity
+}
+
+// getAvailableCapacity returns the free capacity of the pool new volumes can take without breaking its reserve
+func (pool *StoragePool) getAvailableCapacity() int64 {
+	free, _ := pool.Capabilities["FreeCapacity"].(int64)
+	if pool.Reserve != nil {
+		total, _ := pool.Capabilities["TotalCapacity"].(int64)
+		free -= int64(float64(total) * pool.Reserve.RefuseFreePercent / 100)
+	}
+
+	if free < 0 {
+		return 0
+	}
+	return free
+}
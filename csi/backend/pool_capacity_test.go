@@ -0,0 +1,72 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package backend
+
+import (
+	"testing"
+
+	"github.com/prashantv/gostub"
+)
+
+func TestGetPoolCapacity(t *testing.T) {
+	newPool := func(name, parent, storage string, free int64, reserve *PoolReserve) *StoragePool {
+		return &StoragePool{Name: name, Parent: parent, Storage: storage, Reserve: reserve,
+			Capabilities: map[string]interface{}{
+				"SupportThin": true, "FreeCapacity": free, "TotalCapacity": int64(1000)}}
+	}
+
+	stub := gostub.Stub(&csiBackends, map[string]*Backend{
+		"san1": {Name: "san1", Available: true,
+			SupportedTopologies: []map[string]string{{"zone": "a"}},
+			Pools: []*StoragePool{
+				newPool("pool1", "san1", "oceanstor-san", 500, nil),
+				newPool("pool2", "san1", "oceanstor-san", 300, &PoolReserve{RefuseFreePercent: 10}),
+			}},
+		"san2": {Name: "san2", Available: true,
+			SupportedTopologies: []map[string]string{{"zone": "b"}},
+			Pools:               []*StoragePool{newPool("pool1", "san2", "oceanstor-san", 800, nil)}},
+		"nas1": {Name: "nas1", Available: true,
+			Pools: []*StoragePool{newPool("pool1", "nas1", "oceanstor-nas", 900, nil)}},
+		"offline": {Name: "offline",
+			Pools: []*StoragePool{newPool("pool1", "offline", "oceanstor-san", 1000, nil)}},
+	})
+	defer stub.Reset()
+
+	var testCases = []struct {
+		name       string
+		parameters map[string]interface{}
+		topology   map[string]string
+		available  int64
+		maximum    int64
+	}{
+		{"allLuns", map[string]interface{}{}, nil, 1500, 800},
+		{"zoneA", map[string]interface{}{"volumeType": "lun"}, map[string]string{"zone": "a"}, 700, 500},
+		{"backendPool", map[string]interface{}{"backend": "san1", "pool": "pool2"}, nil, 200, 200},
+		{"filesystems", map[string]interface{}{"volumeType": "fs"}, map[string]string{"zone": "a"}, 900, 900},
+		{"noPool", map[string]interface{}{"backend": "offline"}, nil, 0, 0},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			capacity := GetPoolCapacity(ctx, c.parameters, c.topology)
+			if capacity.Available != c.available || capacity.MaximumVolumeSize != c.maximum {
+				t.Errorf("test GetPoolCapacity faild. got: %+v, expect: %d, %d", capacity, c.available,
+					c.maximum)
+			}
+		})
+	}
+}
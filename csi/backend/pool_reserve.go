@@ -0,0 +1,124 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package backend
+
+import (
+	"context"
+	"fmt"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	// poolReserveKey is the backend configuration of the reserve of the pools by pool name, the
+	// reserve named "*" applies to the pools not named
+	poolReserveKey = "poolReserve"
+	// anyPool is the pool name of the reserve applying to all the pools
+	anyPool = "*"
+)
+
+// PoolReserve is the share of a pool kept free. Volumes are not created in a pool whose free
+// capacity would fall below RefuseFreePercent, and a warning is raised below WarnFreePercent.
+type PoolReserve struct {
+	RefuseFreePercent float64
+	WarnFreePercent   float64
+}
+
+// getPoolReserve returns the reserve of the pool in the backend configuration, nil if there is none
+func getPoolReserve(config map[string]interface{}, poolName string) (*PoolReserve, error) {
+	reserves, _ := config[poolReserveKey].(map[string]interface{})
+	reserveConfig, exist := reserves[poolName].(map[string]interface{})
+	if !exist {
+		reserveConfig, exist = reserves[anyPool].(map[string]interface{})
+	}
+	if !exist {
+		return nil, nil
+	}
+
+	refuse, _ := reserveConfig["refuseFreePercent"].(float64)
+	warn, _ := reserveConfig["warnFreePercent"].(float64)
+	if warn < refuse {
+		warn = refuse
+	}
+	if refuse < 0 || warn >= 100 {
+		return nil, fmt.Errorf("reserve %v of pool %s is invalid, the percents must be 0 to 100", reserveConfig,
+			poolName)
+	}
+
+	return &PoolReserve{RefuseFreePercent: refuse, WarnFreePercent: warn}, nil
+}
+
+// getFreePercent returns the percent of the pool which would be free after the volume is created.
+// Thin volumes take no capacity until they are written, so only thick volumes are deducted.
+func (pool *StoragePool) getFreePercent(requestSize int64, allocType string) (float64, bool) {
+	totalCapacity, _ := pool.Capabilities["TotalCapacity"].(int64)
+	freeCapacity, _ := pool.Capabilities["FreeCapacity"].(int64)
+	if totalCapacity <= 0 {
+		return 0, false
+	}
+
+	if allocType == "thick" {
+		freeCapacity -= requestSize
+	}
+	return float64(freeCapacity) * 100 / float64(totalCapacity), true
+}
+
+// filterByReserve filters out the pools whose reserve would be broken by the volume
+func filterByReserve(ctx context.Context, requestSize int64, allocType string,
+	candidatePools []*StoragePool) ([]*StoragePool, error) {
+	var filterPools []*StoragePool
+	for _, pool := range candidatePools {
+		if pool.Reserve == nil {
+			filterPools = append(filterPools, pool)
+			continue
+		}
+
+		freePercent, ok := pool.getFreePercent(requestSize, allocType)
+		if ok && freePercent < pool.Reserve.RefuseFreePercent {
+			log.AddContext(ctx).Warningf("Pool %s of backend %s would be %.1f%% free, below its reserve %.1f%%",
+				pool.Name, pool.Parent, freePercent, pool.Reserve.RefuseFreePercent)
+			continue
+		}
+		filterPools = append(filterPools, pool)
+	}
+
+	if len(filterPools) == 0 && len(candidatePools) > 0 {
+		return nil, utils.KindErrorf(ctx, utils.ErrPoolReserveReached,
+			"failed to select pool, all the candidate pools reach their reserve, capacity: %d", requestSize)
+	}
+	return filterPools, nil
+}
+
+// GetPoolReserveWarning returns a warning if the pool is below its warning reserve after the
+// volume is created, empty otherwise
+func GetPoolReserveWarning(pool *StoragePool, requestSize int64, allocType string) string {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if pool.Reserve == nil {
+		return ""
+	}
+
+	freePercent, ok := pool.getFreePercent(requestSize, allocType)
+	if !ok || freePercent >= pool.Reserve.WarnFreePercent {
+		return ""
+	}
+	return fmt.Sprintf("pool %s of backend %s is %.1f%% free, below its warning reserve %.1f%%, "+
+		"new volumes are refused below %.1f%%", pool.Name, pool.Parent, freePercent,
+		pool.Reserve.WarnFreePercent, pool.Reserve.RefuseFreePercent)
+}
@@ -0,0 +1,80 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package backend
+
+import (
+	"errors"
+	"testing"
+
+	"huawei-csi-driver/utils"
+)
+
+func TestGetPoolReserve(t *testing.T) {
+	config := map[string]interface{}{
+		"poolReserve": map[string]interface{}{
+			"pool1": map[string]interface{}{"refuseFreePercent": float64(10), "warnFreePercent": float64(20)},
+			"*":     map[string]interface{}{"refuseFreePercent": float64(5)},
+		},
+	}
+
+	reserve, err := getPoolReserve(config, "pool1")
+	if err != nil || *reserve != (PoolReserve{RefuseFreePercent: 10, WarnFreePercent: 20}) {
+		t.Errorf("test getPoolReserve of named pool faild. got: %v, %v", reserve, err)
+	}
+
+	reserve, err = getPoolReserve(config, "pool2")
+	if err != nil || *reserve != (PoolReserve{RefuseFreePercent: 5, WarnFreePercent: 5}) {
+		t.Errorf("test getPoolReserve of other pool faild. got: %v, %v", reserve, err)
+	}
+
+	reserve, err = getPoolReserve(map[string]interface{}{}, "pool1")
+	if err != nil || reserve != nil {
+		t.Errorf("test getPoolReserve without reserve faild. got: %v, %v", reserve, err)
+	}
+
+	_, err = getPoolReserve(map[string]interface{}{"poolReserve": map[string]interface{}{
+		"*": map[string]interface{}{"refuseFreePercent": float64(100)}}}, "pool1")
+	if err == nil {
+		t.Errorf("test getPoolReserve of invalid reserve faild. got: nil error")
+	}
+}
+
+func TestFilterByReserve(t *testing.T) {
+	reserve := &PoolReserve{RefuseFreePercent: 10, WarnFreePercent: 20}
+	pools := []*StoragePool{
+		{Name: "noReserve", Capabilities: map[string]interface{}{
+			"FreeCapacity": int64(0), "TotalCapacity": int64(1000)}},
+		{Name: "free", Reserve: reserve, Capabilities: map[string]interface{}{
+			"FreeCapacity": int64(300), "TotalCapacity": int64(1000)}},
+		{Name: "full", Reserve: reserve, Capabilities: map[string]interface{}{
+			"FreeCapacity": int64(50), "TotalCapacity": int64(1000)}},
+	}
+
+	got, err := filterByReserve(ctx, 100, "thin", pools)
+	if err != nil || len(got) != 2 {
+		t.Errorf("test filterByReserve of thin volume faild. got: %d pools, %v", len(got), err)
+	}
+
+	got, err = filterByReserve(ctx, 250, "thick", pools[1:])
+	if !errors.Is(err, utils.ErrPoolReserveReached) {
+		t.Errorf("test filterByReserve of thick volume faild. got: %d pools, %v", len(got), err)
+	}
+
+	if warning := GetPoolReserveWarning(pools[1], 150, "thick"); warning == "" {
+		t.Errorf("test GetPoolReserveWarning faild. got no warning")
+	}
+}
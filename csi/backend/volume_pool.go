@@ -0,0 +1,91 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package backend
+
+import (
+	"context"
+
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// FindVolumePool returns the pool holding a volume of the name among the pools meeting the parameters, which
+// a previous request of the volume may have created before it failed or the controller restarted. The volume
+// is looked up on storage, so a retried request finds it wherever the pool was selected. Nil is returned if
+// none of the pools holds the volume.
+func FindVolumePool(ctx context.Context, name string, parameters map[string]interface{}) (*StoragePool, error) {
+	pools := getCandidatePools(ctx, parameters)
+
+	queried := make(map[string]bool)
+	for _, pool := range pools {
+		if queried[pool.Parent] {
+			continue
+		}
+		queried[pool.Parent] = true
+
+		query, ok := pool.Plugin.(plugin.VolumeStateQuery)
+		if !ok {
+			continue
+		}
+
+		state, err := query.QueryVolumeState(ctx, name)
+		if err != nil {
+			return nil, utils.Errorf(ctx, "query volume %s on backend %s error: %v", name, pool.Parent, err)
+		}
+		if !state.Exist {
+			continue
+		}
+
+		for _, candidate := range pools {
+			if candidate.Parent == pool.Parent && candidate.Name == state.Pool {
+				return candidate, nil
+			}
+		}
+		log.AddContext(ctx).Infof("Volume %s exists in pool %s of backend %s, which doesn't meet the parameters",
+			name, state.Pool, pool.Parent)
+	}
+
+	return nil, nil
+}
+
+// getCandidatePools returns the pools of the available backends meeting the parameters and the topology,
+// whatever their free capacity is
+func getCandidatePools(ctx context.Context, parameters map[string]interface{}) []*StoragePool {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var pools []*StoragePool
+	for _, backend := range csiBackends {
+		if backend.Available {
+			pools = append(pools, backend.Pools...)
+		}
+	}
+
+	pools, err := filterByCapability(ctx, parameters, pools, primaryFilterFuncs)
+	if err != nil {
+		log.AddContext(ctx).Debugf("No pool meets the parameters %v: %v", parameters, err)
+		return nil
+	}
+
+	pools, err = filterByTopology(parameters, pools)
+	if err != nil {
+		log.AddContext(ctx).Debugf("No pool meets the topology of parameters %v: %v", parameters, err)
+		return nil
+	}
+	return pools
+}
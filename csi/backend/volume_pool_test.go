@@ -0,0 +1,87 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package backend
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"huawei-csi-driver/csi/backend/plugin"
+)
+
+// volumeStatePlugin reports the pools of the volumes on its storage
+type volumeStatePlugin struct {
+	plugin.Plugin
+	volumes map[string]string
+	err     error
+}
+
+func (p *volumeStatePlugin) QueryVolumeState(_ context.Context, name string) (*plugin.VolumeState, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	pool, exist := p.volumes[name]
+	return &plugin.VolumeState{Exist: exist, Pool: pool}, nil
+}
+
+func TestFindVolumePool(t *testing.T) {
+	newBackend := func(name string, available bool, p plugin.Plugin) *Backend {
+		backend := &Backend{Name: name, Storage: "oceanstor-san", Available: available, Plugin: p}
+		for _, poolName := range []string{"pool1", "pool2"} {
+			backend.Pools = append(backend.Pools, &StoragePool{Name: poolName, Parent: name,
+				Storage: "oceanstor-san", Plugin: p, Capabilities: map[string]interface{}{"SupportThin": true}})
+		}
+		return backend
+	}
+
+	san2 := &volumeStatePlugin{volumes: map[string]string{"pvc-1": "pool2", "pvc-2": "pool3"}}
+	stubs := gostub.Stub(&csiBackends, map[string]*Backend{
+		"san1":    newBackend("san1", true, &volumeStatePlugin{}),
+		"san2":    newBackend("san2", true, san2),
+		"offline": newBackend("offline", false, &volumeStatePlugin{err: errors.New("unreachable")}),
+	})
+	defer stubs.Reset()
+
+	pool, err := FindVolumePool(context.Background(), "pvc-1", map[string]interface{}{})
+	require.NoError(t, err)
+	require.NotNil(t, pool)
+	assert.Equal(t, "san2", pool.Parent)
+	assert.Equal(t, "pool2", pool.Name)
+
+	// the volume in a pool not meeting the parameters is left to the pool selection
+	pool, err = FindVolumePool(context.Background(), "pvc-1", map[string]interface{}{"pool": "pool1"})
+	assert.NoError(t, err)
+	assert.Nil(t, pool)
+
+	pool, err = FindVolumePool(context.Background(), "pvc-2", map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Nil(t, pool)
+
+	pool, err = FindVolumePool(context.Background(), "pvc-3", map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Nil(t, pool)
+
+	san2.err = errors.New("timeout")
+	_, err = FindVolumePool(context.Background(), "pvc-1", map[string]interface{}{})
+	assert.Error(t, err)
+}
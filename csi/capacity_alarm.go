@@ -0,0 +1,137 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+// capacityAlarmClearedReason is the event reason of a PVC whose capacity alarms are all cleared
+const capacityAlarmClearedReason = "CapacityAlarmCleared"
+
+// raisedCapacityAlarms are the capacity alarms last reported by volume handle, so that an event is
+// recorded once when an alarm is raised rather than at each check
+var raisedCapacityAlarms = map[string]map[string]string{}
+
+// reconcileCapacityAlarms checks the capacity alarms of the volumes and of their pools on storage. A
+// warning event is recorded on the PVC for each alarm raised, and a normal event once they are cleared.
+func reconcileCapacityAlarms(ctx context.Context, k8sUtils k8sutils.Interface, driverName string) error {
+	pvs, err := k8sUtils.ListBoundVolumes(ctx, driverName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("List PVs of driver %s error: %v", driverName, err)
+		return err
+	}
+
+	checked := map[string]bool{}
+	for _, pv := range pvs {
+		if pv.ClaimName == "" {
+			continue
+		}
+
+		alarms, err := getCapacityAlarms(ctx, pv.VolumeHandle)
+		if err != nil {
+			log.AddContext(ctx).Warningf("Get capacity alarms of pvc %s/%s error: %v", pv.ClaimNamespace,
+				pv.ClaimName, err)
+			continue
+		}
+		if alarms == nil {
+			continue
+		}
+
+		checked[pv.VolumeHandle] = true
+		recordCapacityAlarms(ctx, k8sUtils, pv, alarms)
+	}
+
+	// the volumes deleted or no longer checked are forgotten, so that their alarms are reported again
+	// if they come back
+	for volumeHandle := range raisedCapacityAlarms {
+		if !checked[volumeHandle] {
+			delete(raisedCapacityAlarms, volumeHandle)
+		}
+	}
+	return nil
+}
+
+// getCapacityAlarms returns the capacity alarms of the volume, nil if its backend doesn't report them
+func getCapacityAlarms(ctx context.Context, volumeHandle string) (map[string]string, error) {
+	backendName, volName := utils.SplitVolumeId(volumeHandle)
+	if plugin.IsSnapshotVolume(volName) {
+		return nil, nil
+	}
+
+	bk := backend.GetBackend(backendName)
+	if bk == nil || !bk.Available {
+		return nil, nil
+	}
+
+	checker, ok := bk.Plugin.(plugin.CapacityAlarmChecker)
+	if !ok {
+		return nil, nil
+	}
+	return checker.GetCapacityAlarms(ctx, volName)
+}
+
+func recordCapacityAlarms(ctx context.Context, k8sUtils k8sutils.Interface, pv k8sutils.PVInfo,
+	alarms map[string]string) {
+	raised := raisedCapacityAlarms[pv.VolumeHandle]
+	reasons := make([]string, 0, len(alarms))
+	for reason := range alarms {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	for _, reason := range reasons {
+		if _, exist := raised[reason]; exist {
+			continue
+		}
+		log.AddContext(ctx).Warningf("Capacity alarm %s of pvc %s/%s: %s", reason, pv.ClaimNamespace,
+			pv.ClaimName, alarms[reason])
+		recordCapacityAlarmEvent(ctx, k8sUtils, pv, corev1.EventTypeWarning, reason, alarms[reason])
+	}
+
+	if len(alarms) == 0 && len(raised) != 0 {
+		recordCapacityAlarmEvent(ctx, k8sUtils, pv, corev1.EventTypeNormal, capacityAlarmClearedReason,
+			"the capacity alarms of the volume and its storage pool are cleared")
+	}
+	raisedCapacityAlarms[pv.VolumeHandle] = alarms
+}
+
+func recordCapacityAlarmEvent(ctx context.Context, k8sUtils k8sutils.Interface, pv k8sutils.PVInfo,
+	eventType, reason, message string) {
+	err := k8sUtils.RecordClaimEvent(ctx, pv.ClaimNamespace, pv.ClaimName, eventType, reason, message)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Record event %s of pvc %s/%s error: %v", reason, pv.ClaimNamespace,
+			pv.ClaimName, err)
+	}
+}
+
+// reconcileCapacityAlarmsPeriodically reports the capacity alarms of the volumes on the active controller
+func reconcileCapacityAlarmsPeriodically(k8sUtils k8sutils.Interface) {
+	runPeriodically(time.Second*time.Duration(*capacityAlarmSyncInterval), func(ctx context.Context) {
+		_ = reconcileCapacityAlarms(ctx, k8sUtils, *driverName)
+	})
+}
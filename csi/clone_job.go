@@ -0,0 +1,127 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+// reconcileCloneJobs checks the copy of the clone jobs, a job is deleted once its copy is completed or its
+// volume no longer exists. The completion and the failure of a copy are recorded as events of the PVC.
+func reconcileCloneJobs(ctx context.Context, k8sUtils k8sutils.Interface) error {
+	jobs, err := k8sUtils.ListCloneJobs(ctx)
+	if err != nil {
+		log.AddContext(ctx).Errorf("List clone jobs error: %v", err)
+		return err
+	}
+
+	for i := range jobs {
+		reconcileCloneJob(ctx, k8sUtils, &jobs[i])
+	}
+	return nil
+}
+
+func reconcileCloneJob(ctx context.Context, k8sUtils k8sutils.Interface, job *k8sutils.CloneJob) {
+	backendName, volName := utils.SplitVolumeId(job.Spec.VolumeHandle)
+	bk := backend.GetBackend(backendName)
+	if bk == nil || !bk.Available {
+		// checked again when the backend is available
+		return
+	}
+
+	completer, ok := bk.Plugin.(plugin.CloneCompleter)
+	if !ok {
+		deleteCloneJob(ctx, k8sUtils, job)
+		return
+	}
+
+	progress, err := completer.CompleteClone(ctx, volName)
+	if errors.Is(err, utils.ErrNotFound) {
+		log.AddContext(ctx).Infof("Volume %s of clone job %s is deleted", job.Spec.VolumeHandle, job.Name)
+		deleteCloneJob(ctx, k8sUtils, job)
+		return
+	}
+	if err != nil {
+		// a failure is recorded once, the job is still checked in case it is transient
+		log.AddContext(ctx).Errorf("Check copy of volume %s error: %v", job.Spec.VolumeHandle, err)
+		if job.Status.Phase != k8sutils.CloneJobFailed {
+			updateCloneJobStatus(ctx, k8sUtils, job, k8sutils.CloneJobFailed, err.Error())
+			recordCloneJobEvent(ctx, k8sUtils, job, corev1.EventTypeWarning, "CloneFailed", err.Error())
+		}
+		return
+	}
+
+	if progress.Copying {
+		if job.Status.Phase != k8sutils.CloneJobCopying {
+			updateCloneJobStatus(ctx, k8sUtils, job, k8sutils.CloneJobCopying, "")
+		}
+		return
+	}
+
+	if progress.Completed {
+		log.AddContext(ctx).Infof("Copy of volume %s is completed", job.Spec.VolumeHandle)
+		recordCloneJobEvent(ctx, k8sUtils, job, corev1.EventTypeNormal, "CloneCompleted",
+			"The data of the volume is copied from its source")
+	}
+	deleteCloneJob(ctx, k8sUtils, job)
+}
+
+func updateCloneJobStatus(ctx context.Context, k8sUtils k8sutils.Interface, job *k8sutils.CloneJob,
+	phase, message string) {
+	job.Status.Phase, job.Status.Message = phase, message
+	err := k8sUtils.UpdateCloneJobStatus(ctx, job)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Update status of clone job %s error: %v", job.Name, err)
+	}
+}
+
+func deleteCloneJob(ctx context.Context, k8sUtils k8sutils.Interface, job *k8sutils.CloneJob) {
+	err := k8sUtils.DeleteCloneJob(ctx, job.Spec.VolumeHandle)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Delete clone job %s error: %v", job.Name, err)
+	}
+}
+
+func recordCloneJobEvent(ctx context.Context, k8sUtils k8sutils.Interface, job *k8sutils.CloneJob,
+	eventType, reason, message string) {
+	if job.Spec.ClaimName == "" {
+		return
+	}
+
+	err := k8sUtils.RecordClaimEvent(ctx, job.Spec.ClaimNamespace, job.Spec.ClaimName, eventType, reason, message)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Record event of pvc %s/%s error: %v", job.Spec.ClaimNamespace,
+			job.Spec.ClaimName, err)
+	}
+}
+
+// reconcileCloneJobsPeriodically completes the clone jobs on the active controller
+func reconcileCloneJobsPeriodically(k8sUtils k8sutils.Interface) {
+	runPeriodically(time.Second*time.Duration(*cloneJobSyncInterval), func(ctx context.Context) {
+		_ = reconcileCloneJobs(ctx, k8sUtils)
+	})
+}
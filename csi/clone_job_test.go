@@ -0,0 +1,93 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+)
+
+// fakeCloneCompleter returns the progress of the copy of the volumes, or the error
+type fakeCloneCompleter struct {
+	plugin.Plugin
+	progress plugin.CloneProgress
+	err      error
+}
+
+func (f *fakeCloneCompleter) CompleteClone(_ context.Context, _ string) (plugin.CloneProgress, error) {
+	return f.progress, f.err
+}
+
+func TestReconcileCloneJob(t *testing.T) {
+	cases := []struct {
+		name      string
+		phase     string
+		available bool
+		progress  plugin.CloneProgress
+		err       error
+		wantPhase string
+		deleted   bool
+		events    []string
+	}{
+		{"Copying", "", true, plugin.CloneProgress{Copying: true}, nil, k8sutils.CloneJobCopying, false, nil},
+		{"Still copying", k8sutils.CloneJobCopying, true, plugin.CloneProgress{Copying: true}, nil, "", false, nil},
+		{"Completed", k8sutils.CloneJobCopying, true, plugin.CloneProgress{Completed: true}, nil, "", true,
+			[]string{"default/pvc-1 CloneCompleted"}},
+		{"Volume deleted", k8sutils.CloneJobCopying, true, plugin.CloneProgress{}, utils.ErrNotFound, "", true, nil},
+		{"Failed", k8sutils.CloneJobCopying, true, plugin.CloneProgress{}, errors.New("copy fault"),
+			k8sutils.CloneJobFailed, false, []string{"default/pvc-1 CloneFailed"}},
+		{"Still failed", k8sutils.CloneJobFailed, true, plugin.CloneProgress{}, errors.New("copy fault"), "",
+			false, nil},
+		{"Backend unavailable", k8sutils.CloneJobCopying, false, plugin.CloneProgress{}, nil, "", false, nil},
+	}
+
+	for _, c := range cases {
+		completer := &fakeCloneCompleter{progress: c.progress, err: c.err}
+		available := c.available
+		stubs := gostub.Stub(&backend.GetBackend, func(name string) *backend.Backend {
+			return &backend.Backend{Name: name, Available: available, Plugin: completer}
+		})
+
+		job := &k8sutils.CloneJob{}
+		job.Name = "clone-1"
+		job.Spec = k8sutils.CloneJobSpec{VolumeHandle: "backend1.pvc-1", ClaimNamespace: "default",
+			ClaimName: "pvc-1"}
+		job.Status.Phase = c.phase
+		k8sUtils := &fakeK8sUtils{}
+
+		reconcileCloneJob(context.Background(), k8sUtils, job)
+		if c.wantPhase == "" {
+			assert.Empty(t, k8sUtils.updatedJobs, c.name)
+		} else {
+			require.Len(t, k8sUtils.updatedJobs, 1, c.name)
+			assert.Equal(t, c.wantPhase, k8sUtils.updatedJobs[0].Status.Phase, c.name)
+		}
+		assert.Equal(t, c.deleted, len(k8sUtils.deletedJobs) == 1, c.name)
+		assert.Equal(t, c.events, k8sUtils.events, c.name)
+		stubs.Reset()
+	}
+}
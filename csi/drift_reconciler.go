@@ -0,0 +1,131 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	// driftPolicyReport only reports the drift found
+	driftPolicyReport = "report"
+	// driftPolicyRepair repairs the drift which is safe to repair, that is to expand a volume which is
+	// smaller on storage than its PV, and reports the others
+	driftPolicyRepair = "repair"
+
+	// capacityDriftTolerance ignores the capacity difference caused by the alignment on storage
+	capacityDriftTolerance int64 = 1024 * 1024
+)
+
+// reconcileVolumesDrift compares the bound PVs of the driver with the volumes on storage, and reports or
+// repairs the divergences found according to the policy
+func reconcileVolumesDrift(ctx context.Context, k8sUtils k8sutils.Interface, driverName, policy string) error {
+	pvs, err := k8sUtils.ListBoundVolumes(ctx, driverName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("List volumes of driver %s error: %v", driverName, err)
+		return err
+	}
+
+	var drifts int
+	for _, pv := range pvs {
+		if checkVolumeDrift(ctx, pv, policy) {
+			drifts++
+		}
+	}
+
+	log.AddContext(ctx).Infof("Drift reconcile finished, %d of %d volumes diverge from storage", drifts, len(pvs))
+	return nil
+}
+
+// checkVolumeDrift checks a single PV against storage and returns whether it diverges
+func checkVolumeDrift(ctx context.Context, pv k8sutils.PVInfo, policy string) bool {
+	backendName, volName := utils.SplitVolumeId(pv.VolumeHandle)
+	if plugin.IsSnapshotVolume(volName) {
+		return false
+	}
+
+	bk := backend.GetBackend(backendName)
+	if bk == nil {
+		log.AddContext(ctx).Warningf("Drift: backend %s of PV %s doesn't exist", backendName, pv.Name)
+		return true
+	}
+
+	query, ok := bk.Plugin.(plugin.VolumeStateQuery)
+	if !ok || !bk.Available {
+		return false
+	}
+
+	state, err := query.QueryVolumeState(ctx, volName)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Query state of volume %s of PV %s error: %v", volName, pv.Name, err)
+		return false
+	}
+
+	if !state.Exist {
+		log.AddContext(ctx).Errorf("Drift: volume %s of PV %s doesn't exist on backend %s",
+			volName, pv.Name, backendName)
+		return true
+	}
+
+	drift := false
+	if (pv.Attributes["qos"] != "" || pv.Attributes["qosPerGiB"] != "") && state.QoSID == "" {
+		log.AddContext(ctx).Warningf("Drift: QoS %s of PV %s is not associated with volume %s on storage",
+			pv.Attributes["qos"], pv.Name, volName)
+		drift = true
+	}
+
+	if state.Capacity > pv.Capacity+capacityDriftTolerance {
+		log.AddContext(ctx).Warningf("Drift: volume %s is %d bytes on storage, larger than %d bytes of PV %s, "+
+			"expand the PVC to match it", volName, state.Capacity, pv.Capacity, pv.Name)
+		drift = true
+	} else if state.Capacity < pv.Capacity {
+		log.AddContext(ctx).Warningf("Drift: volume %s is %d bytes on storage, smaller than %d bytes of PV %s",
+			volName, state.Capacity, pv.Capacity, pv.Name)
+		drift = true
+		if policy == driftPolicyRepair {
+			repairVolumeCapacity(ctx, bk, volName, pv)
+		}
+	}
+
+	return drift
+}
+
+func repairVolumeCapacity(ctx context.Context, bk *backend.Backend, volName string, pv k8sutils.PVInfo) {
+	_, err := bk.Plugin.ExpandVolume(ctx, volName, pv.Capacity)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Repair: expand volume %s to %d bytes of PV %s error: %v",
+			volName, pv.Capacity, pv.Name, err)
+		return
+	}
+
+	log.AddContext(ctx).Infof("Repair: volume %s is expanded to %d bytes of PV %s, the filesystem is "+
+		"expanded when the volume is staged next time", volName, pv.Capacity, pv.Name)
+}
+
+// reconcileDrift periodically reconciles the drift between PVs and storage on the active controller
+func reconcileDrift(k8sUtils k8sutils.Interface) {
+	runPeriodically(time.Second*time.Duration(*driftReconcileInterval), func(ctx context.Context) {
+		_ = reconcileVolumesDrift(ctx, k8sUtils, *driverName, *driftReconcilePolicy)
+	})
+}
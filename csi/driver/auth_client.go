@@ -0,0 +1,92 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"huawei-csi-driver/storage/oceanstor/volume"
+)
+
+const (
+	// authClientKey is the StorageClass parameter and the PVC annotation of the NFS clients allowed to
+	// access the filesystem, separated by ";"
+	authClientKey = "authClient"
+	// AuthClientAnnotation of a PVC overrides the authClient of the StorageClass when the filesystem is
+	// created, and sets the NFS clients of the share online when it changes
+	AuthClientAnnotation = parameterAnnotationPrefix + authClientKey
+)
+
+// authClientHostPattern matches the host names and wildcards of the NFS clients, e.g. node-*.example.com
+var authClientHostPattern = regexp.MustCompile(`^[A-Za-z0-9*?]([A-Za-z0-9*?.-]*[A-Za-z0-9*?])?$`)
+
+// checkAuthClient checks the authClient parameter of the storage class
+func checkAuthClient(parameters map[string]interface{}) error {
+	authClient, exist := parameters[authClientKey].(string)
+	if !exist {
+		return nil
+	}
+
+	err := ValidateAuthClient(authClient)
+	if err != nil {
+		return fmt.Errorf("%s [%s] in storageClass.yaml is invalid: %v", authClientKey, authClient, err)
+	}
+	return nil
+}
+
+// checkSquash checks the allSquash, rootSquash, anonUid and anonGid parameters of the NFS clients, which only
+// the filesystem and dtree volumes set
+func checkSquash(parameters map[string]interface{}) error {
+	allSquashValue, allExist := parameters["allSquash"].(string)
+	rootSquashValue, rootExist := parameters["rootSquash"].(string)
+	anonUID, uidExist := parameters["anonUid"].(string)
+	anonGID, gidExist := parameters["anonGid"].(string)
+	if !allExist && !rootExist && !uidExist && !gidExist {
+		return nil
+	}
+
+	if !isFileVolume(parameters) {
+		return fmt.Errorf("only the volumes of volumeType fs or dtree can set allSquash, rootSquash, " +
+			"anonUid and anonGid")
+	}
+	return volume.CheckSquash(allSquashValue, rootSquashValue, anonUID, anonGID)
+}
+
+// ValidateAuthClient checks each of the NFS clients separated by ";" is *, an IP, a CIDR, a netgroup
+// starting with @ or a host name
+func ValidateAuthClient(authClient string) error {
+	for _, client := range strings.Split(authClient, ";") {
+		switch {
+		case client == "*", net.ParseIP(client) != nil:
+			continue
+		case strings.Contains(client, "/"):
+			if _, _, err := net.ParseCIDR(client); err != nil {
+				return fmt.Errorf("client %q is not a valid CIDR", client)
+			}
+		case strings.HasPrefix(client, "@"):
+			if !authClientHostPattern.MatchString(client[1:]) {
+				return fmt.Errorf("client %q is not a valid netgroup", client)
+			}
+		case !authClientHostPattern.MatchString(client):
+			return fmt.Errorf("client %q must be *, an IP, a CIDR, a netgroup or a host name", client)
+		}
+	}
+	return nil
+}
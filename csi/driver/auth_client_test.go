@@ -0,0 +1,49 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckAuthClient(t *testing.T) {
+	assert.NoError(t, checkAuthClient(map[string]interface{}{}))
+
+	for _, authClient := range []string{"*", "192.168.1.10", "192.168.1.0/24;10.0.0.5", "fd00::1",
+		"node-*.example.com", "@workers"} {
+		assert.NoError(t, checkAuthClient(map[string]interface{}{authClientKey: authClient}), authClient)
+	}
+
+	for _, authClient := range []string{"", "192.168.1.0/33", "10.0.0.1;", "10.0.0.1,10.0.0.2", "node 1", "@"} {
+		assert.Error(t, checkAuthClient(map[string]interface{}{authClientKey: authClient}), authClient)
+	}
+}
+
+func TestCheckSquash(t *testing.T) {
+	assert.NoError(t, checkSquash(map[string]interface{}{"volumeType": "lun"}))
+	assert.NoError(t, checkSquash(map[string]interface{}{"volumeType": "fs", "rootSquash": "root_squash",
+		"anonUid": "65534", "anonGid": "65534"}))
+	assert.NoError(t, checkSquash(map[string]interface{}{"volumeType": "dtree", "allSquash": "all_squash",
+		"anonUid": "1000"}))
+	assert.Error(t, checkSquash(map[string]interface{}{"volumeType": "lun", "rootSquash": "root_squash"}))
+	assert.Error(t, checkSquash(map[string]interface{}{"volumeType": "fs", "rootSquash": "squash"}))
+	assert.Error(t, checkSquash(map[string]interface{}{"volumeType": "fs", "anonUid": "65534"}))
+	assert.Error(t, checkSquash(map[string]interface{}{"volumeType": "fs", "allSquash": "all_squash",
+		"anonGid": "-1"}))
+}
@@ -0,0 +1,120 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+var (
+	// CapacityGranularity is the granularity in bytes which volume sizes are rounded up to before being
+	// sent to storage. 0 means sizes are sent as requested, and storage rejects sizes it cannot allocate.
+	CapacityGranularity int64 = 0
+	// MinVolumeSize is the minimum size in bytes of a volume, smaller requests are raised to it
+	MinVolumeSize int64 = 0
+)
+
+// grantCapacity returns the size to allocate for the capacity range, which is the required bytes raised
+// to MinVolumeSize and rounded up to CapacityGranularity. OutOfRange is returned if it exceeds the limit.
+func grantCapacity(ctx context.Context, capacityRange *csi.CapacityRange) (int64, error) {
+	size := capacityRange.GetRequiredBytes()
+	if size < MinVolumeSize {
+		size = MinVolumeSize
+	}
+
+	if CapacityGranularity > 0 {
+		size = utils.RoundUpSize(size, CapacityGranularity) * CapacityGranularity
+	}
+
+	limit := capacityRange.GetLimitBytes()
+	if limit > 0 && size > limit {
+		msg := "granted capacity %d exceeds the limit %d of the request, the granularity is %d " +
+			"and the minimum size is %d"
+		log.AddContext(ctx).Errorf(msg, size, limit, CapacityGranularity, MinVolumeSize)
+		return 0, status.Errorf(codes.OutOfRange, msg, size, limit, CapacityGranularity, MinVolumeSize)
+	}
+
+	if size != capacityRange.GetRequiredBytes() {
+		log.AddContext(ctx).Infof("Required capacity %d is granted as %d", capacityRange.GetRequiredBytes(), size)
+	}
+	return size, nil
+}
+
+// getExpandedCapacity returns the capacity on storage if the volume is already not smaller than the size,
+// so that a retried expansion never asks storage to shrink the volume. 0 is returned otherwise.
+func getExpandedCapacity(ctx context.Context, b *backend.Backend, volName string, size int64) int64 {
+	query, ok := b.Plugin.(plugin.VolumeStateQuery)
+	if !ok {
+		return 0
+	}
+
+	state, err := query.QueryVolumeState(ctx, volName)
+	if err != nil || !state.Exist {
+		log.AddContext(ctx).Warningf("Query capacity of volume %s error: %v", volName, err)
+		return 0
+	}
+
+	if state.Capacity < size {
+		return 0
+	}
+
+	return state.Capacity
+}
+
+// getVolume returns the volume with its capacity on storage, and its condition on storage. The capacity
+// consumed in the storage pool is reported in the volume context as consumedCapacity, if storage reports it.
+func getVolume(ctx context.Context, b *backend.Backend, volumeID, volName string) (
+	*csi.Volume, *csi.VolumeCondition, error) {
+	query, ok := b.Plugin.(plugin.VolumeStateQuery)
+	if !ok {
+		return nil, nil, status.Errorf(codes.Unimplemented, "backend %s can not query volumes", b.Name)
+	}
+
+	state, err := query.QueryVolumeState(ctx, volName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Query volume %s error: %v", volName, err)
+		return nil, nil, toStatusError(err)
+	}
+	if !state.Exist {
+		return nil, nil, status.Errorf(codes.NotFound, "volume %s doesn't exist", volumeID)
+	}
+
+	volume := &csi.Volume{
+		VolumeId:      volumeID,
+		CapacityBytes: state.Capacity,
+		VolumeContext: map[string]string{"backend": b.Name, "name": volName},
+	}
+	if state.Consumed > 0 {
+		volume.VolumeContext["consumedCapacity"] = strconv.FormatInt(state.Consumed, 10)
+	}
+
+	condition := &csi.VolumeCondition{Abnormal: state.Abnormal, Message: state.Message}
+	if !state.Abnormal {
+		condition.Message = "The volume is normal on storage"
+	}
+	return volume, condition, nil
+}
@@ -0,0 +1,50 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"huawei-csi-driver/storage/oceanstor/volume"
+)
+
+// capacityAlarmThresholdKey is the StorageClass parameter of the share of a thin LUN allocated in
+// percent above which the storage raises a capacity alarm
+const capacityAlarmThresholdKey = "capacityAlarmThreshold"
+
+// checkCapacityAlarmThreshold checks the capacityAlarmThreshold parameter, which only thin LUNs set
+func checkCapacityAlarmThreshold(parameters map[string]interface{}) error {
+	v, exist := parameters[capacityAlarmThresholdKey].(string)
+	if !exist {
+		return nil
+	}
+
+	threshold, err := strconv.Atoi(v)
+	if err != nil || threshold < volume.CapacityAlarmThresholdMin || threshold > volume.CapacityAlarmThresholdMax {
+		return fmt.Errorf("%s [%s] in storageClass.yaml must be an integer from %d to %d", capacityAlarmThresholdKey,
+			v, volume.CapacityAlarmThresholdMin, volume.CapacityAlarmThresholdMax)
+	}
+	if isFileVolume(parameters) {
+		return fmt.Errorf("only the volumes of volumeType lun can set %s", capacityAlarmThresholdKey)
+	}
+	if parameters["allocType"] == "thick" {
+		return errors.New("thick LUNs are fully allocated, they can't set " + capacityAlarmThresholdKey)
+	}
+	return nil
+}
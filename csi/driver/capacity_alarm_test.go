@@ -0,0 +1,35 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCapacityAlarmThreshold(t *testing.T) {
+	assert.NoError(t, checkCapacityAlarmThreshold(map[string]interface{}{}))
+	assert.NoError(t, checkCapacityAlarmThreshold(map[string]interface{}{capacityAlarmThresholdKey: "80",
+		"volumeType": "lun", "allocType": "thin"}))
+	assert.Error(t, checkCapacityAlarmThreshold(map[string]interface{}{capacityAlarmThresholdKey: "100"}))
+	assert.Error(t, checkCapacityAlarmThreshold(map[string]interface{}{capacityAlarmThresholdKey: "high"}))
+	assert.Error(t, checkCapacityAlarmThreshold(map[string]interface{}{capacityAlarmThresholdKey: "80",
+		"volumeType": "fs"}))
+	assert.Error(t, checkCapacityAlarmThreshold(map[string]interface{}{capacityAlarmThresholdKey: "80",
+		"allocType": "thick"}))
+}
@@ -0,0 +1,95 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+)
+
+func TestGrantCapacity(t *testing.T) {
+	const mi = 1024 * 1024
+
+	var testCases = []struct {
+		name        string
+		granularity int64
+		minSize     int64
+		required    int64
+		limit       int64
+		want        int64
+		code        codes.Code
+	}{
+		{"noRounding", 0, 0, 1000, 0, 1000, codes.OK},
+		{"roundUp", 512, 0, 1000, 0, 1024, codes.OK},
+		{"aligned", mi, 0, 2 * mi, 0, 2 * mi, codes.OK},
+		{"raiseToMinimum", mi, 4 * mi, mi, 0, 4 * mi, codes.OK},
+		{"withinLimit", mi, 0, mi + 1, 2 * mi, 2 * mi, codes.OK},
+		{"exceedLimit", mi, 0, mi + 1, mi + 512, 0, codes.OutOfRange},
+	}
+
+	defer func(granularity, minSize int64) {
+		CapacityGranularity, MinVolumeSize = granularity, minSize
+	}(CapacityGranularity, MinVolumeSize)
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			CapacityGranularity, MinVolumeSize = c.granularity, c.minSize
+			size, err := grantCapacity(context.Background(),
+				&csi.CapacityRange{RequiredBytes: c.required, LimitBytes: c.limit})
+			assert.Equal(t, c.code, status.Code(err))
+			assert.Equal(t, c.want, size)
+		})
+	}
+}
+
+type fakeVolumeStatePlugin struct {
+	plugin.Plugin
+	state *plugin.VolumeState
+}
+
+func (p *fakeVolumeStatePlugin) QueryVolumeState(ctx context.Context, name string) (*plugin.VolumeState, error) {
+	return p.state, nil
+}
+
+func TestGetVolume(t *testing.T) {
+	b := &backend.Backend{Name: "backend", Plugin: &fakeVolumeStatePlugin{
+		state: &plugin.VolumeState{Exist: true, Capacity: 10 * 1024 * 1024, Consumed: 1024 * 1024},
+	}}
+	volume, condition, err := getVolume(context.Background(), b, "backend.vol", "vol")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10*1024*1024), volume.CapacityBytes)
+	assert.Equal(t, "1048576", volume.VolumeContext["consumedCapacity"])
+	assert.False(t, condition.Abnormal)
+
+	b.Plugin = &fakeVolumeStatePlugin{state: &plugin.VolumeState{Exist: true, Abnormal: true, Message: "fault"}}
+	_, condition, err = getVolume(context.Background(), b, "backend.vol", "vol")
+	assert.NoError(t, err)
+	assert.True(t, condition.Abnormal)
+	assert.Equal(t, "fault", condition.Message)
+
+	b.Plugin = &fakeVolumeStatePlugin{state: &plugin.VolumeState{}}
+	_, _, err = getVolume(context.Background(), b, "backend.vol", "vol")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
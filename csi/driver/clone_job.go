@@ -0,0 +1,101 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+// CloneJobEnabled tracks the copy of the volumes created from a source with clone jobs, instead of waiting
+// for the copy in CreateVolume. The controller completes the clone jobs, even after it restarts.
+var CloneJobEnabled = false
+
+// prepareAsyncCopy lets the plugin return the volume created from a source once its copy is started, if the
+// copy is tracked by a clone job or the volume is usable before the data is copied by fastClone
+func (d *Driver) prepareAsyncCopy(req *csi.CreateVolumeRequest, parameters map[string]interface{}) {
+	if req.GetVolumeContentSource() == nil {
+		return
+	}
+
+	if parameters["fastClone"] == "true" || (CloneJobEnabled && d.k8sUtils != nil) {
+		parameters["asyncCopy"] = "true"
+	}
+}
+
+// trackAsyncCopy records the clone job of a volume whose copy is started asynchronously, the plugins
+// which can't complete the copy asynchronously return the volume after the copy. Unless the volume is
+// created by fastClone and usable while copying, ErrCopyInProgress is returned until the data is copied,
+// so that CreateVolume is retried by the provisioner and returns the volume only after that.
+func (d *Driver) trackAsyncCopy(ctx context.Context, parameters map[string]interface{},
+	pool *backend.StoragePool, volName string) error {
+	completer, ok := pool.Plugin.(plugin.CloneCompleter)
+	if parameters["asyncCopy"] != "true" || !ok {
+		return nil
+	}
+
+	volumeHandle := pool.Parent + "." + volName
+	fastClone := parameters["fastClone"] == "true"
+	if CloneJobEnabled && d.k8sUtils != nil {
+		claimName, _ := parameters[pvcNameKey].(string)
+		namespace, _ := parameters[pvcNamespaceKey].(string)
+		err := d.k8sUtils.CreateCloneJob(ctx, k8sutils.CloneJobSpec{
+			VolumeHandle:   volumeHandle,
+			ClaimNamespace: namespace,
+			ClaimName:      claimName,
+			FastClone:      fastClone,
+		})
+		if err != nil {
+			return utils.Errorf(ctx, "record clone job of volume %s error: %v", volumeHandle, err)
+		}
+	}
+
+	progress, err := completer.CompleteClone(ctx, volName)
+	if err != nil {
+		return err
+	}
+	if progress.Copying && fastClone && progress.Usable {
+		log.AddContext(ctx).Infof("Volume %s is usable while its data is being copied", volName)
+		return nil
+	}
+	if progress.Copying {
+		return utils.KindErrorf(ctx, utils.ErrCopyInProgress,
+			"Data of volume %s is being copied from its source, it is created after that", volName)
+	}
+
+	d.forgetAsyncCopy(ctx, volumeHandle)
+	return nil
+}
+
+// forgetAsyncCopy deletes the clone job of the volume, whose copy is completed or which is deleted
+func (d *Driver) forgetAsyncCopy(ctx context.Context, volumeHandle string) {
+	if !CloneJobEnabled || d.k8sUtils == nil {
+		return
+	}
+
+	err := d.k8sUtils.DeleteCloneJob(ctx, volumeHandle)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Delete clone job of volume %s error: %v", volumeHandle, err)
+	}
+}
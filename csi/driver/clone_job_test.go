@@ -0,0 +1,87 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+)
+
+type fakeCloneCompleter struct {
+	plugin.Plugin
+	progress plugin.CloneProgress
+}
+
+func (p *fakeCloneCompleter) CompleteClone(ctx context.Context, name string) (plugin.CloneProgress, error) {
+	return p.progress, nil
+}
+
+type fakeCloneJobs struct {
+	k8sutils.Interface
+	jobs map[string]k8sutils.CloneJobSpec
+}
+
+func (f *fakeCloneJobs) CreateCloneJob(ctx context.Context, spec k8sutils.CloneJobSpec) error {
+	f.jobs[spec.VolumeHandle] = spec
+	return nil
+}
+
+func (f *fakeCloneJobs) DeleteCloneJob(ctx context.Context, volumeHandle string) error {
+	delete(f.jobs, volumeHandle)
+	return nil
+}
+
+func TestTrackAsyncCopy(t *testing.T) {
+	defer func(enabled bool) { CloneJobEnabled = enabled }(CloneJobEnabled)
+	CloneJobEnabled = true
+
+	cases := []struct {
+		name      string
+		fastClone string
+		progress  plugin.CloneProgress
+		wantErr   error
+		wantJob   bool
+	}{
+		{"Copying", "false", plugin.CloneProgress{Copying: true, Usable: true}, utils.ErrCopyInProgress, true},
+		{"Completed", "false", plugin.CloneProgress{Completed: true}, nil, false},
+		{"Fast clone usable", "true", plugin.CloneProgress{Copying: true, Usable: true}, nil, true},
+		{"Fast clone by luncopy", "true", plugin.CloneProgress{Copying: true}, utils.ErrCopyInProgress, true},
+	}
+
+	for _, c := range cases {
+		k8s := &fakeCloneJobs{jobs: map[string]k8sutils.CloneJobSpec{}}
+		d := &Driver{k8sUtils: k8s}
+		pool := &backend.StoragePool{Parent: "backend", Plugin: &fakeCloneCompleter{progress: c.progress}}
+		parameters := map[string]interface{}{"asyncCopy": "true", "fastClone": c.fastClone}
+
+		err := d.trackAsyncCopy(context.Background(), parameters, pool, "pvc-1")
+		assert.Equal(t, c.wantErr == nil, err == nil, c.name)
+		if c.wantErr != nil {
+			assert.True(t, errors.Is(err, c.wantErr), c.name)
+		}
+		_, exist := k8s.jobs["backend.pvc-1"]
+		assert.Equal(t, c.wantJob, exist, c.name)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	// cloneSpeedAnnotation is the PVC or VolumeSnapshot annotation overriding the clonespeed of
+	// the storage class for a single clone, 1 to 4
+	cloneSpeedAnnotation = "csi.huawei.com/cloneSpeed"
+
+	// pvcNameKey and pvcNamespaceKey are passed by the csi-provisioner with --extra-create-metadata
+	pvcNameKey      = "csi.storage.k8s.io/pvc/name"
+	pvcNamespaceKey = "csi.storage.k8s.io/pvc/namespace"
+
+	volumeSnapshotKind = "VolumeSnapshot"
+)
+
+// processCloneSpeedAnnotation overrides the clone speed of a volume created from a volume or
+// snapshot with the annotation of its PVC, or else of the source VolumeSnapshot
+func (d *Driver) processCloneSpeedAnnotation(ctx context.Context, req *csi.CreateVolumeRequest,
+	parameters map[string]interface{}) error {
+	if req.GetVolumeContentSource() == nil || d.k8sUtils == nil {
+		return nil
+	}
+
+	claimName, _ := parameters[pvcNameKey].(string)
+	namespace, _ := parameters[pvcNamespaceKey].(string)
+	if claimName == "" || namespace == "" {
+		return nil
+	}
+
+	pvc, err := d.k8sUtils.GetClaim(ctx, namespace, claimName)
+	if err != nil {
+		return toStatusError(utils.Errorf(ctx, "get pvc %s/%s error: %v", namespace, claimName, err))
+	}
+
+	speed, source := pvc.Annotations[cloneSpeedAnnotation], "pvc "+namespace+"/"+claimName
+	dataSource := pvc.Spec.DataSource
+	if speed == "" && dataSource != nil && dataSource.Kind == volumeSnapshotKind {
+		annotations, err := d.k8sUtils.GetSnapshotAnnotations(ctx, namespace, dataSource.Name)
+		if err != nil {
+			return toStatusError(utils.Errorf(ctx, "get volume snapshot %s/%s error: %v",
+				namespace, dataSource.Name, err))
+		}
+		speed, source = annotations[cloneSpeedAnnotation], "volume snapshot "+namespace+"/"+dataSource.Name
+	}
+
+	if speed == "" {
+		return nil
+	}
+
+	value, err := strconv.Atoi(speed)
+	if err != nil || value < 1 || value > 4 {
+		msg := fmt.Sprintf("annotation %s of %s must be 1 to 4, not %s", cloneSpeedAnnotation, source, speed)
+		log.AddContext(ctx).Errorln(msg)
+		return status.Error(codes.InvalidArgument, msg)
+	}
+
+	log.AddContext(ctx).Infof("Clone speed %d of %s overrides the storage class", value, source)
+	parameters["cloneSpeed"] = speed
+	return nil
+}
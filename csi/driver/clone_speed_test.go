@@ -0,0 +1,82 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"huawei-csi-driver/utils/k8sutils"
+)
+
+type fakeCloneSpeedClaim struct {
+	k8sutils.Interface
+	pvc                 *corev1.PersistentVolumeClaim
+	snapshotAnnotations map[string]string
+}
+
+func (f *fakeCloneSpeedClaim) GetClaim(ctx context.Context, namespace, claimName string) (
+	*corev1.PersistentVolumeClaim, error) {
+	return f.pvc, nil
+}
+
+func (f *fakeCloneSpeedClaim) GetSnapshotAnnotations(ctx context.Context, namespace, snapshotName string) (
+	map[string]string, error) {
+	return f.snapshotAnnotations, nil
+}
+
+func TestProcessCloneSpeedAnnotation(t *testing.T) {
+	snapshotSource := &corev1.TypedLocalObjectReference{Kind: volumeSnapshotKind, Name: "snap"}
+	cases := []struct {
+		name                string
+		claimAnnotations    map[string]string
+		snapshotAnnotations map[string]string
+		want                interface{}
+		wantErr             bool
+	}{
+		{"No annotation", nil, nil, "2", false},
+		{"PVC annotation", map[string]string{cloneSpeedAnnotation: "4"}, nil, "4", false},
+		{"Snapshot annotation", nil, map[string]string{cloneSpeedAnnotation: "1"}, "1", false},
+		{"PVC annotation wins", map[string]string{cloneSpeedAnnotation: "4"},
+			map[string]string{cloneSpeedAnnotation: "1"}, "4", false},
+		{"Invalid annotation", map[string]string{cloneSpeedAnnotation: "5"}, nil, "2", true},
+	}
+
+	req := &csi.CreateVolumeRequest{VolumeContentSource: &csi.VolumeContentSource{
+		Type: &csi.VolumeContentSource_Snapshot{Snapshot: &csi.VolumeContentSource_SnapshotSource{}},
+	}}
+	for _, c := range cases {
+		d := &Driver{k8sUtils: &fakeCloneSpeedClaim{
+			pvc: &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Annotations: c.claimAnnotations},
+				Spec:       corev1.PersistentVolumeClaimSpec{DataSource: snapshotSource},
+			},
+			snapshotAnnotations: c.snapshotAnnotations,
+		}}
+		parameters := map[string]interface{}{
+			"cloneSpeed": "2", pvcNameKey: "pvc", pvcNamespaceKey: "default",
+		}
+		err := d.processCloneSpeedAnnotation(context.Background(), req, parameters)
+		assert.Equal(t, c.wantErr, err != nil, c.name)
+		assert.Equal(t, c.want, parameters["cloneSpeed"], c.name)
+	}
+}
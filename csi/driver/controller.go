@@ -26,10 +26,13 @@ import (
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/golang/protobuf/ptypes/wrappers"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/storage/oceanstor/volume"
 	"huawei-csi-driver/utils"
 	"huawei-csi-driver/utils/log"
 )
@@ -40,20 +43,19 @@ const (
 	FileSystem = "FileSystem"
 )
 
-var nfsProtocolMap = map[string]string{
-	// nfsvers=3.0 is not support
-	"nfsvers=3":   "nfs3",
-	"nfsvers=4":   "nfs4",
-	"nfsvers=4.0": "nfs4",
-	"nfsvers=4.1": "nfs41",
-}
-
 func (d *Driver) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
 	defer utils.RecoverPanic(ctx)
 
 	volumeName := req.GetName()
 	log.AddContext(ctx).Infof("Start to create volume %s", volumeName)
 
+	if _, loaded := d.volumeInFlight.LoadOrStore(volumeName, struct{}{}); loaded {
+		msg := fmt.Sprintf("Volume %s is already being created", volumeName)
+		log.AddContext(ctx).Warningln(msg)
+		return nil, status.Error(codes.Aborted, msg)
+	}
+	defer d.volumeInFlight.Delete(volumeName)
+
 	capacityRange := req.GetCapacityRange()
 	if capacityRange == nil || capacityRange.RequiredBytes <= 0 {
 		msg := "CreateVolume CapacityRange must be provided"
@@ -67,8 +69,29 @@ func (d *Driver) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest)
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	size := capacityRange.RequiredBytes
-	parameters["size"] = capacityRange.RequiredBytes
+	size, err := grantCapacity(ctx, capacityRange)
+	if err != nil {
+		return nil, err
+	}
+	parameters["size"] = size
+
+	if isDirectSnapshotAccess(ctx, req) {
+		volume, err := d.createSnapshotVolume(ctx, req, size)
+		if err != nil {
+			return nil, err
+		}
+		return &csi.CreateVolumeResponse{Volume: volume}, nil
+	}
+
+	overrides, err := d.processParameterAnnotations(ctx, parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.processScaledQoS(ctx, parameters, size)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 
 	cloneFrom, exist := parameters["cloneFrom"].(string)
 	if exist && cloneFrom != "" {
@@ -81,11 +104,29 @@ func (d *Driver) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest)
 		return nil, err
 	}
 
+	err = d.processCloneSpeedAnnotation(ctx, req, parameters)
+	if err != nil {
+		return nil, err
+	}
+
 	// process accessibility requirements. Topology
 	d.processAccessibilityRequirements(ctx, req, parameters)
 	err = d.processNFSProtocol(ctx, req, parameters)
 	if err != nil {
-		return nil, err
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	processCifsUser(req, parameters)
+
+	err = checkMkfsOptions(req, parameters)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Invalid mkfs options of volume %s: %v", volumeName, err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = checkMountFlags(req, parameters)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Invalid mount flags of volume %s: %v", volumeName, err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
 	msg := d.validateModeAndType(req, parameters)
@@ -94,13 +135,20 @@ func (d *Driver) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest)
 		return nil, status.Error(codes.InvalidArgument, msg)
 	}
 
+	err = d.pinVolumePlacement(ctx, volumeName, parameters)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
 	localPool, remotePool, err := backend.SelectStoragePool(ctx, size, parameters)
+	d.checkPoolReserve(ctx, parameters, localPool, err, size)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Cannot select pool for volume creation: %v", err)
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, toStatusError(err)
 	}
 
 	parameters["storagepool"] = localPool.Name
+	applyWorkloadProfile(ctx, parameters, localPool)
 	if remotePool != nil {
 		parameters["metroDomain"] = backend.GetMetroDomain(remotePool.Parent)
 		parameters["vStorePairID"] = backend.GetMetrovStorePairID(remotePool.Parent)
@@ -109,15 +157,25 @@ func (d *Driver) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest)
 
 	parameters["accountName"] = backend.GetAccountName(localPool.Parent)
 
+	d.prepareAsyncCopy(req, parameters)
 	vol, err := localPool.Plugin.CreateVolume(ctx, volumeName, parameters)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Create volume %s error: %v", volumeName, err)
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, toStatusError(err)
+	}
+
+	err = d.trackAsyncCopy(ctx, parameters, localPool, vol.GetVolumeName())
+	if err != nil {
+		return nil, toStatusError(err)
 	}
 
-	volume, err := d.getCreatedVolume(ctx, req, vol, localPool)
+	volume, err := d.getCreatedVolume(ctx, req, vol, localPool, size)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, toStatusError(err)
+	}
+	// Record the QoS requested by the PVC instead of the storage class
+	if qos := overrides["qos"]; qos != "" {
+		volume.VolumeContext["qos"] = qos
 	}
 
 	log.AddContext(ctx).Infof("Volume %s is created", volumeName)
@@ -133,7 +191,89 @@ func (d *Driver) checkStorageClassParameters(ctx context.Context, parameters map
 		return err
 	}
 
-	return nil
+	err = checkEncrypted(parameters)
+	if err != nil {
+		return err
+	}
+
+	err = checkFsBeforeMount(parameters)
+	if err != nil {
+		return err
+	}
+
+	err = checkDataReduction(parameters)
+	if err != nil {
+		return err
+	}
+
+	err = checkSmartTier(parameters)
+	if err != nil {
+		return err
+	}
+
+	err = checkFastClone(parameters)
+	if err != nil {
+		return err
+	}
+
+	err = checkHyperMetroOptions(parameters)
+	if err != nil {
+		return err
+	}
+
+	err = checkReplicationOptions(parameters)
+	if err != nil {
+		return err
+	}
+
+	err = checkSpaceReclamation(parameters)
+	if err != nil {
+		return err
+	}
+
+	err = checkCapacityAlarmThreshold(parameters)
+	if err != nil {
+		return err
+	}
+
+	err = checkAuthClient(parameters)
+	if err != nil {
+		return err
+	}
+
+	err = checkSquash(parameters)
+	if err != nil {
+		return err
+	}
+
+	err = checkSnapshotDirectoryVisibility(parameters)
+	if err != nil {
+		return err
+	}
+
+	err = checkNFSSecurity(parameters)
+	if err != nil {
+		return err
+	}
+
+	err = checkSectorSize(parameters)
+	if err != nil {
+		return err
+	}
+
+	err = checkQuota(parameters)
+	if err != nil {
+		return err
+	}
+
+	return checkWorkloadHint(parameters)
+}
+
+// isFileVolume returns whether the volumes of the parameters are shared by NAS, either as filesystems or as
+// dtrees of a filesystem
+func isFileVolume(parameters map[string]interface{}) bool {
+	volumeType := parameters["volumeType"]
+	return volumeType == "fs" || volumeType == "dtree"
 }
 
 func (d *Driver) checkFsPermission(ctx context.Context, parameters map[string]interface{}) error {
@@ -153,10 +293,139 @@ func (d *Driver) checkFsPermission(ctx context.Context, parameters map[string]in
 	return nil
 }
 
+// checkEncrypted checks the encrypted parameter, only the LUNs can be encrypted on the nodes
+func checkEncrypted(parameters map[string]interface{}) error {
+	encrypted, exist := parameters["encrypted"].(string)
+	if !exist {
+		return nil
+	}
+
+	if encrypted != "true" && encrypted != "false" {
+		return fmt.Errorf("encrypted [%s] in storageClass.yaml must be true or false", encrypted)
+	}
+	if encrypted == "true" && isFileVolume(parameters) {
+		return errors.New("only the volumes of volumeType lun can be encrypted")
+	}
+	return nil
+}
+
+// checkFsBeforeMount checks the checkFsBeforeMount parameter, only the filesystems on the LUNs are
+// checked by the nodes
+func checkFsBeforeMount(parameters map[string]interface{}) error {
+	checkFs, exist := parameters["checkFsBeforeMount"].(string)
+	if !exist {
+		return nil
+	}
+
+	if checkFs != "true" && checkFs != "false" {
+		return fmt.Errorf("checkFsBeforeMount [%s] in storageClass.yaml must be true or false", checkFs)
+	}
+	if checkFs == "true" && isFileVolume(parameters) {
+		return errors.New("only the filesystems of volumeType lun can be checked before mount")
+	}
+	return nil
+}
+
+// checkDataReduction checks the dedup and compression parameters, data reduction is only available on
+// thin volumes
+func checkDataReduction(parameters map[string]interface{}) error {
+	for _, key := range []string{"dedup", "compression"} {
+		value, exist := parameters[key].(string)
+		if !exist {
+			continue
+		}
+
+		if value != "true" && value != "false" {
+			return fmt.Errorf("%s [%s] in storageClass.yaml must be true or false", key, value)
+		}
+		if value == "true" && parameters["allocType"] == "thick" {
+			return fmt.Errorf("%s is only available on the volumes of allocType thin", key)
+		}
+	}
+	return nil
+}
+
+// checkFastClone checks the fastClone parameter, only the LUNs cloned by clone pairs are usable before
+// their data is copied
+func checkFastClone(parameters map[string]interface{}) error {
+	value, exist := parameters["fastClone"].(string)
+	if !exist {
+		return nil
+	}
+
+	if value != "true" && value != "false" {
+		return fmt.Errorf("fastClone [%s] in storageClass.yaml must be true or false", value)
+	}
+	if value == "true" && isFileVolume(parameters) {
+		return errors.New("only the volumes of volumeType lun can set fastClone")
+	}
+	return nil
+}
+
+// checkHyperMetroOptions checks the HyperMetro parameters, which the array checks again when the volume is
+// created, as the support of them depends on the array
+func checkHyperMetroOptions(parameters map[string]interface{}) error {
+	for _, key := range volume.HyperMetroOptionKeys {
+		value, exist := parameters[key].(string)
+		if !exist {
+			continue
+		}
+
+		if err := volume.CheckHyperMetroOption(key, value); err != nil {
+			return fmt.Errorf("%v in storageClass.yaml", err)
+		}
+		if parameters["hyperMetro"] != "true" {
+			return fmt.Errorf("%s is only available on the volumes of hyperMetro true", key)
+		}
+	}
+
+	if _, exist := parameters["hyperMetroGroup"]; exist {
+		if parameters["hyperMetro"] != "true" {
+			return errors.New("hyperMetroGroup is only available on the volumes of hyperMetro true")
+		}
+		if isFileVolume(parameters) {
+			return errors.New("only the volumes of volumeType lun can set hyperMetroGroup")
+		}
+	}
+	return nil
+}
+
+// checkReplicationOptions checks the replication parameters and consistency group, which the array checks
+// again when the volume is created. The synchronous replication pairs are neither synchronized by type nor by period.
+func checkReplicationOptions(parameters map[string]interface{}) error {
+	for _, key := range volume.ReplicationOptionKeys {
+		value, exist := parameters[key].(string)
+		if !exist {
+			continue
+		}
+
+		if err := volume.CheckReplicationOption(key, value); err != nil {
+			return fmt.Errorf("%v in storageClass.yaml", err)
+		}
+		if parameters["replication"] != "true" {
+			return fmt.Errorf("%s is only available on the volumes of replication true", key)
+		}
+	}
+
+	if _, exist := parameters["replicationGroup"]; exist && parameters["replication"] != "true" {
+		return errors.New("replicationGroup is only available on the volumes of replication true")
+	}
+
+	if parameters["replicationModel"] != volume.ReplicationModelSync {
+		return nil
+	}
+	for _, key := range []string{"replicationSyncType", "replicationSyncPeriod"} {
+		if _, exist := parameters[key]; exist {
+			return fmt.Errorf("%s is not available on the volumes of replicationModel %s", key,
+				volume.ReplicationModelSync)
+		}
+	}
+	return nil
+}
+
 func (d *Driver) getCreatedVolume(ctx context.Context, req *csi.CreateVolumeRequest, vol utils.Volume,
-	pool *backend.StoragePool) (*csi.Volume, error) {
+	pool *backend.StoragePool, size int64) (*csi.Volume, error) {
 	contentSource := req.GetVolumeContentSource()
-	size := req.GetCapacityRange().GetRequiredBytes()
 
 	accessibleTopologies := make([]*csi.Topology, 0)
 	if req.GetAccessibilityRequirements() != nil &&
@@ -175,11 +444,59 @@ func (d *Driver) getCreatedVolume(ctx context.Context, req *csi.CreateVolumeRequ
 		"name":         volName,
 		"fsPermission": req.Parameters["fsPermission"],
 	}
+	// Record the requested NFS version so that the nodes mount the share with it
+	if nfsVersion := req.Parameters["nfsVersion"]; nfsVersion != "" {
+		attributes["nfsVersion"] = nfsVersion
+	}
+	// Record the security flavors so that the nodes mount the share with the sec option of them
+	if flavors := req.Parameters[nfsSecurityFlavorsKey]; flavors != "" {
+		attributes[nfsSecurityFlavorsKey] = flavors
+	}
 
 	if lunWWN, err := vol.GetLunWWN(); err == nil {
 		attributes["lunWWN"] = lunWWN
 	}
 
+	// Record the encryption so that the nodes open the LUKS mapping on the LUN
+	if req.Parameters["encrypted"] == "true" {
+		attributes["encrypted"] = "true"
+	}
+
+	// Record the filesystem check so that the nodes check the filesystem before staging it
+	if req.Parameters["checkFsBeforeMount"] == "true" {
+		attributes["checkFsBeforeMount"] = "true"
+	}
+
+	// Record the space reclamation so that the nodes mount with discard or trim the filesystem
+	if reclamation := req.Parameters["spaceReclamation"]; reclamation != "" {
+		attributes["spaceReclamation"] = reclamation
+	}
+
+	// Record the SmartTier policy so that it is set again when the LUN is expanded
+	if policy := req.Parameters[smartTierKey]; policy != "" {
+		attributes[smartTierKey] = policy
+	}
+
+	// Record the mkfs options so that the nodes format the LUN with them
+	if mkfsOptions := req.Parameters["mkfsOptions"]; mkfsOptions != "" {
+		attributes["mkfsOptions"] = mkfsOptions
+	}
+
+	// Record the requested QoS so that the QoS removed on storage can be detected later
+	if qos := req.Parameters["qos"]; qos != "" {
+		attributes["qos"] = qos
+	}
+	if qosPerGiB := req.Parameters[qosPerGiBKey]; qosPerGiB != "" {
+		attributes[qosPerGiBKey] = qosPerGiB
+	}
+
+	// Record the quota parameters so that the quota is recomputed when the volume is expanded
+	for _, key := range quotaKeys {
+		if v := req.Parameters[key]; v != "" {
+			attributes[key] = v
+		}
+	}
+
 	csiVolume := &csi.Volume{
 		VolumeId:           pool.Parent + "." + volName,
 		CapacityBytes:      size,
@@ -203,13 +520,13 @@ func (d *Driver) processVolumeContentSource(ctx context.Context, req *csi.Create
 			sourceBackendName, snapshotParentId, sourceSnapshotName := utils.SplitSnapshotId(sourceSnapshotId)
 			parameters["sourceSnapshotName"] = sourceSnapshotName
 			parameters["snapshotParentId"] = snapshotParentId
-			parameters["backend"] = sourceBackendName
+			setCloneSourceBackend(ctx, parameters, sourceBackendName)
 			log.AddContext(ctx).Infof("Start to create volume from snapshot %s", sourceSnapshotName)
 		} else if contentVolume := contentSource.GetVolume(); contentVolume != nil {
 			sourceVolumeId := contentVolume.GetVolumeId()
 			sourceBackendName, sourceVolumeName := utils.SplitVolumeId(sourceVolumeId)
 			parameters["sourceVolumeName"] = sourceVolumeName
-			parameters["backend"] = sourceBackendName
+			setCloneSourceBackend(ctx, parameters, sourceBackendName)
 			log.AddContext(ctx).Infof("Start to create volume from volume %s", sourceVolumeName)
 		} else {
 			log.AddContext(ctx).Errorf("The source %s is not snapshot either volume", contentSource)
@@ -220,6 +537,17 @@ func (d *Driver) processVolumeContentSource(ctx context.Context, req *csi.Create
 	return nil
 }
 
+// setCloneSourceBackend limits the pools of a volume cloned from a volume or a snapshot to the backend of
+// the source, or to the backends on the same array if the StorageClass sets crossBackendClone
+func setCloneSourceBackend(ctx context.Context, parameters map[string]interface{}, sourceBackendName string) {
+	crossBackend, _ := parameters["crossBackendClone"].(string)
+	if crossBackend != "" && utils.StrToBool(ctx, crossBackend) {
+		parameters["sourceBackend"] = sourceBackendName
+		return
+	}
+	parameters["backend"] = sourceBackendName
+}
+
 func (d *Driver) processAccessibilityRequirements(ctx context.Context, req *csi.CreateVolumeRequest,
 	parameters map[string]interface{}) {
 	accessibleTopology := req.GetAccessibilityRequirements()
@@ -267,12 +595,18 @@ func (d *Driver) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest)
 		return &csi.DeleteVolumeResponse{}, nil
 	}
 
+	if plugin.IsSnapshotVolume(volName) {
+		log.AddContext(ctx).Infof("Volume %s publishes a snapshot, the snapshot is kept", volumeId)
+		return &csi.DeleteVolumeResponse{}, nil
+	}
+
 	err := backend.Plugin.DeleteVolume(ctx, volName)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Delete volume %s error: %v", volumeId, err)
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, toStatusError(err)
 	}
 
+	d.forgetAsyncCopy(ctx, volumeId)
 	log.AddContext(ctx).Infof("Volume %s is deleted", volumeId)
 	return &csi.DeleteVolumeResponse{}, nil
 }
@@ -294,22 +628,44 @@ func (d *Driver) ControllerExpandVolume(ctx context.Context, req *csi.Controller
 		return nil, status.Error(codes.InvalidArgument, "limitBytes is smaller than requiredBytes")
 	}
 
+	minSize, err := grantCapacity(ctx, req.GetCapacityRange())
+	if err != nil {
+		return nil, err
+	}
+
 	backendName, volName := utils.SplitVolumeId(volumeId)
+	if plugin.IsSnapshotVolume(volName) {
+		return nil, status.Errorf(codes.InvalidArgument, "volume %s publishes a snapshot, it cannot be expanded",
+			volumeId)
+	}
+
 	backend := backend.GetBackend(backendName)
 	if backend == nil {
 		msg := fmt.Sprintf("Backend %s doesn't exist", backendName)
 		log.AddContext(ctx).Errorln(msg)
-		return nil, status.Error(codes.Internal, msg)
+		return nil, status.Error(codes.NotFound, msg)
 	}
 
 	if support, err := isSupportExpandVolume(ctx, req, backend); !support {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
+	// A volume already expanded is still passed to the backend, which expands the remote volumes of its
+	// pairs and synchronizes the pairs if they failed to after the volume was expanded last time
+	if capacity := getExpandedCapacity(ctx, backend, volName, minSize); capacity > 0 {
+		log.AddContext(ctx).Infof("Volume %s is already %d bytes, not smaller than %d", volName, capacity, minSize)
+		minSize = capacity
+	}
+
 	nodeExpansionRequired, err := backend.Plugin.ExpandVolume(ctx, volName, minSize)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Expand volume %s error: %v", volumeId, err)
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, toStatusError(err)
+	}
+
+	err = d.updateExpandedVolume(ctx, backend, volumeId, volName, minSize)
+	if err != nil {
+		return nil, toStatusError(err)
 	}
 
 	log.AddContext(ctx).Infof("Volume %s is expanded to %d, nodeExpansionRequired %t", volName, minSize, nodeExpansionRequired)
@@ -319,6 +675,28 @@ func (d *Driver) ControllerExpandVolume(ctx context.Context, req *csi.Controller
 	}, nil
 }
 
+// updateExpandedVolume updates the QoS scaled by the size, the quota and the SmartTier policy of the
+// expanded volume
+func (d *Driver) updateExpandedVolume(ctx context.Context, b *backend.Backend, volumeID, volName string,
+	size int64) error {
+	attributes, err := d.getVolumeAttributes(ctx, volumeID)
+	if err != nil {
+		return err
+	}
+
+	err = d.updateScaledQoS(ctx, b, volumeID, volName, size, attributes)
+	if err != nil {
+		return err
+	}
+
+	err = UpdateQuota(ctx, b, volName, size, attributes)
+	if err != nil {
+		return err
+	}
+
+	return updateSmartTier(ctx, b, volName, attributes[smartTierKey])
+}
+
 func (d *Driver) ControllerPublishVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest) (
 	*csi.ControllerPublishVolumeResponse, error) {
 	// Volume attachment will be done at node stage process
@@ -347,29 +725,50 @@ func (d *Driver) ControllerUnpublishVolume(ctx context.Context, req *csi.Control
 	err := json.Unmarshal([]byte(nodeInfo), &parameters)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Unmarshal node info of %s error: %v", nodeInfo, err)
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, toStatusError(err)
 	}
 
-	err = backend.Plugin.DetachVolume(ctx, volName, parameters)
+	hostName, _ := parameters["HostName"].(string)
+	force, err := d.checkForceDetach(ctx, hostName)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Unpublish volume %s from node %s later: %v", volName, hostName, err)
+		return nil, err
+	}
+
+	if force {
+		err = fenceVolume(ctx, backend.Plugin, volName, parameters)
+	} else {
+		err = backend.Plugin.DetachVolume(ctx, volName, parameters)
+	}
 	if err != nil {
 		log.AddContext(ctx).Errorf("Unpublish volume %s from node %s error: %v", volName, nodeInfo, err)
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, toStatusError(err)
 	}
 
 	log.AddContext(ctx).Infof("Volume %s is controller unpublished from node %s", volumeId, nodeInfo)
 	return &csi.ControllerUnpublishVolumeResponse{}, nil
 }
 
-func (d *Driver) ValidateVolumeCapabilities(ctx context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (*csi.ValidateVolumeCapabilitiesResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "Not implemented")
-}
-
 func (d *Driver) ListVolumes(ctx context.Context, req *csi.ListVolumesRequest) (*csi.ListVolumesResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "Not implemented")
 }
 
+// GetCapacity returns the capacity of the pools meeting the parameters of the storage class in the topology
+// segment, which the external-provisioner publishes as CSIStorageCapacity objects for the scheduler
 func (d *Driver) GetCapacity(ctx context.Context, req *csi.GetCapacityRequest) (*csi.GetCapacityResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "Not implemented")
+	parameters := utils.CopyMap(req.GetParameters())
+	err := d.checkStorageClassParameters(ctx, parameters)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	capacity := backend.GetPoolCapacity(ctx, parameters, req.GetAccessibleTopology().GetSegments())
+	log.AddContext(ctx).Debugf("Capacity of parameters %v in topology %v is %+v", req.GetParameters(),
+		req.GetAccessibleTopology().GetSegments(), capacity)
+	return &csi.GetCapacityResponse{
+		AvailableCapacity: capacity.Available,
+		MaximumVolumeSize: &wrappers.Int64Value{Value: capacity.MaximumVolumeSize},
+	}, nil
 }
 
 func (d *Driver) ControllerGetCapabilities(ctx context.Context, req *csi.ControllerGetCapabilitiesRequest) (*csi.ControllerGetCapabilitiesResponse, error) {
@@ -403,6 +802,13 @@ func (d *Driver) ControllerGetCapabilities(ctx context.Context, req *csi.Control
 					},
 				},
 			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS,
+					},
+				},
+			},
 			{
 				Type: &csi.ControllerServiceCapability_Rpc{
 					Rpc: &csi.ControllerServiceCapability_RPC{
@@ -410,6 +816,27 @@ func (d *Driver) ControllerGetCapabilities(ctx context.Context, req *csi.Control
 					},
 				},
 			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_GET_VOLUME,
+					},
+				},
+			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_GET_CAPACITY,
+					},
+				},
+			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_VOLUME_CONDITION,
+					},
+				},
+			},
 		},
 	}, nil
 }
@@ -431,13 +858,13 @@ func (d *Driver) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshotRequ
 	if backend == nil {
 		msg := fmt.Sprintf("Backend %s doesn't exist", backendName)
 		log.AddContext(ctx).Errorln(msg)
-		return nil, status.Error(codes.Internal, msg)
+		return nil, status.Error(codes.NotFound, msg)
 	}
 
-	snapshot, err := backend.Plugin.CreateSnapshot(ctx, volName, snapshotName)
+	snapshot, err := createSnapshot(ctx, backend, volName, snapshotName, req.GetParameters()["snapshotType"])
 	if err != nil {
 		log.AddContext(ctx).Errorf("Create snapshot %s error: %v", snapshotName, err)
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, toStatusError(err)
 	}
 
 	log.AddContext(ctx).Infof("Finish to Create snapshot %s for volume %s", snapshotName, volumeId)
@@ -470,21 +897,81 @@ func (d *Driver) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshotRequ
 	err := backend.Plugin.DeleteSnapshot(ctx, snapshotParentId, snapshotName)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Delete snapshot %s error: %v", snapshotName, err)
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, toStatusError(err)
 	}
 
 	log.AddContext(ctx).Infof("Finish to Delete snapshot %s", snapshotId)
 	return &csi.DeleteSnapshotResponse{}, nil
 }
 
+// ListSnapshots lists the snapshot of the snapshot ID, which the snapshotter uses to check the snapshots of
+// pre-provisioned VolumeSnapshotContents, or pages through the snapshots on storage of the source volume or
+// of all the backends
 func (d *Driver) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRequest) (*csi.ListSnapshotsResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	snapshotId := req.GetSnapshotId()
+	if snapshotId == "" {
+		return listSnapshots(ctx, req.GetSourceVolumeId(), req.GetStartingToken(), req.GetMaxEntries())
+	}
+
+	backendName, snapshotParentId, snapshotName := utils.SplitSnapshotId(snapshotId)
+	if snapshotParentId == "" || snapshotName == "" {
+		log.AddContext(ctx).Infof("Snapshot ID %s is malformed, no snapshot is listed", snapshotId)
+		return &csi.ListSnapshotsResponse{}, nil
+	}
+
+	backend := backend.GetBackend(backendName)
+	if backend == nil {
+		log.AddContext(ctx).Infof("Backend %s of snapshot %s doesn't exist", backendName, snapshotId)
+		return &csi.ListSnapshotsResponse{}, nil
+	}
+
+	snapshot, err := querySnapshot(ctx, backend, snapshotId, snapshotParentId, snapshotName)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		return &csi.ListSnapshotsResponse{}, nil
+	}
+
+	sourceVolumeId := req.GetSourceVolumeId()
+	if sourceVolumeId != "" && sourceVolumeId != snapshot.SourceVolumeId {
+		return &csi.ListSnapshotsResponse{}, nil
+	}
+
+	return &csi.ListSnapshotsResponse{
+		Entries: []*csi.ListSnapshotsResponse_Entry{{Snapshot: snapshot}},
+	}, nil
 }
 
-// ControllerGetVolume is to get volume info, but unimplemented
+// ControllerGetVolume returns the capacity of the volume on storage, for thin volumes the capacity
+// consumed in the storage pool, and the condition of the volume the health monitor watches
 func (d *Driver) ControllerGetVolume(ctx context.Context, req *csi.ControllerGetVolumeRequest) (
 	*csi.ControllerGetVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	volumeId := req.GetVolumeId()
+	if volumeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "no volume ID provided")
+	}
+
+	backendName, volName := utils.SplitVolumeId(volumeId)
+	b := backend.GetBackend(backendName)
+	if b == nil {
+		msg := fmt.Sprintf("Backend %s doesn't exist", backendName)
+		log.AddContext(ctx).Errorln(msg)
+		return nil, status.Error(codes.NotFound, msg)
+	}
+
+	volume, condition, err := getVolume(ctx, b, volumeId, volName)
+	if err != nil {
+		return nil, err
+	}
+
+	if condition.Abnormal {
+		log.AddContext(ctx).Warningf("Volume %s is abnormal: %s", volumeId, condition.Message)
+	}
+	return &csi.ControllerGetVolumeResponse{
+		Volume: volume,
+		Status: &csi.ControllerGetVolumeResponse_VolumeStatus{VolumeCondition: condition},
+	}, nil
 }
 
 func (d *Driver) validateModeAndType(req *csi.CreateVolumeRequest, parameters map[string]interface{}) string {
@@ -508,8 +995,9 @@ func (d *Driver) validateModeAndType(req *csi.CreateVolumeRequest, parameters ma
 		}
 	}
 
-	if volumeMode == Block && parameters["volumeType"] == "fs" {
-		return "VolumeMode is block but volumeType is fs. Please check the storage class"
+	if volumeMode == Block && isFileVolume(parameters) {
+		return fmt.Sprintf("VolumeMode is block but volumeType is %v. Please check the storage class",
+			parameters["volumeType"])
 	}
 
 	if accessMode == RWX && volumeMode == FileSystem && parameters["volumeType"] == "lun" {
@@ -522,7 +1010,7 @@ func (d *Driver) validateModeAndType(req *csi.CreateVolumeRequest, parameters ma
 
 func isSupportExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest, b *backend.Backend) (
 	bool, error) {
-	if b.Storage == "fusionstorage-nas" || b.Storage == "oceanstor-nas" {
+	if b.Storage == "fusionstorage-nas" || b.Storage == "oceanstor-nas" || b.Storage == "oceanstor-dtree" {
 		log.AddContext(ctx).Debugf("Storage is [%s], support expand volume.", b.Storage)
 		return true, nil
 	}
@@ -548,6 +1036,18 @@ func isSupportExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeR
 
 func (d *Driver) processNFSProtocol(ctx context.Context, req *csi.CreateVolumeRequest,
 	parameters map[string]interface{}) error {
+	// the vers mount option of the CIFS shares is the SMB version
+	if parameters["shareProtocol"] == "cifs" {
+		return nil
+	}
+
+	if nfsVersion, _ := parameters["nfsVersion"].(string); nfsVersion != "" {
+		err := d.addNFSProtocol(ctx, "vers="+nfsVersion, parameters)
+		if err != nil {
+			return err
+		}
+	}
+
 	for _, v := range req.GetVolumeCapabilities() {
 		for _, mountFlag := range v.GetMount().GetMountFlags() {
 			err := d.addNFSProtocol(ctx, mountFlag, parameters)
@@ -564,15 +1064,32 @@ func (d *Driver) processNFSProtocol(ctx context.Context, req *csi.CreateVolumeRe
 	return nil
 }
 
+// processCifsUser takes the user granted access to the CIFS shares from the provisioner secret
+func processCifsUser(req *csi.CreateVolumeRequest, parameters map[string]interface{}) {
+	secrets := req.GetSecrets()
+	user := secrets["username"]
+	if user == "" {
+		return
+	}
+
+	if domain := secrets["domain"]; domain != "" {
+		user = domain + "\\" + user
+	}
+	parameters["cifsUser"] = user
+}
+
 func (d *Driver) addNFSProtocol(ctx context.Context, mountFlag string, parameters map[string]interface{}) error {
 	for _, singleFlag := range strings.Split(mountFlag, ",") {
 		singleFlag = strings.TrimSpace(singleFlag)
-		if strings.HasPrefix(singleFlag, "nfsvers=") {
-			value, ok := nfsProtocolMap[singleFlag]
+		if strings.HasPrefix(singleFlag, "nfsvers=") || strings.HasPrefix(singleFlag, "vers=") {
+			value, ok := utils.GetNFSProtocol(singleFlag[strings.Index(singleFlag, "=")+1:])
 			if !ok {
 				return utils.Errorf(ctx, "unsupported nfs protocol version [%s].", singleFlag)
 			}
 
+			if parameters["nfsProtocol"] == value {
+				continue
+			}
 			if parameters["nfsProtocol"] != nil {
 				return utils.Errorf(ctx, "Duplicate nfs protocol [%s].", mountFlag)
 			}
@@ -0,0 +1,61 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckFastClone(t *testing.T) {
+	assert.NoError(t, checkFastClone(map[string]interface{}{}))
+	assert.NoError(t, checkFastClone(map[string]interface{}{"fastClone": "true", "volumeType": "lun"}))
+	assert.NoError(t, checkFastClone(map[string]interface{}{"fastClone": "false", "volumeType": "fs"}))
+	assert.Error(t, checkFastClone(map[string]interface{}{"fastClone": "yes"}))
+	assert.Error(t, checkFastClone(map[string]interface{}{"fastClone": "true", "volumeType": "fs"}))
+}
+
+func TestCheckHyperMetroOptions(t *testing.T) {
+	assert.NoError(t, checkHyperMetroOptions(map[string]interface{}{}))
+	assert.NoError(t, checkHyperMetroOptions(map[string]interface{}{"hyperMetro": "true",
+		"hyperMetroSpeed": "medium", "hyperMetroRecoveryPolicy": "manual", "hyperMetroSyncPolicy": "full"}))
+	assert.Error(t, checkHyperMetroOptions(map[string]interface{}{"hyperMetro": "true", "hyperMetroSpeed": "4"}))
+	assert.Error(t, checkHyperMetroOptions(map[string]interface{}{"hyperMetroRecoveryPolicy": "manual"}))
+	assert.NoError(t, checkHyperMetroOptions(map[string]interface{}{"hyperMetro": "true",
+		"hyperMetroGroup": "app"}))
+	assert.Error(t, checkHyperMetroOptions(map[string]interface{}{"hyperMetroGroup": "app"}))
+	assert.Error(t, checkHyperMetroOptions(map[string]interface{}{"hyperMetro": "true", "volumeType": "fs",
+		"hyperMetroGroup": "app"}))
+}
+
+func TestCheckReplicationOptions(t *testing.T) {
+	assert.NoError(t, checkReplicationOptions(map[string]interface{}{}))
+	assert.NoError(t, checkReplicationOptions(map[string]interface{}{"replication": "true",
+		"replicationModel": "async", "replicationSyncType": "manual", "replicationSpeed": "low",
+		"replicationBandwidth": "100"}))
+	assert.NoError(t, checkReplicationOptions(map[string]interface{}{"replication": "true",
+		"replicationModel": "sync"}))
+	assert.Error(t, checkReplicationOptions(map[string]interface{}{"replication": "true",
+		"replicationBandwidth": "0"}))
+	assert.Error(t, checkReplicationOptions(map[string]interface{}{"replicationSpeed": "high"}))
+	assert.Error(t, checkReplicationOptions(map[string]interface{}{"replication": "true",
+		"replicationModel": "sync", "replicationSyncPeriod": "3600"}))
+	assert.NoError(t, checkReplicationOptions(map[string]interface{}{"replication": "true",
+		"replicationGroup": "app"}))
+	assert.Error(t, checkReplicationOptions(map[string]interface{}{"replicationGroup": "app"}))
+}
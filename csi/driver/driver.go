@@ -18,6 +18,7 @@ package driver
 
 import (
 	"strings"
+	"sync"
 
 	"huawei-csi-driver/utils/k8sutils"
 )
@@ -30,6 +31,8 @@ type Driver struct {
 	nvmeMultiPathType string
 	k8sUtils          k8sutils.Interface
 	nodeName          string
+	// volumeInFlight records the names of volumes being created to reject concurrent duplicates
+	volumeInFlight *sync.Map
 }
 
 func NewDriver(name, version string, useMultiPath bool, scsiMultiPathType, nvmeMultiPathType string,
@@ -42,5 +45,6 @@ func NewDriver(name, version string, useMultiPath bool, scsiMultiPathType, nvmeM
 		nvmeMultiPathType: nvmeMultiPathType,
 		k8sUtils:          k8sUtils,
 		nodeName:          strings.TrimSpace(nodeName),
+		volumeInFlight:    &sync.Map{},
 	}
 }
@@ -0,0 +1,58 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"huawei-csi-driver/utils"
+)
+
+var errorCodes = []struct {
+	kind error
+	code codes.Code
+}{
+	{utils.ErrVolumeConflict, codes.AlreadyExists},
+	{utils.ErrNotFound, codes.NotFound},
+	{utils.ErrResourceExhausted, codes.ResourceExhausted},
+	{utils.ErrFailedPrecondition, codes.FailedPrecondition},
+	{utils.ErrPoolReserveReached, codes.FailedPrecondition},
+	{utils.ErrTimeout, codes.DeadlineExceeded},
+	{utils.ErrCopyInProgress, codes.Aborted},
+	{context.DeadlineExceeded, codes.DeadlineExceeded},
+	{context.Canceled, codes.Canceled},
+}
+
+// toStatusError translates an error returned by backends to a gRPC status error, so that the
+// sidecars can retry and back off properly. Errors of unknown kinds are translated to Internal.
+func toStatusError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	for _, e := range errorCodes {
+		if errors.Is(err, e.kind) {
+			return status.Error(e.code, err.Error())
+		}
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
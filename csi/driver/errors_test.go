@@ -0,0 +1,52 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"huawei-csi-driver/utils"
+)
+
+func TestToStatusError(t *testing.T) {
+	var testCases = []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		{"unknown", errors.New("unknown error"), codes.Internal},
+		{"conflict", fmt.Errorf("%w: lun exists", utils.ErrVolumeConflict), codes.AlreadyExists},
+		{"notFound", fmt.Errorf("%w: lun not found", utils.ErrNotFound), codes.NotFound},
+		{"poolFull", fmt.Errorf("%w: pool full", utils.ErrResourceExhausted), codes.ResourceExhausted},
+		{"poolReserve", fmt.Errorf("%w: pool reserve", utils.ErrPoolReserveReached), codes.FailedPrecondition},
+		{"precondition", fmt.Errorf("%w: has snapshots", utils.ErrFailedPrecondition), codes.FailedPrecondition},
+		{"timeout", fmt.Errorf("Wait timeout: %w", utils.ErrTimeout), codes.DeadlineExceeded},
+		{"deadline", context.DeadlineExceeded, codes.DeadlineExceeded},
+		{"status", status.Error(codes.Unavailable, "unavailable"), codes.Unavailable},
+	}
+
+	for _, c := range testCases {
+		assert.Equal(t, c.code, status.Code(toStatusError(c.err)), "case name is %s", c.name)
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// ForceDetachSettings are the settings of force detach, which can be changed at runtime by the driver config
+type ForceDetachSettings struct {
+	// Enabled allows volumes to be unmapped from a node which is not ready
+	Enabled bool
+	// Delay is how long a node must have been not ready before its volumes are force detached
+	Delay time.Duration
+}
+
+var (
+	forceDetachMutex   sync.RWMutex
+	currentForceDetach = ForceDetachSettings{Delay: 5 * time.Minute}
+)
+
+// SetForceDetachSettings replaces the settings of force detach
+func SetForceDetachSettings(settings ForceDetachSettings) {
+	forceDetachMutex.Lock()
+	defer forceDetachMutex.Unlock()
+	currentForceDetach = settings
+}
+
+func getForceDetachSettings() ForceDetachSettings {
+	forceDetachMutex.RLock()
+	defer forceDetachMutex.RUnlock()
+	return currentForceDetach
+}
+
+var (
+	missingNodesMutex sync.Mutex
+	// missingNodes records since when the nodes have been missing in Kubernetes, since a deleted node
+	// doesn't tell when it was lost. It is kept in memory, so the delay restarts with the controller.
+	missingNodes = map[string]time.Time{}
+)
+
+// getNodeLostTime returns since when the node with the given hostname has been not ready or missing,
+// nil if the node is ready
+func (d *Driver) getNodeLostTime(ctx context.Context, hostName string) (*time.Time, error) {
+	notReadyTime, err := d.k8sUtils.GetNodeNotReadyTime(ctx, hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	missingNodesMutex.Lock()
+	defer missingNodesMutex.Unlock()
+	if notReadyTime == nil || !notReadyTime.IsZero() {
+		delete(missingNodes, hostName)
+		return notReadyTime, nil
+	}
+
+	missingTime, exist := missingNodes[hostName]
+	if !exist {
+		missingTime = time.Now()
+		missingNodes[hostName] = missingTime
+	}
+	return &missingTime, nil
+}
+
+// checkForceDetach decides whether a volume can be unmapped from the node with the given hostname, and
+// whether the node must be fenced. A ready node is detached as usual. A node which is not ready or missing
+// is fenced only after it has been lost for the force detach delay, before that Unavailable is returned so
+// that the detach is retried later. It never blocks detaching when force detach is disabled.
+func (d *Driver) checkForceDetach(ctx context.Context, hostName string) (bool, error) {
+	settings := getForceDetachSettings()
+	if !settings.Enabled || d.k8sUtils == nil || hostName == "" {
+		return false, nil
+	}
+
+	lostTime, err := d.getNodeLostTime(ctx, hostName)
+	if err != nil {
+		// Do not block the normal detach if the node status is unavailable
+		log.AddContext(ctx).Warningf("Get status of node %s error: %v", hostName, err)
+		return false, nil
+	}
+	if lostTime == nil {
+		return false, nil
+	}
+
+	lostDuration := time.Since(*lostTime)
+	if lostDuration < settings.Delay {
+		return false, status.Errorf(codes.Unavailable,
+			"node %s has been lost for %s, wait until %s elapsed before force detaching",
+			hostName, lostDuration.Round(time.Second), settings.Delay)
+	}
+
+	log.AddContext(ctx).Warningf("Node %s has been lost for %s, force detach volumes from it. "+
+		"CAUTION: make sure the node is powered off or isolated from storage", hostName,
+		lostDuration.Round(time.Second))
+	return true, nil
+}
+
+// fenceVolume unmaps the volume from the lost node on every storage the volume is mapped through.
+// A plugin which cannot fence the node detaches the volume as usual.
+func fenceVolume(ctx context.Context, p plugin.Plugin, volName string, parameters map[string]interface{}) error {
+	fencer, ok := p.(plugin.VolumeFencer)
+	if !ok {
+		log.AddContext(ctx).Warningf("Backend of volume %s cannot fence the lost node, detach it as usual",
+			volName)
+		return p.DetachVolume(ctx, volName, parameters)
+	}
+
+	return fencer.FenceVolume(ctx, volName, parameters)
+}
+
+// The single node writer access modes added by CSI 1.5, which the spec the driver is built with doesn't
+// define. The values of the enum are kept by protobuf, so they are compared by number.
+const (
+	singleNodeSingleWriter csi.VolumeCapability_AccessMode_Mode = 6
+	singleNodeMultiWriter  csi.VolumeCapability_AccessMode_Mode = 7
+)
+
+// isSingleNodeWriterMode tells whether the access mode writes the volume on a single node
+func isSingleNodeWriterMode(mode csi.VolumeCapability_AccessMode_Mode) bool {
+	return mode == csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER ||
+		mode == singleNodeSingleWriter ||
+		mode == singleNodeMultiWriter
+}
+
+// checkExclusiveMapping refuses to stage a single node writer volume which is still mapped to another
+// node that is alive, to protect the filesystem from two writers during a network partition. The node
+// is regarded as alive unless Kubernetes reports it not ready.
+func (d *Driver) checkExclusiveMapping(ctx context.Context, p plugin.Plugin, volName string) error {
+	query, ok := p.(plugin.VolumeMappingQuery)
+	if !ok {
+		return nil
+	}
+
+	hosts, err := query.GetOtherMappedHosts(ctx, volName, map[string]interface{}{})
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get mapped hosts of volume %s error: %v", volName, err)
+		return err
+	}
+
+	for _, host := range hosts {
+		if d.k8sUtils != nil {
+			notReadyTime, err := d.k8sUtils.GetNodeNotReadyTime(ctx, host)
+			if err != nil {
+				log.AddContext(ctx).Warningf("Get status of node %s error: %v", host, err)
+			} else if notReadyTime != nil {
+				log.AddContext(ctx).Warningf("Volume %s is still mapped to node %s which is not ready",
+					volName, host)
+				continue
+			}
+		}
+
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"volume %s is still mapped to node %s, it cannot be staged on another node at the same time",
+			volName, host)
+	}
+
+	return nil
+}
@@ -0,0 +1,212 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	logName string = "driver_test.log"
+	logDir  string = "/var/log/huawei"
+)
+
+type fakeNodeStatus struct {
+	k8sutils.Interface
+	notReadyTime *time.Time
+	err          error
+}
+
+func (f *fakeNodeStatus) GetNodeNotReadyTime(ctx context.Context, hostName string) (*time.Time, error) {
+	return f.notReadyTime, f.err
+}
+
+func TestCheckForceDetach(t *testing.T) {
+	recent := time.Now().Add(-time.Minute)
+	longAgo := time.Now().Add(-time.Hour)
+
+	var testCases = []struct {
+		name    string
+		enabled bool
+		node    *fakeNodeStatus
+		force   bool
+		code    codes.Code
+	}{
+		{"disabled", false, &fakeNodeStatus{notReadyTime: &recent}, false, codes.OK},
+		{"ready", true, &fakeNodeStatus{}, false, codes.OK},
+		{"statusError", true, &fakeNodeStatus{err: errors.New("timeout")}, false, codes.OK},
+		{"withinDelay", true, &fakeNodeStatus{notReadyTime: &recent}, false, codes.Unavailable},
+		{"afterDelay", true, &fakeNodeStatus{notReadyTime: &longAgo}, true, codes.OK},
+		{"nodeDeleted", true, &fakeNodeStatus{notReadyTime: &time.Time{}}, false, codes.Unavailable},
+	}
+
+	defer SetForceDetachSettings(getForceDetachSettings())
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			SetForceDetachSettings(ForceDetachSettings{Enabled: c.enabled, Delay: 5 * time.Minute})
+			d := &Driver{k8sUtils: c.node}
+			force, err := d.checkForceDetach(context.Background(), "node1")
+			assert.Equal(t, c.force, force)
+			assert.Equal(t, c.code, status.Code(err))
+		})
+	}
+}
+
+func TestCheckForceDetachMissingNode(t *testing.T) {
+	ctx := context.Background()
+	defer SetForceDetachSettings(getForceDetachSettings())
+	SetForceDetachSettings(ForceDetachSettings{Enabled: true, Delay: 5 * time.Minute})
+	defer func() {
+		missingNodes = map[string]time.Time{}
+	}()
+
+	// the delay of a missing node starts when it is found missing
+	node := &fakeNodeStatus{notReadyTime: &time.Time{}}
+	d := &Driver{k8sUtils: node}
+	force, err := d.checkForceDetach(ctx, "node1")
+	assert.False(t, force)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	missingNodes["node1"] = time.Now().Add(-time.Hour)
+	force, err = d.checkForceDetach(ctx, "node1")
+	assert.True(t, force)
+	assert.NoError(t, err)
+
+	// a node which comes back is forgotten
+	node.notReadyTime = nil
+	force, err = d.checkForceDetach(ctx, "node1")
+	assert.False(t, force)
+	assert.NoError(t, err)
+	assert.NotContains(t, missingNodes, "node1")
+}
+
+type fakeFencePlugin struct {
+	plugin.Plugin
+	detached bool
+}
+
+func (f *fakeFencePlugin) DetachVolume(ctx context.Context, name string, parameters map[string]interface{}) error {
+	f.detached = true
+	return nil
+}
+
+type fakeFencerPlugin struct {
+	fakeFencePlugin
+	fenced bool
+}
+
+func (f *fakeFencerPlugin) FenceVolume(ctx context.Context, name string, parameters map[string]interface{}) error {
+	f.fenced = true
+	return nil
+}
+
+func TestFenceVolume(t *testing.T) {
+	ctx := context.Background()
+
+	fencer := &fakeFencerPlugin{}
+	assert.NoError(t, fenceVolume(ctx, fencer, "pvc-1", nil))
+	assert.True(t, fencer.fenced)
+	assert.False(t, fencer.detached)
+
+	// a plugin which cannot fence the node detaches the volume as usual
+	p := &fakeFencePlugin{}
+	assert.NoError(t, fenceVolume(ctx, p, "pvc-1", nil))
+	assert.True(t, p.detached)
+}
+
+type fakeMappingPlugin struct {
+	plugin.Plugin
+	hosts []string
+	err   error
+}
+
+func (f *fakeMappingPlugin) GetOtherMappedHosts(ctx context.Context, name string,
+	parameters map[string]interface{}) ([]string, error) {
+	return f.hosts, f.err
+}
+
+func TestCheckExclusiveMapping(t *testing.T) {
+	longAgo := time.Now().Add(-time.Hour)
+
+	var testCases = []struct {
+		name    string
+		plugin  plugin.Plugin
+		node    *fakeNodeStatus
+		wantErr error
+	}{
+		{"notSupported", &struct{ plugin.Plugin }{}, &fakeNodeStatus{}, nil},
+		{"notMapped", &fakeMappingPlugin{}, &fakeNodeStatus{}, nil},
+		{"mappedToLiveNode", &fakeMappingPlugin{hosts: []string{"node2"}}, &fakeNodeStatus{},
+			utils.ErrFailedPrecondition},
+		{"mappedToNotReadyNode", &fakeMappingPlugin{hosts: []string{"node2"}},
+			&fakeNodeStatus{notReadyTime: &longAgo}, nil},
+		{"nodeStatusError", &fakeMappingPlugin{hosts: []string{"node2"}},
+			&fakeNodeStatus{err: errors.New("timeout")}, utils.ErrFailedPrecondition},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			d := &Driver{k8sUtils: c.node}
+			err := d.checkExclusiveMapping(context.Background(), c.plugin, "pvc-test")
+			if c.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsSingleNodeWriterMode(t *testing.T) {
+	assert.True(t, isSingleNodeWriterMode(csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER))
+	assert.True(t, isSingleNodeWriterMode(singleNodeSingleWriter))
+	assert.True(t, isSingleNodeWriterMode(singleNodeMultiWriter))
+	assert.False(t, isSingleNodeWriterMode(csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY))
+	assert.False(t, isSingleNodeWriterMode(csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER))
+}
+
+func TestMain(m *testing.M) {
+	if err := log.InitLogging(logName); err != nil {
+		log.Errorf("Init logging: %s failed. error: %v", logName, err)
+		os.Exit(1)
+	}
+
+	logFile := path.Join(logDir, logName)
+	defer func() {
+		if err := os.RemoveAll(logFile); err != nil {
+			log.Errorf("Remove file: %s failed. error: %s", logFile, err)
+		}
+	}()
+
+	m.Run()
+}
@@ -0,0 +1,96 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+// isReadOnlyMode tells whether the access mode only reads the volume
+func isReadOnlyMode(mode csi.VolumeCapability_AccessMode_Mode) bool {
+	return mode == csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY ||
+		mode == csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY
+}
+
+// checkHyperMetroShare enforces the policy of the HyperMetro share of a volume exposed to the clusters
+// of both sites, before the volume is staged writable on this cluster
+func (d *Driver) checkHyperMetroShare(ctx context.Context, p plugin.Plugin, volumeID, volName string,
+	readOnly bool) error {
+	if d.k8sUtils == nil || readOnly {
+		return nil
+	}
+
+	share, err := d.k8sUtils.GetHyperMetroShare(ctx, volumeID)
+	if err != nil {
+		return utils.Errorf(ctx, "get hypermetro share of volume %s error: %v", volumeID, err)
+	}
+	if share == nil {
+		return nil
+	}
+
+	switch share.Spec.Policy {
+	case k8sutils.DRPassivePolicy:
+		if share.Spec.Role != k8sutils.ActiveRole {
+			return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+				"volume %s is passive in this cluster by hypermetro share %s, it can only be staged read-only",
+				volumeID, share.Name)
+		}
+		return nil
+	case k8sutils.SingleWriterPolicy:
+		return d.checkForeignMapping(ctx, p, volName, share.Name)
+	default:
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition, "policy %s of hypermetro share %s is invalid",
+			share.Spec.Policy, share.Name)
+	}
+}
+
+// checkForeignMapping refuses to stage a single writer volume which is mapped to the hosts of the
+// cluster of the other site
+func (d *Driver) checkForeignMapping(ctx context.Context, p plugin.Plugin, volName, shareName string) error {
+	query, ok := p.(plugin.VolumeMappingQuery)
+	if !ok {
+		return nil
+	}
+
+	hosts, err := query.GetOtherMappedHosts(ctx, volName, map[string]interface{}{})
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get mapped hosts of volume %s error: %v", volName, err)
+		return err
+	}
+
+	for _, host := range hosts {
+		isClusterHost, err := d.k8sUtils.IsClusterHost(ctx, host)
+		if err != nil {
+			return utils.Errorf(ctx, "get node of host %s error: %v", host, err)
+		}
+
+		if !isClusterHost {
+			return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+				"volume %s is mapped to host %s of another cluster, hypermetro share %s allows a single "+
+					"writer cluster", volName, host, shareName)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+)
+
+type fakeHyperMetroShare struct {
+	k8sutils.Interface
+	share        *k8sutils.HyperMetroShare
+	clusterHosts []string
+}
+
+func (f *fakeHyperMetroShare) GetHyperMetroShare(ctx context.Context, volumeHandle string) (
+	*k8sutils.HyperMetroShare, error) {
+	return f.share, nil
+}
+
+func (f *fakeHyperMetroShare) IsClusterHost(ctx context.Context, hostName string) (bool, error) {
+	return utils.IsContain(hostName, f.clusterHosts), nil
+}
+
+func TestCheckHyperMetroShare(t *testing.T) {
+	newShare := func(policy, role string) *k8sutils.HyperMetroShare {
+		share := &k8sutils.HyperMetroShare{Spec: k8sutils.HyperMetroShareSpec{Policy: policy, Role: role}}
+		share.Name = "share"
+		return share
+	}
+
+	var testCases = []struct {
+		name     string
+		share    *k8sutils.HyperMetroShare
+		hosts    []string
+		readOnly bool
+		wantErr  bool
+	}{
+		{"notShared", nil, []string{"remote-node"}, false, false},
+		{"passiveReadOnly", newShare(k8sutils.DRPassivePolicy, k8sutils.PassiveRole), nil, true, false},
+		{"passiveWritable", newShare(k8sutils.DRPassivePolicy, k8sutils.PassiveRole), nil, false, true},
+		{"activeWritable", newShare(k8sutils.DRPassivePolicy, k8sutils.ActiveRole),
+			[]string{"remote-node"}, false, false},
+		{"singleWriterLocalHosts", newShare(k8sutils.SingleWriterPolicy, ""), []string{"node2"}, false, false},
+		{"singleWriterRemoteHosts", newShare(k8sutils.SingleWriterPolicy, ""),
+			[]string{"node2", "remote-node"}, false, true},
+		{"invalidPolicy", newShare("Shared", ""), nil, false, true},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			d := &Driver{k8sUtils: &fakeHyperMetroShare{share: c.share, clusterHosts: []string{"node1", "node2"}}}
+			err := d.checkHyperMetroShare(context.Background(), &fakeMappingPlugin{hosts: c.hosts},
+				"backend.pvc-test", "pvc-test", c.readOnly)
+			assert.Equal(t, c.wantErr, err != nil)
+		})
+	}
+}
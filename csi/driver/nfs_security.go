@@ -0,0 +1,57 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"fmt"
+	"strings"
+
+	"huawei-csi-driver/storage/oceanstor/volume"
+)
+
+const (
+	// nfsACLModeKey is the StorageClass parameter of the NFSv4 ACL mode of the shares, posix or nfs4
+	nfsACLModeKey = "nfsAclMode"
+	// nfsSecurityFlavorsKey is the StorageClass parameter of the RPC security flavors allowed by the shares
+	// separated by ":", such as krb5p:krb5i. The nodes mount the shares with them as the sec option.
+	nfsSecurityFlavorsKey = "nfsSecurityFlavors"
+)
+
+// checkNFSSecurity checks the nfsAclMode and nfsSecurityFlavors parameters, which only the NFS shares set
+func checkNFSSecurity(parameters map[string]interface{}) error {
+	aclMode, aclExist := parameters[nfsACLModeKey].(string)
+	flavors, flavorsExist := parameters[nfsSecurityFlavorsKey].(string)
+	if !aclExist && !flavorsExist {
+		return nil
+	}
+
+	if !isFileVolume(parameters) || parameters["shareProtocol"] == "cifs" {
+		return fmt.Errorf("only the NFS shares of volumeType fs or dtree can set %s and %s", nfsACLModeKey,
+			nfsSecurityFlavorsKey)
+	}
+
+	err := volume.CheckNFSSecurity(aclMode, flavors)
+	if err != nil {
+		return err
+	}
+
+	if nfsVersion, _ := parameters["nfsVersion"].(string); aclMode == volume.NFSACLModeNFS4 &&
+		nfsVersion != "" && !strings.HasPrefix(nfsVersion, "4") {
+		return fmt.Errorf("%s %s requires NFS version 4, not %s", nfsACLModeKey, aclMode, nfsVersion)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckNFSSecurity(t *testing.T) {
+	assert.NoError(t, checkNFSSecurity(map[string]interface{}{"volumeType": "lun"}))
+	assert.NoError(t, checkNFSSecurity(map[string]interface{}{"volumeType": "fs", nfsACLModeKey: "nfs4",
+		nfsSecurityFlavorsKey: "krb5p:krb5i", "nfsVersion": "4.1"}))
+	assert.NoError(t, checkNFSSecurity(map[string]interface{}{"volumeType": "dtree", nfsSecurityFlavorsKey: "sys"}))
+	assert.Error(t, checkNFSSecurity(map[string]interface{}{"volumeType": "lun", nfsSecurityFlavorsKey: "krb5"}))
+	assert.Error(t, checkNFSSecurity(map[string]interface{}{"volumeType": "fs", "shareProtocol": "cifs",
+		nfsACLModeKey: "posix"}))
+	assert.Error(t, checkNFSSecurity(map[string]interface{}{"volumeType": "fs", nfsACLModeKey: "acl"}))
+	assert.Error(t, checkNFSSecurity(map[string]interface{}{"volumeType": "fs", nfsSecurityFlavorsKey: "krb5;sys"}))
+	assert.Error(t, checkNFSSecurity(map[string]interface{}{"volumeType": "fs", nfsACLModeKey: "nfs4",
+		"nfsVersion": "3"}))
+}
@@ -20,10 +20,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	"huawei-csi-driver/connector"
 	// init the nfs connector
+	_ "huawei-csi-driver/connector/cifs"
 	_ "huawei-csi-driver/connector/nfs"
 	"huawei-csi-driver/csi/backend"
 	"huawei-csi-driver/utils"
@@ -34,6 +37,8 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+var getAttachLimit = connector.GetAttachLimit
+
 func (d *Driver) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolumeRequest) (*csi.NodeStageVolumeResponse, error) {
 	defer utils.RecoverPanic(ctx)
 
@@ -60,6 +65,7 @@ func (d *Driver) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolumeRe
 		stagePath := req.GetStagingTargetPath() + "/" + volumeId
 		parameters["stagingPath"] = stagePath
 		parameters["volumeMode"] = "Block"
+		parameters["accessMode"] = req.GetVolumeCapability().GetAccessMode().GetMode()
 	case *csi.VolumeCapability_Mount:
 		log.AddContext(ctx).Infoln("The request is to create volume of type filesystem")
 		mnt := req.GetVolumeCapability().GetMount()
@@ -72,22 +78,66 @@ func (d *Driver) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolumeRe
 			opts = append(opts, "ro")
 		}
 
+		mountFlags := opts
+		if nfsVersion := req.VolumeContext["nfsVersion"]; nfsVersion != "" {
+			mountFlags = append([]string{"vers=" + nfsVersion}, mountFlags...)
+		}
+		if flavors := req.VolumeContext[nfsSecurityFlavorsKey]; flavors != "" {
+			mountFlags = append([]string{"sec=" + flavors}, mountFlags...)
+		}
+		if err := connector.VerifyMountOptions(mountFlags); err != nil {
+			log.AddContext(ctx).Errorf("Invalid mount flags of volume %s: %v", volumeId, err)
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if req.VolumeContext["spaceReclamation"] == SpaceReclamationOnline {
+			opts = append(opts, "discard")
+		}
+
 		parameters["targetPath"] = req.GetStagingTargetPath()
 		parameters["fsType"] = mnt.GetFsType()
 		parameters["mountFlags"] = strings.Join(opts, ",")
 		parameters["accessMode"] = volumeAccessMode
 		parameters["fsPermission"] = req.VolumeContext["fsPermission"]
+		parameters["nfsVersion"] = req.VolumeContext["nfsVersion"]
+		parameters["nfsSecurityFlavors"] = req.VolumeContext[nfsSecurityFlavorsKey]
+		parameters["checkFsBeforeMount"] = req.VolumeContext["checkFsBeforeMount"] == "true"
+		parameters["mkfsOptions"] = req.VolumeContext["mkfsOptions"]
 	default:
 		msg := fmt.Sprintf("Invalid volume capability.")
 		log.AddContext(ctx).Errorln(msg)
 		return nil, status.Error(codes.Internal, msg)
 	}
-	err := backend.Plugin.StageVolume(ctx, volName, parameters)
+	parameters["secrets"] = req.GetSecrets()
+	parameters["encrypted"] = req.VolumeContext["encrypted"] == "true"
+
+	err := d.checkHyperMetroShare(ctx, backend.Plugin, volumeId, volName,
+		isReadOnlyMode(req.GetVolumeCapability().GetAccessMode().GetMode()))
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	if isSingleNodeWriterMode(req.GetVolumeCapability().GetAccessMode().GetMode()) {
+		err := d.checkExclusiveMapping(ctx, backend.Plugin, volName)
+		if err != nil {
+			return nil, toStatusError(err)
+		}
+	}
+
+	storageInterfaces, err := d.getStorageInterfaces(ctx, backend)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	if storageInterfaces != nil {
+		parameters["storageInterfaces"] = storageInterfaces
+	}
+
+	err = backend.Plugin.StageVolume(ctx, volName, parameters)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Stage volume %s error: %v", volName, err)
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, toStatusError(err)
 	}
 
+	recordQuotaVolume(volumeId, req.GetVolumeContext())
 	log.AddContext(ctx).Infof("Volume %s is staged", volumeId)
 	return &csi.NodeStageVolumeResponse{}, nil
 }
@@ -113,9 +163,10 @@ func (d *Driver) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstageVolu
 	err := backend.Plugin.UnstageVolume(ctx, volName, parameters)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Unstage volume %s error: %v", volName, err)
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, toStatusError(err)
 	}
 
+	quotaVolumes.Delete(volumeId)
 	log.AddContext(ctx).Infof("Volume %s is unstaged from %s", volumeId, targetPath)
 	return &csi.NodeUnstageVolumeResponse{}, nil
 }
@@ -127,29 +178,6 @@ func (d *Driver) NodePublishVolume(ctx context.Context,
 	targetPath := req.GetTargetPath()
 
 	log.AddContext(ctx).Infof("Start to node publish volume %s to %s", volumeId, targetPath)
-	if req.GetVolumeCapability().GetBlock() != nil {
-		// If the request is to publish raw block device then create symlink of the device
-		// from the staging are to publish. Do not create fs and mount
-		log.AddContext(ctx).Infoln("Creating symlink for the staged device on the node to publish")
-		sourcePath = sourcePath + "/" + volumeId
-		err := utils.CreateSymlink(ctx, sourcePath, targetPath)
-		if err != nil {
-			log.AddContext(ctx).Errorf("Failed to create symlink for the staging path [%v] to target path [%v]",
-				sourcePath, targetPath)
-			return nil, err
-		}
-		accessMode := utils.GetAccessModeType(req.GetVolumeCapability().GetAccessMode().GetMode())
-		if accessMode == "ReadOnly" {
-			_, err = utils.ExecShellCmd(ctx, "chmod 440 %s", targetPath)
-			if err != nil {
-				log.AddContext(ctx).Errorln("Unable to set ReadOnlyMany permission")
-				return nil, err
-			}
-		}
-		log.AddContext(ctx).Infof("Raw Block Volume %s is node published to %s", volumeId, targetPath)
-		return &csi.NodePublishVolumeResponse{}, nil
-	}
-
 	opts := []string{"bind"}
 	if req.GetReadonly() {
 		opts = append(opts, "ro")
@@ -162,13 +190,39 @@ func (d *Driver) NodePublishVolume(ctx context.Context,
 		"mountFlags": strings.Join(opts, ","),
 	}
 
+	if req.GetVolumeCapability().GetBlock() != nil {
+		// If the request is to publish raw block device then bind mount the device the staging symlink
+		// links to onto the target file. Do not create fs and mount
+		accessMode := utils.GetAccessModeType(req.GetVolumeCapability().GetAccessMode().GetMode())
+		if accessMode == "ReadOnly" && !req.GetReadonly() {
+			opts = append(opts, "ro")
+		}
+
+		devPath := sourcePath + "/" + volumeId
+		// The device staged with a writable access mode is writable, the target published read-only is
+		// bound to a read-only device-mapper node on it, since a read-only mount does not stop the writes
+		if accessMode != "ReadOnly" && req.GetReadonly() {
+			var err error
+			devPath, err = connector.OpenReadOnlyDevice(ctx, devPath, targetPath)
+			if err != nil {
+				return nil, toStatusError(err)
+			}
+		}
+
+		connectInfo["srcType"] = connector.MountDeviceType
+		connectInfo["sourcePath"] = devPath
+		connectInfo["mountFlags"] = strings.Join(opts, ",")
+	}
+
 	conn := connector.GetConnector(ctx, connector.NFSDriver)
 	_, err := conn.ConnectVolume(ctx, connectInfo)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Mount share %s to %s error: %v", sourcePath, targetPath, err)
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, toStatusError(err)
 	}
 
+	d.refreshPathPreference(ctx, volumeId)
+	recordQuotaVolume(volumeId, req.GetVolumeContext())
 	log.AddContext(ctx).Infof("Volume %s is node published to %s", volumeId, targetPath)
 	return &csi.NodePublishVolumeResponse{}, nil
 }
@@ -201,6 +255,18 @@ func (d *Driver) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpublish
 			log.AddContext(ctx).Errorln(msg)
 			return nil, status.Error(codes.Internal, msg)
 		}
+
+		if err := connector.CloseReadOnlyDevice(ctx, targetPath); err != nil {
+			return nil, toStatusError(err)
+		}
+
+		// the target of a raw block volume is a file created to bind the device, remove it after unmounted
+		if info, err := os.Stat(targetPath); err == nil && info.Mode().IsRegular() {
+			if err := os.Remove(targetPath); err != nil {
+				log.AddContext(ctx).Errorf("Remove target file %s of volume %s error: %v", targetPath, volumeId, err)
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+		}
 	}
 	log.AddContext(ctx).Infof("Volume %s is node unpublished from %s", volumeId, targetPath)
 	return &csi.NodeUnpublishVolumeResponse{}, nil
@@ -210,7 +276,7 @@ func (d *Driver) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoRequest) (
 	hostname, err := utils.GetHostName(ctx)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Cannot get current host's hostname")
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, toStatusError(err)
 	}
 
 	node := map[string]interface{}{
@@ -220,13 +286,18 @@ func (d *Driver) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoRequest) (
 	nodeBytes, err := json.Marshal(node)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Marshal node info of %s error: %v", nodeBytes, err)
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, toStatusError(err)
 	}
 	log.AddContext(ctx).Infof("Get NodeId %s", nodeBytes)
 
+	// Let the scheduler respect the HBA and session limits of the node, 0 means unlimited
+	maxVolumes := getAttachLimit(ctx, d.useMultiPath, d.scsiMultiPathType)
+	log.AddContext(ctx).Infof("The max volumes of the node is %d", maxVolumes)
+
 	if d.nodeName == "" {
 		return &csi.NodeGetInfoResponse{
-			NodeId: string(nodeBytes),
+			NodeId:            string(nodeBytes),
+			MaxVolumesPerNode: maxVolumes,
 		}, nil
 	}
 
@@ -234,11 +305,12 @@ func (d *Driver) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoRequest) (
 	topology, err := d.k8sUtils.GetNodeTopology(ctx, d.nodeName)
 	if err != nil {
 		log.AddContext(ctx).Errorln(err)
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, toStatusError(err)
 	}
 
 	return &csi.NodeGetInfoResponse{
-		NodeId: string(nodeBytes),
+		NodeId:            string(nodeBytes),
+		MaxVolumesPerNode: maxVolumes,
 		AccessibleTopology: &csi.Topology{
 			Segments: topology,
 		},
@@ -269,6 +341,13 @@ func (d *Driver) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetCapabi
 					},
 				},
 			},
+			{
+				Type: &csi.NodeServiceCapability_Rpc{
+					Rpc: &csi.NodeServiceCapability_RPC{
+						Type: csi.NodeServiceCapability_RPC_VOLUME_CONDITION,
+					},
+				},
+			},
 		},
 	}, nil
 }
@@ -288,13 +367,30 @@ func (d *Driver) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeS
 		return nil, status.Error(codes.InvalidArgument, msg)
 	}
 
-	volumeMetrics, err := utils.GetVolumeMetrics(VolumePath)
-	if err != nil {
-		msg := fmt.Sprintf("get volume metrics failed, reason %v", volumeMetrics)
+	isBlock, err := isBlockDevice(VolumePath)
+	if os.IsNotExist(err) {
+		msg := fmt.Sprintf("volume path %s of volume %s does not exist", VolumePath, volumeID)
+		log.AddContext(ctx).Errorln(msg)
+		return nil, status.Error(codes.NotFound, msg)
+	} else if err != nil {
+		msg := fmt.Sprintf("stat volume path %s error: %v", VolumePath, err)
 		log.AddContext(ctx).Errorln(msg)
 		return nil, status.Error(codes.Internal, msg)
 	}
 
+	if isBlock {
+		return getBlockVolumeStats(ctx, VolumePath)
+	}
+
+	volumeMetrics, err := getVolumeMetrics(VolumePath)
+	if err != nil {
+		msg := fmt.Sprintf("get volume metrics failed, reason %v", err)
+		log.AddContext(ctx).Errorln(msg)
+		return &csi.NodeGetVolumeStatsResponse{
+			VolumeCondition: &csi.VolumeCondition{Abnormal: true, Message: msg},
+		}, nil
+	}
+
 	volumeAvailable, ok := volumeMetrics.Available.AsInt64()
 	if !ok {
 		msg := fmt.Sprintf("Volume metrics available %v is invalid", volumeMetrics.Available)
@@ -353,9 +449,90 @@ func (d *Driver) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeS
 			},
 		},
 	}
+	d.limitUsageByQuota(ctx, volumeID, response.Usage[0])
+
+	source, err := connector.GetMountSource(ctx, VolumePath)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Get mount source of %s error: %v", VolumePath, err)
+	} else if strings.HasPrefix(source, "/dev/") {
+		response.VolumeCondition = getDeviceCondition(ctx, source)
+	} else {
+		response.VolumeCondition = &csi.VolumeCondition{Message: normalVolumeMessage}
+	}
 	return response, nil
 }
 
+// isBlockDevice checks whether the volume path is the device of a raw block volume, which is bind mounted
+// onto a file, or a symlink to the device for the volumes published by the earlier versions
+var isBlockDevice = func(volumePath string) (bool, error) {
+	info, err := os.Stat(volumePath)
+	if err != nil {
+		return false, err
+	}
+	return info.Mode()&os.ModeDevice != 0 && info.Mode()&os.ModeCharDevice == 0, nil
+}
+
+// getBlockVolumeStats reports the size of the raw block volume, whose usage and inodes are unknown to the node
+func getBlockVolumeStats(ctx context.Context, volumePath string) (*csi.NodeGetVolumeStatsResponse, error) {
+	size, err := connector.GetDeviceSize(ctx, volumePath)
+	if err != nil {
+		msg := fmt.Sprintf("get size of block volume %s error: %v", volumePath, err)
+		log.AddContext(ctx).Errorln(msg)
+		return nil, status.Error(codes.Internal, msg)
+	}
+
+	return &csi.NodeGetVolumeStatsResponse{
+		Usage: []*csi.VolumeUsage{
+			{
+				Total: size,
+				Unit:  csi.VolumeUsage_BYTES,
+			},
+		},
+		VolumeCondition: getDeviceCondition(ctx, volumePath),
+	}, nil
+}
+
+const normalVolumeMessage = "The volume is normal on the node"
+
+// getDeviceCondition reports the volume abnormal if paths of its device are lost, the condition is unknown
+// and nil is returned if the paths can not be checked
+func getDeviceCondition(ctx context.Context, devPath string) *csi.VolumeCondition {
+	message, err := connector.CheckDevicePaths(ctx, devPath)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Check paths of device %s error: %v", devPath, err)
+		return nil
+	}
+	if message != "" {
+		log.AddContext(ctx).Warningln(message)
+		return &csi.VolumeCondition{Abnormal: true, Message: message}
+	}
+	return &csi.VolumeCondition{Message: normalVolumeMessage}
+}
+
+// volumeMetricsTimeout is how long the statfs of the volume path is waited for, which hangs when the NFS
+// share is unreachable
+var volumeMetricsTimeout = 10 * time.Second
+
+func getVolumeMetrics(volumePath string) (*utils.VolumeMetrics, error) {
+	type result struct {
+		metrics *utils.VolumeMetrics
+		err     error
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		metrics, err := utils.GetVolumeMetrics(volumePath)
+		ch <- result{metrics: metrics, err: err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.metrics, r.err
+	case <-time.After(volumeMetricsTimeout):
+		return nil, fmt.Errorf("volume path %s is unreachable in %s", volumePath, volumeMetricsTimeout)
+	}
+}
+
 func (d *Driver) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandVolumeRequest) (*csi.NodeExpandVolumeResponse, error) {
 	log.AddContext(ctx).Infof("Start to node expand volume %s", req)
 	volumeId := req.GetVolumeId()
@@ -397,7 +574,7 @@ func (d *Driver) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandVolume
 	err := backend.Plugin.NodeExpandVolume(ctx, volName, volumePath, isBlock, capacityRange.RequiredBytes)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Node expand volume %s error: %v", volName, err)
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, toStatusError(err)
 	}
 	log.AddContext(ctx).Infof("Finish node expand volume %s", volumeId)
 	return &csi.NodeExpandVolumeResponse{}, nil
@@ -0,0 +1,82 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"path"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"huawei-csi-driver/connector"
+)
+
+func TestNodeGetVolumeStats(t *testing.T) {
+	dir := t.TempDir()
+	var testCases = []struct {
+		name       string
+		volumePath string
+		block      bool
+		usages     int
+		code       codes.Code
+	}{
+		{"noPath", "", false, 0, codes.InvalidArgument},
+		{"notExist", path.Join(dir, "notExist"), false, 0, codes.NotFound},
+		{"filesystem", dir, false, 2, codes.OK},
+		{"block", dir, true, 1, codes.OK},
+	}
+
+	stubs := gostub.Stub(&connector.GetDeviceSize, func(ctx context.Context, hostDevice string) (int64, error) {
+		return 1024, nil
+	})
+	defer stubs.Reset()
+	stubs.Stub(&connector.GetMountSource, func(ctx context.Context, mountPath string) (string, error) {
+		return "/dev/mapper/mpatha", nil
+	})
+	stubs.Stub(&connector.CheckDevicePaths, func(ctx context.Context, devPath string) (string, error) {
+		return "The paths [sdc(offline)] of device dm-3 are lost", nil
+	})
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.block {
+				blockStubs := gostub.Stub(&isBlockDevice, func(volumePath string) (bool, error) {
+					return true, nil
+				})
+				defer blockStubs.Reset()
+			}
+
+			rsp, err := (&Driver{}).NodeGetVolumeStats(context.Background(), &csi.NodeGetVolumeStatsRequest{
+				VolumeId:   "backend.pvc-1",
+				VolumePath: c.volumePath,
+			})
+			assert.Equal(t, c.code, status.Code(err))
+			assert.Len(t, rsp.GetUsage(), c.usages)
+			if c.block {
+				assert.Equal(t, int64(1024), rsp.GetUsage()[0].GetTotal())
+			}
+			if c.code == codes.OK {
+				assert.True(t, rsp.GetVolumeCondition().GetAbnormal())
+			}
+		})
+	}
+}
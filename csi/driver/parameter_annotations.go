@@ -0,0 +1,86 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// parameterAnnotationPrefix followed by the name of a parameter in overridableParameters is the PVC
+// annotation overriding the parameter of the storage class, e.g. csi.huawei.com/allocType
+const parameterAnnotationPrefix = "csi.huawei.com/"
+
+// overridableParameters are the storage class parameters which a PVC can override by annotation, the
+// clonespeed is overridden by processCloneSpeedAnnotation
+var overridableParameters = []string{"qos", "allocType", "applicationType", authClientKey}
+
+// processParameterAnnotations overrides the storage class parameters with the annotations of the PVC,
+// and returns the parameters overridden
+func (d *Driver) processParameterAnnotations(ctx context.Context,
+	parameters map[string]interface{}) (map[string]string, error) {
+	if d.k8sUtils == nil {
+		return nil, nil
+	}
+
+	claimName, _ := parameters[pvcNameKey].(string)
+	namespace, _ := parameters[pvcNamespaceKey].(string)
+	if claimName == "" || namespace == "" {
+		return nil, nil
+	}
+
+	pvc, err := d.k8sUtils.GetClaim(ctx, namespace, claimName)
+	if err != nil {
+		return nil, toStatusError(utils.Errorf(ctx, "get pvc %s/%s error: %v", namespace, claimName, err))
+	}
+
+	overrides := make(map[string]string)
+	for _, key := range overridableParameters {
+		value, exist := pvc.Annotations[parameterAnnotationPrefix+key]
+		if !exist {
+			continue
+		}
+
+		if key == "allocType" && value != "thin" && value != "thick" {
+			msg := fmt.Sprintf("annotation %s%s of pvc %s/%s must be thin or thick, not %s",
+				parameterAnnotationPrefix, key, namespace, claimName, value)
+			log.AddContext(ctx).Errorln(msg)
+			return nil, status.Error(codes.InvalidArgument, msg)
+		}
+		if key == authClientKey {
+			if err := ValidateAuthClient(value); err != nil {
+				msg := fmt.Sprintf("annotation %s%s of pvc %s/%s is invalid: %v", parameterAnnotationPrefix, key,
+					namespace, claimName, err)
+				log.AddContext(ctx).Errorln(msg)
+				return nil, status.Error(codes.InvalidArgument, msg)
+			}
+		}
+
+		log.AddContext(ctx).Infof("Parameter %s %s of pvc %s/%s overrides the storage class", key, value,
+			namespace, claimName)
+		parameters[key] = value
+		overrides[key] = value
+	}
+
+	return overrides, nil
+}
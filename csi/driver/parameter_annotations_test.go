@@ -0,0 +1,65 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProcessParameterAnnotations(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		want        map[string]interface{}
+		wantErr     bool
+	}{
+		{"No annotation", nil, map[string]interface{}{"allocType": "thin", "qos": `{"MAXIOPS": 100}`}, false},
+		{"Override", map[string]string{
+			"csi.huawei.com/allocType":       "thick",
+			"csi.huawei.com/applicationType": "Oracle_OLAP",
+			"csi.huawei.com/storagepool":     "pool2",
+		}, map[string]interface{}{"allocType": "thick", "qos": `{"MAXIOPS": 100}`, "applicationType": "Oracle_OLAP"},
+			false},
+		{"Invalid allocType", map[string]string{"csi.huawei.com/allocType": "thinner"},
+			map[string]interface{}{"allocType": "thin", "qos": `{"MAXIOPS": 100}`}, true},
+		{"AuthClient", map[string]string{"csi.huawei.com/authClient": "10.0.0.0/24;10.1.0.5"},
+			map[string]interface{}{"allocType": "thin", "qos": `{"MAXIOPS": 100}`, "authClient": "10.0.0.0/24;10.1.0.5"},
+			false},
+		{"Invalid authClient", map[string]string{"csi.huawei.com/authClient": "10.0.0.0/40"},
+			map[string]interface{}{"allocType": "thin", "qos": `{"MAXIOPS": 100}`}, true},
+	}
+
+	for _, c := range cases {
+		d := &Driver{k8sUtils: &fakeCloneSpeedClaim{
+			pvc: &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Annotations: c.annotations}},
+		}}
+		parameters := map[string]interface{}{
+			"allocType": "thin", "qos": `{"MAXIOPS": 100}`, pvcNameKey: "pvc", pvcNamespaceKey: "default",
+		}
+		_, err := d.processParameterAnnotations(context.Background(), parameters)
+		assert.Equal(t, c.wantErr, err != nil, c.name)
+
+		delete(parameters, pvcNameKey)
+		delete(parameters, pvcNamespaceKey)
+		assert.Equal(t, c.want, parameters, c.name)
+	}
+}
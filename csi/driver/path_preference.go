@@ -0,0 +1,50 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// refreshPathPreference lets the paths of the published volume be used by the current preference of the
+// node, e.g. the paths to the local HyperMetro site after it recovers. A failure only leaves the paths
+// as they are, so it does not fail the publishing.
+func (d *Driver) refreshPathPreference(ctx context.Context, volumeID string) {
+	backendName, volName := utils.SplitVolumeId(volumeID)
+	b := backend.GetBackend(backendName)
+	if b == nil {
+		return
+	}
+
+	refresher, ok := b.Plugin.(plugin.PathPreferenceRefresher)
+	if !ok {
+		return
+	}
+
+	err := refresher.RefreshPathPreference(ctx, volName, map[string]interface{}{
+		"volumeUseMultiPath": d.useMultiPath,
+		"scsiMultiPathType":  d.scsiMultiPathType,
+	})
+	if err != nil {
+		log.AddContext(ctx).Warningf("Refresh path preference of volume %s error: %v", volumeID, err)
+	}
+}
@@ -0,0 +1,44 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/utils/log"
+)
+
+// pinVolumePlacement restricts the pool selection to the pool a volume of the same name already exists in,
+// otherwise the random pool weighting may create a retried volume again on another pool. The volume is
+// looked up on storage, so the placement survives a restart or a change of the leader controller.
+func (d *Driver) pinVolumePlacement(ctx context.Context, volumeName string,
+	parameters map[string]interface{}) error {
+	pool, err := backend.FindVolumePool(ctx, volumeName, parameters)
+	if err != nil {
+		return err
+	}
+	if pool == nil {
+		return nil
+	}
+
+	log.AddContext(ctx).Infof("Volume %s already exists in pool %s of backend %s, reuse it",
+		volumeName, pool.Name, pool.Parent)
+	parameters["backend"] = pool.Parent
+	parameters["pool"] = pool.Name
+	return nil
+}
@@ -0,0 +1,71 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	// poolReserveReachedReason is the event reason of a PVC refused by the reserve of the pools
+	poolReserveReachedReason = "PoolReserveReached"
+	// poolReserveLowReason is the event reason of a PVC created in a pool below its warning reserve
+	poolReserveLowReason = "PoolReserveLow"
+)
+
+// checkPoolReserve records an event of the PVC if the pool selection is refused by the reserve of the
+// pools, or if the pool selected is below its warning reserve. The refusing reserve of the pool is
+// passed to the plugin as poolReserve, so that it is checked again with the latest capacity.
+func (d *Driver) checkPoolReserve(ctx context.Context, parameters map[string]interface{},
+	pool *backend.StoragePool, selectErr error, size int64) {
+	if selectErr != nil {
+		if errors.Is(selectErr, utils.ErrPoolReserveReached) {
+			d.recordClaimEvent(ctx, parameters, poolReserveReachedReason, selectErr.Error())
+		}
+		return
+	}
+
+	if pool.Reserve != nil && pool.Reserve.RefuseFreePercent > 0 {
+		parameters["poolReserve"] = pool.Reserve.RefuseFreePercent
+	}
+
+	allocType, _ := parameters["allocType"].(string)
+	if warning := backend.GetPoolReserveWarning(pool, size, allocType); warning != "" {
+		log.AddContext(ctx).Warningln(warning)
+		d.recordClaimEvent(ctx, parameters, poolReserveLowReason, warning)
+	}
+}
+
+func (d *Driver) recordClaimEvent(ctx context.Context, parameters map[string]interface{}, reason, message string) {
+	claimName, _ := parameters[pvcNameKey].(string)
+	namespace, _ := parameters[pvcNamespaceKey].(string)
+	if d.k8sUtils == nil || claimName == "" {
+		return
+	}
+
+	err := d.k8sUtils.RecordClaimEvent(ctx, namespace, claimName, corev1.EventTypeWarning, reason, message)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Record event %s of pvc %s/%s error: %v", reason, namespace, claimName, err)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/storage/oceanstor/volume"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// The StorageClass parameters of the SmartQuota of the filesystem and dtree volumes. softQuota and hardQuota
+// are in percent of the volume capacity, so that they are recomputed when the volume is expanded, and
+// gracePeriod is how many days the soft quota is allowed to be exceeded.
+const (
+	softQuotaKey   = "softQuota"
+	hardQuotaKey   = "hardQuota"
+	gracePeriodKey = "gracePeriod"
+)
+
+var quotaKeys = []string{softQuotaKey, hardQuotaKey, gracePeriodKey}
+
+// checkQuota checks the quota parameters, which only the filesystem and dtree volumes set
+func checkQuota(parameters map[string]interface{}) error {
+	softQuota, _ := parameters[softQuotaKey].(string)
+	hardQuota, _ := parameters[hardQuotaKey].(string)
+	gracePeriod, _ := parameters[gracePeriodKey].(string)
+	quota, err := volume.NewQuota(0, softQuota, hardQuota, gracePeriod)
+	if err != nil {
+		return fmt.Errorf("quota parameters in storageClass.yaml are invalid: %v", err)
+	}
+	if quota != nil && !isFileVolume(parameters) {
+		return fmt.Errorf("only the volumes of volumeType fs or dtree can set %s, %s and %s",
+			softQuotaKey, hardQuotaKey, gracePeriodKey)
+	}
+	return nil
+}
+
+// UpdateQuota recomputes the quota of an expanded or shrunk volume whose StorageClass specifies quota
+// parameters
+func UpdateQuota(ctx context.Context, b *backend.Backend, volName string, size int64,
+	attributes map[string]string) error {
+	if attributes[softQuotaKey] == "" && attributes[hardQuotaKey] == "" {
+		return nil
+	}
+
+	updater, ok := b.Plugin.(plugin.QuotaUpdater)
+	if !ok {
+		log.AddContext(ctx).Warningf("Backend %s can not update the quota of volume %s to its new size",
+			b.Name, volName)
+		return nil
+	}
+
+	log.AddContext(ctx).Infof("Update quota of volume %s for %d bytes", volName, size)
+	return updater.UpdateQuota(ctx, volName, size, attributes[softQuotaKey], attributes[hardQuotaKey],
+		attributes[gracePeriodKey])
+}
+
+// quotaVolumes records whether the volumes staged or published on the node have a hard quota, so that
+// their stats do not query the storage unless the quota limits their capacity
+var quotaVolumes sync.Map
+
+// recordQuotaVolume records whether the volume has a hard quota by the context it is staged with
+func recordQuotaVolume(volumeID string, volumeContext map[string]string) {
+	quotaVolumes.Store(volumeID, volumeContext[hardQuotaKey] != "")
+}
+
+// hasHardQuota returns whether the volume has a hard quota, by the context it is staged with or by the
+// attributes of its PV if it is staged before the driver is started
+func (d *Driver) hasHardQuota(ctx context.Context, volumeID string) bool {
+	if hasQuota, exist := quotaVolumes.Load(volumeID); exist {
+		return hasQuota.(bool)
+	}
+
+	attributes, err := d.getVolumeAttributes(ctx, volumeID)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Get attributes of volume %s error: %v", volumeID, err)
+		return false
+	}
+
+	hasQuota := attributes[hardQuotaKey] != ""
+	quotaVolumes.Store(volumeID, hasQuota)
+	return hasQuota
+}
+
+// limitUsageByQuota reports the hard quota of the volume as its capacity, which the statfs on the node
+// doesn't reflect, such as the dtrees which report the capacity of their parent filesystem
+func (d *Driver) limitUsageByQuota(ctx context.Context, volumeID string, usage *csi.VolumeUsage) {
+	backendName, volName := utils.SplitVolumeId(volumeID)
+	b := backend.GetBackend(backendName)
+	if b == nil {
+		return
+	}
+
+	getter, ok := b.Plugin.(plugin.QuotaUsageGetter)
+	if !ok {
+		return
+	}
+
+	if !getter.AlwaysLimitedByQuota() && !d.hasHardQuota(ctx, volumeID) {
+		return
+	}
+
+	limit, used, err := getter.GetQuotaUsage(ctx, volName)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Get quota usage of volume %s error: %v", volumeID, err)
+		return
+	}
+	applyQuotaUsage(usage, limit, used)
+}
+
+// applyQuotaUsage limits the usage to the hard quota, which is not limited if it is 0
+func applyQuotaUsage(usage *csi.VolumeUsage, limit, used int64) {
+	if limit <= 0 {
+		return
+	}
+
+	usage.Total = limit
+	usage.Used = used
+	usage.Available = limit - used
+	if usage.Available < 0 {
+		usage.Available = 0
+	}
+}
@@ -0,0 +1,100 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+)
+
+// fakeQuotaUsageGetter counts the queries of the quota usage of the volumes
+type fakeQuotaUsageGetter struct {
+	plugin.Plugin
+	alwaysLimited bool
+	queries       int
+}
+
+func (f *fakeQuotaUsageGetter) GetQuotaUsage(_ context.Context, _ string) (int64, int64, error) {
+	f.queries++
+	return 200, 150, nil
+}
+
+func (f *fakeQuotaUsageGetter) AlwaysLimitedByQuota() bool {
+	return f.alwaysLimited
+}
+
+func TestCheckQuota(t *testing.T) {
+	assert.NoError(t, checkQuota(map[string]interface{}{"volumeType": "lun"}))
+	assert.NoError(t, checkQuota(map[string]interface{}{"volumeType": "fs", softQuotaKey: "80",
+		hardQuotaKey: "100", gracePeriodKey: "7"}))
+	assert.NoError(t, checkQuota(map[string]interface{}{"volumeType": "dtree", softQuotaKey: "90"}))
+	assert.Error(t, checkQuota(map[string]interface{}{"volumeType": "lun", softQuotaKey: "80"}))
+	assert.Error(t, checkQuota(map[string]interface{}{"volumeType": "fs", hardQuotaKey: "101"}))
+	assert.Error(t, checkQuota(map[string]interface{}{"volumeType": "fs", softQuotaKey: "90", hardQuotaKey: "80"}))
+	assert.Error(t, checkQuota(map[string]interface{}{"volumeType": "fs", hardQuotaKey: "90", gracePeriodKey: "7"}))
+	assert.Error(t, checkQuota(map[string]interface{}{"volumeType": "fs", softQuotaKey: "90", gracePeriodKey: "0"}))
+}
+
+func TestApplyQuotaUsage(t *testing.T) {
+	usage := &csi.VolumeUsage{Total: 1000, Used: 300, Available: 700}
+	applyQuotaUsage(usage, 0, 100)
+	assert.Equal(t, &csi.VolumeUsage{Total: 1000, Used: 300, Available: 700}, usage)
+
+	applyQuotaUsage(usage, 200, 150)
+	assert.Equal(t, &csi.VolumeUsage{Total: 200, Used: 150, Available: 50}, usage)
+
+	applyQuotaUsage(usage, 200, 250)
+	assert.Equal(t, int64(0), usage.Available)
+}
+
+func TestLimitUsageByQuota(t *testing.T) {
+	cases := []struct {
+		name          string
+		alwaysLimited bool
+		volumeContext map[string]string
+		total         int64
+	}{
+		{"No hard quota", false, map[string]string{softQuotaKey: "80"}, 1000},
+		{"Hard quota", false, map[string]string{hardQuotaKey: "90"}, 200},
+		{"Dtree", true, map[string]string{}, 200},
+	}
+
+	getter := &fakeQuotaUsageGetter{}
+	stubs := gostub.Stub(&backend.GetBackend, func(name string) *backend.Backend {
+		return &backend.Backend{Name: name, Plugin: getter}
+	})
+	defer stubs.Reset()
+
+	for _, c := range cases {
+		getter.alwaysLimited = c.alwaysLimited
+		getter.queries = 0
+		recordQuotaVolume("backend.pvc-1", c.volumeContext)
+
+		usage := &csi.VolumeUsage{Total: 1000, Used: 300, Available: 700}
+		(&Driver{}).limitUsageByQuota(context.Background(), "backend.pvc-1", usage)
+		assert.Equal(t, c.total, usage.Total, c.name)
+		assert.Equal(t, c.total != 1000, getter.queries == 1, c.name)
+	}
+	quotaVolumes.Delete("backend.pvc-1")
+}
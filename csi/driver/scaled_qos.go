@@ -0,0 +1,151 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// qosPerGiBKey is the StorageClass parameter of the QoS parameters scaled by the volume size, such as
+// {"MAXIOPS": {"perGiB": 5, "min": 500, "max": 50000}}. They are merged into the qos parameter, and
+// recomputed when the volume is expanded.
+const qosPerGiBKey = "qosPerGiB"
+
+const gib = 1024 * 1024 * 1024
+
+// qosScale is a QoS parameter scaled by the volume size
+type qosScale struct {
+	PerGiB float64 `json:"perGiB"`
+	// Min and Max bound the scaled value, 0 means unbounded
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// scaleQoS returns the qos parameters merged with the qosPerGiB parameters computed for the size.
+// A scaled value is perGiB times the size in GiB rounded up, raised to min and capped to max.
+func scaleQoS(qos, qosPerGiB string, size int64) (string, error) {
+	params := make(map[string]interface{})
+	if qos != "" {
+		err := json.Unmarshal([]byte(qos), &params)
+		if err != nil {
+			return "", fmt.Errorf("failed to unmarshal qos parameters %s: %v", qos, err)
+		}
+	}
+
+	var scales map[string]qosScale
+	err := json.Unmarshal([]byte(qosPerGiB), &scales)
+	if err != nil {
+		return "", fmt.Errorf("failed to unmarshal %s parameters %s: %v", qosPerGiBKey, qosPerGiB, err)
+	}
+	if len(scales) == 0 {
+		return "", fmt.Errorf("%s parameters %s are empty", qosPerGiBKey, qosPerGiB)
+	}
+
+	sizeGiB := float64((size + gib - 1) / gib)
+	for key, scale := range scales {
+		if scale.PerGiB <= 0 || scale.Min < 0 || scale.Max < 0 || (scale.Max > 0 && scale.Max < scale.Min) {
+			return "", fmt.Errorf("%s parameter %s %+v is invalid", qosPerGiBKey, key, scale)
+		}
+
+		value := math.Max(math.Ceil(scale.PerGiB*sizeGiB), scale.Min)
+		if scale.Max > 0 {
+			value = math.Min(value, scale.Max)
+		}
+		params[key] = value
+	}
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// processScaledQoS merges the QoS parameters scaled by the volume size into the qos parameter
+func (d *Driver) processScaledQoS(ctx context.Context, parameters map[string]interface{}, size int64) error {
+	qosPerGiB, _ := parameters[qosPerGiBKey].(string)
+	if qosPerGiB == "" {
+		return nil
+	}
+
+	if shared, _ := parameters["qosShared"].(string); utils.StrToBool(ctx, shared) {
+		return errors.New("qosPerGiB can not be used together with qosShared")
+	}
+
+	qos, _ := parameters["qos"].(string)
+	scaled, err := scaleQoS(qos, qosPerGiB, size)
+	if err != nil {
+		log.AddContext(ctx).Errorln(err)
+		return err
+	}
+
+	log.AddContext(ctx).Infof("The QoS of %d bytes is %s", size, scaled)
+	parameters["qos"] = scaled
+	return nil
+}
+
+// getVolumeAttributes returns the attributes of the PV of the volume, which are recorded at its creation
+func (d *Driver) getVolumeAttributes(ctx context.Context, volumeID string) (map[string]string, error) {
+	if d.k8sUtils == nil {
+		return nil, nil
+	}
+
+	pvs, err := d.k8sUtils.ListBoundVolumes(ctx, d.name)
+	if err != nil {
+		return nil, utils.Errorf(ctx, "list PVs to get attributes of volume %s error: %v", volumeID, err)
+	}
+
+	for _, pv := range pvs {
+		if pv.VolumeHandle == volumeID {
+			return pv.Attributes, nil
+		}
+	}
+	return nil, nil
+}
+
+// updateScaledQoS recomputes the QoS of an expanded volume whose StorageClass specifies qosPerGiB
+func (d *Driver) updateScaledQoS(ctx context.Context, b *backend.Backend, volumeID, volName string,
+	size int64, attributes map[string]string) error {
+	qosPerGiB := attributes[qosPerGiBKey]
+	if qosPerGiB == "" {
+		return nil
+	}
+
+	updater, ok := b.Plugin.(plugin.QoSUpdater)
+	if !ok {
+		log.AddContext(ctx).Warningf("Backend %s can not update the QoS of volume %s to its new size",
+			b.Name, volName)
+		return nil
+	}
+
+	qos, err := scaleQoS(attributes["qos"], qosPerGiB, size)
+	if err != nil {
+		return utils.Errorf(ctx, "scale QoS of volume %s error: %v", volumeID, err)
+	}
+
+	log.AddContext(ctx).Infof("Update QoS of volume %s to %s for %d bytes", volName, qos, size)
+	return updater.UpdateQoS(ctx, volName, qos)
+}
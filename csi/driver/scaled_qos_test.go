@@ -0,0 +1,59 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScaleQoS(t *testing.T) {
+	cases := []struct {
+		name      string
+		qos       string
+		qosPerGiB string
+		size      int64
+		want      string
+		wantErr   bool
+	}{
+		{"Scaled", "", `{"MAXIOPS": {"perGiB": 5}}`, 100 * gib, `{"MAXIOPS":500}`, false},
+		{"Rounded up to GiB", "", `{"MAXIOPS": {"perGiB": 5}}`, 100*gib + 1, `{"MAXIOPS":505}`, false},
+		{"Floor", "", `{"MAXIOPS": {"perGiB": 5, "min": 1000}}`, 100 * gib, `{"MAXIOPS":1000}`, false},
+		{"Ceiling", "", `{"MAXIOPS": {"perGiB": 5, "max": 300}}`, 100 * gib, `{"MAXIOPS":300}`, false},
+		{"Merged with fixed QoS", `{"IOTYPE": 2, "MAXIOPS": 100}`, `{"MAXIOPS": {"perGiB": 5}}`, 100 * gib,
+			`{"IOTYPE":2,"MAXIOPS":500}`, false},
+		{"Invalid perGiB", "", `{"MAXIOPS": {"perGiB": 0}}`, 100 * gib, "", true},
+		{"Ceiling below floor", "", `{"MAXIOPS": {"perGiB": 5, "min": 1000, "max": 300}}`, gib, "", true},
+	}
+
+	for _, c := range cases {
+		qos, err := scaleQoS(c.qos, c.qosPerGiB, c.size)
+		assert.Equal(t, c.wantErr, err != nil, c.name)
+		assert.Equal(t, c.want, qos, c.name)
+	}
+}
+
+func TestProcessScaledQoSShared(t *testing.T) {
+	d := &Driver{}
+	parameters := map[string]interface{}{
+		qosPerGiBKey: `{"MAXIOPS": {"perGiB": 5}}`,
+		"qosShared":  "true",
+	}
+	assert.Error(t, d.processScaledQoS(context.Background(), parameters, gib))
+}
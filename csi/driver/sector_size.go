@@ -0,0 +1,45 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"fmt"
+
+	"huawei-csi-driver/storage/oceanstor/volume"
+)
+
+// sectorSizeKey is the StorageClass parameter of the sector size of the filesystems, such as 8K, which
+// suits the IO size of the workloads like databases
+const sectorSizeKey = "sectorSize"
+
+// checkSectorSize checks the sectorSize parameter, which only filesystems set since a dtree takes the sector
+// size of its parent filesystem. The sizes supported by the product are checked by the backend.
+func checkSectorSize(parameters map[string]interface{}) error {
+	sectorSize, exist := parameters[sectorSizeKey].(string)
+	if !exist {
+		return nil
+	}
+
+	_, err := volume.ParseFSSectorSize(sectorSize)
+	if err != nil {
+		return err
+	}
+	if parameters["volumeType"] != "fs" {
+		return fmt.Errorf("only the volumes of volumeType fs can set %s", sectorSizeKey)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/storage/oceanstor/volume"
+	"huawei-csi-driver/utils/log"
+)
+
+// smartTierKey is the StorageClass parameter of the SmartTier relocation policy of the LUNs on hybrid arrays
+const smartTierKey = "smarttier"
+
+// checkSmartTier checks the smarttier parameter, only the LUNs are relocated by SmartTier
+func checkSmartTier(parameters map[string]interface{}) error {
+	policy, exist := parameters[smartTierKey].(string)
+	if !exist {
+		return nil
+	}
+
+	if _, valid := volume.SmartTierPolicies[policy]; !valid {
+		return fmt.Errorf("smarttier [%s] in storageClass.yaml must be none, automatic, highest or lowest", policy)
+	}
+	if isFileVolume(parameters) {
+		return errors.New("only the volumes of volumeType lun can set smarttier")
+	}
+	return nil
+}
+
+// updateSmartTier sets the relocation policy of the expanded volume again, so that the extents added by
+// the expansion are relocated by it too
+func updateSmartTier(ctx context.Context, b *backend.Backend, volName, policy string) error {
+	if policy == "" {
+		return nil
+	}
+
+	updater, ok := b.Plugin.(plugin.SmartTierUpdater)
+	if !ok {
+		log.AddContext(ctx).Warningf("Backend %s can not update the SmartTier policy of volume %s",
+			b.Name, volName)
+		return nil
+	}
+
+	log.AddContext(ctx).Infof("Update SmartTier policy of volume %s to %s", volName, policy)
+	return updater.UpdateSmartTier(ctx, volName, policy)
+}
@@ -0,0 +1,196 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/storage/oceanstor/volume"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// createSnapshot creates a snapshot of the type given by the snapshotType parameter of the snapshot
+// class, a classic snapshot is created if no type is given
+func createSnapshot(ctx context.Context, b *backend.Backend,
+	volName, snapshotName, snapshotType string) (map[string]interface{}, error) {
+	if snapshotType == "" || snapshotType == plugin.ClassicSnapshotType {
+		return b.Plugin.CreateSnapshot(ctx, volName, snapshotName)
+	}
+
+	if snapshotType != plugin.HyperCDPSnapshotType {
+		return nil, status.Errorf(codes.InvalidArgument, "snapshot type %s is invalid, it has to be %s or %s",
+			snapshotType, plugin.ClassicSnapshotType, plugin.HyperCDPSnapshotType)
+	}
+
+	creator, ok := b.Plugin.(plugin.TypedSnapshotCreator)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "snapshot type %s is not supported by backend %s",
+			snapshotType, b.Name)
+	}
+	return creator.CreateTypedSnapshot(ctx, volName, snapshotName, snapshotType)
+}
+
+// querySnapshot looks up the snapshot of the snapshot ID on storage, which is either created by
+// CreateSnapshot or adopted from the array by a pre-provisioned VolumeSnapshotContent whose
+// snapshot handle is "<backend>.<parent ID>.<snapshot name on storage>". Nil is returned if the
+// snapshot does not exist.
+func querySnapshot(ctx context.Context, b *backend.Backend,
+	snapshotID, parentID, snapshotName string) (*csi.Snapshot, error) {
+	query, ok := b.Plugin.(plugin.SnapshotQuery)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented,
+			"querying snapshots of backend %s is not supported", b.Name)
+	}
+
+	snapshot, err := query.QuerySnapshot(ctx, parentID, snapshotName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Query snapshot %s error: %v", snapshotID, err)
+		return nil, toStatusError(err)
+	}
+	if snapshot == nil {
+		log.AddContext(ctx).Infof("Snapshot %s does not exist on backend %s", snapshotID, b.Name)
+		return nil, nil
+	}
+
+	return toCSISnapshot(b.Name, snapshotID, snapshot), nil
+}
+
+// toCSISnapshot converts the snapshot returned by the plugin to the CSI snapshot of the snapshot ID. The
+// source volume is the one the parent was created for, or the parent name on storage if it is not recorded.
+func toCSISnapshot(backendName, snapshotID string, snapshot map[string]interface{}) *csi.Snapshot {
+	sizeBytes, _ := snapshot["SizeBytes"].(int64)
+	creationTime, _ := snapshot["CreationTime"].(int64)
+	parentVolume, _ := snapshot["ParentVolume"].(string)
+	if parentVolume == "" {
+		parentVolume, _ = snapshot["ParentName"].(string)
+	}
+	return &csi.Snapshot{
+		SizeBytes:      sizeBytes,
+		SnapshotId:     snapshotID,
+		SourceVolumeId: fmt.Sprintf("%s.%s", backendName, parentVolume),
+		CreationTime:   &timestamp.Timestamp{Seconds: creationTime},
+		ReadyToUse:     true,
+	}
+}
+
+// parseSnapshotsToken parses the token of ListSnapshots, which is "<backend>:<offset>" to continue listing
+// from the offset of the backend. An empty token means listing from the start.
+func parseSnapshotsToken(token string) (string, int, error) {
+	if token == "" {
+		return "", 0, nil
+	}
+
+	index := strings.LastIndex(token, ":")
+	if index <= 0 {
+		return "", 0, fmt.Errorf("starting token %s is invalid", token)
+	}
+
+	offset, err := strconv.Atoi(token[index+1:])
+	if err != nil || offset < 0 {
+		return "", 0, fmt.Errorf("starting token %s is invalid", token)
+	}
+	return token[:index], offset, nil
+}
+
+// listSnapshots pages through the snapshots of the source volume, or of all the backends in the order of
+// their names. At most maxEntries snapshots are returned, and at most a page of storage queries if it is 0.
+func listSnapshots(ctx context.Context, sourceVolumeID, startingToken string,
+	maxEntries int32) (*csi.ListSnapshotsResponse, error) {
+	startBackend, offset, err := parseSnapshotsToken(startingToken)
+	if err != nil {
+		return nil, status.Error(codes.Aborted, err.Error())
+	}
+
+	backendNames := backend.GetBackendNames()
+	var volName string
+	if sourceVolumeID != "" {
+		var backendName string
+		backendName, volName = utils.SplitVolumeId(sourceVolumeID)
+		if backend.GetBackend(backendName) == nil {
+			log.AddContext(ctx).Infof("Backend of source volume %s doesn't exist", sourceVolumeID)
+			return &csi.ListSnapshotsResponse{}, nil
+		}
+		backendNames = []string{backendName}
+	}
+
+	limit := int(maxEntries)
+	if limit <= 0 || limit > volume.MaxSnapshotsPage {
+		limit = volume.MaxSnapshotsPage
+	}
+
+	var entries []*csi.ListSnapshotsResponse_Entry
+	for _, name := range backendNames {
+		if name < startBackend {
+			continue
+		} else if name != startBackend {
+			offset = 0
+		}
+
+		lister, ok := backend.GetBackend(name).Plugin.(plugin.SnapshotLister)
+		if !ok {
+			continue
+		}
+
+		if len(entries) >= limit {
+			return &csi.ListSnapshotsResponse{
+				Entries:   entries,
+				NextToken: fmt.Sprintf("%s:%d", name, offset),
+			}, nil
+		}
+
+		for {
+			snapshots, next, err := lister.ListSnapshots(ctx, volName, offset, limit-len(entries))
+			if err != nil {
+				log.AddContext(ctx).Errorf("List snapshots of backend %s error: %v", name, err)
+				return nil, toStatusError(err)
+			}
+
+			for _, snapshot := range snapshots {
+				parentID, _ := snapshot["ParentID"].(string)
+				snapshotName, _ := snapshot["Name"].(string)
+				snapshotID := fmt.Sprintf("%s.%s.%s", name, parentID, snapshotName)
+				entries = append(entries, &csi.ListSnapshotsResponse_Entry{
+					Snapshot: toCSISnapshot(name, snapshotID, snapshot),
+				})
+			}
+
+			if next == 0 {
+				break
+			}
+			if len(entries) >= limit {
+				return &csi.ListSnapshotsResponse{
+					Entries:   entries,
+					NextToken: fmt.Sprintf("%s:%d", name, next),
+				}, nil
+			}
+			offset = next
+		}
+	}
+
+	return &csi.ListSnapshotsResponse{Entries: entries}, nil
+}
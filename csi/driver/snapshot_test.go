@@ -0,0 +1,254 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+)
+
+type fakeSnapshotPlugin struct {
+	plugin.Plugin
+	snapshot map[string]interface{}
+	err      error
+}
+
+func (f *fakeSnapshotPlugin) QuerySnapshot(ctx context.Context,
+	parentID, name string) (map[string]interface{}, error) {
+	return f.snapshot, f.err
+}
+
+// testVolumeName is a PV name longer than the LUN names on storage
+const testVolumeName = "pvc-6f2b1a7c-8e1d-4c3b-9a5f-0d2e4b6c8a10"
+
+func TestQuerySnapshot(t *testing.T) {
+	arraySnapshot := map[string]interface{}{
+		"SizeBytes":    int64(1024),
+		"CreationTime": int64(1600000000),
+		"ParentID":     "12",
+		"ParentName":   "lun01",
+	}
+	volumeSnapshot := map[string]interface{}{
+		"SizeBytes":    int64(1024),
+		"CreationTime": int64(1600000000),
+		"ParentID":     "12",
+		"ParentName":   testVolumeName[:31],
+		"ParentVolume": testVolumeName,
+	}
+
+	var testCases = []struct {
+		name   string
+		plugin plugin.Plugin
+		exist  bool
+		source string
+		code   codes.Code
+	}{
+		{"notSupported", &struct{ plugin.Plugin }{}, false, "", codes.Unimplemented},
+		{"notExist", &fakeSnapshotPlugin{}, false, "", codes.OK},
+		{"queryError", &fakeSnapshotPlugin{err: errors.New("timeout")}, false, "", codes.Internal},
+		{"arraySnapshot", &fakeSnapshotPlugin{snapshot: arraySnapshot}, true, "backend1.lun01", codes.OK},
+		{"volumeSnapshot", &fakeSnapshotPlugin{snapshot: volumeSnapshot}, true,
+			"backend1." + testVolumeName, codes.OK},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			b := &backend.Backend{Name: "backend1", Plugin: c.plugin}
+			snapshot, err := querySnapshot(context.Background(), b,
+				"backend1.12.daily_0001", "12", "daily_0001")
+			assert.Equal(t, c.code, status.Code(err))
+			assert.Equal(t, c.exist, snapshot != nil)
+			if snapshot != nil {
+				assert.Equal(t, "backend1.12.daily_0001", snapshot.SnapshotId)
+				assert.Equal(t, c.source, snapshot.SourceVolumeId)
+				assert.Equal(t, int64(1024), snapshot.SizeBytes)
+				assert.True(t, snapshot.ReadyToUse)
+			}
+		})
+	}
+}
+
+type fakeTypedSnapshotPlugin struct {
+	plugin.Plugin
+	createdType string
+}
+
+func (f *fakeTypedSnapshotPlugin) CreateSnapshot(ctx context.Context,
+	volName, snapshotName string) (map[string]interface{}, error) {
+	f.createdType = plugin.ClassicSnapshotType
+	return map[string]interface{}{}, nil
+}
+
+func (f *fakeTypedSnapshotPlugin) CreateTypedSnapshot(ctx context.Context,
+	volName, snapshotName, snapshotType string) (map[string]interface{}, error) {
+	f.createdType = snapshotType
+	return map[string]interface{}{}, nil
+}
+
+func TestCreateSnapshotOfType(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		typed        bool
+		snapshotType string
+		createdType  string
+		code         codes.Code
+	}{
+		{"default", true, "", plugin.ClassicSnapshotType, codes.OK},
+		{"classic", false, plugin.ClassicSnapshotType, plugin.ClassicSnapshotType, codes.OK},
+		{"hyperCDP", true, plugin.HyperCDPSnapshotType, plugin.HyperCDPSnapshotType, codes.OK},
+		{"hyperCDPNotSupported", false, plugin.HyperCDPSnapshotType, "", codes.InvalidArgument},
+		{"invalidType", true, "clone", "", codes.InvalidArgument},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			fake := &fakeTypedSnapshotPlugin{}
+			var p plugin.Plugin = fake
+			if !c.typed {
+				p = &struct{ plugin.Plugin }{fake}
+			}
+
+			b := &backend.Backend{Name: "backend1", Plugin: p}
+			_, err := createSnapshot(context.Background(), b, "pvc-1", "snapshot-1", c.snapshotType)
+			assert.Equal(t, c.code, status.Code(err))
+			assert.Equal(t, c.createdType, fake.createdType)
+		})
+	}
+}
+
+type fakeSnapshotLister struct {
+	plugin.Plugin
+	total int
+}
+
+func (f *fakeSnapshotLister) ListSnapshots(ctx context.Context, volName string, offset, limit int) (
+	[]map[string]interface{}, int, error) {
+	var snapshots []map[string]interface{}
+	for i := offset; i < f.total && len(snapshots) < limit; i++ {
+		snapshots = append(snapshots, map[string]interface{}{
+			"ParentID":     "1",
+			"ParentName":   testVolumeName[:31],
+			"ParentVolume": testVolumeName,
+			"Name":         fmt.Sprintf("snapshot-%d", i),
+		})
+	}
+
+	next := offset + len(snapshots)
+	if next >= f.total {
+		next = 0
+	}
+	return snapshots, next, nil
+}
+
+func TestListSnapshots(t *testing.T) {
+	backends := map[string]*backend.Backend{
+		"backend1": {Name: "backend1", Plugin: &fakeSnapshotLister{total: 3}},
+		"backend2": {Name: "backend2", Plugin: &struct{ plugin.Plugin }{}},
+		"backend3": {Name: "backend3", Plugin: &fakeSnapshotLister{total: 2}},
+	}
+	stubs := gostub.Stub(&backend.GetBackendNames, func() []string {
+		return []string{"backend1", "backend2", "backend3"}
+	})
+	defer stubs.Reset()
+	stubs.Stub(&backend.GetBackend, func(name string) *backend.Backend {
+		return backends[name]
+	})
+
+	var testCases = []struct {
+		name      string
+		source    string
+		token     string
+		max       int32
+		first     string
+		count     int
+		nextToken string
+		code      codes.Code
+	}{
+		{"all", "", "", 0, "backend1.1.snapshot-0", 5, "", codes.OK},
+		{"firstPage", "", "", 2, "backend1.1.snapshot-0", 2, "backend1:2", codes.OK},
+		{"acrossBackends", "", "backend1:2", 2, "backend1.1.snapshot-2", 2, "backend3:1", codes.OK},
+		{"fullAtBackendEnd", "", "", 3, "backend1.1.snapshot-0", 3, "backend3:0", codes.OK},
+		{"lastPage", "", "backend3:1", 2, "backend3.1.snapshot-1", 1, "", codes.OK},
+		{"sourceVolume", "backend3." + testVolumeName, "", 0, "backend3.1.snapshot-0", 2, "", codes.OK},
+		{"sourceNotExist", "backend4." + testVolumeName, "", 0, "", 0, "", codes.OK},
+		{"invalidToken", "", "backend1", 0, "", 0, "", codes.Aborted},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			rsp, err := listSnapshots(context.Background(), c.source, c.token, c.max)
+			assert.Equal(t, c.code, status.Code(err))
+			if err != nil {
+				return
+			}
+
+			require.Len(t, rsp.Entries, c.count)
+			assert.Equal(t, c.nextToken, rsp.NextToken)
+			if c.count > 0 {
+				assert.Equal(t, c.first, rsp.Entries[0].Snapshot.SnapshotId)
+				backendName := strings.SplitN(c.first, ".", 2)[0]
+				assert.Equal(t, backendName+"."+testVolumeName, rsp.Entries[0].Snapshot.SourceVolumeId)
+			}
+		})
+	}
+}
+
+func TestListSnapshotsOfSnapshotID(t *testing.T) {
+	volumeSnapshot := map[string]interface{}{
+		"ParentID":     "12",
+		"ParentName":   testVolumeName[:31],
+		"ParentVolume": testVolumeName,
+	}
+	stubs := gostub.Stub(&backend.GetBackend, func(name string) *backend.Backend {
+		return &backend.Backend{Name: name, Plugin: &fakeSnapshotPlugin{snapshot: volumeSnapshot}}
+	})
+	defer stubs.Reset()
+
+	var testCases = []struct {
+		name   string
+		source string
+		count  int
+	}{
+		{"noSource", "", 1},
+		{"sourceVolume", "backend1." + testVolumeName, 1},
+		{"otherVolume", "backend1.pvc-2", 0},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			rsp, err := (&Driver{}).ListSnapshots(context.Background(), &csi.ListSnapshotsRequest{
+				SnapshotId:     "backend1.12.snapshot-1",
+				SourceVolumeId: c.source,
+			})
+			require.NoError(t, err)
+			assert.Len(t, rsp.Entries, c.count)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// directSnapshotAccessKey is the StorageClass parameter publishing the source snapshot of a read-only
+// volume as the volume, instead of cloning it
+const directSnapshotAccessKey = "directSnapshotAccess"
+
+// snapshotDirectoryVisibilityKey is the StorageClass parameter showing the snapshot directory of the
+// filesystems, visible or invisible by default, through which the pods browse the snapshots of their files
+const snapshotDirectoryVisibilityKey = "snapshotDirectoryVisibility"
+
+// checkSnapshotDirectoryVisibility checks the snapshotDirectoryVisibility parameter, which only filesystems
+// set since the snapshot directory of a dtree is the one of its parent filesystem
+func checkSnapshotDirectoryVisibility(parameters map[string]interface{}) error {
+	visibility, exist := parameters[snapshotDirectoryVisibilityKey].(string)
+	if !exist {
+		return nil
+	}
+
+	if !strings.EqualFold(visibility, "visible") && !strings.EqualFold(visibility, "invisible") {
+		return fmt.Errorf("%s [%s] in storageClass.yaml must be visible or invisible",
+			snapshotDirectoryVisibilityKey, visibility)
+	}
+	if parameters["volumeType"] != "fs" {
+		return fmt.Errorf("only the volumes of volumeType fs can set %s", snapshotDirectoryVisibilityKey)
+	}
+	return nil
+}
+
+// isDirectSnapshotAccess tells whether the volume to create publishes its source snapshot
+func isDirectSnapshotAccess(ctx context.Context, req *csi.CreateVolumeRequest) bool {
+	access, exist := req.GetParameters()[directSnapshotAccessKey]
+	return exist && utils.StrToBool(ctx, access) && req.GetVolumeContentSource().GetSnapshot() != nil
+}
+
+// createSnapshotVolume creates a read-only volume publishing the source snapshot, nothing is created
+// on storage
+func (d *Driver) createSnapshotVolume(ctx context.Context, req *csi.CreateVolumeRequest, size int64) (
+	*csi.Volume, error) {
+	for _, capability := range req.GetVolumeCapabilities() {
+		if !isReadOnlyMode(capability.GetAccessMode().GetMode()) {
+			msg := fmt.Sprintf("%s requires read-only access modes, not %s", directSnapshotAccessKey,
+				capability.GetAccessMode().GetMode())
+			log.AddContext(ctx).Errorln(msg)
+			return nil, status.Error(codes.InvalidArgument, msg)
+		}
+	}
+
+	snapshotID := req.GetVolumeContentSource().GetSnapshot().GetSnapshotId()
+	backendName, parentID, snapshotName := utils.SplitSnapshotId(snapshotID)
+	b := backend.GetBackend(backendName)
+	if b == nil {
+		msg := fmt.Sprintf("Backend %s doesn't exist", backendName)
+		log.AddContext(ctx).Errorln(msg)
+		return nil, status.Error(codes.NotFound, msg)
+	}
+
+	provider, ok := b.Plugin.(plugin.SnapshotVolumeProvider)
+	if !ok {
+		msg := fmt.Sprintf("Backend %s cannot publish snapshots as volumes", backendName)
+		log.AddContext(ctx).Errorln(msg)
+		return nil, status.Error(codes.InvalidArgument, msg)
+	}
+
+	volName, err := provider.GetSnapshotVolume(ctx, parentID, snapshotName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get volume of snapshot %s error: %v", snapshotID, err)
+		return nil, toStatusError(err)
+	}
+
+	log.AddContext(ctx).Infof("Volume %s publishes snapshot %s", volName, snapshotID)
+	return &csi.Volume{
+		VolumeId:      backendName + "." + volName,
+		CapacityBytes: size,
+		VolumeContext: map[string]string{
+			"backend": backendName,
+			"name":    volName,
+		},
+		ContentSource: req.GetVolumeContentSource(),
+	}, nil
+}
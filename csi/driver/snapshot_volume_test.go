@@ -0,0 +1,70 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newSnapshotVolumeRequest(mode csi.VolumeCapability_AccessMode_Mode) *csi.CreateVolumeRequest {
+	return &csi.CreateVolumeRequest{
+		Parameters: map[string]string{directSnapshotAccessKey: "true"},
+		VolumeCapabilities: []*csi.VolumeCapability{
+			{AccessMode: &csi.VolumeCapability_AccessMode{Mode: mode}},
+		},
+		VolumeContentSource: &csi.VolumeContentSource{
+			Type: &csi.VolumeContentSource_Snapshot{
+				Snapshot: &csi.VolumeContentSource_SnapshotSource{SnapshotId: "backend.1.snapshot_test"},
+			},
+		},
+	}
+}
+
+func TestCreateSnapshotVolumeWritable(t *testing.T) {
+	ctx := context.Background()
+	req := newSnapshotVolumeRequest(csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER)
+	assert.True(t, isDirectSnapshotAccess(ctx, req))
+
+	d := &Driver{}
+	_, err := d.createSnapshotVolume(ctx, req, 1024*1024)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestExpandSnapshotVolume(t *testing.T) {
+	d := &Driver{}
+	_, err := d.ControllerExpandVolume(context.Background(), &csi.ControllerExpandVolumeRequest{
+		VolumeId:      "backend.pvc_test/.snapshot/snapshot_test",
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 1024 * 1024},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestCheckSnapshotDirectoryVisibility(t *testing.T) {
+	assert.NoError(t, checkSnapshotDirectoryVisibility(map[string]interface{}{"volumeType": "dtree"}))
+	assert.NoError(t, checkSnapshotDirectoryVisibility(map[string]interface{}{"volumeType": "fs",
+		snapshotDirectoryVisibilityKey: "Visible"}))
+	assert.Error(t, checkSnapshotDirectoryVisibility(map[string]interface{}{"volumeType": "fs",
+		snapshotDirectoryVisibilityKey: "hidden"}))
+	assert.Error(t, checkSnapshotDirectoryVisibility(map[string]interface{}{"volumeType": "dtree",
+		snapshotDirectoryVisibilityKey: "visible"}))
+}
@@ -0,0 +1,48 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// SpaceReclamationOnline mounts the filesystems of the volumes with discard, so the space of the
+	// deleted files is reclaimed on storage at once
+	SpaceReclamationOnline = "online"
+	// SpaceReclamationPeriodic trims the mounted filesystems of the volumes periodically on the nodes
+	SpaceReclamationPeriodic = "periodic"
+)
+
+// checkSpaceReclamation checks the spaceReclamation parameter, only the filesystems on the LUNs
+// reclaim the space by discarding the blocks
+func checkSpaceReclamation(parameters map[string]interface{}) error {
+	reclamation, exist := parameters["spaceReclamation"].(string)
+	if !exist {
+		return nil
+	}
+
+	if reclamation != SpaceReclamationOnline && reclamation != SpaceReclamationPeriodic {
+		return fmt.Errorf("spaceReclamation [%s] in storageClass.yaml must be %s or %s",
+			reclamation, SpaceReclamationOnline, SpaceReclamationPeriodic)
+	}
+	if isFileVolume(parameters) {
+		return errors.New("only the volumes of volumeType lun can reclaim space by discarding blocks")
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSpaceReclamation(t *testing.T) {
+	assert.NoError(t, checkSpaceReclamation(map[string]interface{}{}))
+	assert.NoError(t, checkSpaceReclamation(map[string]interface{}{"spaceReclamation": SpaceReclamationOnline}))
+	assert.NoError(t, checkSpaceReclamation(map[string]interface{}{"spaceReclamation": SpaceReclamationPeriodic,
+		"volumeType": "lun"}))
+	assert.Error(t, checkSpaceReclamation(map[string]interface{}{"spaceReclamation": "always"}))
+	assert.Error(t, checkSpaceReclamation(map[string]interface{}{"spaceReclamation": SpaceReclamationOnline,
+		"volumeType": "fs"}))
+}
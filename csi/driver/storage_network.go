@@ -0,0 +1,87 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// storageNetworkKey is the backend parameter specifying the node network of the iSCSI and NVMe
+// traffic. It is either a Multus network attachment definition as <namespace>/<name>, or a
+// pattern of the node interface names such as ens1f*.
+const storageNetworkKey = "storageNetwork"
+
+var listInterfaces = net.Interfaces
+
+// getStorageInterfaces returns the up interfaces of the node on the storage network of the
+// backend, nil if the backend does not specify one
+func (d *Driver) getStorageInterfaces(ctx context.Context, b *backend.Backend) ([]string, error) {
+	network, _ := b.Parameters[storageNetworkKey].(string)
+	if network == "" {
+		return nil, nil
+	}
+
+	pattern := network
+	if strings.Contains(network, "/") {
+		if d.k8sUtils == nil {
+			return nil, utils.Errorf(ctx, "can not get network attachment definition %s without kubernetes client",
+				network)
+		}
+
+		namespaceName := strings.SplitN(network, "/", 2)
+		master, err := d.k8sUtils.GetNetworkAttachmentMaster(ctx, namespaceName[0], namespaceName[1])
+		if err != nil {
+			return nil, utils.Errorf(ctx, "get node interface of storage network %s error: %v", network, err)
+		}
+		pattern = master
+	}
+
+	ifaces, err := listInterfaces()
+	if err != nil {
+		return nil, utils.Errorf(ctx, "list node interfaces error: %v", err)
+	}
+
+	var names []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		matched, err := filepath.Match(pattern, iface.Name)
+		if err != nil {
+			return nil, utils.Errorf(ctx, "invalid interface pattern %s of storage network %s", pattern, network)
+		}
+		if matched {
+			names = append(names, iface.Name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"no interface of storage network %s is up on node %s", network, d.nodeName)
+	}
+
+	log.AddContext(ctx).Infof("Interfaces %v of storage network %s are used", names, network)
+	return names, nil
+}
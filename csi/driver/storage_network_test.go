@@ -0,0 +1,77 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/utils/k8sutils"
+)
+
+type fakeNetworkAttachment struct {
+	k8sutils.Interface
+	master string
+}
+
+func (f *fakeNetworkAttachment) GetNetworkAttachmentMaster(ctx context.Context,
+	namespace, name string) (string, error) {
+	return f.master, nil
+}
+
+func TestGetStorageInterfaces(t *testing.T) {
+	stubs := gostub.Stub(&listInterfaces, func() ([]net.Interface, error) {
+		return []net.Interface{
+			{Name: "lo", Flags: net.FlagUp | net.FlagLoopback},
+			{Name: "eth0", Flags: net.FlagUp},
+			{Name: "ens1f0", Flags: net.FlagUp},
+			{Name: "ens1f1", Flags: net.FlagUp},
+			{Name: "ens2f0", Flags: 0},
+		}, nil
+	})
+	defer stubs.Reset()
+
+	d := &Driver{k8sUtils: &fakeNetworkAttachment{master: "ens2f0"}}
+	cases := []struct {
+		name    string
+		network string
+		want    []string
+		wantErr bool
+	}{
+		{"No storage network", "", nil, false},
+		{"Interface pattern", "ens1f*", []string{"ens1f0", "ens1f1"}, false},
+		{"Network attachment definition on a down interface", "kube-system/storage", nil, true},
+	}
+
+	for _, c := range cases {
+		b := &backend.Backend{Parameters: map[string]interface{}{storageNetworkKey: c.network}}
+		interfaces, err := d.getStorageInterfaces(context.Background(), b)
+		assert.Equal(t, c.wantErr, err != nil, c.name)
+		assert.Equal(t, c.want, interfaces, c.name)
+	}
+
+	d.k8sUtils = &fakeNetworkAttachment{master: "eth0"}
+	b := &backend.Backend{Parameters: map[string]interface{}{storageNetworkKey: "kube-system/storage"}}
+	interfaces, err := d.getStorageInterfaces(context.Background(), b)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"eth0"}, interfaces)
+}
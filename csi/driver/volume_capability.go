@@ -0,0 +1,154 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"huawei-csi-driver/connector"
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// isLunStorage tells whether the volumes of the storage are LUNs
+func isLunStorage(storage string) bool {
+	return storage == "oceanstor-san" || storage == "fusionstorage-san"
+}
+
+// checkVolumeCapability returns why the volume of the storage can't be used with the capability,
+// empty if it can. A LUN is mapped to each node by a lun group of its own host, so a raw block LUN
+// can be written by multiple nodes, and unmapping it from one node leaves the other nodes mapped.
+// A filesystem on a LUN can only be written by a single node.
+func checkVolumeCapability(storage string, capability *csi.VolumeCapability) string {
+	if err := connector.VerifyMountOptions(capability.GetMount().GetMountFlags()); err != nil {
+		return err.Error()
+	}
+
+	mode := capability.GetAccessMode().GetMode()
+	if isLunStorage(storage) {
+		if capability.GetBlock() == nil && mode == csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER {
+			return "a filesystem on a LUN can't be written by multiple nodes, use volumeMode Block instead"
+		}
+		return ""
+	}
+
+	if capability.GetBlock() != nil {
+		return fmt.Sprintf("volumes of storage %s can't be used as raw block devices", storage)
+	}
+	return ""
+}
+
+// checkMountFlags verifies the mount flags of the filesystem capabilities of the volume to create,
+// along with the NFS version and security flavors set by the StorageClass
+func checkMountFlags(req *csi.CreateVolumeRequest, parameters map[string]interface{}) error {
+	var versionFlags []string
+	if nfsVersion, _ := parameters["nfsVersion"].(string); nfsVersion != "" && parameters["shareProtocol"] != "cifs" {
+		versionFlags = append(versionFlags, "vers="+nfsVersion)
+	}
+	if flavors, _ := parameters[nfsSecurityFlavorsKey].(string); flavors != "" {
+		versionFlags = append(versionFlags, "sec="+flavors)
+	}
+
+	for _, capability := range req.GetVolumeCapabilities() {
+		if capability.GetMount() == nil {
+			continue
+		}
+
+		err := connector.VerifyMountOptions(append(versionFlags, capability.GetMount().GetMountFlags()...))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkMkfsOptions checks the mkfsOptions parameter against the filesystem types of the capabilities,
+// ext4 is formatted if the filesystem type is not given
+func checkMkfsOptions(req *csi.CreateVolumeRequest, parameters map[string]interface{}) error {
+	mkfsOptions, _ := parameters["mkfsOptions"].(string)
+	if mkfsOptions == "" {
+		return nil
+	}
+
+	if isFileVolume(parameters) {
+		return errors.New("mkfsOptions is only supported by the volumes of volumeType lun")
+	}
+
+	for _, capability := range req.GetVolumeCapabilities() {
+		if capability.GetMount() == nil {
+			continue
+		}
+
+		fsType := capability.GetMount().GetFsType()
+		if fsType == "" {
+			fsType = "ext4"
+		}
+		if _, err := connector.SplitMkfsOptions(fsType, mkfsOptions); err != nil {
+			return fmt.Errorf("mkfsOptions [%s] in storageClass.yaml is invalid: %v", mkfsOptions, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateVolumeCapabilities confirms the capabilities if the volume supports all of them
+func (d *Driver) ValidateVolumeCapabilities(ctx context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (
+	*csi.ValidateVolumeCapabilitiesResponse, error) {
+	volumeID := req.GetVolumeId()
+	if volumeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "no volume ID provided")
+	}
+	if len(req.GetVolumeCapabilities()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no volume capabilities provided")
+	}
+
+	backendName, volName := utils.SplitVolumeId(volumeID)
+	b := backend.GetBackend(backendName)
+	if b == nil {
+		msg := fmt.Sprintf("Backend %s doesn't exist", backendName)
+		log.AddContext(ctx).Errorln(msg)
+		return nil, status.Error(codes.NotFound, msg)
+	}
+
+	_, _, err := getVolume(ctx, b, volumeID, volName)
+	if err != nil && status.Code(err) != codes.Unimplemented {
+		return nil, err
+	}
+
+	for _, capability := range req.GetVolumeCapabilities() {
+		if reason := checkVolumeCapability(b.Storage, capability); reason != "" {
+			log.AddContext(ctx).Infof("Volume %s doesn't support capability %v: %s", volumeID, capability, reason)
+			return &csi.ValidateVolumeCapabilitiesResponse{Message: reason}, nil
+		}
+	}
+
+	return &csi.ValidateVolumeCapabilitiesResponse{
+		Confirmed: &csi.ValidateVolumeCapabilitiesResponse_Confirmed{
+			VolumeContext:      req.GetVolumeContext(),
+			VolumeCapabilities: req.GetVolumeCapabilities(),
+			Parameters:         req.GetParameters(),
+		},
+	}, nil
+}
@@ -0,0 +1,63 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckVolumeCapability(t *testing.T) {
+	newCapability := func(block bool, mode csi.VolumeCapability_AccessMode_Mode) *csi.VolumeCapability {
+		capability := &csi.VolumeCapability{AccessMode: &csi.VolumeCapability_AccessMode{Mode: mode}}
+		if block {
+			capability.AccessType = &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}}
+		} else {
+			capability.AccessType = &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}}
+		}
+		return capability
+	}
+
+	loopMount := newCapability(false, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER)
+	loopMount.GetMount().MountFlags = []string{"loop"}
+
+	var testCases = []struct {
+		name       string
+		storage    string
+		capability *csi.VolumeCapability
+		supported  bool
+	}{
+		{"sanBlockRWX", "oceanstor-san", newCapability(true, csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER),
+			true},
+		{"sanFilesystemRWX", "fusionstorage-san",
+			newCapability(false, csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER), false},
+		{"sanFilesystemRWO", "oceanstor-san", newCapability(false, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+			true},
+		{"nasFilesystemRWX", "oceanstor-nas",
+			newCapability(false, csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER), true},
+		{"nasBlock", "oceanstor-nas", newCapability(true, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER), false},
+		{"sanFilesystemLoop", "oceanstor-san", loopMount, false},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.supported, checkVolumeCapability(c.storage, c.capability) == "")
+		})
+	}
+}
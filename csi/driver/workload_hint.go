@@ -0,0 +1,116 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	// workloadHintKey is the storage class parameter naming the kind of workload of the volumes,
+	// which stands for the array tuning in workloadProfiles
+	workloadHintKey = "workloadHint"
+
+	// The read prefetch policies of LUNs
+	prefetchNone        = 0
+	prefetchIntelligent = 3
+)
+
+// workloadProfile is the array tuning a workload hint stands for. The parameters of the storage
+// class take precedence over the profile.
+type workloadProfile struct {
+	// applicationType is the workload type of storage, skipped if the storage lacks it
+	applicationType string
+	// qos is the default QoS, skipped if the storage doesn't support it
+	qos string
+	// prefetchPolicy is the read prefetch policy of LUNs
+	prefetchPolicy int
+}
+
+// workloadProfiles are the profiles by workload hint. Backends steer the hints to pools with
+// poolWorkloadHints in their configuration.
+var workloadProfiles = map[string]workloadProfile{
+	"latency-sensitive": {
+		applicationType: "Oracle_OLTP",
+		qos:             `{"IOTYPE": 2, "LATENCY": 0.5}`,
+		prefetchPolicy:  prefetchNone,
+	},
+	"throughput": {
+		applicationType: "Oracle_OLAP",
+		prefetchPolicy:  prefetchIntelligent,
+	},
+	"capacity": {
+		qos:            `{"IOTYPE": 2, "MAXBANDWIDTH": 100}`,
+		prefetchPolicy: prefetchIntelligent,
+	},
+	"archive": {
+		qos:            `{"IOTYPE": 2, "MAXBANDWIDTH": 100}`,
+		prefetchPolicy: prefetchIntelligent,
+	},
+}
+
+// checkWorkloadHint checks the workload hint of the storage class is known
+func checkWorkloadHint(parameters map[string]interface{}) error {
+	hint, _ := parameters[workloadHintKey].(string)
+	if hint == "" {
+		return nil
+	}
+
+	if _, exist := workloadProfiles[hint]; !exist {
+		return fmt.Errorf("unknown %s %s, it must be latency-sensitive, throughput, capacity or archive",
+			workloadHintKey, hint)
+	}
+	return nil
+}
+
+// applyWorkloadProfile applies the profile of the workload hint to the parameters the storage class
+// doesn't set, as far as the pool selected supports them
+func applyWorkloadProfile(ctx context.Context, parameters map[string]interface{}, pool *backend.StoragePool) {
+	hint, _ := parameters[workloadHintKey].(string)
+	profile, exist := workloadProfiles[hint]
+	if !exist {
+		return
+	}
+
+	if appType, _ := parameters["applicationType"].(string); appType == "" && profile.applicationType != "" {
+		if supported, _ := pool.Capabilities["SupportApplicationType"].(bool); supported {
+			parameters["hintApplicationType"] = profile.applicationType
+		}
+	}
+
+	qos, _ := parameters["qos"].(string)
+	qosPerGiB, _ := parameters[qosPerGiBKey].(string)
+	if qos == "" && qosPerGiB == "" && profile.qos != "" {
+		supported, _ := pool.Capabilities["SupportQoS"].(bool)
+		if supported && pool.Plugin.SupportQoSParameters(ctx, profile.qos) == nil {
+			parameters["qos"] = profile.qos
+		} else {
+			log.AddContext(ctx).Infof("Pool %s of backend %s doesn't support the qos %s of workload hint %s",
+				pool.Name, pool.Parent, profile.qos, hint)
+		}
+	}
+
+	if _, exist := parameters["prefetchPolicy"]; !exist {
+		parameters["prefetchPolicy"] = profile.prefetchPolicy
+	}
+	log.AddContext(ctx).Infof("Workload hint %s is applied to the volume in pool %s of backend %s",
+		hint, pool.Name, pool.Parent)
+}
@@ -0,0 +1,68 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+)
+
+type fakeQoSPlugin struct {
+	plugin.Plugin
+	err error
+}
+
+func (p *fakeQoSPlugin) SupportQoSParameters(context.Context, string) error {
+	return p.err
+}
+
+func TestCheckWorkloadHint(t *testing.T) {
+	assert.NoError(t, checkWorkloadHint(map[string]interface{}{}))
+	assert.NoError(t, checkWorkloadHint(map[string]interface{}{workloadHintKey: "throughput"}))
+	assert.Error(t, checkWorkloadHint(map[string]interface{}{workloadHintKey: "fast"}))
+}
+
+func TestApplyWorkloadProfile(t *testing.T) {
+	pool := &backend.StoragePool{
+		Capabilities: map[string]interface{}{"SupportApplicationType": true, "SupportQoS": true},
+		Plugin:       &fakeQoSPlugin{},
+	}
+	parameters := map[string]interface{}{workloadHintKey: "latency-sensitive"}
+	applyWorkloadProfile(context.Background(), parameters, pool)
+	assert.Equal(t, "Oracle_OLTP", parameters["hintApplicationType"])
+	assert.Equal(t, workloadProfiles["latency-sensitive"].qos, parameters["qos"])
+	assert.Equal(t, prefetchNone, parameters["prefetchPolicy"])
+
+	// The parameters of the storage class take precedence
+	parameters = map[string]interface{}{workloadHintKey: "latency-sensitive", "applicationType": "SAP_HANA",
+		"qos": `{"MAXIOPS": 1000}`}
+	applyWorkloadProfile(context.Background(), parameters, pool)
+	assert.Nil(t, parameters["hintApplicationType"])
+	assert.Equal(t, `{"MAXIOPS": 1000}`, parameters["qos"])
+
+	// The QoS not supported by the pool is skipped
+	pool.Plugin = &fakeQoSPlugin{err: errors.New("LATENCY is a invalid key")}
+	parameters = map[string]interface{}{workloadHintKey: "latency-sensitive"}
+	applyWorkloadProfile(context.Background(), parameters, pool)
+	assert.Nil(t, parameters["qos"])
+}
@@ -0,0 +1,282 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"huawei-csi-driver/connector"
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/driver"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	driverConfigVersion       = "v1"
+	driverConfigCheckInterval = 10 * time.Second
+)
+
+// DriverConfig is the versioned runtime configuration of the driver. It is initialized from the flags and
+// overridden by the fields present in the driver config file, which is watched and applied without restart.
+type DriverConfig struct {
+	Version            string            `json:"version"`
+	LogLevel           string            `json:"logLevel"`
+	RPC                RPCConfig         `json:"rpc"`
+	Connector          ConnectorConfig   `json:"connector"`
+	BackendInitTimeout int               `json:"backendInitTimeout"`
+	ForceDetach        ForceDetachConfig `json:"forceDetach"`
+}
+
+// RPCConfig is the concurrency limit and the timeouts in seconds of the CSI RPCs
+type RPCConfig struct {
+	MaxConcurrent  int            `json:"maxConcurrent"`
+	DefaultTimeout int            `json:"defaultTimeout"`
+	Timeouts       map[string]int `json:"timeouts"`
+}
+
+// ConnectorConfig is the settings of attaching volumes on the node
+type ConnectorConfig struct {
+	ScanVolumeTimeout      int      `json:"scanVolumeTimeout"`
+	FCRequirePathPerFabric bool     `json:"fcRequirePathPerFabric"`
+	UltraPathDetection     bool     `json:"ultraPathDetection"`
+	UltraPathNVMeMinPaths  int      `json:"ultraPathNVMeMinPaths"`
+	DisabledProtocols      []string `json:"disabledProtocols"`
+	MountOptionAllowlist   []string `json:"mountOptionAllowlist"`
+}
+
+// ForceDetachConfig is the settings of detaching volumes from the nodes which are not ready
+type ForceDetachConfig struct {
+	Enabled bool `json:"enabled"`
+	Delay   int  `json:"delay"`
+}
+
+func newDriverConfigFromFlags() (*DriverConfig, error) {
+	rpcMethodTimeouts, err := parseRPCTimeouts(*rpcTimeouts)
+	if err != nil {
+		return nil, err
+	}
+
+	timeouts := make(map[string]int)
+	for method, timeout := range rpcMethodTimeouts {
+		timeouts[method] = int(timeout / time.Second)
+	}
+
+	var protocols []string
+	if *disabledProtocols != "" {
+		protocols = strings.Split(strings.ReplaceAll(*disabledProtocols, " ", ""), ",")
+	}
+
+	var mountOptions []string
+	if *mountOptionAllowlist != "" {
+		mountOptions = strings.Split(strings.ReplaceAll(*mountOptionAllowlist, " ", ""), ",")
+	}
+
+	return &DriverConfig{
+		Version:  driverConfigVersion,
+		LogLevel: log.GetLevel(),
+		RPC: RPCConfig{
+			MaxConcurrent:  *maxConcurrentRPCs,
+			DefaultTimeout: *rpcDefaultTimeout,
+			Timeouts:       timeouts,
+		},
+		Connector: ConnectorConfig{
+			ScanVolumeTimeout:      *scanVolumeTimeout,
+			FCRequirePathPerFabric: *fcRequirePathPerFabric,
+			UltraPathDetection:     *ultraPathDetection,
+			UltraPathNVMeMinPaths:  *ultraPathNVMeMinPaths,
+			DisabledProtocols:      protocols,
+			MountOptionAllowlist:   mountOptions,
+		},
+		BackendInitTimeout: *backendInitTimeout,
+		ForceDetach: ForceDetachConfig{
+			Enabled: *enableForceDetach,
+			Delay:   *forceDetachDelay,
+		},
+	}, nil
+}
+
+// loadDriverConfig returns the config built from the flags and overridden by the config file data,
+// the data is ignored if it is empty
+func loadDriverConfig(data []byte) (*DriverConfig, error) {
+	config, err := newDriverConfigFromFlags()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bytes.TrimSpace(data)) != 0 {
+		// the version must be set explicitly by the file
+		config.Version = ""
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("unmarshal driver config error: %v", err)
+		}
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c *DriverConfig) validate() error {
+	if c.Version != driverConfigVersion {
+		return fmt.Errorf("unsupported driver config version %q, it must be %s", c.Version, driverConfigVersion)
+	}
+
+	if c.Connector.ScanVolumeTimeout < 1 || c.Connector.ScanVolumeTimeout > 600 {
+		return fmt.Errorf("the value of scanVolumeTimeout ranges from 1 to 600, %d", c.Connector.ScanVolumeTimeout)
+	}
+
+	if c.Connector.UltraPathNVMeMinPaths < 1 {
+		return fmt.Errorf("the value of ultraPathNVMeMinPaths must be positive, %d",
+			c.Connector.UltraPathNVMeMinPaths)
+	}
+
+	if err := connector.VerifyDisabledProtocols(c.Connector.DisabledProtocols); err != nil {
+		return fmt.Errorf("the value of disabledProtocols is invalid: %v", err)
+	}
+
+	if err := connector.VerifyMountOptionAllowlist(c.Connector.MountOptionAllowlist); err != nil {
+		return fmt.Errorf("the value of mountOptionAllowlist is invalid: %v", err)
+	}
+
+	if c.BackendInitTimeout < 1 {
+		return fmt.Errorf("the value of backendInitTimeout must be positive, %d", c.BackendInitTimeout)
+	}
+
+	if c.ForceDetach.Delay < 0 {
+		return fmt.Errorf("the value of forceDetach delay must not be negative, %d", c.ForceDetach.Delay)
+	}
+
+	if c.RPC.MaxConcurrent < 0 || c.RPC.DefaultTimeout < 0 {
+		return fmt.Errorf("invalid rpc settings, maxConcurrent: %d, defaultTimeout: %d",
+			c.RPC.MaxConcurrent, c.RPC.DefaultTimeout)
+	}
+
+	for method, timeout := range c.RPC.Timeouts {
+		if method == "" || timeout < 1 {
+			return fmt.Errorf("invalid timeout of rpc method %s, it must be a positive integer", method)
+		}
+	}
+
+	return nil
+}
+
+// apply makes the config take effect, it must have been validated
+func (c *DriverConfig) apply() error {
+	if err := log.SetLevel(c.LogLevel); err != nil {
+		return err
+	}
+
+	connector.SetSettings(connector.Settings{
+		ScanVolumeTimeout:      time.Second * time.Duration(c.Connector.ScanVolumeTimeout),
+		FCRequirePathPerFabric: c.Connector.FCRequirePathPerFabric,
+		UltraPathDetection:     c.Connector.UltraPathDetection,
+		UltraPathNVMeMinPaths:  c.Connector.UltraPathNVMeMinPaths,
+		DisabledProtocols:      c.Connector.DisabledProtocols,
+		MountOptionAllowlist:   c.Connector.MountOptionAllowlist,
+	})
+
+	backend.SetTimeouts(backend.Timeouts{
+		InitBackend:        time.Second * time.Duration(c.BackendInitTimeout),
+		UpdateCapabilities: time.Second * time.Duration(c.BackendInitTimeout),
+	})
+
+	driver.SetForceDetachSettings(driver.ForceDetachSettings{
+		Enabled: c.ForceDetach.Enabled,
+		Delay:   time.Second * time.Duration(c.ForceDetach.Delay),
+	})
+
+	methodTimeouts := make(map[string]time.Duration)
+	for method, timeout := range c.RPC.Timeouts {
+		methodTimeouts[method] = time.Second * time.Duration(timeout)
+	}
+	setRPCSettings(rpcSettings{
+		maxConcurrent:  c.RPC.MaxConcurrent,
+		defaultTimeout: time.Second * time.Duration(c.RPC.DefaultTimeout),
+		methodTimeouts: methodTimeouts,
+	})
+
+	return nil
+}
+
+func readDriverConfigFile(file string) ([]byte, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil && os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// initDriverConfig applies the driver config at startup, the flags are used alone if the file does not exist
+func initDriverConfig() []byte {
+	data, err := readDriverConfigFile(*driverConfigFile)
+	if err != nil {
+		raisePanic("Read driver config file %s error: %v", *driverConfigFile, err)
+	}
+
+	config, err := loadDriverConfig(data)
+	if err != nil {
+		raisePanic("Load driver config error: %v", err)
+	}
+
+	if err := config.apply(); err != nil {
+		raisePanic("Apply driver config error: %v", err)
+	}
+	return data
+}
+
+// watchDriverConfig applies the changes of the driver config file, an invalid config is logged and the
+// current one is kept
+func watchDriverConfig(data []byte) {
+	ctx := context.Background()
+	defer utils.RecoverPanic(ctx)
+
+	ticker := time.NewTicker(driverConfigCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		newData, err := readDriverConfigFile(*driverConfigFile)
+		if err != nil {
+			log.AddContext(ctx).Warningf("Read driver config file %s error: %v", *driverConfigFile, err)
+			continue
+		}
+
+		if bytes.Equal(newData, data) {
+			continue
+		}
+		data = newData
+
+		config, err := loadDriverConfig(newData)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Load driver config error, keep the current config: %v", err)
+			continue
+		}
+
+		if err := config.apply(); err != nil {
+			log.AddContext(ctx).Errorf("Apply driver config error: %v", err)
+			continue
+		}
+		log.AddContext(ctx).Infof("Driver config %s is reloaded", *driverConfigFile)
+	}
+}
@@ -0,0 +1,59 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/connector"
+)
+
+func TestApplyDriverConfigConcurrently(t *testing.T) {
+	defer connector.SetSettings(connector.GetSettings())
+
+	config := &DriverConfig{
+		Version:  driverConfigVersion,
+		LogLevel: "info",
+		Connector: ConnectorConfig{ScanVolumeTimeout: 5, UltraPathNVMeMinPaths: 2,
+			DisabledProtocols: []string{"fc"}},
+		BackendInitTimeout: 60,
+	}
+
+	// the settings are read by the RPCs while the config watcher applies a changed config
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_ = connector.GetSettings().ScanVolumeTimeout
+			_ = getRPCSettings().defaultTimeout
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, config.apply())
+	}
+	wg.Wait()
+
+	settings := connector.GetSettings()
+	assert.Equal(t, 5*time.Second, settings.ScanVolumeTimeout)
+	assert.Equal(t, 2, settings.UltraPathNVMeMinPaths)
+	assert.Equal(t, []string{"fc"}, settings.DisabledProtocols)
+}
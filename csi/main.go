@@ -87,6 +87,29 @@ var (
 	nvmeMultiPathType = flag.String("nvme-multipath-type",
 		connector.HWUltraPathNVMe,
 		"Multipath software for roce/fc-nvme block volumes")
+	fcRequirePathPerFabric = flag.Bool("fc-require-path-per-fabric",
+		false,
+		"Whether to require at least one path in each FC fabric of the node when attach multipath FC volume")
+	disabledProtocols = flag.String("disabled-protocols",
+		"",
+		"The comma separated protocols never used on the node, e.g. fc,fc-nvme, so they are not probed")
+	mountOptionAllowlist = flag.String("mount-option-allowlist",
+		"",
+		"The comma separated names of the mount options the volumes may be mounted with, e.g. "+
+			"nfsvers,hard,noatime, any option but the dangerous ones such as loop is allowed if it is empty")
+	ultraPathDetection = flag.Bool("ultrapath-detection",
+		true,
+		"Whether to check if the devices are managed by UltraPath, disable it on nodes without UltraPath")
+	multipathConfigCheck = flag.Bool("multipath-config-check",
+		true,
+		"Whether to check the DM-multipath config of the node against the recommended settings at startup")
+	multipathConfigRemediate = flag.Bool("multipath-config-remediate",
+		false,
+		"Whether to apply the recommended DM-multipath settings as "+connector.ManagedMultipathConfig+
+			" and reload multipathd if the config drifts")
+	ultraPathNVMeMinPaths = flag.Int("ultrapath-nvme-min-paths",
+		1,
+		"The number of normal paths the UltraPath-NVMe device must have before a roce/fc-nvme volume is attached")
 	kubeconfig = flag.String("kubeconfig",
 		"",
 		"absolute path to the kubeconfig file")
@@ -99,10 +122,98 @@ var (
 	deviceCleanupTimeout = flag.Int("deviceCleanupTimeout",
 		300,
 		"Timeout interval in seconds for stale device cleanup")
+	backendInitTimeout = flag.Int("backend-init-timeout",
+		120,
+		"The timeout seconds for logging in to and initializing a single backend at startup")
 	scanVolumeTimeout = flag.Int("scan-volume-timeout",
 		3,
 		"The timeout for waiting for multipath aggregation "+
 			"when DM-multipath is used on the host")
+	maxConcurrentRPCs = flag.Int("max-concurrent-rpcs",
+		0,
+		"The max number of CSI RPCs handled concurrently, exceeding requests are rejected "+
+			"with ResourceExhausted. 0 means unlimited")
+	rpcDefaultTimeout = flag.Int("rpc-default-timeout",
+		0,
+		"The default timeout seconds of a CSI RPC if no method timeout is set. 0 means no timeout")
+	rpcTimeouts = flag.String("rpc-timeouts",
+		"",
+		"The per-method timeouts of CSI RPCs in seconds, e.g. CreateVolume=300,NodeStageVolume=600")
+	maxRequestSize = flag.Int("max-request-size",
+		4*1024*1024,
+		"The max size in bytes of a CSI request message")
+	enableForceDetach = flag.Bool("enable-force-detach",
+		false,
+		"Whether to unmap volumes from nodes which are not ready or missing, so they can be attached elsewhere")
+	forceDetachDelay = flag.Int("force-detach-delay",
+		300,
+		"The seconds a node must have been not ready or missing before its volumes are force detached")
+	driftReconcileInterval = flag.Int("drift-reconcile-interval",
+		0,
+		"The interval seconds to reconcile the drift between PVs and volumes on storage. 0 means disabled")
+	capacityGranularity = flag.Int64("capacity-granularity",
+		0,
+		"The granularity in bytes which volume sizes are rounded up to, it must be a multiple of 512. "+
+			"0 means sizes are sent to storage as requested")
+	minVolumeSize = flag.Int64("min-volume-size",
+		0,
+		"The minimum size in bytes of a volume, smaller requests are raised to it")
+	driftReconcilePolicy = flag.String("drift-reconcile-policy",
+		driftPolicyReport,
+		"How to handle the drift found: report, or repair which also expands volumes smaller than their PVs")
+	protectionGroupSyncInterval = flag.Int("protection-group-sync-interval",
+		0,
+		"The interval seconds to sync the ProtectionGroup resources with storage. 0 means disabled")
+	volumeShrinkSyncInterval = flag.Int("volume-shrink-sync-interval",
+		0,
+		"The interval seconds to process the VolumeShrink resources. 0 means disabled")
+	volumeRestoreSyncInterval = flag.Int("volume-restore-sync-interval",
+		0,
+		"The interval seconds to move the VolumeRestore resources on. 0 means disabled")
+	volumeMigrationSyncInterval = flag.Int("volume-migration-sync-interval",
+		0,
+		"The interval seconds to move the VolumeMigration resources on. 0 means disabled")
+	volumeFailoverSyncInterval = flag.Int("volume-failover-sync-interval",
+		0,
+		"The interval seconds to process the VolumeFailover resources. 0 means disabled")
+	cloneJobSyncInterval = flag.Int("clone-job-sync-interval",
+		60,
+		"The interval seconds to check the copy of the volumes created from a source, which is tracked by "+
+			"CloneJob resources instead of waited for in CreateVolume. 0 means disabled")
+	volumeQoSSyncInterval = flag.Int("volume-qos-sync-interval",
+		0,
+		"The interval seconds to apply the "+volumeQoSAnnotation+" annotations of PVCs to their volumes. "+
+			"0 means disabled")
+	capacityAlarmSyncInterval = flag.Int("capacity-alarm-sync-interval",
+		0,
+		"The interval seconds to check the capacity alarms of the volumes and their pools on storage, "+
+			"which are recorded as events of the PVCs. 0 means disabled")
+	shareAccessSyncInterval = flag.Int("share-access-sync-interval",
+		0,
+		"The interval seconds to apply the "+driver.AuthClientAnnotation+" annotations of PVCs to the NFS "+
+			"clients of their filesystems. 0 means disabled")
+	recycleBinPurgeInterval = flag.Int("recycle-bin-purge-interval",
+		0,
+		"The interval seconds to purge the deleted volumes kept in the recycle bins of the backends longer "+
+			"than their recycleBinRetention. 0 means disabled")
+	fstrimInterval = flag.Int("fstrim-interval",
+		86400,
+		"The interval seconds to trim the filesystems of the volumes whose spaceReclamation is periodic on the "+
+			"node. 0 means disabled")
+	staleDeviceCleanupInterval = flag.Int("stale-device-cleanup-interval",
+		0,
+		"The interval seconds to remove the devices of the LUNs no longer mapped to the node. 0 means disabled")
+	metricsAddress = flag.String("metrics-address",
+		"",
+		"The address such as :9090 to serve the volume capacity metrics at /metrics. Empty means disabled")
+	volumeMetricsInterval = flag.Int("volume-metrics-interval",
+		300,
+		"The interval seconds to collect the volume capacity metrics from storage")
+
+	driverConfigFile = flag.String("driver-config-file",
+		"/etc/huawei/driver.json",
+		"The versioned driver config file which overrides the runtime settings flags and is reloaded "+
+			"on change. The flags are used alone if it does not exist")
 
 	config CSIConfig
 	secret CSISecret
@@ -151,11 +262,68 @@ func parseConfig() {
 		log.Warningln("Node name is empty. Topology aware volume provisioning feature may not behave normal")
 	}
 
-	if *scanVolumeTimeout < 1 || *scanVolumeTimeout > 600 {
-		raisePanic("The value of scanVolumeTimeout ranges from 1 to 600,%d", *scanVolumeTimeout)
+	if *capacityGranularity < 0 || *capacityGranularity%512 != 0 || *minVolumeSize < 0 {
+		raisePanic("Invalid capacity settings, capacityGranularity: %d, minVolumeSize: %d",
+			*capacityGranularity, *minVolumeSize)
+	}
+
+	driver.CapacityGranularity = *capacityGranularity
+	driver.MinVolumeSize = *minVolumeSize
+	driver.CloneJobEnabled = *cloneJobSyncInterval > 0
+
+	if *driftReconcileInterval < 0 ||
+		(*driftReconcilePolicy != driftPolicyReport && *driftReconcilePolicy != driftPolicyRepair) {
+		raisePanic("Invalid drift reconcile settings, interval: %d, policy: %s",
+			*driftReconcileInterval, *driftReconcilePolicy)
+	}
+
+	if *protectionGroupSyncInterval < 0 {
+		raisePanic("Invalid protection group sync interval: %d", *protectionGroupSyncInterval)
 	}
 
-	connector.ScanVolumeTimeout = time.Second * time.Duration(*scanVolumeTimeout)
+	if *volumeShrinkSyncInterval < 0 {
+		raisePanic("Invalid volume shrink sync interval: %d", *volumeShrinkSyncInterval)
+	}
+
+	if *volumeRestoreSyncInterval < 0 {
+		raisePanic("Invalid volume restore sync interval: %d", *volumeRestoreSyncInterval)
+	}
+
+	if *volumeMigrationSyncInterval < 0 {
+		raisePanic("Invalid volume migration sync interval: %d", *volumeMigrationSyncInterval)
+	}
+
+	if *volumeFailoverSyncInterval < 0 {
+		raisePanic("Invalid volume failover sync interval: %d", *volumeFailoverSyncInterval)
+	}
+
+	if *cloneJobSyncInterval < 0 {
+		raisePanic("Invalid clone job sync interval: %d", *cloneJobSyncInterval)
+	}
+
+	if *volumeQoSSyncInterval < 0 {
+		raisePanic("Invalid volume qos sync interval: %d", *volumeQoSSyncInterval)
+	}
+
+	if *capacityAlarmSyncInterval < 0 {
+		raisePanic("Invalid capacity alarm sync interval: %d", *capacityAlarmSyncInterval)
+	}
+
+	if *shareAccessSyncInterval < 0 {
+		raisePanic("Invalid share access sync interval: %d", *shareAccessSyncInterval)
+	}
+
+	if *recycleBinPurgeInterval < 0 {
+		raisePanic("Invalid recycle bin purge interval: %d", *recycleBinPurgeInterval)
+	}
+
+	if *volumeMetricsInterval < 1 {
+		raisePanic("Invalid volume metrics interval: %d", *volumeMetricsInterval)
+	}
+
+	if *maxRequestSize < 1 {
+		raisePanic("Invalid max request size: %d", *maxRequestSize)
+	}
 }
 
 func getSecret(backendSecret, backendConfig map[string]interface{}, secretKey string) {
@@ -180,6 +348,13 @@ func mergeData(config CSIConfig, secret CSISecret) error {
 		backendSecret := Secret.(map[string]interface{})
 		getSecret(backendSecret, backendConfig, "user")
 		getSecret(backendSecret, backendConfig, "password")
+
+		// The CHAP credentials of the iSCSI initiators are optional
+		for _, key := range []string{"chapUser", "chapPassword", "chapMutualUser", "chapMutualPassword"} {
+			if value, exist := backendSecret[key].(string); exist {
+				backendConfig[key] = value
+			}
+		}
 	}
 	return nil
 }
@@ -192,10 +367,49 @@ func updateBackendCapabilities() {
 
 	ticker := time.NewTicker(time.Second * time.Duration(*backendUpdateInterval))
 	for range ticker.C {
+		backend.RegisterPendingBackends()
 		backend.AsyncUpdateCapabilities(*controllerFlagFile)
 	}
 }
 
+// runPeriodically runs the function at the interval, a panic of the function is recovered so that it runs
+// again at the next tick. The function is skipped while the controller is a standby one, whose controller
+// flag file does not exist.
+func runPeriodically(interval time.Duration, fn func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !isActiveController() {
+			continue
+		}
+
+		func() {
+			ctx := context.Background()
+			defer utils.RecoverPanic(ctx)
+			fn(ctx)
+		}()
+	}
+}
+
+// isActiveController returns whether the controller is the active one, whose controller flag file exists.
+// It is always true on the nodes and on the controllers not given a flag file.
+func isActiveController() bool {
+	if *controllerFlagFile == "" {
+		return true
+	}
+	_, err := os.Stat(*controllerFlagFile)
+	return err == nil
+}
+
+// registerPendingBackendsPeriodically registers the backends failed to be registered at startup on the node
+func registerPendingBackendsPeriodically() {
+	ticker := time.NewTicker(time.Second * time.Duration(*backendUpdateInterval))
+	for range ticker.C {
+		backend.RegisterPendingBackends()
+	}
+}
+
 func getLogFileName() string {
 	// check log file name
 	logFileName := nodeLogFile
@@ -241,6 +455,7 @@ func main() {
 	go exitClean(controllerService)
 	// parse configurations
 	parseConfig()
+	go watchDriverConfig(initDriverConfig())
 	if !controllerService {
 		doNodeAction()
 		// init version file on node
@@ -257,6 +472,8 @@ func main() {
 
 	if controllerService {
 		go updateBackendCapabilities()
+	} else {
+		go registerPendingBackendsPeriodically()
 	}
 
 	k8sUtils, err := k8sutils.NewK8SUtils(*kubeconfig)
@@ -268,6 +485,68 @@ func main() {
 		triggerGarbageCollector(k8sUtils)
 	}
 
+	if !controllerService && *multipathConfigCheck && *volumeUseMultiPath &&
+		*scsiMultiPathType == connector.DMMultiPath {
+		go checkMultipathConfig(k8sUtils)
+	}
+
+	if !controllerService && *fstrimInterval > 0 {
+		go trimNodeVolumesPeriodically(k8sUtils)
+	}
+
+	if !controllerService && *staleDeviceCleanupInterval > 0 {
+		go reconcileStaleDevicesPeriodically(k8sUtils)
+	}
+
+	if controllerService && *driftReconcileInterval > 0 {
+		go reconcileDrift(k8sUtils)
+	}
+
+	if controllerService && *protectionGroupSyncInterval > 0 {
+		go reconcileProtectionGroupsPeriodically(k8sUtils)
+	}
+
+	if controllerService && *volumeShrinkSyncInterval > 0 {
+		go reconcileVolumeShrinksPeriodically(k8sUtils)
+	}
+
+	if controllerService && *volumeRestoreSyncInterval > 0 {
+		go reconcileVolumeRestoresPeriodically(k8sUtils)
+	}
+
+	if controllerService && *volumeMigrationSyncInterval > 0 {
+		go reconcileVolumeMigrationsPeriodically(k8sUtils)
+	}
+
+	if controllerService && *volumeFailoverSyncInterval > 0 {
+		go reconcileVolumeFailoversPeriodically(k8sUtils)
+	}
+
+	if controllerService && *cloneJobSyncInterval > 0 {
+		go reconcileCloneJobsPeriodically(k8sUtils)
+	}
+
+	if controllerService && *volumeQoSSyncInterval > 0 {
+		go reconcileVolumeQoSPeriodically(k8sUtils)
+	}
+
+	if controllerService && *capacityAlarmSyncInterval > 0 {
+		go reconcileCapacityAlarmsPeriodically(k8sUtils)
+	}
+
+	if controllerService && *shareAccessSyncInterval > 0 {
+		go reconcileShareAccessPeriodically(k8sUtils)
+	}
+
+	if controllerService && *recycleBinPurgeInterval > 0 {
+		go purgeRecycleBinsPeriodically()
+	}
+
+	if controllerService && *metricsAddress != "" {
+		go collectVolumeMetricsPeriodically(k8sUtils)
+		go serveVolumeMetrics(*metricsAddress)
+	}
+
 	d := driver.NewDriver(*driverName, csiVersion, *volumeUseMultiPath, *scsiMultiPathType,
 		*nvmeMultiPathType, k8sUtils, *nodeName)
 
@@ -301,10 +580,7 @@ func listenEndpoint(endpoint string) net.Listener {
 }
 
 func registerServer(listener net.Listener, d *driver.Driver) {
-	opts := []grpc.ServerOption{
-		grpc.UnaryInterceptor(log.EnsureGRPCContext),
-	}
-	server := grpc.NewServer(opts...)
+	server := grpc.NewServer(getServerOptions()...)
 
 	csi.RegisterIdentityServer(server, d)
 	csi.RegisterControllerServer(server, d)
@@ -333,6 +609,7 @@ func checkMultiPathService() {
 		"SCSIMultipathType":  *scsiMultiPathType,
 		"NVMeMultipathType":  *nvmeMultiPathType,
 		"volumeUseMultiPath": *volumeUseMultiPath,
+		"disabledProtocols":  connector.GetSettings().DisabledProtocols,
 	}
 
 	requiredServices, err := utils.GetRequiredMultipath(context.Background(),
@@ -357,6 +634,10 @@ func doNodeAction() {
 
 	checkMultiPathType()
 	checkMultiPathService()
+
+	ctx := context.Background()
+	connector.Preflight(ctx, utils.GetBackendProtocols(ctx, config.Backends),
+		*volumeUseMultiPath, *scsiMultiPathType, *nvmeMultiPathType)
 }
 
 func triggerGarbageCollector(k8sUtils k8sutils.Interface) {
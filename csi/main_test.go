@@ -0,0 +1,201 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	logName = "csiTest.log"
+	logDir  = "/var/log/huawei"
+)
+
+// fakeK8sUtils overrides the kubernetes utilities used by a test, calling any other method panics
+type fakeK8sUtils struct {
+	k8sutils.Interface
+	volumeHandles    map[string]string
+	protectionGroups []*k8sutils.ProtectionGroup
+	replicas         map[string]int32
+	attributes       map[string]string
+	capacities       map[string]int64
+	annotations      map[string]string
+	attached         map[string]bool
+	snapshotHandles  map[string]string
+	claims           map[string]*corev1.PersistentVolumeClaim
+	events           []string
+	deletedJobs      []string
+
+	migrations []k8sutils.VolumeMigration
+	shrinks    []k8sutils.VolumeShrink
+	failovers  []k8sutils.VolumeFailover
+	restores   []k8sutils.VolumeRestore
+	// updatedMigrations, updatedShrinks, updatedFailovers, updatedRestores and updatedJobs are the
+	// statuses updated in order
+	updatedMigrations []k8sutils.VolumeMigration
+	updatedShrinks    []k8sutils.VolumeShrink
+	updatedFailovers  []k8sutils.VolumeFailover
+	updatedRestores   []k8sutils.VolumeRestore
+	updatedJobs       []k8sutils.CloneJob
+}
+
+func (k *fakeK8sUtils) GetClaimVolumeHandle(_ context.Context, _, namespace, claimName string) (string, error) {
+	return k.volumeHandles[namespace+"/"+claimName], nil
+}
+
+func (k *fakeK8sUtils) UpdateProtectionGroup(_ context.Context, group *k8sutils.ProtectionGroup, _ bool) error {
+	copied := *group
+	k.protectionGroups = append(k.protectionGroups, &copied)
+	return nil
+}
+
+func (k *fakeK8sUtils) IsWorkloadUsingClaim(_ context.Context, _ string, workload k8sutils.WorkloadReference,
+	_ string) (bool, error) {
+	_, exist := k.replicas[workload.Name]
+	return exist, nil
+}
+
+func (k *fakeK8sUtils) GetWorkloadReplicas(_ context.Context, _ string, workload k8sutils.WorkloadReference) (
+	int32, error) {
+	return k.replicas[workload.Name], nil
+}
+
+func (k *fakeK8sUtils) ScaleWorkload(_ context.Context, _ string, workload k8sutils.WorkloadReference,
+	replicas int32) (int32, error) {
+	previous := k.replicas[workload.Name]
+	k.replicas[workload.Name] = replicas
+	return previous, nil
+}
+
+func (k *fakeK8sUtils) GetClaimVolumeAttributes(_ context.Context, _, _ string) (map[string]string, error) {
+	return k.attributes, nil
+}
+
+func (k *fakeK8sUtils) UpdateClaimVolumeCapacity(_ context.Context, namespace, claimName string,
+	capacity int64) error {
+	k.capacities[namespace+"/"+claimName] = capacity
+	return nil
+}
+
+func (k *fakeK8sUtils) UpdateClaimVolumeAnnotation(_ context.Context, namespace, claimName, key,
+	value string) error {
+	k.annotations[namespace+"/"+claimName+"/"+key] = value
+	return nil
+}
+
+func (k *fakeK8sUtils) IsClaimVolumeAttached(_ context.Context, namespace, claimName string) (bool, error) {
+	return k.attached[namespace+"/"+claimName], nil
+}
+
+func (k *fakeK8sUtils) GetSnapshotHandle(_ context.Context, namespace, snapshotName string) (string, error) {
+	return k.snapshotHandles[namespace+"/"+snapshotName], nil
+}
+
+func (k *fakeK8sUtils) GetClaim(_ context.Context, namespace, claimName string) (*corev1.PersistentVolumeClaim,
+	error) {
+	return k.claims[namespace+"/"+claimName], nil
+}
+
+func (k *fakeK8sUtils) RecordClaimEvent(_ context.Context, namespace, claimName, _, reason, _ string) error {
+	k.events = append(k.events, namespace+"/"+claimName+" "+reason)
+	return nil
+}
+
+func (k *fakeK8sUtils) ListVolumeMigrations(_ context.Context) ([]k8sutils.VolumeMigration, error) {
+	return k.migrations, nil
+}
+
+func (k *fakeK8sUtils) UpdateVolumeMigrationStatus(_ context.Context, migration *k8sutils.VolumeMigration) error {
+	k.updatedMigrations = append(k.updatedMigrations, *migration)
+	return nil
+}
+
+func (k *fakeK8sUtils) ListVolumeShrinks(_ context.Context) ([]k8sutils.VolumeShrink, error) {
+	return k.shrinks, nil
+}
+
+func (k *fakeK8sUtils) UpdateVolumeShrinkStatus(_ context.Context, shrink *k8sutils.VolumeShrink) error {
+	k.updatedShrinks = append(k.updatedShrinks, *shrink)
+	return nil
+}
+
+func (k *fakeK8sUtils) ListVolumeFailovers(_ context.Context) ([]k8sutils.VolumeFailover, error) {
+	return k.failovers, nil
+}
+
+func (k *fakeK8sUtils) UpdateVolumeFailoverStatus(_ context.Context, failover *k8sutils.VolumeFailover) error {
+	k.updatedFailovers = append(k.updatedFailovers, *failover)
+	return nil
+}
+
+func (k *fakeK8sUtils) ListVolumeRestores(_ context.Context) ([]k8sutils.VolumeRestore, error) {
+	return k.restores, nil
+}
+
+func (k *fakeK8sUtils) UpdateVolumeRestoreStatus(_ context.Context, restore *k8sutils.VolumeRestore) error {
+	k.updatedRestores = append(k.updatedRestores, *restore)
+	return nil
+}
+
+func (k *fakeK8sUtils) UpdateCloneJobStatus(_ context.Context, job *k8sutils.CloneJob) error {
+	k.updatedJobs = append(k.updatedJobs, *job)
+	return nil
+}
+
+func (k *fakeK8sUtils) DeleteCloneJob(_ context.Context, volumeHandle string) error {
+	k.deletedJobs = append(k.deletedJobs, volumeHandle)
+	return nil
+}
+
+func TestIsActiveController(t *testing.T) {
+	flagFile := path.Join(t.TempDir(), "controller-flag")
+	stubs := gostub.Stub(&controllerFlagFile, new(string))
+	defer stubs.Reset()
+	assert.True(t, isActiveController())
+
+	*controllerFlagFile = flagFile
+	assert.False(t, isActiveController())
+
+	assert.NoError(t, os.WriteFile(flagFile, nil, 0600))
+	assert.True(t, isActiveController())
+}
+
+func TestMain(m *testing.M) {
+	if err := log.InitLogging(logName); err != nil {
+		log.Errorf("Init logging: %s failed. error: %v", logName, err)
+		os.Exit(1)
+	}
+
+	logFile := path.Join(logDir, logName)
+	defer func() {
+		if err := os.RemoveAll(logFile); err != nil {
+			log.Errorf("Remove file: %s failed. error: %s", logFile, err)
+		}
+	}()
+
+	m.Run()
+}
@@ -0,0 +1,73 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"huawei-csi-driver/connector"
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	multipathConfigDriftReason      = "MultipathConfigDrift"
+	multipathConfigRemediatedReason = "MultipathConfigRemediated"
+)
+
+// checkMultipathConfig verifies the DM-multipath config of the node at startup, the drift is recorded
+// as an event of the node, and remediated by the managed config snippet if it is enabled
+func checkMultipathConfig(k8sUtils k8sutils.Interface) {
+	ctx := context.Background()
+	drifts, err := connector.CheckMultipathConfig(ctx)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Check multipath config error: %v", err)
+		return
+	}
+
+	if len(drifts) == 0 {
+		log.AddContext(ctx).Infoln("Multipath config is as recommended")
+		return
+	}
+
+	message := "Multipath config drifts from the recommended settings: " + strings.Join(drifts, "; ")
+	log.AddContext(ctx).Warningln(message)
+	recordNodeEvent(ctx, k8sUtils, corev1.EventTypeWarning, multipathConfigDriftReason, message)
+
+	if !*multipathConfigRemediate {
+		return
+	}
+
+	err = connector.ApplyMultipathConfig(ctx)
+	if err != nil {
+		recordNodeEvent(ctx, k8sUtils, corev1.EventTypeWarning, multipathConfigDriftReason, err.Error())
+		return
+	}
+
+	recordNodeEvent(ctx, k8sUtils, corev1.EventTypeNormal, multipathConfigRemediatedReason,
+		"Applied the recommended settings in "+connector.ManagedMultipathConfig+" and reloaded multipathd")
+}
+
+func recordNodeEvent(ctx context.Context, k8sUtils k8sutils.Interface, eventType, reason, message string) {
+	err := k8sUtils.RecordNodeEvent(ctx, *nodeName, eventType, reason, message)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Record event %s of node %s error: %v", reason, *nodeName, err)
+	}
+}
@@ -0,0 +1,330 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+// protectionGroupFinalizer keeps a protection group until its group on storage is deleted
+const protectionGroupFinalizer = "csi.huawei.com/protection-group"
+
+// getStorageName returns a name on storage for the object of the protection group, which is
+// unique and short enough for the name limit of storage
+func getStorageName(prefix string, group *k8sutils.ProtectionGroup, object string) string {
+	sum := sha256.Sum256([]byte(string(group.UID) + "/" + object))
+	return prefix + hex.EncodeToString(sum[:])[:16]
+}
+
+// reconcileProtectionGroups reconciles the protection groups with storage, the error of each group
+// is reported in its status
+func reconcileProtectionGroups(ctx context.Context, k8sUtils k8sutils.Interface, driverName string) error {
+	groups, err := k8sUtils.ListProtectionGroups(ctx)
+	if err != nil {
+		log.AddContext(ctx).Errorf("List protection groups error: %v", err)
+		return err
+	}
+
+	for i := range groups {
+		reconcileProtectionGroup(ctx, k8sUtils, driverName, &groups[i])
+	}
+	return nil
+}
+
+func reconcileProtectionGroup(ctx context.Context, k8sUtils k8sutils.Interface, driverName string,
+	group *k8sutils.ProtectionGroup) {
+	if group.DeletionTimestamp != nil {
+		deleteProtectionGroup(ctx, k8sUtils, group)
+		return
+	}
+
+	if !utils.IsContain(protectionGroupFinalizer, group.Finalizers) {
+		group.Finalizers = append(group.Finalizers, protectionGroupFinalizer)
+		err := k8sUtils.UpdateProtectionGroup(ctx, group, false)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Add finalizer to protection group %s/%s error: %v",
+				group.Namespace, group.Name, err)
+			return
+		}
+	}
+
+	err := syncProtectionGroup(ctx, k8sUtils, driverName, group)
+	group.Status.Message = ""
+	if err != nil {
+		log.AddContext(ctx).Errorf("Sync protection group %s/%s error: %v", group.Namespace, group.Name, err)
+		group.Status.Message = err.Error()
+	}
+
+	err = k8sUtils.UpdateProtectionGroup(ctx, group, true)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Update status of protection group %s/%s error: %v",
+			group.Namespace, group.Name, err)
+	}
+}
+
+// getGroupMembers returns the backend and the volume names by PVC name of the members
+func getGroupMembers(ctx context.Context, k8sUtils k8sutils.Interface, driverName string,
+	group *k8sutils.ProtectionGroup) (string, map[string]string, error) {
+	var groupBackend string
+	members := make(map[string]string, len(group.Spec.PersistentVolumeClaims))
+	for _, claim := range group.Spec.PersistentVolumeClaims {
+		volumeHandle, err := k8sUtils.GetClaimVolumeHandle(ctx, driverName, group.Namespace, claim)
+		if err != nil {
+			return "", nil, err
+		}
+
+		backendName, volName := utils.SplitVolumeId(volumeHandle)
+		if groupBackend != "" && backendName != groupBackend {
+			return "", nil, fmt.Errorf("pvc %s is on backend %s, but the other members are on backend %s",
+				claim, backendName, groupBackend)
+		}
+		groupBackend = backendName
+		members[claim] = volName
+	}
+
+	return groupBackend, members, nil
+}
+
+func getVolumeNames(members map[string]string) []string {
+	volumes := make([]string, 0, len(members))
+	for _, volName := range members {
+		volumes = append(volumes, volName)
+	}
+	sort.Strings(volumes)
+	return volumes
+}
+
+// getGroupManager returns the plugin of the backend managing the protection groups
+var getGroupManager = func(backendName string) (plugin.ProtectionGroupManager, error) {
+	bk := backend.GetBackend(backendName)
+	if bk == nil {
+		return nil, fmt.Errorf("backend %s doesn't exist", backendName)
+	}
+
+	manager, ok := bk.Plugin.(plugin.ProtectionGroupManager)
+	if !ok {
+		return nil, fmt.Errorf("backend %s of storage %s doesn't support protection groups", backendName, bk.Storage)
+	}
+	return manager, nil
+}
+
+// syncProtectionGroup makes the group on storage hold the member volumes, replicates them as a unit
+// if required, and takes or deletes the group snapshots as the snapshot names change
+func syncProtectionGroup(ctx context.Context, k8sUtils k8sutils.Interface, driverName string,
+	group *k8sutils.ProtectionGroup) error {
+	backendName, members, err := getGroupMembers(ctx, k8sUtils, driverName, group)
+	if err != nil {
+		return err
+	}
+
+	status := &group.Status
+	if len(status.Members) > 0 && backendName != status.Backend {
+		return fmt.Errorf("members have to stay on backend %s, delete and recreate the group to move them",
+			status.Backend)
+	}
+	if backendName == "" {
+		return nil
+	}
+
+	manager, err := getGroupManager(backendName)
+	if err != nil {
+		return err
+	}
+	status.Backend = backendName
+	status.GroupName = getStorageName("k8s_pg_", group, "")
+
+	var removed []string
+	for claim, volName := range status.Members {
+		if _, exist := members[claim]; !exist {
+			removed = append(removed, volName)
+		}
+	}
+	if len(removed) > 0 {
+		err = manager.RemoveFromProtectionGroup(ctx, status.GroupName, removed)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = manager.EnsureProtectionGroup(ctx, status.GroupName, getVolumeNames(members))
+	if err != nil {
+		return err
+	}
+	status.Members = members
+
+	if status.Replicated && !group.Spec.Replication {
+		return fmt.Errorf("replication of a protection group cannot be turned off, delete and recreate the group")
+	}
+	if group.Spec.Replication {
+		err = manager.EnsureReplicationGroup(ctx, status.GroupName, getVolumeNames(members))
+		if err != nil {
+			return err
+		}
+		status.Replicated = true
+	}
+
+	return syncGroupSnapshots(ctx, manager, group)
+}
+
+func syncGroupSnapshots(ctx context.Context, manager plugin.ProtectionGroupManager,
+	group *k8sutils.ProtectionGroup) error {
+	err := deleteGroupSnapshots(ctx, manager, group, group.Spec.Snapshots)
+	if err != nil {
+		return err
+	}
+
+	status := &group.Status
+	taken := make(map[string]bool, len(status.Snapshots))
+	for _, snapshot := range status.Snapshots {
+		taken[snapshot.Name] = true
+	}
+
+	for _, name := range group.Spec.Snapshots {
+		if taken[name] {
+			continue
+		}
+
+		volumeSnapshots, err := manager.CreateGroupSnapshot(ctx, status.GroupName,
+			getStorageName("k8s_gs_", group, name), getVolumeNames(status.Members))
+		if err != nil {
+			return err
+		}
+
+		handles := make(map[string]string, len(status.Members))
+		for claim, volName := range status.Members {
+			if volumeSnapshot, exist := volumeSnapshots[volName]; exist {
+				handles[claim] = status.Backend + "." + volumeSnapshot
+			}
+		}
+		status.Snapshots = append(status.Snapshots, k8sutils.GroupSnapshotStatus{
+			Name:            name,
+			CreationTime:    metav1.Now(),
+			SnapshotHandles: handles,
+		})
+		taken[name] = true
+		log.AddContext(ctx).Infof("Snapshot %s of protection group %s/%s is taken", name, group.Namespace, group.Name)
+	}
+
+	return nil
+}
+
+// deleteGroupSnapshots deletes the group snapshots which are not to keep, the snapshots failed to delete
+// are kept in the status to retry
+func deleteGroupSnapshots(ctx context.Context, manager plugin.ProtectionGroupManager,
+	group *k8sutils.ProtectionGroup, keep []string) error {
+	status := &group.Status
+	var snapshots []k8sutils.GroupSnapshotStatus
+	for i, snapshot := range status.Snapshots {
+		if utils.IsContain(snapshot.Name, keep) {
+			snapshots = append(snapshots, snapshot)
+			continue
+		}
+
+		volumeSnapshots := make([]string, 0, len(snapshot.SnapshotHandles))
+		for _, handle := range snapshot.SnapshotHandles {
+			volumeSnapshots = append(volumeSnapshots, strings.TrimPrefix(handle, status.Backend+"."))
+		}
+		err := manager.DeleteGroupSnapshot(ctx, getStorageName("k8s_gs_", group, snapshot.Name), volumeSnapshots)
+		if err != nil {
+			status.Snapshots = append(snapshots, status.Snapshots[i:]...)
+			return err
+		}
+		log.AddContext(ctx).Infof("Snapshot %s of protection group %s/%s is deleted",
+			snapshot.Name, group.Namespace, group.Name)
+	}
+	status.Snapshots = snapshots
+
+	return nil
+}
+
+// deleteProtectionGroup deletes the group snapshots and the group on storage, then releases the
+// protection group. The snapshots deleted are removed from the status, so a failed deletion is
+// retried from where it stopped.
+func deleteProtectionGroup(ctx context.Context, k8sUtils k8sutils.Interface, group *k8sutils.ProtectionGroup) {
+	if !utils.IsContain(protectionGroupFinalizer, group.Finalizers) {
+		return
+	}
+
+	if group.Status.GroupName != "" {
+		manager, err := getGroupManager(group.Status.Backend)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Delete protection group %s/%s error: %v", group.Namespace, group.Name, err)
+			return
+		}
+
+		if len(group.Status.Snapshots) > 0 {
+			err = deleteGroupSnapshots(ctx, manager, group, nil)
+			group.Status.Message = ""
+			if err != nil {
+				group.Status.Message = err.Error()
+			}
+			updateErr := k8sUtils.UpdateProtectionGroup(ctx, group, true)
+			if updateErr != nil {
+				log.AddContext(ctx).Errorf("Update status of protection group %s/%s error: %v",
+					group.Namespace, group.Name, updateErr)
+				return
+			}
+			if err != nil {
+				log.AddContext(ctx).Errorf("Delete snapshots of protection group %s/%s error: %v",
+					group.Namespace, group.Name, err)
+				return
+			}
+		}
+
+		err = manager.DeleteProtectionGroup(ctx, group.Status.GroupName, getVolumeNames(group.Status.Members))
+		if err != nil {
+			log.AddContext(ctx).Errorf("Delete protection group %s/%s error: %v", group.Namespace, group.Name, err)
+			return
+		}
+	}
+
+	var finalizers []string
+	for _, finalizer := range group.Finalizers {
+		if finalizer != protectionGroupFinalizer {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+	group.Finalizers = finalizers
+	err := k8sUtils.UpdateProtectionGroup(ctx, group, false)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Remove finalizer of protection group %s/%s error: %v",
+			group.Namespace, group.Name, err)
+		return
+	}
+	log.AddContext(ctx).Infof("Protection group %s/%s is deleted", group.Namespace, group.Name)
+}
+
+// reconcileProtectionGroupsPeriodically reconciles the protection groups on the active controller
+func reconcileProtectionGroupsPeriodically(k8sUtils k8sutils.Interface) {
+	runPeriodically(time.Second*time.Duration(*protectionGroupSyncInterval), func(ctx context.Context) {
+		_ = reconcileProtectionGroups(ctx, k8sUtils, *driverName)
+	})
+}
@@ -0,0 +1,175 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils/k8sutils"
+)
+
+// fakeGroupManager records the protection group operations on storage
+type fakeGroupManager struct {
+	groups           map[string][]string
+	snapshots        map[string]bool
+	replicated       map[string]bool
+	deleteSnapshotOK bool
+	// deletedVolumeSnapshots are the snapshots of the volumes deleted with the group snapshots
+	deletedVolumeSnapshots []string
+}
+
+func newFakeGroupManager() *fakeGroupManager {
+	return &fakeGroupManager{groups: map[string][]string{}, snapshots: map[string]bool{},
+		replicated: map[string]bool{}, deleteSnapshotOK: true}
+}
+
+func (m *fakeGroupManager) EnsureProtectionGroup(_ context.Context, group string, volumes []string) error {
+	m.groups[group] = volumes
+	return nil
+}
+
+func (m *fakeGroupManager) RemoveFromProtectionGroup(_ context.Context, group string, volumes []string) error {
+	var kept []string
+	for _, volume := range m.groups[group] {
+		removed := false
+		for _, v := range volumes {
+			removed = removed || v == volume
+		}
+		if !removed {
+			kept = append(kept, volume)
+		}
+	}
+	m.groups[group] = kept
+	return nil
+}
+
+func (m *fakeGroupManager) DeleteProtectionGroup(_ context.Context, group string, _ []string) error {
+	delete(m.groups, group)
+	return nil
+}
+
+func (m *fakeGroupManager) CreateGroupSnapshot(_ context.Context, _, snapshot string,
+	volumes []string) (map[string]string, error) {
+	m.snapshots[snapshot] = true
+	snapshots := make(map[string]string, len(volumes))
+	for _, volume := range volumes {
+		snapshots[volume] = "1." + snapshot
+	}
+	return snapshots, nil
+}
+
+func (m *fakeGroupManager) DeleteGroupSnapshot(_ context.Context, snapshot string, volumeSnapshots []string) error {
+	if !m.deleteSnapshotOK {
+		return errors.New("snapshot is busy")
+	}
+	delete(m.snapshots, snapshot)
+	m.deletedVolumeSnapshots = append(m.deletedVolumeSnapshots, volumeSnapshots...)
+	return nil
+}
+
+func (m *fakeGroupManager) EnsureReplicationGroup(_ context.Context, group string, _ []string) error {
+	m.replicated[group] = true
+	return nil
+}
+
+func newProtectionGroup(claims []string, snapshots ...string) *k8sutils.ProtectionGroup {
+	return &k8sutils.ProtectionGroup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pg", UID: "uid-1"},
+		Spec:       k8sutils.ProtectionGroupSpec{PersistentVolumeClaims: claims, Snapshots: snapshots},
+	}
+}
+
+func stubGroupManager(manager plugin.ProtectionGroupManager) *gostub.Stubs {
+	return gostub.Stub(&getGroupManager, func(string) (plugin.ProtectionGroupManager, error) {
+		return manager, nil
+	})
+}
+
+func TestReconcileProtectionGroup(t *testing.T) {
+	ctx := context.Background()
+	manager := newFakeGroupManager()
+	defer stubGroupManager(manager).Reset()
+	k8sUtils := &fakeK8sUtils{volumeHandles: map[string]string{
+		"default/data": "san.pvc-1", "default/log": "san.pvc-2", "default/other": "nas.pvc-3"}}
+
+	tests := []struct {
+		name          string
+		claims        []string
+		snapshots     []string
+		expectMembers []string
+		expectSnaps   int
+		expectMessage bool
+	}{
+		{"Create", []string{"data", "log"}, []string{"daily"}, []string{"pvc-1", "pvc-2"}, 1, false},
+		{"RemoveMember", []string{"data"}, []string{"daily"}, []string{"pvc-1"}, 1, false},
+		{"RotateSnapshots", []string{"data"}, []string{"hourly"}, []string{"pvc-1"}, 1, false},
+		{"OtherBackend", []string{"data", "other"}, nil, []string{"pvc-1"}, 1, true},
+	}
+
+	group := newProtectionGroup(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group.Spec.PersistentVolumeClaims = tt.claims
+			group.Spec.Snapshots = tt.snapshots
+			reconcileProtectionGroup(ctx, k8sUtils, "csi.huawei.com", group)
+
+			assert.Contains(t, group.Finalizers, protectionGroupFinalizer)
+			assert.Equal(t, tt.expectMembers, manager.groups[group.Status.GroupName])
+			assert.Len(t, group.Status.Snapshots, tt.expectSnaps)
+			assert.Len(t, manager.snapshots, tt.expectSnaps)
+			assert.Equal(t, tt.expectMessage, group.Status.Message != "")
+		})
+	}
+}
+
+func TestDeleteProtectionGroup(t *testing.T) {
+	ctx := context.Background()
+	manager := newFakeGroupManager()
+	defer stubGroupManager(manager).Reset()
+	k8sUtils := &fakeK8sUtils{volumeHandles: map[string]string{"default/data": "san.pvc-1"}}
+
+	group := newProtectionGroup([]string{"data"}, "daily", "weekly")
+	reconcileProtectionGroup(ctx, k8sUtils, "csi.huawei.com", group)
+	assert.Len(t, manager.snapshots, 2)
+
+	now := metav1.Now()
+	group.DeletionTimestamp = &now
+	manager.deleteSnapshotOK = false
+	reconcileProtectionGroup(ctx, k8sUtils, "csi.huawei.com", group)
+	assert.Contains(t, group.Finalizers, protectionGroupFinalizer)
+	assert.Len(t, group.Status.Snapshots, 2)
+	assert.NotEmpty(t, group.Status.Message)
+
+	manager.deleteSnapshotOK = true
+	reconcileProtectionGroup(ctx, k8sUtils, "csi.huawei.com", group)
+	assert.NotContains(t, group.Finalizers, protectionGroupFinalizer)
+	assert.Empty(t, group.Status.Snapshots)
+	assert.Empty(t, manager.snapshots)
+	assert.Empty(t, manager.groups)
+	// the snapshots of the members are told by their handles without the backend
+	assert.Len(t, manager.deletedVolumeSnapshots, 2)
+	for _, volumeSnapshot := range manager.deletedVolumeSnapshots {
+		assert.Regexp(t, `^1\.k8s_gs_`, volumeSnapshot)
+	}
+}
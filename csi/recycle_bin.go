@@ -0,0 +1,54 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils/log"
+)
+
+// purgeRecycleBins destroys the deleted volumes kept in the recycle bins of the backends longer than
+// their retention. A backend failed to purge is retried at the next interval.
+func purgeRecycleBins(ctx context.Context) {
+	for _, backendName := range backend.GetBackendNames() {
+		bk := backend.GetBackend(backendName)
+		if bk == nil || !bk.Available {
+			continue
+		}
+
+		recycler, ok := bk.Plugin.(plugin.VolumeRecycler)
+		if !ok {
+			continue
+		}
+
+		err := recycler.PurgeRecycledVolumes(ctx)
+		if err != nil {
+			log.AddContext(ctx).Warningf("Purge recycle bin of backend %s error: %v", backendName, err)
+		}
+	}
+}
+
+// purgeRecycleBinsPeriodically purges the recycle bins of the backends on the active controller
+func purgeRecycleBinsPeriodically() {
+	runPeriodically(time.Second*time.Duration(*recycleBinPurgeInterval), func(ctx context.Context) {
+		purgeRecycleBins(ctx)
+	})
+}
@@ -0,0 +1,147 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"huawei-csi-driver/utils/log"
+)
+
+// parseRPCTimeouts parses the per-method timeouts like "CreateVolume=300,NodeStageVolume=600",
+// the method is the short name of a CSI RPC and the value is in seconds
+func parseRPCTimeouts(config string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	if strings.TrimSpace(config) == "" {
+		return timeouts, nil
+	}
+
+	for _, item := range strings.Split(config, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid rpc timeout %s, the format must be <method>=<seconds>", item)
+		}
+
+		seconds, err := strconv.Atoi(kv[1])
+		if err != nil || seconds < 1 {
+			return nil, fmt.Errorf("invalid timeout of rpc method %s, it must be a positive integer", kv[0])
+		}
+
+		timeouts[kv[0]] = time.Second * time.Duration(seconds)
+	}
+
+	return timeouts, nil
+}
+
+// rpcSettings are the limits of the CSI RPCs, which can be changed at runtime by the driver config
+type rpcSettings struct {
+	maxConcurrent  int
+	defaultTimeout time.Duration
+	methodTimeouts map[string]time.Duration
+}
+
+var (
+	rpcSettingsMutex   sync.RWMutex
+	currentRPCSettings rpcSettings
+)
+
+func setRPCSettings(settings rpcSettings) {
+	rpcSettingsMutex.Lock()
+	defer rpcSettingsMutex.Unlock()
+	currentRPCSettings = settings
+}
+
+func getRPCSettings() rpcSettings {
+	rpcSettingsMutex.RLock()
+	defer rpcSettingsMutex.RUnlock()
+	return currentRPCSettings
+}
+
+// timeoutInterceptor bounds each RPC by its method timeout or the default timeout, a deadline
+// already set by the caller is kept if it is earlier. A zero timeout means no bound is applied.
+func timeoutInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	settings := getRPCSettings()
+	timeout := settings.defaultTimeout
+	if t, exist := settings.methodTimeouts[path.Base(info.FullMethod)]; exist {
+		timeout = t
+	}
+
+	if timeout <= 0 {
+		return handler(ctx, req)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return handler(ctx, req)
+}
+
+// newConcurrencyInterceptor rejects RPCs beyond the max concurrent in flight with ResourceExhausted,
+// so that callers back off and retry instead of piling up goroutines in the driver. A zero max
+// means unlimited.
+func newConcurrencyInterceptor() grpc.UnaryServerInterceptor {
+	var inFlight int32
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		defer atomic.AddInt32(&inFlight, -1)
+		count := atomic.AddInt32(&inFlight, 1)
+		maxConcurrent := getRPCSettings().maxConcurrent
+		if maxConcurrent > 0 && int(count) > maxConcurrent {
+			msg := fmt.Sprintf("Too many concurrent requests (limit %d), reject %s", maxConcurrent,
+				info.FullMethod)
+			log.AddContext(ctx).Warningln(msg)
+			return nil, status.Error(codes.ResourceExhausted, msg)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// recoveryInterceptor converts a panic in an RPC handler to an Internal error, so that an unexpected
+// response from storage fails the request instead of crashing the whole plugin
+func recoveryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.AddContext(ctx).Errorf("Panic in %s: %v\n%s", info.FullMethod, r, debug.Stack())
+			resp, err = nil, status.Errorf(codes.Internal, "unexpected error in %s: %v", info.FullMethod, r)
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+func getServerOptions() []grpc.ServerOption {
+	interceptors := []grpc.UnaryServerInterceptor{log.EnsureGRPCContext, recoveryInterceptor,
+		newConcurrencyInterceptor(), timeoutInterceptor}
+
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.MaxRecvMsgSize(*maxRequestSize),
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/csi/driver"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+// appliedShareAccess is the authClient annotation last handled by volume handle, so that each change of
+// the annotation is sent to storage once
+var appliedShareAccess = map[string]string{}
+
+// reconcileShareAccess sets the NFS clients of the volumes to the authClient annotation of their PVCs
+// whenever it changes, e.g. as the nodes of the cluster are added or removed. The result is recorded
+// as an event of the PVC.
+func reconcileShareAccess(ctx context.Context, k8sUtils k8sutils.Interface, driverName string) error {
+	pvs, err := k8sUtils.ListBoundVolumes(ctx, driverName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("List PVs of driver %s error: %v", driverName, err)
+		return err
+	}
+
+	for _, pv := range pvs {
+		if pv.ClaimName == "" {
+			continue
+		}
+
+		claim, err := k8sUtils.GetClaim(ctx, pv.ClaimNamespace, pv.ClaimName)
+		if err != nil {
+			log.AddContext(ctx).Warningf("Get pvc %s/%s error: %v", pv.ClaimNamespace, pv.ClaimName, err)
+			continue
+		}
+
+		authClient := claim.Annotations[driver.AuthClientAnnotation]
+		if authClient == "" || appliedShareAccess[pv.VolumeHandle] == authClient {
+			continue
+		}
+
+		eventType, reason, message := corev1.EventTypeNormal, "ShareAccessUpdated",
+			"NFS clients of the volume are set to "+authClient
+		err = updateShareAccess(ctx, pv, authClient)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Update share access of pvc %s/%s error: %v", pv.ClaimNamespace,
+				pv.ClaimName, err)
+			eventType, reason, message = corev1.EventTypeWarning, "ShareAccessUpdateFailed", err.Error()
+		}
+
+		// a failed update is not retried until the annotation changes again, as retrying doesn't help
+		// an invalid client
+		appliedShareAccess[pv.VolumeHandle] = authClient
+		err = k8sUtils.RecordClaimEvent(ctx, pv.ClaimNamespace, pv.ClaimName, eventType, reason, message)
+		if err != nil {
+			log.AddContext(ctx).Warningf("Record event of pvc %s/%s error: %v", pv.ClaimNamespace,
+				pv.ClaimName, err)
+		}
+	}
+	return nil
+}
+
+func updateShareAccess(ctx context.Context, pv k8sutils.PVInfo, authClient string) error {
+	err := driver.ValidateAuthClient(authClient)
+	if err != nil {
+		return fmt.Errorf("annotation %s is invalid: %v", driver.AuthClientAnnotation, err)
+	}
+
+	backendName, volName := utils.SplitVolumeId(pv.VolumeHandle)
+	if plugin.IsSnapshotVolume(volName) {
+		return fmt.Errorf("the volume is a snapshot, its NFS clients can't be set")
+	}
+
+	bk := backend.GetBackend(backendName)
+	if bk == nil {
+		return fmt.Errorf("backend %s doesn't exist", backendName)
+	}
+
+	updater, ok := bk.Plugin.(plugin.ShareAccessUpdater)
+	if !ok {
+		return fmt.Errorf("backend %s of storage %s doesn't support updating NFS clients", backendName,
+			bk.Storage)
+	}
+
+	log.AddContext(ctx).Infof("Update NFS clients of volume %s to %s", volName, authClient)
+	return updater.UpdateShareAccess(ctx, volName, authClient)
+}
+
+// reconcileShareAccessPeriodically applies the authClient annotations of the PVCs on the active controller
+func reconcileShareAccessPeriodically(k8sUtils k8sutils.Interface) {
+	runPeriodically(time.Second*time.Duration(*shareAccessSyncInterval), func(ctx context.Context) {
+		_ = reconcileShareAccess(ctx, k8sUtils, *driverName)
+	})
+}
@@ -0,0 +1,77 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"huawei-csi-driver/csi/driver"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+// trimFilesystem discards the unused blocks of the mounted filesystem, so the space of the thin LUN
+// is reclaimed on storage
+var trimFilesystem = func(ctx context.Context, mountPath string) error {
+	if _, err := utils.ExecShellCmd(ctx, "mountpoint -q %s", mountPath); err != nil {
+		log.AddContext(ctx).Debugf("%s is not mounted, skip trimming it", mountPath)
+		return nil
+	}
+
+	output, err := utils.ExecShellCmd(ctx, "fstrim -v %s", mountPath)
+	if err != nil {
+		return utils.Errorf(ctx, "trim %s error: %v, output: %s", mountPath, err, output)
+	}
+
+	log.AddContext(ctx).Infof("Trim %s: %s", mountPath, output)
+	return nil
+}
+
+// trimNodeVolumes trims the staged filesystems of the PVs on the node whose spaceReclamation is periodic
+func trimNodeVolumes(ctx context.Context, k8sUtils k8sutils.Interface, kubeletRootDir, driverName string) {
+	nodeVolumes, err := getNodeVolumes(ctx, kubeletRootDir, driverName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get volumes on the node error: %v", err)
+		return
+	}
+
+	for _, nodeVolume := range nodeVolumes {
+		attributes, err := k8sUtils.GetVolumeAttributes(ctx, nodeVolume.VolumeName)
+		if err != nil {
+			log.AddContext(ctx).Warningf("Get attributes of PV %s error: %v", nodeVolume.VolumeName, err)
+			continue
+		}
+		if attributes["spaceReclamation"] != driver.SpaceReclamationPeriodic {
+			continue
+		}
+
+		stagingPath := filepath.Join(kubeletRootDir+relativePvDirPath, nodeVolume.VolumeName, "globalmount")
+		if err := trimFilesystem(ctx, stagingPath); err != nil {
+			log.AddContext(ctx).Warningf("Reclaim space of PV %s error: %v", nodeVolume.VolumeName, err)
+		}
+	}
+}
+
+// trimNodeVolumesPeriodically reclaims the space of the volumes on the node periodically
+func trimNodeVolumesPeriodically(k8sUtils k8sutils.Interface) {
+	runPeriodically(time.Second*time.Duration(*fstrimInterval), func(ctx context.Context) {
+		trimNodeVolumes(ctx, k8sUtils, *kubeletRootDir, *driverName)
+	})
+}
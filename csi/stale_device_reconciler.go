@@ -0,0 +1,96 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"huawei-csi-driver/connector"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+// staleDeviceCandidates are the LUNs whose devices were found stale by the last round, the devices are
+// removed if they are still stale in the next round, so the LUNs being attached are left alone
+var staleDeviceCandidates = map[string]bool{}
+
+// getNodeLunWWNs returns the WWNs of the LUNs of the PVs on the node
+func getNodeLunWWNs(ctx context.Context, k8sUtils k8sutils.Interface, kubeletRootDir,
+	driverName string) (map[string]bool, error) {
+	nodeVolumes, err := getNodeVolumes(ctx, kubeletRootDir, driverName)
+	if err != nil {
+		return nil, err
+	}
+
+	lunWWNs := make(map[string]bool, len(nodeVolumes))
+	for _, nodeVolume := range nodeVolumes {
+		attributes, err := k8sUtils.GetVolumeAttributes(ctx, nodeVolume.VolumeName)
+		if err != nil {
+			return nil, utils.Errorf(ctx, "get attributes of PV %s error: %v", nodeVolume.VolumeName, err)
+		}
+		if lunWWN := attributes["lunWWN"]; lunWWN != "" {
+			lunWWNs[lunWWN] = true
+		}
+	}
+
+	return lunWWNs, nil
+}
+
+// reconcileStaleDevices removes the devices of the LUNs which are no longer mapped to the node and not used
+// by any PV on the node, the round is skipped if the LUNs of the PVs can't be determined
+func reconcileStaleDevices(ctx context.Context, k8sUtils k8sutils.Interface, kubeletRootDir, driverName string) {
+	inUseWWNs, err := getNodeLunWWNs(ctx, k8sUtils, kubeletRootDir, driverName)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Skip the stale device cleanup: %v", err)
+		return
+	}
+
+	staleDevices, err := connector.FindStaleSCSIDevices(ctx, inUseWWNs)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Find stale devices error: %v", err)
+		return
+	}
+
+	candidates := make(map[string]bool, len(staleDevices))
+	for lunWWN, devices := range staleDevices {
+		if !staleDeviceCandidates[lunWWN] {
+			log.AddContext(ctx).Infof("Devices %v of LUN %s are stale, remove them if they are still stale "+
+				"next time", devices, lunWWN)
+			candidates[lunWWN] = true
+			continue
+		}
+
+		if err := connector.RemoveStaleSCSIDevices(ctx, lunWWN); err != nil {
+			log.AddContext(ctx).Warningf("Remove stale devices %v of LUN %s error: %v", devices, lunWWN, err)
+			candidates[lunWWN] = true
+			continue
+		}
+		log.AddContext(ctx).Infof("Stale devices %v of LUN %s are removed", devices, lunWWN)
+	}
+	staleDeviceCandidates = candidates
+
+	connector.RescanNVMeNamespaces(ctx)
+}
+
+// reconcileStaleDevicesPeriodically cleans up the stale devices on the node periodically
+func reconcileStaleDevicesPeriodically(k8sUtils k8sutils.Interface) {
+	runPeriodically(time.Second*time.Duration(*staleDeviceCleanupInterval), func(ctx context.Context) {
+		reconcileStaleDevices(ctx, k8sUtils, *kubeletRootDir, *driverName)
+	})
+}
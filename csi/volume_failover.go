@@ -0,0 +1,109 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+// reconcileVolumeFailovers fails the volumes of the pending volume failovers over to their replication
+// storage. A failover refused by storage is failed with the reason, the other errors are kept in its
+// message and retried, as the primary storage may be unreachable during a disaster.
+func reconcileVolumeFailovers(ctx context.Context, k8sUtils k8sutils.Interface, driverName string) error {
+	failovers, err := k8sUtils.ListVolumeFailovers(ctx)
+	if err != nil {
+		log.AddContext(ctx).Errorf("List volume failovers error: %v", err)
+		return err
+	}
+
+	for i := range failovers {
+		failover := &failovers[i]
+		if failover.Status.Phase != "" {
+			continue
+		}
+
+		err = failoverVolume(ctx, k8sUtils, driverName, failover)
+		failover.Status.Message = ""
+		if err == nil {
+			failover.Status.Phase = k8sutils.VolumeFailoverSucceeded
+		} else {
+			log.AddContext(ctx).Errorf("Fail over volume of %s/%s error: %v", failover.Namespace, failover.Name, err)
+			failover.Status.Message = err.Error()
+			if isRefused(err) {
+				failover.Status.Phase = k8sutils.VolumeFailoverFailed
+			}
+		}
+
+		err = k8sUtils.UpdateVolumeFailoverStatus(ctx, failover)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Update status of volume failover %s/%s error: %v",
+				failover.Namespace, failover.Name, err)
+		}
+	}
+	return nil
+}
+
+// failoverVolume lets the replica of the volume of the PVC be written on the replication storage
+func failoverVolume(ctx context.Context, k8sUtils k8sutils.Interface, driverName string,
+	failover *k8sutils.VolumeFailover) error {
+	volumeHandle, err := k8sUtils.GetClaimVolumeHandle(ctx, driverName, failover.Namespace,
+		failover.Spec.PersistentVolumeClaim)
+	if err != nil {
+		return err
+	}
+
+	backendName, volName := utils.SplitVolumeId(volumeHandle)
+	if plugin.IsSnapshotVolume(volName) {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"pvc %s is a snapshot, it cannot be failed over", failover.Spec.PersistentVolumeClaim)
+	}
+
+	bk := backend.GetBackend(backendName)
+	if bk == nil {
+		return fmt.Errorf("backend %s doesn't exist", backendName)
+	}
+
+	trigger, ok := bk.Plugin.(plugin.VolumeFailoverTrigger)
+	if !ok {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"backend %s of storage %s doesn't support failing volumes over", backendName, bk.Storage)
+	}
+
+	err = trigger.FailoverVolume(ctx, volName)
+	if err != nil {
+		return err
+	}
+
+	log.AddContext(ctx).Infof("Volume %s of pvc %s/%s is failed over", volumeHandle, failover.Namespace,
+		failover.Spec.PersistentVolumeClaim)
+	return nil
+}
+
+// reconcileVolumeFailoversPeriodically fails the volumes of the volume failovers over on the active controller
+func reconcileVolumeFailoversPeriodically(k8sUtils k8sutils.Interface) {
+	runPeriodically(time.Second*time.Duration(*volumeFailoverSyncInterval), func(ctx context.Context) {
+		_ = reconcileVolumeFailovers(ctx, k8sUtils, *driverName)
+	})
+}
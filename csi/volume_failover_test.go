@@ -0,0 +1,93 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+)
+
+// fakeFailoverTrigger records the volumes failed over on storage, or returns the error
+type fakeFailoverTrigger struct {
+	plugin.Plugin
+	err      error
+	failover []string
+}
+
+func (f *fakeFailoverTrigger) FailoverVolume(_ context.Context, name string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.failover = append(f.failover, name)
+	return nil
+}
+
+func TestReconcileVolumeFailovers(t *testing.T) {
+	cases := []struct {
+		name         string
+		phase        string
+		volumeHandle string
+		err          error
+		wantPhase    string
+		updated      bool
+	}{
+		{"Failed over", "", "backend1.pvc-1", nil, k8sutils.VolumeFailoverSucceeded, true},
+		{"Refused", "", "backend1.pvc-1", utils.ErrFailedPrecondition, k8sutils.VolumeFailoverFailed, true},
+		{"Storage unreachable", "", "backend1.pvc-1", errors.New("connection refused"), "", true},
+		{"Snapshot", "", "backend1.pvc-1" + plugin.SnapshotVolumeSeparator + "snapshot-1", nil,
+			k8sutils.VolumeFailoverFailed, true},
+		{"Done", k8sutils.VolumeFailoverSucceeded, "backend1.pvc-1", nil, k8sutils.VolumeFailoverSucceeded, false},
+	}
+
+	for _, c := range cases {
+		trigger := &fakeFailoverTrigger{err: c.err}
+		stubs := gostub.Stub(&backend.GetBackend, func(name string) *backend.Backend {
+			return &backend.Backend{Name: name, Plugin: trigger}
+		})
+
+		failover := k8sutils.VolumeFailover{}
+		failover.Namespace = "default"
+		failover.Spec.PersistentVolumeClaim = "pvc-1"
+		failover.Status.Phase = c.phase
+		k8sUtils := &fakeK8sUtils{
+			volumeHandles: map[string]string{"default/pvc-1": c.volumeHandle},
+			failovers:     []k8sutils.VolumeFailover{failover},
+		}
+
+		assert.NoError(t, reconcileVolumeFailovers(context.Background(), k8sUtils, "csi.huawei.com"), c.name)
+		stubs.Reset()
+		if !c.updated {
+			assert.Empty(t, k8sUtils.updatedFailovers, c.name)
+			continue
+		}
+		require.Len(t, k8sUtils.updatedFailovers, 1, c.name)
+		status := k8sUtils.updatedFailovers[0].Status
+		assert.Equal(t, c.wantPhase, status.Phase, c.name)
+		assert.Equal(t, c.wantPhase != k8sutils.VolumeFailoverSucceeded, status.Message != "", c.name)
+		assert.Equal(t, c.wantPhase == k8sutils.VolumeFailoverSucceeded, len(trigger.failover) == 1, c.name)
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+// volumeMetric is the capacity of the volume of a PV on storage
+type volumeMetric struct {
+	pv        string
+	backend   string
+	volume    string
+	allocated int64
+	consumed  int64
+}
+
+var (
+	volumeMetricsMutex sync.RWMutex
+	volumeMetrics      []volumeMetric
+)
+
+// collectVolumeMetrics queries the capacity of the volumes of the bound PVs on storage
+func collectVolumeMetrics(ctx context.Context, k8sUtils k8sutils.Interface, driverName string) error {
+	pvs, err := k8sUtils.ListBoundVolumes(ctx, driverName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("List volumes of driver %s error: %v", driverName, err)
+		return err
+	}
+
+	metrics := make([]volumeMetric, 0, len(pvs))
+	for _, pv := range pvs {
+		backendName, volName := utils.SplitVolumeId(pv.VolumeHandle)
+		bk := backend.GetBackend(backendName)
+		if bk == nil || !bk.Available || plugin.IsSnapshotVolume(volName) {
+			continue
+		}
+
+		query, ok := bk.Plugin.(plugin.VolumeStateQuery)
+		if !ok {
+			continue
+		}
+
+		state, err := query.QueryVolumeState(ctx, volName)
+		if err != nil || !state.Exist {
+			log.AddContext(ctx).Warningf("Query capacity of volume %s of PV %s error: %v", volName, pv.Name, err)
+			continue
+		}
+
+		metrics = append(metrics, volumeMetric{
+			pv:        pv.Name,
+			backend:   backendName,
+			volume:    volName,
+			allocated: state.Capacity,
+			consumed:  state.Consumed,
+		})
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].pv < metrics[j].pv
+	})
+
+	volumeMetricsMutex.Lock()
+	defer volumeMetricsMutex.Unlock()
+	volumeMetrics = metrics
+	return nil
+}
+
+// collectVolumeMetricsPeriodically collects the volume metrics on the active controller
+func collectVolumeMetricsPeriodically(k8sUtils k8sutils.Interface) {
+	runPeriodically(time.Second*time.Duration(*volumeMetricsInterval), func(ctx context.Context) {
+		_ = collectVolumeMetrics(ctx, k8sUtils, *driverName)
+	})
+}
+
+// writeVolumeMetrics writes the volume metrics in the Prometheus text format. The consumed capacity
+// is omitted for the volumes whose storage does not report it.
+func writeVolumeMetrics(w http.ResponseWriter, _ *http.Request) {
+	volumeMetricsMutex.RLock()
+	defer volumeMetricsMutex.RUnlock()
+
+	var b strings.Builder
+	b.WriteString("# HELP huawei_csi_volume_allocated_bytes The capacity in bytes of the volume on storage\n")
+	b.WriteString("# TYPE huawei_csi_volume_allocated_bytes gauge\n")
+	for _, m := range volumeMetrics {
+		fmt.Fprintf(&b, "huawei_csi_volume_allocated_bytes{persistentvolume=%q,backend=%q,volume=%q} %d\n",
+			m.pv, m.backend, m.volume, m.allocated)
+	}
+
+	b.WriteString("# HELP huawei_csi_volume_consumed_bytes The capacity in bytes consumed by the volume " +
+		"in the storage pool\n")
+	b.WriteString("# TYPE huawei_csi_volume_consumed_bytes gauge\n")
+	for _, m := range volumeMetrics {
+		if m.consumed > 0 {
+			fmt.Fprintf(&b, "huawei_csi_volume_consumed_bytes{persistentvolume=%q,backend=%q,volume=%q} %d\n",
+				m.pv, m.backend, m.volume, m.consumed)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// serveVolumeMetrics serves the volume metrics at /metrics of the address
+func serveVolumeMetrics(address string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", writeVolumeMetrics)
+
+	err := http.ListenAndServe(address, mux)
+	if err != nil {
+		log.Errorf("Serve volume metrics at %s error: %v", address, err)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	// defaultMigrationSpeed is the migration speed of the volume migrations which don't set it
+	defaultMigrationSpeed = 2
+	// storagePoolAnnotation is the annotation of the PV recording the pool its volume was migrated to
+	storagePoolAnnotation = "csi.huawei.com/storagePool"
+)
+
+// reconcileVolumeMigrations moves the volume migrations on. A migration starts moving the volume
+// to the pool on storage, waits for the move to complete while the volume stays in use, and then
+// records the pool on the PV.
+func reconcileVolumeMigrations(ctx context.Context, k8sUtils k8sutils.Interface, driverName string) error {
+	migrations, err := k8sUtils.ListVolumeMigrations(ctx)
+	if err != nil {
+		log.AddContext(ctx).Errorf("List volume migrations error: %v", err)
+		return err
+	}
+
+	for i := range migrations {
+		migration := &migrations[i]
+		if migration.Status.Phase == k8sutils.VolumeMigrationSucceeded ||
+			migration.Status.Phase == k8sutils.VolumeMigrationFailed {
+			continue
+		}
+
+		err = reconcileVolumeMigration(ctx, k8sUtils, driverName, migration)
+		migration.Status.Message = ""
+		if err != nil {
+			log.AddContext(ctx).Errorf("Migrate volume of %s/%s error: %v", migration.Namespace, migration.Name, err)
+			migration.Status.Message = err.Error()
+			if isRefused(err) {
+				migration.Status.Phase = k8sutils.VolumeMigrationFailed
+			}
+		}
+
+		err = k8sUtils.UpdateVolumeMigrationStatus(ctx, migration)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Update status of volume migration %s/%s error: %v",
+				migration.Namespace, migration.Name, err)
+		}
+	}
+	return nil
+}
+
+func reconcileVolumeMigration(ctx context.Context, k8sUtils k8sutils.Interface, driverName string,
+	migration *k8sutils.VolumeMigration) error {
+	spec := &migration.Spec
+	migrator, volName, err := getMigrationTarget(ctx, k8sUtils, driverName, migration)
+	if err != nil {
+		return err
+	}
+
+	status := &migration.Status
+	switch status.Phase {
+	case "":
+		speed := spec.MigrationSpeed
+		if speed == 0 {
+			speed = defaultMigrationSpeed
+		}
+		err = migrator.MigrateVolume(ctx, volName, spec.StoragePool, speed)
+		if err != nil {
+			return err
+		}
+		status.Phase = k8sutils.VolumeMigrationMigrating
+		log.AddContext(ctx).Infof("Volume %s of pvc %s/%s is migrating to pool %s", volName,
+			migration.Namespace, spec.PersistentVolumeClaim, spec.StoragePool)
+		return nil
+	case k8sutils.VolumeMigrationMigrating:
+		migrating, err := migrator.IsMigrating(ctx, volName, spec.StoragePool)
+		if err != nil {
+			return err
+		}
+		if migrating {
+			return fmt.Errorf("waiting for pvc %s to migrate to pool %s", spec.PersistentVolumeClaim,
+				spec.StoragePool)
+		}
+
+		err = k8sUtils.UpdateClaimVolumeAnnotation(ctx, migration.Namespace, spec.PersistentVolumeClaim,
+			storagePoolAnnotation, spec.StoragePool)
+		if err != nil {
+			return err
+		}
+		status.Phase = k8sutils.VolumeMigrationSucceeded
+		return nil
+	default:
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition, "unknown phase %s", status.Phase)
+	}
+}
+
+// getMigrationTarget returns the plugin and the name on storage of the volume of the PVC, after
+// checking the pool belongs to the backend of the volume
+func getMigrationTarget(ctx context.Context, k8sUtils k8sutils.Interface, driverName string,
+	migration *k8sutils.VolumeMigration) (plugin.VolumeMigrator, string, error) {
+	spec := &migration.Spec
+	if spec.MigrationSpeed < 0 || spec.MigrationSpeed > 4 {
+		return nil, "", utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"migration speed must be 1 to 4, not %d", spec.MigrationSpeed)
+	}
+
+	volumeHandle, err := k8sUtils.GetClaimVolumeHandle(ctx, driverName, migration.Namespace,
+		spec.PersistentVolumeClaim)
+	if err != nil {
+		return nil, "", err
+	}
+
+	backendName, volName := utils.SplitVolumeId(volumeHandle)
+	if plugin.IsSnapshotVolume(volName) {
+		return nil, "", utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"pvc %s publishes a snapshot, which cannot be migrated", spec.PersistentVolumeClaim)
+	}
+
+	bk := backend.GetBackend(backendName)
+	if bk == nil {
+		return nil, "", fmt.Errorf("backend %s doesn't exist", backendName)
+	}
+
+	found := false
+	for _, pool := range bk.Pools {
+		if pool.Name == spec.StoragePool {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, "", utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"storage pool %s is not a pool of backend %s", spec.StoragePool, backendName)
+	}
+
+	migrator, ok := bk.Plugin.(plugin.VolumeMigrator)
+	if !ok {
+		return nil, "", utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"backend %s of storage %s doesn't support migrating volumes", backendName, bk.Storage)
+	}
+	return migrator, volName, nil
+}
+
+// reconcileVolumeMigrationsPeriodically moves the volume migrations on on the active controller
+func reconcileVolumeMigrationsPeriodically(k8sUtils k8sutils.Interface) {
+	runPeriodically(time.Second*time.Duration(*volumeMigrationSyncInterval), func(ctx context.Context) {
+		_ = reconcileVolumeMigrations(ctx, k8sUtils, *driverName)
+	})
+}
@@ -0,0 +1,132 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+)
+
+// fakeMigrator records the pools and the speeds the volumes are migrated to on storage
+type fakeMigrator struct {
+	plugin.Plugin
+	migrating bool
+	pools     map[string]string
+	speeds    map[string]int
+}
+
+func (f *fakeMigrator) MigrateVolume(_ context.Context, name, pool string, speed int) error {
+	f.pools[name], f.speeds[name] = pool, speed
+	return nil
+}
+
+func (f *fakeMigrator) IsMigrating(_ context.Context, _, _ string) (bool, error) {
+	return f.migrating, nil
+}
+
+func newVolumeMigration(phase, pool string) k8sutils.VolumeMigration {
+	migration := k8sutils.VolumeMigration{}
+	migration.Namespace = "default"
+	migration.Name = "migration-1"
+	migration.Spec.PersistentVolumeClaim = "pvc-1"
+	migration.Spec.StoragePool = pool
+	migration.Status.Phase = phase
+	return migration
+}
+
+func TestReconcileVolumeMigrations(t *testing.T) {
+	cases := []struct {
+		name       string
+		phase      string
+		pool       string
+		migrating  bool
+		wantPhase  string
+		wantErr    bool
+		annotation string
+	}{
+		{"Start", "", "pool-2", false, k8sutils.VolumeMigrationMigrating, false, ""},
+		{"Wait", k8sutils.VolumeMigrationMigrating, "pool-2", true, k8sutils.VolumeMigrationMigrating, true, ""},
+		{"Migrated", k8sutils.VolumeMigrationMigrating, "pool-2", false, k8sutils.VolumeMigrationSucceeded,
+			false, "pool-2"},
+		{"Pool of other backend", "", "pool-3", false, k8sutils.VolumeMigrationFailed, true, ""},
+		{"Unknown phase", "Paused", "pool-2", false, k8sutils.VolumeMigrationFailed, true, ""},
+	}
+
+	migrator := &fakeMigrator{}
+	stubs := gostub.Stub(&backend.GetBackend, func(name string) *backend.Backend {
+		return &backend.Backend{Name: name, Plugin: migrator,
+			Pools: []*backend.StoragePool{{Name: "pool-1"}, {Name: "pool-2"}}}
+	})
+	defer stubs.Reset()
+
+	for _, c := range cases {
+		migrator.migrating = c.migrating
+		migrator.pools, migrator.speeds = map[string]string{}, map[string]int{}
+		k8sUtils := &fakeK8sUtils{
+			volumeHandles: map[string]string{"default/pvc-1": "backend1.pvc-1"},
+			annotations:   map[string]string{},
+			migrations:    []k8sutils.VolumeMigration{newVolumeMigration(c.phase, c.pool)},
+		}
+
+		assert.NoError(t, reconcileVolumeMigrations(context.Background(), k8sUtils, "csi.huawei.com"), c.name)
+		require.Len(t, k8sUtils.updatedMigrations, 1, c.name)
+		status := k8sUtils.updatedMigrations[0].Status
+		assert.Equal(t, c.wantPhase, status.Phase, c.name)
+		assert.Equal(t, c.wantErr, status.Message != "", c.name)
+		assert.Equal(t, c.annotation, k8sUtils.annotations["default/pvc-1/"+storagePoolAnnotation], c.name)
+		if c.phase == "" && !c.wantErr {
+			assert.Equal(t, "pool-2", migrator.pools["pvc-1"], c.name)
+			assert.Equal(t, defaultMigrationSpeed, migrator.speeds["pvc-1"], c.name)
+		}
+	}
+}
+
+func TestReconcileVolumeMigrationsSkipDone(t *testing.T) {
+	k8sUtils := &fakeK8sUtils{migrations: []k8sutils.VolumeMigration{
+		newVolumeMigration(k8sutils.VolumeMigrationSucceeded, "pool-2"),
+		newVolumeMigration(k8sutils.VolumeMigrationFailed, "pool-2"),
+	}}
+
+	assert.NoError(t, reconcileVolumeMigrations(context.Background(), k8sUtils, "csi.huawei.com"))
+	assert.Empty(t, k8sUtils.updatedMigrations)
+}
+
+func TestGetMigrationTargetRefused(t *testing.T) {
+	stubs := gostub.Stub(&backend.GetBackend, func(name string) *backend.Backend {
+		return &backend.Backend{Name: name, Plugin: &fakeShrinker{}, Pools: []*backend.StoragePool{{Name: "pool-2"}}}
+	})
+	defer stubs.Reset()
+
+	k8sUtils := &fakeK8sUtils{volumeHandles: map[string]string{"default/pvc-1": "backend1.pvc-1"}}
+	migration := newVolumeMigration("", "pool-2")
+	_, _, err := getMigrationTarget(context.Background(), k8sUtils, "csi.huawei.com", &migration)
+	assert.True(t, errors.Is(err, utils.ErrFailedPrecondition))
+
+	migration.Spec.MigrationSpeed = 5
+	_, _, err = getMigrationTarget(context.Background(), k8sUtils, "csi.huawei.com", &migration)
+	assert.True(t, errors.Is(err, utils.ErrFailedPrecondition))
+}
@@ -0,0 +1,115 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	// volumeQoSAnnotation of a PVC sets the QoS of its volume online, in the format of the qos
+	// StorageClass parameter. It also overrides the qos of the StorageClass when the volume is created.
+	volumeQoSAnnotation = "csi.huawei.com/qos"
+	// qosPerGiBAttribute is set on the PVs whose QoS is scaled with their size
+	qosPerGiBAttribute = "qosPerGiB"
+)
+
+// appliedVolumeQoS is the QoS annotation last handled by volume handle, so that each change of the
+// annotation is sent to storage once
+var appliedVolumeQoS = map[string]string{}
+
+// reconcileVolumeQoS sets the QoS of the volumes to the QoS annotation of their PVCs whenever it
+// changes, the result is recorded as an event of the PVC
+func reconcileVolumeQoS(ctx context.Context, k8sUtils k8sutils.Interface, driverName string) error {
+	pvs, err := k8sUtils.ListBoundVolumes(ctx, driverName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("List PVs of driver %s error: %v", driverName, err)
+		return err
+	}
+
+	for _, pv := range pvs {
+		if pv.ClaimName == "" {
+			continue
+		}
+
+		claim, err := k8sUtils.GetClaim(ctx, pv.ClaimNamespace, pv.ClaimName)
+		if err != nil {
+			log.AddContext(ctx).Warningf("Get pvc %s/%s error: %v", pv.ClaimNamespace, pv.ClaimName, err)
+			continue
+		}
+
+		qos := claim.Annotations[volumeQoSAnnotation]
+		if qos == "" || appliedVolumeQoS[pv.VolumeHandle] == qos {
+			continue
+		}
+
+		eventType, reason, message := corev1.EventTypeNormal, "QoSUpdated", "QoS of the volume is set to "+qos
+		err = updateVolumeQoS(ctx, pv, qos)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Update QoS of pvc %s/%s error: %v", pv.ClaimNamespace, pv.ClaimName, err)
+			eventType, reason, message = corev1.EventTypeWarning, "QoSUpdateFailed", err.Error()
+		}
+
+		// a failed update is not retried until the annotation changes again, as retrying doesn't help
+		// an invalid QoS
+		appliedVolumeQoS[pv.VolumeHandle] = qos
+		err = k8sUtils.RecordClaimEvent(ctx, pv.ClaimNamespace, pv.ClaimName, eventType, reason, message)
+		if err != nil {
+			log.AddContext(ctx).Warningf("Record event of pvc %s/%s error: %v", pv.ClaimNamespace,
+				pv.ClaimName, err)
+		}
+	}
+	return nil
+}
+
+func updateVolumeQoS(ctx context.Context, pv k8sutils.PVInfo, qos string) error {
+	if pv.Attributes[qosPerGiBAttribute] != "" {
+		return fmt.Errorf("the QoS of the volume is scaled with its size by the StorageClass, " +
+			"it can't be set by annotation")
+	}
+
+	backendName, volName := utils.SplitVolumeId(pv.VolumeHandle)
+	bk := backend.GetBackend(backendName)
+	if bk == nil {
+		return fmt.Errorf("backend %s doesn't exist", backendName)
+	}
+
+	updater, ok := bk.Plugin.(plugin.QoSUpdater)
+	if !ok {
+		return fmt.Errorf("backend %s of storage %s doesn't support updating QoS", backendName, bk.Storage)
+	}
+
+	log.AddContext(ctx).Infof("Update QoS of volume %s to %s", volName, qos)
+	return updater.UpdateQoS(ctx, volName, qos)
+}
+
+// reconcileVolumeQoSPeriodically applies the QoS annotations of the PVCs on the active controller
+func reconcileVolumeQoSPeriodically(k8sUtils k8sutils.Interface) {
+	runPeriodically(time.Second*time.Duration(*volumeQoSSyncInterval), func(ctx context.Context) {
+		_ = reconcileVolumeQoS(ctx, k8sUtils, *driverName)
+	})
+}
@@ -0,0 +1,301 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+// defaultRollbackSpeed is the rollback speed of the volume restores which don't set it
+const defaultRollbackSpeed = 2
+
+// restoreTarget is the volume of a volume restore and its snapshot on storage
+type restoreTarget struct {
+	bk               *backend.Backend
+	rollbacker       plugin.SnapshotRollbacker
+	volName          string
+	snapshotParentID string
+	snapshotName     string
+}
+
+// isRefused returns whether the error is a refusal which retrying doesn't help
+func isRefused(err error) bool {
+	return errors.Is(err, utils.ErrFailedPrecondition) || errors.Is(err, utils.ErrNotFound)
+}
+
+// reconcileVolumeRestores moves the volume restores on. A restore records the replicas of its workload
+// and scales it down, waits for the volume to be detached, rolls the volume back to the snapshot, and
+// then scales the workload up again, whose pods rescan the volume as they attach it.
+func reconcileVolumeRestores(ctx context.Context, k8sUtils k8sutils.Interface, driverName string) error {
+	restores, err := k8sUtils.ListVolumeRestores(ctx)
+	if err != nil {
+		log.AddContext(ctx).Errorf("List volume restores error: %v", err)
+		return err
+	}
+
+	for i := range restores {
+		restore := &restores[i]
+		if restore.Status.Phase == k8sutils.VolumeRestoreSucceeded ||
+			restore.Status.Phase == k8sutils.VolumeRestoreFailed {
+			continue
+		}
+
+		err = reconcileVolumeRestore(ctx, k8sUtils, driverName, restore)
+		restore.Status.Message = ""
+		if err != nil {
+			log.AddContext(ctx).Errorf("Restore volume of %s/%s error: %v", restore.Namespace, restore.Name, err)
+			restore.Status.Message = err.Error()
+			if isRefused(err) {
+				abortVolumeRestore(ctx, k8sUtils, restore)
+			}
+		}
+
+		err = k8sUtils.UpdateVolumeRestoreStatus(ctx, restore)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Update status of volume restore %s/%s error: %v",
+				restore.Namespace, restore.Name, err)
+		}
+	}
+	return nil
+}
+
+func reconcileVolumeRestore(ctx context.Context, k8sUtils k8sutils.Interface, driverName string,
+	restore *k8sutils.VolumeRestore) error {
+	target, err := getRestoreTarget(ctx, k8sUtils, driverName, restore)
+	if err != nil {
+		return err
+	}
+
+	status := &restore.Status
+	switch status.Phase {
+	case "":
+		return scaleDownWorkload(ctx, k8sUtils, restore)
+	case k8sutils.VolumeRestoreScalingDown:
+		attached, err := k8sUtils.IsClaimVolumeAttached(ctx, restore.Namespace, restore.Spec.PersistentVolumeClaim)
+		if err != nil {
+			return err
+		}
+		if attached {
+			return fmt.Errorf("waiting for pvc %s to be detached", restore.Spec.PersistentVolumeClaim)
+		}
+
+		speed := restore.Spec.RollbackSpeed
+		if speed == 0 {
+			speed = defaultRollbackSpeed
+		}
+		status.RestoreSize, err = target.rollbacker.RollbackSnapshot(ctx, target.volName, target.snapshotParentID,
+			target.snapshotName, speed)
+		if err != nil {
+			return err
+		}
+		status.Phase = k8sutils.VolumeRestoreRollingBack
+		return nil
+	case k8sutils.VolumeRestoreRollingBack:
+		rollingBack, err := target.rollbacker.IsRollingBack(ctx, target.snapshotParentID, target.snapshotName)
+		if err != nil {
+			return err
+		}
+		if rollingBack {
+			return fmt.Errorf("waiting for pvc %s to roll back", restore.Spec.PersistentVolumeClaim)
+		}
+		return scaleUpWorkload(ctx, k8sUtils, target, restore)
+	default:
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition, "unknown phase %s", status.Phase)
+	}
+}
+
+// getRestoreTarget returns the volume of the PVC and its snapshot of the VolumeSnapshot on storage
+func getRestoreTarget(ctx context.Context, k8sUtils k8sutils.Interface, driverName string,
+	restore *k8sutils.VolumeRestore) (*restoreTarget, error) {
+	spec := &restore.Spec
+	if spec.RollbackSpeed < 0 || spec.RollbackSpeed > 4 {
+		return nil, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"rollback speed must be 1 to 4, not %d", spec.RollbackSpeed)
+	}
+
+	volumeHandle, err := k8sUtils.GetClaimVolumeHandle(ctx, driverName, restore.Namespace, spec.PersistentVolumeClaim)
+	if err != nil {
+		return nil, err
+	}
+	snapshotHandle, err := k8sUtils.GetSnapshotHandle(ctx, restore.Namespace, spec.VolumeSnapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	backendName, volName := utils.SplitVolumeId(volumeHandle)
+	snapshotBackend, snapshotParentID, snapshotName := utils.SplitSnapshotId(snapshotHandle)
+	if snapshotBackend != backendName {
+		return nil, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"volume snapshot %s is not on backend %s of pvc %s", spec.VolumeSnapshot, backendName,
+			spec.PersistentVolumeClaim)
+	}
+
+	bk := backend.GetBackend(backendName)
+	if bk == nil {
+		return nil, fmt.Errorf("backend %s doesn't exist", backendName)
+	}
+
+	rollbacker, ok := bk.Plugin.(plugin.SnapshotRollbacker)
+	if !ok {
+		return nil, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"backend %s of storage %s doesn't support restoring volumes in place", backendName, bk.Storage)
+	}
+
+	return &restoreTarget{
+		bk:               bk,
+		rollbacker:       rollbacker,
+		volName:          volName,
+		snapshotParentID: snapshotParentID,
+		snapshotName:     snapshotName,
+	}, nil
+}
+
+// scaleDownWorkload scales the workload down to 0, so that the volume is detached before it is
+// rolled back. The replicas of the workload are recorded in the status first, and the workload is
+// scaled down by the next attempt once they are saved, so that a retry never records 0 as the
+// replicas to scale back up to.
+func scaleDownWorkload(ctx context.Context, k8sUtils k8sutils.Interface, restore *k8sutils.VolumeRestore) error {
+	spec, status := &restore.Spec, &restore.Status
+	if status.Replicas == nil {
+		using, err := k8sUtils.IsWorkloadUsingClaim(ctx, restore.Namespace, spec.Workload,
+			spec.PersistentVolumeClaim)
+		if err != nil {
+			return err
+		}
+		if !using {
+			return utils.KindErrorf(ctx, utils.ErrFailedPrecondition, "%s %s doesn't use pvc %s",
+				spec.Workload.Kind, spec.Workload.Name, spec.PersistentVolumeClaim)
+		}
+
+		replicas, err := k8sUtils.GetWorkloadReplicas(ctx, restore.Namespace, spec.Workload)
+		if err != nil {
+			return err
+		}
+		status.Replicas = &replicas
+		return nil
+	}
+
+	_, err := k8sUtils.ScaleWorkload(ctx, restore.Namespace, spec.Workload, 0)
+	if err != nil {
+		return err
+	}
+
+	status.Phase = k8sutils.VolumeRestoreScalingDown
+	log.AddContext(ctx).Infof("%s %s/%s is scaled down from %d to restore pvc %s", spec.Workload.Kind,
+		restore.Namespace, spec.Workload.Name, *status.Replicas, spec.PersistentVolumeClaim)
+	return nil
+}
+
+// scaleUpWorkload scales the workload back up after the rollback. If the volume had been expanded
+// after the snapshot, it is expanded on storage again to the capacity of the PVC, and the filesystem
+// on it grows to the capacity when the pods of the workload stage the volume.
+func scaleUpWorkload(ctx context.Context, k8sUtils k8sutils.Interface, target *restoreTarget,
+	restore *k8sutils.VolumeRestore) error {
+	spec, status := &restore.Spec, &restore.Status
+	claim, err := k8sUtils.GetClaim(ctx, restore.Namespace, spec.PersistentVolumeClaim)
+	if err != nil {
+		return err
+	}
+
+	capacity := claim.Status.Capacity.Storage()
+	if status.RestoreSize > 0 && capacity != nil && status.RestoreSize < capacity.Value() {
+		err = expandRestoredVolume(ctx, target, capacity.Value())
+		if err != nil {
+			return err
+		}
+	}
+
+	if status.Replicas != nil {
+		_, err = k8sUtils.ScaleWorkload(ctx, restore.Namespace, spec.Workload, *status.Replicas)
+		if err != nil {
+			return err
+		}
+	}
+
+	status.Phase = k8sutils.VolumeRestoreSucceeded
+	log.AddContext(ctx).Infof("Pvc %s/%s is restored from volume snapshot %s", restore.Namespace,
+		spec.PersistentVolumeClaim, spec.VolumeSnapshot)
+	return nil
+}
+
+// expandRestoredVolume expands the rolled back volume on storage to the capacity, a volume already
+// expanded by an earlier attempt is left as it is
+func expandRestoredVolume(ctx context.Context, target *restoreTarget, capacity int64) error {
+	query, ok := target.bk.Plugin.(plugin.VolumeStateQuery)
+	if !ok {
+		log.AddContext(ctx).Warningf("Backend %s can not query volumes, volume %s is left at its restored size",
+			target.bk.Name, target.volName)
+		return nil
+	}
+
+	state, err := query.QueryVolumeState(ctx, target.volName)
+	if err != nil {
+		return err
+	}
+	if !state.Exist {
+		return utils.KindErrorf(ctx, utils.ErrNotFound, "volume %s doesn't exist", target.volName)
+	}
+	if state.Capacity >= capacity {
+		return nil
+	}
+
+	_, err = target.bk.Plugin.ExpandVolume(ctx, target.volName, capacity)
+	if err != nil {
+		return err
+	}
+	log.AddContext(ctx).Infof("Restored volume %s is expanded from %d to %d", target.volName, state.Capacity,
+		capacity)
+	return nil
+}
+
+// abortVolumeRestore fails a refused volume restore, the workload is scaled back up if it was scaled
+// down. The workload of a restore refused while rolling back is left scaled down, as the volume may
+// be half rolled back.
+func abortVolumeRestore(ctx context.Context, k8sUtils k8sutils.Interface, restore *k8sutils.VolumeRestore) {
+	status := &restore.Status
+	if status.Phase == k8sutils.VolumeRestoreRollingBack {
+		status.Message += ", the volume may be half rolled back, so the workload is left scaled down"
+		status.Phase = k8sutils.VolumeRestoreFailed
+		return
+	}
+
+	if status.Replicas != nil {
+		_, err := k8sUtils.ScaleWorkload(ctx, restore.Namespace, restore.Spec.Workload, *status.Replicas)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Scale up %s %s/%s error: %v", restore.Spec.Workload.Kind,
+				restore.Namespace, restore.Spec.Workload.Name, err)
+			return
+		}
+	}
+	status.Phase = k8sutils.VolumeRestoreFailed
+}
+
+// reconcileVolumeRestoresPeriodically moves the volume restores on on the active controller
+func reconcileVolumeRestoresPeriodically(k8sUtils k8sutils.Interface) {
+	runPeriodically(time.Second*time.Duration(*volumeRestoreSyncInterval), func(ctx context.Context) {
+		_ = reconcileVolumeRestores(ctx, k8sUtils, *driverName)
+	})
+}
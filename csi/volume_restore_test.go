@@ -0,0 +1,165 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+)
+
+// fakeRollbacker rolls the volumes back to their snapshots on a fake storage, or returns the error
+type fakeRollbacker struct {
+	plugin.Plugin
+	rollingBack bool
+	err         error
+	rolledBack  []string
+}
+
+func (f *fakeRollbacker) RollbackSnapshot(_ context.Context, name, _, snapshotName string, _ int) (int64, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	f.rolledBack = append(f.rolledBack, name+"/"+snapshotName)
+	return 1 << 30, nil
+}
+
+func (f *fakeRollbacker) IsRollingBack(_ context.Context, _, _ string) (bool, error) {
+	return f.rollingBack, f.err
+}
+
+func newVolumeRestore() *k8sutils.VolumeRestore {
+	restore := &k8sutils.VolumeRestore{}
+	restore.Namespace = "default"
+	restore.Spec.PersistentVolumeClaim = "pvc-1"
+	restore.Spec.VolumeSnapshot = "snapshot-1"
+	restore.Spec.Workload = k8sutils.WorkloadReference{Kind: k8sutils.DeploymentKind, Name: "app"}
+	return restore
+}
+
+func TestScaleDownWorkload(t *testing.T) {
+	ctx := context.Background()
+	k8sUtils := &fakeK8sUtils{replicas: map[string]int32{"app": 3}}
+	restore := newVolumeRestore()
+
+	// the replicas are recorded before the workload is scaled
+	assert.NoError(t, scaleDownWorkload(ctx, k8sUtils, restore))
+	assert.Equal(t, int32(3), *restore.Status.Replicas)
+	assert.Equal(t, "", restore.Status.Phase)
+	assert.Equal(t, int32(3), k8sUtils.replicas["app"])
+
+	assert.NoError(t, scaleDownWorkload(ctx, k8sUtils, restore))
+	assert.Equal(t, k8sutils.VolumeRestoreScalingDown, restore.Status.Phase)
+	assert.Equal(t, int32(0), k8sUtils.replicas["app"])
+
+	// a retry after the phase failed to be saved keeps the recorded replicas
+	restore.Status.Phase = ""
+	assert.NoError(t, scaleDownWorkload(ctx, k8sUtils, restore))
+	assert.Equal(t, int32(3), *restore.Status.Replicas)
+	assert.Equal(t, k8sutils.VolumeRestoreScalingDown, restore.Status.Phase)
+
+	abortVolumeRestore(ctx, k8sUtils, restore)
+	assert.Equal(t, k8sutils.VolumeRestoreFailed, restore.Status.Phase)
+	assert.Equal(t, int32(3), k8sUtils.replicas["app"])
+}
+
+func TestScaleDownWorkloadNotUsingClaim(t *testing.T) {
+	ctx := context.Background()
+	k8sUtils := &fakeK8sUtils{replicas: map[string]int32{}}
+	restore := newVolumeRestore()
+
+	err := scaleDownWorkload(ctx, k8sUtils, restore)
+	assert.True(t, isRefused(err))
+	assert.Nil(t, restore.Status.Replicas)
+}
+
+func TestReconcileVolumeRestores(t *testing.T) {
+	cases := []struct {
+		name           string
+		phase          string
+		snapshotHandle string
+		attached       bool
+		rollingBack    bool
+		err            error
+		wantPhase      string
+		wantMessage    bool
+		wantReplicas   int32
+	}{
+		{"Detaching", k8sutils.VolumeRestoreScalingDown, "backend1.1.snapshot-1", true, false, nil,
+			k8sutils.VolumeRestoreScalingDown, true, 0},
+		{"Roll back", k8sutils.VolumeRestoreScalingDown, "backend1.1.snapshot-1", false, false, nil,
+			k8sutils.VolumeRestoreRollingBack, false, 0},
+		{"Rolling back", k8sutils.VolumeRestoreRollingBack, "backend1.1.snapshot-1", false, true, nil,
+			k8sutils.VolumeRestoreRollingBack, true, 0},
+		{"Restored", k8sutils.VolumeRestoreRollingBack, "backend1.1.snapshot-1", false, false, nil,
+			k8sutils.VolumeRestoreSucceeded, false, 3},
+		{"Rollback refused", k8sutils.VolumeRestoreScalingDown, "backend1.1.snapshot-1", false, false,
+			utils.ErrFailedPrecondition, k8sutils.VolumeRestoreFailed, true, 3},
+		{"Refused while rolling back", k8sutils.VolumeRestoreRollingBack, "backend1.1.snapshot-1", false, false,
+			utils.ErrNotFound, k8sutils.VolumeRestoreFailed, true, 0},
+		{"Snapshot of other backend", k8sutils.VolumeRestoreScalingDown, "backend2.1.snapshot-1", false, false, nil,
+			k8sutils.VolumeRestoreFailed, true, 3},
+	}
+
+	for _, c := range cases {
+		rollbacker := &fakeRollbacker{rollingBack: c.rollingBack, err: c.err}
+		stubs := gostub.Stub(&backend.GetBackend, func(name string) *backend.Backend {
+			return &backend.Backend{Name: name, Plugin: rollbacker}
+		})
+
+		replicas := int32(3)
+		restore := newVolumeRestore()
+		restore.Status.Phase = c.phase
+		restore.Status.Replicas = &replicas
+		claim := &corev1.PersistentVolumeClaim{}
+		claim.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")}
+		k8sUtils := &fakeK8sUtils{
+			volumeHandles:   map[string]string{"default/pvc-1": "backend1.pvc-1"},
+			snapshotHandles: map[string]string{"default/snapshot-1": c.snapshotHandle},
+			attached:        map[string]bool{"default/pvc-1": c.attached},
+			claims:          map[string]*corev1.PersistentVolumeClaim{"default/pvc-1": claim},
+			replicas:        map[string]int32{"app": 0},
+			restores:        []k8sutils.VolumeRestore{*restore},
+		}
+
+		assert.NoError(t, reconcileVolumeRestores(context.Background(), k8sUtils, "csi.huawei.com"), c.name)
+		stubs.Reset()
+		require.Len(t, k8sUtils.updatedRestores, 1, c.name)
+		status := k8sUtils.updatedRestores[0].Status
+		assert.Equal(t, c.wantPhase, status.Phase, c.name)
+		assert.Equal(t, c.wantMessage, status.Message != "", c.name)
+		assert.Equal(t, c.wantReplicas, k8sUtils.replicas["app"], c.name)
+		if c.phase == k8sutils.VolumeRestoreScalingDown && c.wantPhase == k8sutils.VolumeRestoreRollingBack {
+			assert.Equal(t, []string{"pvc-1/snapshot-1"}, rollbacker.rolledBack, c.name)
+			assert.Equal(t, int64(1<<30), status.RestoreSize, c.name)
+		}
+		if errors.Is(c.err, utils.ErrNotFound) {
+			assert.Contains(t, status.Message, "half rolled back", c.name)
+		}
+	}
+}
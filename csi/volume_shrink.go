@@ -0,0 +1,136 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/csi/driver"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/k8sutils"
+	"huawei-csi-driver/utils/log"
+)
+
+// reconcileVolumeShrinks shrinks the volumes of the pending volume shrinks. A shrink refused by
+// storage is failed with the reason, the other errors are kept in its message and retried.
+func reconcileVolumeShrinks(ctx context.Context, k8sUtils k8sutils.Interface, driverName string) error {
+	shrinks, err := k8sUtils.ListVolumeShrinks(ctx)
+	if err != nil {
+		log.AddContext(ctx).Errorf("List volume shrinks error: %v", err)
+		return err
+	}
+
+	for i := range shrinks {
+		shrink := &shrinks[i]
+		if shrink.Status.Phase != "" {
+			continue
+		}
+
+		capacity, err := shrinkVolume(ctx, k8sUtils, driverName, shrink)
+		shrink.Status.Message = ""
+		if err == nil {
+			shrink.Status.Phase = k8sutils.VolumeShrinkSucceeded
+			shrink.Status.Capacity = resource.NewQuantity(capacity, resource.BinarySI).String()
+		} else {
+			log.AddContext(ctx).Errorf("Shrink volume of %s/%s error: %v", shrink.Namespace, shrink.Name, err)
+			shrink.Status.Message = err.Error()
+			if errors.Is(err, utils.ErrFailedPrecondition) {
+				shrink.Status.Phase = k8sutils.VolumeShrinkFailed
+			}
+		}
+
+		err = k8sUtils.UpdateVolumeShrinkStatus(ctx, shrink)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Update status of volume shrink %s/%s error: %v",
+				shrink.Namespace, shrink.Name, err)
+		}
+	}
+	return nil
+}
+
+// shrinkVolume shrinks the volume of the PVC on storage, its quota and then the capacity of its PV, and
+// returns the capacity in bytes the volume is shrunk to
+func shrinkVolume(ctx context.Context, k8sUtils k8sutils.Interface, driverName string,
+	shrink *k8sutils.VolumeShrink) (int64, error) {
+	quantity, err := resource.ParseQuantity(shrink.Spec.Capacity)
+	if err != nil || quantity.Value() <= 0 {
+		return 0, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"invalid capacity %q to shrink to", shrink.Spec.Capacity)
+	}
+	capacity := utils.Capacity(quantity.Value()).RoundUp(utils.SectorSize).Bytes()
+
+	volumeHandle, err := k8sUtils.GetClaimVolumeHandle(ctx, driverName, shrink.Namespace,
+		shrink.Spec.PersistentVolumeClaim)
+	if err != nil {
+		return 0, err
+	}
+
+	backendName, volName := utils.SplitVolumeId(volumeHandle)
+	if plugin.IsSnapshotVolume(volName) {
+		return 0, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"pvc %s is a snapshot, it cannot be shrunk", shrink.Spec.PersistentVolumeClaim)
+	}
+
+	bk := backend.GetBackend(backendName)
+	if bk == nil {
+		return 0, fmt.Errorf("backend %s doesn't exist", backendName)
+	}
+
+	shrinker, ok := bk.Plugin.(plugin.VolumeShrinker)
+	if !ok {
+		return 0, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"backend %s of storage %s doesn't support shrinking volumes", backendName, bk.Storage)
+	}
+
+	attributes, err := k8sUtils.GetClaimVolumeAttributes(ctx, shrink.Namespace, shrink.Spec.PersistentVolumeClaim)
+	if err != nil {
+		return 0, err
+	}
+
+	err = shrinker.ShrinkVolume(ctx, volName, capacity)
+	if err != nil {
+		return 0, err
+	}
+
+	err = driver.UpdateQuota(ctx, bk, volName, capacity, attributes)
+	if err != nil {
+		return 0, err
+	}
+
+	err = k8sUtils.UpdateClaimVolumeCapacity(ctx, shrink.Namespace, shrink.Spec.PersistentVolumeClaim, capacity)
+	if err != nil {
+		return 0, err
+	}
+
+	log.AddContext(ctx).Infof("Volume %s of pvc %s/%s is shrunk to %d bytes",
+		volumeHandle, shrink.Namespace, shrink.Spec.PersistentVolumeClaim, capacity)
+	return capacity, nil
+}
+
+// reconcileVolumeShrinksPeriodically shrinks the volumes of the volume shrinks on the active controller
+func reconcileVolumeShrinksPeriodically(k8sUtils k8sutils.Interface) {
+	runPeriodically(time.Second*time.Duration(*volumeShrinkSyncInterval), func(ctx context.Context) {
+		_ = reconcileVolumeShrinks(ctx, k8sUtils, *driverName)
+	})
+}
@@ -0,0 +1,124 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"huawei-csi-driver/csi/backend"
+	"huawei-csi-driver/csi/backend/plugin"
+	"huawei-csi-driver/utils/k8sutils"
+)
+
+// fakeShrinker records the capacity and the hard quota the volumes are shrunk to on storage
+type fakeShrinker struct {
+	plugin.Plugin
+	sizes      map[string]int64
+	hardQuotas map[string]string
+}
+
+func (f *fakeShrinker) ShrinkVolume(_ context.Context, name string, size int64) error {
+	f.sizes[name] = size
+	return nil
+}
+
+func (f *fakeShrinker) UpdateQuota(_ context.Context, name string, size int64, _, hardQuota, _ string) error {
+	f.hardQuotas[name] = hardQuota
+	return nil
+}
+
+func TestShrinkVolume(t *testing.T) {
+	shrinker := &fakeShrinker{sizes: map[string]int64{}, hardQuotas: map[string]string{}}
+	stubs := gostub.Stub(&backend.GetBackend, func(name string) *backend.Backend {
+		return &backend.Backend{Name: name, Plugin: shrinker}
+	})
+	defer stubs.Reset()
+
+	k8sUtils := &fakeK8sUtils{
+		volumeHandles: map[string]string{"default/pvc-1": "backend1.pvc-1"},
+		attributes:    map[string]string{"hardQuota": "90"},
+		capacities:    map[string]int64{},
+	}
+	shrink := &k8sutils.VolumeShrink{}
+	shrink.Namespace = "default"
+	shrink.Spec.PersistentVolumeClaim = "pvc-1"
+	shrink.Spec.Capacity = "1Gi"
+
+	capacity, err := shrinkVolume(context.Background(), k8sUtils, "csi.huawei.com", shrink)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1<<30), capacity)
+	assert.Equal(t, int64(1<<30), shrinker.sizes["pvc-1"])
+	assert.Equal(t, "90", shrinker.hardQuotas["pvc-1"])
+	assert.Equal(t, int64(1<<30), k8sUtils.capacities["default/pvc-1"])
+}
+
+func TestReconcileVolumeShrinks(t *testing.T) {
+	cases := []struct {
+		name      string
+		phase     string
+		capacity  string
+		backend   bool
+		wantPhase string
+		updated   bool
+	}{
+		{"Shrunk", "", "1Gi", true, k8sutils.VolumeShrinkSucceeded, true},
+		{"Invalid capacity", "", "-1Gi", true, k8sutils.VolumeShrinkFailed, true},
+		{"Backend not registered", "", "1Gi", false, "", true},
+		{"Done", k8sutils.VolumeShrinkSucceeded, "1Gi", true, k8sutils.VolumeShrinkSucceeded, false},
+	}
+
+	for _, c := range cases {
+		shrinker := &fakeShrinker{sizes: map[string]int64{}, hardQuotas: map[string]string{}}
+		registered := c.backend
+		stubs := gostub.Stub(&backend.GetBackend, func(name string) *backend.Backend {
+			if !registered {
+				return nil
+			}
+			return &backend.Backend{Name: name, Plugin: shrinker}
+		})
+
+		shrink := k8sutils.VolumeShrink{}
+		shrink.Namespace = "default"
+		shrink.Spec.PersistentVolumeClaim = "pvc-1"
+		shrink.Spec.Capacity = c.capacity
+		shrink.Status.Phase = c.phase
+		k8sUtils := &fakeK8sUtils{
+			volumeHandles: map[string]string{"default/pvc-1": "backend1.pvc-1"},
+			capacities:    map[string]int64{},
+			shrinks:       []k8sutils.VolumeShrink{shrink},
+		}
+
+		assert.NoError(t, reconcileVolumeShrinks(context.Background(), k8sUtils, "csi.huawei.com"), c.name)
+		stubs.Reset()
+		if !c.updated {
+			assert.Empty(t, k8sUtils.updatedShrinks, c.name)
+			continue
+		}
+		require.Len(t, k8sUtils.updatedShrinks, 1, c.name)
+		status := k8sUtils.updatedShrinks[0].Status
+		assert.Equal(t, c.wantPhase, status.Phase, c.name)
+		assert.Equal(t, c.wantPhase != k8sutils.VolumeShrinkSucceeded, status.Message != "", c.name)
+		if c.wantPhase == k8sutils.VolumeShrinkSucceeded {
+			assert.Equal(t, "1Gi", status.Capacity, c.name)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"regexp"
 	"strings"
 
 	"huawei-csi-driver/utils"
@@ -74,9 +75,170 @@ func GetRoCEInitiator(ctx context.Context) (string, error) {
 		return "", err
 	}
 
-	return strings.TrimRight(output, "\n"), nil
+	return strings.SplitN(strings.TrimRight(output, "\n"), "\n", 2)[0], nil
 }
 
+// ISCSIInterface is an iscsiadm interface which logs in with its own initiator name
+type ISCSIInterface struct {
+	Name      string
+	Initiator string
+}
+
+// GetISCSIInterfaces returns the iscsiadm interfaces bound to an initiator name other than the
+// default one, which are used to log in over each of the initiators of the node
+func GetISCSIInterfaces(ctx context.Context) ([]ISCSIInterface, error) {
+	output, err := utils.ExecShellCmd(ctx, "iscsiadm -m iface")
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get ISCSI interfaces error: %v", output)
+		return nil, err
+	}
+
+	var ifaces []ISCSIInterface
+	for _, line := range strings.Split(output, "\n") {
+		// The format is "name transport,hwaddress,ipaddress,net_ifacename,initiatorname"
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		settings := strings.Split(fields[1], ",")
+		if len(settings) < 5 || settings[0] != "tcp" {
+			continue
+		}
+
+		initiator := settings[4]
+		if initiator == "" || initiator == "<empty>" {
+			continue
+		}
+
+		err := VerifyIscsiInitiator(initiator)
+		if err != nil {
+			return nil, err
+		}
+		ifaces = append(ifaces, ISCSIInterface{Name: fields[0], Initiator: initiator})
+	}
+
+	return ifaces, nil
+}
+
+// GetISCSIInitiators returns the default initiator name of the node, followed by the ones
+// bound to iscsiadm interfaces
+func GetISCSIInitiators(ctx context.Context) ([]string, error) {
+	name, err := GetISCSIInitiator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = VerifyIscsiInitiator(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ifaces, err := GetISCSIInterfaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	initiators := []string{name}
+	for _, iface := range ifaces {
+		if !utils.IsContain(iface.Initiator, initiators) {
+			initiators = append(initiators, iface.Initiator)
+		}
+	}
+
+	return initiators, nil
+}
+
+// GetRoCEInitiators returns the host NQNs of the node. The first line of /etc/nvme/hostnqn is
+// the default one, the others are used by RoCE NICs with their own host NQN.
+func GetRoCEInitiators(ctx context.Context) ([]string, error) {
+	output, err := utils.ExecShellCmd(ctx, "cat /etc/nvme/hostnqn")
+	if err != nil {
+		if strings.Contains(output, "No such file or directory") {
+			msg := "No NVME initiator exists"
+			log.AddContext(ctx).Errorln(msg)
+			return nil, errors.New(msg)
+		}
+
+		log.AddContext(ctx).Errorf("Get NVME initiator error: %v", output)
+		return nil, err
+	}
+
+	var initiators []string
+	for _, line := range strings.Split(output, "\n") {
+		nqn := strings.TrimSpace(line)
+		if nqn == "" || utils.IsContain(nqn, initiators) {
+			continue
+		}
+
+		err := VerifyRoCEInitiator(nqn)
+		if err != nil {
+			return nil, err
+		}
+		initiators = append(initiators, nqn)
+	}
+
+	if len(initiators) == 0 {
+		return nil, errors.New("No NVME initiator exists")
+	}
+
+	return initiators, nil
+}
+
+// VerifyIscsiInitiator checks the format of an iSCSI initiator name
+func VerifyIscsiInitiator(name string) error {
+	if len(name) > maxIscsiNameLength || !(strings.HasPrefix(name, "iqn.") ||
+		strings.HasPrefix(name, "eui.") || strings.HasPrefix(name, "naa.")) {
+		return fmt.Errorf("ISCSI initiator %s is invalid", name)
+	}
+
+	return nil
+}
+
+// VerifyRoCEInitiator checks the format of an NVMe host NQN
+func VerifyRoCEInitiator(nqn string) error {
+	if len(nqn) > maxNQNLength || !strings.HasPrefix(nqn, "nqn.") {
+		return fmt.Errorf("NVME initiator %s is invalid", nqn)
+	}
+
+	return nil
+}
+
+const (
+	maxIscsiNameLength = 223
+	maxNQNLength       = 223
+)
+
+// lookupIP resolves the host name of a portal
+var lookupIP = net.LookupIP
+
+var hostNameRegexp = regexp.MustCompile(
+	`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*\.?$`)
+
+var numericLabelRegexp = regexp.MustCompile(`^[0-9]+$`)
+
+// isValidHostName checks whether the portal is a DNS host name or FQDN. A name whose last
+// label is numeric is rejected, as it is most likely a mistyped IP address.
+func isValidHostName(portal string) bool {
+	if len(portal) > 253 || !hostNameRegexp.MatchString(portal) {
+		return false
+	}
+
+	labels := strings.Split(strings.TrimSuffix(portal, "."), ".")
+	return !numericLabelRegexp.MatchString(labels[len(labels)-1])
+}
+
+// parsePortalIP parses the IP address of a portal, IPv6 addresses may be in brackets
+func parsePortalIP(portal string) net.IP {
+	if strings.HasPrefix(portal, "[") && strings.HasSuffix(portal, "]") {
+		portal = portal[1 : len(portal)-1]
+	}
+
+	return net.ParseIP(portal)
+}
+
+// VerifyIscsiPortals checks that each portal is an IP address or a host name. Host names
+// are kept as they are, and resolved by ResolvePortals each time a volume is attached.
 func VerifyIscsiPortals(portals []interface{}) ([]string, error) {
 	if len(portals) < 1 {
 		return nil, errors.New("At least 1 portal must be provided for iscsi backend")
@@ -85,9 +247,13 @@ func VerifyIscsiPortals(portals []interface{}) ([]string, error) {
 	var verifiedPortals []string
 
 	for _, i := range portals {
-		portal := i.(string)
-		ip := net.ParseIP(portal)
-		if ip == nil {
+		portal, ok := i.(string)
+		if !ok {
+			return nil, fmt.Errorf("%v of portals is invalid", i)
+		}
+
+		ip := parsePortalIP(portal)
+		if ip == nil && !isValidHostName(portal) {
 			return nil, fmt.Errorf("%s of portals is invalid", portal)
 		}
 
@@ -96,3 +262,36 @@ func VerifyIscsiPortals(portals []interface{}) ([]string, error) {
 
 	return verifiedPortals, nil
 }
+
+// ResolvePortals returns the IP addresses of the portals. Host names are resolved again on
+// every call, so a retried attachment picks up the current DNS records after a failure.
+// Names that can not be resolved are skipped.
+func ResolvePortals(ctx context.Context, portals []string) []string {
+	var ips []string
+	resolved := make(map[string]bool)
+
+	for _, portal := range portals {
+		var portalIPs []net.IP
+		if ip := parsePortalIP(portal); ip != nil {
+			portalIPs = []net.IP{ip}
+		} else {
+			var err error
+			portalIPs, err = lookupIP(portal)
+			if err != nil {
+				log.AddContext(ctx).Warningf("Resolve portal %s error: %v", portal, err)
+				continue
+			}
+			log.AddContext(ctx).Infof("Portal %s is resolved to %v", portal, portalIPs)
+		}
+
+		for _, ip := range portalIPs {
+			ipStr := ip.String()
+			if !resolved[ipStr] {
+				resolved[ipStr] = true
+				ips = append(ips, ipStr)
+			}
+		}
+	}
+
+	return ips
+}
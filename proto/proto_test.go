@@ -19,10 +19,13 @@ package proto
 import (
 	"context"
 	"errors"
+	"net"
 	"os"
 	"path"
+	"strings"
 	"testing"
 
+	"github.com/prashantv/gostub"
 	"github.com/stretchr/testify/assert"
 
 	"huawei-csi-driver/utils"
@@ -156,6 +159,78 @@ func TestGetRoCEInitiator(t *testing.T) {
 	}
 }
 
+func TestGetISCSIInterfaces(t *testing.T) {
+	output := "default tcp,<empty>,<empty>,<empty>,<empty>\n" +
+		"iser iser,<empty>,<empty>,<empty>,<empty>\n" +
+		"eth1 tcp,<empty>,<empty>,eth1,iqn.1994-05.com.redhat:eth1\n" +
+		"eth2 tcp,<empty>,<empty>,eth2,iqn.1994-05.com.redhat:eth2\n"
+	stubs := gostub.Stub(&utils.ExecShellCmd, func(_ context.Context, _ string, _ ...interface{}) (string, error) {
+		return output, nil
+	})
+	defer stubs.Reset()
+
+	ifaces, err := GetISCSIInterfaces(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, []ISCSIInterface{
+		{Name: "eth1", Initiator: "iqn.1994-05.com.redhat:eth1"},
+		{Name: "eth2", Initiator: "iqn.1994-05.com.redhat:eth2"},
+	}, ifaces)
+
+	output = "eth1 tcp,<empty>,<empty>,eth1,invalid-initiator\n"
+	_, err = GetISCSIInterfaces(context.TODO())
+	assert.Error(t, err)
+}
+
+func TestGetRoCEInitiators(t *testing.T) {
+	cases := []struct {
+		name     string
+		output   string
+		wantNQNs []string
+		wantErr  bool
+	}{
+		{
+			"Several host NQNs",
+			"nqn.2014-08.org.nvmexpress:uuid:a08ce5a6\nnqn.2014-08.org.nvmexpress:uuid:b19df6b7\n" +
+				"nqn.2014-08.org.nvmexpress:uuid:a08ce5a6\n",
+			[]string{"nqn.2014-08.org.nvmexpress:uuid:a08ce5a6", "nqn.2014-08.org.nvmexpress:uuid:b19df6b7"},
+			false,
+		},
+		{
+			"Invalid host NQN",
+			"nqn.2014-08.org.nvmexpress:uuid:a08ce5a6\nuuid:b19df6b7\n",
+			nil,
+			true,
+		},
+		{
+			"The hostnqn file is empty",
+			"\n",
+			nil,
+			true,
+		},
+	}
+
+	temp := utils.ExecShellCmd
+	defer func() { utils.ExecShellCmd = temp }()
+	for _, c := range cases {
+		utils.ExecShellCmd = func(_ context.Context, _ string, _ ...interface{}) (string, error) {
+			return c.output, nil
+		}
+		nqns, err := GetRoCEInitiators(context.TODO())
+		assert.Equal(t, c.wantErr, err != nil, c.name)
+		assert.Equal(t, c.wantNQNs, nqns, c.name)
+	}
+}
+
+func TestVerifyInitiators(t *testing.T) {
+	assert.NoError(t, VerifyIscsiInitiator("iqn.1994-05.com.redhat:98d87323a952"))
+	assert.NoError(t, VerifyIscsiInitiator("eui.02004567A425678D"))
+	assert.Error(t, VerifyIscsiInitiator("1994-05.com.redhat:98d87323a952"))
+	assert.Error(t, VerifyIscsiInitiator("iqn."+strings.Repeat("a", maxIscsiNameLength)))
+
+	assert.NoError(t, VerifyRoCEInitiator("nqn.2014-08.org.nvmexpress:uuid:a08ce5a6"))
+	assert.Error(t, VerifyRoCEInitiator("iqn.1994-05.com.redhat:98d87323a952"))
+}
+
 func TestVerifyIscsiPortals(t *testing.T) {
 	cases := []struct {
 		name    string
@@ -181,6 +256,24 @@ func TestVerifyIscsiPortals(t *testing.T) {
 			nil,
 			errors.New("192..125.25:3260 of portals is invalid"),
 		},
+		{
+			"Host name scenario",
+			[]interface{}{"iscsi-a.storage.example.com", "192.168.125.26"},
+			[]string{"iscsi-a.storage.example.com", "192.168.125.26"},
+			nil,
+		},
+		{
+			"IPv6 scenario",
+			[]interface{}{"fe80::1", "[2001:db8::25]"},
+			[]string{"fe80::1", "[2001:db8::25]"},
+			nil,
+		},
+		{
+			"The portal looks like a truncated IP address",
+			[]interface{}{"192.168.125"},
+			nil,
+			errors.New("192.168.125 of portals is invalid"),
+		},
 	}
 
 	for _, c := range cases {
@@ -190,6 +283,21 @@ func TestVerifyIscsiPortals(t *testing.T) {
 	}
 }
 
+func TestResolvePortals(t *testing.T) {
+	stubs := gostub.Stub(&lookupIP, func(host string) ([]net.IP, error) {
+		if host == "iscsi-a.storage.example.com" {
+			return []net.IP{net.ParseIP("192.168.125.25"), net.ParseIP("192.168.125.26")}, nil
+		}
+		return nil, errors.New("no such host")
+	})
+	defer stubs.Reset()
+
+	portals := ResolvePortals(context.Background(),
+		[]string{"iscsi-a.storage.example.com", "192.168.125.26", "unknown.example.com", "192.168.125.27",
+			"[2001:DB8::25]"})
+	assert.Equal(t, []string{"192.168.125.25", "192.168.125.26", "192.168.125.27", "2001:db8::25"}, portals)
+}
+
 func TestMain(m *testing.M) {
 	if err := log.InitLogging(logName); err != nil {
 		log.Errorf("init logging: %s failed. error: %v", logName, err)
@@ -26,6 +26,7 @@ import (
 	"huawei-csi-driver/connector"
 	_ "huawei-csi-driver/connector/iscsi"
 	_ "huawei-csi-driver/connector/local"
+	_ "huawei-csi-driver/connector/roce"
 	"huawei-csi-driver/proto"
 	"huawei-csi-driver/storage/fusionstorage/client"
 	"huawei-csi-driver/utils"
@@ -45,6 +46,12 @@ const (
 	DISABLE_ALUA = "Disable_alua"
 )
 
+// isHostMapping returns whether the volumes are mapped to the hosts of the nodes, as the iSCSI and
+// NVMe over RoCE volumes are, while the SCSI volumes are attached to the VBS clients by manage IP
+func (p *Attacher) isHostMapping() bool {
+	return p.protocol == "iscsi" || p.protocol == "roce"
+}
+
 func NewAttacher(cli *client.Client, protocol, invoker string, portals []string,
 	hosts map[string]string, alua map[string]interface{}) *Attacher {
 	return &Attacher{
@@ -83,13 +90,18 @@ func (p *Attacher) parseISCSIPortal(ctx context.Context, iscsiPortal map[string]
 		return ""
 	}
 
-	portalSplit := strings.Split(portal, ":")
-	if len(portalSplit) < 2 {
-		log.AddContext(ctx).Errorf("ISCSI portal %s is invalid", portal)
-		return ""
+	ipStr, _, err := net.SplitHostPort(portal)
+	if err != nil {
+		portalSplit := strings.Split(portal, ":")
+		if len(portalSplit) < 2 {
+			log.AddContext(ctx).Errorf("ISCSI portal %s is invalid", portal)
+			return ""
+		}
+
+		// An IPv6 address followed by the port without brackets
+		ipStr = strings.Join(portalSplit[:len(portalSplit)-1], ":")
 	}
 
-	ipStr := strings.Join(portalSplit[:len(portalSplit)-1], ":")
 	ip := net.ParseIP(ipStr)
 	if ip == nil {
 		log.AddContext(ctx).Errorf("ISCSI IP %s is invalid", ipStr)
@@ -119,7 +131,7 @@ func (p *Attacher) needUpdateIscsiHost(host map[string]interface{}, hostAlua map
 	return false
 }
 
-func (p *Attacher) createIscsiHost(ctx context.Context, hostName string) error {
+func (p *Attacher) createHost(ctx context.Context, hostName string) error {
 	host, err := p.cli.GetHostByName(ctx, hostName)
 	if err != nil {
 		return err
@@ -164,14 +176,13 @@ func (p *Attacher) getTargetPortals(ctx context.Context) ([]string, []string, er
 
 	var tgtPortals []string
 	var tgtIQNs []string
-	for _, portal := range p.portals {
-		ip := net.ParseIP(portal).String()
+	for _, ip := range proto.ResolvePortals(ctx, p.portals) {
 		if !validIPs[ip] {
 			log.AddContext(ctx).Warningf("Config ISCSI portal %s is not valid", ip)
 			continue
 		}
 
-		formatIP := fmt.Sprintf("%s:3260", ip)
+		formatIP := net.JoinHostPort(ip, "3260")
 		tgtPortals = append(tgtPortals, formatIP)
 		tgtIQNs = append(tgtIQNs, validIQNs[ip])
 	}
@@ -204,12 +215,24 @@ func (p *Attacher) parseiSCSIPortalList(ctx context.Context,
 	return nil
 }
 
+// attachIscsiInitiatorToHost adds all the iSCSI initiators of the node to the host
 func (p *Attacher) attachIscsiInitiatorToHost(ctx context.Context, hostName string) error {
-	initiatorName, err := proto.GetISCSIInitiator(ctx)
+	initiatorNames, err := proto.GetISCSIInitiators(ctx)
 	if err != nil {
 		return err
 	}
 
+	for _, initiatorName := range initiatorNames {
+		err := p.attachInitiator(ctx, initiatorName, hostName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Attacher) attachInitiator(ctx context.Context, initiatorName, hostName string) error {
 	initiator, err := p.cli.GetInitiatorByName(ctx, initiatorName)
 	if err != nil {
 		return err
@@ -233,7 +256,7 @@ func (p *Attacher) attachIscsiInitiatorToHost(ctx context.Context, hostName stri
 		if len(host) == 0 {
 			addInitiator = true
 		} else if host != hostName {
-			return fmt.Errorf("ISCSI initiator %s is already associated to another host %s", initiatorName, host)
+			return fmt.Errorf("Initiator %s is already associated to another host %s", initiatorName, host)
 		}
 	}
 
@@ -247,6 +270,39 @@ func (p *Attacher) attachIscsiInitiatorToHost(ctx context.Context, hostName stri
 	return nil
 }
 
+// GetOtherMappedHosts returns the hosts other than the given node, which the volume is mapped to.
+// Only iSCSI and NVMe over RoCE volumes are mapped to hosts.
+func (p *Attacher) GetOtherMappedHosts(ctx context.Context,
+	lunName string,
+	parameters map[string]interface{}) ([]string, error) {
+	if !p.isHostMapping() {
+		return nil, nil
+	}
+
+	selfHostName, err := p.getHostName(ctx, parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts, err := p.cli.QueryHostOfVolume(ctx, lunName)
+	if err != nil {
+		return nil, err
+	}
+
+	var hostNames []string
+	for _, host := range hosts {
+		hostName, err := utils.GetStringField(host, "hostName")
+		if err != nil {
+			return nil, err
+		}
+		if hostName != selfHostName {
+			hostNames = append(hostNames, hostName)
+		}
+	}
+
+	return hostNames, nil
+}
+
 func (p *Attacher) isVolumeAddToHost(ctx context.Context, lunName, hostName string) (bool, error) {
 	hosts, err := p.cli.QueryHostOfVolume(ctx, lunName)
 	if err != nil {
@@ -254,7 +310,7 @@ func (p *Attacher) isVolumeAddToHost(ctx context.Context, lunName, hostName stri
 	}
 
 	for _, i := range hosts {
-		if i["hostName"].(string) == hostName {
+		if i["hostName"] == hostName {
 			return true, nil
 		}
 	}
@@ -274,7 +330,7 @@ func (p *Attacher) doMapping(ctx context.Context, lunName, hostName string) (str
 		return "", errors.New(msg)
 	}
 
-	if p.protocol == "iscsi" {
+	if p.isHostMapping() {
 		isAdded, err := p.isVolumeAddToHost(ctx, lunName, hostName)
 		if err != nil {
 			return "", err
@@ -298,7 +354,7 @@ func (p *Attacher) doMapping(ctx context.Context, lunName, hostName string) (str
 		}
 	}
 
-	return lun["wwn"].(string), nil
+	return utils.GetStringField(lun, "wwn")
 }
 
 func (p *Attacher) doUnmapping(ctx context.Context, lunName, hostName string) (string, error) {
@@ -307,7 +363,7 @@ func (p *Attacher) doUnmapping(ctx context.Context, lunName, hostName string) (s
 		return "", err
 	}
 
-	if p.protocol == "iscsi" {
+	if p.isHostMapping() {
 		isAdded, err := p.isVolumeAddToHost(ctx, lunName, hostName)
 		if err != nil {
 			return "", err
@@ -331,7 +387,7 @@ func (p *Attacher) doUnmapping(ctx context.Context, lunName, hostName string) (s
 		}
 	}
 
-	return lun["wwn"].(string), nil
+	return utils.GetStringField(lun, "wwn")
 }
 
 func (p *Attacher) getMappingProperties(ctx context.Context,
@@ -363,6 +419,8 @@ func (p *Attacher) getMappingProperties(ctx context.Context,
 	if !exist {
 		return nil, errors.New("key scsiMultiPathType does not exist in parameters")
 	}
+
+	connectInfo["storageInterfaces"] = parameters["storageInterfaces"]
 	return connectInfo, nil
 }
 
@@ -375,9 +433,9 @@ func (p *Attacher) iSCSIControllerAttach(ctx context.Context, lunInfo utils.Volu
 		return nil, err
 	}
 
-	err = p.createIscsiHost(ctx, hostName)
+	err = p.createHost(ctx, hostName)
 	if err != nil {
-		log.AddContext(ctx).Errorf("Create iSCSI host %s error: %v", hostName, err)
+		log.AddContext(ctx).Errorf("Create host %s error: %v", hostName, err)
 		return nil, err
 	}
 
@@ -386,18 +444,11 @@ func (p *Attacher) iSCSIControllerAttach(ctx context.Context, lunInfo utils.Volu
 		return nil, err
 	}
 
-	isAdded, err := p.isVolumeAddToHost(ctx, lunInfo.GetVolumeName(), hostName)
+	err = p.addLunToHost(ctx, lunInfo.GetVolumeName(), hostName)
 	if err != nil {
 		return nil, err
 	}
 
-	if !isAdded {
-		err := p.cli.AddLunToHost(ctx, lunInfo.GetVolumeName(), hostName)
-		if err != nil {
-			return nil, err
-		}
-	}
-
 	hostLunId, err := p.cli.GetHostLunId(ctx, hostName, lunInfo.GetVolumeName())
 	if err != nil {
 		return nil, err
@@ -410,6 +461,79 @@ func (p *Attacher) iSCSIControllerAttach(ctx context.Context, lunInfo utils.Volu
 	return p.getMappingProperties(ctx, lunWWN, hostLunId, parameters)
 }
 
+// roCEControllerAttach maps the volume to the host of the node as a namespace of the NVMe subsystem, which
+// the node discovers and connects over RoCE. The host NQNs are not registered by the driver, as the iSCSI
+// service API of the storage only takes IQNs, so the host named after the node has to be created with its
+// host NQNs on storage beforehand. Attaching over DPC is not supported.
+func (p *Attacher) roCEControllerAttach(ctx context.Context, lunInfo utils.Volume,
+	parameters map[string]interface{}) (map[string]interface{}, error) {
+	hostName, err := p.getHostName(ctx, parameters)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get host name error: %v", err)
+		return nil, err
+	}
+
+	host, err := p.cli.GetHostByName(ctx, hostName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get host %s error: %v", hostName, err)
+		return nil, err
+	}
+	if host == nil {
+		return nil, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"Host %s does not exist, it has to be created with the host NQNs of the node on storage", hostName)
+	}
+
+	err = p.addLunToHost(ctx, lunInfo.GetVolumeName(), hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	lunWWN, err := lunInfo.GetLunWWN()
+	if err != nil {
+		return nil, err
+	}
+	return p.getRoCEProperties(ctx, lunWWN, parameters)
+}
+
+func (p *Attacher) addLunToHost(ctx context.Context, lunName, hostName string) error {
+	isAdded, err := p.isVolumeAddToHost(ctx, lunName, hostName)
+	if err != nil {
+		return err
+	}
+
+	if !isAdded {
+		return p.cli.AddLunToHost(ctx, lunName, hostName)
+	}
+	return nil
+}
+
+func (p *Attacher) getRoCEProperties(ctx context.Context, wwn string,
+	parameters map[string]interface{}) (map[string]interface{}, error) {
+	tgtPortals := proto.ResolvePortals(ctx, p.portals)
+	if len(tgtPortals) == 0 {
+		return nil, utils.Errorf(ctx, "None of the config portals %v is resolved", p.portals)
+	}
+
+	volumeUseMultiPath, exist := parameters["volumeUseMultiPath"].(bool)
+	if !exist {
+		return nil, errors.New("key volumeUseMultiPath does not exist in parameters")
+	}
+
+	multiPathType, exist := parameters["nvmeMultiPathType"].(string)
+	if !exist {
+		return nil, errors.New("key nvmeMultiPathType does not exist in parameters")
+	}
+
+	return map[string]interface{}{
+		"tgtPortals":         tgtPortals,
+		"tgtLunGuid":         wwn,
+		"volumeUseMultiPath": volumeUseMultiPath,
+		"multiPathType":      multiPathType,
+		"storageInterfaces":  parameters["storageInterfaces"],
+		"nvmeTransport":      parameters["nvmeTransport"],
+	}, nil
+}
+
 func (p *Attacher) SCSIControllerAttach(ctx context.Context,
 	lunInfo utils.Volume,
 	parameters map[string]interface{}) (string, error) {
@@ -472,6 +596,13 @@ func (p *Attacher) NodeStage(ctx context.Context,
 		}
 
 		conn = connector.GetConnector(ctx, connector.ISCSIDriver)
+	} else if p.protocol == "roce" {
+		mappingInfo, err = p.roCEControllerAttach(ctx, lunInfo, parameters)
+		if err != nil {
+			return &connector.ConnectInfo{}, err
+		}
+
+		conn = connector.GetConnector(ctx, connector.RoCEDriver)
 	} else {
 		tgtLunWWN, err := p.SCSIControllerAttach(ctx, lunInfo, parameters)
 		if err != nil {
@@ -499,6 +630,8 @@ func (p *Attacher) NodeUnstage(ctx context.Context,
 	var conn connector.Connector
 	if p.protocol == "iscsi" {
 		conn = connector.GetConnector(ctx, connector.ISCSIDriver)
+	} else if p.protocol == "roce" {
+		conn = connector.GetConnector(ctx, connector.RoCEDriver)
 	} else {
 		conn = connector.GetConnector(ctx, connector.LocalDriver)
 	}
@@ -24,7 +24,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/http/cookiejar"
 	"strconv"
@@ -43,6 +42,8 @@ const (
 	defaultParallelCount int = 50
 	maxParallelCount     int = 1000
 	minParallelCount     int = 20
+
+	maxLogResponseBytes = 8 * 1024
 )
 
 var (
@@ -175,11 +176,11 @@ func (cli *Client) KeepAlive(ctx context.Context) {
 
 func (cli *Client) doCall(ctx context.Context,
 	method string, url string,
-	data map[string]interface{}) (http.Header, []byte, error) {
+	data map[string]interface{}) (http.Header, map[string]interface{}, error) {
 	var err error
 	var reqUrl string
 	var reqBody io.Reader
-	var respBody []byte
+	var respBody map[string]interface{}
 
 	if data != nil {
 		reqBytes, err := json.Marshal(data)
@@ -227,39 +228,30 @@ func (cli *Client) doCall(ctx context.Context,
 
 	defer resp.Body.Close()
 
-	respBody, err = ioutil.ReadAll(resp.Body)
+	// Decode the body as a stream and keep only a bounded prefix of it for logging
+	logBody := utils.NewLimitedBuffer(maxLogResponseBytes)
+	err = json.NewDecoder(io.TeeReader(resp.Body, logBody)).Decode(&respBody)
+
+	log.FilteredLog(ctx, isFilterLog(method, url), utils.IsDebugLog(method, url, debugLog),
+		fmt.Sprintf("Response method: %s, url: %s, body: %s", method, reqUrl, logBody))
+
 	if err != nil {
-		log.AddContext(ctx).Errorf("Read response data error: %v", err)
+		log.AddContext(ctx).Errorf("Decode response body %s error: %v", logBody, err)
 		return nil, nil, err
 	}
 
-	log.FilteredLog(ctx, isFilterLog(method, url), utils.IsDebugLog(method, url, debugLog),
-		fmt.Sprintf("Response method: %s, url: %s, body: %s", method, reqUrl, respBody))
-
 	return resp.Header, respBody, nil
 }
 
 func (cli *Client) baseCall(ctx context.Context, method string, url string, data map[string]interface{}) (http.Header,
 	map[string]interface{}, error) {
-	var body map[string]interface{}
-	respHeader, respBody, err := cli.doCall(ctx, method, url, data)
-	if err != nil {
-		return nil, nil, err
-	}
-	err = json.Unmarshal(respBody, &body)
-	if err != nil {
-		log.AddContext(ctx).Errorf("Unmarshal response body %s error: %v", respBody, err)
-		return nil, nil, err
-	}
-	return respHeader, body, nil
+	return cli.doCall(ctx, method, url, data)
 }
 
 func (cli *Client) call(ctx context.Context,
 	method string, url string,
 	data map[string]interface{}) (http.Header, map[string]interface{}, error) {
-	var body map[string]interface{}
-
-	respHeader, respBody, err := cli.doCall(ctx, method, url, data)
+	respHeader, body, err := cli.doCall(ctx, method, url, data)
 
 	if err != nil {
 		if err.Error() == "unconnected" {
@@ -269,12 +261,6 @@ func (cli *Client) call(ctx context.Context,
 		return nil, nil, err
 	}
 
-	err = json.Unmarshal(respBody, &body)
-	if err != nil {
-		log.AddContext(ctx).Errorf("Unmarshal response body %s error: %v", respBody, err)
-		return nil, nil, err
-	}
-
 	if errorCode, ok := body["errorCode"].(string); ok && errorCode == offLineCode {
 		log.AddContext(ctx).Warningf("User offline, try to relogin %s", cli.url)
 		goto RETRY
@@ -297,16 +283,10 @@ func (cli *Client) call(ctx context.Context,
 RETRY:
 	err = cli.reLogin(ctx)
 	if err == nil {
-		respHeader, respBody, err = cli.doCall(ctx, method, url, data)
-	}
-
-	if err != nil {
-		return nil, nil, err
+		respHeader, body, err = cli.doCall(ctx, method, url, data)
 	}
 
-	err = json.Unmarshal(respBody, &body)
 	if err != nil {
-		log.AddContext(ctx).Errorf("Unmarshal response body %s error: %v", respBody, err)
 		return nil, nil, err
 	}
 
@@ -44,6 +44,27 @@ func (cli *Client) CreateQoS(ctx context.Context, qosName string, qosData map[st
 	return nil
 }
 
+// UpdateQoS modifies the limits of an existing QoS, all the volumes associated with it are affected
+func (cli *Client) UpdateQoS(ctx context.Context, qosName string, qosData map[string]int) error {
+	data := map[string]interface{}{
+		"qosName":     qosName,
+		"qosSpecInfo": qosData,
+	}
+
+	resp, err := cli.post(ctx, "/dsware/service/v1.3/qos/modify", data)
+	if err != nil {
+		return err
+	}
+
+	result := int64(resp["result"].(float64))
+	if result != 0 {
+		errorCode, _ := resp["errorCode"].(string)
+		return fmt.Errorf("update QoS %v error: %s", data, errorCode)
+	}
+
+	return nil
+}
+
 func (cli *Client) DeleteQoS(ctx context.Context, qosName string) error {
 	data := map[string]interface{}{
 		"qosNames": []string{qosName},
@@ -19,11 +19,12 @@ package smartx
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"huawei-csi-driver/storage/fusionstorage/client"
+	"huawei-csi-driver/utils"
 	"huawei-csi-driver/utils/log"
 )
 
@@ -36,36 +37,79 @@ var (
 			return value > 0
 		},
 	}
+
+	// oceanStorQosKeys maps the keys of the OceanStor qos syntax to the keys of the FusionStorage QoS specs
+	oceanStorQosKeys = map[string]string{
+		"MAXBANDWIDTH": "maxMBPS",
+		"MAXIOPS":      "maxIOPS",
+	}
+
+	// unsupportedQosKeys are the keys of the OceanStor qos syntax which FusionStorage can't limit
+	unsupportedQosKeys = []string{"MINBANDWIDTH", "MINIOPS", "LATENCY"}
 )
 
-func VerifyQos(ctx context.Context, qosConfig string) (map[string]int, error) {
-	var msg string
-	var params map[string]int
+const (
+	// qosIOTypeReadWrite is the only IOTYPE of the OceanStor qos syntax which FusionStorage supports,
+	// the limits of FusionStorage QoS apply to both read and write IO
+	qosIOTypeReadWrite = 2
+)
 
+// VerifyQos validates the qos StorageClass parameter and converts it to the FusionStorage QoS specs,
+// both the FusionStorage syntax (maxMBPS, maxIOPS) and the OceanStor syntax (MAXBANDWIDTH, MAXIOPS, IOTYPE)
+// are accepted
+func VerifyQos(ctx context.Context, qosConfig string) (map[string]int, error) {
+	var params map[string]interface{}
 	err := json.Unmarshal([]byte(qosConfig), &params)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Unmarshal %s error: %v", qosConfig, err)
 		return nil, err
 	}
 
+	qos := make(map[string]int)
 	for k, v := range params {
-		f, exist := ValidQosKey[k]
-		if !exist {
-			msg = fmt.Sprintf("%s is an invalid key for QoS", k)
-			goto ERROR
+		value, ok := v.(float64)
+		if !ok || value != math.Trunc(value) {
+			return nil, utils.Errorf(ctx, "%s of qos specs %v must be an integer", k, v)
+		}
+
+		if k == "IOTYPE" {
+			if int(value) != qosIOTypeReadWrite {
+				return nil, utils.Errorf(ctx, "IOTYPE %d of qos specs is not supported by FusionStorage, "+
+					"only %d (read and write) is supported", int(value), qosIOTypeReadWrite)
+			}
+			continue
 		}
 
-		if !f(v) {
-			msg = fmt.Sprintf("%s of qos specs is invalid", k)
-			goto ERROR
+		if utils.IsContain(k, unsupportedQosKeys) {
+			return nil, utils.Errorf(ctx, "%s of qos specs is not supported by FusionStorage, "+
+				"only MAXBANDWIDTH and MAXIOPS can be limited", k)
 		}
+
+		key := k
+		if specKey, exist := oceanStorQosKeys[k]; exist {
+			key = specKey
+		}
+
+		f, exist := ValidQosKey[key]
+		if !exist {
+			return nil, utils.Errorf(ctx, "%s is an invalid key for QoS", k)
+		}
+		if !f(int(value)) {
+			return nil, utils.Errorf(ctx, "%s of qos specs is invalid", k)
+		}
+		if _, exist := qos[key]; exist {
+			return nil, utils.Errorf(ctx, "%s of qos specs is set more than once", key)
+		}
+
+		qos[key] = int(value)
 	}
 
-	return params, nil
+	if len(qos) == 0 {
+		return nil, utils.Errorf(ctx, "qos specs %s must limit at least one of MAXBANDWIDTH and MAXIOPS",
+			qosConfig)
+	}
 
-ERROR:
-	log.AddContext(ctx).Errorln(msg)
-	return nil, errors.New(msg)
+	return qos, nil
 }
 
 type QoS struct {
@@ -106,6 +150,28 @@ func (p *QoS) AddQoS(ctx context.Context, volName string, params map[string]int)
 	return qosName, nil
 }
 
+// UpdateQoS modifies the QoS of the volume, a new QoS is created if the volume has none
+func (p *QoS) UpdateQoS(ctx context.Context, volName string, params map[string]int) error {
+	qosName, err := p.cli.GetQoSNameByVolume(ctx, volName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get QoS of volume %s error: %v", volName, err)
+		return err
+	}
+
+	if qosName == "" {
+		_, err = p.AddQoS(ctx, volName, params)
+		return err
+	}
+
+	err = p.cli.UpdateQoS(ctx, qosName, params)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Update QoS %s of volume %s to %v error: %v", qosName, volName, params, err)
+		return err
+	}
+
+	return nil
+}
+
 func (p *QoS) RemoveQoS(ctx context.Context, volName string) error {
 	qosName, err := p.cli.GetQoSNameByVolume(ctx, volName)
 	if err != nil {
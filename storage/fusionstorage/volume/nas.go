@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -39,6 +40,16 @@ const (
 	directoryQuotaType      = "1"
 )
 
+// quotaUnitBytes are the bytes of the space_unit_type of quotas
+var quotaUnitBytes = map[int64]int64{
+	0: 1,
+	1: 1 << 10,
+	2: 1 << 20,
+	3: 1 << 30,
+	4: 1 << 40,
+	5: 1 << 50,
+}
+
 const (
 	allSquashString    string = "all_squash"
 	noAllSquashString  string = "no_all_squash"
@@ -146,17 +157,32 @@ func (p *NAS) preCreate(ctx context.Context, params map[string]interface{}) erro
 		}
 	}
 
-	if val, ok := params["snapshotdirectoryvisibility"].(string); ok {
-		if strings.EqualFold(val, visibleString) {
-			params["isshowsnapdir"] = true
-		} else if strings.EqualFold(val, invisibleString) {
-			params["isshowsnapdir"] = false
-		} else {
-			return utils.Errorf(ctx, "parameter snapshotDirectoryVisibility [%v] in sc must be %s or %s.",
-				params["snapshotdirectoryvisibility"], visibleString, invisibleString)
+	// the squashed users are mapped to the anonymous IDs of storage
+	for _, key := range []string{"anonuid", "anongid"} {
+		if _, exist := params[key]; exist {
+			return utils.Errorln(ctx, "parameters anonUid and anonGid in sc are not supported by FusionStorage")
+		}
+	}
+	if _, exist := params["sectorsize"]; exist {
+		return utils.Errorln(ctx, "parameter sectorSize in sc is not supported by FusionStorage")
+	}
+	for _, key := range []string{"nfsaclmode", "nfssecurityflavors"} {
+		if _, exist := params[key]; exist {
+			return utils.Errorln(ctx, "parameters nfsAclMode and nfsSecurityFlavors in sc are not supported "+
+				"by FusionStorage")
 		}
 	}
 
+	// the snapshot directory is invisible unless it is requested, so that the pods don't see the snapshots
+	if val, ok := params["snapshotdirectoryvisibility"].(string); !ok || strings.EqualFold(val, invisibleString) {
+		params["isshowsnapdir"] = false
+	} else if strings.EqualFold(val, visibleString) {
+		params["isshowsnapdir"] = true
+	} else {
+		return utils.Errorf(ctx, "parameter snapshotDirectoryVisibility [%v] in sc must be %s or %s.",
+			params["snapshotdirectoryvisibility"], visibleString, invisibleString)
+	}
+
 	return nil
 }
 
@@ -169,7 +195,7 @@ func (p *NAS) Create(ctx context.Context, params map[string]interface{}) (utils.
 	createTask := taskflow.NewTaskFlow(ctx, "Create-FileSystem-Volume")
 	createTask.AddTask("Create-FS", p.createFS, p.revertFS)
 	if params["protocol"] == "dpc" {
-		createTask.AddTask("Create-Quota", p.createQuota, nil)
+		createTask.AddTask("Create-Quota", p.createQuota, p.revertQuota)
 	} else {
 		createTask.AddTask("Create-Quota", p.createQuota, p.revertQuota)
 		createTask.AddTask("Create-Share", p.createShare, p.revertShare)
@@ -228,8 +254,13 @@ func (p *NAS) createFS(ctx context.Context,
 		return nil, err
 	}
 
+	fsID, err := utils.GetInt64Field(fs, "id")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of filesystem %s error: %v", fsName, err)
+	}
+
 	return map[string]interface{}{
-		"fsID": strconv.FormatInt(int64(fs["id"].(float64)), 10),
+		"fsID": strconv.FormatInt(fsID, 10),
 	}, nil
 }
 
@@ -337,6 +368,108 @@ func (p *NAS) deleteQuota(ctx context.Context, fsID string) error {
 	return nil
 }
 
+// Expand raises the limit of the quota of the filesystem to the new size in KiB, which is the capacity
+// of the filesystem its clients see and are refused to write beyond
+func (p *NAS) Expand(ctx context.Context, name string, newSize int64) error {
+	fsID, quota, err := p.getFSQuota(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	quotaID, err := utils.GetStringField(quota, "id")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of quota of filesystem %s error: %v", fsID, err)
+	}
+
+	// the soft quota is raised only if the filesystem has no hard one, as it is created
+	limitKey := "space_hard_quota"
+	if _, hardSet := getQuotaLimit(quota, "space_hard_quota"); !hardSet {
+		if _, softSet := getQuotaLimit(quota, "space_soft_quota"); softSet {
+			limitKey = "space_soft_quota"
+		}
+	}
+
+	limit, _ := getQuotaLimit(quota, limitKey)
+	if limit >= newSize*quotaUnitBytes[spaceQuotaUnitKB] {
+		log.AddContext(ctx).Infof("Quota %s of filesystem %s is already %d bytes", quotaID, fsID, limit)
+		return nil
+	}
+
+	err = p.cli.UpdateQuota(ctx, map[string]interface{}{
+		"id":              quotaID,
+		"space_unit_type": spaceQuotaUnitKB,
+		limitKey:          newSize,
+	})
+	if err != nil {
+		log.AddContext(ctx).Errorf("Expand quota %s of filesystem %s error: %v", quotaID, fsID, err)
+		return err
+	}
+
+	log.AddContext(ctx).Infof("Quota %s of filesystem %s is expanded to %d KiB", quotaID, fsID, newSize)
+	return nil
+}
+
+// GetQuotaUsage returns the hard limit of the quota of the filesystem and the capacity it uses in bytes
+func (p *NAS) GetQuotaUsage(ctx context.Context, name string) (int64, int64, error) {
+	_, quota, err := p.getFSQuota(ctx, name)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	hardLimit, _ := getQuotaLimit(quota, "space_hard_quota")
+	used, _ := getQuotaLimit(quota, "space_used")
+	return hardLimit, used, nil
+}
+
+// getFSQuota returns the ID of the filesystem and its quota
+func (p *NAS) getFSQuota(ctx context.Context, name string) (string, map[string]interface{}, error) {
+	fsName := utils.GetFileSystemName(name)
+	fs, err := p.cli.GetFileSystemByName(ctx, fsName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get filesystem %s error: %v", fsName, err)
+		return "", nil, err
+	}
+	if fs == nil {
+		return "", nil, utils.KindErrorf(ctx, utils.ErrNotFound, "Filesystem %s does not exist", fsName)
+	}
+
+	id, err := utils.GetInt64Field(fs, "id")
+	if err != nil {
+		return "", nil, utils.Errorf(ctx, "Get ID of filesystem %s error: %v", fsName, err)
+	}
+	fsID := strconv.FormatInt(id, 10)
+
+	quota, err := p.cli.GetQuotaByFileSystem(ctx, fsID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get filesystem %s quota error: %v", fsID, err)
+		return "", nil, err
+	}
+	if quota == nil {
+		return "", nil, utils.KindErrorf(ctx, utils.ErrFailedPrecondition, "Filesystem %s has no quota", fsName)
+	}
+
+	return fsID, quota, nil
+}
+
+// getQuotaLimit returns the space field of the quota in bytes, and whether it is set. The unset limits
+// are reported as the max unsigned integer.
+func getQuotaLimit(quota map[string]interface{}, key string) (int64, bool) {
+	value, ok := quota[key].(float64)
+	if !ok || value <= 0 || value >= math.MaxInt64 {
+		return 0, false
+	}
+
+	unit := int64(spaceQuotaUnitKB)
+	if unitType, ok := quota["space_unit_type"].(float64); ok {
+		unit = int64(unitType)
+	}
+	unitBytes, exist := quotaUnitBytes[unit]
+	if !exist {
+		return 0, false
+	}
+	return int64(value) * unitBytes, true
+}
+
 func (p *NAS) createShare(ctx context.Context,
 	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
 	fsName, ok := params["name"].(string)
@@ -373,8 +506,13 @@ func (p *NAS) createShare(ctx context.Context,
 			return nil, err
 		}
 	}
+	shareID, err := utils.GetStringField(share, "id")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of nfs share error: %v", err)
+	}
+
 	return map[string]interface{}{
-		"shareID":   share["id"].(string),
+		"shareID":   shareID,
 		"accountId": accountId,
 	}, nil
 }
@@ -447,8 +585,16 @@ func (p *NAS) Delete(ctx context.Context, name string) error {
 		return nil
 	}
 
-	fsID := strconv.FormatInt(int64(fs["id"].(float64)), 10)
-	accountId := fs["account_id"].(string)
+	id, err := utils.GetInt64Field(fs, "id")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of filesystem %s error: %v", fsName, err)
+	}
+	accountId, err := utils.GetStringField(fs, "account_id")
+	if err != nil {
+		return utils.Errorf(ctx, "Get account of filesystem %s error: %v", fsName, err)
+	}
+
+	fsID := strconv.FormatInt(id, 10)
 	sharePath := utils.GetFSSharePath(name)
 	share, err := p.cli.GetNfsShareByPath(ctx, sharePath, accountId)
 	if err != nil {
@@ -469,14 +615,20 @@ func (p *NAS) Delete(ctx context.Context, name string) error {
 			return err
 		}
 	} else {
-		shareID := share["id"].(string)
+		shareID, err := utils.GetStringField(share, "id")
+		if err != nil {
+			return utils.Errorf(ctx, "Get ID of nfs share %s error: %v", sharePath, err)
+		}
 		err = p.cli.DeleteNfsShare(ctx, shareID, accountId)
 		if err != nil {
 			log.AddContext(ctx).Errorf("Delete nfs share %s error: %v", shareID, err)
 			return err
 		}
 
-		fsID := share["file_system_id"].(string)
+		fsID, err := utils.GetStringField(share, "file_system_id")
+		if err != nil {
+			return utils.Errorf(ctx, "Get filesystem of nfs share %s error: %v", sharePath, err)
+		}
 		err = p.deleteQuota(ctx, fsID)
 		if err != nil {
 			log.AddContext(ctx).Errorf("Delete filesystem %s quota error: %v", fsID, err)
@@ -70,7 +70,11 @@ func (p *SAN) preCreate(ctx context.Context, params map[string]interface{}) erro
 			return fmt.Errorf("Storage pool %s doesn't exist", v)
 		}
 
-		params["poolId"] = int64(pool["poolId"].(float64))
+		poolID, err := utils.GetInt64Field(pool, "poolId")
+		if err != nil {
+			return utils.Errorf(ctx, "Get ID of storage pool %s error: %v", v, err)
+		}
+		params["poolId"] = poolID
 	}
 
 	if v, exist := params["sourcevolumename"].(string); exist && v != "" {
@@ -139,6 +143,15 @@ func (p *SAN) createLun(ctx context.Context,
 		} else {
 			err = p.cli.CreateVolume(ctx, params)
 		}
+	} else if capacity, ok := params["capacity"].(int64); ok {
+		volSize, err := utils.GetInt64Field(vol, "volSize")
+		if err != nil {
+			return nil, utils.Errorf(ctx, "Get size of LUN %s error: %v", name, err)
+		}
+		if volSize > capacity {
+			return nil, utils.VolumeConflictf(ctx, "LUN %s already exists with capacity %d, but %d is requested",
+				name, volSize, capacity)
+		}
 	}
 
 	if err != nil {
@@ -160,13 +173,16 @@ func (p *SAN) clone(ctx context.Context, params map[string]interface{}) error {
 		return err
 	}
 	if srcVol == nil {
-		msg := fmt.Sprintf("Clone src vol %s does not exist", cloneFrom)
-		log.AddContext(ctx).Errorln(msg)
-		return errors.New(msg)
+		return utils.KindErrorf(ctx, utils.ErrNotFound, "Clone src vol %s does not exist", cloneFrom)
+	}
+
+	srcVolSize, err := utils.GetInt64Field(srcVol, "volSize")
+	if err != nil {
+		return utils.Errorf(ctx, "Get size of clone src vol %s error: %v", cloneFrom, err)
 	}
 
 	volCapacity := params["capacity"].(int64)
-	if volCapacity < int64(srcVol["volSize"].(float64)) {
+	if volCapacity < srcVolSize {
 		msg := fmt.Sprintf("Clone vol capacity must be >= src %s", cloneFrom)
 		log.AddContext(ctx).Errorln(msg)
 		return errors.New(msg)
@@ -204,13 +220,16 @@ func (p *SAN) createFromSnapshot(ctx context.Context, params map[string]interfac
 		return err
 	}
 	if srcSnapshot == nil {
-		msg := fmt.Sprintf("Src snapshot %s does not exist", srcSnapshotName)
-		log.AddContext(ctx).Errorln(msg)
-		return errors.New(msg)
+		return utils.KindErrorf(ctx, utils.ErrNotFound, "Src snapshot %s does not exist", srcSnapshotName)
+	}
+
+	srcSnapshotSize, err := utils.GetInt64Field(srcSnapshot, "snapshotSize")
+	if err != nil {
+		return utils.Errorf(ctx, "Get size of src snapshot %s error: %v", srcSnapshotName, err)
 	}
 
 	volCapacity := params["capacity"].(int64)
-	if volCapacity < int64(srcSnapshot["snapshotSize"].(float64)) {
+	if volCapacity < srcSnapshotSize {
 		msg := fmt.Sprintf("Clone vol capacity must be >= src snapshot %s", srcSnapshotName)
 		log.AddContext(ctx).Errorln(msg)
 		return errors.New(msg)
@@ -284,6 +303,26 @@ func (p *SAN) Delete(ctx context.Context, name string) error {
 	return p.cli.DeleteVolume(ctx, name)
 }
 
+// UpdateQoS sets the QoS limits of the volume
+func (p *SAN) UpdateQoS(ctx context.Context, name string, qos map[string]int) error {
+	vol, err := p.cli.GetVolumeByName(ctx, name)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get volume by name %s error: %v", name, err)
+		return err
+	}
+	if vol == nil {
+		return utils.KindErrorf(ctx, utils.ErrNotFound, "Volume %s to update QoS does not exist", name)
+	}
+
+	smartQos := smartx.NewQoS(p.cli)
+	err = smartQos.UpdateQoS(ctx, name, qos)
+	if err != nil {
+		return utils.Errorf(ctx, "Update qos %v of volume %s error: %v", qos, name, err)
+	}
+
+	return nil
+}
+
 func (p *SAN) Expand(ctx context.Context, name string, newSize int64) (bool, error) {
 	lun, err := p.cli.GetVolumeByName(ctx, name)
 	if err != nil {
@@ -291,15 +330,25 @@ func (p *SAN) Expand(ctx context.Context, name string, newSize int64) (bool, err
 		return false, err
 	}
 	if lun == nil {
-		msg := fmt.Sprintf("Lun %s to expand does not exist", name)
-		log.AddContext(ctx).Errorf(msg)
-		return false, errors.New(msg)
+		return false, utils.KindErrorf(ctx, utils.ErrNotFound, "Lun %s to expand does not exist", name)
 	}
 
-	isAttached := int64(lun["volType"].(float64)) == SCSITYPE || int64(lun["volType"].(float64)) == ISCSITYPE
-	curSize := int64(lun["volSize"].(float64))
-	if newSize <= curSize {
-		msg := fmt.Sprintf("Lun %s newSize %d must be greater than curSize %d", name, newSize, curSize)
+	volType, err := utils.GetInt64Field(lun, "volType")
+	if err != nil {
+		return false, utils.Errorf(ctx, "Get type of lun %s error: %v", name, err)
+	}
+	curSize, err := utils.GetInt64Field(lun, "volSize")
+	if err != nil {
+		return false, utils.Errorf(ctx, "Get size of lun %s error: %v", name, err)
+	}
+	poolID, err := utils.GetInt64Field(lun, "poolId")
+	if err != nil {
+		return false, utils.Errorf(ctx, "Get pool of lun %s error: %v", name, err)
+	}
+
+	isAttached := volType == SCSITYPE || volType == ISCSITYPE
+	if newSize < curSize {
+		msg := fmt.Sprintf("Lun %s newSize %d must not be less than curSize %d", name, newSize, curSize)
 		log.AddContext(ctx).Errorln(msg)
 		return false, errors.New(msg)
 	}
@@ -309,10 +358,10 @@ func (p *SAN) Expand(ctx context.Context, name string, newSize int64) (bool, err
 	expandTask.AddTask("Expand-Local-Lun", p.expandLocalLun, nil)
 
 	params := map[string]interface{}{
-		"lunName":       lun["volName"].(string),
+		"lunName":       name,
 		"size":          newSize,
 		"expandSize":    newSize - curSize,
-		"localParentId": int64(lun["poolId"].(float64)),
+		"localParentId": poolID,
 	}
 	_, err = expandTask.Run(params)
 	return isAttached, err
@@ -335,6 +384,10 @@ func (p *SAN) expandLocalLun(ctx context.Context,
 	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
 	lunName := params["lunName"].(string)
 	newSize := params["size"].(int64)
+	if params["expandSize"].(int64) == 0 {
+		log.AddContext(ctx).Infof("Volume %s is already %d MiB", lunName, newSize)
+		return nil, nil
+	}
 
 	err := p.cli.ExtendVolume(ctx, lunName, newSize)
 	if err != nil {
@@ -352,9 +405,7 @@ func (p *SAN) CreateSnapshot(ctx context.Context,
 		log.AddContext(ctx).Errorf("Get lun by name %s error: %v", lunName, err)
 		return nil, err
 	} else if lun == nil {
-		msg := fmt.Sprintf("Create snapshot from Lun %s does not exist", lunName)
-		log.AddContext(ctx).Errorln(msg)
-		return nil, errors.New(msg)
+		return nil, utils.KindErrorf(ctx, utils.ErrNotFound, "Create snapshot from Lun %s does not exist", lunName)
 	}
 
 	snapshot, err := p.cli.GetSnapshotByName(ctx, snapshotName)
@@ -364,19 +415,13 @@ func (p *SAN) CreateSnapshot(ctx context.Context,
 	}
 
 	if snapshot != nil {
-		if snapshot["fatherName"].(string) != lunName {
+		if snapshot["fatherName"] != lunName {
 			msg := fmt.Sprintf("Snapshot %s is already exist, but the parent LUN %s is incompatible",
 				snapshotName, lunName)
 			log.AddContext(ctx).Errorln(msg)
 			return nil, errors.New(msg)
 		} else {
-			snapshotCreated, _ := strconv.ParseInt(snapshot["createTime"].(string), 10, 64)
-			snapshotSize := int64(snapshot["snapshotSize"].(float64)) * 1024 * 1024
-			return map[string]interface{}{
-				"CreationTime": snapshotCreated,
-				"SizeBytes":    snapshotSize,
-				"ParentID":     strconv.FormatInt(int64(lun["volId"].(float64)), 10),
-			}, nil
+			return getSnapshotInfo(ctx, lun, snapshot)
 		}
 	}
 
@@ -398,12 +443,29 @@ func (p *SAN) CreateSnapshot(ctx context.Context,
 		return nil, err
 	}
 
-	snapshotCreated, _ := strconv.ParseInt(snapshot["createTime"].(string), 10, 64)
-	snapshotSize := int64(snapshot["snapshotSize"].(float64)) * 1024 * 1024
+	if snapshot == nil {
+		return nil, utils.Errorf(ctx, "Snapshot %s does not exist after created", snapshotName)
+	}
+
+	return getSnapshotInfo(ctx, lun, snapshot)
+}
+
+func getSnapshotInfo(ctx context.Context, lun, snapshot map[string]interface{}) (map[string]interface{}, error) {
+	snapshotSize, err := utils.GetInt64Field(snapshot, "snapshotSize")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get size of snapshot error: %v", err)
+	}
+	lunID, err := utils.GetInt64Field(lun, "volId")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of lun error: %v", err)
+	}
+
+	createTime, _ := snapshot["createTime"].(string)
+	snapshotCreated, _ := strconv.ParseInt(createTime, 10, 64)
 	return map[string]interface{}{
 		"CreationTime": snapshotCreated,
-		"SizeBytes":    snapshotSize,
-		"ParentID":     strconv.FormatInt(int64(lun["volId"].(float64)), 10),
+		"SizeBytes":    snapshotSize * 1024 * 1024,
+		"ParentID":     strconv.FormatInt(lunID, 10),
 	}, nil
 }
 
@@ -428,6 +490,44 @@ func (p *SAN) DeleteSnapshot(ctx context.Context, snapshotName string) error {
 	return nil
 }
 
+// QuerySnapshot returns the snapshot of the parent volume in the format of CreateSnapshot,
+// nil if the volume has no snapshot of the name
+func (p *SAN) QuerySnapshot(ctx context.Context,
+	parentID, snapshotName string) (map[string]interface{}, error) {
+	snapshot, err := p.cli.GetSnapshotByName(ctx, snapshotName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun snapshot by name %s error: %v", snapshotName, err)
+		return nil, err
+	}
+	if snapshot == nil {
+		return nil, nil
+	}
+
+	parentName, _ := snapshot["fatherName"].(string)
+	lun, err := p.cli.GetVolumeByName(ctx, parentName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun by name %s error: %v", parentName, err)
+		return nil, err
+	}
+	if lun == nil {
+		return nil, nil
+	}
+
+	volID, _ := lun["volId"].(float64)
+	if strconv.FormatInt(int64(volID), 10) != parentID {
+		return nil, nil
+	}
+
+	snapshotCreated, _ := strconv.ParseInt(snapshot["createTime"].(string), 10, 64)
+	snapshotSize, _ := snapshot["snapshotSize"].(float64)
+	return map[string]interface{}{
+		"CreationTime": snapshotCreated,
+		"SizeBytes":    int64(snapshotSize) * 1024 * 1024,
+		"ParentID":     parentID,
+		"ParentName":   parentName,
+	}, nil
+}
+
 func (p *SAN) createSnapshot(ctx context.Context,
 	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
 	lunName := params["lunName"].(string)
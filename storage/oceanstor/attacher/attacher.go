@@ -34,6 +34,13 @@ import (
 const (
 	hostGroupType = 14
 	lunGroupType  = 256
+
+	// metroPathOptimizedKey is the parameter telling whether the paths of a HyperMetro volume to the storage
+	// site are optimized for the node
+	metroPathOptimizedKey = "metroPathOptimized"
+	// recommendedAluaKey is the parameter telling to use the ALUA recommended for the multipath software
+	// of the node if no ALUA is configured for it
+	recommendedAluaKey = "recommendedALUA"
 )
 
 type AttacherPlugin interface {
@@ -41,16 +48,27 @@ type AttacherPlugin interface {
 	ControllerDetach(context.Context, string, map[string]interface{}) (string, error)
 	NodeStage(context.Context, string, map[string]interface{}) (*connector.ConnectInfo, error)
 	NodeUnstage(context.Context, string, map[string]interface{}) (*connector.DisConnectInfo, error)
+	GetOtherMappedHosts(context.Context, string, map[string]interface{}) ([]string, error)
 	getTargetRoCEPortals(context.Context) ([]string, error)
 	getLunInfo(context.Context, string) (map[string]interface{}, error)
 }
 
+// CHAP is the CHAP credentials the iSCSI initiators log in with, the mutual ones authenticate the
+// storage to the initiators as well
+type CHAP struct {
+	User           string
+	Password       string
+	MutualUser     string
+	MutualPassword string
+}
+
 type Attacher struct {
 	cli      client.BaseClientInterface
 	protocol string
 	invoker  string
 	portals  []string
 	alua     map[string]interface{}
+	chap     *CHAP
 }
 
 func NewAttacher(
@@ -58,12 +76,13 @@ func NewAttacher(
 	cli client.BaseClientInterface,
 	protocol, invoker string,
 	portals []string,
-	alua map[string]interface{}) AttacherPlugin {
+	alua map[string]interface{},
+	chap *CHAP) AttacherPlugin {
 	switch product {
 	case "DoradoV6":
-		return newDoradoV6Attacher(cli, protocol, invoker, portals, alua)
+		return newDoradoV6Attacher(cli, protocol, invoker, portals, alua, chap)
 	default:
-		return newOceanStorAttacher(cli, protocol, invoker, portals, alua)
+		return newOceanStorAttacher(cli, protocol, invoker, portals, alua, chap)
 	}
 }
 
@@ -116,6 +135,10 @@ func (p *Attacher) getHost(ctx context.Context,
 		}
 	}
 
+	if host != nil && toCreate {
+		p.updateHostDescription(ctx, host, hostname)
+	}
+
 	if host != nil {
 		return host, nil
 	}
@@ -127,6 +150,86 @@ func (p *Attacher) getHost(ctx context.Context,
 	return nil, nil
 }
 
+// updateHostDescription records the hostname of the node in the description of the host, as the name of
+// the host is truncated to 31 characters. Only a host without description is updated, so that the
+// description an administrator gives a host is kept. The attach goes on if the update fails, as the node
+// of the host is then told by its name.
+func (p *Attacher) updateHostDescription(ctx context.Context, host map[string]interface{}, hostname string) {
+	if description, _ := host["DESCRIPTION"].(string); description != "" {
+		return
+	}
+
+	hostID, err := utils.GetStringField(host, "ID")
+	if err != nil {
+		log.AddContext(ctx).Warningf("Get ID of host %v error: %v", host["NAME"], err)
+		return
+	}
+
+	err = p.cli.UpdateHost(ctx, hostID, map[string]interface{}{"DESCRIPTION": hostname})
+	if err != nil {
+		log.AddContext(ctx).Warningf("Update description of host %s error: %v", hostID, err)
+		return
+	}
+
+	host["DESCRIPTION"] = hostname
+}
+
+// getNodeName returns the hostname of the node of the host, which is recorded in the description of the
+// host. A description the name of the host isn't derived from is not a hostname and is ignored. The name
+// of a host is used if it isn't truncated, otherwise the name is returned as it is and matches no node.
+func (p *Attacher) getNodeName(host map[string]interface{}) (string, error) {
+	hostName, err := utils.GetStringField(host, "NAME")
+	if err != nil {
+		return "", err
+	}
+
+	if description, _ := host["DESCRIPTION"].(string); description != "" && p.getHostName(description) == hostName {
+		return description, nil
+	}
+
+	if len(hostName) >= 31 {
+		return hostName, nil
+	}
+	return strings.TrimPrefix(hostName, p.getHostName("")), nil
+}
+
+// getHostAlua returns the ALUA configured for the host, or the one recommended for the multipath software
+// of the node by the function if no ALUA is configured and the recommended one is enabled
+func (p *Attacher) getHostAlua(ctx context.Context, hostName string, parameters map[string]interface{},
+	recommended func(parameters map[string]interface{}) map[string]interface{}) map[string]interface{} {
+	if hostAlua := utils.GetAlua(ctx, p.alua, hostName); hostAlua != nil {
+		return hostAlua
+	}
+
+	if enabled, _ := parameters[recommendedAluaKey].(bool); !enabled {
+		return nil
+	}
+
+	hostAlua := recommended(parameters)
+	if hostAlua != nil {
+		log.AddContext(ctx).Infof("Use the recommended ALUA %v for host %s", hostAlua, hostName)
+	}
+	return hostAlua
+}
+
+// updateHostOSType sets the operating system of the host configured in the ALUA, the array adapts the
+// SCSI behaviours of the host to it
+func (p *Attacher) updateHostOSType(ctx context.Context, host, hostAlua map[string]interface{}) error {
+	osType, exist := hostAlua["OPERATIONSYSTEM"]
+	if !exist || osType == host["OPERATIONSYSTEM"] {
+		return nil
+	}
+
+	hostID, err := utils.GetStringField(host, "ID")
+	if err != nil {
+		return err
+	}
+
+	log.AddContext(ctx).Infof("Update the operating system of host %s from %v to %v", hostID,
+		host["OPERATIONSYSTEM"], osType)
+	return p.cli.UpdateHost(ctx, hostID, map[string]interface{}{"OPERATIONSYSTEM": osType})
+}
+
 func (p *Attacher) createMapping(ctx context.Context, hostID string) (string, error) {
 	mappingName := p.getMappingName(hostID)
 	mapping, err := p.cli.GetMappingByName(ctx, mappingName)
@@ -142,7 +245,7 @@ func (p *Attacher) createMapping(ctx context.Context, hostID string) (string, er
 		}
 	}
 
-	return mapping["ID"].(string), nil
+	return utils.GetStringField(mapping, "ID")
 }
 
 func (p *Attacher) createHostGroup(ctx context.Context, hostID, mappingID string) error {
@@ -160,9 +263,15 @@ func (p *Attacher) createHostGroup(ctx context.Context, hostID, mappingID string
 	hostGroupName := p.getHostGroupName(hostID)
 
 	for _, i := range hostGroupsByHostID {
-		group := i.(map[string]interface{})
-		if group["NAME"].(string) == hostGroupName {
-			hostGroupID = group["ID"].(string)
+		group, err := utils.ToObject(i)
+		if err != nil {
+			return err
+		}
+		if group["NAME"] == hostGroupName {
+			hostGroupID, err = utils.GetStringField(group, "ID")
+			if err != nil {
+				return err
+			}
 			return p.addToHostGroupMapping(ctx, hostGroupName, hostGroupID, mappingID)
 		}
 	}
@@ -180,7 +289,10 @@ func (p *Attacher) createHostGroup(ctx context.Context, hostID, mappingID string
 		}
 	}
 
-	hostGroupID = hostGroup["ID"].(string)
+	hostGroupID, err = utils.GetStringField(hostGroup, "ID")
+	if err != nil {
+		return err
+	}
 
 	err = p.cli.AddHostToGroup(ctx, hostID, hostGroupID)
 	if err != nil {
@@ -204,7 +316,7 @@ func (p *Attacher) addToHostGroupMapping(ctx context.Context, groupName, groupID
 		if !ok {
 			return fmt.Errorf("invalid group type. Expected 'map[string]interface{}', found %T", i)
 		}
-		if group["NAME"].(string) == groupName {
+		if group["NAME"] == groupName {
 			return nil
 		}
 	}
@@ -232,9 +344,15 @@ func (p *Attacher) createLunGroup(ctx context.Context, lunID, hostID, mappingID
 
 	lunGroupName := p.getLunGroupName(hostID)
 	for _, i := range lunGroupsByLunID {
-		group := i.(map[string]interface{})
-		if group["NAME"].(string) == lunGroupName {
-			lunGroupID = group["ID"].(string)
+		group, err := utils.ToObject(i)
+		if err != nil {
+			return err
+		}
+		if group["NAME"] == lunGroupName {
+			lunGroupID, err = utils.GetStringField(group, "ID")
+			if err != nil {
+				return err
+			}
 			return p.addToLUNGroupMapping(ctx, lunGroupName, lunGroupID, mappingID)
 		}
 	}
@@ -252,7 +370,10 @@ func (p *Attacher) createLunGroup(ctx context.Context, lunID, hostID, mappingID
 		}
 	}
 
-	lunGroupID = lunGroup["ID"].(string)
+	lunGroupID, err = utils.GetStringField(lunGroup, "ID")
+	if err != nil {
+		return err
+	}
 	err = p.cli.AddLunToGroup(ctx, lunID, lunGroupID)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Add lun %s to group %s error: %v", lunID, lunGroupID, err)
@@ -274,7 +395,7 @@ func (p *Attacher) addToLUNGroupMapping(ctx context.Context, groupName, groupID,
 		if !ok {
 			return fmt.Errorf("invalid group type. Expected 'map[string]interface{}', found %T", i)
 		}
-		if group["NAME"].(string) == groupName {
+		if group["NAME"] == groupName {
 			return nil
 		}
 	}
@@ -346,14 +467,25 @@ func (p *Attacher) getISCSIProperties(ctx context.Context, wwn, hostLunId string
 		return nil, errors.New("key scsiMultiPathType does not exist in parameters")
 	}
 
-	return map[string]interface{}{
+	properties := map[string]interface{}{
 		"tgtPortals":         tgtPortals,
 		"tgtIQNs":            tgtIQNs,
 		"tgtHostLUNs":        tgtHostLUNs,
 		"tgtLunWWN":          wwn,
 		"volumeUseMultiPath": volumeUseMultiPath,
 		"multiPathType":      multiPathType,
-	}, nil
+		"storageInterfaces":  parameters["storageInterfaces"],
+	}
+
+	if p.chap != nil {
+		properties["authMethod"] = "CHAP"
+		properties["authUserName"] = p.chap.User
+		properties["authPassword"] = connector.SensitiveString(p.chap.Password)
+		properties["authUserNameIn"] = p.chap.MutualUser
+		properties["authPasswordIn"] = connector.SensitiveString(p.chap.MutualPassword)
+	}
+
+	return properties, nil
 }
 
 func (p *Attacher) getFCProperties(ctx context.Context, wwn, hostLunId string, parameters map[string]interface{}) (
@@ -435,6 +567,8 @@ func (p *Attacher) getRoCEProperties(ctx context.Context, wwn, hostLunId string,
 		"tgtLunGuid":         wwn,
 		"volumeUseMultiPath": volumeUseMultiPath,
 		"multiPathType":      multiPathType,
+		"storageInterfaces":  parameters["storageInterfaces"],
+		"nvmeTransport":      parameters["nvmeTransport"],
 	}, nil
 }
 
@@ -468,29 +602,44 @@ func (p *Attacher) getTargetISCSIProperties(ctx context.Context) ([]string, []st
 	validIPs := map[string]bool{}
 	validIQNs := map[string]string{}
 	for _, i := range ports {
-		port := i.(map[string]interface{})
-		portID := port["ID"].(string)
-		portIqn := strings.Split(strings.Split(portID, ",")[0], "+")[1]
-		splitIqn := strings.Split(portIqn, ":")
+		port, err := utils.ToObject(i)
+		if err != nil {
+			return nil, nil, err
+		}
+		portID, err := utils.GetStringField(port, "ID")
+		if err != nil {
+			return nil, nil, err
+		}
+		splitPortID := strings.Split(strings.Split(portID, ",")[0], "+")
+		if len(splitPortID) < 2 {
+			log.AddContext(ctx).Warningf("Unexpected iSCSI tgt port ID %s", portID)
+			continue
+		}
 
+		// The IP address is the last part of the IQN, an IPv6 address contains colons as well
+		portIqn := splitPortID[1]
+		splitIqn := strings.SplitN(portIqn, ":", 6)
 		if len(splitIqn) < 6 {
 			continue
 		}
 
-		validIPs[splitIqn[5]] = true
-		validIQNs[splitIqn[5]] = portIqn
+		ip := splitIqn[5]
+		if parsedIP := net.ParseIP(ip); parsedIP != nil {
+			ip = parsedIP.String()
+		}
+		validIPs[ip] = true
+		validIQNs[ip] = portIqn
 	}
 
 	var tgtPortals []string
 	var tgtIQNs []string
-	for _, portal := range p.portals {
-		ip := net.ParseIP(portal).String()
+	for _, ip := range proto.ResolvePortals(ctx, p.portals) {
 		if !validIPs[ip] {
 			log.AddContext(ctx).Warningf("ISCSI portal %s is not valid", ip)
 			continue
 		}
 
-		formatIP := fmt.Sprintf("%s:3260", ip)
+		formatIP := net.JoinHostPort(ip, "3260")
 		tgtPortals = append(tgtPortals, formatIP)
 		tgtIQNs = append(tgtIQNs, validIQNs[ip])
 	}
@@ -506,8 +655,7 @@ func (p *Attacher) getTargetISCSIProperties(ctx context.Context) ([]string, []st
 
 func (p *Attacher) getTargetRoCEPortals(ctx context.Context) ([]string, error) {
 	var availablePortals []string
-	for _, portal := range p.portals {
-		ip := net.ParseIP(portal).String()
+	for _, ip := range proto.ResolvePortals(ctx, p.portals) {
 		rocePortal, err := p.cli.GetRoCEPortalByIP(ctx, ip)
 		if err != nil {
 			log.AddContext(ctx).Errorf("Get RoCE tgt portal error: %v", err)
@@ -603,13 +751,28 @@ func (p *Attacher) getTargetFCProperties(ctx context.Context) ([]string, error)
 	return tgtWWNs, nil
 }
 
-func (p *Attacher) attachISCSI(ctx context.Context, hostID string) (map[string]interface{}, error) {
-	name, err := proto.GetISCSIInitiator(ctx)
+// attachISCSI adds all the iSCSI initiators of the node to the host
+func (p *Attacher) attachISCSI(ctx context.Context, hostID string) ([]map[string]interface{}, error) {
+	names, err := proto.GetISCSIInitiators(ctx)
 	if err != nil {
-		log.AddContext(ctx).Errorf("Get ISCSI initiator name error: %v", name)
+		log.AddContext(ctx).Errorf("Get ISCSI initiator names error: %v", err)
 		return nil, err
 	}
 
+	var initiators []map[string]interface{}
+	for _, name := range names {
+		initiator, err := p.attachISCSIInitiator(ctx, name, hostID)
+		if err != nil {
+			return nil, err
+		}
+		initiators = append(initiators, initiator)
+	}
+
+	return initiators, nil
+}
+
+func (p *Attacher) attachISCSIInitiator(ctx context.Context,
+	name, hostID string) (map[string]interface{}, error) {
 	initiator, err := p.cli.GetIscsiInitiator(ctx, name)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Get ISCSI initiator %s error: %v", name, err)
@@ -639,9 +802,35 @@ func (p *Attacher) attachISCSI(ctx context.Context, hostID string) (map[string]i
 		return nil, errors.New(msg)
 	}
 
+	err = p.checkInitiatorChap(ctx, name, initiator)
+	if err != nil {
+		return nil, err
+	}
+
 	return initiator, nil
 }
 
+// checkInitiatorChap enables the CHAP of the initiator if it is not enabled with the configured user.
+// The password cannot be read back from the storage, it is set whenever the CHAP is enabled.
+func (p *Attacher) checkInitiatorChap(ctx context.Context, name string, initiator map[string]interface{}) error {
+	if p.chap == nil {
+		return nil
+	}
+
+	if initiator["USECHAP"] == "true" && initiator["CHAPNAME"] == p.chap.User {
+		return nil
+	}
+
+	err := p.cli.UpdateIscsiInitiatorChap(ctx, name, p.chap.User, p.chap.Password)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Enable CHAP of ISCSI initiator %s error: %v", name, err)
+		return err
+	}
+
+	log.AddContext(ctx).Infof("CHAP of ISCSI initiator %s is enabled with user %s", name, p.chap.User)
+	return nil
+}
+
 func (p *Attacher) attachFC(ctx context.Context, hostID string) ([]map[string]interface{}, error) {
 	fcInitiators, err := proto.GetFCInitiator(ctx)
 	if err != nil {
@@ -694,13 +883,28 @@ func (p *Attacher) attachFC(ctx context.Context, hostID string) ([]map[string]in
 	return hostInitiators, nil
 }
 
-func (p *Attacher) attachRoCE(ctx context.Context, hostID string) (map[string]interface{}, error) {
-	name, err := proto.GetRoCEInitiator(ctx)
+// attachRoCE adds all the host NQNs of the node to the host
+func (p *Attacher) attachRoCE(ctx context.Context, hostID string) ([]map[string]interface{}, error) {
+	names, err := proto.GetRoCEInitiators(ctx)
 	if err != nil {
-		log.AddContext(ctx).Errorf("Get RoCE initiator name error: %v", name)
+		log.AddContext(ctx).Errorf("Get RoCE initiator names error: %v", err)
 		return nil, err
 	}
 
+	var initiators []map[string]interface{}
+	for _, name := range names {
+		initiator, err := p.attachRoCEInitiator(ctx, name, hostID)
+		if err != nil {
+			return nil, err
+		}
+		initiators = append(initiators, initiator)
+	}
+
+	return initiators, nil
+}
+
+func (p *Attacher) attachRoCEInitiator(ctx context.Context,
+	name, hostID string) (map[string]interface{}, error) {
 	initiator, err := p.cli.GetRoCEInitiator(ctx, name)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Get RoCE initiator %s error: %v", name, err)
@@ -745,7 +949,10 @@ func (p *Attacher) doMapping(ctx context.Context, hostID, lunName string) (strin
 		return "", "", errors.New(msg)
 	}
 
-	lunID := lun["ID"].(string)
+	lunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return "", "", err
+	}
 
 	mappingID, err := p.createMapping(ctx, hostID)
 	if err != nil {
@@ -789,7 +996,10 @@ func (p *Attacher) doUnmapping(ctx context.Context, hostID, lunName string) (str
 		return "", nil
 	}
 
-	lunID := lun["ID"].(string)
+	lunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return "", err
+	}
 
 	lunGroupsByLunID, err := p.cli.QueryAssociateLunGroup(ctx, 11, lunID)
 	if err != nil {
@@ -800,9 +1010,15 @@ func (p *Attacher) doUnmapping(ctx context.Context, hostID, lunName string) (str
 	lunGroupName := p.getLunGroupName(hostID)
 
 	for _, i := range lunGroupsByLunID {
-		group := i.(map[string]interface{})
-		if group["NAME"].(string) == lunGroupName {
-			lunGroupID := group["ID"].(string)
+		group, err := utils.ToObject(i)
+		if err != nil {
+			return "", err
+		}
+		if group["NAME"] == lunGroupName {
+			lunGroupID, err := utils.GetStringField(group, "ID")
+			if err != nil {
+				return "", err
+			}
 			err = p.cli.RemoveLunFromGroup(ctx, lunID, lunGroupID)
 			if err != nil {
 				log.AddContext(ctx).Errorf("Remove lun %s from group %s error: %v",
@@ -819,6 +1035,70 @@ func (p *Attacher) doUnmapping(ctx context.Context, hostID, lunName string) (str
 	return lunUniqueId, nil
 }
 
+// GetOtherMappedHosts returns the hostnames of the nodes other than the given node, which the LUN is
+// mapped to by the lun groups of this invoker
+func (p *Attacher) GetOtherMappedHosts(ctx context.Context,
+	lunName string,
+	parameters map[string]interface{}) ([]string, error) {
+	lun, err := p.cli.GetLunByName(ctx, lunName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun %s info error: %v", lunName, err)
+		return nil, err
+	}
+	if lun == nil {
+		return nil, nil
+	}
+
+	lunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return nil, err
+	}
+
+	lunGroupsByLunID, err := p.cli.QueryAssociateLunGroup(ctx, 11, lunID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Query associated lungroups of lun %s error: %v", lunID, err)
+		return nil, err
+	}
+
+	var selfHostID string
+	selfHost, err := p.getHost(ctx, parameters, false)
+	if err != nil {
+		return nil, err
+	}
+	if selfHost != nil {
+		selfHostID, _ = selfHost["ID"].(string)
+	}
+
+	lunGroupPrefix := p.getLunGroupName("")
+	var hostNames []string
+	for _, i := range lunGroupsByLunID {
+		group, err := utils.ToObject(i)
+		if err != nil {
+			return nil, err
+		}
+
+		groupName, _ := group["NAME"].(string)
+		hostID := strings.TrimPrefix(groupName, lunGroupPrefix)
+		if !strings.HasPrefix(groupName, lunGroupPrefix) || hostID == selfHostID {
+			continue
+		}
+
+		host, err := p.cli.GetHostByID(ctx, hostID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Get host %s of lungroup %s error: %v", hostID, groupName, err)
+			return nil, err
+		}
+
+		nodeName, err := p.getNodeName(host)
+		if err != nil {
+			return nil, err
+		}
+		hostNames = append(hostNames, nodeName)
+	}
+
+	return hostNames, nil
+}
+
 func (p *Attacher) NodeUnstage(ctx context.Context,
 	lunName string,
 	_ map[string]interface{}) (*connector.DisConnectInfo, error) {
@@ -848,7 +1128,10 @@ func (p *Attacher) ControllerDetach(ctx context.Context,
 		return "", nil
 	}
 
-	hostID := host["ID"].(string)
+	hostID, err := utils.GetStringField(host, "ID")
+	if err != nil {
+		return "", err
+	}
 	wwn, err := p.doUnmapping(ctx, hostID, lunName)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Unmapping LUN %s from host %s error: %v", lunName, hostID, err)
@@ -0,0 +1,144 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package attacher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"huawei-csi-driver/storage/oceanstor/client"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	logName = "attacherTest.log"
+	logDir  = "/var/log/huawei"
+)
+
+// fakeHostClient keeps the hosts on the array, calling any other client method panics
+type fakeHostClient struct {
+	client.BaseClientInterface
+	hosts     map[string]map[string]interface{}
+	updated   map[string]map[string]interface{}
+	updateErr error
+}
+
+func (c *fakeHostClient) GetHostByName(_ context.Context, name string) (map[string]interface{}, error) {
+	return c.hosts[name], nil
+}
+
+func (c *fakeHostClient) CreateHost(_ context.Context, name string) (map[string]interface{}, error) {
+	c.hosts[name] = map[string]interface{}{"ID": "2", "NAME": name}
+	return c.hosts[name], nil
+}
+
+func (c *fakeHostClient) UpdateHost(_ context.Context, id string, data map[string]interface{}) error {
+	if c.updateErr != nil {
+		return c.updateErr
+	}
+	c.updated[id] = data
+	return nil
+}
+
+func TestGetHostDescription(t *testing.T) {
+	hostname := "node-with-a-hostname-longer-than-the-host-name"
+	hostName := "k8s_node-with-a-hostname-longer"
+
+	tests := []struct {
+		name              string
+		host              map[string]interface{}
+		updateErr         error
+		expectDescription string
+		expectUpdated     bool
+		expectNodeName    string
+	}{
+		{"Created", nil, nil, hostname, true, hostname},
+		{"No description", map[string]interface{}{"ID": "1", "NAME": hostName}, nil, hostname, true, hostname},
+		{"Recorded", map[string]interface{}{"ID": "1", "NAME": hostName, "DESCRIPTION": hostname}, nil,
+			hostname, false, hostname},
+		{"Described by administrator", map[string]interface{}{"ID": "1", "NAME": hostName,
+			"DESCRIPTION": "rack 3"}, nil, "rack 3", false, hostName},
+		{"Update failed", map[string]interface{}{"ID": "1", "NAME": hostName}, errors.New("timeout"), "", false,
+			hostName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := &fakeHostClient{hosts: map[string]map[string]interface{}{},
+				updated: map[string]map[string]interface{}{}, updateErr: tt.updateErr}
+			if tt.host != nil {
+				cli.hosts[hostName] = tt.host
+			}
+			p := &Attacher{cli: cli}
+
+			host, err := p.getHost(context.Background(), map[string]interface{}{"HostName": hostname}, true)
+			require.NoError(t, err)
+			require.NotNil(t, host)
+			description, _ := host["DESCRIPTION"].(string)
+			assert.Equal(t, tt.expectDescription, description)
+			assert.Equal(t, tt.expectUpdated, len(cli.updated) == 1)
+
+			nodeName, err := p.getNodeName(host)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectNodeName, nodeName)
+		})
+	}
+}
+
+func TestGetNodeName(t *testing.T) {
+	tests := []struct {
+		name   string
+		host   map[string]interface{}
+		expect string
+	}{
+		{"Recorded hostname", map[string]interface{}{"NAME": "k8s_node-1", "DESCRIPTION": "node-1"}, "node-1"},
+		{"Description of administrator", map[string]interface{}{"NAME": "k8s_node-1", "DESCRIPTION": "rack 3"},
+			"node-1"},
+		{"No description", map[string]interface{}{"NAME": "k8s_node-1"}, "node-1"},
+	}
+
+	p := &Attacher{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeName, err := p.getNodeName(tt.host)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, nodeName)
+		})
+	}
+}
+
+func TestMain(m *testing.M) {
+	if err := log.InitLogging(logName); err != nil {
+		log.Errorf("Init logging: %s failed. error: %v", logName, err)
+		os.Exit(1)
+	}
+
+	logFile := path.Join(logDir, logName)
+	defer func() {
+		if err := os.RemoveAll(logFile); err != nil {
+			log.Errorf("Remove file: %s failed. error: %s", logFile, err)
+		}
+	}()
+
+	m.Run()
+}
@@ -30,14 +30,16 @@ type DoradoV6Attacher struct {
 }
 
 const (
-	ACCESS_MODE_BALANCED = "0"
+	ACCESS_MODE_BALANCED   = "0"
+	ACCESS_MODE_ASYMMETRIC = "1"
 )
 
 func newDoradoV6Attacher(
 	cli client.BaseClientInterface,
 	protocol, invoker string,
 	portals []string,
-	alua map[string]interface{}) AttacherPlugin {
+	alua map[string]interface{},
+	chap *CHAP) AttacherPlugin {
 	return &DoradoV6Attacher{
 		Attacher: Attacher{
 			cli:      cli,
@@ -45,6 +47,7 @@ func newDoradoV6Attacher(
 			invoker:  invoker,
 			portals:  portals,
 			alua:     alua,
+			chap:     chap,
 		},
 	}
 }
@@ -69,6 +72,37 @@ func (p *DoradoV6Attacher) needUpdateHost(host map[string]interface{}, hostAlua
 	return false
 }
 
+// recommendedHostAlua returns the host access mode recommended for the multipath software of the node,
+// DM-multipath needs the asymmetric mode to tell the optimized paths, while UltraPath balances the paths
+func recommendedHostAlua(parameters map[string]interface{}) map[string]interface{} {
+	multiPathType, _ := parameters["scsiMultiPathType"].(string)
+	switch multiPathType {
+	case connector.DMMultiPath:
+		return map[string]interface{}{"accessMode": ACCESS_MODE_ASYMMETRIC, "hyperMetroPathOptimized": "1"}
+	case connector.HWUltraPath:
+		return map[string]interface{}{"accessMode": ACCESS_MODE_BALANCED}
+	default:
+		return nil
+	}
+}
+
+// withMetroPathPreference makes the host access the storage asymmetrically, with the paths optimized if
+// the node prefers this HyperMetro site. The ALUA is returned as is if no preference is set for the node.
+func withMetroPathPreference(hostAlua, parameters map[string]interface{}) map[string]interface{} {
+	optimized, exist := parameters[metroPathOptimizedKey].(bool)
+	if !exist {
+		return hostAlua
+	}
+
+	alua := make(map[string]interface{}, len(hostAlua)+2)
+	for k, v := range hostAlua {
+		alua[k] = v
+	}
+	alua["accessMode"] = ACCESS_MODE_ASYMMETRIC
+	alua["hyperMetroPathOptimized"] = map[bool]string{true: "1", false: "0"}[optimized]
+	return alua
+}
+
 func (p *DoradoV6Attacher) ControllerAttach(ctx context.Context,
 	lunName string,
 	parameters map[string]interface{}) (map[string]interface{}, error) {
@@ -78,8 +112,21 @@ func (p *DoradoV6Attacher) ControllerAttach(ctx context.Context,
 		return nil, err
 	}
 
-	hostID := host["ID"].(string)
-	hostAlua := utils.GetAlua(ctx, p.alua, host["NAME"].(string))
+	hostID, err := utils.GetStringField(host, "ID")
+	if err != nil {
+		return nil, err
+	}
+	hostName, err := utils.GetStringField(host, "NAME")
+	if err != nil {
+		return nil, err
+	}
+
+	hostAlua := withMetroPathPreference(p.getHostAlua(ctx, hostName, parameters, recommendedHostAlua), parameters)
+	err = p.updateHostOSType(ctx, host, hostAlua)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Update host %s error: %v", hostID, err)
+		return nil, err
+	}
 
 	if hostAlua != nil && p.needUpdateHost(host, hostAlua) {
 		err := p.cli.UpdateHost(ctx, hostID, hostAlua)
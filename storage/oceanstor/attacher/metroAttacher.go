@@ -19,6 +19,7 @@ package attacher
 import (
 	"context"
 	"errors"
+	"regexp"
 
 	"huawei-csi-driver/connector"
 	"huawei-csi-driver/utils"
@@ -29,6 +30,10 @@ type MetroAttacher struct {
 	localAttacher  AttacherPlugin
 	remoteAttacher AttacherPlugin
 	protocol       string
+	// localPreferredHosts and remotePreferredHosts are the regexps of the hostnames of the nodes at the
+	// local and remote storage sites, whose paths to the storage of their own site are optimized
+	localPreferredHosts  []string
+	remotePreferredHosts []string
 }
 
 func NewMetroAttacher(localAttacher, remoteAttacher AttacherPlugin, protocol string) *MetroAttacher {
@@ -39,6 +44,63 @@ func NewMetroAttacher(localAttacher, remoteAttacher AttacherPlugin, protocol str
 	}
 }
 
+// SetPathPreference sets the regexps of the hostnames of the nodes at the local and remote storage sites
+func (p *MetroAttacher) SetPathPreference(localHosts, remoteHosts []string) {
+	p.localPreferredHosts = localHosts
+	p.remotePreferredHosts = remoteHosts
+}
+
+func matchAnyHost(ctx context.Context, patterns []string, hostname string) bool {
+	for _, pattern := range patterns {
+		match, err := regexp.MatchString(pattern, hostname)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Regexp match error: %v", err)
+		} else if match {
+			return true
+		}
+	}
+	return false
+}
+
+// getPathParameters returns the parameters of attaching the volume on the local and remote storage sites,
+// which tell the attachers whether the paths to their site are optimized for the node
+func (p *MetroAttacher) getPathParameters(ctx context.Context,
+	parameters map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	if len(p.localPreferredHosts) == 0 && len(p.remotePreferredHosts) == 0 {
+		return parameters, parameters
+	}
+
+	hostname, exist := parameters["HostName"].(string)
+	if !exist {
+		var err error
+		hostname, err = utils.GetHostName(ctx)
+		if err != nil {
+			log.AddContext(ctx).Warningf("Get hostname error: %v, path preference is not set", err)
+			return parameters, parameters
+		}
+	}
+
+	var localOptimized bool
+	if matchAnyHost(ctx, p.localPreferredHosts, hostname) {
+		localOptimized = true
+	} else if !matchAnyHost(ctx, p.remotePreferredHosts, hostname) {
+		return parameters, parameters
+	}
+
+	log.AddContext(ctx).Infof("Node %s prefers the paths to the %s storage site", hostname,
+		map[bool]string{true: "local", false: "remote"}[localOptimized])
+	return withMetroPathOptimized(parameters, localOptimized), withMetroPathOptimized(parameters, !localOptimized)
+}
+
+func withMetroPathOptimized(parameters map[string]interface{}, optimized bool) map[string]interface{} {
+	newParameters := make(map[string]interface{}, len(parameters)+1)
+	for k, v := range parameters {
+		newParameters[k] = v
+	}
+	newParameters[metroPathOptimizedKey] = optimized
+	return newParameters
+}
+
 // NodeStage to do storage mapping and get the connector
 func (p *MetroAttacher) NodeStage(ctx context.Context,
 	lunName string,
@@ -96,13 +158,14 @@ func (p *MetroAttacher) mergeMappingInfo(ctx context.Context,
 func (p *MetroAttacher) ControllerAttach(ctx context.Context,
 	lunName string,
 	parameters map[string]interface{}) (map[string]interface{}, error) {
-	remoteMapping, err := p.remoteAttacher.ControllerAttach(ctx, lunName, parameters)
+	localParameters, remoteParameters := p.getPathParameters(ctx, parameters)
+	remoteMapping, err := p.remoteAttacher.ControllerAttach(ctx, lunName, remoteParameters)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Attach hypermetro remote volume %s error: %v", lunName, err)
 		return nil, err
 	}
 
-	localMapping, err := p.localAttacher.ControllerAttach(ctx, lunName, parameters)
+	localMapping, err := p.localAttacher.ControllerAttach(ctx, lunName, localParameters)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Attach hypermetro local volume %s error: %v", lunName, err)
 		return nil, err
@@ -129,6 +192,31 @@ func (p *MetroAttacher) ControllerDetach(ctx context.Context,
 	return p.mergeLunWWN(ctx, locLunWWN, rmtLunWWN)
 }
 
+// GetOtherMappedHosts returns the hostnames of the nodes other than the given node, which the LUN is
+// mapped to on either storage site
+func (p *MetroAttacher) GetOtherMappedHosts(ctx context.Context,
+	lunName string,
+	parameters map[string]interface{}) ([]string, error) {
+	rmtHosts, err := p.remoteAttacher.GetOtherMappedHosts(ctx, lunName, parameters)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get mapped hosts of hypermetro remote volume %s error: %v", lunName, err)
+		return nil, err
+	}
+
+	locHosts, err := p.localAttacher.GetOtherMappedHosts(ctx, lunName, parameters)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get mapped hosts of hypermetro local volume %s error: %v", lunName, err)
+		return nil, err
+	}
+
+	for _, host := range rmtHosts {
+		if !utils.IsContain(host, locHosts) {
+			locHosts = append(locHosts, host)
+		}
+	}
+	return locHosts, nil
+}
+
 func (p *MetroAttacher) mergeLunWWN(ctx context.Context, locLunWWN, rmtLunWWN string) (string, error) {
 	if rmtLunWWN == "" && locLunWWN == "" {
 		log.AddContext(ctx).Infoln("both storage site of HyperMetro are failed to get lun WWN")
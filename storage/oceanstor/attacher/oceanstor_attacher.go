@@ -30,7 +30,12 @@ type OceanStorAttacher struct {
 }
 
 const (
-	MULTIPATHTYPE_DEFAULT = "0"
+	MULTIPATHTYPE_DEFAULT     = "0"
+	MULTIPATHTYPE_THIRD_PARTY = "1"
+	FAILOVERMODE_COMMON_ALUA  = "1"
+	SPECIALMODETYPE_MODE0     = "0"
+	PATHTYPE_OPTIMIZED        = "0"
+	PATHTYPE_NON_OPTIMIZED    = "1"
 )
 
 func newOceanStorAttacher(
@@ -38,7 +43,8 @@ func newOceanStorAttacher(
 	protocol,
 	invoker string,
 	portals []string,
-	alua map[string]interface{}) AttacherPlugin {
+	alua map[string]interface{},
+	chap *CHAP) AttacherPlugin {
 	return &OceanStorAttacher{
 		Attacher: Attacher{
 			cli:      cli,
@@ -46,6 +52,7 @@ func newOceanStorAttacher(
 			invoker:  invoker,
 			portals:  portals,
 			alua:     alua,
+			chap:     chap,
 		},
 	}
 }
@@ -81,34 +88,95 @@ func (p *OceanStorAttacher) needUpdateInitiatorAlua(initiator map[string]interfa
 	return false
 }
 
-func (p *OceanStorAttacher) attachISCSI(ctx context.Context, hostID, hostName string) error {
-	iscsiInitiator, err := p.Attacher.attachISCSI(ctx, hostID)
+// recommendedInitiatorAlua returns the initiator ALUA recommended for the multipath software of the node,
+// DM-multipath needs the common ALUA to tell the optimized paths, while UltraPath uses the default mode
+func recommendedInitiatorAlua(parameters map[string]interface{}) map[string]interface{} {
+	multiPathType, _ := parameters["scsiMultiPathType"].(string)
+	switch multiPathType {
+	case connector.DMMultiPath:
+		return map[string]interface{}{
+			"MULTIPATHTYPE":   MULTIPATHTYPE_THIRD_PARTY,
+			"FAILOVERMODE":    FAILOVERMODE_COMMON_ALUA,
+			"SPECIALMODETYPE": SPECIALMODETYPE_MODE0,
+			"PATHTYPE":        PATHTYPE_OPTIMIZED,
+		}
+	case connector.HWUltraPath:
+		return map[string]interface{}{"MULTIPATHTYPE": MULTIPATHTYPE_DEFAULT}
+	default:
+		return nil
+	}
+}
+
+// withInitiatorPathPreference makes the initiators use ALUA, with the paths optimized if the node prefers
+// this HyperMetro site. The ALUA is returned as is if no preference is set for the node.
+func withInitiatorPathPreference(hostAlua, parameters map[string]interface{}) map[string]interface{} {
+	optimized, exist := parameters[metroPathOptimizedKey].(bool)
+	if !exist {
+		return hostAlua
+	}
+
+	alua := make(map[string]interface{}, len(hostAlua)+3)
+	for k, v := range hostAlua {
+		alua[k] = v
+	}
+	alua["MULTIPATHTYPE"] = MULTIPATHTYPE_THIRD_PARTY
+	if _, exist := alua["FAILOVERMODE"]; !exist {
+		alua["FAILOVERMODE"] = FAILOVERMODE_COMMON_ALUA
+	}
+	alua["PATHTYPE"] = PATHTYPE_NON_OPTIMIZED
+	if optimized {
+		alua["PATHTYPE"] = PATHTYPE_OPTIMIZED
+	}
+	return alua
+}
+
+func (p *OceanStorAttacher) attachISCSI(ctx context.Context, hostID string,
+	hostAlua map[string]interface{}) error {
+	iscsiInitiators, err := p.Attacher.attachISCSI(ctx, hostID)
 	if err != nil {
 		return err
 	}
 
-	hostAlua := utils.GetAlua(ctx, p.alua, hostName)
-	if hostAlua != nil && p.needUpdateInitiatorAlua(iscsiInitiator, hostAlua) {
-		err = p.cli.UpdateIscsiInitiator(ctx, iscsiInitiator["ID"].(string), hostAlua)
+	if hostAlua == nil {
+		return nil
 	}
 
-	return err
+	for _, i := range iscsiInitiators {
+		if !p.needUpdateInitiatorAlua(i, hostAlua) {
+			continue
+		}
+
+		initiatorID, err := utils.GetStringField(i, "ID")
+		if err != nil {
+			return err
+		}
+		err = p.cli.UpdateIscsiInitiator(ctx, initiatorID, hostAlua)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (p *OceanStorAttacher) attachFC(ctx context.Context, hostID, hostName string) error {
+func (p *OceanStorAttacher) attachFC(ctx context.Context, hostID string,
+	hostAlua map[string]interface{}) error {
 	fcInitiators, err := p.Attacher.attachFC(ctx, hostID)
 	if err != nil {
 		return err
 	}
 
-	hostAlua := utils.GetAlua(ctx, p.alua, hostName)
 	if hostAlua != nil {
 		for _, i := range fcInitiators {
 			if !p.needUpdateInitiatorAlua(i, hostAlua) {
 				continue
 			}
 
-			err := p.cli.UpdateFCInitiator(ctx, i["ID"].(string), hostAlua)
+			initiatorID, err := utils.GetStringField(i, "ID")
+			if err != nil {
+				return err
+			}
+			err = p.cli.UpdateFCInitiator(ctx, initiatorID, hostAlua)
 			if err != nil {
 				return err
 			}
@@ -133,13 +201,27 @@ func (p *OceanStorAttacher) ControllerAttach(ctx context.Context,
 		return nil, err
 	}
 
-	hostID := host["ID"].(string)
-	hostName := host["NAME"].(string)
+	hostID, err := utils.GetStringField(host, "ID")
+	if err != nil {
+		return nil, err
+	}
+	hostName, err := utils.GetStringField(host, "NAME")
+	if err != nil {
+		return nil, err
+	}
+
+	hostAlua := withInitiatorPathPreference(p.getHostAlua(ctx, hostName, parameters, recommendedInitiatorAlua),
+		parameters)
+	err = p.updateHostOSType(ctx, host, hostAlua)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Update host %s error: %v", hostID, err)
+		return nil, err
+	}
 
 	if p.protocol == "iscsi" {
-		err = p.attachISCSI(ctx, hostID, hostName)
+		err = p.attachISCSI(ctx, hostID, hostAlua)
 	} else if p.protocol == "fc" || p.protocol == "fc-nvme" {
-		err = p.attachFC(ctx, hostID, hostName)
+		err = p.attachFC(ctx, hostID, hostAlua)
 	} else if p.protocol == "roce" {
 		err = p.attachRoCE(ctx, hostID)
 	}
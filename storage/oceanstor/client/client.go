@@ -24,7 +24,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/http/cookiejar"
 	"regexp"
@@ -41,26 +40,37 @@ const (
 	MaxParallelCount     int = 1000
 	MinParallelCount     int = 20
 	GetInfoWaitInternal      = 10
+	// MaxLogResponseBytes is the max length of response body to be logged
+	MaxLogResponseBytes = 8 * 1024
 
 	description string = "Created from huawei-csi for Kubernetes"
+	// CSIDescription is the description of the objects created by the driver
+	CSIDescription = description
 )
 
 type BaseClientInterface interface {
 	ApplicationType
+	Cifs
 	Clone
+	DTree
+	Quota
 	FC
 	Filesystem
 	FSSnapshot
 	Host
+	HyperCDP
 	HyperMetro
 	Iscsi
 	Lun
 	LunCopy
 	LunSnapshot
 	Mapping
+	Migration
+	ProtectGroup
 	Qos
 	Replication
 	RoCE
+	SmartCache
 	System
 	VStore
 
@@ -89,6 +99,10 @@ var (
 			`/vstore_pair\?filter=ID`,
 			`/FsHyperMetroDomain\?RUNNINGSTATUS=0`,
 		},
+		// the CHAP passwords of the initiators
+		"PUT": {
+			`/iscsi_initiator/`,
+		},
 	}
 
 	debugLog = map[string]map[string]bool{
@@ -275,18 +289,16 @@ func (cli *BaseClient) BaseCall(ctx context.Context,
 
 	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.AddContext(ctx).Errorf("Read response data error: %v", err)
-		return r, err
-	}
+	// Decode the body as a stream rather than buffering it entirely, only a bounded
+	// prefix is kept for logging so that large list responses don't bloat memory
+	body := utils.NewLimitedBuffer(MaxLogResponseBytes)
+	err = json.NewDecoder(io.TeeReader(resp.Body, body)).Decode(&r)
 
 	log.FilteredLog(ctx, isFilterLog(method, url), utils.IsDebugLog(method, url, debugLog),
 		fmt.Sprintf("Response method: %s, Url: %s, body: %s", method, reqUrl, body))
 
-	err = json.Unmarshal(body, &r)
 	if err != nil {
-		log.AddContext(ctx).Errorf("json.Unmarshal data %s error: %v", body, err)
+		log.AddContext(ctx).Errorf("Decode response data %s error: %v", body, err)
 		return r, err
 	}
 
@@ -309,6 +321,31 @@ func (cli *BaseClient) Delete(ctx context.Context, url string, data map[string]i
 	return cli.Call(ctx, "DELETE", url, data)
 }
 
+// getObjectByName returns the first object of the query by name, nil if there is none
+func (cli *BaseClient) getObjectByName(ctx context.Context, url, kind, name string) (map[string]interface{}, error) {
+	resp, err := cli.Get(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return nil, fmt.Errorf("Get %s by name %s error: %d", kind, name, code)
+	}
+
+	if resp.Data == nil {
+		log.AddContext(ctx).Infof("The %s %s does not exist", kind, name)
+		return nil, nil
+	}
+
+	respData := resp.Data.([]interface{})
+	if len(respData) <= 0 {
+		return nil, nil
+	}
+
+	return respData[0].(map[string]interface{}), nil
+}
+
 func (cli *BaseClient) DuplicateClient() *BaseClient {
 	dup := *cli
 
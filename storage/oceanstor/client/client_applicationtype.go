@@ -23,9 +23,17 @@ import (
 	URL "net/url"
 )
 
+const (
+	// defaultWorkloadBlockSize is the IO size 8KB of the created application types, in the enum of BLOCKSIZE
+	// which is 1:4KB, 2:8KB, 3:16KB, 4:32KB and 5:64KB
+	defaultWorkloadBlockSize = 2
+)
+
 type ApplicationType interface {
 	// GetApplicationTypeByName used for get application type
 	GetApplicationTypeByName(ctx context.Context, appType string) (string, error)
+	// CreateApplicationType used for create application type with the default IO size and data reduction
+	CreateApplicationType(ctx context.Context, appType string) (string, error)
 }
 
 // GetApplicationTypeByName function to get the Application type ID to set the I/O size
@@ -67,3 +75,35 @@ func (cli *BaseClient) GetApplicationTypeByName(ctx context.Context, appType str
 	}
 	return result, nil
 }
+
+// CreateApplicationType used for create application type with the IO size of 8KB and the data reduction
+// enabled, which fit the general random workloads, and returns its ID
+func (cli *BaseClient) CreateApplicationType(ctx context.Context, appType string) (string, error) {
+	data := map[string]interface{}{
+		"NAME":           appType,
+		"BLOCKSIZE":      defaultWorkloadBlockSize,
+		"ENABLECOMPRESS": true,
+		"ENABLEDEDUP":    true,
+	}
+
+	resp, err := cli.Post(ctx, "/workload_type", data)
+	if err != nil {
+		return "", err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return "", fmt.Errorf("Create application type %s error: %d", appType, code)
+	}
+
+	respData, ok := resp.Data.(map[string]interface{})
+	if !ok {
+		return "", errors.New("application type response is not valid")
+	}
+
+	id, ok := respData["ID"].(string)
+	if !ok {
+		return "", fmt.Errorf("ID of application type %s is not valid", appType)
+	}
+	return id, nil
+}
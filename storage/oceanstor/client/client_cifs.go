@@ -0,0 +1,235 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	cifsShareNotExist     int64 = 1077939717
+	cifsShareAlreadyExist int64 = 1077939724
+)
+
+type Cifs interface {
+	// GetCifsShareByName used for get cifs share by name
+	GetCifsShareByName(ctx context.Context, name, vStoreID string) (map[string]interface{}, error)
+	// CreateCifsShare used for create cifs share
+	CreateCifsShare(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error)
+	// DeleteCifsShare used for delete cifs share by id
+	DeleteCifsShare(ctx context.Context, id, vStoreID string) error
+	// AllowCifsShareAccess used for allow cifs share access
+	AllowCifsShareAccess(ctx context.Context, req *AllowCifsShareAccessRequest) error
+	// GetCifsShareAccess used for get the access of the user to the cifs share
+	GetCifsShareAccess(ctx context.Context, parentID, name, vStoreID string) (map[string]interface{}, error)
+	// UpdateCifsShareAccess used for update the permission of the cifs share access
+	UpdateCifsShareAccess(ctx context.Context, accessID string, permission int, vStoreID string) error
+}
+
+// GetCifsShareByName used for get cifs share by name
+func (cli *BaseClient) GetCifsShareByName(ctx context.Context, name, vStoreID string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("/CIFSHARE?filter=NAME::%s&range=[0-100]", name)
+	var data = make(map[string]interface{})
+	if vStoreID != "" {
+		data["vstoreId"] = vStoreID
+	}
+
+	resp, err := cli.Get(ctx, url, data)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code == cifsShareNotExist {
+		log.AddContext(ctx).Infof("Cifs share %s does not exist", name)
+		return nil, nil
+	}
+	if code != 0 {
+		return nil, fmt.Errorf("Get cifs share %s error: %d", name, code)
+	}
+
+	if resp.Data == nil {
+		log.AddContext(ctx).Infof("Cifs share %s does not exist", name)
+		return nil, nil
+	}
+
+	respData := resp.Data.([]interface{})
+	if len(respData) == 0 {
+		log.AddContext(ctx).Infof("Cifs share %s does not exist", name)
+		return nil, nil
+	}
+
+	share := respData[0].(map[string]interface{})
+	return share, nil
+}
+
+// CreateCifsShare used for create cifs share
+func (cli *BaseClient) CreateCifsShare(ctx context.Context,
+	params map[string]interface{}) (map[string]interface{}, error) {
+	name := params["name"].(string)
+	data := map[string]interface{}{
+		"NAME":        name,
+		"SHAREPATH":   params["sharepath"].(string),
+		"FSID":        params["fsid"].(string),
+		"DESCRIPTION": params["description"].(string),
+	}
+
+	vStoreID, _ := params["vStoreID"].(string)
+	if vStoreID != "" {
+		data["vstoreId"] = vStoreID
+	}
+
+	resp, err := cli.Post(ctx, "/CIFSHARE", data)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code == cifsShareAlreadyExist || code == sharePathAlreadyExist {
+		log.AddContext(ctx).Infof("Cifs share %s already exists while creating", name)
+		return cli.GetCifsShareByName(ctx, name, vStoreID)
+	}
+
+	if code != 0 {
+		return nil, fmt.Errorf("create cifs share %v error: %d", data, code)
+	}
+
+	respData := resp.Data.(map[string]interface{})
+	return respData, nil
+}
+
+// DeleteCifsShare used for delete cifs share by id
+func (cli *BaseClient) DeleteCifsShare(ctx context.Context, id, vStoreID string) error {
+	url := fmt.Sprintf("/CIFSHARE/%s", id)
+	var data = make(map[string]interface{})
+	if vStoreID != "" {
+		data["vstoreId"] = vStoreID
+	}
+
+	resp, err := cli.Delete(ctx, url, data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code == cifsShareNotExist {
+		log.AddContext(ctx).Infof("Cifs share %s does not exist while deleting", id)
+		return nil
+	}
+	if code != 0 {
+		return fmt.Errorf("Delete cifs share %s error: %d", id, code)
+	}
+
+	return nil
+}
+
+// AllowCifsShareAccessRequest used for AllowCifsShareAccess request
+type AllowCifsShareAccessRequest struct {
+	Name       string
+	ParentID   string
+	Permission int
+	DomainType int
+	VStoreID   string
+}
+
+// AllowCifsShareAccess used for allow cifs share access
+func (cli *BaseClient) AllowCifsShareAccess(ctx context.Context, req *AllowCifsShareAccessRequest) error {
+	data := map[string]interface{}{
+		"NAME":       req.Name,
+		"PARENTID":   req.ParentID,
+		"PERMISSION": req.Permission,
+		"DOMAINTYPE": req.DomainType,
+	}
+	if req.VStoreID != "" {
+		data["vstoreId"] = req.VStoreID
+	}
+
+	resp, err := cli.Post(ctx, "/CIFS_SHARE_AUTH_CLIENT", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("allow cifs share %v access error: %d", data, code)
+	}
+
+	return nil
+}
+
+// GetCifsShareAccess used for get the access of the user to the cifs share, nil is returned if
+// the user has no access to the share
+func (cli *BaseClient) GetCifsShareAccess(ctx context.Context, parentID, name, vStoreID string) (
+	map[string]interface{}, error) {
+	url := fmt.Sprintf("/CIFS_SHARE_AUTH_CLIENT?filter=PARENTID::%s&range=[0-100]", parentID)
+	var data = make(map[string]interface{})
+	if vStoreID != "" {
+		data["vstoreId"] = vStoreID
+	}
+
+	resp, err := cli.Get(ctx, url, data)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return nil, fmt.Errorf("get cifs share access of %s error: %d", parentID, code)
+	}
+
+	respData, _ := resp.Data.([]interface{})
+	for _, i := range respData {
+		access, ok := i.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// The names of the domain users are case insensitive
+		if accessName, _ := access["NAME"].(string); strings.EqualFold(accessName, name) {
+			return access, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// UpdateCifsShareAccess used for update the permission of the cifs share access
+func (cli *BaseClient) UpdateCifsShareAccess(ctx context.Context, accessID string, permission int,
+	vStoreID string) error {
+	url := fmt.Sprintf("/CIFS_SHARE_AUTH_CLIENT/%s", accessID)
+	data := map[string]interface{}{
+		"PERMISSION": permission,
+	}
+	if vStoreID != "" {
+		data["vstoreId"] = vStoreID
+	}
+
+	resp, err := cli.Put(ctx, url, data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("update cifs share access %s by %v error: %d", accessID, data, code)
+	}
+
+	return nil
+}
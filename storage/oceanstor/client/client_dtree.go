@@ -0,0 +1,116 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+const (
+	// ParentTypeFS is the parent type of the dtrees and the quotas in filesystems
+	ParentTypeFS = 40
+	// ParentTypeDTree is the parent type of the quotas of dtrees
+	ParentTypeDTree = 16445
+
+	// dTreeSecurityStyleUnix is the UNIX security style of the dtrees shared by NFS
+	dTreeSecurityStyleUnix = 3
+)
+
+type DTree interface {
+	// CreateDTree used for create dtree in the parent filesystem
+	CreateDTree(ctx context.Context, parentName, name string) (map[string]interface{}, error)
+	// GetDTreeByName used for get dtree by name in the parent filesystem
+	GetDTreeByName(ctx context.Context, parentName, name string) (map[string]interface{}, error)
+	// DeleteDTree used for delete dtree by id
+	DeleteDTree(ctx context.Context, dTreeID string) error
+}
+
+// CreateDTree used for create dtree in the parent filesystem
+func (cli *BaseClient) CreateDTree(ctx context.Context, parentName, name string) (map[string]interface{}, error) {
+	data := map[string]interface{}{
+		"NAME":          name,
+		"PARENTNAME":    parentName,
+		"PARENTTYPE":    ParentTypeFS,
+		"QUOTASWITCH":   true,
+		"securityStyle": dTreeSecurityStyleUnix,
+	}
+
+	resp, err := cli.Post(ctx, "/QUOTATREE", data)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return nil, fmt.Errorf("Create dtree %s in filesystem %s error: %d", name, parentName, code)
+	}
+
+	dTree, ok := resp.Data.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Create dtree %s in filesystem %s returns no data", name, parentName)
+	}
+	return dTree, nil
+}
+
+// GetDTreeByName used for get dtree by name in the parent filesystem
+func (cli *BaseClient) GetDTreeByName(ctx context.Context, parentName, name string) (map[string]interface{}, error) {
+	query := fmt.Sprintf("/QUOTATREE?PARENTNAME=%s&NAME=%s", url.QueryEscape(parentName), url.QueryEscape(name))
+	resp, err := cli.Get(ctx, query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code == objectNotExist {
+		return nil, nil
+	}
+	if code != 0 {
+		return nil, fmt.Errorf("Get dtree %s in filesystem %s error: %d", name, parentName, code)
+	}
+
+	// the dtree is returned as an object, or as a list by some versions
+	switch data := resp.Data.(type) {
+	case map[string]interface{}:
+		return data, nil
+	case []interface{}:
+		if len(data) > 0 {
+			dTree, _ := data[0].(map[string]interface{})
+			return dTree, nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteDTree used for delete dtree by id
+func (cli *BaseClient) DeleteDTree(ctx context.Context, dTreeID string) error {
+	resp, err := cli.Delete(ctx, "/QUOTATREE", map[string]interface{}{"ID": dTreeID})
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code == objectNotExist {
+		return nil
+	}
+	if code != 0 {
+		return fmt.Errorf("Delete dtree %s error: %d", dTreeID, code)
+	}
+
+	return nil
+}
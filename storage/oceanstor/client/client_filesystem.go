@@ -46,6 +46,8 @@ type Filesystem interface {
 	GetFileSystemByName(ctx context.Context, name string) (map[string]interface{}, error)
 	// GetFileSystemByID used for get file system by id
 	GetFileSystemByID(ctx context.Context, id string) (map[string]interface{}, error)
+	// GetFileSystemsByNamePrefix used for get the file systems whose names contain the prefix in the range
+	GetFileSystemsByNamePrefix(ctx context.Context, prefix string, startRange, endRange int64) ([]interface{}, error)
 	// GetNfsShareByPath used for get nfs share by path
 	GetNfsShareByPath(ctx context.Context, path, vStoreID string) (map[string]interface{}, error)
 	// GetNfsShareAccess used for get nfs share access
@@ -122,6 +124,28 @@ func (cli *BaseClient) GetFileSystemByName(ctx context.Context, name string) (ma
 	return fs, nil
 }
 
+// GetFileSystemsByNamePrefix used for get the file systems whose names contain the prefix in the range
+func (cli *BaseClient) GetFileSystemsByNamePrefix(ctx context.Context, prefix string, startRange,
+	endRange int64) ([]interface{}, error) {
+	url := fmt.Sprintf("/filesystem?filter=NAME:%s&range=[%d-%d]", prefix, startRange, endRange)
+	resp, err := cli.Get(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return nil, fmt.Errorf("Get filesystems of name prefix %s error: %d", prefix, code)
+	}
+
+	if resp.Data == nil {
+		return nil, nil
+	}
+
+	// the fuzzy filter matches the names containing the prefix anywhere, which the callers check
+	return resp.Data.([]interface{}), nil
+}
+
 // GetFileSystemByID used for get file system by id
 func (cli *BaseClient) GetFileSystemByID(ctx context.Context, id string) (map[string]interface{}, error) {
 	url := fmt.Sprintf("/filesystem/%s", id)
@@ -303,6 +327,12 @@ type AllowNfsShareAccessRequest struct {
 	AllSquash  int
 	RootSquash int
 	VStoreID   string
+	// AnonUID and AnonGID are the IDs the squashed users are mapped to, empty to keep the defaults of storage
+	AnonUID string
+	AnonGID string
+	// SecurityFlavors are the RPC security flavors of the client separated by ":", such as krb5p:krb5i,
+	// empty for sys
+	SecurityFlavors string
 }
 
 // AllowNfsShareAccess used for allow nfs share access
@@ -318,6 +348,15 @@ func (cli *BaseClient) AllowNfsShareAccess(ctx context.Context, req *AllowNfsSha
 	if req.VStoreID != "" {
 		data["vstoreId"] = req.VStoreID
 	}
+	if req.AnonUID != "" {
+		data["ANONYMOUSUSERID"] = req.AnonUID
+	}
+	if req.AnonGID != "" {
+		data["ANONYMOUSGROUPID"] = req.AnonGID
+	}
+	if req.SecurityFlavors != "" {
+		data["SECURITYTYPE"] = req.SecurityFlavors
+	}
 
 	resp, err := cli.Post(ctx, "/NFS_SHARE_AUTH_CLIENT", data)
 	if err != nil {
@@ -340,6 +379,13 @@ func (cli *BaseClient) CreateNfsShare(ctx context.Context,
 		"FSID":        params["fsid"].(string),
 		"DESCRIPTION": params["description"].(string),
 	}
+	if dTreeID, _ := params["dtreeid"].(string); dTreeID != "" {
+		data["DTREEID"] = dTreeID
+	}
+	// the NFSv4 ACLs of the share are kept if it is 1, or mapped to the UNIX mode bits if it is 0
+	if nfsV4ACL, exist := params["nfsv4acl"].(int); exist {
+		data["NFSV4ACL"] = nfsV4ACL
+	}
 
 	vStoreID, _ := params["vStoreID"].(string)
 	if vStoreID != "" {
@@ -450,6 +496,7 @@ func (cli *BaseClient) GetNFSServiceSetting(ctx context.Context) (map[string]boo
 		"SupportNFS3":  true,
 		"SupportNFS4":  false,
 		"SupportNFS41": false,
+		"SupportNFS42": false,
 	}
 	respData := resp.Data.(map[string]interface{})
 	for k, v := range respData {
@@ -460,6 +507,8 @@ func (cli *BaseClient) GetNFSServiceSetting(ctx context.Context) (map[string]boo
 			setting["SupportNFS4"], err = strconv.ParseBool(v.(string))
 		} else if k == "SUPPORTV41" {
 			setting["SupportNFS41"], err = strconv.ParseBool(v.(string))
+		} else if k == "SUPPORTV42" {
+			setting["SupportNFS42"], err = strconv.ParseBool(v.(string))
 		}
 
 		if err != nil {
@@ -491,6 +540,18 @@ func (cli *BaseClient) CreateFileSystem(ctx context.Context, params map[string]i
 		data["ISSHOWSNAPDIR"] = val
 	}
 
+	if val, exist := params["sectorsize"].(int64); exist {
+		data["SECTORSIZE"] = val
+	}
+
+	if val, exist := params["dedup"].(bool); exist {
+		data["ENABLEDEDUP"] = val
+	}
+
+	if val, exist := params["compression"].(bool); exist {
+		data["ENABLECOMPRESSION"] = val
+	}
+
 	if hyperMetro, hyperMetroOK := params["hypermetro"].(bool); hyperMetroOK && hyperMetro {
 		data["fileSystemMode"] = hyperMetroFilesystem
 		if vstoreId, exist := params["vstoreId"].(string); exist && vstoreId != "" {
@@ -40,6 +40,10 @@ type FSSnapshot interface {
 	GetFSSnapshotByName(ctx context.Context, parentID, snapshotName string) (map[string]interface{}, error)
 	// GetFSSnapshotCountByParentId used for get file system snapshot count by parent id
 	GetFSSnapshotCountByParentId(ctx context.Context, ParentId string) (int, error)
+	// GetFSSnapshotsByRange used for get the file system snapshots in the range by parent id
+	GetFSSnapshotsByRange(ctx context.Context, parentID string, start, end int) ([]interface{}, error)
+	// RollbackFSSnapshot used for roll back the parent file system to the file system snapshot
+	RollbackFSSnapshot(ctx context.Context, snapshotID string, speed int) error
 }
 
 // DeleteFSSnapshot used for delete file system snapshot by id
@@ -139,3 +143,50 @@ func (cli *BaseClient) CreateFSSnapshot(ctx context.Context, name, parentID stri
 	respData := resp.Data.(map[string]interface{})
 	return respData, nil
 }
+
+// GetFSSnapshotsByRange used for get the snapshots of the file system in the range [start, end)
+func (cli *BaseClient) GetFSSnapshotsByRange(ctx context.Context, parentID string, start, end int) (
+	[]interface{}, error) {
+	url := fmt.Sprintf("/FSSNAPSHOT?PARENTID=%s&range=[%d-%d]", parentID, start, end)
+	resp, err := cli.Get(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code == snapshotParentNotExistV3 || code == snapshotParentNotExistV6 {
+		log.AddContext(ctx).Infof("The parent filesystem %s of snapshots does not exist", parentID)
+		return nil, nil
+	}
+	if code != 0 {
+		return nil, fmt.Errorf("failed to get snapshots of filesystem %s in range [%d-%d], error is %d",
+			parentID, start, end, code)
+	}
+
+	if resp.Data == nil {
+		return nil, nil
+	}
+
+	respData := resp.Data.([]interface{})
+	return respData, nil
+}
+
+// RollbackFSSnapshot used for roll back the parent file system to the file system snapshot
+func (cli *BaseClient) RollbackFSSnapshot(ctx context.Context, snapshotID string, speed int) error {
+	data := map[string]interface{}{
+		"ID":            snapshotID,
+		"ROLLBACKSPEED": speed,
+	}
+
+	resp, err := cli.Put(ctx, "/fssnapshot/rollback_fssnapshot", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Rollback FS snapshot %s error: %d", snapshotID, code)
+	}
+
+	return nil
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 
+	"huawei-csi-driver/utils"
 	"huawei-csi-driver/utils/log"
 )
 
@@ -37,6 +38,8 @@ type Host interface {
 	QueryAssociateHostGroup(ctx context.Context, objType int, objID string) ([]interface{}, error)
 	// GetHostByName used to get host by name
 	GetHostByName(ctx context.Context, name string) (map[string]interface{}, error)
+	// GetHostByID used to get host by id
+	GetHostByID(ctx context.Context, id string) (map[string]interface{}, error)
 	// GetHostGroupByName used for get host group by name
 	GetHostGroupByName(ctx context.Context, name string) (map[string]interface{}, error)
 	// DeleteHost used for delete host by id
@@ -166,6 +169,14 @@ func (cli *BaseClient) UpdateHost(ctx context.Context, id string, alua map[strin
 		data["hyperMetroPathOptimized"] = hyperMetroPathOptimized
 	}
 
+	if osType, ok := alua["OPERATIONSYSTEM"]; ok {
+		data["OPERATIONSYSTEM"] = osType
+	}
+
+	if description, ok := alua["DESCRIPTION"]; ok {
+		data["DESCRIPTION"] = description
+	}
+
 	resp, err := cli.Put(ctx, url, data)
 	if err != nil {
 		return err
@@ -208,6 +219,23 @@ func (cli *BaseClient) GetHostByName(ctx context.Context, name string) (map[stri
 	return host, nil
 }
 
+// GetHostByID used for get host by id
+func (cli *BaseClient) GetHostByID(ctx context.Context, id string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("/host/%s", id)
+	resp, err := cli.Get(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		msg := fmt.Sprintf("Get host %s error: %d", id, code)
+		return nil, errors.New(msg)
+	}
+
+	return utils.ToObject(resp.Data)
+}
+
 // DeleteHost used for delete host by id
 func (cli *BaseClient) DeleteHost(ctx context.Context, id string) error {
 	url := fmt.Sprintf("/host/%s", id)
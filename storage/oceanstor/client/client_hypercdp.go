@@ -0,0 +1,105 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+type HyperCDP interface {
+	// CreateHyperCDP used for create HyperCDP object of lun
+	CreateHyperCDP(ctx context.Context, name, lunID string) (map[string]interface{}, error)
+	// GetHyperCDPByName used for get HyperCDP object by name
+	GetHyperCDPByName(ctx context.Context, name string) (map[string]interface{}, error)
+	// DeleteHyperCDP used for delete HyperCDP object
+	DeleteHyperCDP(ctx context.Context, hyperCDPID string) error
+	// GetHyperCDPsByRange used for get the HyperCDP objects in the range, of the lun if parentID is not empty
+	GetHyperCDPsByRange(ctx context.Context, parentID string, start, end int) ([]interface{}, error)
+}
+
+// CreateHyperCDP used for create HyperCDP object of lun
+func (cli *BaseClient) CreateHyperCDP(ctx context.Context, name, lunID string) (map[string]interface{}, error) {
+	data := map[string]interface{}{
+		"NAME":        name,
+		"DESCRIPTION": description,
+		"PARENTID":    lunID,
+	}
+
+	resp, err := cli.Post(ctx, "/hypercdp", data)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return nil, fmt.Errorf("Create HyperCDP object %s for lun %s error: %d", name, lunID, code)
+	}
+
+	respData := resp.Data.(map[string]interface{})
+	return respData, nil
+}
+
+// GetHyperCDPByName used for get HyperCDP object by name
+func (cli *BaseClient) GetHyperCDPByName(ctx context.Context, name string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("/hypercdp?filter=NAME::%s", name)
+	return cli.getObjectByName(ctx, url, "HyperCDP object", name)
+}
+
+// DeleteHyperCDP used for delete HyperCDP object
+func (cli *BaseClient) DeleteHyperCDP(ctx context.Context, hyperCDPID string) error {
+	url := fmt.Sprintf("/hypercdp/%s", hyperCDPID)
+	resp, err := cli.Delete(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Delete HyperCDP object %s error: %d", hyperCDPID, code)
+	}
+
+	return nil
+}
+
+// GetHyperCDPsByRange used for get the HyperCDP objects in the range [start, end), of the lun if parentID is
+// not empty or of all the luns otherwise
+func (cli *BaseClient) GetHyperCDPsByRange(ctx context.Context, parentID string, start, end int) (
+	[]interface{}, error) {
+	url := fmt.Sprintf("/hypercdp?range=[%d-%d]", start, end)
+	if parentID != "" {
+		url = fmt.Sprintf("/hypercdp?filter=PARENTID::%s&range=[%d-%d]", parentID, start, end)
+	}
+
+	resp, err := cli.Get(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return nil, fmt.Errorf("Get HyperCDP objects of lun %s in range [%d-%d] error: %d",
+			parentID, start, end, code)
+	}
+
+	if resp.Data == nil {
+		return nil, nil
+	}
+
+	respData := resp.Data.([]interface{})
+	return respData, nil
+}
@@ -25,6 +25,11 @@ import (
 
 const (
 	hyperMetroNotExist int64 = 1077674242
+
+	// hyperMetroPairType and hyperMetroGroupType are the object types of the HyperMetro pairs and
+	// consistency groups
+	hyperMetroPairType  = 15361
+	hyperMetroGroupType = 15364
 )
 
 type HyperMetro interface {
@@ -46,6 +51,22 @@ type HyperMetro interface {
 	SyncHyperMetroPair(ctx context.Context, pairID string) error
 	// StopHyperMetroPair used for stop hyper metro pair
 	StopHyperMetroPair(ctx context.Context, pairID string) error
+	// GetHyperMetroGroupByName used for get hyper metro consistency group by name
+	GetHyperMetroGroupByName(ctx context.Context, name string) (map[string]interface{}, error)
+	// CreateHyperMetroGroup used for create hyper metro consistency group
+	CreateHyperMetroGroup(ctx context.Context, data map[string]interface{}) (map[string]interface{}, error)
+	// DeleteHyperMetroGroup used for delete hyper metro consistency group by group id
+	DeleteHyperMetroGroup(ctx context.Context, groupID string) error
+	// AddPairToHyperMetroGroup used for add hyper metro pair to hyper metro consistency group
+	AddPairToHyperMetroGroup(ctx context.Context, pairID, groupID string) error
+	// RemovePairFromHyperMetroGroup used for remove hyper metro pair from hyper metro consistency group
+	RemovePairFromHyperMetroGroup(ctx context.Context, pairID, groupID string) error
+	// GetHyperMetroPairsByGroup used for get hyper metro pairs of hyper metro consistency group
+	GetHyperMetroPairsByGroup(ctx context.Context, groupID string) ([]map[string]interface{}, error)
+	// SyncHyperMetroGroup used for synchronize hyper metro consistency group
+	SyncHyperMetroGroup(ctx context.Context, groupID string) error
+	// StopHyperMetroGroup used for stop hyper metro consistency group
+	StopHyperMetroGroup(ctx context.Context, groupID string) error
 }
 
 // GetHyperMetroDomainByName used for get hyper metro domain by name
@@ -259,3 +280,153 @@ func (cli *BaseClient) DeleteHyperMetroPair(ctx context.Context, pairID string,
 
 	return nil
 }
+
+// GetHyperMetroGroupByName used for get hyper metro consistency group by name
+func (cli *BaseClient) GetHyperMetroGroupByName(ctx context.Context, name string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("/HyperMetro_ConsistentGroup?filter=NAME::%s", name)
+	return cli.getObjectByName(ctx, url, "hypermetro consistency group", name)
+}
+
+// CreateHyperMetroGroup used for create hyper metro consistency group
+func (cli *BaseClient) CreateHyperMetroGroup(ctx context.Context, data map[string]interface{}) (
+	map[string]interface{}, error) {
+	data["DESCRIPTION"] = description
+	resp, err := cli.Post(ctx, "/HyperMetro_ConsistentGroup", data)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return nil, fmt.Errorf("Create hypermetro consistency group %v error: %d", data, code)
+	}
+
+	respData := resp.Data.(map[string]interface{})
+	return respData, nil
+}
+
+// DeleteHyperMetroGroup used for delete hyper metro consistency group by group id
+func (cli *BaseClient) DeleteHyperMetroGroup(ctx context.Context, groupID string) error {
+	url := fmt.Sprintf("/HyperMetro_ConsistentGroup/%s", groupID)
+	resp, err := cli.Delete(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Delete hypermetro consistency group %s error: %d", groupID, code)
+	}
+
+	return nil
+}
+
+// AddPairToHyperMetroGroup used for add hyper metro pair to hyper metro consistency group
+func (cli *BaseClient) AddPairToHyperMetroGroup(ctx context.Context, pairID, groupID string) error {
+	data := map[string]interface{}{
+		"ID":               groupID,
+		"TYPE":             hyperMetroGroupType,
+		"ASSOCIATEOBJID":   pairID,
+		"ASSOCIATEOBJTYPE": hyperMetroPairType,
+	}
+
+	resp, err := cli.Post(ctx, "/hyperMetro/associate/pair", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Add hypermetro pair %s to consistency group %s error: %d", pairID, groupID, code)
+	}
+
+	return nil
+}
+
+// RemovePairFromHyperMetroGroup used for remove hyper metro pair from hyper metro consistency group
+func (cli *BaseClient) RemovePairFromHyperMetroGroup(ctx context.Context, pairID, groupID string) error {
+	data := map[string]interface{}{
+		"ID":               groupID,
+		"TYPE":             hyperMetroGroupType,
+		"ASSOCIATEOBJID":   pairID,
+		"ASSOCIATEOBJTYPE": hyperMetroPairType,
+	}
+
+	resp, err := cli.Delete(ctx, "/hyperMetro/associate/pair", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Remove hypermetro pair %s from consistency group %s error: %d", pairID, groupID, code)
+	}
+
+	return nil
+}
+
+// GetHyperMetroPairsByGroup used for get hyper metro pairs of hyper metro consistency group
+func (cli *BaseClient) GetHyperMetroPairsByGroup(ctx context.Context, groupID string) (
+	[]map[string]interface{}, error) {
+	url := fmt.Sprintf("/HyperMetroPair/associate?ASSOCIATEOBJTYPE=%d&ASSOCIATEOBJID=%s",
+		hyperMetroGroupType, groupID)
+	resp, err := cli.Get(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return nil, fmt.Errorf("Get hypermetro pairs of consistency group %s error: %d", groupID, code)
+	}
+
+	if resp.Data == nil {
+		return nil, nil
+	}
+
+	var pairs []map[string]interface{}
+	for _, i := range resp.Data.([]interface{}) {
+		pairs = append(pairs, i.(map[string]interface{}))
+	}
+	return pairs, nil
+}
+
+// SyncHyperMetroGroup used for synchronize hyper metro consistency group
+func (cli *BaseClient) SyncHyperMetroGroup(ctx context.Context, groupID string) error {
+	data := map[string]interface{}{
+		"ID":   groupID,
+		"TYPE": hyperMetroGroupType,
+	}
+
+	resp, err := cli.Put(ctx, "/HyperMetro_ConsistentGroup/sync", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Sync hypermetro consistency group %s error: %d", groupID, code)
+	}
+
+	return nil
+}
+
+// StopHyperMetroGroup used for stop hyper metro consistency group
+func (cli *BaseClient) StopHyperMetroGroup(ctx context.Context, groupID string) error {
+	data := map[string]interface{}{
+		"ID":   groupID,
+		"TYPE": hyperMetroGroupType,
+	}
+
+	resp, err := cli.Put(ctx, "/HyperMetro_ConsistentGroup/stop", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Stop hypermetro consistency group %s error: %d", groupID, code)
+	}
+
+	return nil
+}
@@ -32,6 +32,8 @@ type Iscsi interface {
 	GetIscsiInitiatorByID(ctx context.Context, initiator string) (map[string]interface{}, error)
 	// UpdateIscsiInitiator used for update iscsi initiator
 	UpdateIscsiInitiator(ctx context.Context, initiator string, alua map[string]interface{}) error
+	// UpdateIscsiInitiatorChap used for enable the CHAP authentication of iscsi initiator
+	UpdateIscsiInitiatorChap(ctx context.Context, initiator, chapName, chapPassword string) error
 	// AddIscsiInitiator used for add iscsi initiator
 	AddIscsiInitiator(ctx context.Context, initiator string) (map[string]interface{}, error)
 	// AddIscsiInitiatorToHost used for add iscsi initiator to host
@@ -167,6 +169,28 @@ func (cli *BaseClient) UpdateIscsiInitiator(ctx context.Context, initiator strin
 	return nil
 }
 
+// UpdateIscsiInitiatorChap used for enable the CHAP authentication of iscsi initiator
+func (cli *BaseClient) UpdateIscsiInitiatorChap(ctx context.Context, initiator, chapName, chapPassword string) error {
+	url := fmt.Sprintf("/iscsi_initiator/%s", initiator)
+	data := map[string]interface{}{
+		"USECHAP":      "true",
+		"CHAPNAME":     chapName,
+		"CHAPPASSWORD": chapPassword,
+	}
+
+	resp, err := cli.Put(ctx, url, data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("update chap of iscsi initiator %s error: %d", initiator, code)
+	}
+
+	return nil
+}
+
 // AddIscsiInitiatorToHost used for add iscsi initiator to host
 func (cli *BaseClient) AddIscsiInitiatorToHost(ctx context.Context, initiator, hostID string) error {
 	url := fmt.Sprintf("/iscsi_initiator/%s", initiator)
@@ -65,6 +65,8 @@ type Lun interface {
 	AddLunToGroup(ctx context.Context, lunID string, groupID string) error
 	// CreateLunGroup used for create lun group
 	CreateLunGroup(ctx context.Context, name string) (map[string]interface{}, error)
+	// GetLunsByNamePrefix used for get the luns whose names contain the prefix in the range
+	GetLunsByNamePrefix(ctx context.Context, prefix string, startRange, endRange int64) ([]interface{}, error)
 }
 
 // QueryAssociateLunGroup used for query associate lun group by object type and object id
@@ -118,6 +120,28 @@ func (cli *BaseClient) GetLunByName(ctx context.Context, name string) (map[strin
 	return lun, nil
 }
 
+// GetLunsByNamePrefix used for get the luns whose names contain the prefix in the range
+func (cli *BaseClient) GetLunsByNamePrefix(ctx context.Context, prefix string, startRange,
+	endRange int64) ([]interface{}, error) {
+	url := fmt.Sprintf("/lun?filter=NAME:%s&range=[%d-%d]", prefix, startRange, endRange)
+	resp, err := cli.Get(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return nil, fmt.Errorf("Get luns of name prefix %s error: %d", prefix, code)
+	}
+
+	if resp.Data == nil {
+		return nil, nil
+	}
+
+	// the fuzzy filter matches the names containing the prefix anywhere, which the callers check
+	return resp.Data.([]interface{}), nil
+}
+
 // GetLunByID used for get lun by id
 func (cli *BaseClient) GetLunByID(ctx context.Context, id string) (map[string]interface{}, error) {
 	url := fmt.Sprintf("/lun/%s", id)
@@ -275,6 +299,21 @@ func (cli *BaseClient) CreateLun(ctx context.Context, params map[string]interfac
 	if val, ok := params["workloadTypeID"].(string); ok {
 		data["WORKLOADTYPEID"] = val
 	}
+	if val, ok := params["prefetchpolicy"].(int); ok {
+		data["PREFETCHPOLICY"] = val
+	}
+	if val, ok := params["dedup"].(bool); ok {
+		data["ENABLESMARTDEDUP"] = val
+	}
+	if val, ok := params["compression"].(bool); ok {
+		data["ENABLECOMPRESSION"] = val
+	}
+	if val, ok := params["datatransferpolicy"].(int); ok {
+		data["DATATRANSFERPOLICY"] = val
+	}
+	if val, ok := params["capacitythreshold"].(int); ok {
+		data["CAPACITYTHRESHOLD"] = val
+	}
 
 	resp, err := cli.Post(ctx, "/lun", data)
 	if err != nil {
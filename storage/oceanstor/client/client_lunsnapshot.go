@@ -39,6 +39,10 @@ type LunSnapshot interface {
 	ActivateLunSnapshot(ctx context.Context, snapshotID string) error
 	// DeactivateLunSnapshot used for stop lun snapshot
 	DeactivateLunSnapshot(ctx context.Context, snapshotID string) error
+	// RollbackLunSnapshot used for roll back the parent lun to the lun snapshot
+	RollbackLunSnapshot(ctx context.Context, snapshotID string, speed int) error
+	// GetLunSnapshotsByRange used for get the lun snapshots in the range, of the lun if parentID is not empty
+	GetLunSnapshotsByRange(ctx context.Context, parentID string, start, end int) ([]interface{}, error)
 }
 
 // CreateLunSnapshot used for create lun snapshot
@@ -151,3 +155,50 @@ func (cli *BaseClient) DeactivateLunSnapshot(ctx context.Context, snapshotID str
 
 	return nil
 }
+
+// RollbackLunSnapshot used for roll back the parent lun to the lun snapshot
+func (cli *BaseClient) RollbackLunSnapshot(ctx context.Context, snapshotID string, speed int) error {
+	data := map[string]interface{}{
+		"ID":            snapshotID,
+		"ROLLBACKSPEED": speed,
+	}
+
+	resp, err := cli.Put(ctx, "/snapshot/rollback", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Rollback snapshot %s error: %d", snapshotID, code)
+	}
+
+	return nil
+}
+
+// GetLunSnapshotsByRange used for get the lun snapshots in the range [start, end), of the lun if parentID is
+// not empty or of all the luns otherwise
+func (cli *BaseClient) GetLunSnapshotsByRange(ctx context.Context, parentID string, start, end int) (
+	[]interface{}, error) {
+	url := fmt.Sprintf("/snapshot?range=[%d-%d]", start, end)
+	if parentID != "" {
+		url = fmt.Sprintf("/snapshot?filter=PARENTID::%s&range=[%d-%d]", parentID, start, end)
+	}
+
+	resp, err := cli.Get(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return nil, fmt.Errorf("Get snapshots of lun %s in range [%d-%d] error: %d", parentID, start, end, code)
+	}
+
+	if resp.Data == nil {
+		return nil, nil
+	}
+
+	respData := resp.Data.([]interface{})
+	return respData, nil
+}
@@ -0,0 +1,78 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+type Migration interface {
+	// CreateLunMigration used for create SmartMigration task moving the source lun to the target lun
+	CreateLunMigration(ctx context.Context, srcLunID, targetLunID string, speed int) error
+	// GetLunMigration used for get SmartMigration task by source lun id
+	GetLunMigration(ctx context.Context, srcLunID string) (map[string]interface{}, error)
+	// DeleteLunMigration used for delete SmartMigration task by source lun id
+	DeleteLunMigration(ctx context.Context, srcLunID string) error
+}
+
+// CreateLunMigration used for create SmartMigration task moving the source lun to the target lun
+func (cli *BaseClient) CreateLunMigration(ctx context.Context, srcLunID, targetLunID string, speed int) error {
+	data := map[string]interface{}{
+		"PARENTID":    srcLunID,
+		"TARGETLUNID": targetLunID,
+		"SPEED":       speed,
+		"WORKMODE":    0,
+	}
+
+	resp, err := cli.Post(ctx, "/LUN_MIGRATION", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Create migration from lun %s to %s error: %d", srcLunID, targetLunID, code)
+	}
+
+	return nil
+}
+
+// GetLunMigration used for get SmartMigration task by source lun id
+func (cli *BaseClient) GetLunMigration(ctx context.Context, srcLunID string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("/LUN_MIGRATION?filter=PARENTID::%s", srcLunID)
+	return cli.getObjectByName(ctx, url, "migration of lun", srcLunID)
+}
+
+// DeleteLunMigration used for delete SmartMigration task by source lun id
+func (cli *BaseClient) DeleteLunMigration(ctx context.Context, srcLunID string) error {
+	url := fmt.Sprintf("/LUN_MIGRATION/%s", srcLunID)
+	resp, err := cli.Delete(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code == objectNotExist {
+		return nil
+	}
+	if code != 0 {
+		return fmt.Errorf("Delete migration of lun %s error: %d", srcLunID, code)
+	}
+
+	return nil
+}
@@ -0,0 +1,363 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// snapshotConsistencyGroupType is the object type of snapshot consistency groups
+	snapshotConsistencyGroupType = 57956
+)
+
+type ProtectGroup interface {
+	// GetProtectGroupByName used for get protection group by name
+	GetProtectGroupByName(ctx context.Context, name string) (map[string]interface{}, error)
+	// CreateProtectGroup used for create protection group
+	CreateProtectGroup(ctx context.Context, name string) (map[string]interface{}, error)
+	// DeleteProtectGroup used for delete protection group
+	DeleteProtectGroup(ctx context.Context, groupID string) error
+	// AddLunToProtectGroup used for add lun to protection group
+	AddLunToProtectGroup(ctx context.Context, lunID, groupID string) error
+	// RemoveLunFromProtectGroup used for remove lun from protection group
+	RemoveLunFromProtectGroup(ctx context.Context, lunID, groupID string) error
+	// GetSnapshotConsistencyGroupByName used for get snapshot consistency group by name
+	GetSnapshotConsistencyGroupByName(ctx context.Context, name string) (map[string]interface{}, error)
+	// CreateSnapshotConsistencyGroup used for create snapshot consistency group of protection group
+	CreateSnapshotConsistencyGroup(ctx context.Context, name, groupID string) (map[string]interface{}, error)
+	// ActivateSnapshotConsistencyGroup used for activate snapshot consistency group
+	ActivateSnapshotConsistencyGroup(ctx context.Context, snapshotGroupID string) error
+	// DeactivateSnapshotConsistencyGroup used for stop snapshot consistency group
+	DeactivateSnapshotConsistencyGroup(ctx context.Context, snapshotGroupID string) error
+	// DeleteSnapshotConsistencyGroup used for delete snapshot consistency group
+	DeleteSnapshotConsistencyGroup(ctx context.Context, snapshotGroupID string) error
+	// GetSnapshotsOfConsistencyGroup used for get lun snapshots of snapshot consistency group
+	GetSnapshotsOfConsistencyGroup(ctx context.Context, snapshotGroupID string) ([]map[string]interface{}, error)
+	// GetReplicationGroupByName used for get replication consistency group by name
+	GetReplicationGroupByName(ctx context.Context, name string) (map[string]interface{}, error)
+	// CreateReplicationGroup used for create replication consistency group
+	CreateReplicationGroup(ctx context.Context, name string, model int) (map[string]interface{}, error)
+	// DeleteReplicationGroup used for delete replication consistency group
+	DeleteReplicationGroup(ctx context.Context, replicationGroupID string) error
+	// AddPairToReplicationGroup used for add replication pair to replication consistency group
+	AddPairToReplicationGroup(ctx context.Context, pairID, replicationGroupID string) error
+	// RemovePairFromReplicationGroup used for remove replication pair from replication consistency group
+	RemovePairFromReplicationGroup(ctx context.Context, pairID, replicationGroupID string) error
+	// SyncReplicationGroup used for synchronize replication consistency group
+	SyncReplicationGroup(ctx context.Context, replicationGroupID string) error
+}
+
+// GetProtectGroupByName used for get protection group by name
+func (cli *BaseClient) GetProtectGroupByName(ctx context.Context, name string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("/protectgroup?filter=NAME::%s", name)
+	return cli.getObjectByName(ctx, url, "protection group", name)
+}
+
+// CreateProtectGroup used for create protection group
+func (cli *BaseClient) CreateProtectGroup(ctx context.Context, name string) (map[string]interface{}, error) {
+	data := map[string]interface{}{
+		"NAME":        name,
+		"DESCRIPTION": description,
+	}
+
+	resp, err := cli.Post(ctx, "/protectgroup", data)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return nil, fmt.Errorf("Create protection group %s error: %d", name, code)
+	}
+
+	respData := resp.Data.(map[string]interface{})
+	return respData, nil
+}
+
+// DeleteProtectGroup used for delete protection group
+func (cli *BaseClient) DeleteProtectGroup(ctx context.Context, groupID string) error {
+	url := fmt.Sprintf("/protectgroup/%s", groupID)
+	resp, err := cli.Delete(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Delete protection group %s error: %d", groupID, code)
+	}
+
+	return nil
+}
+
+// AddLunToProtectGroup used for add lun to protection group
+func (cli *BaseClient) AddLunToProtectGroup(ctx context.Context, lunID, groupID string) error {
+	data := map[string]interface{}{
+		"ID":               groupID,
+		"ASSOCIATEOBJTYPE": "11",
+		"ASSOCIATEOBJID":   lunID,
+	}
+
+	resp, err := cli.Put(ctx, "/protectgroup/associate", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Add lun %s to protection group %s error: %d", lunID, groupID, code)
+	}
+
+	return nil
+}
+
+// RemoveLunFromProtectGroup used for remove lun from protection group
+func (cli *BaseClient) RemoveLunFromProtectGroup(ctx context.Context, lunID, groupID string) error {
+	url := fmt.Sprintf("/protectgroup/associate?ID=%s&ASSOCIATEOBJTYPE=11&ASSOCIATEOBJID=%s", groupID, lunID)
+	resp, err := cli.Delete(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Remove lun %s from protection group %s error: %d", lunID, groupID, code)
+	}
+
+	return nil
+}
+
+// GetSnapshotConsistencyGroupByName used for get snapshot consistency group by name
+func (cli *BaseClient) GetSnapshotConsistencyGroupByName(ctx context.Context,
+	name string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("/snapshot_consistency_group?filter=NAME::%s", name)
+	return cli.getObjectByName(ctx, url, "snapshot consistency group", name)
+}
+
+// CreateSnapshotConsistencyGroup used for create snapshot consistency group of protection group
+func (cli *BaseClient) CreateSnapshotConsistencyGroup(ctx context.Context,
+	name, groupID string) (map[string]interface{}, error) {
+	data := map[string]interface{}{
+		"NAME":        name,
+		"DESCRIPTION": description,
+		"PARENTID":    groupID,
+	}
+
+	resp, err := cli.Post(ctx, "/snapshot_consistency_group", data)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return nil, fmt.Errorf("Create snapshot consistency group %s of protection group %s error: %d",
+			name, groupID, code)
+	}
+
+	respData := resp.Data.(map[string]interface{})
+	return respData, nil
+}
+
+// ActivateSnapshotConsistencyGroup used for activate snapshot consistency group
+func (cli *BaseClient) ActivateSnapshotConsistencyGroup(ctx context.Context, snapshotGroupID string) error {
+	data := map[string]interface{}{
+		"ID": snapshotGroupID,
+	}
+
+	resp, err := cli.Put(ctx, "/snapshot_consistency_group/activate", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Activate snapshot consistency group %s error: %d", snapshotGroupID, code)
+	}
+
+	return nil
+}
+
+// DeactivateSnapshotConsistencyGroup used for stop snapshot consistency group
+func (cli *BaseClient) DeactivateSnapshotConsistencyGroup(ctx context.Context, snapshotGroupID string) error {
+	data := map[string]interface{}{
+		"ID": snapshotGroupID,
+	}
+
+	resp, err := cli.Put(ctx, "/snapshot_consistency_group/stop", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 && code != snapshotNotActivated {
+		return fmt.Errorf("Stop snapshot consistency group %s error: %d", snapshotGroupID, code)
+	}
+
+	return nil
+}
+
+// DeleteSnapshotConsistencyGroup used for delete snapshot consistency group
+func (cli *BaseClient) DeleteSnapshotConsistencyGroup(ctx context.Context, snapshotGroupID string) error {
+	url := fmt.Sprintf("/snapshot_consistency_group/%s", snapshotGroupID)
+	resp, err := cli.Delete(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Delete snapshot consistency group %s error: %d", snapshotGroupID, code)
+	}
+
+	return nil
+}
+
+// GetSnapshotsOfConsistencyGroup used for get lun snapshots of snapshot consistency group
+func (cli *BaseClient) GetSnapshotsOfConsistencyGroup(ctx context.Context,
+	snapshotGroupID string) ([]map[string]interface{}, error) {
+	url := fmt.Sprintf("/snapshot/associate?ASSOCIATEOBJTYPE=%d&ASSOCIATEOBJID=%s",
+		snapshotConsistencyGroupType, snapshotGroupID)
+	resp, err := cli.Get(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return nil, fmt.Errorf("Get snapshots of snapshot consistency group %s error: %d", snapshotGroupID, code)
+	}
+
+	var snapshots []map[string]interface{}
+	if resp.Data == nil {
+		return snapshots, nil
+	}
+
+	for _, s := range resp.Data.([]interface{}) {
+		snapshots = append(snapshots, s.(map[string]interface{}))
+	}
+	return snapshots, nil
+}
+
+// GetReplicationGroupByName used for get replication consistency group by name
+func (cli *BaseClient) GetReplicationGroupByName(ctx context.Context, name string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("/CONSISTENTGROUP?filter=NAME::%s", name)
+	return cli.getObjectByName(ctx, url, "replication consistency group", name)
+}
+
+// CreateReplicationGroup used for create replication consistency group
+func (cli *BaseClient) CreateReplicationGroup(ctx context.Context,
+	name string, model int) (map[string]interface{}, error) {
+	data := map[string]interface{}{
+		"NAME":             name,
+		"DESCRIPTION":      description,
+		"RECOVERYPOLICY":   "1",
+		"REPLICATIONMODEL": model,
+		"SPEED":            "2",
+	}
+
+	resp, err := cli.Post(ctx, "/CONSISTENTGROUP", data)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return nil, fmt.Errorf("Create replication consistency group %s error: %d", name, code)
+	}
+
+	respData := resp.Data.(map[string]interface{})
+	return respData, nil
+}
+
+// DeleteReplicationGroup used for delete replication consistency group
+func (cli *BaseClient) DeleteReplicationGroup(ctx context.Context, replicationGroupID string) error {
+	url := fmt.Sprintf("/CONSISTENTGROUP/%s", replicationGroupID)
+	resp, err := cli.Delete(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Delete replication consistency group %s error: %d", replicationGroupID, code)
+	}
+
+	return nil
+}
+
+// AddPairToReplicationGroup used for add replication pair to replication consistency group
+func (cli *BaseClient) AddPairToReplicationGroup(ctx context.Context, pairID, replicationGroupID string) error {
+	data := map[string]interface{}{
+		"ID":     replicationGroupID,
+		"RMLIST": []string{pairID},
+	}
+
+	resp, err := cli.Put(ctx, "/ADD_MIRROR", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Add replication pair %s to consistency group %s error: %d",
+			pairID, replicationGroupID, code)
+	}
+
+	return nil
+}
+
+// SyncReplicationGroup used for synchronize replication consistency group
+func (cli *BaseClient) SyncReplicationGroup(ctx context.Context, replicationGroupID string) error {
+	data := map[string]interface{}{
+		"ID": replicationGroupID,
+	}
+
+	resp, err := cli.Put(ctx, "/SYNCHRONIZE_CONSISTENCY_GROUP", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Sync replication consistency group %s error: %d", replicationGroupID, code)
+	}
+
+	return nil
+}
+
+// RemovePairFromReplicationGroup used for remove replication pair from replication consistency group
+func (cli *BaseClient) RemovePairFromReplicationGroup(ctx context.Context, pairID, replicationGroupID string) error {
+	data := map[string]interface{}{
+		"ID":     replicationGroupID,
+		"RMLIST": []string{pairID},
+	}
+
+	resp, err := cli.Put(ctx, "/DEL_MIRROR", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Remove replication pair %s from consistency group %s error: %d",
+			pairID, replicationGroupID, code)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+// directoryQuotaType limits the capacity of the directory rather than of users
+const directoryQuotaType = 1
+
+type Quota interface {
+	// CreateQuota used for create the directory quota of filesystem or dtree, the limits are the fields of
+	// quota such as SPACEHARDQUOTA in bytes
+	CreateQuota(ctx context.Context, parentType int, parentID string,
+		limits map[string]interface{}) (map[string]interface{}, error)
+	// GetQuota used for get the directory quota of filesystem or dtree
+	GetQuota(ctx context.Context, parentType int, parentID string) (map[string]interface{}, error)
+	// UpdateQuota used for update the limits of quota
+	UpdateQuota(ctx context.Context, quotaID string, limits map[string]interface{}) error
+	// DeleteQuota used for delete quota by id
+	DeleteQuota(ctx context.Context, quotaID string) error
+}
+
+// CreateQuota used for create the directory quota of filesystem or dtree, the limits are the fields of
+// quota such as SPACEHARDQUOTA in bytes
+func (cli *BaseClient) CreateQuota(ctx context.Context, parentType int, parentID string,
+	limits map[string]interface{}) (map[string]interface{}, error) {
+	data := map[string]interface{}{
+		"PARENTTYPE": parentType,
+		"PARENTID":   parentID,
+		"QUOTATYPE":  directoryQuotaType,
+	}
+	for k, v := range limits {
+		data[k] = v
+	}
+
+	resp, err := cli.Post(ctx, "/FS_QUOTA", data)
+	if err != nil {
+		return nil, err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return nil, fmt.Errorf("Create quota of %d %s error: %d", parentType, parentID, code)
+	}
+
+	quota, ok := resp.Data.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Create quota of %d %s returns no data", parentType, parentID)
+	}
+	return quota, nil
+}
+
+// GetQuota used for get the directory quota of filesystem or dtree
+func (cli *BaseClient) GetQuota(ctx context.Context, parentType int,
+	parentID string) (map[string]interface{}, error) {
+	query := fmt.Sprintf("/FS_QUOTA?PARENTTYPE=%d&PARENTID=%s&range=[0-100]", parentType, parentID)
+	return cli.getObjectByName(ctx, query, fmt.Sprintf("quota of parent type %d", parentType), parentID)
+}
+
+// UpdateQuota used for update the limits of quota
+func (cli *BaseClient) UpdateQuota(ctx context.Context, quotaID string, limits map[string]interface{}) error {
+	data := map[string]interface{}{
+		"ID": quotaID,
+	}
+	for k, v := range limits {
+		data[k] = v
+	}
+
+	resp, err := cli.Put(ctx, "/FS_QUOTA", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Update quota %s error: %d", quotaID, code)
+	}
+
+	return nil
+}
+
+// DeleteQuota used for delete quota by id
+func (cli *BaseClient) DeleteQuota(ctx context.Context, quotaID string) error {
+	resp, err := cli.Delete(ctx, "/FS_QUOTA", map[string]interface{}{"ID": quotaID})
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code == objectNotExist {
+		return nil
+	}
+	if code != 0 {
+		return fmt.Errorf("Delete quota %s error: %d", quotaID, code)
+	}
+
+	return nil
+}
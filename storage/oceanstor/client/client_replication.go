@@ -47,6 +47,8 @@ type Replication interface {
 	SyncReplicationPair(ctx context.Context, pairID string) error
 	// SplitReplicationPair used for split replication pair by pair id
 	SplitReplicationPair(ctx context.Context, pairID string) error
+	// CancelReplicationSecondaryWriteLock used for letting the secondary resource of replication pair be written
+	CancelReplicationSecondaryWriteLock(ctx context.Context, pairID string) error
 }
 
 // CreateReplicationPair used for create replication pair
@@ -105,6 +107,25 @@ func (cli *BaseClient) SyncReplicationPair(ctx context.Context, pairID string) e
 	return nil
 }
 
+// CancelReplicationSecondaryWriteLock used for letting the secondary resource of replication pair be written
+func (cli *BaseClient) CancelReplicationSecondaryWriteLock(ctx context.Context, pairID string) error {
+	data := map[string]interface{}{
+		"ID": pairID,
+	}
+
+	resp, err := cli.Put(ctx, "/REPLICATIONPAIR/CANCEL_SECODARY_WRITE_LOCK", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Cancel secondary write lock of replication pair %s error: %d", pairID, code)
+	}
+
+	return nil
+}
+
 // DeleteReplicationPair used for delete replication pair by pair id
 func (cli *BaseClient) DeleteReplicationPair(ctx context.Context, pairID string) error {
 	url := fmt.Sprintf("/REPLICATIONPAIR/%s", pairID)
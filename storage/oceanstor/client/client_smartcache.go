@@ -0,0 +1,81 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"fmt"
+	URL "net/url"
+)
+
+type SmartCache interface {
+	// GetSmartCachePartitionByName used for get SmartCache partition by name
+	GetSmartCachePartitionByName(ctx context.Context, name string) (map[string]interface{}, error)
+	// AddLunToSmartCachePartition used for associate lun with SmartCache partition
+	AddLunToSmartCachePartition(ctx context.Context, lunID, partitionID string) error
+	// RemoveLunFromSmartCachePartition used for disassociate lun from SmartCache partition
+	RemoveLunFromSmartCachePartition(ctx context.Context, lunID, partitionID string) error
+}
+
+// GetSmartCachePartitionByName used for get SmartCache partition by name
+func (cli *BaseClient) GetSmartCachePartitionByName(ctx context.Context,
+	name string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("/SMARTCACHEPARTITION?filter=NAME::%s", URL.QueryEscape(name))
+	return cli.getObjectByName(ctx, url, "SmartCache partition", name)
+}
+
+// AddLunToSmartCachePartition used for associate lun with SmartCache partition
+func (cli *BaseClient) AddLunToSmartCachePartition(ctx context.Context, lunID, partitionID string) error {
+	data := map[string]interface{}{
+		"ID":               partitionID,
+		"ASSOCIATEOBJTYPE": 11,
+		"ASSOCIATEOBJID":   lunID,
+	}
+
+	resp, err := cli.Put(ctx, "/SMARTCACHEPARTITION/CREATE_ASSOCIATE", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Add lun %s to SmartCache partition %s error: %d", lunID, partitionID, code)
+	}
+
+	return nil
+}
+
+// RemoveLunFromSmartCachePartition used for disassociate lun from SmartCache partition
+func (cli *BaseClient) RemoveLunFromSmartCachePartition(ctx context.Context, lunID, partitionID string) error {
+	data := map[string]interface{}{
+		"ID":               partitionID,
+		"ASSOCIATEOBJTYPE": 11,
+		"ASSOCIATEOBJID":   lunID,
+	}
+
+	resp, err := cli.Put(ctx, "/SMARTCACHEPARTITION/REMOVE_ASSOCIATE", data)
+	if err != nil {
+		return err
+	}
+
+	code := int64(resp.Error["code"].(float64))
+	if code != 0 {
+		return fmt.Errorf("Remove lun %s from SmartCache partition %s error: %d", lunID, partitionID, code)
+	}
+
+	return nil
+}
@@ -29,6 +29,8 @@ type System interface {
 	GetPoolByName(ctx context.Context, name string) (map[string]interface{}, error)
 	// GetAllPools used for get all pools
 	GetAllPools(ctx context.Context) (map[string]interface{}, error)
+	// GetPoolsByNames used for get the specified pools by server side filtering
+	GetPoolsByNames(ctx context.Context, names []string) (map[string]interface{}, error)
 	// GetSystem used for get system info
 	GetSystem(ctx context.Context) (map[string]interface{}, error)
 	// GetLicenseFeature used for get license feature
@@ -96,6 +98,24 @@ func (cli *BaseClient) GetAllPools(ctx context.Context) (map[string]interface{},
 	return pools, nil
 }
 
+// GetPoolsByNames used for get the specified pools, each pool is queried with a name filter
+// so that storages with a large number of pools don't return the full list
+func (cli *BaseClient) GetPoolsByNames(ctx context.Context, names []string) (map[string]interface{}, error) {
+	pools := make(map[string]interface{})
+	for _, name := range names {
+		pool, err := cli.GetPoolByName(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if pool != nil {
+			pools[name] = pool
+		}
+	}
+
+	return pools, nil
+}
+
 // GetLicenseFeature used for get license feature
 func (cli *BaseClient) GetLicenseFeature(ctx context.Context) (map[string]int, error) {
 	resp, err := cli.Get(ctx, "/license/feature", nil)
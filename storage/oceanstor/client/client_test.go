@@ -1695,6 +1695,201 @@ func TestGetLunCountOfMapping(t *testing.T) {
 	}
 }
 
+func TestGetHyperMetroPairsByGroup(t *testing.T) {
+	var cases = []struct {
+		name         string
+		responseBody string
+		wantPairs    int
+		wantErr      bool
+	}{
+		{
+			"Normal",
+			"{\"data\":[{\"ID\":\"1\",\"CGID\":\"2\"}],\"error\":{\"code\":0,\"description\":\"0\"}}",
+			1,
+			false,
+		},
+		{
+			"Empty group",
+			"{\"error\":{\"code\":0,\"description\":\"0\"}}",
+			0,
+			false,
+		},
+		{
+			"Get pairs error",
+			"{\"data\":{},\"error\":{\"code\":1077949061,\"description\":\"0\"}}",
+			0,
+			true,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := NewMockHTTPClient(ctrl)
+
+	temp := testClient.Client
+	defer func() { testClient.Client = temp }()
+	testClient.Client = mockClient
+
+	for _, s := range cases {
+		mockClient.EXPECT().Do(gomock.Any()).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+			r := ioutil.NopCloser(bytes.NewReader([]byte(s.responseBody)))
+			return &http.Response{
+				StatusCode: int(successStatus),
+				Body:       r,
+			}, nil
+		}).AnyTimes()
+
+		pairs, err := testClient.GetHyperMetroPairsByGroup(context.TODO(), "2")
+		assert.Equal(t, s.wantErr, err != nil, "%s, err:%v", s.name, err)
+		assert.Len(t, pairs, s.wantPairs, s.name)
+	}
+}
+
+func TestDeleteLunMigration(t *testing.T) {
+	var cases = []struct {
+		name         string
+		responseBody string
+		wantErr      bool
+	}{
+		{
+			"Normal",
+			"{\"data\":{},\"error\":{\"code\":0,\"description\":\"0\"}}",
+			false,
+		},
+		{
+			"Migration not exist",
+			"{\"data\":{},\"error\":{\"code\":1077948996,\"description\":\"0\"}}",
+			false,
+		},
+		{
+			"Delete migration error",
+			"{\"data\":{},\"error\":{\"code\":1077949061,\"description\":\"0\"}}",
+			true,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := NewMockHTTPClient(ctrl)
+
+	temp := testClient.Client
+	defer func() { testClient.Client = temp }()
+	testClient.Client = mockClient
+
+	for _, s := range cases {
+		mockClient.EXPECT().Do(gomock.Any()).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+			r := ioutil.NopCloser(bytes.NewReader([]byte(s.responseBody)))
+			return &http.Response{
+				StatusCode: int(successStatus),
+				Body:       r,
+			}, nil
+		}).Times(1)
+
+		err := testClient.DeleteLunMigration(context.TODO(), "1")
+		assert.Equal(t, s.wantErr, err != nil, "%s, err:%v", s.name, err)
+	}
+}
+
+func TestRollbackFSSnapshot(t *testing.T) {
+	var cases = []struct {
+		name         string
+		responseBody string
+		wantErr      bool
+	}{
+		{
+			"Normal",
+			"{\"data\":{},\"error\":{\"code\":0,\"description\":\"0\"}}",
+			false,
+		},
+		{
+			"Rollback snapshot error",
+			"{\"data\":{},\"error\":{\"code\":1077949061,\"description\":\"0\"}}",
+			true,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := NewMockHTTPClient(ctrl)
+
+	temp := testClient.Client
+	defer func() { testClient.Client = temp }()
+	testClient.Client = mockClient
+
+	for _, s := range cases {
+		mockClient.EXPECT().Do(gomock.Any()).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+			r := ioutil.NopCloser(bytes.NewReader([]byte(s.responseBody)))
+			return &http.Response{
+				StatusCode: int(successStatus),
+				Body:       r,
+			}, nil
+		}).Times(1)
+
+		err := testClient.RollbackFSSnapshot(context.TODO(), "1", 2)
+		assert.Equal(t, s.wantErr, err != nil, "%s, err:%v", s.name, err)
+	}
+}
+
+func TestGetDTreeByName(t *testing.T) {
+	var cases = []struct {
+		name         string
+		responseBody string
+		wantID       interface{}
+		wantErr      bool
+	}{
+		{
+			"Object",
+			"{\"data\":{\"ID\":\"1@4096\",\"NAME\":\"pvc_1\"},\"error\":{\"code\":0,\"description\":\"0\"}}",
+			"1@4096",
+			false,
+		},
+		{
+			"List",
+			"{\"data\":[{\"ID\":\"2@4096\",\"NAME\":\"pvc_2\"}],\"error\":{\"code\":0,\"description\":\"0\"}}",
+			"2@4096",
+			false,
+		},
+		{
+			"Not exist",
+			"{\"error\":{\"code\":1077948996,\"description\":\"0\"}}",
+			nil,
+			false,
+		},
+		{
+			"Get dtree error",
+			"{\"error\":{\"code\":1077949061,\"description\":\"0\"}}",
+			nil,
+			true,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClient := NewMockHTTPClient(ctrl)
+
+	temp := testClient.Client
+	defer func() { testClient.Client = temp }()
+	testClient.Client = mockClient
+
+	for _, s := range cases {
+		mockClient.EXPECT().Do(gomock.Any()).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+			r := ioutil.NopCloser(bytes.NewReader([]byte(s.responseBody)))
+			return &http.Response{
+				StatusCode: int(successStatus),
+				Body:       r,
+			}, nil
+		}).Times(1)
+
+		dTree, err := testClient.GetDTreeByName(context.TODO(), "parent", "pvc")
+		assert.Equal(t, s.wantErr, err != nil, "%s, err:%v", s.name, err)
+		var id interface{}
+		if dTree != nil {
+			id = dTree["ID"]
+		}
+		assert.Equal(t, s.wantID, id, s.name)
+	}
+}
+
 func TestMain(m *testing.M) {
 	if err := log.InitLogging(logName); err != nil {
 		log.Errorf("init logging: %s failed. error: %v", logName, err)
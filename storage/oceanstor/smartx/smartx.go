@@ -21,8 +21,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"math/big"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"huawei-csi-driver/storage/oceanstor/client"
@@ -30,6 +33,13 @@ import (
 	"huawei-csi-driver/utils/log"
 )
 
+const (
+	// MaxSharedQosMembers is the maximum number of objects attached to one shared SmartQoS policy
+	MaxSharedQosMembers = 64
+	// maxSharedQosPolicies bounds the number of shared policies created for one set of QoS parameters
+	maxSharedQosPolicies = 256
+)
+
 type qosParameterValidators map[string]func(int) bool
 type qosParameterList map[string]struct{}
 
@@ -235,6 +245,9 @@ func ValidateQoSParameters(product string, qosParam map[string]float64) (map[str
 	return validatedParameters, nil
 }
 
+// sharedQosMutex serializes the read-modify-write of the member list of a SmartQoS policy
+var sharedQosMutex sync.Mutex
+
 type SmartX struct {
 	cli client.BaseClientInterface
 }
@@ -250,43 +263,55 @@ func (p *SmartX) getQosName(objID, objType string) string {
 	return fmt.Sprintf("k8s_%s%s_%s", objType, objID, now)
 }
 
-func (p *SmartX) CreateQos(ctx context.Context,
-	objID, objType, vStoreID string,
-	params map[string]int) (string, error) {
-	var err error
-	var lowerLimit bool
+// getSharedQosName returns a stable name derived from the QoS parameters, so that volumes with
+// identical QoS settings resolve to the same SmartQoS policy
+func (p *SmartX) getSharedQosName(objType string, params map[string]int, index int) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := fnv.New32a()
+	for _, k := range keys {
+		h.Write([]byte(fmt.Sprintf("%s=%d;", k, params[k])))
+	}
+
+	return fmt.Sprintf("k8s_sqos_%s_%08x_%d", objType, h.Sum32(), index)
+}
 
+func (p *SmartX) upgradeIOPriority(ctx context.Context, objID, objType string, params map[string]int) error {
+	var lowerLimit bool
 	for k := range params {
 		if strings.HasPrefix(k, "MIN") || strings.HasPrefix(k, "LATENCY") {
 			lowerLimit = true
 		}
 	}
 
-	if lowerLimit {
-		data := map[string]interface{}{
-			"IOPRIORITY": 3,
-		}
+	if !lowerLimit {
+		return nil
+	}
 
-		if objType == "fs" {
-			err = p.cli.UpdateFileSystem(ctx, objID, data)
-		} else {
-			err = p.cli.UpdateLun(ctx, objID, data)
-		}
+	data := map[string]interface{}{
+		"IOPRIORITY": 3,
+	}
 
-		if err != nil {
-			log.AddContext(ctx).Errorf("Upgrade obj %s of type %s IOPRIORITY error: %v", objID, objID, err)
-			return "", err
-		}
+	var err error
+	if objType == "fs" {
+		err = p.cli.UpdateFileSystem(ctx, objID, data)
+	} else {
+		err = p.cli.UpdateLun(ctx, objID, data)
 	}
 
-	name := p.getQosName(objID, objType)
-	qos, err := p.cli.CreateQos(ctx, name, objID, objType, vStoreID, params)
 	if err != nil {
-		log.AddContext(ctx).Errorf("Create qos %v for obj %s of type %s error: %v",
-			params, objID, objType, err)
-		return "", err
+		log.AddContext(ctx).Errorf("Upgrade obj %s of type %s IOPRIORITY error: %v", objID, objType, err)
+		return err
 	}
 
+	return nil
+}
+
+func (p *SmartX) ensureQosActive(ctx context.Context, qos map[string]interface{}, vStoreID string) (string, error) {
 	qosID, ok := qos["ID"].(string)
 	if !ok {
 		return "", errors.New("qos ID is expected as string")
@@ -308,28 +333,183 @@ func (p *SmartX) CreateQos(ctx context.Context,
 	return qosID, nil
 }
 
-func (p *SmartX) DeleteQos(ctx context.Context, qosID, objID, objType, vStoreID string) error {
+func (p *SmartX) CreateQos(ctx context.Context,
+	objID, objType, vStoreID string,
+	params map[string]int) (string, error) {
+	err := p.upgradeIOPriority(ctx, objID, objType, params)
+	if err != nil {
+		return "", err
+	}
+
+	name := p.getQosName(objID, objType)
+	qos, err := p.cli.CreateQos(ctx, name, objID, objType, vStoreID, params)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Create qos %v for obj %s of type %s error: %v",
+			params, objID, objType, err)
+		return "", err
+	}
+
+	return p.ensureQosActive(ctx, qos, vStoreID)
+}
+
+// CreateSharedQos attaches the object to a SmartQoS policy shared by all objects with identical QoS
+// parameters. A new policy is created only when no policy exists yet or all of them are full.
+func (p *SmartX) CreateSharedQos(ctx context.Context,
+	objID, objType, vStoreID string,
+	params map[string]int) (string, error) {
+	err := p.upgradeIOPriority(ctx, objID, objType, params)
+	if err != nil {
+		return "", err
+	}
+
+	sharedQosMutex.Lock()
+	defer sharedQosMutex.Unlock()
+
+	for index := 0; index < maxSharedQosPolicies; index++ {
+		name := p.getSharedQosName(objType, params, index)
+		qos, err := p.cli.GetQosByName(ctx, name, vStoreID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Get shared qos %s error: %v", name, err)
+			return "", err
+		}
+
+		if qos == nil {
+			qos, err = p.cli.CreateQos(ctx, name, objID, objType, vStoreID, params)
+			if err != nil {
+				log.AddContext(ctx).Errorf("Create shared qos %s for obj %s of type %s error: %v",
+					name, objID, objType, err)
+				return "", err
+			}
+
+			log.AddContext(ctx).Infof("Create shared qos %s for obj %s of type %s", name, objID, objType)
+			return p.ensureQosActive(ctx, qos, vStoreID)
+		}
+
+		members, err := p.getQosMembers(ctx, qos, objType)
+		if err != nil {
+			return "", err
+		}
+
+		if utils.IsContain(objID, members) {
+			return p.ensureQosActive(ctx, qos, vStoreID)
+		}
+
+		if len(members) >= MaxSharedQosMembers {
+			log.AddContext(ctx).Debugf("Shared qos %s is full, try the next one", name)
+			continue
+		}
+
+		qosID, err := p.ensureQosActive(ctx, qos, vStoreID)
+		if err != nil {
+			return "", err
+		}
+
+		err = p.cli.UpdateQos(ctx, qosID, vStoreID, map[string]interface{}{
+			p.getQosListKey(objType): append(members, objID),
+		})
+		if err != nil {
+			log.AddContext(ctx).Errorf("Add obj %s of type %s to shared qos %s error: %v",
+				objID, objType, qosID, err)
+			return "", err
+		}
+
+		log.AddContext(ctx).Infof("Add obj %s of type %s to shared qos %s", objID, objType, name)
+		return qosID, nil
+	}
+
+	return "", fmt.Errorf("all shared qos policies for parameters %v are full", params)
+}
+
+// UpdateQos sets the QoS parameters of the object and returns the ID of its SmartQoS policy. The policy
+// is updated in place if the object is its only member, otherwise the object leaves the policy shared with
+// other objects for a dedicated one, so that the others keep their QoS.
+func (p *SmartX) UpdateQos(ctx context.Context,
+	objID, objType, vStoreID, qosID string,
+	params map[string]int) (string, error) {
+	if qosID == "" {
+		return p.CreateQos(ctx, objID, objType, vStoreID, params)
+	}
+
 	qos, err := p.cli.GetQosByID(ctx, qosID, vStoreID)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Get qos by ID %s error: %v", qosID, err)
-		return err
+		return "", err
 	}
 
-	var objList []string
+	members, err := p.getQosMembers(ctx, qos, objType)
+	if err != nil {
+		return "", err
+	}
+
+	if len(members) > 1 || !utils.IsContain(objID, members) {
+		log.AddContext(ctx).Infof("Qos %s is shared by %v, move obj %s of type %s to a dedicated qos",
+			qosID, members, objID, objType)
+		err = p.DeleteQos(ctx, qosID, objID, objType, vStoreID)
+		if err != nil {
+			return "", err
+		}
+		return p.CreateQos(ctx, objID, objType, vStoreID, params)
+	}
 
-	listObj := "LUNLIST"
+	err = p.upgradeIOPriority(ctx, objID, objType, params)
+	if err != nil {
+		return "", err
+	}
+
+	data := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		data[k] = v
+	}
+	err = p.cli.UpdateQos(ctx, qosID, vStoreID, data)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Update qos %s to %v error: %v", qosID, params, err)
+		return "", err
+	}
+
+	return qosID, nil
+}
+
+func (p *SmartX) getQosListKey(objType string) string {
 	if objType == "fs" {
-		listObj = "FSLIST"
+		return "FSLIST"
 	}
+	return "LUNLIST"
+}
+
+func (p *SmartX) getQosMembers(ctx context.Context, qos map[string]interface{}, objType string) ([]string, error) {
+	var objList []string
 
-	listStr, ok := qos[listObj].(string)
+	listStr, ok := qos[p.getQosListKey(objType)].(string)
 	if !ok {
-		return errors.New("qos volume list is expected as marshaled string")
+		return nil, errors.New("qos volume list is expected as marshaled string")
 	}
 
-	err = json.Unmarshal([]byte(listStr), &objList)
+	if listStr == "" {
+		return objList, nil
+	}
+
+	err := json.Unmarshal([]byte(listStr), &objList)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Unmarshal %s error: %v", listStr, err)
+		return nil, err
+	}
+
+	return objList, nil
+}
+
+func (p *SmartX) DeleteQos(ctx context.Context, qosID, objID, objType, vStoreID string) error {
+	sharedQosMutex.Lock()
+	defer sharedQosMutex.Unlock()
+
+	qos, err := p.cli.GetQosByID(ctx, qosID, vStoreID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get qos by ID %s error: %v", qosID, err)
+		return err
+	}
+
+	listObj := p.getQosListKey(objType)
+	objList, err := p.getQosMembers(ctx, qos, objType)
+	if err != nil {
 		return err
 	}
 
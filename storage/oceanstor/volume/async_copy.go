@@ -0,0 +1,135 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"strings"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// isAsyncCopy returns whether the clone target is returned once its copy is started, the copy is then
+// completed by CompleteCopy
+func isAsyncCopy(params map[string]interface{}) bool {
+	asyncCopy, _ := params["asyncCopy"].(bool)
+	return asyncCopy
+}
+
+// isCloneSnapshot returns whether the snapshot is the one created to clone a LUN by luncopy, which is
+// deleted together with the luncopy
+func isCloneSnapshot(snapshotName string) bool {
+	return strings.HasPrefix(snapshotName, "k8s_lun_") && strings.HasSuffix(snapshotName, "_snap")
+}
+
+// CopyProgress is the progress of the asynchronous copy of a LUN
+type CopyProgress struct {
+	// Copying is true while the data is being copied
+	Copying bool
+	// Usable is true if the LUN can be used while it is copying, which is the target of a clone pair
+	Usable bool
+	// Completed is only true for the check which finds the copy finished and deletes it
+	Completed bool
+}
+
+// CompleteCopy checks the clone pair or luncopy of a LUN created by asynchronous copy, which is
+// deleted once the data is copied fully
+func (p *SAN) CompleteCopy(ctx context.Context, name string) (CopyProgress, error) {
+	lunName := utils.GetLunName(name)
+	lun, err := p.cli.GetLunByName(ctx, lunName)
+	if err != nil {
+		return CopyProgress{}, err
+	}
+	if lun == nil {
+		return CopyProgress{}, utils.KindErrorf(ctx, utils.ErrNotFound, "LUN %s does not exist", lunName)
+	}
+
+	lunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return CopyProgress{}, err
+	}
+
+	if p.product == "DoradoV6" {
+		// ID of clone pair is the same as destination LUN ID
+		clonePair, err := p.cli.GetClonePairInfo(ctx, lunID)
+		if err != nil {
+			return CopyProgress{}, err
+		}
+		if clonePair != nil {
+			return p.completeClonePair(ctx, lunID, clonePair)
+		}
+	}
+
+	return p.completeLunCopy(ctx, lunID)
+}
+
+func (p *SAN) completeClonePair(ctx context.Context, lunID string,
+	clonePair map[string]interface{}) (CopyProgress, error) {
+	finished, err := isClonePairFinished(lunID, clonePair)
+	if err != nil {
+		return CopyProgress{}, err
+	}
+	if !finished {
+		return CopyProgress{Copying: true, Usable: true}, nil
+	}
+
+	err = p.cli.DeleteClonePair(ctx, lunID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Delete finished ClonePair %s error: %v", lunID, err)
+		return CopyProgress{}, err
+	}
+
+	log.AddContext(ctx).Infof("ClonePair %s is finished and deleted", lunID)
+	return CopyProgress{Completed: true}, nil
+}
+
+func (p *SAN) completeLunCopy(ctx context.Context, lunID string) (CopyProgress, error) {
+	lunCopyName, err := p.getLunCopyOfLunID(ctx, lunID)
+	if err != nil {
+		return CopyProgress{}, err
+	}
+	if lunCopyName == "" {
+		return CopyProgress{}, nil
+	}
+
+	lunCopy, err := p.cli.GetLunCopyByName(ctx, lunCopyName)
+	if err != nil {
+		return CopyProgress{}, err
+	}
+	if lunCopy == nil {
+		return CopyProgress{}, nil
+	}
+
+	finished, err := isLunCopyFinished(lunCopyName, lunCopy)
+	if err != nil {
+		return CopyProgress{}, err
+	}
+	if !finished {
+		return CopyProgress{Copying: true}, nil
+	}
+
+	snapshotName, _ := lunCopy["SOURCELUNNAME"].(string)
+	err = p.deleteLunCopy(ctx, lunCopyName, isCloneSnapshot(snapshotName))
+	if err != nil {
+		log.AddContext(ctx).Errorf("Delete finished luncopy %s error: %v", lunCopyName, err)
+		return CopyProgress{}, err
+	}
+
+	log.AddContext(ctx).Infof("Luncopy %s is finished and deleted", lunCopyName)
+	return CopyProgress{Completed: true}, nil
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
 	"huawei-csi-driver/storage/oceanstor/client"
 	"huawei-csi-driver/storage/oceanstor/smartx"
@@ -28,6 +29,15 @@ import (
 	"huawei-csi-driver/utils/log"
 )
 
+const (
+	// MissingApplicationTypeFail refuses the volume whose applicationType does not exist on storage
+	MissingApplicationTypeFail = "fail"
+	// MissingApplicationTypeCreate creates the applicationType missing on storage
+	MissingApplicationTypeCreate = "create"
+	// MissingApplicationTypeDefault creates the volume with the default workload instead
+	MissingApplicationTypeDefault = "default"
+)
+
 type Base struct {
 	cli              client.BaseClientInterface
 	metroRemoteCli   client.BaseClientInterface
@@ -99,8 +109,46 @@ func (p *Base) getPoolID(ctx context.Context, params map[string]interface{}) err
 		return fmt.Errorf("storage pool %s doesn't exist", poolName)
 	}
 
-	params["poolID"] = pool["ID"].(string)
+	poolID, err := utils.GetStringField(pool, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "get ID of storage pool %s error: %v", poolName, err)
+	}
+	params["poolID"] = poolID
+
+	return p.checkPoolReserve(ctx, pool, params)
+}
+
+// checkPoolReserve refuses the volume if the free capacity of the pool would fall below the reserve
+// in params, which is checked with the capacity cached at pool selection and again here with the
+// latest capacity. Thin volumes take no capacity until they are written, so only thick volumes
+// are deducted.
+func (p *Base) checkPoolReserve(ctx context.Context, pool, params map[string]interface{}) error {
+	reserve, exist := params["poolreserve"].(float64)
+	if !exist {
+		return nil
+	}
+
+	freeCapacity, err := utils.ParseSectors(pool, "USERFREECAPACITY")
+	if err != nil {
+		return utils.Errorf(ctx, "get free capacity of storage pool %s error: %v", pool["NAME"], err)
+	}
+	totalCapacity, err := utils.ParseSectors(pool, "USERTOTALCAPACITY")
+	if err != nil {
+		return utils.Errorf(ctx, "get total capacity of storage pool %s error: %v", pool["NAME"], err)
+	}
+	if totalCapacity <= 0 {
+		return nil
+	}
 
+	if params["alloctype"] == 0 {
+		capacity, _ := params["capacity"].(int64)
+		freeCapacity -= utils.CapacityFromSectors(capacity)
+	}
+	freePercent := float64(freeCapacity) * 100 / float64(totalCapacity)
+	if freePercent < reserve {
+		return utils.KindErrorf(ctx, utils.ErrPoolReserveReached,
+			"storage pool %s would be %.1f%% free, below its reserve %.1f%%", pool["NAME"], freePercent, reserve)
+	}
 	return nil
 }
 
@@ -121,6 +169,59 @@ func (p *Base) getQoS(ctx context.Context, params map[string]interface{}) error
 	return nil
 }
 
+// createQos creates a dedicated SmartQoS policy for the object, or attaches it to a policy
+// shared by objects with identical QoS parameters when qosShared is requested
+func (p *Base) createQos(ctx context.Context, cli client.BaseClientInterface,
+	objID, objType, vStoreID string, qos map[string]int, params map[string]interface{}) (string, error) {
+	smartX := smartx.NewSmartX(cli)
+	if shared, _ := params["qosShared"].(bool); shared {
+		return smartX.CreateSharedQos(ctx, objID, objType, vStoreID, qos)
+	}
+
+	return smartX.CreateQos(ctx, objID, objType, vStoreID, qos)
+}
+
+// updateQos sets the parameters of the SmartQoS policy of the object, a policy is created if the
+// object has none, or if its policy is shared with other objects
+func (p *Base) updateQos(ctx context.Context, cli client.BaseClientInterface,
+	objID, objType, vStoreID, qosID string, qos map[string]int) error {
+	_, err := smartx.NewSmartX(cli).UpdateQos(ctx, objID, objType, vStoreID, qosID, qos)
+	return err
+}
+
+// checkExistVolume checks whether an existing LUN or filesystem of the same name, which may be
+// left by a previous partially failed request, is in the requested pool and compatible with the
+// requested capacity
+func (p *Base) checkExistVolume(ctx context.Context,
+	name string, obj, params map[string]interface{}) error {
+	// A clone may be created in the pool of the source and with the source capacity, and extended afterwards
+	_, cloneExist := params["clonefrom"]
+	_, snapshotExist := params["fromSnapshot"]
+	poolID, ok := params["poolID"].(string)
+	if ok && !cloneExist && !snapshotExist && obj["PARENTID"] != poolID {
+		return utils.VolumeConflictf(ctx, "%s already exists in pool %v, but pool %v is requested",
+			name, obj["PARENTNAME"], params["storagepool"])
+	}
+
+	capacity, ok := params["capacity"].(int64)
+	if !ok {
+		return nil
+	}
+
+	existCapacity, err := utils.ParseSectors(obj, "CAPACITY")
+	if err != nil {
+		return utils.Errorf(ctx, "get capacity of %s error: %v", name, err)
+	}
+
+	requested := utils.CapacityFromSectors(capacity)
+	if existCapacity > requested || (existCapacity < requested && !cloneExist && !snapshotExist) {
+		return utils.VolumeConflictf(ctx, "%s already exists with capacity %d bytes, but %d bytes is requested",
+			name, existCapacity.Bytes(), requested.Bytes())
+	}
+
+	return nil
+}
+
 func (p *Base) getRemotePoolID(ctx context.Context,
 	params map[string]interface{}, remoteCli client.BaseClientInterface) (string, error) {
 	remotePool, exist := params["remotestoragepool"].(string)
@@ -139,7 +240,7 @@ func (p *Base) getRemotePoolID(ctx context.Context,
 		return "", fmt.Errorf("remote storage pool %s doesn't exist", remotePool)
 	}
 
-	return pool["ID"].(string), nil
+	return utils.GetStringField(pool, "ID")
 }
 
 func (p *Base) preExpandCheckCapacity(ctx context.Context,
@@ -156,13 +257,15 @@ func (p *Base) preExpandCheckCapacity(ctx context.Context,
 	return nil, nil
 }
 
-func (p *Base) getSnapshotReturnInfo(snapshot map[string]interface{}, snapshotSize int64) map[string]interface{} {
-	snapshotCreated, _ := strconv.ParseInt(snapshot["TIMESTAMP"].(string), 10, 64)
-	snapshotSizeBytes := snapshotSize * 512
+func (p *Base) getSnapshotReturnInfo(snapshot map[string]interface{},
+	snapshotSize utils.Capacity) map[string]interface{} {
+	timestamp, _ := snapshot["TIMESTAMP"].(string)
+	parentID, _ := snapshot["PARENTID"].(string)
+	snapshotCreated, _ := strconv.ParseInt(timestamp, 10, 64)
 	return map[string]interface{}{
 		"CreationTime": snapshotCreated,
-		"SizeBytes":    snapshotSizeBytes,
-		"ParentID":     snapshot["PARENTID"].(string),
+		"SizeBytes":    snapshotSize.Bytes(),
+		"ParentID":     parentID,
 	}
 }
 
@@ -183,19 +286,12 @@ func (p *Base) createReplicationPair(ctx context.Context,
 	}
 
 	data := map[string]interface{}{
-		"LOCALRESID":       localID,
-		"LOCALRESTYPE":     resType,
-		"REMOTEDEVICEID":   remoteDeviceID,
-		"REMOTERESID":      remoteID,
-		"REPLICATIONMODEL": 2, // asynchronous replication
-		"SYNCHRONIZETYPE":  2, // timed wait after synchronization begins
-		"SPEED":            4, // highest speed
-	}
-
-	replicationSyncPeriod, exist := params["replicationSyncPeriod"]
-	if exist {
-		data["TIMINGVAL"] = replicationSyncPeriod
+		"LOCALRESID":     localID,
+		"LOCALRESTYPE":   resType,
+		"REMOTEDEVICEID": remoteDeviceID,
+		"REMOTERESID":    remoteID,
 	}
+	setReplicationPairOptions(params, data)
 
 	vStorePairID, exist := taskResult["vStorePairID"]
 	if exist {
@@ -208,10 +304,19 @@ func (p *Base) createReplicationPair(ctx context.Context,
 		return nil, err
 	}
 
-	pairID := pair["ID"].(string)
-	err = p.cli.SyncReplicationPair(ctx, pairID)
+	pairID, err := utils.GetStringField(pair, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of replication pair error: %v", err)
+	}
+	if groupName, _ := params["replicationGroup"].(string); groupName != "" {
+		err = p.joinReplicationGroup(ctx, groupName, pairID, data["REPLICATIONMODEL"].(int))
+	} else {
+		err = p.cli.SyncReplicationPair(ctx, pairID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Sync replication pair %s error: %v", pairID, err)
+		}
+	}
 	if err != nil {
-		log.AddContext(ctx).Errorf("Sync replication pair %s error: %v", pairID, err)
 		p.cli.DeleteReplicationPair(ctx, pairID)
 		return nil, err
 	}
@@ -238,36 +343,107 @@ func (p *Base) getRemoteDeviceID(ctx context.Context, deviceSN string) (string,
 		return "", errors.New(msg)
 	}
 
-	return remoteDevice["ID"].(string), nil
+	return utils.GetStringField(remoteDevice, "ID")
 }
 
+// getWorkLoadIDByName returns the ID of the workload type, the one missing on storage is created or
+// replaced by the default workload by missing, which is the missingApplicationType of the backend
 func (p *Base) getWorkLoadIDByName(ctx context.Context,
 	cli client.BaseClientInterface,
-	workloadTypeName string) (string, error) {
+	workloadTypeName, missing string) (string, error) {
 	workloadTypeID, err := cli.GetApplicationTypeByName(ctx, workloadTypeName)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Get application types returned error: %v", err)
 		return "", err
 	}
-	if workloadTypeID == "" {
-		msg := fmt.Sprintf("The workloadType %s does not exist on storage", workloadTypeName)
-		log.AddContext(ctx).Errorln(msg)
-		return "", errors.New(msg)
+	if workloadTypeID != "" {
+		return workloadTypeID, nil
+	}
+
+	switch missing {
+	case MissingApplicationTypeCreate:
+		return p.createWorkLoad(ctx, cli, workloadTypeName)
+	case MissingApplicationTypeDefault:
+		log.AddContext(ctx).Warningf("The workloadType %s does not exist on storage, "+
+			"the volume uses the default workload", workloadTypeName)
+		return "", nil
+	}
+
+	msg := fmt.Sprintf("The workloadType %s does not exist on storage", workloadTypeName)
+	log.AddContext(ctx).Errorln(msg)
+	return "", errors.New(msg)
+}
+
+func (p *Base) createWorkLoad(ctx context.Context, cli client.BaseClientInterface,
+	workloadTypeName string) (string, error) {
+	workloadTypeID, err := cli.CreateApplicationType(ctx, workloadTypeName)
+	if err != nil {
+		// The workload type may be created by another volume at the same time
+		existID, getErr := cli.GetApplicationTypeByName(ctx, workloadTypeName)
+		if getErr == nil && existID != "" {
+			return existID, nil
+		}
+
+		log.AddContext(ctx).Errorf("Create workloadType %s error: %v", workloadTypeName, err)
+		return "", err
 	}
+
+	log.AddContext(ctx).Infof("The workloadType %s is created on storage", workloadTypeName)
 	return workloadTypeID, nil
 }
 
 func (p *Base) setWorkLoadID(ctx context.Context, cli client.BaseClientInterface, params map[string]interface{}) error {
 	if val, ok := params["applicationtype"].(string); ok {
-		workloadTypeID, err := p.getWorkLoadIDByName(ctx, cli, val)
+		missing, _ := params["missingapplicationtype"].(string)
+		workloadTypeID, err := p.getWorkLoadIDByName(ctx, cli, val, missing)
 		if err != nil {
 			return err
 		}
+		if workloadTypeID != "" {
+			params["workloadTypeID"] = workloadTypeID
+		}
+	} else if val, ok := params["hintapplicationtype"].(string); ok {
+		// The workload type of a workload hint is a preference, which is skipped if the storage lacks it
+		workloadTypeID, err := cli.GetApplicationTypeByName(ctx, val)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Get application types returned error: %v", err)
+			return err
+		}
+		if workloadTypeID == "" {
+			log.AddContext(ctx).Warningf("The workloadType %s of the workload hint does not exist on storage", val)
+			return nil
+		}
 		params["workloadTypeID"] = workloadTypeID
 	}
 	return nil
 }
 
+// setSmartCachePartitionID validates the SmartCache partition exists on storage, and sets its ID in params
+func (p *Base) setSmartCachePartitionID(ctx context.Context, cli client.BaseClientInterface,
+	params map[string]interface{}) error {
+	partitionName, ok := params["cachepartition"].(string)
+	if !ok {
+		return nil
+	}
+
+	partition, err := cli.GetSmartCachePartitionByName(ctx, partitionName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get SmartCache partition %s error: %v", partitionName, err)
+		return err
+	}
+	if partition == nil {
+		return utils.KindErrorf(ctx, utils.ErrNotFound, "The SmartCache partition %s does not exist on storage",
+			partitionName)
+	}
+
+	partitionID, err := utils.GetStringField(partition, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of SmartCache partition %s error: %v", partitionName, err)
+	}
+	params["cachePartitionID"] = partitionID
+	return nil
+}
+
 func (p *Base) prepareVolObj(ctx context.Context, params, res map[string]interface{}) utils.Volume {
 	volName, isStr := params["name"].(string)
 	if !isStr {
@@ -282,3 +458,23 @@ func (p *Base) prepareVolObj(ctx context.Context, params, res map[string]interfa
 	}
 	return volObj
 }
+
+// waitReplicationPairSplit waits for the split of a synchronous replication pair, which completes only
+// after the writes being mirrored to the remote volume, whose extension is refused until then
+func (p *Base) waitReplicationPairSplit(ctx context.Context, pairID string) error {
+	return utils.WaitUntil(func() (bool, error) {
+		pair, err := p.cli.GetReplicationPairByID(ctx, pairID)
+		if err != nil {
+			return false, err
+		}
+		if pair == nil {
+			return false, utils.Errorf(ctx, "Replication pair %s does not exist", pairID)
+		}
+
+		runningStatus, err := utils.GetStringField(pair, "RUNNINGSTATUS")
+		if err != nil {
+			return false, err
+		}
+		return runningStatus == replicationPairRunningStatusSplit, nil
+	}, time.Minute*5, time.Second*2)
+}
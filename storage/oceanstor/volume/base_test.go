@@ -0,0 +1,56 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/utils"
+)
+
+func TestCheckExistVolume(t *testing.T) {
+	obj := map[string]interface{}{"NAME": "pvc-1", "PARENTID": "1", "PARENTNAME": "pool1", "CAPACITY": "2097152"}
+
+	tests := []struct {
+		name     string
+		params   map[string]interface{}
+		conflict bool
+	}{
+		{"Same", map[string]interface{}{"poolID": "1", "capacity": int64(2097152)}, false},
+		{"Other pool", map[string]interface{}{"poolID": "2", "storagepool": "pool2", "capacity": int64(2097152)},
+			true},
+		{"Larger", map[string]interface{}{"poolID": "1", "capacity": int64(4194304)}, true},
+		{"Smaller", map[string]interface{}{"poolID": "1", "capacity": int64(1048576)}, true},
+		{"Clone in pool of source", map[string]interface{}{"poolID": "2", "clonefrom": "pvc-0",
+			"capacity": int64(4194304)}, false},
+		{"Capacity not requested", map[string]interface{}{"poolID": "1"}, false},
+	}
+
+	p := &Base{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.checkExistVolume(ctx, "pvc-1", obj, tt.params)
+			assert.Equal(t, tt.conflict, errors.Is(err, utils.ErrVolumeConflict))
+			if !tt.conflict {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	// CapacityAlarmThresholdMin is the lowest capacityAlarmThreshold of a thin LUN in percent
+	CapacityAlarmThresholdMin = 1
+	// CapacityAlarmThresholdMax is the highest capacityAlarmThreshold of a thin LUN in percent
+	CapacityAlarmThresholdMax = 99
+
+	// VolumeCapacityThresholdReached is the alarm of a thin LUN whose allocated capacity reaches its threshold
+	VolumeCapacityThresholdReached = "VolumeCapacityThresholdReached"
+	// PoolCapacityThresholdReached is the alarm of a pool whose used capacity reaches its alarm threshold
+	PoolCapacityThresholdReached = "PoolCapacityThresholdReached"
+	// PoolOverSubscribed is the alarm of a pool which reaches its alarm threshold while the capacity of
+	// its LUNs exceeds its own capacity, so the writes to the thin LUNs may fail once it is full
+	PoolOverSubscribed = "PoolOverSubscribed"
+)
+
+// setCapacityAlarmThreshold converts the capacityalarmthreshold parameter to the CAPACITYTHRESHOLD of
+// the LUN, the share of its capacity allocated above which the storage raises an alarm
+func (p *SAN) setCapacityAlarmThreshold(ctx context.Context, params map[string]interface{}) error {
+	v, exist := params["capacityalarmthreshold"].(string)
+	if !exist || v == "" {
+		return nil
+	}
+
+	threshold, err := strconv.Atoi(v)
+	if err != nil || threshold < CapacityAlarmThresholdMin || threshold > CapacityAlarmThresholdMax {
+		return utils.Errorf(ctx, "invalid capacityAlarmThreshold %s", v)
+	}
+	if params["alloctype"] != 1 {
+		return utils.Errorf(ctx, "capacityAlarmThreshold is only for thin LUNs")
+	}
+
+	params["capacitythreshold"] = threshold
+	return nil
+}
+
+// GetCapacityAlarms returns the capacity alarms of the LUN and of its pool by alarm. The threshold
+// of the LUN is checked against its allocated capacity, and the pool against its own usage alarm
+// threshold, and over-subscription is reported along with the alarm of the pool.
+func (p *SAN) GetCapacityAlarms(ctx context.Context, name string) (map[string]string, error) {
+	lunName := utils.GetLunName(name)
+	lun, err := p.cli.GetLunByName(ctx, lunName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun by name %s error: %v", lunName, err)
+		return nil, err
+	}
+	if lun == nil {
+		return nil, utils.KindErrorf(ctx, utils.ErrNotFound, "Lun %s to check capacity alarms does not exist", lunName)
+	}
+
+	alarms := map[string]string{}
+	threshold := parsePercentField(lun, "CAPACITYTHRESHOLD")
+	if threshold > 0 {
+		capacity, err := utils.ParseSectors(lun, "CAPACITY")
+		if err != nil {
+			return nil, utils.Errorf(ctx, "Get capacity of lun %s error: %v", lunName, err)
+		}
+		allocated, err := utils.ParseSectors(lun, "ALLOCCAPACITY")
+		if err != nil {
+			return nil, utils.Errorf(ctx, "Get allocated capacity of lun %s error: %v", lunName, err)
+		}
+		if capacity > 0 {
+			if usage := allocated.Bytes() * 100 / capacity.Bytes(); usage >= threshold {
+				alarms[VolumeCapacityThresholdReached] = fmt.Sprintf("%d%% of the volume is allocated on "+
+					"storage, which reaches its alarm threshold %d%%", usage, threshold)
+			}
+		}
+	}
+
+	poolName, _ := lun["PARENTNAME"].(string)
+	pool, err := p.cli.GetPoolByName(ctx, poolName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get storage pool %s info error: %v", poolName, err)
+		return nil, err
+	}
+	if pool == nil {
+		return alarms, nil
+	}
+
+	used := parsePercentField(pool, "USERCONSUMEDCAPACITYPERCENTAGE")
+	poolThreshold := parsePercentField(pool, "USERCONSUMEDCAPACITYTHRESHOLD")
+	if poolThreshold == 0 || used < poolThreshold {
+		return alarms, nil
+	}
+
+	total, err := utils.ParseSectors(pool, "USERTOTALCAPACITY")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get total capacity of storage pool %s error: %v", poolName, err)
+	}
+	// the arrays which don't report the capacity of the LUNs of the pool are never over-subscribed
+	subscribed, err := utils.ParseSectors(pool, "LUNCONFIGEDCAPACITY")
+	if err == nil && total > 0 && subscribed > total {
+		alarms[PoolOverSubscribed] = fmt.Sprintf("storage pool %s is %d%% used, which reaches its alarm "+
+			"threshold %d%%, and its volumes are %d%% of its capacity", poolName, used, poolThreshold,
+			subscribed*100/total)
+	} else {
+		alarms[PoolCapacityThresholdReached] = fmt.Sprintf("storage pool %s is %d%% used, which reaches "+
+			"its alarm threshold %d%%", poolName, used, poolThreshold)
+	}
+	return alarms, nil
+}
+
+// parsePercentField returns the percentage of the field of the object, 0 if it is not set
+func parsePercentField(obj map[string]interface{}, field string) int64 {
+	value, _ := strconv.ParseInt(fmt.Sprint(obj[field]), 10, 64)
+	return value
+}
@@ -30,14 +30,22 @@ const (
 
 	replicationPairRunningStatusNormal = "1"
 	replicationPairRunningStatusSync   = "23"
+	replicationPairRunningStatusSplit  = "26"
 
 	replicationVStorePairRunningStatusNormal = "1"
 	replicationVStorePairRunningStatusSync   = "23"
 
 	replicationRolePrimary = "0"
 
+	replicationSecResAccessReadWrite = "3"
+
+	vStorePairLinkStatusConnected = "1"
+
 	systemVStore = "0"
 
+	// cloneTargetDescription marks a clone target LUN whose copy has not been started yet
+	cloneTargetDescription = "Cloning from Kubernetes CSI"
+
 	hyperMetroPairHealthStatusFault = "2"
 
 	hyperMetroPairRunningStatusUnknown = "0"
@@ -62,6 +70,10 @@ const (
 	clonePairRunningStatusNormal       = "2"
 	clonePairRunningStatusInitializing = "3"
 
-	snapshotRunningStatusActive   = "43"
-	snapshotRunningStatusInactive = "45"
+	snapshotRunningStatusActive      = "43"
+	snapshotRunningStatusRollingBack = "44"
+	snapshotRunningStatusInactive    = "45"
+
+	lunMigrationRunningStatusFault    = "74"
+	lunMigrationRunningStatusComplete = "76"
 )
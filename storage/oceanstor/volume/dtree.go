@@ -0,0 +1,338 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+
+	"huawei-csi-driver/storage/oceanstor/client"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+	"huawei-csi-driver/utils/taskflow"
+)
+
+// DTree provisions the volumes as dtrees of a parent filesystem, each one limited by a directory quota
+// and exported by its own NFS share. The dtrees are lighter than filesystems, so that an array hosts
+// many more of them.
+type DTree struct {
+	NAS
+
+	parentName string
+}
+
+// NewDTree returns the DTree provisioning the dtrees in the parent filesystem
+func NewDTree(cli client.BaseClientInterface, product, parentName string) *DTree {
+	return &DTree{
+		NAS:        *NewNAS(cli, nil, nil, product, NASHyperMetro{}, ShareProtocolNFS),
+		parentName: parentName,
+	}
+}
+
+// GetDTreeSharePath returns the path of the NFS share of the dtree in the parent filesystem
+func GetDTreeSharePath(parentName, name string) string {
+	return "/" + parentName + "/" + utils.GetFileSystemName(name) + "/"
+}
+
+func (p *DTree) preCreate(ctx context.Context, params map[string]interface{}) error {
+	if _, exist := params["authclient"].(string); !exist {
+		return utils.Errorln(ctx, "authclient must be provided for dtree")
+	}
+
+	for _, key := range []string{"sourcevolumename", "sourcesnapshotname", "clonefrom"} {
+		if _, exist := params[key]; exist {
+			return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+				"dtree volumes can't be created from volumes or snapshots")
+		}
+	}
+
+	params["name"] = utils.GetFileSystemName(params["name"].(string))
+	err := parseQuota(ctx, params)
+	if err != nil {
+		return err
+	}
+	err = p.parseNFSSecurity(ctx, params)
+	if err != nil {
+		return err
+	}
+	return parseSquash(ctx, params)
+}
+
+// Create creates the dtree with its quota and NFS share, whose clients are set to authclient
+func (p *DTree) Create(ctx context.Context, params map[string]interface{}) (utils.Volume, error) {
+	err := p.preCreate(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	taskflow := taskflow.NewTaskFlow(ctx, "Create-DTree-Volume")
+	taskflow.AddTask("Create-DTree", p.createDTree, p.revertDTree)
+	taskflow.AddTask("Create-DTree-Quota", p.createDTreeQuota, p.revertDTreeQuota)
+	taskflow.AddTask("Create-DTree-Share", p.createDTreeShare, p.revertShare)
+	taskflow.AddTask("Allow-Share-Access", p.allowShareAccess, p.revertShareAccess)
+
+	_, err = taskflow.Run(params)
+	if err != nil {
+		taskflow.Revert()
+		return nil, err
+	}
+
+	return p.prepareVolObj(ctx, params, nil), nil
+}
+
+func (p *DTree) createDTree(ctx context.Context,
+	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+	name := params["name"].(string)
+	parent, err := p.cli.GetFileSystemByName(ctx, p.parentName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get parent filesystem %s error: %v", p.parentName, err)
+		return nil, err
+	} else if parent == nil {
+		return nil, utils.KindErrorf(ctx, utils.ErrNotFound, "Parent filesystem %s of dtree %s does not exist",
+			p.parentName, name)
+	}
+
+	parentID, err := utils.GetStringField(parent, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of filesystem %s error: %v", p.parentName, err)
+	}
+
+	dTree, err := p.cli.GetDTreeByName(ctx, p.parentName, name)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get dtree %s error: %v", name, err)
+		return nil, err
+	}
+
+	created := false
+	if dTree == nil {
+		dTree, err = p.cli.CreateDTree(ctx, p.parentName, name)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Create dtree %s in filesystem %s error: %v", name, p.parentName, err)
+			return nil, err
+		}
+		created = true
+	}
+
+	dTreeID, err := utils.GetStringField(dTree, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of dtree %s error: %v", name, err)
+	}
+
+	result := map[string]interface{}{
+		"parentFSID": parentID,
+		"dTreeID":    dTreeID,
+	}
+	// a dtree left by an earlier attempt is reused, but not deleted on failure
+	if created {
+		result["createdDTreeID"] = dTreeID
+	}
+	return result, nil
+}
+
+func (p *DTree) revertDTree(ctx context.Context, taskResult map[string]interface{}) error {
+	dTreeID, exist := taskResult["createdDTreeID"].(string)
+	if !exist {
+		return nil
+	}
+	return p.cli.DeleteDTree(ctx, dTreeID)
+}
+
+func (p *DTree) createDTreeQuota(ctx context.Context,
+	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+	limits := map[string]interface{}{
+		"SPACEHARDQUOTA": utils.CapacityFromSectors(params["capacity"].(int64)).Bytes(),
+	}
+	if quota, exist := params["quota"].(*Quota); exist {
+		for k, v := range quota.limits() {
+			limits[k] = v
+		}
+	}
+
+	quotaID, err := p.setQuota(ctx, client.ParentTypeDTree, taskResult["dTreeID"].(string), limits)
+	if err != nil {
+		return nil, err
+	}
+
+	if quotaID == "" {
+		return nil, nil
+	}
+	return map[string]interface{}{
+		"quotaID": quotaID,
+	}, nil
+}
+
+func (p *DTree) revertDTreeQuota(ctx context.Context, taskResult map[string]interface{}) error {
+	quotaID, exist := taskResult["quotaID"].(string)
+	if !exist {
+		return nil
+	}
+	return p.cli.DeleteQuota(ctx, quotaID)
+}
+
+func (p *DTree) createDTreeShare(ctx context.Context,
+	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+	sharePath := GetDTreeSharePath(p.parentName, params["name"].(string))
+	share, err := p.cli.GetNfsShareByPath(ctx, sharePath, "")
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get nfs share by path %s error: %v", sharePath, err)
+		return nil, err
+	}
+
+	if share == nil {
+		shareParams := map[string]interface{}{
+			"sharepath":   sharePath,
+			"fsid":        taskResult["parentFSID"].(string),
+			"dtreeid":     taskResult["dTreeID"].(string),
+			"description": "Created from Kubernetes Provisioner",
+		}
+		if nfsV4ACL, exist := params["nfsv4acl"]; exist {
+			shareParams["nfsv4acl"] = nfsV4ACL
+		}
+
+		share, err = p.cli.CreateNfsShare(ctx, shareParams)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Create nfs share %v error: %v", shareParams, err)
+			return nil, err
+		}
+	}
+
+	shareID, err := utils.GetStringField(share, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of nfs share error: %v", err)
+	}
+
+	return map[string]interface{}{
+		"shareID": shareID,
+	}, nil
+}
+
+// Delete deletes the share, the quota and the dtree, the data in the dtree is destroyed
+func (p *DTree) Delete(ctx context.Context, name string) error {
+	dTreeName := utils.GetFileSystemName(name)
+	dTree, err := p.cli.GetDTreeByName(ctx, p.parentName, dTreeName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get dtree %s error: %v", dTreeName, err)
+		return err
+	}
+	if dTree == nil {
+		log.AddContext(ctx).Infof("Dtree %s to delete does not exist", dTreeName)
+		return nil
+	}
+
+	dTreeID, err := utils.GetStringField(dTree, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of dtree %s error: %v", dTreeName, err)
+	}
+
+	sharePath := GetDTreeSharePath(p.parentName, dTreeName)
+	share, err := p.cli.GetNfsShareByPath(ctx, sharePath, "")
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get nfs share by path %s error: %v", sharePath, err)
+		return err
+	}
+	if share != nil {
+		shareID, err := utils.GetStringField(share, "ID")
+		if err != nil {
+			return utils.Errorf(ctx, "Get ID of nfs share %s error: %v", sharePath, err)
+		}
+		err = p.cli.DeleteNfsShare(ctx, shareID, "")
+		if err != nil {
+			log.AddContext(ctx).Errorf("Delete share %s error: %v", shareID, err)
+			return err
+		}
+	}
+
+	quota, err := p.cli.GetQuota(ctx, client.ParentTypeDTree, dTreeID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get quota of dtree %s error: %v", dTreeName, err)
+		return err
+	}
+	if quota != nil {
+		quotaID, err := utils.GetStringField(quota, "ID")
+		if err != nil {
+			return utils.Errorf(ctx, "Get ID of quota of dtree %s error: %v", dTreeName, err)
+		}
+		err = p.cli.DeleteQuota(ctx, quotaID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Delete quota %s of dtree %s error: %v", quotaID, dTreeName, err)
+			return err
+		}
+	}
+
+	err = p.cli.DeleteDTree(ctx, dTreeID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Delete dtree %s error: %v", dTreeName, err)
+		return err
+	}
+	return nil
+}
+
+// Expand raises the hard limit of the quota of the dtree to the new size in sectors
+func (p *DTree) Expand(ctx context.Context, name string, newSize int64) error {
+	dTreeID, err := p.getDTreeID(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	limits := map[string]interface{}{
+		"SPACEHARDQUOTA": utils.CapacityFromSectors(newSize).Bytes(),
+	}
+	_, err = p.setQuota(ctx, client.ParentTypeDTree, dTreeID, limits)
+	return err
+}
+
+func (p *DTree) getDTreeID(ctx context.Context, name string) (string, error) {
+	dTreeName := utils.GetFileSystemName(name)
+	dTree, err := p.cli.GetDTreeByName(ctx, p.parentName, dTreeName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get dtree %s error: %v", dTreeName, err)
+		return "", err
+	} else if dTree == nil {
+		return "", utils.KindErrorf(ctx, utils.ErrNotFound, "Dtree %s does not exist", dTreeName)
+	}
+
+	dTreeID, err := utils.GetStringField(dTree, "ID")
+	if err != nil {
+		return "", utils.Errorf(ctx, "Get ID of dtree %s error: %v", dTreeName, err)
+	}
+	return dTreeID, nil
+}
+
+// GetParentCapacity returns the total and the free capacity of the parent filesystem, where the quotas of
+// the dtrees are allocated
+func (p *DTree) GetParentCapacity(ctx context.Context) (utils.Capacity, utils.Capacity, error) {
+	parent, err := p.cli.GetFileSystemByName(ctx, p.parentName)
+	if err != nil {
+		return 0, 0, err
+	} else if parent == nil {
+		return 0, 0, utils.KindErrorf(ctx, utils.ErrNotFound, "Parent filesystem %s does not exist", p.parentName)
+	}
+
+	total, err := utils.ParseSectors(parent, "CAPACITY")
+	if err != nil {
+		return 0, 0, utils.Errorf(ctx, "Get capacity of filesystem %s error: %v", p.parentName, err)
+	}
+	used, err := utils.ParseSectors(parent, "ALLOCCAPACITY")
+	if err != nil {
+		return 0, 0, utils.Errorf(ctx, "Get allocated capacity of filesystem %s error: %v", p.parentName, err)
+	}
+
+	free := total - used
+	if free < 0 {
+		free = 0
+	}
+	return total, free, nil
+}
@@ -0,0 +1,111 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// CreateHyperCDP creates a HyperCDP object of the LUN, which is a lightweight snapshot of Dorado V6,
+// and returns it in the format of CreateSnapshot
+func (p *SAN) CreateHyperCDP(ctx context.Context, lunName, name string) (map[string]interface{}, error) {
+	if p.product != utils.OceanStorDoradoV6 {
+		return nil, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"HyperCDP is only supported by Dorado V6, the storage is %s", p.product)
+	}
+
+	lun, err := p.cli.GetLunByName(ctx, lunName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun by name %s error: %v", lunName, err)
+		return nil, err
+	}
+	if lun == nil {
+		return nil, utils.KindErrorf(ctx, utils.ErrNotFound, "Lun %s to create HyperCDP does not exist", lunName)
+	}
+
+	lunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of lun %s error: %v", lunName, err)
+	}
+
+	hyperCDP, err := p.cli.GetHyperCDPByName(ctx, name)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get HyperCDP object by name %s error: %v", name, err)
+		return nil, err
+	}
+
+	if hyperCDP == nil {
+		hyperCDP, err = p.cli.CreateHyperCDP(ctx, name, lunID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Create HyperCDP object %s of lun %s error: %v", name, lunName, err)
+			return nil, err
+		}
+	} else if hyperCDP["PARENTID"] != lunID {
+		return nil, utils.VolumeConflictf(ctx, "HyperCDP object %s is already exist, but the parent LUN %s "+
+			"is incompatible", name, lunName)
+	}
+
+	lunCapacity, err := utils.ParseSectors(lun, "CAPACITY")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get capacity of lun %s error: %v", lunName, err)
+	}
+	return p.getSnapshotReturnInfo(hyperCDP, lunCapacity), nil
+}
+
+// getSnapshotOrHyperCDP returns the LUN snapshot of the name, or the HyperCDP object of the name if
+// there is no such snapshot. The capacity of the parent LUN is set as USERCAPACITY of a HyperCDP object.
+func (p *SAN) getSnapshotOrHyperCDP(ctx context.Context, name string) (map[string]interface{}, bool, error) {
+	snapshot, err := p.cli.GetLunSnapshotByName(ctx, name)
+	if err != nil || snapshot != nil || p.product != utils.OceanStorDoradoV6 {
+		return snapshot, false, err
+	}
+
+	hyperCDP, err := p.cli.GetHyperCDPByName(ctx, name)
+	if err != nil || hyperCDP == nil {
+		return nil, false, err
+	}
+
+	if _, exist := hyperCDP["USERCAPACITY"]; !exist {
+		parentID, _ := hyperCDP["PARENTID"].(string)
+		lun, err := p.cli.GetLunByID(ctx, parentID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Get parent lun %s of HyperCDP object %s error: %v", parentID, name, err)
+			return nil, false, err
+		}
+		hyperCDP["USERCAPACITY"] = lun["CAPACITY"]
+		hyperCDP["PARENTNAME"] = lun["NAME"]
+	}
+
+	return hyperCDP, true, nil
+}
+
+func (p *SAN) deleteHyperCDP(ctx context.Context, name string, hyperCDP map[string]interface{}) error {
+	hyperCDPID, err := utils.GetStringField(hyperCDP, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of HyperCDP object %s error: %v", name, err)
+	}
+
+	err = p.cli.DeleteHyperCDP(ctx, hyperCDPID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Delete HyperCDP object %s error: %v", name, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// getHyperMetroGroupID returns the HyperMetro consistency group of the pair, empty if it is in none
+func getHyperMetroGroupID(pair map[string]interface{}) string {
+	if isInGroup, _ := pair["ISINCG"].(string); isInGroup != "true" {
+		return ""
+	}
+	groupID, _ := pair["CGID"].(string)
+	return groupID
+}
+
+// isHyperMetroRunning returns whether the HyperMetro pair or consistency group is to be stopped before its
+// members change
+func isHyperMetroRunning(object map[string]interface{}) bool {
+	status, _ := object["RUNNINGSTATUS"].(string)
+	return status == hyperMetroPairRunningStatusNormal ||
+		status == hyperMetroPairRunningStatusToSync ||
+		status == hyperMetroPairRunningStatusSyncing
+}
+
+// joinHyperMetroGroup puts the synchronized HyperMetro pair in the HyperMetro consistency group of the
+// hyperMetroGroup parameter, which is created in the domain of the pair for the first pair. The group is
+// stopped while the pair joins it and synchronized after.
+func (p *SAN) joinHyperMetroGroup(ctx context.Context, params map[string]interface{},
+	domainID, pairID string) error {
+	groupName, _ := params["hypermetrogroup"].(string)
+	if groupName == "" {
+		return nil
+	}
+
+	pair, err := p.cli.GetHyperMetroPair(ctx, pairID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get hypermetro pair %s error: %v", pairID, err)
+		return err
+	}
+	if pair == nil {
+		return utils.Errorf(ctx, "Hypermetro pair %s does not exist", pairID)
+	}
+	if getHyperMetroGroupID(pair) != "" {
+		return nil
+	}
+
+	group, err := p.cli.GetHyperMetroGroupByName(ctx, groupName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get hypermetro consistency group %s error: %v", groupName, err)
+		return err
+	}
+
+	if group == nil {
+		data := map[string]interface{}{
+			"NAME":     groupName,
+			"DOMAINID": domainID,
+		}
+		setHyperMetroPairOptions(params, data)
+		group, err = p.cli.CreateHyperMetroGroup(ctx, data)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Create hypermetro consistency group %s error: %v", groupName, err)
+			return err
+		}
+	} else if group["DOMAINID"] != nil && group["DOMAINID"] != domainID {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"Hypermetro consistency group %s is not in the hypermetro domain %s", groupName, domainID)
+	}
+
+	groupID, err := utils.GetStringField(group, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of hypermetro consistency group %s error: %v", groupName, err)
+	}
+
+	if isHyperMetroRunning(group) {
+		err = p.cli.StopHyperMetroGroup(ctx, groupID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Stop hypermetro consistency group %s error: %v", groupName, err)
+			return err
+		}
+	}
+	err = p.cli.StopHyperMetroPair(ctx, pairID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Stop hypermetro pair %s error: %v", pairID, err)
+		return err
+	}
+
+	err = p.cli.AddPairToHyperMetroGroup(ctx, pairID, groupID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Add hypermetro pair %s to group %s error: %v", pairID, groupName, err)
+		return err
+	}
+	return p.cli.SyncHyperMetroGroup(ctx, groupID)
+}
+
+// leaveHyperMetroGroup removes the HyperMetro pair to delete from its HyperMetro consistency group, which
+// is synchronized again for the other pairs, or deleted if it has no pairs left
+func (p *SAN) leaveHyperMetroGroup(ctx context.Context, pair map[string]interface{}) error {
+	groupID := getHyperMetroGroupID(pair)
+	if groupID == "" {
+		return nil
+	}
+
+	pairID, err := utils.GetStringField(pair, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of hypermetro pair error: %v", err)
+	}
+
+	err = p.cli.StopHyperMetroGroup(ctx, groupID)
+	if err != nil {
+		log.AddContext(ctx).Warningf("Stop hypermetro consistency group %s error: %v", groupID, err)
+	}
+	err = p.cli.RemovePairFromHyperMetroGroup(ctx, pairID, groupID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Remove hypermetro pair %s from group %s error: %v", pairID, groupID, err)
+		return err
+	}
+
+	pairs, err := p.cli.GetHyperMetroPairsByGroup(ctx, groupID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get hypermetro pairs of group %s error: %v", groupID, err)
+		return err
+	}
+	if len(pairs) != 0 {
+		return p.cli.SyncHyperMetroGroup(ctx, groupID)
+	}
+
+	log.AddContext(ctx).Infof("Delete the empty hypermetro consistency group %s", groupID)
+	return p.cli.DeleteHyperMetroGroup(ctx, groupID)
+}
@@ -0,0 +1,91 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"fmt"
+)
+
+const (
+	// HyperMetroSyncPolicyAuto synchronizes a new HyperMetro pair only if the volume is cloned, as a new
+	// empty volume has nothing to synchronize
+	HyperMetroSyncPolicyAuto = "auto"
+	// HyperMetroSyncPolicyFull always synchronizes a new HyperMetro pair fully
+	HyperMetroSyncPolicyFull = "full"
+)
+
+// HyperMetroOptionKeys are the StorageClass and backend parameters of the HyperMetro pairs, the
+// StorageClass overrides the backend
+var HyperMetroOptionKeys = []string{"hyperMetroSpeed", "hyperMetroRecoveryPolicy", "hyperMetroSyncPolicy"}
+
+// pairSpeeds are the values of the speed parameters of the HyperMetro and replication pairs, in the
+// values of SPEED of the pairs
+var pairSpeeds = map[string]int{
+	"low":     1,
+	"medium":  2,
+	"high":    3,
+	"highest": 4,
+}
+
+// hyperMetroRecoveryPolicies are the values of the hyperMetroRecoveryPolicy parameter, in the values of
+// RECOVERYPOLICY of the pairs
+var hyperMetroRecoveryPolicies = map[string]int{
+	"automatic": 1,
+	"manual":    2,
+}
+
+// CheckHyperMetroOption checks the value of a HyperMetro parameter
+func CheckHyperMetroOption(key, value string) error {
+	var valid bool
+	switch key {
+	case "hyperMetroSpeed":
+		_, valid = pairSpeeds[value]
+	case "hyperMetroRecoveryPolicy":
+		_, valid = hyperMetroRecoveryPolicies[value]
+	case "hyperMetroSyncPolicy":
+		valid = value == HyperMetroSyncPolicyAuto || value == HyperMetroSyncPolicyFull
+	default:
+		return fmt.Errorf("unknown HyperMetro parameter %s", key)
+	}
+
+	if !valid {
+		return fmt.Errorf("%s [%s] is invalid, the speed is low, medium, high or highest, the recovery "+
+			"policy is automatic or manual, and the sync policy is auto or full", key, value)
+	}
+	return nil
+}
+
+// setHyperMetroPairOptions sets the speed and recovery policy of the HyperMetro pair to create, the
+// speed is the highest unless it is set
+func setHyperMetroPairOptions(params, data map[string]interface{}) {
+	speed, _ := params["hypermetrospeed"].(string)
+	if value, exist := pairSpeeds[speed]; exist {
+		data["SPEED"] = value
+	} else {
+		data["SPEED"] = pairSpeeds["highest"]
+	}
+
+	policy, _ := params["hypermetrorecoverypolicy"].(string)
+	if value, exist := hyperMetroRecoveryPolicies[policy]; exist {
+		data["RECOVERYPOLICY"] = value
+	}
+}
+
+// isHyperMetroFullSync returns whether the new HyperMetro pair is synchronized whatever the volume is
+func isHyperMetroFullSync(params map[string]interface{}) bool {
+	return params["hypermetrosyncpolicy"] == HyperMetroSyncPolicyFull
+}
@@ -0,0 +1,185 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"huawei-csi-driver/storage/oceanstor/client"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// getMigrationTargetName returns the name of the LUN the LUN of the ID is migrated to, which the array
+// deletes once the data and the identity of the source LUN are moved to it
+func getMigrationTargetName(lunID string) string {
+	return "csi_mig_" + lunID
+}
+
+// Migrate starts moving the LUN to the storage pool by SmartMigration, while the LUN stays attached.
+// The LUNs of HyperMetro and replication pairs are refused, as their remote LUNs stay where they are.
+func (p *SAN) Migrate(ctx context.Context, name, poolName string, speed int) error {
+	lunName := utils.GetLunName(name)
+	lun, err := p.cli.GetLunByName(ctx, lunName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun by name %s error: %v", lunName, err)
+		return err
+	} else if lun == nil {
+		return utils.KindErrorf(ctx, utils.ErrNotFound, "Lun %s to migrate does not exist", lunName)
+	}
+
+	if lun["PARENTNAME"] == poolName {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition, "Lun %s is already in pool %s",
+			lunName, poolName)
+	}
+
+	var rss map[string]string
+	rssStr, _ := lun["HASRSSOBJECT"].(string)
+	json.Unmarshal([]byte(rssStr), &rss)
+	if rss["HyperMetro"] == "TRUE" || rss["RemoteReplication"] == "TRUE" {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"Lun %s of a hypermetro or replication pair cannot be migrated", lunName)
+	}
+
+	lunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of lun %s error: %v", lunName, err)
+	}
+
+	migration, err := p.cli.GetLunMigration(ctx, lunID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get migration of lun %s error: %v", lunName, err)
+		return err
+	}
+	if migration != nil {
+		log.AddContext(ctx).Infof("Lun %s is already migrating", lunName)
+		return nil
+	}
+
+	targetID, err := p.createMigrationTarget(ctx, lun, lunID, poolName)
+	if err != nil {
+		return err
+	}
+
+	err = p.cli.CreateLunMigration(ctx, lunID, targetID, speed)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Migrate lun %s to pool %s error: %v", lunName, poolName, err)
+		p.cli.DeleteLun(ctx, targetID)
+		return err
+	}
+
+	log.AddContext(ctx).Infof("Lun %s starts migrating to pool %s", lunName, poolName)
+	return nil
+}
+
+// createMigrationTarget creates the LUN in the storage pool the LUN is migrated to, which has the same
+// capacity and allocation type as the LUN
+func (p *SAN) createMigrationTarget(ctx context.Context, lun map[string]interface{},
+	lunID, poolName string) (string, error) {
+	pool, err := p.cli.GetPoolByName(ctx, poolName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get storage pool %s error: %v", poolName, err)
+		return "", err
+	} else if pool == nil {
+		return "", utils.KindErrorf(ctx, utils.ErrFailedPrecondition, "Storage pool %s does not exist", poolName)
+	}
+
+	targetName := getMigrationTargetName(lunID)
+	target, err := p.cli.GetLunByName(ctx, targetName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun by name %s error: %v", targetName, err)
+		return "", err
+	}
+
+	if target == nil {
+		poolID, err := utils.GetStringField(pool, "ID")
+		if err != nil {
+			return "", utils.Errorf(ctx, "Get ID of storage pool %s error: %v", poolName, err)
+		}
+		capacity, err := utils.ParseSectors(lun, "CAPACITY")
+		if err != nil {
+			return "", utils.Errorf(ctx, "Get capacity of lun %s error: %v", lunID, err)
+		}
+		allocTypeStr, _ := lun["ALLOCTYPE"].(string)
+		allocType, _ := strconv.Atoi(allocTypeStr)
+
+		target, err = p.cli.CreateLun(ctx, map[string]interface{}{
+			"name":        targetName,
+			"parentid":    poolID,
+			"capacity":    capacity.Sectors(),
+			"description": client.CSIDescription,
+			"alloctype":   allocType,
+		})
+		if err != nil {
+			log.AddContext(ctx).Errorf("Create lun %s to migrate to error: %v", targetName, err)
+			return "", err
+		}
+	}
+
+	return utils.GetStringField(target, "ID")
+}
+
+// IsMigrating returns whether the LUN is still migrating to the storage pool. The migration is deleted
+// once it is completed, a faulty one is deleted with its target and refused.
+func (p *SAN) IsMigrating(ctx context.Context, name, poolName string) (bool, error) {
+	lunName := utils.GetLunName(name)
+	lun, err := p.cli.GetLunByName(ctx, lunName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun by name %s error: %v", lunName, err)
+		return false, err
+	} else if lun == nil {
+		return false, utils.KindErrorf(ctx, utils.ErrNotFound, "Lun %s to migrate does not exist", lunName)
+	}
+
+	lunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return false, utils.Errorf(ctx, "Get ID of lun %s error: %v", lunName, err)
+	}
+
+	migration, err := p.cli.GetLunMigration(ctx, lunID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get migration of lun %s error: %v", lunName, err)
+		return false, err
+	}
+	if migration == nil {
+		if lun["PARENTNAME"] != poolName {
+			return false, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+				"Lun %s is neither migrating nor in pool %s", lunName, poolName)
+		}
+		return false, nil
+	}
+
+	switch migration["RUNNINGSTATUS"] {
+	case lunMigrationRunningStatusComplete:
+		err = p.cli.DeleteLunMigration(ctx, lunID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Delete migration of lun %s error: %v", lunName, err)
+			return false, err
+		}
+		log.AddContext(ctx).Infof("Lun %s is migrated to pool %s", lunName, poolName)
+		return false, nil
+	case lunMigrationRunningStatusFault:
+		p.cli.DeleteLunMigration(ctx, lunID)
+		p.deleteLun(ctx, getMigrationTargetName(lunID), p.cli)
+		return false, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"Migration of lun %s to pool %s is faulty", lunName, poolName)
+	default:
+		return true, nil
+	}
+}
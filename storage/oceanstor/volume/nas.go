@@ -44,6 +44,11 @@ const (
 	noAllSquash  = 1
 	rootSquash   = 0
 	noRootSquash = 1
+
+	// ShareProtocolNFS exports the filesystem as an NFS share
+	ShareProtocolNFS = "nfs"
+	// ShareProtocolCIFS exports the filesystem as a CIFS/SMB share
+	ShareProtocolCIFS = "cifs"
 )
 
 type NASHyperMetro struct {
@@ -55,9 +60,12 @@ type NASHyperMetro struct {
 type NAS struct {
 	Base
 	NASHyperMetro
+
+	shareProtocol string
 }
 
-func NewNAS(cli, metroRemoteCli, replicaRemoteCli client.BaseClientInterface, product string, nasHyperMetro NASHyperMetro) *NAS {
+func NewNAS(cli, metroRemoteCli, replicaRemoteCli client.BaseClientInterface, product string,
+	nasHyperMetro NASHyperMetro, shareProtocol string) *NAS {
 	return &NAS{
 		Base: Base{
 			cli:              cli,
@@ -66,11 +74,17 @@ func NewNAS(cli, metroRemoteCli, replicaRemoteCli client.BaseClientInterface, pr
 			product:          product,
 		},
 		NASHyperMetro: nasHyperMetro,
+		shareProtocol: shareProtocol,
 	}
 }
 
 func (p *NAS) preCreate(ctx context.Context, params map[string]interface{}) error {
-	if _, exist := params["authclient"].(string); !exist {
+	if p.shareProtocol == ShareProtocolCIFS {
+		err := p.preCreateCifs(ctx, params)
+		if err != nil {
+			return err
+		}
+	} else if _, exist := params["authclient"].(string); !exist {
 		msg := "authclient must be provided for filesystem"
 		log.AddContext(ctx).Errorln(msg)
 		return errors.New(msg)
@@ -97,46 +111,97 @@ func (p *NAS) preCreate(ctx context.Context, params map[string]interface{}) erro
 		return err
 	}
 
-	// all_squash  all_squash: 0  no_all_squash: 1
-	val, exist := params["allsquash"].(string)
+	err = parseSquash(ctx, params)
+	if err != nil {
+		return err
+	}
 
-	if !exist || val == "" {
-		params["allsquash"] = noAllSquash
+	err = parseQuota(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	err = p.parseNFSSecurity(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	err = p.parseSectorSize(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	// the snapshot directory is invisible unless it is requested, so that the pods don't see the snapshots
+	if val, ok := params["snapshotdirectoryvisibility"].(string); !ok || strings.EqualFold(val, invisibleString) {
+		params["isshowsnapdir"] = false
+	} else if strings.EqualFold(val, visibleString) {
+		params["isshowsnapdir"] = true
 	} else {
-		if strings.EqualFold(val, noAllSquashString) {
-			params["allsquash"] = noAllSquash
-		} else if strings.EqualFold(val, allSquashString) {
-			params["allsquash"] = allSquash
-		} else {
-			return utils.Errorf(ctx, "parameter allSquash [%v] in sc must be %s or %s.",
-				val, allSquashString, noAllSquashString)
+		return utils.Errorf(ctx, "parameter snapshotDirectoryVisibility [%v] in sc must be %s or %s.",
+			params["snapshotdirectoryvisibility"], visibleString, invisibleString)
+	}
+
+	return nil
+}
+
+// AnonymousIDMax is the highest anonUid and anonGid which the squashed users of NFS shares are mapped to
+const AnonymousIDMax = 4294967294
+
+// CheckSquash checks the allSquash, rootSquash, anonUid and anonGid parameters of the NFS share access.
+// The anonymous IDs are only for the shares which squash root or all users.
+func CheckSquash(allSquashValue, rootSquashValue, anonUID, anonGID string) error {
+	if allSquashValue != "" && !strings.EqualFold(allSquashValue, allSquashString) &&
+		!strings.EqualFold(allSquashValue, noAllSquashString) {
+		return fmt.Errorf("parameter allSquash [%v] in sc must be %s or %s.",
+			allSquashValue, allSquashString, noAllSquashString)
+	}
+	if rootSquashValue != "" && !strings.EqualFold(rootSquashValue, rootSquashString) &&
+		!strings.EqualFold(rootSquashValue, noRootSquashString) {
+		return fmt.Errorf("parameter rootSquash [%v] in sc must be %s or %s.",
+			rootSquashValue, rootSquashString, noRootSquashString)
+	}
+
+	for key, value := range map[string]string{"anonUid": anonUID, "anonGid": anonGID} {
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id < 0 || id > AnonymousIDMax {
+			return fmt.Errorf("parameter %s [%s] in sc must be an integer from 0 to %d", key, value, AnonymousIDMax)
+		}
+		if !strings.EqualFold(allSquashValue, allSquashString) && !strings.EqualFold(rootSquashValue,
+			rootSquashString) {
+			return fmt.Errorf("parameter %s in sc is only for the shares of %s or %s", key,
+				allSquashString, rootSquashString)
 		}
 	}
+	return nil
+}
 
-	// root_squash
-	val, exist = params["rootsquash"].(string)
-	if !exist || val == "" {
-		params["rootsquash"] = noRootSquash
+// parseSquash converts the allsquash and rootsquash parameters of the share access to their values on storage,
+// the anonuid and anongid parameters are kept if they are set
+func parseSquash(ctx context.Context, params map[string]interface{}) error {
+	allSquashValue, _ := params["allsquash"].(string)
+	rootSquashValue, _ := params["rootsquash"].(string)
+	anonUID, _ := params["anonuid"].(string)
+	anonGID, _ := params["anongid"].(string)
+	err := CheckSquash(allSquashValue, rootSquashValue, anonUID, anonGID)
+	if err != nil {
+		return utils.Errorln(ctx, err.Error())
+	}
+
+	// all_squash  all_squash: 0  no_all_squash: 1
+	if strings.EqualFold(allSquashValue, allSquashString) {
+		params["allsquash"] = allSquash
 	} else {
-		if strings.EqualFold(val, noRootSquashString) {
-			params["rootsquash"] = noRootSquash
-		} else if strings.EqualFold(val, rootSquashString) {
-			params["rootsquash"] = rootSquash
-		} else {
-			return utils.Errorf(ctx, "parameter rootSquash [%v] in sc must be %s or %s.",
-				val, rootSquashString, noRootSquashString)
-		}
+		params["allsquash"] = noAllSquash
 	}
 
-	if val, ok := params["snapshotdirectoryvisibility"].(string); ok {
-		if strings.EqualFold(val, visibleString) {
-			params["isshowsnapdir"] = true
-		} else if strings.EqualFold(val, invisibleString) {
-			params["isshowsnapdir"] = false
-		} else {
-			return utils.Errorf(ctx, "parameter snapshotDirectoryVisibility [%v] in sc must be %s or %s.",
-				params["snapshotdirectoryvisibility"], visibleString, invisibleString)
-		}
+	// root_squash
+	if strings.EqualFold(rootSquashValue, rootSquashString) {
+		params["rootsquash"] = rootSquash
+	} else {
+		params["rootsquash"] = noRootSquash
 	}
 
 	return nil
@@ -164,6 +229,9 @@ func (p *NAS) Create(ctx context.Context, params map[string]interface{}) (utils.
 	}
 
 	taskflow.AddTask("Create-Local-FS", p.createLocalFS, p.revertLocalFS)
+	if _, exist := params["quota"]; exist {
+		taskflow.AddTask("Create-Local-Quota", p.createLocalQuota, p.revertLocalQuota)
+	}
 
 	if replicationOK && replication {
 		taskflow.AddTask("Create-Remote-FS", p.createRemoteFS, p.revertRemoteFS)
@@ -176,8 +244,13 @@ func (p *NAS) Create(ctx context.Context, params map[string]interface{}) (utils.
 		taskflow.AddTask("Create-HyperMetro", p.createHyperMetro, p.revertHyperMetro)
 	}
 
-	taskflow.AddTask("Create-Share", p.createShare, p.revertShare)
-	taskflow.AddTask("Allow-Share-Access", p.allowShareAccess, p.revertShareAccess)
+	if p.shareProtocol == ShareProtocolCIFS {
+		taskflow.AddTask("Create-CIFS-Share", p.createCifsShare, p.revertCifsShare)
+		taskflow.AddTask("Allow-CIFS-Share-Access", p.allowCifsShareAccess, nil)
+	} else {
+		taskflow.AddTask("Create-Share", p.createShare, p.revertShare)
+		taskflow.AddTask("Allow-Share-Access", p.allowShareAccess, p.revertShareAccess)
+	}
 	taskflow.AddTask("Create-QoS", p.createLocalQoS, p.revertLocalQoS)
 
 	params["localVStoreID"] = p.LocVStoreID
@@ -211,20 +284,28 @@ func (p *NAS) createLocalFS(ctx context.Context, params, taskResult map[string]i
 
 		if _, exist := params["clonefrom"]; exist {
 			fs, err = p.clone(ctx, params)
+			if err == nil {
+				err = p.setSnapshotDirVisibility(ctx, fs, params)
+			}
 		} else if _, exist := params["fromSnapshot"]; exist {
 			fs, err = p.createFromSnapshot(ctx, params)
+			if err == nil {
+				err = p.setSnapshotDirVisibility(ctx, fs, params)
+			}
 		} else {
 			fs, err = p.cli.CreateFileSystem(ctx, params)
 		}
 	} else {
-		if fs["ISCLONEFS"].(string) == "false" {
-			return map[string]interface{}{
-				"localFSID": fs["ID"].(string),
-			}, nil
+		err = p.checkExistVolume(ctx, fsName, fs, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if fs["ISCLONEFS"] == "false" {
+			return p.getLocalFSResult(ctx, fsName, fs)
 		}
 
-		fsID := fs["ID"].(string)
-		err = p.waitFSSplitDone(ctx, fsID)
+		err = p.resumeFSSplit(ctx, fs, params)
 	}
 
 	if err != nil {
@@ -232,8 +313,38 @@ func (p *NAS) createLocalFS(ctx context.Context, params, taskResult map[string]i
 		return nil, err
 	}
 
+	return p.getLocalFSResult(ctx, fsName, fs)
+}
+
+// setSnapshotDirVisibility shows or hides the snapshot directory of the clone filesystem, which takes the
+// visibility of its source
+func (p *NAS) setSnapshotDirVisibility(ctx context.Context, fs, params map[string]interface{}) error {
+	visible, exist := params["isshowsnapdir"].(bool)
+	if !exist || fs["ISSHOWSNAPDIR"] == strconv.FormatBool(visible) {
+		return nil
+	}
+
+	fsID, err := utils.GetStringField(fs, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of filesystem %s error: %v", params["name"], err)
+	}
+	err = p.cli.UpdateFileSystem(ctx, fsID, map[string]interface{}{"ISSHOWSNAPDIR": visible})
+	if err != nil {
+		log.AddContext(ctx).Errorf("Set snapshot directory visibility of filesystem %s error: %v", fsID, err)
+		return err
+	}
+	return nil
+}
+
+func (p *NAS) getLocalFSResult(ctx context.Context, fsName string, fs map[string]interface{}) (
+	map[string]interface{}, error) {
+	fsID, err := utils.GetStringField(fs, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of filesystem %s error: %v", fsName, err)
+	}
+
 	return map[string]interface{}{
-		"localFSID": fs["ID"].(string),
+		"localFSID": fsID,
 	}, nil
 }
 
@@ -245,26 +356,34 @@ func (p *NAS) clone(ctx context.Context, params map[string]interface{}) (map[str
 		return nil, err
 	}
 	if cloneFromFS == nil {
-		msg := fmt.Errorf("Filesystem %s does not exist", clonefrom)
-		log.AddContext(ctx).Errorln(msg)
-		return nil, msg
+		return nil, utils.KindErrorf(ctx, utils.ErrNotFound, "Filesystem %s does not exist", clonefrom)
+	}
+
+	srcFSCapacity, err := utils.ParseSectors(cloneFromFS, "CAPACITY")
+	if err != nil {
+		return nil, err
 	}
 
-	srcFSCapacity, err := strconv.ParseInt(cloneFromFS["CAPACITY"].(string), 10, 64)
+	err = checkCloneSectorSize(ctx, cloneFromFS, params)
 	if err != nil {
 		return nil, err
 	}
 
-	cloneFSCapacity := params["capacity"].(int64)
+	cloneFSCapacity := utils.CapacityFromSectors(params["capacity"].(int64))
 	if cloneFSCapacity < srcFSCapacity {
 		msg := fmt.Sprintf("Clone filesystem capacity must be >= src %s", clonefrom)
 		log.AddContext(ctx).Errorln(msg)
 		return nil, errors.New(msg)
 	}
 
+	cloneFromFSID, err := utils.GetStringField(cloneFromFS, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of clone src filesystem %s error: %v", clonefrom, err)
+	}
+
 	cloneFilesystemReq := &CloneFilesystemRequest{
 		FsName:               params["name"].(string),
-		ParentID:             cloneFromFS["ID"].(string),
+		ParentID:             cloneFromFSID,
 		ParentSnapshotID:     "",
 		AllocType:            params["alloctype"].(int),
 		CloneSpeed:           params["clonespeed"].(int),
@@ -292,35 +411,47 @@ func (p *NAS) createFromSnapshot(ctx context.Context, params map[string]interfac
 		return nil, err
 	}
 	if srcSnapshot == nil {
-		msg := fmt.Errorf("src snapshot %s does not exist", srcSnapshotName)
-		log.AddContext(ctx).Errorln(msg)
-		return nil, msg
+		return nil, utils.KindErrorf(ctx, utils.ErrNotFound, "src snapshot %s does not exist", srcSnapshotName)
 	}
 
-	parentName := srcSnapshot["PARENTNAME"].(string)
+	parentName, err := utils.GetStringField(srcSnapshot, "PARENTNAME")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get parent of src snapshot %s error: %v", srcSnapshotName, err)
+	}
+	parentID, err := utils.GetStringField(srcSnapshot, "PARENTID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get parent ID of src snapshot %s error: %v", srcSnapshotName, err)
+	}
+	srcSnapshotID, err := utils.GetStringField(srcSnapshot, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of src snapshot %s error: %v", srcSnapshotName, err)
+	}
 	parentFS, err := p.cli.GetFileSystemByName(ctx, parentName)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Get clone src filesystem %s error: %v", parentName, err)
 		return nil, err
 	}
 	if parentFS == nil {
-		msg := fmt.Errorf("Filesystem %s does not exist", parentName)
-		log.AddContext(ctx).Errorln(msg)
-		return nil, msg
+		return nil, utils.KindErrorf(ctx, utils.ErrNotFound, "Filesystem %s does not exist", parentName)
 	}
 
-	srcSnapshotCapacity, err := strconv.ParseInt(parentFS["CAPACITY"].(string), 10, 64)
+	srcSnapshotCapacity, err := utils.ParseSectors(parentFS, "CAPACITY")
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkCloneSectorSize(ctx, parentFS, params)
 	if err != nil {
 		return nil, err
 	}
 
 	cloneFilesystemReq := &CloneFilesystemRequest{
 		FsName:               params["name"].(string),
-		ParentID:             srcSnapshot["PARENTID"].(string),
-		ParentSnapshotID:     srcSnapshot["ID"].(string),
+		ParentID:             parentID,
+		ParentSnapshotID:     srcSnapshotID,
 		AllocType:            params["alloctype"].(int),
 		CloneSpeed:           params["clonespeed"].(int),
-		CloneFsCapacity:      params["capacity"].(int64),
+		CloneFsCapacity:      utils.CapacityFromSectors(params["capacity"].(int64)),
 		SrcCapacity:          srcSnapshotCapacity,
 		DeleteParentSnapshot: false,
 		VStoreId:             systemVStore,
@@ -343,12 +474,15 @@ func (p *NAS) cloneFilesystem(ctx context.Context, req *CloneFilesystemRequest)
 		return nil, err
 	}
 
-	cloneFSID := cloneFS["ID"].(string)
+	cloneFSID, err := utils.GetStringField(cloneFS, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of clone filesystem %s error: %v", req.FsName, err)
+	}
 	if req.CloneFsCapacity > req.SrcCapacity {
-		err := p.cli.ExtendFileSystem(ctx, cloneFSID, req.CloneFsCapacity)
+		err := p.cli.ExtendFileSystem(ctx, cloneFSID, req.CloneFsCapacity.Sectors())
 		if err != nil {
 			log.AddContext(ctx).Errorf("Extend filesystem %s to capacity %d error: %v",
-				cloneFSID, req.CloneFsCapacity, err)
+				cloneFSID, req.CloneFsCapacity.Sectors(), err)
 			_ = p.cli.DeleteFileSystem(ctx, map[string]interface{}{"ID": cloneFSID})
 			return nil, err
 		}
@@ -381,22 +515,89 @@ func (p *NAS) splitClone(ctx context.Context, cloneFSID string, req *CloneFilesy
 	return p.waitFSSplitDone(ctx, cloneFSID)
 }
 
+// resumeFSSplit continues the split of an existing clone filesystem, which may have been
+// interrupted by a controller restart. The progress is derived from the filesystem itself,
+// so a retried CreateVolume picks the split up where it stopped.
+func (p *NAS) resumeFSSplit(ctx context.Context, fs, params map[string]interface{}) error {
+	fsID, err := utils.GetStringField(fs, "ID")
+	if err != nil {
+		return err
+	}
+
+	err = p.extendCloneFS(ctx, fs, fsID, params)
+	if err != nil {
+		return err
+	}
+
+	splitStatus, _ := fs["SPLITSTATUS"].(string)
+	if splitStatus == filesystemSplitStatusNotStart {
+		vStoreID, ok := fs["vstoreId"].(string)
+		if !ok {
+			vStoreID = systemVStore
+		}
+		cloneSpeed, _ := params["clonespeed"].(int)
+		_, deleteParentSnapshot := params["clonefrom"]
+
+		log.AddContext(ctx).Infof("Split of clone filesystem %s has not started, start it", fsID)
+		err = p.cli.SplitCloneFS(ctx, fsID, vStoreID, cloneSpeed, deleteParentSnapshot)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Split filesystem %s error: %v", fsID, err)
+			return err
+		}
+	}
+
+	return p.waitFSSplitDone(ctx, fsID)
+}
+
+// extendCloneFS extends a clone filesystem which still has the capacity of its source, in
+// case the restart happened before the extension.
+func (p *NAS) extendCloneFS(ctx context.Context, fs map[string]interface{},
+	fsID string, params map[string]interface{}) error {
+	capacity, ok := params["capacity"].(int64)
+	if !ok {
+		return nil
+	}
+
+	existCapacity, err := utils.ParseSectors(fs, "CAPACITY")
+	if err != nil {
+		return err
+	}
+	if existCapacity >= utils.CapacityFromSectors(capacity) {
+		return nil
+	}
+
+	err = p.cli.ExtendFileSystem(ctx, fsID, capacity)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Extend filesystem %s to capacity %d error: %v", fsID, capacity, err)
+		return err
+	}
+
+	return nil
+}
+
 func (p *NAS) waitFSSplitDone(ctx context.Context, fsID string) error {
 	return utils.WaitUntil(func() (bool, error) {
 		fs, err := p.cli.GetFileSystemByID(ctx, fsID)
 		if err != nil {
 			return false, err
 		}
+		if fs == nil {
+			return false, utils.KindErrorf(ctx, utils.ErrNotFound, "filesystem %s does not exist", fsID)
+		}
 
 		if fs["ISCLONEFS"] == "false" {
 			return true, nil
 		}
 
-		if fs["HEALTHSTATUS"].(string) != filesystemHealthStatusNormal {
-			return false, fmt.Errorf("filesystem %s has the bad healthStatus code %s", fs["NAME"], fs["HEALTHSTATUS"].(string))
+		healthStatus, _ := fs["HEALTHSTATUS"].(string)
+		if healthStatus != filesystemHealthStatusNormal {
+			return false, fmt.Errorf("filesystem %s has the bad healthStatus code %s", fs["NAME"], healthStatus)
 		}
 
-		splitStatus := fs["SPLITSTATUS"].(string)
+		splitStatus, err := utils.GetStringField(fs, "SPLITSTATUS")
+		if err != nil {
+			return false, err
+		}
 		if splitStatus == filesystemSplitStatusQueuing ||
 			splitStatus == filesystemSplitStatusSplitting ||
 			splitStatus == filesystemSplitStatusNotStart {
@@ -432,10 +633,9 @@ func (p *NAS) createLocalQoS(ctx context.Context,
 	}
 
 	activeClient := p.getActiveClient(taskResult)
-	smartX := smartx.NewSmartX(activeClient)
 	vStoreID := p.getVStoreID(taskResult)
 	fsID := p.getActiveFsID(taskResult)
-	qosID, err := smartX.CreateQos(ctx, fsID, "fs", vStoreID, qos)
+	qosID, err := p.createQos(ctx, activeClient, fsID, "fs", vStoreID, qos, params)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Create qos %v for fs %s error: %v", qos, fsID, err)
 		return nil, err
@@ -474,8 +674,7 @@ func (p *NAS) createRemoteQoS(ctx context.Context,
 	fsID := taskResult["remoteFSID"].(string)
 	remoteCli := taskResult["remoteCli"].(client.BaseClientInterface)
 
-	smartX := smartx.NewSmartX(remoteCli)
-	qosID, err := smartX.CreateQos(ctx, fsID, "fs", "", qos)
+	qosID, err := p.createQos(ctx, remoteCli, fsID, "fs", "", qos, params)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Create qos %v for fs %s error: %v", qos, fsID, err)
 		return nil, err
@@ -521,6 +720,9 @@ func (p *NAS) createShare(ctx context.Context,
 			"description": "Created from Kubernetes Provisioner",
 			"vStoreID":    vStoreID,
 		}
+		if nfsV4ACL, exist := params["nfsv4acl"]; exist {
+			shareParams["nfsv4acl"] = nfsV4ACL
+		}
 
 		share, err = activeClient.CreateNfsShare(ctx, shareParams)
 		if err != nil {
@@ -529,8 +731,13 @@ func (p *NAS) createShare(ctx context.Context,
 		}
 	}
 
+	shareID, err := utils.GetStringField(share, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of nfs share error: %v", err)
+	}
+
 	return map[string]interface{}{
-		"shareID": share["ID"].(string),
+		"shareID": shareID,
 	}, nil
 }
 
@@ -563,8 +770,14 @@ func (p *NAS) getCurrentShareAccess(ctx context.Context, shareID, vStoreID strin
 		}
 
 		for _, c := range clients {
-			client := c.(map[string]interface{})
-			name := client["NAME"].(string)
+			client, err := utils.ToObject(c)
+			if err != nil {
+				return nil, err
+			}
+			name, err := utils.GetStringField(client, "NAME")
+			if err != nil {
+				return nil, err
+			}
 			accesses[name] = c
 		}
 	}
@@ -601,6 +814,9 @@ func (p *NAS) allowShareAccess(ctx context.Context,
 			RootSquash: params["rootsquash"].(int),
 			VStoreID:   vStoreID,
 		}
+		req.AnonUID, _ = params["anonuid"].(string)
+		req.AnonGID, _ = params["anongid"].(string)
+		req.SecurityFlavors, _ = params["nfssecurityflavors"].(string)
 		err := activeClient.AllowNfsShareAccess(ctx, req)
 		if err != nil {
 			log.AddContext(ctx).Errorf("Allow nfs share access %v failed. error: %v", req, err)
@@ -610,10 +826,14 @@ func (p *NAS) allowShareAccess(ctx context.Context,
 
 	// Remove all other extra access
 	for _, i := range accesses {
-		access := i.(map[string]interface{})
-		accessID := access["ID"].(string)
+		access, _ := i.(map[string]interface{})
+		accessID, err := utils.GetStringField(access, "ID")
+		if err != nil {
+			log.AddContext(ctx).Warningf("Get ID of extra nfs share access error: %v", err)
+			continue
+		}
 
-		err := activeClient.DeleteNfsShareAccess(ctx, accessID, vStoreID)
+		err = activeClient.DeleteNfsShareAccess(ctx, accessID, vStoreID)
 		if err != nil {
 			log.AddContext(ctx).Warningf("Delete extra nfs share access %s error: %v", accessID, err)
 		}
@@ -643,9 +863,13 @@ func (p *NAS) revertShareAccess(ctx context.Context, taskResult map[string]inter
 		if _, exist := accesses[i]; !exist {
 			continue
 		}
-		access := accesses[i].(map[string]interface{})
-		accessID := access["ID"].(string)
-		err := p.cli.DeleteNfsShareAccess(ctx, accessID, vStoreID)
+		access, _ := accesses[i].(map[string]interface{})
+		accessID, err := utils.GetStringField(access, "ID")
+		if err != nil {
+			log.AddContext(ctx).Warningf("Get ID of nfs share access %s error: %v", i, err)
+			continue
+		}
+		err = p.cli.DeleteNfsShareAccess(ctx, accessID, vStoreID)
 		if err != nil {
 			log.AddContext(ctx).Warningf("Delete extra nfs share access %s error: %v", accessID, err)
 		}
@@ -665,7 +889,18 @@ func (p *NAS) Delete(ctx context.Context, name string) error {
 		return nil
 	}
 
-	fsID := fs["ID"].(string)
+	fsID, err := utils.GetStringField(fs, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of filesystem %s error: %v", fsName, err)
+	}
+	replicationIDStr, err := utils.GetStringField(fs, "REMOTEREPLICATIONIDS")
+	if err != nil {
+		return utils.Errorf(ctx, "Get replication pairs of filesystem %s error: %v", fsName, err)
+	}
+	hypermetroIDStr, err := utils.GetStringField(fs, "HYPERMETROPAIRIDS")
+	if err != nil {
+		return utils.Errorf(ctx, "Get hypermetro pairs of filesystem %s error: %v", fsName, err)
+	}
 	fsSnapshotNum, err := p.cli.GetFSSnapshotCountByParentId(ctx, fsID)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Failed to get the snapshot count of filesystem %s error: %v", fsID, err)
@@ -673,11 +908,11 @@ func (p *NAS) Delete(ctx context.Context, name string) error {
 	}
 
 	var replicationIDs []string
-	replicationIDBytes := []byte(fs["REMOTEREPLICATIONIDS"].(string))
+	replicationIDBytes := []byte(replicationIDStr)
 	json.Unmarshal(replicationIDBytes, &replicationIDs)
 
 	var hypermetroIDs []string
-	hypermetroIDBytes := []byte(fs["HYPERMETROPAIRIDS"].(string))
+	hypermetroIDBytes := []byte(hypermetroIDStr)
 	json.Unmarshal(hypermetroIDBytes, &hypermetroIDs)
 
 	taskflow := taskflow.NewTaskFlow(ctx, "Delete-FileSystem-Volume")
@@ -690,10 +925,8 @@ func (p *NAS) Delete(ctx context.Context, name string) error {
 		}
 
 		if fsSnapshotNum > 1 {
-			msg := fmt.Sprintf("There are %d snapshots exist in filesystem %s. "+
-				"Please delete the snapshots firstly", fsSnapshotNum-1, fsName)
-			log.AddContext(ctx).Errorln(msg)
-			return errors.New(msg)
+			return utils.KindErrorf(ctx, utils.ErrFailedPrecondition, "There are %d snapshots exist in "+
+				"filesystem %s. Please delete the snapshots firstly", fsSnapshotNum-1, fsName)
 		}
 
 		taskflow.AddTask("Delete-Replication-Pair", p.deleteReplicationPair, nil)
@@ -745,6 +978,95 @@ func (p *NAS) Delete(ctx context.Context, name string) error {
 	return err
 }
 
+// UpdateQoS sets the QoS parameters of the filesystem
+func (p *NAS) UpdateQoS(ctx context.Context, name string, qos map[string]int) error {
+	fsName := utils.GetFileSystemName(name)
+	fs, err := p.cli.GetFileSystemByName(ctx, fsName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get filesystem %s error: %v", fsName, err)
+		return err
+	}
+
+	if fs == nil {
+		return utils.KindErrorf(ctx, utils.ErrNotFound, "Filesystem %s to update QoS does not exist", fsName)
+	}
+
+	fsID, err := utils.GetStringField(fs, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of filesystem %s error: %v", fsName, err)
+	}
+
+	vStoreID, _ := fs["vstoreId"].(string)
+	qosID, _ := fs["IOCLASSID"].(string)
+	err = p.updateQos(ctx, p.cli, fsID, "fs", vStoreID, qosID, qos)
+	if err != nil {
+		return utils.Errorf(ctx, "Update qos %v of filesystem %s error: %v", qos, fsName, err)
+	}
+
+	return nil
+}
+
+// Shrink reduces the capacity of the filesystem, which must not be less than the capacity the
+// filesystem uses. The filesystems of HyperMetro and replication pairs are refused.
+func (p *NAS) Shrink(ctx context.Context, name string, newSize utils.Capacity) error {
+	fsName := utils.GetFileSystemName(name)
+	fs, err := p.cli.GetFileSystemByName(ctx, fsName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get filesystem %s error: %v", fsName, err)
+		return err
+	}
+
+	if fs == nil {
+		return utils.KindErrorf(ctx, utils.ErrNotFound, "Filesystem %s to shrink does not exist", fsName)
+	}
+
+	fsID, err := utils.GetStringField(fs, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of filesystem %s error: %v", fsName, err)
+	}
+
+	curSize, err := utils.ParseSectors(fs, "CAPACITY")
+	if err != nil {
+		return utils.Errorf(ctx, "Get capacity of filesystem %s error: %v", fsName, err)
+	}
+	if newSize == curSize {
+		log.AddContext(ctx).Infof("Filesystem %s is already %d sectors", fsName, newSize.Sectors())
+		return nil
+	} else if newSize > curSize {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"Filesystem %s newSize %d must be smaller than curSize %d to shrink", fsName, newSize.Sectors(),
+			curSize.Sectors())
+	}
+
+	for _, pairField := range []string{"HYPERMETROPAIRIDS", "REMOTEREPLICATIONIDS"} {
+		var pairIDs []string
+		pairIDStr, _ := fs[pairField].(string)
+		_ = json.Unmarshal([]byte(pairIDStr), &pairIDs)
+		if len(pairIDs) > 0 {
+			return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+				"Filesystem %s is in pairs %v, the filesystems of pairs cannot be shrunk", fsName, pairIDs)
+		}
+	}
+
+	usedSize, err := utils.ParseSectors(fs, "ALLOCCAPACITY")
+	if err != nil {
+		return utils.Errorf(ctx, "Get used capacity of filesystem %s error: %v", fsName, err)
+	}
+	if newSize < usedSize {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"Filesystem %s uses %d sectors, it cannot be shrunk to %d", fsName, usedSize.Sectors(), newSize.Sectors())
+	}
+
+	err = p.cli.UpdateFileSystem(ctx, fsID, map[string]interface{}{"CAPACITY": newSize.Sectors()})
+	if err != nil {
+		log.AddContext(ctx).Errorf("Shrink filesystem %s to %d error: %v", fsName, newSize.Sectors(), err)
+		return err
+	}
+
+	log.AddContext(ctx).Infof("Filesystem %s is shrunk from %d to %d sectors", fsName, curSize, newSize)
+	return nil
+}
+
 func (p *NAS) Expand(ctx context.Context, name string, newSize int64) error {
 	fsName := utils.GetFileSystemName(name)
 	fs, err := p.cli.GetFileSystemByName(ctx, fsName)
@@ -754,24 +1076,45 @@ func (p *NAS) Expand(ctx context.Context, name string, newSize int64) error {
 	}
 
 	if fs == nil {
-		msg := fmt.Sprintf("Filesystem %s to expand does not exist", fsName)
-		log.AddContext(ctx).Errorf(msg)
-		return errors.New(msg)
+		return utils.KindErrorf(ctx, utils.ErrNotFound, "Filesystem %s to expand does not exist", fsName)
 	}
 
-	curSize, _ := strconv.ParseInt(fs["CAPACITY"].(string), 10, 64)
-	if newSize <= curSize {
-		msg := fmt.Sprintf("Filesystem %s newSize %d must be greater than curSize %d", fsName, newSize, curSize)
+	fsID, err := utils.GetStringField(fs, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of filesystem %s error: %v", fsName, err)
+	}
+	parentName, err := utils.GetStringField(fs, "PARENTNAME")
+	if err != nil {
+		return utils.Errorf(ctx, "Get parent name of filesystem %s error: %v", fsName, err)
+	}
+	capacity, err := utils.ParseSectors(fs, "CAPACITY")
+	if err != nil {
+		return utils.Errorf(ctx, "Get capacity of filesystem %s error: %v", fsName, err)
+	}
+	replicationIDStr, err := utils.GetStringField(fs, "REMOTEREPLICATIONIDS")
+	if err != nil {
+		return utils.Errorf(ctx, "Get replication pairs of filesystem %s error: %v", fsName, err)
+	}
+	hyperMetroIDStr, err := utils.GetStringField(fs, "HYPERMETROPAIRIDS")
+	if err != nil {
+		return utils.Errorf(ctx, "Get hypermetro pairs of filesystem %s error: %v", fsName, err)
+	}
+
+	// A filesystem already of the size is not expanded again, but the expansion of its remote
+	// filesystems and the synchronization of its pairs are still run, in case they failed last time
+	curSize := capacity.Sectors()
+	if newSize < curSize {
+		msg := fmt.Sprintf("Filesystem %s newSize %d must not be less than curSize %d", fsName, newSize, curSize)
 		log.AddContext(ctx).Errorln(msg)
 		return errors.New(msg)
 	}
 
 	var replicationIDs []string
-	replicationIDBytes := []byte(fs["REMOTEREPLICATIONIDS"].(string))
+	replicationIDBytes := []byte(replicationIDStr)
 	_ = json.Unmarshal(replicationIDBytes, &replicationIDs)
 
 	var hyperMetroIDs []string
-	hyperMetroIDBytes := []byte(fs["HYPERMETROPAIRIDS"].(string))
+	hyperMetroIDBytes := []byte(hyperMetroIDStr)
 	_ = json.Unmarshal(hyperMetroIDBytes, &hyperMetroIDs)
 
 	expandTask := taskflow.NewTaskFlow(ctx, "Expand-FileSystem-Volume")
@@ -784,6 +1127,7 @@ func (p *NAS) Expand(ctx context.Context, name string, newSize int64) error {
 			return errors.New(msg)
 		}
 		expandTask.AddTask("Expand-Remote-PreCheck-Capacity", p.preExpandCheckRemoteCapacity, nil)
+		expandTask.AddTask("Split-Replication", p.splitReplication, nil)
 		expandTask.AddTask("Expand-Replication-Remote-FileSystem", p.expandReplicationRemoteFS, nil)
 	}
 
@@ -796,16 +1140,26 @@ func (p *NAS) Expand(ctx context.Context, name string, newSize int64) error {
 
 		expandTask.AddTask("Expand-Remote-PreCheck-Capacity", p.preExpandCheckRemoteCapacity, nil)
 		expandTask.AddTask("Set-HyperMetro-ActiveClient", p.setActiveClient, nil)
+		expandTask.AddTask("Suspend-HyperMetro", p.suspendHyperMetro, nil)
 		expandTask.AddTask("Expand-HyperMetro-Remote-FileSystem", p.expandHyperMetroRemoteFS, nil)
 	}
 
 	expandTask.AddTask("Expand-Local-FileSystem", p.expandLocalFS, nil)
+
+	if len(replicationIDs) > 0 {
+		expandTask.AddTask("Sync-Replication", p.syncReplication, nil)
+	}
+
+	if len(hyperMetroIDs) > 0 {
+		expandTask.AddTask("Sync-HyperMetro", p.syncHyperMetro, nil)
+	}
+
 	params := map[string]interface{}{
 		"name":            name,
 		"size":            newSize,
 		"expandSize":      newSize - curSize,
-		"localFSID":       fs["ID"].(string),
-		"localParentName": fs["PARENTNAME"].(string),
+		"localFSID":       fsID,
+		"localParentName": parentName,
 		"replicationIDs":  replicationIDs,
 		"hyperMetroIDs":   hyperMetroIDs,
 	}
@@ -841,7 +1195,10 @@ func (p *NAS) getvStorePair(ctx context.Context) (map[string]interface{}, error)
 		return nil, errors.New(msg)
 	}
 
-	vStoreID := vStore["ID"].(string)
+	vStoreID, err := utils.GetStringField(vStore, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of vstore %s error: %v", localvStore, err)
+	}
 
 	vStorePair, err := p.cli.GetReplicationvStorePairByvStore(ctx, vStoreID)
 	if err != nil {
@@ -864,7 +1221,7 @@ func (p *NAS) getvStorePair(ctx context.Context) (map[string]interface{}, error)
 		return nil, errors.New(msg)
 	}
 
-	remotevStore := vStorePair["REMOTEVSTORENAME"].(string)
+	remotevStore, _ := vStorePair["REMOTEVSTORENAME"].(string)
 	if remotevStore != p.replicaRemoteCli.GetvStoreName() {
 		msg := fmt.Sprintf("Remote vstore %s does not correspond with configuration", remotevStore)
 		log.AddContext(ctx).Errorln(msg)
@@ -897,9 +1254,12 @@ func (p *NAS) getReplicationParams(ctx context.Context,
 	}
 
 	if vStorePair != nil {
-		vStorePairID = vStorePair["ID"].(string)
-		remoteDeviceID = vStorePair["REMOTEDEVICEID"].(string)
-		remoteDeviceSN = vStorePair["REMOTEDEVICESN"].(string)
+		vStorePairID, err = utils.GetStringField(vStorePair, "ID")
+		if err != nil {
+			return nil, utils.Errorf(ctx, "Get ID of replication vstore pair error: %v", err)
+		}
+		remoteDeviceID, _ = vStorePair["REMOTEDEVICEID"].(string)
+		remoteDeviceSN, _ = vStorePair["REMOTEDEVICESN"].(string)
 	}
 
 	remoteSystem, err := p.replicaRemoteCli.GetSystem(ctx)
@@ -909,7 +1269,10 @@ func (p *NAS) getReplicationParams(ctx context.Context,
 	}
 
 	if remoteDeviceID == "" {
-		sn := remoteSystem["ID"].(string)
+		sn, err := utils.GetStringField(remoteSystem, "ID")
+		if err != nil {
+			return nil, utils.Errorf(ctx, "Get SN of remote device error: %v", err)
+		}
 		remoteDeviceID, err = p.getRemoteDeviceID(ctx, sn)
 		if err != nil {
 			return nil, err
@@ -961,8 +1324,13 @@ func (p *NAS) createRemoteFS(ctx context.Context,
 		}
 	}
 
+	fsID, err := utils.GetStringField(fs, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of remote filesystem %s error: %v", fsName, err)
+	}
+
 	return map[string]interface{}{
-		"remoteFSID": fs["ID"].(string),
+		"remoteFSID": fsID,
 	}, nil
 }
 
@@ -990,8 +1358,12 @@ func (p *NAS) deleteReplicationPair(ctx context.Context,
 		if err != nil {
 			return nil, err
 		}
+		err = p.leaveReplicationGroup(ctx, pair)
+		if err != nil {
+			return nil, err
+		}
 
-		runningStatus := pair["RUNNINGSTATUS"].(string)
+		runningStatus, _ := pair["RUNNINGSTATUS"].(string)
 		if runningStatus == replicationPairRunningStatusNormal ||
 			runningStatus == replicationPairRunningStatusSync {
 			p.cli.SplitReplicationPair(ctx, pairID)
@@ -1047,14 +1419,21 @@ func (p *NAS) deleteShare(ctx context.Context, name, vStoreID string, cli client
 	}
 
 	if share != nil {
-		shareID := share["ID"].(string)
-		err := cli.DeleteNfsShare(ctx, shareID, vStoreID)
+		shareID, err := utils.GetStringField(share, "ID")
+		if err != nil {
+			return utils.Errorf(ctx, "Get ID of nfs share %s error: %v", sharePath, err)
+		}
+		err = cli.DeleteNfsShare(ctx, shareID, vStoreID)
 		if err != nil {
 			log.AddContext(ctx).Errorf("Delete share %s error: %v", shareID, err)
 			return err
 		}
 	}
 
+	if p.shareProtocol == ShareProtocolCIFS {
+		return p.deleteCifsShare(ctx, name, vStoreID, cli)
+	}
+
 	return nil
 }
 
@@ -1071,7 +1450,10 @@ func (p *NAS) deleteFS(ctx context.Context, name string, cli client.BaseClientIn
 		return nil
 	}
 
-	fsID := fs["ID"].(string)
+	fsID, err := utils.GetStringField(fs, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of filesystem %s error: %v", fsName, err)
+	}
 	vStoreID, _ := fs["vstoreId"].(string)
 	qosID, ok := fs["IOCLASSID"].(string)
 	if ok && qosID != "" {
@@ -1167,9 +1549,9 @@ func (p *NAS) createHyperMetro(ctx context.Context,
 		"HCRESOURCETYPE": 2, // 2: file system
 		"LOCALOBJID":     localFSID,
 		"REMOTEOBJID":    remoteFSID,
-		"SPEED":          4, // 4: highest speed
 		"VSTOREPAIRID":   vStorePairID,
 	}
+	setHyperMetroPairOptions(params, data)
 
 	metroDomainID, exist := params["metroDomainID"].(string)
 	if exist && metroDomainID != "" {
@@ -1182,7 +1564,10 @@ func (p *NAS) createHyperMetro(ctx context.Context,
 		return nil, err
 	}
 
-	pairID := pair["ID"].(string)
+	pairID, err := utils.GetStringField(pair, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of nas hypermetro pair error: %v", err)
+	}
 	// There is no need to synchronize when use NAS Dorado V6 or OceanStor V6 HyperMetro Volume
 	if p.product != utils.OceanStorDoradoV6 {
 		err = activeClient.SyncHyperMetroPair(ctx, pairID)
@@ -1217,7 +1602,7 @@ func (p *NAS) revertHyperMetro(ctx context.Context, taskResult map[string]interf
 		return nil
 	}
 
-	status := pair["RUNNINGSTATUS"].(string)
+	status, _ := pair["RUNNINGSTATUS"].(string)
 	if status == hyperMetroPairRunningStatusNormal ||
 		status == hyperMetroPairRunningStatusToSync ||
 		status == hyperMetroPairRunningStatusSyncing {
@@ -1246,7 +1631,7 @@ func (p *NAS) deleteHyperMetro(ctx context.Context,
 			continue
 		}
 
-		status := pair["RUNNINGSTATUS"].(string)
+		status, _ := pair["RUNNINGSTATUS"].(string)
 		if status == hyperMetroPairRunningStatusNormal ||
 			status == hyperMetroPairRunningStatusToSync ||
 			status == hyperMetroPairRunningStatusSyncing {
@@ -1326,20 +1711,26 @@ func (p *NAS) preExpandCheckRemoteCapacity(ctx context.Context,
 	}
 
 	newSize := params["size"].(int64)
-	curSize, err := strconv.ParseInt(remoteFs["CAPACITY"].(string), 10, 64)
+	curSize, err := utils.ParseSectors(remoteFs, "CAPACITY")
 	if err != nil {
 		return nil, err
 	}
 
-	if newSize < curSize {
-		msg := fmt.Sprintf("Remote Filesystem %s newSize %d must be greater than curSize %d",
-			remoteFsName, newSize, curSize)
+	if newSize < curSize.Sectors() {
+		msg := fmt.Sprintf("Remote Filesystem %s newSize %d must not be less than curSize %d",
+			remoteFsName, newSize, curSize.Sectors())
 		log.AddContext(ctx).Errorln(msg)
 		return nil, errors.New(msg)
 	}
 
+	remoteFSID, err := utils.GetStringField(remoteFs, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of remote filesystem %s error: %v", remoteFsName, err)
+	}
+
 	return map[string]interface{}{
-		"remoteFSID": remoteFs["ID"].(string),
+		"remoteFSID":       remoteFSID,
+		"remoteFSExpanded": newSize == curSize.Sectors(),
 	}, nil
 }
 
@@ -1359,6 +1750,11 @@ func (p *NAS) expandReplicationRemoteFS(ctx context.Context,
 	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
 	fsID := taskResult["remoteFSID"].(string)
 	newSize := params["size"].(int64)
+	if expanded, _ := taskResult["remoteFSExpanded"].(bool); expanded {
+		log.AddContext(ctx).Infof("Replica remote filesystem %s is already %d sectors", fsID, newSize)
+		return nil, nil
+	}
+
 	err := p.expandFS(ctx, fsID, newSize, p.replicaRemoteCli)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Expand replica filesystem %s error: %v", fsID, err)
@@ -1368,6 +1764,59 @@ func (p *NAS) expandReplicationRemoteFS(ctx context.Context,
 	return nil, err
 }
 
+// splitReplication splits the running replication pairs of the filesystem, the remote filesystem of a
+// pair cannot be expanded until its pair is split
+func (p *NAS) splitReplication(ctx context.Context,
+	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+	var replicationPairIDs []string
+	for _, pairID := range params["replicationIDs"].([]string) {
+		pair, err := p.cli.GetReplicationPairByID(ctx, pairID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Get nas replication pair %s error: %v", pairID, err)
+			return nil, err
+		}
+
+		runningStatus, _ := pair["RUNNINGSTATUS"].(string)
+		if runningStatus != replicationPairRunningStatusNormal &&
+			runningStatus != replicationPairRunningStatusSync {
+			continue
+		}
+
+		err = p.cli.SplitReplicationPair(ctx, pairID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Split nas replication pair %s error: %v", pairID, err)
+			return nil, err
+		}
+
+		replicationPairIDs = append(replicationPairIDs, pairID)
+		if isSyncReplicationPair(pair) {
+			err = p.waitReplicationPairSplit(ctx, pairID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"replicationPairIDs": replicationPairIDs,
+	}, nil
+}
+
+// syncReplication synchronizes the replication pairs split for the expansion
+func (p *NAS) syncReplication(ctx context.Context,
+	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+	replicationPairIDs, _ := taskResult["replicationPairIDs"].([]string)
+	for _, pairID := range replicationPairIDs {
+		err := p.cli.SyncReplicationPair(ctx, pairID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Sync nas replication pair %s error: %v", pairID, err)
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
 func (p *NAS) expandHyperMetroRemoteFS(ctx context.Context,
 	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
 	if p.product == "DoradoV6" {
@@ -1376,6 +1825,11 @@ func (p *NAS) expandHyperMetroRemoteFS(ctx context.Context,
 
 	fsID := taskResult["remoteFSID"].(string)
 	newSize := params["size"].(int64)
+	if expanded, _ := taskResult["remoteFSExpanded"].(bool); expanded {
+		log.AddContext(ctx).Infof("HyperMetro remote filesystem %s is already %d sectors", fsID, newSize)
+		return nil, nil
+	}
+
 	err := p.expandFS(ctx, fsID, newSize, p.metroRemoteCli)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Expand hyperMetro filesystem %s error: %v", fsID, err)
@@ -1385,11 +1839,98 @@ func (p *NAS) expandHyperMetroRemoteFS(ctx context.Context,
 	return nil, err
 }
 
+// suspendHyperMetro stops the HyperMetro pairs of the filesystem, or their consistency groups, before both
+// filesystems are expanded. The filesystems of Dorado V6 are expanded along with their pairs by storage.
+func (p *NAS) suspendHyperMetro(ctx context.Context,
+	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+	if p.product == utils.OceanStorDoradoV6 {
+		return nil, nil
+	}
+
+	activeClient := p.getActiveClient(taskResult)
+	var pairIDs, groupIDs []string
+	for _, pairID := range params["hyperMetroIDs"].([]string) {
+		pair, err := activeClient.GetHyperMetroPair(ctx, pairID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Get nas hypermetro pair %s error: %v", pairID, err)
+			return nil, err
+		}
+		if pair == nil {
+			continue
+		}
+
+		// The pairs of a consistency group are suspended with the group
+		if groupID := getHyperMetroGroupID(pair); groupID != "" {
+			if utils.IsContain(groupID, groupIDs) {
+				continue
+			}
+			if isHyperMetroRunning(pair) {
+				err := activeClient.StopHyperMetroGroup(ctx, groupID)
+				if err != nil {
+					log.AddContext(ctx).Errorf("Suspend nas hypermetro consistency group %s error: %v",
+						groupID, err)
+					return nil, err
+				}
+			}
+			groupIDs = append(groupIDs, groupID)
+			continue
+		}
+
+		if isHyperMetroRunning(pair) {
+			err := activeClient.StopHyperMetroPair(ctx, pairID)
+			if err != nil {
+				log.AddContext(ctx).Errorf("Suspend nas hypermetro pair %s error: %v", pairID, err)
+				return nil, err
+			}
+		}
+		pairIDs = append(pairIDs, pairID)
+	}
+
+	return map[string]interface{}{
+		"suspendedHyperMetroPairIDs":  pairIDs,
+		"suspendedHyperMetroGroupIDs": groupIDs,
+	}, nil
+}
+
+// syncHyperMetro synchronizes the HyperMetro pairs and consistency groups suspended for the expansion
+func (p *NAS) syncHyperMetro(ctx context.Context,
+	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+	activeClient := p.getActiveClient(taskResult)
+	groupIDs, _ := taskResult["suspendedHyperMetroGroupIDs"].([]string)
+	for _, groupID := range groupIDs {
+		err := activeClient.SyncHyperMetroGroup(ctx, groupID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Sync nas hypermetro consistency group %s error: %v", groupID, err)
+			return nil, err
+		}
+	}
+
+	pairIDs, _ := taskResult["suspendedHyperMetroPairIDs"].([]string)
+	for _, pairID := range pairIDs {
+		err := activeClient.SyncHyperMetroPair(ctx, pairID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Sync nas hypermetro pair %s error: %v", pairID, err)
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
 func (p *NAS) expandLocalFS(ctx context.Context,
 	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
 	newSize := params["size"].(int64)
 	activeClient := p.getActiveClient(taskResult)
 	fsID := p.getActiveFsID(taskResult)
+	expanded := params["expandSize"].(int64) == 0
+	if activeClient != p.cli {
+		expanded, _ = taskResult["remoteFSExpanded"].(bool)
+	}
+	if expanded {
+		log.AddContext(ctx).Infof("Filesystem %s is already %d sectors", fsID, newSize)
+		return nil, nil
+	}
+
 	err := p.expandFS(ctx, fsID, newSize, activeClient)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Expand filesystem %s error: %v", fsID, err)
@@ -1406,19 +1947,23 @@ func (p *NAS) CreateSnapshot(ctx context.Context, name, snapshotName string) (ma
 		return nil, err
 	}
 	if fs == nil {
-		msg := fmt.Sprintf("Filesystem %s to create snapshot does not exist", fsName)
-		log.AddContext(ctx).Errorf(msg)
-		return nil, errors.New(msg)
+		return nil, utils.KindErrorf(ctx, utils.ErrNotFound, "Filesystem %s to create snapshot does not exist", fsName)
 	}
 
-	fsId := fs["ID"].(string)
+	fsId, err := utils.GetStringField(fs, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of filesystem %s error: %v", fsName, err)
+	}
 	snapshot, err := p.cli.GetFSSnapshotByName(ctx, fsId, snapshotName)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Get filesystem snapshot by name %s error: %v", snapshotName, err)
 		return nil, err
 	}
 
-	snapshotSize, _ := strconv.ParseInt(fs["CAPACITY"].(string), 10, 64)
+	snapshotSize, err := utils.ParseSectors(fs, "CAPACITY")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get capacity of filesystem %s error: %v", fsName, err)
+	}
 	if snapshot != nil {
 		log.AddContext(ctx).Infof("The snapshot %s is already exist.", snapshotName)
 		return p.getSnapshotReturnInfo(snapshot, snapshotSize), nil
@@ -1446,7 +1991,10 @@ func (p *NAS) DeleteSnapshot(ctx context.Context, snapshotParentId, snapshotName
 		return nil
 	}
 
-	snapshotId := snapshot["ID"].(string)
+	snapshotId, err := utils.GetStringField(snapshot, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of filesystem snapshot %s error: %v", snapshotName, err)
+	}
 	err = p.cli.DeleteFSSnapshot(ctx, snapshotId)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Delete filesystem snapshot %s error: %v", snapshotId, err)
@@ -1456,6 +2004,65 @@ func (p *NAS) DeleteSnapshot(ctx context.Context, snapshotParentId, snapshotName
 	return nil
 }
 
+// QuerySnapshot returns the snapshot of the parent filesystem in the format of CreateSnapshot,
+// nil if the filesystem has no snapshot of the name
+func (p *NAS) QuerySnapshot(ctx context.Context,
+	parentID, snapshotName string) (map[string]interface{}, error) {
+	snapshot, err := p.cli.GetFSSnapshotByName(ctx, parentID, snapshotName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get filesystem snapshot by name %s error: %v", snapshotName, err)
+		return nil, err
+	}
+	if snapshot == nil {
+		return nil, nil
+	}
+
+	fs, err := p.cli.GetFileSystemByID(ctx, parentID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get filesystem by ID %s error: %v", parentID, err)
+		return nil, err
+	}
+
+	snapshotSize, err := utils.ParseSectors(fs, "CAPACITY")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get capacity of filesystem %s error: %v", parentID, err)
+	}
+	info := p.getSnapshotReturnInfo(snapshot, snapshotSize)
+	info["ParentName"], _ = snapshot["PARENTNAME"].(string)
+	info["ParentVolume"] = getParentVolume(fs)
+	return info, nil
+}
+
+// GetSnapshotDirectoryParent returns the name of the filesystem whose snapshot directory holds the
+// snapshot, the snapshot directory has to be visible
+func (p *NAS) GetSnapshotDirectoryParent(ctx context.Context, parentID, snapshotName string) (string, error) {
+	snapshot, err := p.cli.GetFSSnapshotByName(ctx, parentID, snapshotName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get filesystem snapshot by name %s error: %v", snapshotName, err)
+		return "", err
+	}
+	if snapshot == nil {
+		return "", utils.KindErrorf(ctx, utils.ErrNotFound, "Filesystem snapshot %s does not exist", snapshotName)
+	}
+
+	fs, err := p.cli.GetFileSystemByID(ctx, parentID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get filesystem by ID %s error: %v", parentID, err)
+		return "", err
+	}
+
+	fsName, err := utils.GetStringField(fs, "NAME")
+	if err != nil {
+		return "", utils.Errorf(ctx, "Get name of filesystem %s error: %v", parentID, err)
+	}
+	if fs["ISSHOWSNAPDIR"] == "false" {
+		return "", utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"Snapshot directory of filesystem %s is invisible", fsName)
+	}
+
+	return fsName, nil
+}
+
 func (p *NAS) getActiveClient(taskResult map[string]interface{}) client.BaseClientInterface {
 	activeClient, exist := taskResult["activeClient"].(client.BaseClientInterface)
 	if !exist {
@@ -0,0 +1,208 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"huawei-csi-driver/storage/oceanstor/client"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	cifsReadOnly    = 0
+	cifsFullControl = 1
+	cifsReadWrite   = 5
+
+	cifsDomainUser = 0
+	cifsLocalUser  = 2
+)
+
+var cifsPermissions = map[string]int{
+	"read_only":    cifsReadOnly,
+	"read_write":   cifsReadWrite,
+	"full_control": cifsFullControl,
+}
+
+// preCreateCifs checks the user that will be granted access to the CIFS share
+// and translates the configured permission into the storage value.
+func (p *NAS) preCreateCifs(ctx context.Context, params map[string]interface{}) error {
+	if user, _ := params["cifsuser"].(string); user == "" {
+		return utils.Errorf(ctx, "the username of the provisioner secret must be provided for cifs share")
+	}
+
+	val, _ := params["cifspermission"].(string)
+	if val == "" {
+		params["cifspermission"] = cifsFullControl
+		return nil
+	}
+
+	permission, exist := cifsPermissions[strings.ToLower(val)]
+	if !exist {
+		return utils.Errorf(ctx, "parameter cifsPermission [%v] in sc must be read_only, read_write or full_control",
+			val)
+	}
+
+	params["cifspermission"] = permission
+	return nil
+}
+
+func (p *NAS) createCifsShare(ctx context.Context,
+	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+	fsName := params["name"].(string)
+	activeClient := p.getActiveClient(taskResult)
+	vStoreID := p.getVStoreID(taskResult)
+	share, err := activeClient.GetCifsShareByName(ctx, fsName, vStoreID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get cifs share %s error: %v", fsName, err)
+		return nil, err
+	}
+
+	if share == nil {
+		shareParams := map[string]interface{}{
+			"name":        fsName,
+			"sharepath":   utils.GetSharePath(fsName),
+			"fsid":        p.getActiveFsID(taskResult),
+			"description": "Created from Kubernetes Provisioner",
+			"vStoreID":    vStoreID,
+		}
+
+		share, err = activeClient.CreateCifsShare(ctx, shareParams)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Create cifs share %v error: %v", shareParams, err)
+			return nil, err
+		}
+	}
+
+	shareID, err := utils.GetStringField(share, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of cifs share error: %v", err)
+	}
+
+	return map[string]interface{}{
+		"shareID": shareID,
+	}, nil
+}
+
+func (p *NAS) revertCifsShare(ctx context.Context, taskResult map[string]interface{}) error {
+	shareID, exist := taskResult["shareID"].(string)
+	if !exist || len(shareID) == 0 {
+		return nil
+	}
+	activeClient := p.getActiveClient(taskResult)
+	vStoreID := p.getVStoreID(taskResult)
+	return activeClient.DeleteCifsShare(ctx, shareID, vStoreID)
+}
+
+// allowCifsShareAccess grants the user of the provisioner secret access to the share. An existing
+// access of the user is kept, and its permission is updated if it differs. The access is removed
+// together with the share, so no revert is needed.
+func (p *NAS) allowCifsShareAccess(ctx context.Context,
+	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+	user := params["cifsuser"].(string)
+	permission := params["cifspermission"].(int)
+	shareID := taskResult["shareID"].(string)
+	vStoreID := p.getVStoreID(taskResult)
+	activeClient := p.getActiveClient(taskResult)
+
+	access, err := activeClient.GetCifsShareAccess(ctx, shareID, user, vStoreID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get access of user %s to cifs share %s error: %v", user, shareID, err)
+		return nil, err
+	}
+
+	if access != nil {
+		return nil, p.updateCifsShareAccess(ctx, access, permission, vStoreID, activeClient)
+	}
+
+	domainType := cifsLocalUser
+	if strings.Contains(user, "\\") {
+		domainType = cifsDomainUser
+	}
+
+	req := &client.AllowCifsShareAccessRequest{
+		Name:       user,
+		ParentID:   shareID,
+		Permission: permission,
+		DomainType: domainType,
+		VStoreID:   vStoreID,
+	}
+	err = activeClient.AllowCifsShareAccess(ctx, req)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Allow cifs share access %v failed. error: %v", req, err)
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// updateCifsShareAccess sets the permission of the existing cifs share access
+func (p *NAS) updateCifsShareAccess(ctx context.Context, access map[string]interface{}, permission int,
+	vStoreID string, cli client.BaseClientInterface) error {
+	accessID, err := utils.GetStringField(access, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of cifs share access error: %v", err)
+	}
+
+	current, err := utils.GetStringField(access, "PERMISSION")
+	if err != nil {
+		return utils.Errorf(ctx, "Get permission of cifs share access %s error: %v", accessID, err)
+	}
+	if current == strconv.Itoa(permission) {
+		return nil
+	}
+
+	err = cli.UpdateCifsShareAccess(ctx, accessID, permission, vStoreID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Update permission of cifs share access %s to %d error: %v",
+			accessID, permission, err)
+		return err
+	}
+
+	log.AddContext(ctx).Infof("Permission of cifs share access %s is updated from %s to %d", accessID,
+		current, permission)
+	return nil
+}
+
+func (p *NAS) deleteCifsShare(ctx context.Context, name, vStoreID string, cli client.BaseClientInterface) error {
+	shareName := utils.GetFileSystemName(name)
+	share, err := cli.GetCifsShareByName(ctx, shareName, vStoreID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get cifs share %s error: %v", shareName, err)
+		return err
+	}
+
+	if share == nil {
+		return nil
+	}
+
+	shareID, err := utils.GetStringField(share, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of cifs share %s error: %v", shareName, err)
+	}
+
+	err = cli.DeleteCifsShare(ctx, shareID, vStoreID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Delete cifs share %s error: %v", shareID, err)
+		return err
+	}
+
+	return nil
+}
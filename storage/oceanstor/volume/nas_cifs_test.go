@@ -0,0 +1,83 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/storage/oceanstor/client"
+)
+
+// fakeCifsClient keeps the accesses of a cifs share on a fake storage
+type fakeCifsClient struct {
+	*fakeClient
+	accesses map[string]map[string]interface{}
+	allowed  int
+	updated  int
+}
+
+func (c *fakeCifsClient) GetCifsShareAccess(_ context.Context, _, name, _ string) (map[string]interface{}, error) {
+	return c.accesses[name], nil
+}
+
+func (c *fakeCifsClient) AllowCifsShareAccess(_ context.Context, req *client.AllowCifsShareAccessRequest) error {
+	c.accesses[req.Name] = map[string]interface{}{
+		"ID":         strconv.Itoa(len(c.accesses)),
+		"NAME":       req.Name,
+		"PERMISSION": strconv.Itoa(req.Permission),
+	}
+	c.allowed++
+	return nil
+}
+
+func (c *fakeCifsClient) UpdateCifsShareAccess(_ context.Context, accessID string, permission int, _ string) error {
+	for _, access := range c.accesses {
+		if access["ID"] == accessID {
+			access["PERMISSION"] = strconv.Itoa(permission)
+		}
+	}
+	c.updated++
+	return nil
+}
+
+func TestAllowCifsShareAccess(t *testing.T) {
+	cli := &fakeCifsClient{fakeClient: newFakeClient(), accesses: map[string]map[string]interface{}{}}
+	nas := NewNAS(cli, nil, nil, "DoradoV6", NASHyperMetro{}, "cifs")
+	params := map[string]interface{}{"cifsuser": "user1", "cifspermission": cifsFullControl}
+	taskResult := map[string]interface{}{"shareID": "1"}
+
+	_, err := nas.allowCifsShareAccess(ctx, params, taskResult)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cli.allowed)
+
+	// a retried task keeps the existing access
+	_, err = nas.allowCifsShareAccess(ctx, params, taskResult)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cli.allowed)
+	assert.Equal(t, 0, cli.updated)
+
+	params["cifspermission"] = cifsReadOnly
+	_, err = nas.allowCifsShareAccess(ctx, params, taskResult)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cli.allowed)
+	assert.Equal(t, 1, cli.updated)
+	assert.Equal(t, strconv.Itoa(cifsReadOnly), cli.accesses["user1"]["PERMISSION"])
+}
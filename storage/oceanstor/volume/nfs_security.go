@@ -0,0 +1,83 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"huawei-csi-driver/utils"
+)
+
+const (
+	// NFSACLModePOSIX maps the NFSv4 ACLs of the share to the UNIX mode bits, which is the default
+	NFSACLModePOSIX = "posix"
+	// NFSACLModeNFS4 keeps the NFSv4 ACLs of the share, which are only set by the NFSv4 clients
+	NFSACLModeNFS4 = "nfs4"
+
+	nfsV4ACLDisabled = 0
+	nfsV4ACLEnabled  = 1
+)
+
+// NFSSecurityFlavors are the RPC security flavors the NFS clients are authenticated by, sys trusts the UIDs
+// of the clients and the Kerberos ones authenticate, check the integrity of and encrypt the traffic
+var NFSSecurityFlavors = []string{"sys", "krb5", "krb5i", "krb5p"}
+
+// CheckNFSSecurity checks the nfsAclMode and the nfsSecurityFlavors separated by ":" of the NFS share
+func CheckNFSSecurity(aclMode, flavors string) error {
+	if aclMode != "" && aclMode != NFSACLModePOSIX && aclMode != NFSACLModeNFS4 {
+		return fmt.Errorf("parameter nfsAclMode [%s] in sc must be %s or %s", aclMode, NFSACLModePOSIX,
+			NFSACLModeNFS4)
+	}
+
+	if flavors == "" {
+		return nil
+	}
+	for _, flavor := range strings.Split(flavors, ":") {
+		if !utils.IsContain(flavor, NFSSecurityFlavors) {
+			return fmt.Errorf("security flavor [%s] of parameter nfsSecurityFlavors in sc must be one of %v",
+				flavor, NFSSecurityFlavors)
+		}
+	}
+	return nil
+}
+
+// parseNFSSecurity converts the nfsaclmode parameter to the NFSv4 ACL switch of the share, the security
+// flavors are kept if they are set
+func (p *NAS) parseNFSSecurity(ctx context.Context, params map[string]interface{}) error {
+	aclMode, _ := params["nfsaclmode"].(string)
+	flavors, _ := params["nfssecurityflavors"].(string)
+	if aclMode == "" && flavors == "" {
+		return nil
+	}
+
+	if p.shareProtocol == ShareProtocolCIFS {
+		return utils.Errorln(ctx, "parameters nfsAclMode and nfsSecurityFlavors in sc are only for NFS shares")
+	}
+	err := CheckNFSSecurity(aclMode, flavors)
+	if err != nil {
+		return utils.Errorln(ctx, err.Error())
+	}
+
+	if aclMode == NFSACLModeNFS4 {
+		params["nfsv4acl"] = nfsV4ACLEnabled
+	} else if aclMode == NFSACLModePOSIX {
+		params["nfsv4acl"] = nfsV4ACLDisabled
+	}
+	return nil
+}
@@ -0,0 +1,555 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+	"huawei-csi-driver/utils/taskflow"
+)
+
+// GroupSnapshotMember is the snapshot of a member LUN or filesystem taken by a group snapshot
+type GroupSnapshotMember struct {
+	ParentName string
+	ParentID   string
+	Name       string
+}
+
+// memberSnapshot is the snapshot of a member filesystem in the result of the group snapshot taskflow
+type memberSnapshot struct {
+	GroupSnapshotMember
+	id      string
+	created bool
+}
+
+func (p *SAN) getLunID(ctx context.Context, lunName string) (string, error) {
+	lun, err := p.cli.GetLunByName(ctx, lunName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun by name %s error: %v", lunName, err)
+		return "", err
+	}
+	if lun == nil {
+		return "", utils.KindErrorf(ctx, utils.ErrNotFound, "Lun %s does not exist", lunName)
+	}
+
+	return utils.GetStringField(lun, "ID")
+}
+
+// isInProtectGroup tells whether the LUN is a member of the protection group by its protectGroupIds,
+// which lists the groups of the LUN
+func isInProtectGroup(lun map[string]interface{}, groupID string) bool {
+	groupIDs, _ := lun["protectGroupIds"].(string)
+	for _, id := range strings.Split(strings.Trim(groupIDs, "[]"), ",") {
+		if strings.Trim(id, "\" ") == groupID {
+			return true
+		}
+	}
+	return false
+}
+
+// EnsureProtectGroup creates the protection group if it does not exist, and adds the LUNs to it
+func (p *SAN) EnsureProtectGroup(ctx context.Context, groupName string, lunNames []string) error {
+	group, err := p.cli.GetProtectGroupByName(ctx, groupName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get protection group %s error: %v", groupName, err)
+		return err
+	}
+	if group == nil {
+		group, err = p.cli.CreateProtectGroup(ctx, groupName)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Create protection group %s error: %v", groupName, err)
+			return err
+		}
+		log.AddContext(ctx).Infof("Protection group %s is created", groupName)
+	}
+
+	groupID, err := utils.GetStringField(group, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of protection group %s error: %v", groupName, err)
+	}
+
+	for _, lunName := range lunNames {
+		lun, err := p.cli.GetLunByName(ctx, lunName)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Get lun by name %s error: %v", lunName, err)
+			return err
+		}
+		if lun == nil {
+			return utils.KindErrorf(ctx, utils.ErrNotFound, "Lun %s of protection group %s does not exist",
+				lunName, groupName)
+		}
+		if isInProtectGroup(lun, groupID) {
+			continue
+		}
+
+		lunID, err := utils.GetStringField(lun, "ID")
+		if err != nil {
+			return utils.Errorf(ctx, "Get ID of lun %s error: %v", lunName, err)
+		}
+		err = p.cli.AddLunToProtectGroup(ctx, lunID, groupID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Add lun %s to protection group %s error: %v", lunName, groupName, err)
+			return err
+		}
+		log.AddContext(ctx).Infof("Lun %s is added to protection group %s", lunName, groupName)
+	}
+
+	return nil
+}
+
+// RemoveFromProtectGroup removes the LUNs from the protection group, the LUNs no longer exist are skipped
+func (p *SAN) RemoveFromProtectGroup(ctx context.Context, groupName string, lunNames []string) error {
+	group, err := p.cli.GetProtectGroupByName(ctx, groupName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get protection group %s error: %v", groupName, err)
+		return err
+	}
+	if group == nil {
+		return nil
+	}
+
+	groupID, err := utils.GetStringField(group, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of protection group %s error: %v", groupName, err)
+	}
+
+	for _, lunName := range lunNames {
+		lun, err := p.cli.GetLunByName(ctx, lunName)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Get lun by name %s error: %v", lunName, err)
+			return err
+		}
+		if lun == nil || !isInProtectGroup(lun, groupID) {
+			continue
+		}
+
+		lunID, err := utils.GetStringField(lun, "ID")
+		if err != nil {
+			return utils.Errorf(ctx, "Get ID of lun %s error: %v", lunName, err)
+		}
+		err = p.cli.RemoveLunFromProtectGroup(ctx, lunID, groupID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Remove lun %s from protection group %s error: %v",
+				lunName, groupName, err)
+			return err
+		}
+		log.AddContext(ctx).Infof("Lun %s is removed from protection group %s", lunName, groupName)
+	}
+
+	return nil
+}
+
+// DeleteProtectGroup deletes the replication group and the protection group of the LUNs, the LUNs
+// and their replication pairs are kept
+func (p *SAN) DeleteProtectGroup(ctx context.Context, groupName string, lunNames []string) error {
+	err := p.deleteReplicationGroup(ctx, groupName, lunNames)
+	if err != nil {
+		return err
+	}
+
+	err = p.RemoveFromProtectGroup(ctx, groupName, lunNames)
+	if err != nil {
+		return err
+	}
+
+	group, err := p.cli.GetProtectGroupByName(ctx, groupName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get protection group %s error: %v", groupName, err)
+		return err
+	}
+	if group == nil {
+		log.AddContext(ctx).Infof("Protection group %s to delete does not exist", groupName)
+		return nil
+	}
+
+	groupID, err := utils.GetStringField(group, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of protection group %s error: %v", groupName, err)
+	}
+	return p.cli.DeleteProtectGroup(ctx, groupID)
+}
+
+// CreateGroupSnapshot takes a snapshot of the protection group, which snapshots all the member LUNs
+// at the same point in time, and returns the snapshots of the members. The snapshot consistency group
+// created is deleted if it fails to be activated, so no inactive group is left on storage.
+func (p *SAN) CreateGroupSnapshot(ctx context.Context,
+	groupName, snapshotName string) ([]GroupSnapshotMember, error) {
+	params := map[string]interface{}{
+		"groupName":    groupName,
+		"snapshotName": snapshotName,
+	}
+
+	createTask := taskflow.NewTaskFlow(ctx, "Create-Group-Snapshot")
+	createTask.AddTask("Create-Snapshot-Consistency-Group", p.createSnapshotGroup, p.revertSnapshotGroup)
+	createTask.AddTask("Activate-Snapshot-Consistency-Group", p.activateSnapshotGroup, nil)
+	createTask.AddTask("Get-Group-Snapshot-Members", p.getGroupSnapshotMembers, nil)
+
+	res, err := createTask.Run(params)
+	if err != nil {
+		createTask.Revert()
+		return nil, err
+	}
+
+	members, _ := res["members"].([]GroupSnapshotMember)
+	log.AddContext(ctx).Infof("Snapshot %s of protection group %s is taken with %d members",
+		snapshotName, groupName, len(members))
+	return members, nil
+}
+
+func (p *SAN) createSnapshotGroup(ctx context.Context,
+	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+	groupName, snapshotName := params["groupName"].(string), params["snapshotName"].(string)
+	snapshotGroup, err := p.cli.GetSnapshotConsistencyGroupByName(ctx, snapshotName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get snapshot consistency group %s error: %v", snapshotName, err)
+		return nil, err
+	}
+
+	// A group left by an earlier attempt is reused, but not deleted on failure as it may be in use
+	created := snapshotGroup == nil
+	if created {
+		group, err := p.cli.GetProtectGroupByName(ctx, groupName)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Get protection group %s error: %v", groupName, err)
+			return nil, err
+		}
+		if group == nil {
+			return nil, utils.KindErrorf(ctx, utils.ErrNotFound, "Protection group %s does not exist", groupName)
+		}
+
+		groupID, err := utils.GetStringField(group, "ID")
+		if err != nil {
+			return nil, utils.Errorf(ctx, "Get ID of protection group %s error: %v", groupName, err)
+		}
+		snapshotGroup, err = p.cli.CreateSnapshotConsistencyGroup(ctx, snapshotName, groupID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Create snapshot of protection group %s error: %v", groupName, err)
+			return nil, err
+		}
+	}
+
+	snapshotGroupID, err := utils.GetStringField(snapshotGroup, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of snapshot consistency group %s error: %v", snapshotName, err)
+	}
+
+	return map[string]interface{}{
+		"snapshotGroupID":      snapshotGroupID,
+		"snapshotGroupCreated": created,
+	}, nil
+}
+
+func (p *SAN) revertSnapshotGroup(ctx context.Context, taskResult map[string]interface{}) error {
+	snapshotGroupID, _ := taskResult["snapshotGroupID"].(string)
+	if created, _ := taskResult["snapshotGroupCreated"].(bool); !created || snapshotGroupID == "" {
+		return nil
+	}
+
+	// A group which has never been activated is deleted directly
+	if activated, _ := taskResult["snapshotGroupActivated"].(bool); activated {
+		err := p.cli.DeactivateSnapshotConsistencyGroup(ctx, snapshotGroupID)
+		if err != nil {
+			return err
+		}
+	}
+	return p.cli.DeleteSnapshotConsistencyGroup(ctx, snapshotGroupID)
+}
+
+func (p *SAN) activateSnapshotGroup(ctx context.Context,
+	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+	snapshotGroupID := taskResult["snapshotGroupID"].(string)
+
+	// Activating an activated group is harmless, a retry after a failed activation needs it
+	err := p.cli.ActivateSnapshotConsistencyGroup(ctx, snapshotGroupID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Activate snapshot consistency group %s error: %v", params["snapshotName"], err)
+		return nil, err
+	}
+	return map[string]interface{}{"snapshotGroupActivated": true}, nil
+}
+
+func (p *SAN) getGroupSnapshotMembers(ctx context.Context,
+	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+	snapshotGroupID := taskResult["snapshotGroupID"].(string)
+	snapshots, err := p.cli.GetSnapshotsOfConsistencyGroup(ctx, snapshotGroupID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get snapshots of snapshot consistency group %s error: %v",
+			params["snapshotName"], err)
+		return nil, err
+	}
+
+	var members []GroupSnapshotMember
+	for _, snapshot := range snapshots {
+		lunName, _ := snapshot["PARENTNAME"].(string)
+		parentID, _ := snapshot["PARENTID"].(string)
+		name, _ := snapshot["NAME"].(string)
+		members = append(members, GroupSnapshotMember{ParentName: lunName, ParentID: parentID, Name: name})
+	}
+	return map[string]interface{}{"members": members}, nil
+}
+
+// DeleteGroupSnapshot deletes the snapshot of a protection group together with the snapshots of its members
+func (p *SAN) DeleteGroupSnapshot(ctx context.Context, snapshotName string) error {
+	snapshotGroup, err := p.cli.GetSnapshotConsistencyGroupByName(ctx, snapshotName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get snapshot consistency group %s error: %v", snapshotName, err)
+		return err
+	}
+	if snapshotGroup == nil {
+		log.AddContext(ctx).Infof("Snapshot consistency group %s to delete does not exist", snapshotName)
+		return nil
+	}
+
+	snapshotGroupID, err := utils.GetStringField(snapshotGroup, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of snapshot consistency group %s error: %v", snapshotName, err)
+	}
+
+	err = p.cli.DeactivateSnapshotConsistencyGroup(ctx, snapshotGroupID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Deactivate snapshot consistency group %s error: %v", snapshotName, err)
+		return err
+	}
+
+	err = p.cli.DeleteSnapshotConsistencyGroup(ctx, snapshotGroupID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Delete snapshot consistency group %s error: %v", snapshotName, err)
+		return err
+	}
+	return nil
+}
+
+// CreateGroupSnapshot snapshots the filesystems as a unit, the snapshots taken are deleted if any of the
+// filesystems fails to be snapshotted, so no partial group snapshot is left on storage. Storage has no
+// consistency group for filesystems and they are snapshotted one after another, so their snapshots are
+// consistent with each other only if the application is quiesced.
+func (p *NAS) CreateGroupSnapshot(ctx context.Context,
+	snapshotName string, fsNames []string) ([]GroupSnapshotMember, error) {
+	createTask := taskflow.NewTaskFlow(ctx, "Create-Filesystem-Group-Snapshot")
+	for _, fsName := range fsNames {
+		createTask.AddTask("Create-Filesystem-Snapshot-"+fsName, p.createMemberSnapshot(fsName),
+			p.revertMemberSnapshot(fsName))
+	}
+
+	res, err := createTask.Run(map[string]interface{}{"snapshotName": snapshotName})
+	if err != nil {
+		createTask.Revert()
+		return nil, err
+	}
+
+	members := make([]GroupSnapshotMember, 0, len(fsNames))
+	for _, fsName := range fsNames {
+		if snapshot, ok := res[fsName].(memberSnapshot); ok {
+			members = append(members, snapshot.GroupSnapshotMember)
+		}
+	}
+	log.AddContext(ctx).Infof("Group snapshot %s is taken with %d filesystems", snapshotName, len(members))
+	return members, nil
+}
+
+func (p *NAS) createMemberSnapshot(fsName string) taskflow.TaskRunFunc {
+	return func(ctx context.Context, params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+		snapshotName := params["snapshotName"].(string)
+		fs, err := p.cli.GetFileSystemByName(ctx, fsName)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Get filesystem by name %s error: %v", fsName, err)
+			return nil, err
+		}
+		if fs == nil {
+			return nil, utils.KindErrorf(ctx, utils.ErrNotFound, "Filesystem %s of group snapshot %s does not exist",
+				fsName, snapshotName)
+		}
+
+		fsID, err := utils.GetStringField(fs, "ID")
+		if err != nil {
+			return nil, utils.Errorf(ctx, "Get ID of filesystem %s error: %v", fsName, err)
+		}
+		snapshot, err := p.cli.GetFSSnapshotByName(ctx, fsID, snapshotName)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Get filesystem snapshot by name %s error: %v", snapshotName, err)
+			return nil, err
+		}
+
+		// A snapshot left by an earlier attempt is reused, but not deleted on failure as it may be in use
+		created := snapshot == nil
+		if created {
+			snapshot, err = p.cli.CreateFSSnapshot(ctx, snapshotName, fsID)
+			if err != nil {
+				log.AddContext(ctx).Errorf("Create snapshot %s for filesystem %s error: %v", snapshotName, fsName, err)
+				return nil, err
+			}
+		}
+
+		snapshotID, err := utils.GetStringField(snapshot, "ID")
+		if err != nil {
+			return nil, utils.Errorf(ctx, "Get ID of filesystem snapshot %s error: %v", snapshotName, err)
+		}
+		return map[string]interface{}{
+			fsName: memberSnapshot{
+				GroupSnapshotMember: GroupSnapshotMember{ParentName: fsName, ParentID: fsID, Name: snapshotName},
+				id:                  snapshotID,
+				created:             created,
+			},
+		}, nil
+	}
+}
+
+func (p *NAS) revertMemberSnapshot(fsName string) taskflow.TaskRevertFunc {
+	return func(ctx context.Context, taskResult map[string]interface{}) error {
+		snapshot, ok := taskResult[fsName].(memberSnapshot)
+		if !ok || !snapshot.created {
+			return nil
+		}
+		return p.cli.DeleteFSSnapshot(ctx, snapshot.id)
+	}
+}
+
+// DeleteGroupSnapshot deletes the snapshots of the member filesystems taken by a group snapshot
+func (p *NAS) DeleteGroupSnapshot(ctx context.Context, members []GroupSnapshotMember) error {
+	for _, member := range members {
+		err := p.DeleteSnapshot(ctx, member.ParentID, member.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *SAN) getReplicationPair(ctx context.Context, lunName string) (map[string]interface{}, error) {
+	lunID, err := p.getLunID(ctx, lunName)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs, err := p.cli.GetReplicationPairByResID(ctx, lunID, 11)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get replication pair of lun %s error: %v", lunName, err)
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+
+	return pairs[0], nil
+}
+
+// EnsureReplicationGroup puts the replication pairs of the LUNs in the replication consistency group of
+// the name, so that they are synchronized and failed over as a unit
+func (p *SAN) EnsureReplicationGroup(ctx context.Context, groupName string, lunNames []string) error {
+	group, err := p.cli.GetReplicationGroupByName(ctx, groupName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get replication consistency group %s error: %v", groupName, err)
+		return err
+	}
+
+	var added bool
+	for _, lunName := range lunNames {
+		pair, err := p.getReplicationPair(ctx, lunName)
+		if err != nil {
+			return err
+		}
+		if pair == nil {
+			return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+				"Lun %s of protection group %s is not replicated", lunName, groupName)
+		}
+		if isInGroup, _ := pair["ISINCG"].(string); isInGroup == "true" {
+			continue
+		}
+
+		if group == nil {
+			model, _ := pair["REPLICATIONMODEL"].(string)
+			replicationModel, _ := strconv.Atoi(model)
+			group, err = p.cli.CreateReplicationGroup(ctx, groupName, replicationModel)
+			if err != nil {
+				log.AddContext(ctx).Errorf("Create replication consistency group %s error: %v", groupName, err)
+				return err
+			}
+		}
+
+		groupID, err := utils.GetStringField(group, "ID")
+		if err != nil {
+			return utils.Errorf(ctx, "Get ID of replication consistency group %s error: %v", groupName, err)
+		}
+		pairID, err := utils.GetStringField(pair, "ID")
+		if err != nil {
+			return utils.Errorf(ctx, "Get ID of replication pair of lun %s error: %v", lunName, err)
+		}
+
+		// A pair has to be split before it joins a consistency group
+		err = p.cli.SplitReplicationPair(ctx, pairID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Split replication pair %s error: %v", pairID, err)
+			return err
+		}
+		err = p.cli.AddPairToReplicationGroup(ctx, pairID, groupID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Add replication pair %s to group %s error: %v", pairID, groupName, err)
+			return err
+		}
+		added = true
+	}
+
+	if !added {
+		return nil
+	}
+
+	groupID, _ := utils.GetStringField(group, "ID")
+	return p.cli.SyncReplicationGroup(ctx, groupID)
+}
+
+func (p *SAN) deleteReplicationGroup(ctx context.Context, groupName string, lunNames []string) error {
+	group, err := p.cli.GetReplicationGroupByName(ctx, groupName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get replication consistency group %s error: %v", groupName, err)
+		return err
+	}
+	if group == nil {
+		return nil
+	}
+
+	groupID, err := utils.GetStringField(group, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of replication consistency group %s error: %v", groupName, err)
+	}
+
+	for _, lunName := range lunNames {
+		pair, err := p.getReplicationPair(ctx, lunName)
+		if errors.Is(err, utils.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if pair == nil || pair["CGID"] != groupID {
+			continue
+		}
+
+		pairID, _ := utils.GetStringField(pair, "ID")
+		err = p.cli.RemovePairFromReplicationGroup(ctx, pairID, groupID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Remove replication pair %s from group %s error: %v", pairID, groupName, err)
+			return err
+		}
+	}
+
+	return p.cli.DeleteReplicationGroup(ctx, groupID)
+}
@@ -0,0 +1,253 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProtectGroupClient keeps the protection groups and snapshot consistency groups on a fake storage
+type fakeProtectGroupClient struct {
+	*fakeClient
+	protectGroups  map[string]string
+	snapshotGroups map[string]map[string]interface{}
+	addedLuns      []string
+	activateErr    error
+	deactivateErr  error
+	membersErr     error
+}
+
+func newFakeProtectGroupClient() *fakeProtectGroupClient {
+	cli := &fakeProtectGroupClient{
+		fakeClient:     newFakeClient(),
+		protectGroups:  map[string]string{},
+		snapshotGroups: map[string]map[string]interface{}{},
+	}
+	cli.luns["1"] = map[string]interface{}{"ID": "1", "NAME": "pvc-1"}
+	cli.luns["2"] = map[string]interface{}{"ID": "2", "NAME": "pvc-2"}
+	return cli
+}
+
+func (c *fakeProtectGroupClient) GetProtectGroupByName(_ context.Context,
+	name string) (map[string]interface{}, error) {
+	if id, exist := c.protectGroups[name]; exist {
+		return map[string]interface{}{"ID": id, "NAME": name}, nil
+	}
+	return nil, nil
+}
+
+func (c *fakeProtectGroupClient) CreateProtectGroup(_ context.Context, name string) (map[string]interface{}, error) {
+	c.protectGroups[name] = fmt.Sprintf("%d", len(c.protectGroups)+10)
+	return map[string]interface{}{"ID": c.protectGroups[name], "NAME": name}, nil
+}
+
+func (c *fakeProtectGroupClient) AddLunToProtectGroup(_ context.Context, lunID, groupID string) error {
+	c.luns[lunID]["protectGroupIds"] = fmt.Sprintf(`["%s"]`, groupID)
+	c.addedLuns = append(c.addedLuns, lunID)
+	return nil
+}
+
+func (c *fakeProtectGroupClient) GetSnapshotConsistencyGroupByName(_ context.Context,
+	name string) (map[string]interface{}, error) {
+	return c.snapshotGroups[name], nil
+}
+
+func (c *fakeProtectGroupClient) CreateSnapshotConsistencyGroup(_ context.Context,
+	name, groupID string) (map[string]interface{}, error) {
+	c.snapshotGroups[name] = map[string]interface{}{"ID": name, "PARENTID": groupID, "active": false}
+	return c.snapshotGroups[name], nil
+}
+
+func (c *fakeProtectGroupClient) ActivateSnapshotConsistencyGroup(_ context.Context, id string) error {
+	if c.activateErr != nil {
+		return c.activateErr
+	}
+	c.snapshotGroups[id]["active"] = true
+	return nil
+}
+
+func (c *fakeProtectGroupClient) DeactivateSnapshotConsistencyGroup(_ context.Context, id string) error {
+	if c.deactivateErr != nil {
+		return c.deactivateErr
+	}
+	c.snapshotGroups[id]["active"] = false
+	return nil
+}
+
+func (c *fakeProtectGroupClient) DeleteSnapshotConsistencyGroup(_ context.Context, id string) error {
+	delete(c.snapshotGroups, id)
+	return nil
+}
+
+func (c *fakeProtectGroupClient) GetSnapshotsOfConsistencyGroup(_ context.Context,
+	id string) ([]map[string]interface{}, error) {
+	if c.membersErr != nil {
+		return nil, c.membersErr
+	}
+	return []map[string]interface{}{
+		{"NAME": id + "_1", "PARENTID": "1", "PARENTNAME": "pvc-1"},
+		{"NAME": id + "_2", "PARENTID": "2", "PARENTNAME": "pvc-2"},
+	}, nil
+}
+
+func TestEnsureProtectGroup(t *testing.T) {
+	cli := newFakeProtectGroupClient()
+	san := NewSAN(cli, nil, nil, "DoradoV6")
+
+	assert.NoError(t, san.EnsureProtectGroup(ctx, "k8s_pg_1", []string{"pvc-1", "pvc-2"}))
+	assert.Equal(t, []string{"1", "2"}, cli.addedLuns)
+
+	// the members already in the group are not added again
+	assert.NoError(t, san.EnsureProtectGroup(ctx, "k8s_pg_1", []string{"pvc-1", "pvc-2"}))
+	assert.Equal(t, []string{"1", "2"}, cli.addedLuns)
+	assert.Len(t, cli.protectGroups, 1)
+
+	assert.Error(t, san.EnsureProtectGroup(ctx, "k8s_pg_1", []string{"pvc-3"}))
+}
+
+func TestCreateGroupSnapshot(t *testing.T) {
+	cli := newFakeProtectGroupClient()
+	san := NewSAN(cli, nil, nil, "DoradoV6")
+	assert.NoError(t, san.EnsureProtectGroup(ctx, "k8s_pg_1", []string{"pvc-1", "pvc-2"}))
+
+	members, err := san.CreateGroupSnapshot(ctx, "k8s_pg_1", "k8s_gs_1")
+	assert.NoError(t, err)
+	assert.Equal(t, []GroupSnapshotMember{{ParentName: "pvc-1", ParentID: "1", Name: "k8s_gs_1_1"},
+		{ParentName: "pvc-2", ParentID: "2", Name: "k8s_gs_1_2"}}, members)
+	assert.Equal(t, true, cli.snapshotGroups["k8s_gs_1"]["active"])
+
+	// a group failed to activate is deleted without being deactivated
+	cli.activateErr = errors.New("activate error")
+	cli.deactivateErr = errors.New("deactivate error")
+	_, err = san.CreateGroupSnapshot(ctx, "k8s_pg_1", "k8s_gs_2")
+	assert.Error(t, err)
+	assert.NotContains(t, cli.snapshotGroups, "k8s_gs_2")
+
+	// an activated group is deactivated before it is deleted
+	cli.activateErr, cli.deactivateErr = nil, nil
+	cli.membersErr = errors.New("query error")
+	_, err = san.CreateGroupSnapshot(ctx, "k8s_pg_1", "k8s_gs_2")
+	assert.Error(t, err)
+	assert.NotContains(t, cli.snapshotGroups, "k8s_gs_2")
+	cli.membersErr = nil
+
+	_, err = san.CreateGroupSnapshot(ctx, "k8s_pg_2", "k8s_gs_3")
+	assert.Error(t, err)
+}
+
+func TestDeleteGroupSnapshot(t *testing.T) {
+	cli := newFakeProtectGroupClient()
+	san := NewSAN(cli, nil, nil, "DoradoV6")
+	assert.NoError(t, san.EnsureProtectGroup(ctx, "k8s_pg_1", []string{"pvc-1"}))
+	_, err := san.CreateGroupSnapshot(ctx, "k8s_pg_1", "k8s_gs_1")
+	assert.NoError(t, err)
+
+	cli.deactivateErr = errors.New("deactivate error")
+	assert.Error(t, san.DeleteGroupSnapshot(ctx, "k8s_gs_1"))
+	assert.Contains(t, cli.snapshotGroups, "k8s_gs_1")
+
+	cli.deactivateErr = nil
+	assert.NoError(t, san.DeleteGroupSnapshot(ctx, "k8s_gs_1"))
+	assert.NotContains(t, cli.snapshotGroups, "k8s_gs_1")
+	assert.NoError(t, san.DeleteGroupSnapshot(ctx, "k8s_gs_1"))
+}
+
+// fakeFSSnapshotClient keeps the filesystems and their snapshots on a fake storage
+type fakeFSSnapshotClient struct {
+	*fakeClient
+	filesystems map[string]string
+	fsSnapshots map[string]map[string]interface{}
+	createErr   map[string]error
+}
+
+func newFakeFSSnapshotClient() *fakeFSSnapshotClient {
+	return &fakeFSSnapshotClient{
+		fakeClient:  newFakeClient(),
+		filesystems: map[string]string{"pvc-1": "1", "pvc-2": "2"},
+		fsSnapshots: map[string]map[string]interface{}{},
+		createErr:   map[string]error{},
+	}
+}
+
+func (c *fakeFSSnapshotClient) GetFileSystemByName(_ context.Context, name string) (map[string]interface{}, error) {
+	if id, exist := c.filesystems[name]; exist {
+		return map[string]interface{}{"ID": id, "NAME": name}, nil
+	}
+	return nil, nil
+}
+
+func (c *fakeFSSnapshotClient) GetFSSnapshotByName(_ context.Context,
+	parentID, name string) (map[string]interface{}, error) {
+	return c.fsSnapshots[parentID+"."+name], nil
+}
+
+func (c *fakeFSSnapshotClient) CreateFSSnapshot(_ context.Context,
+	name, parentID string) (map[string]interface{}, error) {
+	if err := c.createErr[parentID]; err != nil {
+		return nil, err
+	}
+	c.fsSnapshots[parentID+"."+name] = map[string]interface{}{"ID": parentID + "." + name, "NAME": name}
+	return c.fsSnapshots[parentID+"."+name], nil
+}
+
+func (c *fakeFSSnapshotClient) DeleteFSSnapshot(_ context.Context, id string) error {
+	delete(c.fsSnapshots, id)
+	return nil
+}
+
+func TestCreateFilesystemGroupSnapshot(t *testing.T) {
+	cli := newFakeFSSnapshotClient()
+	nas := NewNAS(cli, nil, nil, "DoradoV6", NASHyperMetro{}, "nfs")
+
+	members, err := nas.CreateGroupSnapshot(ctx, "k8s_gs_1", []string{"pvc-1", "pvc-2"})
+	assert.NoError(t, err)
+	assert.Equal(t, []GroupSnapshotMember{{ParentName: "pvc-1", ParentID: "1", Name: "k8s_gs_1"},
+		{ParentName: "pvc-2", ParentID: "2", Name: "k8s_gs_1"}}, members)
+	assert.Len(t, cli.fsSnapshots, 2)
+
+	// the snapshots taken are deleted if a filesystem fails to be snapshotted
+	cli.createErr["2"] = errors.New("create error")
+	_, err = nas.CreateGroupSnapshot(ctx, "k8s_gs_2", []string{"pvc-1", "pvc-2"})
+	assert.Error(t, err)
+	assert.NotContains(t, cli.fsSnapshots, "1.k8s_gs_2")
+
+	// a snapshot left by an earlier attempt is kept
+	cli.fsSnapshots["1.k8s_gs_3"] = map[string]interface{}{"ID": "1.k8s_gs_3", "NAME": "k8s_gs_3"}
+	_, err = nas.CreateGroupSnapshot(ctx, "k8s_gs_3", []string{"pvc-1", "pvc-2"})
+	assert.Error(t, err)
+	assert.Contains(t, cli.fsSnapshots, "1.k8s_gs_3")
+
+	_, err = nas.CreateGroupSnapshot(ctx, "k8s_gs_4", []string{"pvc-1", "pvc-3"})
+	assert.Error(t, err)
+	assert.NotContains(t, cli.fsSnapshots, "1.k8s_gs_4")
+}
+
+func TestDeleteFilesystemGroupSnapshot(t *testing.T) {
+	cli := newFakeFSSnapshotClient()
+	nas := NewNAS(cli, nil, nil, "DoradoV6", NASHyperMetro{}, "nfs")
+	members, err := nas.CreateGroupSnapshot(ctx, "k8s_gs_1", []string{"pvc-1", "pvc-2"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, nas.DeleteGroupSnapshot(ctx, members))
+	assert.Empty(t, cli.fsSnapshots)
+	assert.NoError(t, nas.DeleteGroupSnapshot(ctx, members))
+}
@@ -0,0 +1,260 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"huawei-csi-driver/storage/oceanstor/client"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	// QuotaPercentMin is the lowest softQuota or hardQuota in percent of the volume capacity
+	QuotaPercentMin = 1
+	// QuotaPercentMax is the highest softQuota or hardQuota in percent of the volume capacity
+	QuotaPercentMax = 100
+	// GracePeriodMax is the longest gracePeriod in days the soft quota is allowed to be exceeded
+	GracePeriodMax = 4294967294
+)
+
+// Quota is the SmartQuota of a filesystem or dtree volume, the fields which are 0 are not set
+type Quota struct {
+	// SoftLimit is the capacity in bytes above which the storage raises an alarm, and the writes fail
+	// once the grace period expires
+	SoftLimit int64
+	// HardLimit is the capacity in bytes above which the writes fail
+	HardLimit int64
+	// GracePeriod is how many days the soft limit is allowed to be exceeded
+	GracePeriod int64
+}
+
+// NewQuota returns the quota of a volume of the size in bytes by the softQuota and hardQuota percentages
+// of the size and the gracePeriod in days, nil if none of them is set
+func NewQuota(size int64, softQuota, hardQuota, gracePeriod string) (*Quota, error) {
+	if softQuota == "" && hardQuota == "" && gracePeriod == "" {
+		return nil, nil
+	}
+
+	parsePercent := func(key, value string) (int64, error) {
+		if value == "" {
+			return 0, nil
+		}
+		percent, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || percent < QuotaPercentMin || percent > QuotaPercentMax {
+			return 0, fmt.Errorf("%s [%s] must be an integer from %d to %d", key, value,
+				QuotaPercentMin, QuotaPercentMax)
+		}
+		return percent, nil
+	}
+
+	soft, err := parsePercent("softQuota", softQuota)
+	if err != nil {
+		return nil, err
+	}
+	hard, err := parsePercent("hardQuota", hardQuota)
+	if err != nil {
+		return nil, err
+	}
+	if soft > 0 && hard > 0 && soft >= hard {
+		return nil, fmt.Errorf("softQuota [%s] must be lower than hardQuota [%s]", softQuota, hardQuota)
+	}
+
+	quota := &Quota{
+		SoftLimit: size * soft / 100,
+		HardLimit: size * hard / 100,
+	}
+	if gracePeriod != "" {
+		if soft == 0 {
+			return nil, fmt.Errorf("gracePeriod [%s] is only for the volumes setting softQuota", gracePeriod)
+		}
+		quota.GracePeriod, err = strconv.ParseInt(gracePeriod, 10, 64)
+		if err != nil || quota.GracePeriod < 1 || quota.GracePeriod > GracePeriodMax {
+			return nil, fmt.Errorf("gracePeriod [%s] must be an integer from 1 to %d", gracePeriod, GracePeriodMax)
+		}
+	}
+	return quota, nil
+}
+
+// limits returns the fields of the quota on storage which are set
+func (q *Quota) limits() map[string]interface{} {
+	limits := map[string]interface{}{}
+	if q.SoftLimit > 0 {
+		limits["SPACESOFTQUOTA"] = q.SoftLimit
+	}
+	if q.HardLimit > 0 {
+		limits["SPACEHARDQUOTA"] = q.HardLimit
+	}
+	if q.GracePeriod > 0 {
+		limits["SOFTGRACETIME"] = q.GracePeriod
+	}
+	return limits
+}
+
+// parseQuota converts the softquota, hardquota and graceperiod parameters to the quota of the volume
+func parseQuota(ctx context.Context, params map[string]interface{}) error {
+	softQuota, _ := params["softquota"].(string)
+	hardQuota, _ := params["hardquota"].(string)
+	gracePeriod, _ := params["graceperiod"].(string)
+	quota, err := NewQuota(utils.CapacityFromSectors(params["capacity"].(int64)).Bytes(),
+		softQuota, hardQuota, gracePeriod)
+	if err != nil {
+		return utils.Errorln(ctx, err.Error())
+	}
+
+	if quota != nil {
+		params["quota"] = quota
+	}
+	return nil
+}
+
+// setQuota updates the limits of the quota of the filesystem or dtree, or creates the quota if it does
+// not exist, whose ID is returned to be deleted on failure
+func (p *NAS) setQuota(ctx context.Context, parentType int, parentID string,
+	limits map[string]interface{}) (string, error) {
+	quota, err := p.cli.GetQuota(ctx, parentType, parentID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get quota of %s error: %v", parentID, err)
+		return "", err
+	}
+
+	if quota != nil {
+		quotaID, err := utils.GetStringField(quota, "ID")
+		if err != nil {
+			return "", utils.Errorf(ctx, "Get ID of quota of %s error: %v", parentID, err)
+		}
+		return "", p.cli.UpdateQuota(ctx, quotaID, limits)
+	}
+
+	quota, err = p.cli.CreateQuota(ctx, parentType, parentID, limits)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Create quota of %s error: %v", parentID, err)
+		return "", err
+	}
+
+	quotaID, err := utils.GetStringField(quota, "ID")
+	if err != nil {
+		return "", utils.Errorf(ctx, "Get ID of quota of %s error: %v", parentID, err)
+	}
+	return quotaID, nil
+}
+
+// getQuotaUsage returns the hard limit of the quota of the filesystem or dtree and its used capacity in
+// bytes, the limit is 0 if it is not set
+func (p *NAS) getQuotaUsage(ctx context.Context, parentType int, parentID string) (int64, int64, error) {
+	quota, err := p.cli.GetQuota(ctx, parentType, parentID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get quota of %s error: %v", parentID, err)
+		return 0, 0, err
+	}
+	if quota == nil {
+		return 0, 0, nil
+	}
+
+	// an unlimited quota is reported as the max uint64, which is not parsed as int64
+	limit, err := strconv.ParseInt(fmt.Sprint(quota["SPACEHARDQUOTA"]), 10, 64)
+	if err != nil || limit <= 0 {
+		return 0, 0, nil
+	}
+	used, _ := strconv.ParseInt(fmt.Sprint(quota["SPACEUSED"]), 10, 64)
+	return limit, used, nil
+}
+
+func (p *NAS) createLocalQuota(ctx context.Context,
+	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+	quota := params["quota"].(*Quota)
+	quotaID, err := p.setQuota(ctx, client.ParentTypeFS, taskResult["localFSID"].(string), quota.limits())
+	if err != nil {
+		return nil, err
+	}
+
+	if quotaID == "" {
+		return nil, nil
+	}
+	return map[string]interface{}{
+		"localQuotaID": quotaID,
+	}, nil
+}
+
+func (p *NAS) revertLocalQuota(ctx context.Context, taskResult map[string]interface{}) error {
+	quotaID, exist := taskResult["localQuotaID"].(string)
+	if !exist {
+		return nil
+	}
+	return p.cli.DeleteQuota(ctx, quotaID)
+}
+
+// UpdateQuota sets the quota of the filesystem, which is recomputed when the filesystem is expanded
+func (p *NAS) UpdateQuota(ctx context.Context, name string, quota *Quota) error {
+	fsID, err := p.getFSID(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.setQuota(ctx, client.ParentTypeFS, fsID, quota.limits())
+	return err
+}
+
+// GetQuotaUsage returns the hard limit of the quota of the filesystem and its used capacity in bytes
+func (p *NAS) GetQuotaUsage(ctx context.Context, name string) (int64, int64, error) {
+	fsID, err := p.getFSID(ctx, name)
+	if err != nil {
+		return 0, 0, err
+	}
+	return p.getQuotaUsage(ctx, client.ParentTypeFS, fsID)
+}
+
+func (p *NAS) getFSID(ctx context.Context, name string) (string, error) {
+	fsName := utils.GetFileSystemName(name)
+	fs, err := p.cli.GetFileSystemByName(ctx, fsName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get filesystem %s error: %v", fsName, err)
+		return "", err
+	}
+	if fs == nil {
+		return "", utils.KindErrorf(ctx, utils.ErrNotFound, "Filesystem %s does not exist", fsName)
+	}
+
+	fsID, err := utils.GetStringField(fs, "ID")
+	if err != nil {
+		return "", utils.Errorf(ctx, "Get ID of filesystem %s error: %v", fsName, err)
+	}
+	return fsID, nil
+}
+
+// UpdateQuota sets the quota of the dtree, whose hard limit is left to its capacity unless it is set
+func (p *DTree) UpdateQuota(ctx context.Context, name string, quota *Quota) error {
+	dTreeID, err := p.getDTreeID(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.setQuota(ctx, client.ParentTypeDTree, dTreeID, quota.limits())
+	return err
+}
+
+// GetQuotaUsage returns the hard limit of the quota of the dtree and its used capacity in bytes
+func (p *DTree) GetQuotaUsage(ctx context.Context, name string) (int64, int64, error) {
+	dTreeID, err := p.getDTreeID(ctx, name)
+	if err != nil {
+		return 0, 0, err
+	}
+	return p.getQuotaUsage(ctx, client.ParentTypeDTree, dTreeID)
+}
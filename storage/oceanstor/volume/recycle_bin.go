@@ -0,0 +1,301 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+	"huawei-csi-driver/utils/taskflow"
+)
+
+const (
+	// RecycledNamePrefix is the name prefix of the LUNs and filesystems kept in the recycle bin, the
+	// filesystems have "_" in place of "-"
+	RecycledNamePrefix = "deleted-"
+
+	// lunAssociateObjType is the type of the LUNs associated to the lungroups
+	lunAssociateObjType = 11
+	// recycleBinPageSize is the number of recycled objects listed at a time
+	recycleBinPageSize int64 = 100
+)
+
+// getRecycledName returns the name of the object in the recycle bin. It records when the object is
+// recycled, and the object ID keeps it unique and within the 31 characters of the LUN names.
+func getRecycledName(objectID string, recycledAt time.Time) string {
+	return fmt.Sprintf("%s%d-%s", RecycledNamePrefix, recycledAt.Unix(), objectID)
+}
+
+// getRecycledTime returns when the object of the name was recycled, false if the name isn't a recycled one
+func getRecycledTime(name string) (time.Time, bool) {
+	fields := strings.Split(strings.Replace(name, "_", "-", -1), "-")
+	if len(fields) != 3 || fields[0]+"-" != RecycledNamePrefix || fields[2] == "" {
+		return time.Time{}, false
+	}
+
+	seconds, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+// getExpiredRecycledNames returns the names of the recycled objects kept longer than the retention
+func getExpiredRecycledNames(objects []interface{}, retention time.Duration, now time.Time) []string {
+	var names []string
+	for _, i := range objects {
+		object, ok := i.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, _ := object["NAME"].(string)
+		recycledAt, ok := getRecycledTime(name)
+		if ok && now.Sub(recycledAt) >= retention {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// listRecycled returns all the objects named with the recycled prefix by the list function
+func listRecycled(ctx context.Context, prefix string,
+	list func(context.Context, string, int64, int64) ([]interface{}, error)) ([]interface{}, error) {
+	var objects []interface{}
+	for start := int64(0); ; start += recycleBinPageSize {
+		page, err := list(ctx, prefix, start, start+recycleBinPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, page...)
+		if int64(len(page)) < recycleBinPageSize {
+			return objects, nil
+		}
+	}
+}
+
+// Recycle keeps the LUN in the recycle bin rather than destroying its data: the LUN is unmapped from
+// the hosts and renamed with the recycled prefix, its original name is kept in the description. The
+// LUNs of HyperMetro and replication pairs are deleted, as a pair can't be kept on one side only.
+func (p *SAN) Recycle(ctx context.Context, name string) error {
+	lunName := utils.GetLunName(name)
+	lun, err := p.cli.GetLunByName(ctx, lunName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun by name %s error: %v", lunName, err)
+		return err
+	}
+	if lun == nil {
+		log.AddContext(ctx).Infof("Lun %s to recycle does not exist", lunName)
+		return nil
+	}
+
+	rssStr, err := utils.GetStringField(lun, "HASRSSOBJECT")
+	if err != nil {
+		return utils.Errorf(ctx, "Get rss object of lun %s error: %v", lunName, err)
+	}
+	lunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of lun %s error: %v", lunName, err)
+	}
+
+	var rss map[string]string
+	_ = json.Unmarshal([]byte(rssStr), &rss)
+
+	if rss["HyperMetro"] == "TRUE" || rss["RemoteReplication"] == "TRUE" {
+		log.AddContext(ctx).Warningf("Lun %s is in HyperMetro or replication pairs, delete it instead "+
+			"of recycling", lunName)
+		return p.Delete(ctx, name)
+	}
+
+	taskflow := taskflow.NewTaskFlow(ctx, "Recycle-LUN-Volume")
+
+	if rss["LunCopy"] == "TRUE" {
+		taskflow.AddTask("Delete-Local-LunCopy", p.deleteLocalLunCopy, nil)
+	}
+
+	if rss["HyperCopy"] == "TRUE" {
+		taskflow.AddTask("Delete-Local-HyperCopy", p.deleteLocalHyperCopy, nil)
+	}
+
+	taskflow.AddTask("Recycle-Local-LUN", p.recycleLocalLun, nil)
+
+	params := map[string]interface{}{
+		"lun":     lun,
+		"lunID":   lunID,
+		"lunName": lunName,
+	}
+
+	_, err = taskflow.Run(params)
+	return err
+}
+
+func (p *SAN) recycleLocalLun(ctx context.Context,
+	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+	lunID := params["lunID"].(string)
+	lunName := params["lunName"].(string)
+
+	lunGroups, err := p.cli.QueryAssociateLunGroup(ctx, lunAssociateObjType, lunID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Query associated lun groups of lun %s error: %v", lunID, err)
+		return nil, err
+	}
+
+	for _, i := range lunGroups {
+		group, err := utils.ToObject(i)
+		if err != nil {
+			return nil, err
+		}
+		groupID, err := utils.GetStringField(group, "ID")
+		if err != nil {
+			return nil, err
+		}
+
+		err = p.cli.RemoveLunFromGroup(ctx, lunID, groupID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Remove lun %s from group %s error: %v", lunID, groupID, err)
+			return nil, err
+		}
+	}
+
+	recycledName := getRecycledName(lunID, time.Now())
+	err = p.cli.UpdateLun(ctx, lunID, map[string]interface{}{
+		"NAME":        recycledName,
+		"DESCRIPTION": fmt.Sprintf("Recycled from %s", lunName),
+	})
+	if err != nil {
+		log.AddContext(ctx).Errorf("Rename lun %s to %s error: %v", lunName, recycledName, err)
+		return nil, err
+	}
+
+	log.AddContext(ctx).Infof("Lun %s is recycled as %s", lunName, recycledName)
+	return nil, nil
+}
+
+// PurgeRecycled deletes the LUNs kept in the recycle bin longer than the retention. The LUNs failed to
+// delete, e.g. with snapshots, are left for the next purge.
+func (p *SAN) PurgeRecycled(ctx context.Context, retention time.Duration) error {
+	luns, err := listRecycled(ctx, RecycledNamePrefix, p.cli.GetLunsByNamePrefix)
+	if err != nil {
+		log.AddContext(ctx).Errorf("List recycled luns error: %v", err)
+		return err
+	}
+
+	names := getExpiredRecycledNames(luns, retention, time.Now())
+	return purgeRecycled(ctx, names, func(name string) error {
+		return p.deleteLun(ctx, name, p.cli)
+	})
+}
+
+// Recycle keeps the filesystem in the recycle bin rather than destroying its data: the shares of the
+// filesystem are deleted and it is renamed with the recycled prefix, its original name is kept in the
+// description. The filesystems of HyperMetro and replication pairs are deleted, as a pair can't be
+// kept on one side only.
+func (p *NAS) Recycle(ctx context.Context, name string) error {
+	fsName := utils.GetFileSystemName(name)
+	fs, err := p.cli.GetFileSystemByName(ctx, fsName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get filesystem %s error: %v", fsName, err)
+		return err
+	}
+	if fs == nil {
+		log.AddContext(ctx).Infof("Filesystem %s to recycle does not exist", fsName)
+		return nil
+	}
+
+	fsID, err := utils.GetStringField(fs, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of filesystem %s error: %v", fsName, err)
+	}
+	for _, pairField := range []string{"HYPERMETROPAIRIDS", "REMOTEREPLICATIONIDS"} {
+		var pairIDs []string
+		pairIDStr, _ := fs[pairField].(string)
+		_ = json.Unmarshal([]byte(pairIDStr), &pairIDs)
+		if len(pairIDs) > 0 {
+			log.AddContext(ctx).Warningf("Filesystem %s is in pairs %v, delete it instead of recycling",
+				fsName, pairIDs)
+			return p.Delete(ctx, name)
+		}
+	}
+
+	// the snapshots are named after the filesystem, which would be lost with the renaming
+	fsSnapshotNum, err := p.cli.GetFSSnapshotCountByParentId(ctx, fsID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Failed to get the snapshot count of filesystem %s error: %v", fsID, err)
+		return err
+	}
+	if fsSnapshotNum > 0 {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition, "There are %d snapshots exist in "+
+			"filesystem %s. Please delete the snapshots firstly", fsSnapshotNum, fsName)
+	}
+
+	vStoreID, _ := fs["vstoreId"].(string)
+	err = p.deleteShare(ctx, name, vStoreID, p.cli)
+	if err != nil {
+		return err
+	}
+
+	recycledName := utils.GetFileSystemName(getRecycledName(fsID, time.Now()))
+	err = p.cli.UpdateFileSystem(ctx, fsID, map[string]interface{}{
+		"NAME":        recycledName,
+		"DESCRIPTION": fmt.Sprintf("Recycled from %s", fsName),
+	})
+	if err != nil {
+		log.AddContext(ctx).Errorf("Rename filesystem %s to %s error: %v", fsName, recycledName, err)
+		return err
+	}
+
+	log.AddContext(ctx).Infof("Filesystem %s is recycled as %s", fsName, recycledName)
+	return nil
+}
+
+// PurgeRecycled deletes the filesystems kept in the recycle bin longer than the retention. The
+// filesystems failed to delete are left for the next purge.
+func (p *NAS) PurgeRecycled(ctx context.Context, retention time.Duration) error {
+	prefix := utils.GetFileSystemName(RecycledNamePrefix)
+	filesystems, err := listRecycled(ctx, prefix, p.cli.GetFileSystemsByNamePrefix)
+	if err != nil {
+		log.AddContext(ctx).Errorf("List recycled filesystems error: %v", err)
+		return err
+	}
+
+	names := getExpiredRecycledNames(filesystems, retention, time.Now())
+	return purgeRecycled(ctx, names, func(name string) error {
+		return p.deleteFS(ctx, name, p.cli)
+	})
+}
+
+// purgeRecycled deletes the recycled objects by name, and returns the error of the last one failed
+func purgeRecycled(ctx context.Context, names []string, deleteFunc func(string) error) error {
+	var lastErr error
+	for _, name := range names {
+		err := deleteFunc(name)
+		if err != nil {
+			log.AddContext(ctx).Warningf("Purge recycled object %s error: %v", name, err)
+			lastErr = err
+			continue
+		}
+		log.AddContext(ctx).Infof("Recycled object %s is purged", name)
+	}
+	return lastErr
+}
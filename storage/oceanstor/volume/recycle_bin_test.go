@@ -0,0 +1,144 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/utils"
+)
+
+// fakeRecycleClient keeps the lungroups of the LUNs on a fake storage
+type fakeRecycleClient struct {
+	*fakeClient
+	lunGroups map[string][]string
+}
+
+func (c *fakeRecycleClient) QueryAssociateLunGroup(_ context.Context, _ int, lunID string) (
+	[]interface{}, error) {
+	var groups []interface{}
+	for _, groupID := range c.lunGroups[lunID] {
+		groups = append(groups, map[string]interface{}{"ID": groupID})
+	}
+	return groups, nil
+}
+
+func (c *fakeRecycleClient) RemoveLunFromGroup(_ context.Context, lunID, groupID string) error {
+	var groups []string
+	for _, id := range c.lunGroups[lunID] {
+		if id != groupID {
+			groups = append(groups, id)
+		}
+	}
+	c.lunGroups[lunID] = groups
+	return nil
+}
+
+func (c *fakeRecycleClient) GetLunsByNamePrefix(_ context.Context, prefix string, start, end int64) (
+	[]interface{}, error) {
+	var luns []interface{}
+	for _, lun := range c.luns {
+		if name, _ := lun["NAME"].(string); strings.HasPrefix(name, prefix) {
+			luns = append(luns, lun)
+		}
+	}
+	if start >= int64(len(luns)) {
+		return nil, nil
+	}
+	if end > int64(len(luns)) {
+		end = int64(len(luns))
+	}
+	return luns[start:end], nil
+}
+
+func TestGetRecycledTime(t *testing.T) {
+	recycledAt := time.Unix(1666000000, 0)
+	cases := []struct {
+		name       string
+		objectName string
+		recycled   bool
+	}{
+		{"LUN", getRecycledName("12", recycledAt), true},
+		{"Filesystem", utils.GetFileSystemName(getRecycledName("12", recycledAt)), true},
+		{"Volume", "pvc-12", false},
+		{"Invalid time", "deleted-yesterday-12", false},
+		{"No object ID", "deleted-1666000000-", false},
+		{"Extra field", "deleted-1666000000-12-1", false},
+	}
+
+	for _, c := range cases {
+		got, ok := getRecycledTime(c.objectName)
+		assert.Equal(t, c.recycled, ok, c.name)
+		if c.recycled {
+			assert.Equal(t, recycledAt, got, c.name)
+		}
+	}
+}
+
+func TestGetExpiredRecycledNames(t *testing.T) {
+	now := time.Unix(1666000000, 0)
+	objects := []interface{}{
+		map[string]interface{}{"NAME": getRecycledName("1", now.Add(-48*time.Hour))},
+		map[string]interface{}{"NAME": getRecycledName("2", now.Add(-24*time.Hour))},
+		map[string]interface{}{"NAME": getRecycledName("3", now.Add(-time.Hour))},
+		map[string]interface{}{"NAME": utils.GetFileSystemName(getRecycledName("4", now.Add(-48*time.Hour)))},
+		map[string]interface{}{"NAME": "pvc-5"},
+		"invalid",
+	}
+
+	names := getExpiredRecycledNames(objects, 24*time.Hour, now)
+	assert.Equal(t, []string{getRecycledName("1", now.Add(-48*time.Hour)),
+		getRecycledName("2", now.Add(-24*time.Hour)),
+		utils.GetFileSystemName(getRecycledName("4", now.Add(-48*time.Hour)))}, names)
+}
+
+func TestRecycleLun(t *testing.T) {
+	cli := &fakeRecycleClient{fakeClient: newFakeClient(), lunGroups: map[string][]string{"1": {"10", "11"}}}
+	cli.luns["1"] = map[string]interface{}{"ID": "1", "NAME": "pvc-1", "HASRSSOBJECT": "{}"}
+	san := NewSAN(cli, nil, nil, "DoradoV6")
+
+	assert.NoError(t, san.Recycle(ctx, "pvc-1"))
+	assert.Empty(t, cli.lunGroups["1"])
+	assert.Empty(t, cli.deletedLuns)
+
+	updated := cli.updatedLuns["1"]
+	name, _ := updated["NAME"].(string)
+	_, recycled := getRecycledTime(name)
+	assert.True(t, recycled)
+	assert.True(t, strings.HasSuffix(name, "-1"))
+	assert.Equal(t, "Recycled from pvc-1", updated["DESCRIPTION"])
+
+	assert.NoError(t, san.Recycle(ctx, "pvc-2"))
+	assert.Len(t, cli.updatedLuns, 1)
+}
+
+func TestPurgeRecycledLuns(t *testing.T) {
+	now := time.Now()
+	cli := &fakeRecycleClient{fakeClient: newFakeClient()}
+	cli.luns["1"] = map[string]interface{}{"ID": "1", "NAME": getRecycledName("1", now.Add(-48*time.Hour))}
+	cli.luns["2"] = map[string]interface{}{"ID": "2", "NAME": getRecycledName("2", now.Add(-time.Hour))}
+	cli.luns["3"] = map[string]interface{}{"ID": "3", "NAME": "pvc-3"}
+	san := NewSAN(cli, nil, nil, "DoradoV6")
+
+	assert.NoError(t, san.PurgeRecycled(ctx, 24*time.Hour))
+	assert.Equal(t, []string{"1"}, cli.deletedLuns)
+}
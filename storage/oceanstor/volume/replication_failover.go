@@ -0,0 +1,98 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"encoding/json"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// Failover lets the replica of the filesystem on the replication storage be written, for the workloads to
+// continue there when the primary storage fails. The pairs still running are split, so the replica stops
+// receiving the writes of the primary filesystem until the pairs are synchronized again on storage.
+func (p *NAS) Failover(ctx context.Context, name string) error {
+	if p.replicaRemoteCli == nil {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition, "remote client for replication is nil")
+	}
+
+	fsName := utils.GetFileSystemName(name)
+	fs, err := p.replicaRemoteCli.GetFileSystemByName(ctx, fsName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get replica filesystem %s error: %v", fsName, err)
+		return err
+	}
+	if fs == nil {
+		return utils.KindErrorf(ctx, utils.ErrNotFound,
+			"Replica filesystem %s does not exist on the replication storage", fsName)
+	}
+
+	var replicationIDs []string
+	replicationIDStr, _ := fs["REMOTEREPLICATIONIDS"].(string)
+	_ = json.Unmarshal([]byte(replicationIDStr), &replicationIDs)
+	if len(replicationIDs) == 0 {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"Replica filesystem %s is not in replication pairs", fsName)
+	}
+
+	for _, pairID := range replicationIDs {
+		err = p.failoverReplicationPair(ctx, pairID)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.AddContext(ctx).Infof("Replica filesystem %s of replication pairs %v is failed over", fsName,
+		replicationIDs)
+	return nil
+}
+
+// failoverReplicationPair splits the replication pair on the replication storage and cancels the write
+// lock of its secondary filesystem. The pairs whose replica is already primary are left as they are.
+func (p *NAS) failoverReplicationPair(ctx context.Context, pairID string) error {
+	pair, err := p.replicaRemoteCli.GetReplicationPairByID(ctx, pairID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get replication pair %s error: %v", pairID, err)
+		return err
+	}
+
+	if pair["ISPRIMARY"] == "true" {
+		log.AddContext(ctx).Infof("The replica of replication pair %s is already primary", pairID)
+		return nil
+	}
+
+	runningStatus, _ := pair["RUNNINGSTATUS"].(string)
+	if runningStatus == replicationPairRunningStatusNormal || runningStatus == replicationPairRunningStatusSync {
+		err = p.replicaRemoteCli.SplitReplicationPair(ctx, pairID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Split replication pair %s error: %v", pairID, err)
+			return err
+		}
+	}
+
+	if pair["SECRESACCESS"] != replicationSecResAccessReadWrite {
+		err = p.replicaRemoteCli.CancelReplicationSecondaryWriteLock(ctx, pairID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Cancel secondary write lock of replication pair %s error: %v", pairID, err)
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"strconv"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// replicationGroupType is the object type of the replication consistency groups
+const replicationGroupType = 57702
+
+// joinReplicationGroup puts the new replication pair in the replication consistency group of the name,
+// which is created for the first pair, and synchronizes the group instead of the pair
+func (p *Base) joinReplicationGroup(ctx context.Context, groupName, pairID string, model int) error {
+	group, err := p.cli.GetReplicationGroupByName(ctx, groupName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get replication consistency group %s error: %v", groupName, err)
+		return err
+	}
+
+	created := false
+	if group == nil {
+		group, err = p.cli.CreateReplicationGroup(ctx, groupName, model)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Create replication consistency group %s error: %v", groupName, err)
+			return err
+		}
+		created = true
+	} else if groupModel, _ := group["REPLICATIONMODEL"].(string); groupModel != "" &&
+		groupModel != strconv.Itoa(model) {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"The replication model of consistency group %s is not the one of the volume", groupName)
+	}
+
+	groupID, err := utils.GetStringField(group, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of replication consistency group %s error: %v", groupName, err)
+	}
+
+	err = p.cli.AddPairToReplicationGroup(ctx, pairID, groupID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Add replication pair %s to group %s error: %v", pairID, groupName, err)
+		if created {
+			p.cli.DeleteReplicationGroup(ctx, groupID)
+		}
+		return err
+	}
+
+	err = p.cli.SyncReplicationGroup(ctx, groupID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Sync replication consistency group %s error: %v", groupName, err)
+		p.cli.RemovePairFromReplicationGroup(ctx, pairID, groupID)
+		if created {
+			p.cli.DeleteReplicationGroup(ctx, groupID)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// leaveReplicationGroup removes the replication pair to delete from its replication consistency group,
+// which is deleted once it has no pairs left
+func (p *Base) leaveReplicationGroup(ctx context.Context, pair map[string]interface{}) error {
+	if isInGroup, _ := pair["ISINCG"].(string); isInGroup != "true" {
+		return nil
+	}
+
+	pairID, err := utils.GetStringField(pair, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of replication pair error: %v", err)
+	}
+	groupID, err := utils.GetStringField(pair, "CGID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get consistency group of replication pair %s error: %v", pairID, err)
+	}
+
+	err = p.cli.RemovePairFromReplicationGroup(ctx, pairID, groupID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Remove replication pair %s from group %s error: %v", pairID, groupID, err)
+		return err
+	}
+
+	pairs, err := p.cli.GetReplicationPairByResID(ctx, groupID, replicationGroupType)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get replication pairs of group %s error: %v", groupID, err)
+		return err
+	}
+	if len(pairs) != 0 {
+		return nil
+	}
+
+	log.AddContext(ctx).Infof("Delete the empty replication consistency group %s", groupID)
+	return p.cli.DeleteReplicationGroup(ctx, groupID)
+}
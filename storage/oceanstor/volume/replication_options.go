@@ -0,0 +1,124 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	// ReplicationModelSync is the replicationModel of synchronous replication
+	ReplicationModelSync = "sync"
+	// ReplicationModelAsync is the replicationModel of asynchronous replication, which is the default
+	ReplicationModelAsync = "async"
+
+	// ReplicationLinkLatencyLow is the replicationLinkLatency of the links to the replication array whose
+	// latency allows synchronous replication, which is the default
+	ReplicationLinkLatencyLow = "low"
+	// ReplicationLinkLatencyHigh is the replicationLinkLatency of the links to the replication array only
+	// allowing asynchronous replication, as every write waits for the remote array on synchronous ones
+	ReplicationLinkLatencyHigh = "high"
+)
+
+// ReplicationOptionKeys are the StorageClass parameters of the replication pairs
+var ReplicationOptionKeys = []string{"replicationModel", "replicationSyncType", "replicationSpeed",
+	"replicationBandwidth"}
+
+// replicationModels are the values of the replicationModel parameter, in the values of REPLICATIONMODEL
+var replicationModels = map[string]int{
+	ReplicationModelSync:  1,
+	ReplicationModelAsync: 2,
+}
+
+// replicationSyncTypes are the values of the replicationSyncType parameter of the asynchronous
+// replication, in the values of SYNCHRONIZETYPE. The timed ones wait replicationSyncPeriod seconds after
+// the last synchronization begins or ends.
+var replicationSyncTypes = map[string]int{
+	"manual":          1,
+	"timedAfterBegin": 2,
+	"timedAfterEnd":   3,
+}
+
+// CheckReplicationOption checks the value of a replication parameter
+func CheckReplicationOption(key, value string) error {
+	var valid bool
+	switch key {
+	case "replicationModel":
+		_, valid = replicationModels[value]
+	case "replicationSyncType":
+		_, valid = replicationSyncTypes[value]
+	case "replicationSpeed":
+		_, valid = pairSpeeds[value]
+	case "replicationBandwidth":
+		bandwidth, err := strconv.Atoi(value)
+		valid = err == nil && bandwidth > 0
+	default:
+		return fmt.Errorf("unknown replication parameter %s", key)
+	}
+
+	if !valid {
+		return fmt.Errorf("%s [%s] is invalid, the model is sync or async, the sync type is manual, "+
+			"timedAfterBegin or timedAfterEnd, the speed is low, medium, high or highest, and the bandwidth "+
+			"is a positive number of MB/s", key, value)
+	}
+	return nil
+}
+
+// isSyncReplication returns whether the replication of the volume is synchronous
+func isSyncReplication(params map[string]interface{}) bool {
+	return params["replicationModel"] == ReplicationModelSync
+}
+
+// isSyncReplicationPair returns whether the replication pair on storage is synchronous
+func isSyncReplicationPair(pair map[string]interface{}) bool {
+	return pair["REPLICATIONMODEL"] == strconv.Itoa(replicationModels[ReplicationModelSync])
+}
+
+// setReplicationPairOptions sets the model, synchronization, speed and bandwidth of the replication pair
+// to create. It is asynchronous and synchronized at the highest speed replicationSyncPeriod seconds after
+// the last synchronization begins unless they are set.
+func setReplicationPairOptions(params, data map[string]interface{}) {
+	data["REPLICATIONMODEL"] = replicationModels[ReplicationModelAsync]
+	if isSyncReplication(params) {
+		data["REPLICATIONMODEL"] = replicationModels[ReplicationModelSync]
+	} else {
+		syncType, _ := params["replicationSyncType"].(string)
+		if value, exist := replicationSyncTypes[syncType]; exist {
+			data["SYNCHRONIZETYPE"] = value
+		} else {
+			data["SYNCHRONIZETYPE"] = replicationSyncTypes["timedAfterBegin"]
+		}
+
+		replicationSyncPeriod, exist := params["replicationSyncPeriod"]
+		if exist && data["SYNCHRONIZETYPE"] != replicationSyncTypes["manual"] {
+			data["TIMINGVAL"] = replicationSyncPeriod
+		}
+	}
+
+	speed, _ := params["replicationSpeed"].(string)
+	if value, exist := pairSpeeds[speed]; exist {
+		data["SPEED"] = value
+	} else {
+		data["SPEED"] = pairSpeeds["highest"]
+	}
+
+	bandwidth, _ := params["replicationBandwidth"].(string)
+	if value, err := strconv.Atoi(bandwidth); err == nil {
+		data["BANDWIDTH"] = value
+	}
+}
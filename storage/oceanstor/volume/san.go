@@ -21,7 +21,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strconv"
 	"time"
 
 	"huawei-csi-driver/storage/oceanstor/client"
@@ -68,7 +67,17 @@ func (p *SAN) preCreate(ctx context.Context, params map[string]interface{}) erro
 		return err
 	}
 
-	return nil
+	err = p.setSmartTierPolicy(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	err = p.setCapacityAlarmThreshold(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	return p.setSmartCachePartitionID(ctx, p.cli, params)
 }
 
 func (p *SAN) Create(ctx context.Context, params map[string]interface{}) (utils.Volume, error) {
@@ -92,6 +101,12 @@ func (p *SAN) Create(ctx context.Context, params map[string]interface{}) (utils.
 	}
 
 	taskflow.AddTask("Create-Local-LUN", p.createLocalLun, p.revertLocalLun)
+	_, cloneExist := params["clonefrom"]
+	_, snapshotExist := params["fromSnapshot"]
+	if cloneExist || snapshotExist {
+		taskflow.AddTask("Extend-Local-Clone-LUN", p.extendLocalCloneLun, nil)
+	}
+	taskflow.AddTask("Add-Local-SmartCache", p.addLocalSmartCache, p.revertLocalSmartCache)
 	taskflow.AddTask("Create-Local-QoS", p.createLocalQoS, p.revertLocalQoS)
 
 	if replicationOK && replication {
@@ -126,7 +141,14 @@ func (p *SAN) Delete(ctx context.Context, name string) error {
 		return nil
 	}
 
-	rssStr := lun["HASRSSOBJECT"].(string)
+	rssStr, err := utils.GetStringField(lun, "HASRSSOBJECT")
+	if err != nil {
+		return utils.Errorf(ctx, "Get rss object of lun %s error: %v", lunName, err)
+	}
+	lunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of lun %s error: %v", lunName, err)
+	}
 
 	var rss map[string]string
 	json.Unmarshal([]byte(rssStr), &rss)
@@ -155,7 +177,7 @@ func (p *SAN) Delete(ctx context.Context, name string) error {
 
 	params := map[string]interface{}{
 		"lun":     lun,
-		"lunID":   lun["ID"].(string),
+		"lunID":   lunID,
 		"lunName": lunName,
 	}
 
@@ -163,6 +185,31 @@ func (p *SAN) Delete(ctx context.Context, name string) error {
 	return err
 }
 
+// UpdateQoS sets the QoS parameters of the LUN
+func (p *SAN) UpdateQoS(ctx context.Context, name string, qos map[string]int) error {
+	lunName := utils.GetLunName(name)
+	lun, err := p.cli.GetLunByName(ctx, lunName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun by name %s error: %v", lunName, err)
+		return err
+	} else if lun == nil {
+		return utils.KindErrorf(ctx, utils.ErrNotFound, "Lun %s to update QoS does not exist", lunName)
+	}
+
+	lunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of lun %s error: %v", lunName, err)
+	}
+
+	qosID, _ := lun["IOCLASSID"].(string)
+	err = p.updateQos(ctx, p.cli, lunID, "lun", "", qosID, qos)
+	if err != nil {
+		return utils.Errorf(ctx, "Update qos %v of lun %s error: %v", qos, lunName, err)
+	}
+
+	return nil
+}
+
 func (p *SAN) Expand(ctx context.Context, name string, newSize int64) (bool, error) {
 	lunName := utils.GetLunName(name)
 	lun, err := p.cli.GetLunByName(ctx, lunName)
@@ -170,21 +217,38 @@ func (p *SAN) Expand(ctx context.Context, name string, newSize int64) (bool, err
 		log.AddContext(ctx).Errorf("Get lun by name %s error: %v", lunName, err)
 		return false, err
 	} else if lun == nil {
-		msg := fmt.Sprintf("Lun %s to expand does not exist", lunName)
-		log.AddContext(ctx).Errorf(msg)
-		return false, errors.New(msg)
+		return false, utils.KindErrorf(ctx, utils.ErrNotFound, "Lun %s to expand does not exist", lunName)
 	}
 
 	isAttached := lun["EXPOSEDTOINITIATOR"] == "true"
-	curSize, _ := strconv.ParseInt(lun["CAPACITY"].(string), 10, 64)
-	if newSize <= curSize {
-		msg := fmt.Sprintf("Lun %s newSize %d must be greater than curSize %d", lunName, newSize, curSize)
+	lunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return false, utils.Errorf(ctx, "Get ID of lun %s error: %v", lunName, err)
+	}
+	parentName, err := utils.GetStringField(lun, "PARENTNAME")
+	if err != nil {
+		return false, utils.Errorf(ctx, "Get parent name of lun %s error: %v", lunName, err)
+	}
+	rssStr, err := utils.GetStringField(lun, "HASRSSOBJECT")
+	if err != nil {
+		return false, utils.Errorf(ctx, "Get rss object of lun %s error: %v", lunName, err)
+	}
+	capacity, err := utils.ParseSectors(lun, "CAPACITY")
+	if err != nil {
+		return false, utils.Errorf(ctx, "Get capacity of lun %s error: %v", lunName, err)
+	}
+
+	// A LUN already of the size is not expanded again, but the expansion of its remote LUNs
+	// and the synchronization of its pairs are still run, in case they failed last time
+	curSize := capacity.Sectors()
+	if newSize < curSize {
+		msg := fmt.Sprintf("Lun %s newSize %d must not be less than curSize %d", lunName, newSize, curSize)
 		log.AddContext(ctx).Errorln(msg)
 		return false, errors.New(msg)
 	}
 
 	var rss map[string]string
-	json.Unmarshal([]byte(lun["HASRSSOBJECT"].(string)), &rss)
+	json.Unmarshal([]byte(rssStr), &rss)
 
 	expandTask := taskflow.NewTaskFlow(ctx, "Expand-LUN-Volume")
 	expandTask.AddTask("Expand-PreCheck-Capacity", p.preExpandCheckCapacity, nil)
@@ -217,8 +281,8 @@ func (p *SAN) Expand(ctx context.Context, name string, newSize int64) (bool, err
 		"name":            name,
 		"size":            newSize,
 		"expandSize":      newSize - curSize,
-		"lunID":           lun["ID"].(string),
-		"localParentName": lun["PARENTNAME"].(string),
+		"lunID":           lunID,
+		"localParentName": parentName,
 	}
 	_, err = expandTask.Run(params)
 	return isAttached, err
@@ -233,6 +297,12 @@ func (p *SAN) createLocalLun(ctx context.Context,
 		log.AddContext(ctx).Errorf("Get LUN %s error: %v", lunName, err)
 		return nil, err
 	}
+	if lun != nil {
+		lun, err = p.deleteUncopiedCloneTarget(ctx, lun, params)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	if lun == nil {
 		params["parentid"] = params["poolID"].(string)
@@ -250,16 +320,30 @@ func (p *SAN) createLocalLun(ctx context.Context,
 			return nil, err
 		}
 	} else {
-		err := p.waitCloneFinish(ctx, lun, taskResult)
+		err := p.checkExistVolume(ctx, lunName, lun, params)
+		if err != nil {
+			return nil, err
+		}
+
+		err = p.resumeClone(ctx, lun, params)
 		if err != nil {
-			log.AddContext(ctx).Errorf("Wait clone finish for LUN %s error: %v", lunName, err)
+			log.AddContext(ctx).Errorf("Resume clone for LUN %s error: %v", lunName, err)
 			return nil, err
 		}
 	}
 
+	lunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of LUN %s error: %v", lunName, err)
+	}
+	lunWWN, err := utils.GetStringField(lun, "WWN")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get WWN of LUN %s error: %v", lunName, err)
+	}
+
 	return map[string]interface{}{
-		"localLunID": lun["ID"].(string),
-		"lunWWN":     lun["WWN"].(string),
+		"localLunID": lunID,
+		"lunWWN":     lunWWN,
 	}, nil
 }
 
@@ -271,16 +355,14 @@ func (p *SAN) clonePair(ctx context.Context, params map[string]interface{}) (map
 		return nil, err
 	}
 	if srcLun == nil {
-		msg := fmt.Sprintf("Clone src LUN %s does not exist", cloneFrom)
-		log.AddContext(ctx).Errorln(msg)
-		return nil, errors.New(msg)
+		return nil, utils.KindErrorf(ctx, utils.ErrNotFound, "Clone src LUN %s does not exist", cloneFrom)
 	}
 
-	srcLunCapacity, err := strconv.ParseInt(srcLun["CAPACITY"].(string), 10, 64)
+	srcLunCapacity, err := utils.ParseSectors(srcLun, "CAPACITY")
 	if err != nil {
 		return nil, err
 	}
-	cloneLunCapacity := params["capacity"].(int64)
+	cloneLunCapacity := utils.CapacityFromSectors(params["capacity"].(int64))
 	if cloneLunCapacity < srcLunCapacity {
 		msg := fmt.Sprintf("Clone LUN capacity must be >= src %s", cloneFrom)
 		log.AddContext(ctx).Errorln(msg)
@@ -292,23 +374,28 @@ func (p *SAN) clonePair(ctx context.Context, params map[string]interface{}) (map
 		return nil, err
 	}
 	if dstLun == nil {
-		copyParams := utils.CopyMap(params)
-		copyParams["capacity"] = srcLunCapacity
-
-		dstLun, err = p.cli.CreateLun(ctx, copyParams)
+		dstLun, err = p.createCloneTarget(ctx, params, srcLunCapacity)
 		if err != nil {
 			return nil, err
 		}
 	}
-	srcLunID := srcLun["ID"].(string)
-	dstLunID := dstLun["ID"].(string)
+	srcLunID, err := utils.GetStringField(srcLun, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of clone src LUN %s error: %v", cloneFrom, err)
+	}
+	dstLunID, err := utils.GetStringField(dstLun, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of clone dst LUN error: %v", err)
+	}
 
 	cloneSpeed := params["clonespeed"].(int)
 	err = p.createClonePair(ctx, clonePairRequest{srcLunID: srcLunID,
-		dstLunID:         dstLunID,
-		cloneLunCapacity: cloneLunCapacity,
-		srcLunCapacity:   srcLunCapacity,
-		cloneSpeed:       cloneSpeed})
+		dstLunID:          dstLunID,
+		cloneLunCapacity:  cloneLunCapacity,
+		srcLunCapacity:    srcLunCapacity,
+		cloneSpeed:        cloneSpeed,
+		dstLunDescription: getDescription(params),
+		asyncCopy:         isAsyncCopy(params)})
 	if err != nil {
 		log.AddContext(ctx).Errorf("Create clone pair, source lun ID %s, target lun ID %s error: %s",
 			srcLunID, dstLunID, err)
@@ -322,22 +409,20 @@ func (p *SAN) clonePair(ctx context.Context, params map[string]interface{}) (map
 func (p *SAN) fromSnapshotByClonePair(ctx context.Context,
 	params map[string]interface{}) (map[string]interface{}, error) {
 	srcSnapshotName := params["fromSnapshot"].(string)
-	srcSnapshot, err := p.cli.GetLunSnapshotByName(ctx, srcSnapshotName)
+	srcSnapshot, _, err := p.getSnapshotOrHyperCDP(ctx, srcSnapshotName)
 	if err != nil {
 		return nil, err
 	}
 	if srcSnapshot == nil {
-		msg := fmt.Sprintf("Clone snapshot %s does not exist", srcSnapshotName)
-		log.AddContext(ctx).Errorln(msg)
-		return nil, errors.New(msg)
+		return nil, utils.KindErrorf(ctx, utils.ErrNotFound, "Clone snapshot %s does not exist", srcSnapshotName)
 	}
 
-	srcSnapshotCapacity, err := strconv.ParseInt(srcSnapshot["USERCAPACITY"].(string), 10, 64)
+	srcSnapshotCapacity, err := utils.ParseSectors(srcSnapshot, "USERCAPACITY")
 	if err != nil {
 		return nil, err
 	}
 
-	cloneLunCapacity := params["capacity"].(int64)
+	cloneLunCapacity := utils.CapacityFromSectors(params["capacity"].(int64))
 	if cloneLunCapacity < srcSnapshotCapacity {
 		msg := fmt.Sprintf("Clone target LUN capacity must be >= src snapshot %s", srcSnapshotName)
 		log.AddContext(ctx).Errorln(msg)
@@ -349,23 +434,28 @@ func (p *SAN) fromSnapshotByClonePair(ctx context.Context,
 		return nil, err
 	}
 	if dstLun == nil {
-		copyParams := utils.CopyMap(params)
-		copyParams["capacity"] = srcSnapshotCapacity
-
-		dstLun, err = p.cli.CreateLun(ctx, copyParams)
+		dstLun, err = p.createCloneTarget(ctx, params, srcSnapshotCapacity)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	srcSnapshotID := srcSnapshot["ID"].(string)
-	dstLunID := dstLun["ID"].(string)
+	srcSnapshotID, err := utils.GetStringField(srcSnapshot, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of clone src snapshot %s error: %v", srcSnapshotName, err)
+	}
+	dstLunID, err := utils.GetStringField(dstLun, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of clone dst LUN error: %v", err)
+	}
 	cloneSpeed := params["clonespeed"].(int)
 	err = p.createClonePair(ctx, clonePairRequest{srcLunID: srcSnapshotID,
-		dstLunID:         dstLunID,
-		cloneLunCapacity: cloneLunCapacity,
-		srcLunCapacity:   srcSnapshotCapacity,
-		cloneSpeed:       cloneSpeed})
+		dstLunID:          dstLunID,
+		cloneLunCapacity:  cloneLunCapacity,
+		srcLunCapacity:    srcSnapshotCapacity,
+		cloneSpeed:        cloneSpeed,
+		dstLunDescription: getDescription(params),
+		asyncCopy:         isAsyncCopy(params)})
 	if err != nil {
 		log.AddContext(ctx).Errorf("Clone snapshot by clone pair, source snapshot ID %s,"+
 			" target lun ID %s error: %s", srcSnapshotID, dstLunID, err)
@@ -380,9 +470,13 @@ func (p *SAN) fromSnapshotByClonePair(ctx context.Context,
 type clonePairRequest struct {
 	srcLunID         string
 	dstLunID         string
-	cloneLunCapacity int64
-	srcLunCapacity   int64
+	cloneLunCapacity utils.Capacity
+	srcLunCapacity   utils.Capacity
 	cloneSpeed       int
+	// dstLunDescription is restored on the target LUN once the clone pair is started
+	dstLunDescription string
+	// asyncCopy returns once the clone pair is started, the target LUN is usable while the data is copied
+	asyncCopy bool
 }
 
 func (p *SAN) createClonePair(ctx context.Context,
@@ -395,9 +489,13 @@ func (p *SAN) createClonePair(ctx context.Context,
 		return err
 	}
 
-	clonePairID := clonePair["ID"].(string)
+	clonePairID, err := utils.GetStringField(clonePair, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of clone pair from %s to %s error: %v", clonePairReq.srcLunID,
+			clonePairReq.dstLunID, err)
+	}
 	if clonePairReq.srcLunCapacity < clonePairReq.cloneLunCapacity {
-		err = p.cli.ExtendLun(ctx, clonePairReq.dstLunID, clonePairReq.cloneLunCapacity)
+		err = p.cli.ExtendLun(ctx, clonePairReq.dstLunID, clonePairReq.cloneLunCapacity.Sectors())
 		if err != nil {
 			log.AddContext(ctx).Errorf("Extend clone lun %s error: %v", clonePairReq.dstLunID, err)
 			p.cli.DeleteClonePair(ctx, clonePairID)
@@ -412,6 +510,16 @@ func (p *SAN) createClonePair(ctx context.Context,
 		return err
 	}
 
+	err = p.markCloneStarted(ctx, clonePairReq.dstLunID, clonePairReq.dstLunDescription)
+	if err != nil {
+		return err
+	}
+
+	if clonePairReq.asyncCopy {
+		log.AddContext(ctx).Infof("ClonePair %s is started, the data is copied in the background", clonePairID)
+		return nil
+	}
+
 	err = p.waitClonePairFinish(ctx, clonePairID)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Wait ClonePair %s finish error: %v", clonePairID, err)
@@ -428,17 +536,15 @@ func (p *SAN) lunCopy(ctx context.Context, params map[string]interface{}) (map[s
 		log.AddContext(ctx).Errorf("Get clone src LUN %s error: %v", clonefrom, err)
 		return nil, err
 	} else if srcLun == nil {
-		msg := fmt.Sprintf("Clone src LUN %s does not exist", clonefrom)
-		log.AddContext(ctx).Errorln(msg)
-		return nil, errors.New(msg)
+		return nil, utils.KindErrorf(ctx, utils.ErrNotFound, "Clone src LUN %s does not exist", clonefrom)
 	}
 
-	srcLunCapacity, err := strconv.ParseInt(srcLun["CAPACITY"].(string), 10, 64)
+	srcLunCapacity, err := utils.ParseSectors(srcLun, "CAPACITY")
 	if err != nil {
 		return nil, err
 	}
 
-	cloneLunCapacity := params["capacity"].(int64)
+	cloneLunCapacity := utils.CapacityFromSectors(params["capacity"].(int64))
 	if cloneLunCapacity < srcLunCapacity {
 		msg := fmt.Sprintf("Clone LUN capacity must be >= src %s", clonefrom)
 		log.AddContext(ctx).Errorln(msg)
@@ -449,14 +555,20 @@ func (p *SAN) lunCopy(ctx context.Context, params map[string]interface{}) (map[s
 	if err != nil {
 		return nil, err
 	} else if dstLun == nil {
-		dstLun, err = p.cli.CreateLun(ctx, params)
+		dstLun, err = p.createCloneTarget(ctx, params, srcLunCapacity)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	srcLunID := srcLun["ID"].(string)
-	dstLunID := dstLun["ID"].(string)
+	srcLunID, err := utils.GetStringField(srcLun, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of clone src LUN %s error: %v", clonefrom, err)
+	}
+	dstLunID, err := utils.GetStringField(dstLun, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of clone dst LUN error: %v", err)
+	}
 	snapshotName := fmt.Sprintf("k8s_lun_%s_to_%s_snap", srcLunID, dstLunID)
 
 	smartX := smartx.NewSmartX(p.cli)
@@ -472,10 +584,17 @@ func (p *SAN) lunCopy(ctx context.Context, params map[string]interface{}) (map[s
 		}
 	}
 
-	lunCopyName, err := p.ensureLUNCopy(ctx, snapshot["ID"].(string), dstLunID, params["clonespeed"].(int))
+	snapshotID, err := utils.GetStringField(snapshot, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of snapshot %s error: %v", snapshotName, err)
+	}
+	lunCopyName, err := p.ensureLUNCopy(ctx, snapshotID, dstLunID, params)
 	if err != nil {
 		return nil, err
 	}
+	if isAsyncCopy(params) {
+		return dstLun, nil
+	}
 
 	err = p.deleteLunCopy(ctx, lunCopyName, true)
 	if err != nil {
@@ -486,7 +605,10 @@ func (p *SAN) lunCopy(ctx context.Context, params map[string]interface{}) (map[s
 	return dstLun, nil
 }
 
-func (p *SAN) ensureLUNCopy(ctx context.Context, snapshotID, dstLunID string, cloneSpeed int) (string, error) {
+func (p *SAN) ensureLUNCopy(ctx context.Context, snapshotID, dstLunID string,
+	params map[string]interface{}) (string, error) {
+	cloneSpeed, _ := params["clonespeed"].(int)
+	asyncCopy := isAsyncCopy(params)
 	lunCopyName, err := p.createLunCopy(ctx, snapshotID, dstLunID, cloneSpeed, true)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Create lun copy, source snapshot ID %s, target lun ID %s error: %s",
@@ -495,6 +617,14 @@ func (p *SAN) ensureLUNCopy(ctx context.Context, snapshotID, dstLunID string, cl
 		p.cli.DeleteLun(ctx, dstLunID)
 		return "", err
 	}
+	err = p.markCloneStarted(ctx, dstLunID, getDescription(params))
+	if err != nil {
+		return "", err
+	}
+	if asyncCopy {
+		log.AddContext(ctx).Infof("Luncopy %s is started, the data is copied in the background", lunCopyName)
+		return lunCopyName, nil
+	}
 
 	err = p.waitLunCopyFinish(ctx, lunCopyName)
 	if err != nil {
@@ -512,17 +642,15 @@ func (p *SAN) fromSnapshotByLunCopy(ctx context.Context,
 		return nil, err
 	}
 	if srcSnapshot == nil {
-		msg := fmt.Sprintf("Clone src snapshot %s does not exist", srcSnapshotName)
-		log.AddContext(ctx).Errorln(msg)
-		return nil, errors.New(msg)
+		return nil, utils.KindErrorf(ctx, utils.ErrNotFound, "Clone src snapshot %s does not exist", srcSnapshotName)
 	}
 
-	srcSnapshotCapacity, err := strconv.ParseInt(srcSnapshot["USERCAPACITY"].(string), 10, 64)
+	srcSnapshotCapacity, err := utils.ParseSectors(srcSnapshot, "USERCAPACITY")
 	if err != nil {
 		return nil, err
 	}
 
-	if params["capacity"].(int64) < srcSnapshotCapacity {
+	if utils.CapacityFromSectors(params["capacity"].(int64)) < srcSnapshotCapacity {
 		msg := fmt.Sprintf("Clone LUN capacity must be >= src snapshot%s", srcSnapshotName)
 		log.AddContext(ctx).Errorln(msg)
 		return nil, errors.New(msg)
@@ -533,21 +661,35 @@ func (p *SAN) fromSnapshotByLunCopy(ctx context.Context,
 		return nil, err
 	}
 	if dstLun == nil {
-		dstLun, err = p.cli.CreateLun(ctx, params)
+		dstLun, err = p.createCloneTarget(ctx, params, srcSnapshotCapacity)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	dstLunID := dstLun["ID"].(string)
-	lunCopyName, err := p.createLunCopy(ctx, srcSnapshot["ID"].(string),
-		dstLunID, params["clonespeed"].(int), false)
+	dstLunID, err := utils.GetStringField(dstLun, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of clone dst LUN error: %v", err)
+	}
+	srcSnapshotID, err := utils.GetStringField(srcSnapshot, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of clone src snapshot %s error: %v", srcSnapshotName, err)
+	}
+	lunCopyName, err := p.createLunCopy(ctx, srcSnapshotID, dstLunID, params["clonespeed"].(int), false)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Create LunCopy, source snapshot ID %s, target lun ID %s error: %s",
-			srcSnapshot["ID"].(string), dstLunID, err)
+			srcSnapshotID, dstLunID, err)
 		p.cli.DeleteLun(ctx, dstLunID)
 		return nil, err
 	}
+	err = p.markCloneStarted(ctx, dstLunID, getDescription(params))
+	if err != nil {
+		return nil, err
+	}
+	if isAsyncCopy(params) {
+		log.AddContext(ctx).Infof("Luncopy %s is started, the data is copied in the background", lunCopyName)
+		return dstLun, nil
+	}
 
 	err = p.waitLunCopyFinish(ctx, lunCopyName)
 	if err != nil {
@@ -581,7 +723,10 @@ func (p *SAN) createLunCopy(ctx context.Context,
 		}
 	}
 
-	lunCopyID := lunCopy["ID"].(string)
+	lunCopyID, err := utils.GetStringField(lunCopy, "ID")
+	if err != nil {
+		return "", utils.Errorf(ctx, "Get ID of luncopy %s error: %v", lunCopyName, err)
+	}
 
 	err = p.cli.StartLunCopy(ctx, lunCopyID)
 	if err != nil {
@@ -595,20 +740,83 @@ func (p *SAN) createLunCopy(ctx context.Context,
 
 func (p *SAN) clone(ctx context.Context,
 	params map[string]interface{}, taskResult map[string]interface{}) (map[string]interface{}, error) {
-	if p.product == "DoradoV6" {
-		return p.clonePair(ctx, params)
-	} else {
+	if p.product != "DoradoV6" {
 		return p.lunCopy(ctx, params)
 	}
+
+	samePool, err := p.isInTargetPool(ctx, params["clonefrom"].(string), params)
+	if err != nil {
+		return nil, err
+	}
+	if !samePool {
+		log.AddContext(ctx).Infof("Clone src LUN %s is in another pool, clone it by luncopy",
+			params["clonefrom"])
+		return p.lunCopy(ctx, params)
+	}
+	return p.clonePair(ctx, params)
 }
 
 func (p *SAN) createFromSnapshot(ctx context.Context,
 	params map[string]interface{}, taskResult map[string]interface{}) (map[string]interface{}, error) {
-	if p.product == "DoradoV6" {
-		return p.fromSnapshotByClonePair(ctx, params)
-	} else {
+	if p.product != "DoradoV6" {
+		return p.fromSnapshotByLunCopy(ctx, params)
+	}
+
+	samePool, err := p.isSnapshotInTargetPool(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if !samePool {
+		log.AddContext(ctx).Infof("Clone src snapshot %s is in another pool, clone it by luncopy",
+			params["fromSnapshot"])
 		return p.fromSnapshotByLunCopy(ctx, params)
 	}
+	return p.fromSnapshotByClonePair(ctx, params)
+}
+
+// isInTargetPool returns whether the LUN is in the pool of the LUN being created, clone pairs only
+// copy LUNs within a pool. A LUN which doesn't exist is regarded as in the pool, whose clone fails
+// with the not found error.
+func (p *SAN) isInTargetPool(ctx context.Context, lunName string, params map[string]interface{}) (bool, error) {
+	lun, err := p.cli.GetLunByName(ctx, lunName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get LUN %s error: %v", lunName, err)
+		return false, err
+	}
+	if lun == nil {
+		return true, nil
+	}
+
+	poolID, _ := lun["PARENTID"].(string)
+	return poolID == params["parentid"], nil
+}
+
+// isSnapshotInTargetPool returns whether the parent LUN of the snapshot is in the pool of the LUN
+// being created
+func (p *SAN) isSnapshotInTargetPool(ctx context.Context, params map[string]interface{}) (bool, error) {
+	snapshotName := params["fromSnapshot"].(string)
+	snapshot, err := p.cli.GetLunSnapshotByName(ctx, snapshotName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get snapshot %s error: %v", snapshotName, err)
+		return false, err
+	}
+	if snapshot == nil {
+		// HyperCDP objects and missing snapshots are left to the clone pair
+		return true, nil
+	}
+
+	parentID, _ := snapshot["PARENTID"].(string)
+	parent, err := p.cli.GetLunByID(ctx, parentID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get parent LUN %s of snapshot %s error: %v", parentID, snapshotName, err)
+		return false, err
+	}
+	if parent == nil {
+		return true, nil
+	}
+
+	poolID, _ := parent["PARENTID"].(string)
+	return poolID == params["parentid"], nil
 }
 
 func (p *SAN) revertLocalLun(ctx context.Context, taskResult map[string]interface{}) error {
@@ -635,8 +843,7 @@ func (p *SAN) createLocalQoS(ctx context.Context,
 
 	qosID, exist := lun["IOCLASSID"].(string)
 	if !exist || qosID == "" {
-		smartX := smartx.NewSmartX(p.cli)
-		qosID, err = smartX.CreateQos(ctx, lunID, "lun", "", qos)
+		qosID, err = p.createQos(ctx, p.cli, lunID, "lun", "", qos, params)
 		if err != nil {
 			log.AddContext(ctx).Errorf("Create qos %v for lun %s error: %v", qos, lunID, err)
 			return nil, err
@@ -648,6 +855,50 @@ func (p *SAN) createLocalQoS(ctx context.Context,
 	}, nil
 }
 
+func (p *SAN) addLocalSmartCache(ctx context.Context,
+	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+	partitionID, exist := params["cachePartitionID"].(string)
+	if !exist {
+		return nil, nil
+	}
+
+	lunID := taskResult["localLunID"].(string)
+	lun, err := p.cli.GetLunByID(ctx, lunID)
+	if err != nil {
+		return nil, err
+	}
+
+	if lun["SMARTCACHEPARTITIONID"] == partitionID {
+		return nil, nil
+	}
+
+	err = p.cli.AddLunToSmartCachePartition(ctx, lunID, partitionID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Add lun %s to SmartCache partition %s error: %v", lunID, partitionID, err)
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"localCachePartitionID": partitionID,
+	}, nil
+}
+
+// revertLocalSmartCache removes the LUN from the SmartCache partition it was added to, as a LUN in a
+// partition can't be deleted
+func (p *SAN) revertLocalSmartCache(ctx context.Context, taskResult map[string]interface{}) error {
+	lunID, lunIDExist := taskResult["localLunID"].(string)
+	partitionID, partitionIDExist := taskResult["localCachePartitionID"].(string)
+	if !lunIDExist || !partitionIDExist {
+		return nil
+	}
+
+	err := p.cli.RemoveLunFromSmartCachePartition(ctx, lunID, partitionID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Remove lun %s from SmartCache partition %s error: %v", lunID, partitionID, err)
+	}
+	return err
+}
+
 func (p *SAN) revertLocalQoS(ctx context.Context, taskResult map[string]interface{}) error {
 	lunID, lunIDExist := taskResult["localLunID"].(string)
 	qosID, qosIDExist := taskResult["localQosID"].(string)
@@ -682,135 +933,396 @@ func (p *SAN) getLunCopyOfLunID(ctx context.Context, lunID string) (string, erro
 	if err != nil {
 		return "", err
 	}
+	if lunCopy == nil {
+		return "", nil
+	}
 
-	return lunCopy["NAME"].(string), nil
+	return utils.GetStringField(lunCopy, "NAME")
 }
 
-func (p *SAN) deleteLunCopy(ctx context.Context, lunCopyName string, isDeleteSnapshot bool) error {
-	lunCopy, err := p.cli.GetLunCopyByName(ctx, lunCopyName)
+func (p *SAN) deleteLunCopy(ctx context.Context, lunCopyName string, isDeleteSnapshot bool) error {
+	lunCopy, err := p.cli.GetLunCopyByName(ctx, lunCopyName)
+	if err != nil {
+		return err
+	}
+	if lunCopy == nil {
+		return nil
+	}
+
+	lunCopyID, err := utils.GetStringField(lunCopy, "ID")
+	if err != nil {
+		return err
+	}
+
+	runningStatus, _ := lunCopy["RUNNINGSTATUS"].(string)
+	if runningStatus == lunCopyRunningStatusQueuing ||
+		runningStatus == lunCopyRunningStatusCopying {
+		p.cli.StopLunCopy(ctx, lunCopyID)
+	}
+
+	err = p.cli.DeleteLunCopy(ctx, lunCopyID)
+	if err != nil {
+		return err
+	}
+
+	snapshotName, _ := lunCopy["SOURCELUNNAME"].(string)
+	snapshot, err := p.cli.GetLunSnapshotByName(ctx, snapshotName)
+	if err == nil && snapshot != nil && isDeleteSnapshot {
+		snapshotID, err := utils.GetStringField(snapshot, "ID")
+		if err != nil {
+			return utils.Errorf(ctx, "Get ID of snapshot %s error: %v", snapshotName, err)
+		}
+		smartX := smartx.NewSmartX(p.cli)
+		smartX.DeleteLunSnapshot(ctx, snapshotID)
+	}
+
+	return nil
+}
+
+func (p *SAN) waitLunCopyFinish(ctx context.Context, lunCopyName string) error {
+	err := utils.WaitUntil(func() (bool, error) {
+		lunCopy, err := p.cli.GetLunCopyByName(ctx, lunCopyName)
+		if err != nil {
+			return false, err
+		}
+		if lunCopy == nil {
+			return true, nil
+		}
+
+		return isLunCopyFinished(lunCopyName, lunCopy)
+	}, time.Hour*6, time.Second*5)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// isLunCopyFinished returns whether the data of the luncopy is copied fully
+func isLunCopyFinished(lunCopyName string, lunCopy map[string]interface{}) (bool, error) {
+	healthStatus, err := utils.GetStringField(lunCopy, "HEALTHSTATUS")
+	if err != nil {
+		return false, err
+	}
+	if healthStatus == lunCopyHealthStatusFault {
+		return false, fmt.Errorf("Luncopy %s is at fault status", lunCopyName)
+	}
+
+	runningStatus, err := utils.GetStringField(lunCopy, "RUNNINGSTATUS")
+	if err != nil {
+		return false, err
+	}
+	if runningStatus == lunCopyRunningStatusQueuing ||
+		runningStatus == lunCopyRunningStatusCopying {
+		return false, nil
+	} else if runningStatus == lunCopyRunningStatusStop ||
+		runningStatus == lunCopyRunningStatusPaused {
+		return false, fmt.Errorf("Luncopy %s is stopped", lunCopyName)
+	} else {
+		return true, nil
+	}
+}
+
+func (p *SAN) waitClonePairFinish(ctx context.Context, clonePairID string) error {
+	err := utils.WaitUntil(func() (bool, error) {
+		clonePair, err := p.cli.GetClonePairInfo(ctx, clonePairID)
+		if err != nil {
+			return false, err
+		}
+		if clonePair == nil {
+			return true, nil
+		}
+
+		return isClonePairFinished(clonePairID, clonePair)
+	}, time.Hour*6, time.Second*5)
+
+	if err != nil {
+		return err
+	}
+
+	p.cli.DeleteClonePair(ctx, clonePairID)
+	return nil
+}
+
+// isClonePairFinished returns whether the data of the clone pair is copied fully
+func isClonePairFinished(clonePairID string, clonePair map[string]interface{}) (bool, error) {
+	healthStatus, err := utils.GetStringField(clonePair, "copyStatus")
+	if err != nil {
+		return false, err
+	}
+	if healthStatus == clonePairHealthStatusFault {
+		return false, fmt.Errorf("ClonePair %s is at fault status", clonePairID)
+	}
+
+	runningStatus, err := utils.GetStringField(clonePair, "syncStatus")
+	if err != nil {
+		return false, err
+	}
+	if runningStatus == clonePairRunningStatusNormal {
+		return true, nil
+	} else if runningStatus == clonePairRunningStatusSyncing ||
+		runningStatus == clonePairRunningStatusInitializing ||
+		runningStatus == clonePairRunningStatusUnsyncing {
+		return false, nil
+	} else {
+		return false, fmt.Errorf("ClonePair %s running status is abnormal", clonePairID)
+	}
+}
+
+// resumeClone continues the clone of an existing LUN, which may have been interrupted by a
+// controller restart. Everything is derived from the objects on the array, so any retried
+// CreateVolume picks the operation up where it stopped.
+func (p *SAN) resumeClone(ctx context.Context, lun, params map[string]interface{}) error {
+	lunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return err
+	}
+
+	if p.product == "DoradoV6" {
+		return p.resumeClonePair(ctx, lun, lunID, params)
+	}
+
+	return p.resumeLunCopy(ctx, lunID, params)
+}
+
+func (p *SAN) resumeClonePair(ctx context.Context, lun map[string]interface{},
+	lunID string, params map[string]interface{}) error {
+	// ID of clone pair is the same as destination LUN ID
+	clonePair, err := p.cli.GetClonePairInfo(ctx, lunID)
+	if err != nil {
+		return err
+	}
+	if clonePair == nil {
+		// a LUN cloned from another pool is copied by luncopy
+		return p.resumeLunCopy(ctx, lunID, params)
+	}
+
+	err = p.extendCloneLun(ctx, lun, lunID, params)
+	if err != nil {
+		return err
+	}
+
+	syncStatus, err := utils.GetStringField(clonePair, "syncStatus")
+	if err != nil {
+		return err
+	}
+	if syncStatus == clonePairRunningStatusUnsyncing {
+		log.AddContext(ctx).Infof("ClonePair %s has not been synchronized, start it", lunID)
+		err = p.cli.SyncClonePair(ctx, lunID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Start ClonePair %s error: %v", lunID, err)
+			return err
+		}
+	}
+
+	if isAsyncCopy(params) {
+		return nil
+	}
+	return p.waitClonePairFinish(ctx, lunID)
+}
+
+// extendCloneLun extends a clone target LUN which was created with the capacity of its
+// source, in case the restart happened before the extension.
+func (p *SAN) extendCloneLun(ctx context.Context, lun map[string]interface{},
+	lunID string, params map[string]interface{}) error {
+	capacity, ok := params["capacity"].(int64)
+	if !ok {
+		return nil
+	}
+
+	existCapacity, err := utils.ParseSectors(lun, "CAPACITY")
+	if err != nil {
+		return err
+	}
+	if existCapacity >= utils.CapacityFromSectors(capacity) {
+		return nil
+	}
+
+	err = p.cli.ExtendLun(ctx, lunID, capacity)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Extend clone lun %s error: %v", lunID, err)
+		return err
+	}
+
+	return nil
+}
+
+// extendLocalCloneLun extends a LUN cloned from a LUN or a snapshot to the requested capacity, as
+// the LUN is created with the capacity of its source and the copy keeps it
+func (p *SAN) extendLocalCloneLun(ctx context.Context,
+	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+	lunID := taskResult["localLunID"].(string)
+	lun, err := p.cli.GetLunByID(ctx, lunID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get LUN %s error: %v", lunID, err)
+		return nil, err
+	}
+	if lun == nil {
+		return nil, utils.Errorf(ctx, "Clone LUN %s does not exist", lunID)
+	}
+
+	if isAsyncCopy(params) {
+		// a luncopy target is extended once it is copied, which is checked again when the creation is retried
+		progress, err := p.completeLunCopy(ctx, lunID)
+		if err != nil || progress.Copying {
+			return nil, err
+		}
+	}
+
+	return nil, p.extendCloneLun(ctx, lun, lunID, params)
+}
+
+func (p *SAN) resumeLunCopy(ctx context.Context, lunID string, params map[string]interface{}) error {
+	_, isClone := params["clonefrom"]
+
+	lunCopyName, err := p.getLunCopyOfLunID(ctx, lunID)
+	if err != nil {
+		return err
+	}
+	if len(lunCopyName) == 0 {
+		if !isClone {
+			return nil
+		}
+		return p.resumeCloneSnapshot(ctx, lunID, params)
+	}
+	if isAsyncCopy(params) {
+		return nil
+	}
+
+	err = p.waitLunCopyFinish(ctx, lunCopyName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Wait luncopy %s finish error: %v", lunCopyName, err)
+		return err
+	}
+
+	return p.deleteLunCopy(ctx, lunCopyName, isClone)
+}
+
+// resumeCloneSnapshot handles a clone whose intermediate snapshot was created, but whose
+// luncopy was not. The snapshot is deleted together with the luncopy once the copy is done,
+// so its existence tells that the copy has not been performed yet.
+func (p *SAN) resumeCloneSnapshot(ctx context.Context, lunID string, params map[string]interface{}) error {
+	snapshot, err := p.getCloneSnapshot(ctx, lunID, params)
+	if err != nil || snapshot == nil {
+		return err
+	}
+	snapshotName, _ := snapshot["NAME"].(string)
+
+	snapshotID, err := utils.GetStringField(snapshot, "ID")
 	if err != nil {
 		return err
 	}
-	if lunCopy == nil {
-		return nil
+
+	log.AddContext(ctx).Infof("Luncopy from snapshot %s to LUN %s was not created, resume it", snapshotName, lunID)
+	lunCopyName, err := p.ensureLUNCopy(ctx, snapshotID, lunID, params)
+	if err != nil || isAsyncCopy(params) {
+		return err
 	}
 
-	lunCopyID := lunCopy["ID"].(string)
-	runningStatus := lunCopy["RUNNINGSTATUS"].(string)
-	if runningStatus == lunCopyRunningStatusQueuing ||
-		runningStatus == lunCopyRunningStatusCopying {
-		p.cli.StopLunCopy(ctx, lunCopyID)
+	return p.deleteLunCopy(ctx, lunCopyName, true)
+}
+
+// getCloneSnapshot returns the intermediate snapshot of a clone copied by luncopy, nil if there is none
+func (p *SAN) getCloneSnapshot(ctx context.Context, lunID string,
+	params map[string]interface{}) (map[string]interface{}, error) {
+	cloneFrom, ok := params["clonefrom"].(string)
+	if !ok {
+		return nil, nil
 	}
 
-	err = p.cli.DeleteLunCopy(ctx, lunCopyID)
+	srcLun, err := p.cli.GetLunByName(ctx, cloneFrom)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if srcLun == nil {
+		return nil, nil
 	}
 
-	snapshotName := lunCopy["SOURCELUNNAME"].(string)
-	snapshot, err := p.cli.GetLunSnapshotByName(ctx, snapshotName)
-	if err == nil && snapshot != nil && isDeleteSnapshot {
-		snapshotID := snapshot["ID"].(string)
-		smartX := smartx.NewSmartX(p.cli)
-		smartX.DeleteLunSnapshot(ctx, snapshotID)
+	srcLunID, err := utils.GetStringField(srcLun, "ID")
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	snapshotName := fmt.Sprintf("k8s_lun_%s_to_%s_snap", srcLunID, lunID)
+	return p.cli.GetLunSnapshotByName(ctx, snapshotName)
 }
 
-func (p *SAN) waitLunCopyFinish(ctx context.Context, lunCopyName string) error {
-	err := utils.WaitUntil(func() (bool, error) {
-		lunCopy, err := p.cli.GetLunCopyByName(ctx, lunCopyName)
-		if err != nil {
-			return false, err
-		}
-		if lunCopy == nil {
-			return true, nil
-		}
-
-		healthStatus := lunCopy["HEALTHSTATUS"].(string)
-		if healthStatus == lunCopyHealthStatusFault {
-			return false, fmt.Errorf("Luncopy %s is at fault status", lunCopyName)
-		}
+// createCloneTarget creates the target LUN of a clone with the capacity of its source. The LUN is marked
+// by its description until the copy is started, so that a retried creation can tell an empty target.
+func (p *SAN) createCloneTarget(ctx context.Context, params map[string]interface{},
+	capacity utils.Capacity) (map[string]interface{}, error) {
+	copyParams := utils.CopyMap(params)
+	copyParams["capacity"] = capacity.Sectors()
+	copyParams["description"] = cloneTargetDescription
 
-		runningStatus := lunCopy["RUNNINGSTATUS"].(string)
-		if runningStatus == lunCopyRunningStatusQueuing ||
-			runningStatus == lunCopyRunningStatusCopying {
-			return false, nil
-		} else if runningStatus == lunCopyRunningStatusStop ||
-			runningStatus == lunCopyRunningStatusPaused {
-			return false, fmt.Errorf("Luncopy %s is stopped", lunCopyName)
-		} else {
-			return true, nil
-		}
-	}, time.Hour*6, time.Second*5)
+	return p.cli.CreateLun(ctx, copyParams)
+}
 
+// markCloneStarted restores the description of a clone target LUN once its copy is started
+func (p *SAN) markCloneStarted(ctx context.Context, lunID, description string) error {
+	err := p.cli.UpdateLun(ctx, lunID, map[string]interface{}{"DESCRIPTION": description})
 	if err != nil {
-		return err
+		return utils.Errorf(ctx, "Mark copy of clone LUN %s started error: %v", lunID, err)
 	}
 
 	return nil
 }
 
-func (p *SAN) waitClonePairFinish(ctx context.Context, clonePairID string) error {
-	err := utils.WaitUntil(func() (bool, error) {
-		clonePair, err := p.cli.GetClonePairInfo(ctx, clonePairID)
-		if err != nil {
-			return false, err
-		}
-		if clonePair == nil {
-			return true, nil
-		}
+// deleteUncopiedCloneTarget deletes a clone target LUN whose copy was never started, which happens when the
+// creation is interrupted between the creation of the LUN and of its clone pair, luncopy or snapshot. Such a
+// LUN holds no data, so nil is returned for it to be created again. If any copy relationship exists, the
+// copy is marked started and resumed by resumeClone.
+func (p *SAN) deleteUncopiedCloneTarget(ctx context.Context,
+	lun, params map[string]interface{}) (map[string]interface{}, error) {
+	if description, _ := lun["DESCRIPTION"].(string); description != cloneTargetDescription {
+		return lun, nil
+	}
 
-		healthStatus := clonePair["copyStatus"].(string)
-		if healthStatus == clonePairHealthStatusFault {
-			return false, fmt.Errorf("ClonePair %s is at fault status", clonePairID)
-		}
+	lunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return nil, err
+	}
 
-		runningStatus := clonePair["syncStatus"].(string)
-		if runningStatus == clonePairRunningStatusNormal {
-			return true, nil
-		} else if runningStatus == clonePairRunningStatusSyncing ||
-			runningStatus == clonePairRunningStatusInitializing ||
-			runningStatus == clonePairRunningStatusUnsyncing {
-			return false, nil
-		} else {
-			return false, fmt.Errorf("ClonePair %s running status is abnormal", clonePairID)
-		}
-	}, time.Hour*6, time.Second*5)
+	started, err := p.isCloneStarted(ctx, lunID, params)
+	if err != nil {
+		return nil, err
+	}
+	if started {
+		return lun, p.markCloneStarted(ctx, lunID, getDescription(params))
+	}
 
+	log.AddContext(ctx).Warningf("Copy of clone LUN %s was never started, recreate it", lunID)
+	err = p.cli.DeleteLun(ctx, lunID)
 	if err != nil {
-		return err
+		return nil, utils.Errorf(ctx, "Delete uncopied clone LUN %s error: %v", lunID, err)
 	}
 
-	p.cli.DeleteClonePair(ctx, clonePairID)
-	return nil
+	return nil, nil
 }
 
-func (p *SAN) waitCloneFinish(ctx context.Context,
-	lun map[string]interface{}, taskResult map[string]interface{}) error {
-	lunID := lun["ID"].(string)
+// isCloneStarted returns whether a clone pair, luncopy or intermediate snapshot of the clone LUN exists
+func (p *SAN) isCloneStarted(ctx context.Context, lunID string, params map[string]interface{}) (bool, error) {
 	if p.product == "DoradoV6" {
 		// ID of clone pair is the same as destination LUN ID
-		err := p.waitClonePairFinish(ctx, lunID)
-		if err != nil {
-			return err
-		}
-	} else {
-		lunCopyName, err := p.getLunCopyOfLunID(ctx, lunID)
-		if err != nil {
-			return err
+		clonePair, err := p.cli.GetClonePairInfo(ctx, lunID)
+		if err != nil || clonePair != nil {
+			return clonePair != nil, err
 		}
+	}
 
-		if len(lunCopyName) > 0 {
-			err := p.waitLunCopyFinish(ctx, lunCopyName)
-			if err != nil {
-				return err
-			}
-		}
+	lunCopyName, err := p.getLunCopyOfLunID(ctx, lunID)
+	if err != nil || lunCopyName != "" {
+		return lunCopyName != "", err
 	}
 
-	return nil
+	snapshot, err := p.getCloneSnapshot(ctx, lunID, params)
+	return snapshot != nil, err
+}
+
+func getDescription(params map[string]interface{}) string {
+	description, _ := params["description"].(string)
+	return description
 }
 
 func (p *SAN) createRemoteLun(ctx context.Context,
@@ -838,8 +1350,13 @@ func (p *SAN) createRemoteLun(ctx context.Context,
 		}
 	}
 
+	remoteLunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of remote LUN %s error: %v", lunName, err)
+	}
+
 	return map[string]interface{}{
-		"remoteLunID": lun["ID"].(string),
+		"remoteLunID": remoteLunID,
 	}, nil
 }
 
@@ -869,8 +1386,7 @@ func (p *SAN) createRemoteQoS(ctx context.Context,
 
 	qosID, exist := lun["IOCLASSID"].(string)
 	if !exist || qosID == "" {
-		smartX := smartx.NewSmartX(remoteCli)
-		qosID, err = smartX.CreateQos(ctx, lunID, "lun", "", qos)
+		qosID, err = p.createQos(ctx, remoteCli, lunID, "lun", "", qos, params)
 		if err != nil {
 			log.AddContext(ctx).Errorf("Create qos %v for lun %s error: %v", qos, lunID, err)
 			return nil, err
@@ -905,18 +1421,22 @@ func (p *SAN) createHyperMetro(ctx context.Context,
 		return nil, err
 	}
 
+	_, needFirstSync1 := params["clonefrom"]
+	_, needFirstSync2 := params["fromSnapshot"]
+	needFirstSync := needFirstSync1 || needFirstSync2 || isHyperMetroFullSync(params)
+
 	var pairID string
 	if pair == nil {
-		_, needFirstSync1 := params["clonefrom"]
-		_, needFirstSync2 := params["fromSnapshot"]
-		needFirstSync := needFirstSync1 || needFirstSync2
 		data := map[string]interface{}{
 			"DOMAINID":       domainID,
 			"HCRESOURCETYPE": 1,
 			"ISFIRSTSYNC":    needFirstSync,
 			"LOCALOBJID":     localLunID,
 			"REMOTEOBJID":    remoteLunID,
-			"SPEED":          4,
+		}
+		setHyperMetroPairOptions(params, data)
+		if vStorePairID, exist := taskResult["vStorePairID"]; exist {
+			data["VSTOREPAIRID"] = vStorePairID
 		}
 
 		pair, err := p.cli.CreateHyperMetroPair(ctx, data)
@@ -926,7 +1446,11 @@ func (p *SAN) createHyperMetro(ctx context.Context,
 			return nil, err
 		}
 
-		pairID = pair["ID"].(string)
+		pairID, err = utils.GetStringField(pair, "ID")
+		if err != nil {
+			return nil, utils.Errorf(ctx, "Get ID of hypermetro pair between lun (%s-%s) error: %v",
+				localLunID, remoteLunID, err)
+		}
 		if needFirstSync {
 			err := p.cli.SyncHyperMetroPair(ctx, pairID)
 			if err != nil {
@@ -936,7 +1460,10 @@ func (p *SAN) createHyperMetro(ctx context.Context,
 			}
 		}
 	} else {
-		pairID = pair["ID"].(string)
+		pairID, err = p.resumeHyperMetroSync(ctx, pair, needFirstSync)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	err = p.waitHyperMetroSyncFinish(ctx, pairID)
@@ -946,11 +1473,42 @@ func (p *SAN) createHyperMetro(ctx context.Context,
 		return nil, err
 	}
 
+	err = p.joinHyperMetroGroup(ctx, params, domainID, pairID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Join hypermetro pair %s to consistency group error: %v", pairID, err)
+		p.cli.DeleteHyperMetroPair(ctx, pairID, true)
+		return nil, err
+	}
+
 	return map[string]interface{}{
 		"hyperMetroPairID": pairID,
 	}, nil
 }
 
+// resumeHyperMetroSync restarts the first synchronization of an existing hypermetro pair,
+// in case the pair was created but the synchronization never started before a restart.
+func (p *SAN) resumeHyperMetroSync(ctx context.Context,
+	pair map[string]interface{}, needFirstSync bool) (string, error) {
+	pairID, err := utils.GetStringField(pair, "ID")
+	if err != nil {
+		return "", err
+	}
+
+	runningStatus, _ := pair["RUNNINGSTATUS"].(string)
+	if !needFirstSync || runningStatus != hyperMetroPairRunningStatusPause {
+		return pairID, nil
+	}
+
+	log.AddContext(ctx).Infof("Hypermetro pair %s is paused before the first sync, resume it", pairID)
+	err = p.cli.SyncHyperMetroPair(ctx, pairID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Sync hypermetro pair %s error: %v", pairID, err)
+		return "", err
+	}
+
+	return pairID, nil
+}
+
 func (p *SAN) waitHyperMetroSyncFinish(ctx context.Context, pairID string) error {
 	err := utils.WaitUntil(func() (bool, error) {
 		pair, err := p.cli.GetHyperMetroPair(ctx, pairID)
@@ -963,12 +1521,18 @@ func (p *SAN) waitHyperMetroSyncFinish(ctx context.Context, pairID string) error
 			return false, errors.New(msg)
 		}
 
-		healthStatus := pair["HEALTHSTATUS"].(string)
+		healthStatus, err := utils.GetStringField(pair, "HEALTHSTATUS")
+		if err != nil {
+			return false, err
+		}
 		if healthStatus == hyperMetroPairHealthStatusFault {
 			return false, fmt.Errorf("Hypermetro pair %s is fault", pairID)
 		}
 
-		runningStatus := pair["RUNNINGSTATUS"].(string)
+		runningStatus, err := utils.GetStringField(pair, "RUNNINGSTATUS")
+		if err != nil {
+			return false, err
+		}
 		if runningStatus == hyperMetroPairRunningStatusToSync ||
 			runningStatus == hyperMetroPairRunningStatusSyncing {
 			return false, nil
@@ -1004,13 +1568,6 @@ func (p *SAN) revertHyperMetro(ctx context.Context, taskResult map[string]interf
 
 func (p *SAN) getHyperMetroParams(ctx context.Context,
 	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
-	metroDomain, exist := params["metrodomain"].(string)
-	if !exist || len(metroDomain) == 0 {
-		msg := "No hypermetro domain is specified for metro volume"
-		log.AddContext(ctx).Errorln(msg)
-		return nil, errors.New(msg)
-	}
-
 	if p.metroRemoteCli == nil {
 		msg := "remote client for hypermetro is nil"
 		log.AddContext(ctx).Errorln(msg)
@@ -1022,13 +1579,38 @@ func (p *SAN) getHyperMetroParams(ctx context.Context,
 		return nil, err
 	}
 
+	// The LUNs of the vStores are paired in the domain of the HyperMetro vStore pair
+	if vStorePairID, _ := params["vStorePairID"].(string); vStorePairID != "" {
+		metroDomainID, err := p.getvStorePairDomainID(ctx, vStorePairID)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"remotePoolID":  remotePoolID,
+			"remoteCli":     p.metroRemoteCli,
+			"metroDomainID": metroDomainID,
+			"vStorePairID":  vStorePairID,
+		}, nil
+	}
+
+	metroDomain, exist := params["metrodomain"].(string)
+	if !exist || len(metroDomain) == 0 {
+		msg := "No hypermetro domain is specified for metro volume"
+		log.AddContext(ctx).Errorln(msg)
+		return nil, errors.New(msg)
+	}
+
 	domain, err := p.metroRemoteCli.GetHyperMetroDomainByName(ctx, metroDomain)
 	if err != nil || domain == nil {
 		msg := fmt.Sprintf("Cannot get hypermetro domain %s ID", metroDomain)
 		log.AddContext(ctx).Errorln(msg)
 		return nil, errors.New(msg)
 	}
-	if status := domain["RUNNINGSTATUS"].(string); status != hyperMetroDomainRunningStatusNormal {
+	domainID, err := utils.GetStringField(domain, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of hypermetro domain %s error: %v", metroDomain, err)
+	}
+	if status, _ := domain["RUNNINGSTATUS"].(string); status != hyperMetroDomainRunningStatusNormal {
 		msg := fmt.Sprintf("Hypermetro domain %s status is not normal", metroDomain)
 		log.AddContext(ctx).Errorln(msg)
 		return nil, errors.New(msg)
@@ -1037,10 +1619,34 @@ func (p *SAN) getHyperMetroParams(ctx context.Context,
 	return map[string]interface{}{
 		"remotePoolID":  remotePoolID,
 		"remoteCli":     p.metroRemoteCli,
-		"metroDomainID": domain["ID"].(string),
+		"metroDomainID": domainID,
 	}, nil
 }
 
+// getvStorePairDomainID returns the HyperMetro domain of the HyperMetro vStore pair, which pairs the vStore
+// of the backend with the one of the metro backend
+func (p *SAN) getvStorePairDomainID(ctx context.Context, vStorePairID string) (string, error) {
+	vStorePair, err := p.cli.GetvStorePairByID(ctx, vStorePairID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get hypermetro vstore pair %s error: %v", vStorePairID, err)
+		return "", err
+	}
+	if vStorePair == nil {
+		return "", utils.Errorf(ctx, "Hypermetro vstore pair %s does not exist", vStorePairID)
+	}
+
+	if vStorePair["LOCALVSTORENAME"] != p.cli.GetvStoreName() ||
+		vStorePair["REMOTEVSTORENAME"] != p.metroRemoteCli.GetvStoreName() {
+		return "", utils.Errorf(ctx, "Hypermetro vstore pair %s does not pair the vstores %s and %s",
+			vStorePairID, p.cli.GetvStoreName(), p.metroRemoteCli.GetvStoreName())
+	}
+	if vStorePair["LINKSTATUS"] != vStorePairLinkStatusConnected {
+		return "", utils.Errorf(ctx, "Hypermetro vstore pair %s is not connected", vStorePairID)
+	}
+
+	return utils.GetStringField(vStorePair, "DOMAINID")
+}
+
 func (p *SAN) deleteLocalLunCopy(ctx context.Context,
 	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
 	lunID := params["lunID"].(string)
@@ -1076,7 +1682,10 @@ func (p *SAN) deleteLocalHyperCopy(ctx context.Context,
 		return nil, nil
 	}
 
-	clonePairID := clonePair["ID"].(string)
+	clonePairID, err := utils.GetStringField(clonePair, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of clone pair of lun %s error: %v", lunID, err)
+	}
 	err = p.cli.DeleteClonePair(ctx, clonePairID)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Delete clone pair %s error: %v", clonePairID, err)
@@ -1118,8 +1727,16 @@ func (p *SAN) deleteHyperMetro(ctx context.Context,
 		return nil, nil
 	}
 
-	pairID := pair["ID"].(string)
-	status := pair["RUNNINGSTATUS"].(string)
+	err = p.leaveHyperMetroGroup(ctx, pair)
+	if err != nil {
+		return nil, err
+	}
+
+	pairID, err := utils.GetStringField(pair, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of hypermetro pair of lun %s error: %v", lunID, err)
+	}
+	status, _ := pair["RUNNINGSTATUS"].(string)
 
 	if status == hyperMetroPairRunningStatusNormal ||
 		status == hyperMetroPairRunningStatusToSync ||
@@ -1136,60 +1753,55 @@ func (p *SAN) deleteHyperMetro(ctx context.Context,
 	return nil, nil
 }
 
+// preExpandCheckRemoteCapacity returns the ID of the remote LUN, and whether it is already of the size
 func (p *SAN) preExpandCheckRemoteCapacity(ctx context.Context,
-	params map[string]interface{}, cli client.BaseClientInterface) (string, error) {
+	params map[string]interface{}, cli client.BaseClientInterface) (map[string]interface{}, error) {
 	// check the remote pool
 	name := params["name"].(string)
 	remoteLunName := utils.GetLunName(name)
 	remoteLun, err := cli.GetLunByName(ctx, remoteLunName)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Get lun by name %s error: %v", remoteLunName, err)
-		return "", err
+		return nil, err
 	}
 	if remoteLun == nil {
 		msg := fmt.Sprintf("remote lun %s to extend does not exist", remoteLunName)
 		log.AddContext(ctx).Errorln(msg)
-		return "", errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
 	newSize := params["size"].(int64)
-	curSize, err := strconv.ParseInt(remoteLun["CAPACITY"].(string), 10, 64)
+	curSize, err := utils.ParseSectors(remoteLun, "CAPACITY")
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	if newSize < curSize {
-		msg := fmt.Sprintf("Remote Lun %s newSize %d must be greater than curSize %d",
-			remoteLunName, newSize, curSize)
+	if newSize < curSize.Sectors() {
+		msg := fmt.Sprintf("Remote Lun %s newSize %d must not be less than curSize %d",
+			remoteLunName, newSize, curSize.Sectors())
 		log.AddContext(ctx).Errorln(msg)
-		return "", errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
-	return remoteLun["ID"].(string), nil
-}
-
-func (p *SAN) preExpandHyperMetroCheckRemoteCapacity(ctx context.Context,
-	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
-	remoteLunID, err := p.preExpandCheckRemoteCapacity(ctx, params, p.metroRemoteCli)
+	remoteLunID, err := utils.GetStringField(remoteLun, "ID")
 	if err != nil {
-		return nil, err
+		return nil, utils.Errorf(ctx, "Get ID of remote lun %s error: %v", remoteLunName, err)
 	}
 
 	return map[string]interface{}{
-		"remoteLunID": remoteLunID,
+		"remoteLunID":       remoteLunID,
+		"remoteLunExpanded": newSize == curSize.Sectors(),
 	}, nil
 }
 
-func (p *SAN) preExpandReplicationCheckRemoteCapacity(ctx context.Context,
+func (p *SAN) preExpandHyperMetroCheckRemoteCapacity(ctx context.Context,
 	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
-	remoteLunID, err := p.preExpandCheckRemoteCapacity(ctx, params, p.replicaRemoteCli)
-	if err != nil {
-		return nil, err
-	}
+	return p.preExpandCheckRemoteCapacity(ctx, params, p.metroRemoteCli)
+}
 
-	return map[string]interface{}{
-		"remoteLunID": remoteLunID,
-	}, nil
+func (p *SAN) preExpandReplicationCheckRemoteCapacity(ctx context.Context,
+	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
+	return p.preExpandCheckRemoteCapacity(ctx, params, p.replicaRemoteCli)
 }
 
 func (p *SAN) suspendHyperMetro(ctx context.Context,
@@ -1205,8 +1817,26 @@ func (p *SAN) suspendHyperMetro(ctx context.Context,
 		return nil, nil
 	}
 
-	pairID := pair["ID"].(string)
-	status := pair["RUNNINGSTATUS"].(string)
+	pairID, err := utils.GetStringField(pair, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of hypermetro pair of lun %s error: %v", lunID, err)
+	}
+	status, _ := pair["RUNNINGSTATUS"].(string)
+
+	// The pairs of a consistency group are suspended with the group
+	if groupID := getHyperMetroGroupID(pair); groupID != "" {
+		if isHyperMetroRunning(pair) {
+			err := p.cli.StopHyperMetroGroup(ctx, groupID)
+			if err != nil {
+				log.AddContext(ctx).Errorf("Suspend san hypermetro consistency group %s error: %v", groupID, err)
+				return nil, err
+			}
+		}
+		return map[string]interface{}{
+			"hyperMetroPairID":  pairID,
+			"hyperMetroGroupID": groupID,
+		}, nil
+	}
 
 	if status == hyperMetroPairRunningStatusNormal ||
 		status == hyperMetroPairRunningStatusToSync ||
@@ -1226,6 +1856,10 @@ func (p *SAN) expandHyperMetroRemoteLun(ctx context.Context,
 	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
 	remoteLunID := taskResult["remoteLunID"].(string)
 	newSize := params["size"].(int64)
+	if expanded, _ := taskResult["remoteLunExpanded"].(bool); expanded {
+		log.AddContext(ctx).Infof("Hypermetro remote lun %s is already %d sectors", remoteLunID, newSize)
+		return nil, nil
+	}
 
 	err := p.metroRemoteCli.ExtendLun(ctx, remoteLunID, newSize)
 	if err != nil {
@@ -1243,6 +1877,15 @@ func (p *SAN) syncHyperMetro(ctx context.Context,
 		return nil, nil
 	}
 
+	if groupID, _ := taskResult["hyperMetroGroupID"].(string); groupID != "" {
+		err := p.cli.SyncHyperMetroGroup(ctx, groupID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Sync san hypermetro consistency group %s error: %v", groupID, err)
+			return nil, err
+		}
+		return nil, nil
+	}
+
 	err := p.cli.SyncHyperMetroPair(ctx, pairID)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Sync san hypermetro pair %s error: %v", pairID, err)
@@ -1256,6 +1899,10 @@ func (p *SAN) expandLocalLun(ctx context.Context,
 	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
 	lunID := params["lunID"].(string)
 	newSize := params["size"].(int64)
+	if params["expandSize"].(int64) == 0 {
+		log.AddContext(ctx).Infof("Lun %s is already %d sectors", lunID, newSize)
+		return nil, nil
+	}
 
 	err := p.cli.ExtendLun(ctx, lunID, newSize)
 	if err != nil {
@@ -1274,12 +1921,13 @@ func (p *SAN) CreateSnapshot(ctx context.Context,
 		return nil, err
 	}
 	if lun == nil {
-		msg := fmt.Sprintf("Lun %s to create snapshot does not exist", lunName)
-		log.AddContext(ctx).Errorln(msg)
-		return nil, errors.New(msg)
+		return nil, utils.KindErrorf(ctx, utils.ErrNotFound, "Lun %s to create snapshot does not exist", lunName)
 	}
 
-	lunId := lun["ID"].(string)
+	lunId, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of lun %s error: %v", lunName, err)
+	}
 	snapshot, err := p.cli.GetLunSnapshotByName(ctx, snapshotName)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Get lun snapshot by name %s error: %v", snapshotName, err)
@@ -1287,13 +1935,15 @@ func (p *SAN) CreateSnapshot(ctx context.Context,
 	}
 
 	if snapshot != nil {
-		snapshotParentId := snapshot["PARENTID"].(string)
-		if snapshotParentId != lunId {
+		if snapshot["PARENTID"] != lunId {
 			msg := fmt.Sprintf("Snapshot %s is already exist, but the parent LUN %s is incompatible", snapshotName, lunName)
 			log.AddContext(ctx).Errorln(msg)
 			return nil, errors.New(msg)
 		} else {
-			snapshotSize, _ := strconv.ParseInt(snapshot["USERCAPACITY"].(string), 10, 64)
+			snapshotSize, err := utils.ParseSectors(snapshot, "USERCAPACITY")
+			if err != nil {
+				return nil, utils.Errorf(ctx, "Get capacity of snapshot %s error: %v", snapshotName, err)
+			}
 			return p.getSnapshotReturnInfo(snapshot, snapshotSize), nil
 		}
 	}
@@ -1319,12 +1969,11 @@ func (p *SAN) CreateSnapshot(ctx context.Context,
 		return nil, err
 	}
 
-	snapshotSize, _ := strconv.ParseInt(result["snapshotSize"].(string), 10, 64)
-	return p.getSnapshotReturnInfo(snapshot, snapshotSize), nil
+	return p.getSnapshotReturnInfo(snapshot, result["snapshotSize"].(utils.Capacity)), nil
 }
 
 func (p *SAN) DeleteSnapshot(ctx context.Context, snapshotName string) error {
-	snapshot, err := p.cli.GetLunSnapshotByName(ctx, snapshotName)
+	snapshot, isHyperCDP, err := p.getSnapshotOrHyperCDP(ctx, snapshotName)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Get lun snapshot by name %s error: %v", snapshotName, err)
 		return err
@@ -1334,19 +1983,57 @@ func (p *SAN) DeleteSnapshot(ctx context.Context, snapshotName string) error {
 		log.AddContext(ctx).Infof("Lun snapshot %s to delete does not exist", snapshotName)
 		return nil
 	}
+	if isHyperCDP {
+		return p.deleteHyperCDP(ctx, snapshotName, snapshot)
+	}
 
 	taskflow := taskflow.NewTaskFlow(ctx, "Delete-LUN-Snapshot")
 	taskflow.AddTask("Deactivate-Snapshot", p.deactivateSnapshot, nil)
 	taskflow.AddTask("Delete-Snapshot", p.deleteSnapshot, nil)
 
+	snapshotID, err := utils.GetStringField(snapshot, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of lun snapshot %s error: %v", snapshotName, err)
+	}
+
 	params := map[string]interface{}{
-		"snapshotId": snapshot["ID"].(string),
+		"snapshotId": snapshotID,
 	}
 
 	_, err = taskflow.Run(params)
 	return err
 }
 
+// QuerySnapshot returns the snapshot of the parent LUN in the format of CreateSnapshot,
+// nil if the LUN has no snapshot of the name
+func (p *SAN) QuerySnapshot(ctx context.Context,
+	parentID, snapshotName string) (map[string]interface{}, error) {
+	snapshot, _, err := p.getSnapshotOrHyperCDP(ctx, snapshotName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun snapshot by name %s error: %v", snapshotName, err)
+		return nil, err
+	}
+
+	if snapshot == nil || snapshot["PARENTID"] != parentID {
+		return nil, nil
+	}
+
+	lun, err := p.cli.GetLunByID(ctx, parentID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun by ID %s error: %v", parentID, err)
+		return nil, err
+	}
+
+	snapshotSize, err := utils.ParseSectors(snapshot, "USERCAPACITY")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get capacity of snapshot %s error: %v", snapshotName, err)
+	}
+	info := p.getSnapshotReturnInfo(snapshot, snapshotSize)
+	info["ParentName"], _ = snapshot["PARENTNAME"].(string)
+	info["ParentVolume"] = getParentVolume(lun)
+	return info, nil
+}
+
 func (p *SAN) createSnapshot(ctx context.Context,
 	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
 	lunID := params["lunID"].(string)
@@ -1364,9 +2051,18 @@ func (p *SAN) createSnapshot(ctx context.Context,
 		return nil, err
 	}
 
+	snapshotID, err := utils.GetStringField(snapshot, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get ID of snapshot %s error: %v", snapshotName, err)
+	}
+	snapshotSize, err := utils.ParseSectors(snapshot, "USERCAPACITY")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get capacity of snapshot %s error: %v", snapshotName, err)
+	}
+
 	return map[string]interface{}{
-		"snapshotId":   snapshot["ID"].(string),
-		"snapshotSize": snapshot["USERCAPACITY"].(string),
+		"snapshotId":   snapshotID,
+		"snapshotSize": snapshotSize,
 	}, nil
 }
 
@@ -1382,7 +2078,7 @@ func (p *SAN) waitSnapshotReady(ctx context.Context, snapshotName string) error
 			return false, errors.New(msg)
 		}
 
-		runningStatus := snapshot["RUNNINGSTATUS"].(string)
+		runningStatus, err := utils.GetStringField(snapshot, "RUNNINGSTATUS")
 		if err != nil {
 			return false, err
 		}
@@ -1467,7 +2163,10 @@ func (p *SAN) getReplicationParams(ctx context.Context,
 		return nil, err
 	}
 
-	sn := remoteSystem["ID"].(string)
+	sn, err := utils.GetStringField(remoteSystem, "ID")
+	if err != nil {
+		return nil, utils.Errorf(ctx, "Get SN of remote device error: %v", err)
+	}
 	remoteDeviceID, err := p.getRemoteDeviceID(ctx, sn)
 	if err != nil {
 		return nil, err
@@ -1497,9 +2196,16 @@ func (p *SAN) deleteReplicationPair(ctx context.Context,
 	}
 
 	for _, pair := range pairs {
-		pairID := pair["ID"].(string)
+		pairID, err := utils.GetStringField(pair, "ID")
+		if err != nil {
+			return nil, utils.Errorf(ctx, "Get ID of replication pair of lun %s error: %v", lunID, err)
+		}
+		err = p.leaveReplicationGroup(ctx, pair)
+		if err != nil {
+			return nil, err
+		}
 
-		runningStatus := pair["RUNNINGSTATUS"].(string)
+		runningStatus, _ := pair["RUNNINGSTATUS"].(string)
 		if runningStatus == replicationPairRunningStatusNormal ||
 			runningStatus == replicationPairRunningStatusSync {
 			p.cli.SplitReplicationPair(ctx, pairID)
@@ -1538,7 +2244,10 @@ func (p *SAN) deleteLun(ctx context.Context, name string, cli client.BaseClientI
 		return nil
 	}
 
-	lunID := lun["ID"].(string)
+	lunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of lun %s error: %v", name, err)
+	}
 
 	qosID, exist := lun["IOCLASSID"].(string)
 	if exist && qosID != "" {
@@ -1550,6 +2259,16 @@ func (p *SAN) deleteLun(ctx context.Context, name string, cli client.BaseClientI
 		}
 	}
 
+	partitionID, exist := lun["SMARTCACHEPARTITIONID"].(string)
+	if exist && partitionID != "" {
+		err := cli.RemoveLunFromSmartCachePartition(ctx, lunID, partitionID)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Remove lun %s from SmartCache partition %s error: %v",
+				lunID, partitionID, err)
+			return err
+		}
+	}
+
 	err = cli.DeleteLun(ctx, lunID)
 	if err != nil {
 		log.AddContext(ctx).Errorf("Delete lun %s error: %v", lunID, err)
@@ -1575,20 +2294,29 @@ func (p *SAN) splitReplication(ctx context.Context,
 	replicationPairIDs := []string{}
 
 	for _, pair := range pairs {
-		pairID := pair["ID"].(string)
+		pairID, err := utils.GetStringField(pair, "ID")
+		if err != nil {
+			return nil, utils.Errorf(ctx, "Get ID of replication pair of lun %s error: %v", lunID, err)
+		}
 
-		runningStatus := pair["RUNNINGSTATUS"].(string)
+		runningStatus, _ := pair["RUNNINGSTATUS"].(string)
 		if runningStatus != replicationPairRunningStatusNormal &&
 			runningStatus != replicationPairRunningStatusSync {
 			continue
 		}
 
-		err := p.cli.SplitReplicationPair(ctx, pairID)
+		err = p.cli.SplitReplicationPair(ctx, pairID)
 		if err != nil {
 			return nil, err
 		}
 
 		replicationPairIDs = append(replicationPairIDs, pairID)
+		if isSyncReplicationPair(pair) {
+			err = p.waitReplicationPairSplit(ctx, pairID)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	return map[string]interface{}{
@@ -1600,6 +2328,10 @@ func (p *SAN) expandReplicationRemoteLun(ctx context.Context,
 	params, taskResult map[string]interface{}) (map[string]interface{}, error) {
 	remoteLunID := taskResult["remoteLunID"].(string)
 	newSize := params["size"].(int64)
+	if expanded, _ := taskResult["remoteLunExpanded"].(bool); expanded {
+		log.AddContext(ctx).Infof("Replication remote lun %s is already %d sectors", remoteLunID, newSize)
+		return nil, nil
+	}
 
 	err := p.replicaRemoteCli.ExtendLun(ctx, remoteLunID, newSize)
 	if err != nil {
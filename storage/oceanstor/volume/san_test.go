@@ -0,0 +1,276 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteUncopiedCloneTarget(t *testing.T) {
+	newLun := func(description string) map[string]interface{} {
+		return map[string]interface{}{"ID": "2", "NAME": "pvc-2", "DESCRIPTION": description}
+	}
+	params := map[string]interface{}{"clonefrom": "pvc-1", "description": "Created from Kubernetes CSI"}
+
+	tests := []struct {
+		name       string
+		product    string
+		lun        map[string]interface{}
+		clonePair  bool
+		snapshot   bool
+		expectLun  bool
+		expectMark bool
+	}{
+		{"Copied", "DoradoV6", newLun("Created from Kubernetes CSI"), false, false, true, false},
+		{"NeverStarted", "DoradoV6", newLun(cloneTargetDescription), false, false, false, false},
+		{"ClonePairStarted", "DoradoV6", newLun(cloneTargetDescription), true, false, true, true},
+		{"SnapshotCreated", "V5", newLun(cloneTargetDescription), false, true, true, true},
+		{"NeverStartedByLunCopy", "V5", newLun(cloneTargetDescription), false, false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := newFakeClient()
+			cli.luns["1"] = map[string]interface{}{"ID": "1", "NAME": "pvc-1"}
+			cli.luns["2"] = tt.lun
+			if tt.clonePair {
+				cli.clonePairs["2"] = map[string]interface{}{"ID": "2"}
+			}
+			if tt.snapshot {
+				cli.snapshots["k8s_lun_1_to_2_snap"] = map[string]interface{}{"ID": "3"}
+			}
+
+			san := NewSAN(cli, nil, nil, tt.product)
+			lun, err := san.deleteUncopiedCloneTarget(ctx, tt.lun, params)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectLun, lun != nil)
+			assert.Equal(t, !tt.expectLun, len(cli.deletedLuns) == 1)
+			if tt.expectMark {
+				assert.Equal(t, map[string]interface{}{"DESCRIPTION": "Created from Kubernetes CSI"},
+					cli.updatedLuns["2"])
+			} else {
+				assert.Empty(t, cli.updatedLuns)
+			}
+		})
+	}
+}
+
+// fakeExpandClient extends the LUNs and keeps their HyperMetro pair on a fake storage
+type fakeExpandClient struct {
+	*fakeClient
+	extendedLuns []string
+	syncedPairs  []string
+}
+
+func (c *fakeExpandClient) GetPoolByName(_ context.Context, name string) (map[string]interface{}, error) {
+	return map[string]interface{}{"ID": "0", "NAME": name}, nil
+}
+
+func (c *fakeExpandClient) ExtendLun(_ context.Context, lunID string, newCapacity int64) error {
+	c.luns[lunID]["CAPACITY"] = strconv.FormatInt(newCapacity, 10)
+	c.extendedLuns = append(c.extendedLuns, lunID)
+	return nil
+}
+
+func (c *fakeExpandClient) GetHyperMetroPairByLocalObjID(_ context.Context,
+	objID string) (map[string]interface{}, error) {
+	return map[string]interface{}{"ID": "pair-" + objID, "RUNNINGSTATUS": hyperMetroPairRunningStatusNormal}, nil
+}
+
+func (c *fakeExpandClient) StopHyperMetroPair(_ context.Context, _ string) error {
+	return nil
+}
+
+func (c *fakeExpandClient) SyncHyperMetroPair(_ context.Context, pairID string) error {
+	c.syncedPairs = append(c.syncedPairs, pairID)
+	return nil
+}
+
+func TestExpandHyperMetroLun(t *testing.T) {
+	newLun := func(id, capacity string) map[string]interface{} {
+		return map[string]interface{}{"ID": id, "NAME": "pvc-1", "CAPACITY": capacity, "PARENTNAME": "pool",
+			"HASRSSOBJECT": `{"HyperMetro":"TRUE"}`, "EXPOSEDTOINITIATOR": "false"}
+	}
+
+	tests := []struct {
+		name           string
+		localCapacity  string
+		remoteCapacity string
+		expectLocal    []string
+		expectRemote   []string
+		expectErr      bool
+	}{
+		{"NotExpanded", "100", "100", []string{"1"}, []string{"2"}, false},
+		{"LocalExpanded", "200", "100", nil, []string{"2"}, false},
+		{"BothExpanded", "200", "200", nil, nil, false},
+		{"LargerThanRequested", "300", "300", nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local := &fakeExpandClient{fakeClient: newFakeClient()}
+			local.luns["1"] = newLun("1", tt.localCapacity)
+			remote := &fakeExpandClient{fakeClient: newFakeClient()}
+			remote.luns["2"] = newLun("2", tt.remoteCapacity)
+
+			san := NewSAN(local, remote, nil, "DoradoV6")
+			_, err := san.Expand(ctx, "pvc-1", 200)
+			assert.Equal(t, tt.expectErr, err != nil)
+			if tt.expectErr {
+				return
+			}
+
+			// the pair is synchronized even if both LUNs were expanded last time
+			assert.Equal(t, tt.expectLocal, local.extendedLuns)
+			assert.Equal(t, tt.expectRemote, remote.extendedLuns)
+			assert.Equal(t, []string{"pair-1"}, local.syncedPairs)
+		})
+	}
+}
+
+// fakeSmartCacheClient keeps the SmartCache partitions of the LUNs on a fake storage
+type fakeSmartCacheClient struct {
+	*fakeClient
+}
+
+func (c *fakeSmartCacheClient) AddLunToSmartCachePartition(_ context.Context, lunID, partitionID string) error {
+	c.luns[lunID]["SMARTCACHEPARTITIONID"] = partitionID
+	return nil
+}
+
+func (c *fakeSmartCacheClient) RemoveLunFromSmartCachePartition(_ context.Context, lunID, _ string) error {
+	c.luns[lunID]["SMARTCACHEPARTITIONID"] = ""
+	return nil
+}
+
+func (c *fakeSmartCacheClient) DeleteLun(ctx context.Context, id string) error {
+	if c.luns[id]["SMARTCACHEPARTITIONID"] != "" {
+		return errors.New("lun is in a SmartCache partition")
+	}
+	return c.fakeClient.DeleteLun(ctx, id)
+}
+
+func TestRemoveLunFromSmartCache(t *testing.T) {
+	cli := &fakeSmartCacheClient{fakeClient: newFakeClient()}
+	cli.luns["1"] = map[string]interface{}{"ID": "1", "NAME": "pvc-1", "SMARTCACHEPARTITIONID": ""}
+	san := NewSAN(cli, nil, nil, "DoradoV6")
+
+	taskResult := map[string]interface{}{"localLunID": "1"}
+	result, err := san.addLocalSmartCache(ctx, map[string]interface{}{"cachePartitionID": "5"}, taskResult)
+	assert.NoError(t, err)
+	assert.Equal(t, "5", cli.luns["1"]["SMARTCACHEPARTITIONID"])
+
+	result["localLunID"] = "1"
+	assert.NoError(t, san.revertLocalSmartCache(ctx, result))
+	assert.Equal(t, "", cli.luns["1"]["SMARTCACHEPARTITIONID"])
+
+	cli.luns["1"]["SMARTCACHEPARTITIONID"] = "5"
+	assert.NoError(t, san.deleteLun(ctx, "pvc-1", cli))
+	assert.Equal(t, []string{"1"}, cli.deletedLuns)
+}
+
+// fakeMetroClient keeps the HyperMetro vStore pairs and the HyperMetro pairs created on a fake storage
+type fakeMetroClient struct {
+	*fakeClient
+	vStoreName  string
+	vStorePairs map[string]map[string]interface{}
+	createdPair map[string]interface{}
+}
+
+func (c *fakeMetroClient) GetvStoreName() string {
+	return c.vStoreName
+}
+
+func (c *fakeMetroClient) GetvStorePairByID(_ context.Context, pairID string) (map[string]interface{}, error) {
+	return c.vStorePairs[pairID], nil
+}
+
+func (c *fakeMetroClient) GetHyperMetroPairByLocalObjID(_ context.Context, _ string) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+func (c *fakeMetroClient) CreateHyperMetroPair(_ context.Context,
+	data map[string]interface{}) (map[string]interface{}, error) {
+	c.createdPair = data
+	return map[string]interface{}{"ID": "pair-1"}, nil
+}
+
+func (c *fakeMetroClient) GetHyperMetroPair(_ context.Context, pairID string) (map[string]interface{}, error) {
+	return map[string]interface{}{"ID": pairID, "HEALTHSTATUS": "1",
+		"RUNNINGSTATUS": hyperMetroPairRunningStatusNormal}, nil
+}
+
+func TestGetvStorePairDomainID(t *testing.T) {
+	cases := []struct {
+		name     string
+		pair     map[string]interface{}
+		domainID string
+		wantErr  bool
+	}{
+		{"Connected", map[string]interface{}{"LOCALVSTORENAME": "vstore-a", "REMOTEVSTORENAME": "vstore-b",
+			"LINKSTATUS": vStorePairLinkStatusConnected, "DOMAINID": "3"}, "3", false},
+		{"Not exist", nil, "", true},
+		{"Other vstores", map[string]interface{}{"LOCALVSTORENAME": "vstore-a", "REMOTEVSTORENAME": "vstore-c",
+			"LINKSTATUS": vStorePairLinkStatusConnected, "DOMAINID": "3"}, "", true},
+		{"Disconnected", map[string]interface{}{"LOCALVSTORENAME": "vstore-a", "REMOTEVSTORENAME": "vstore-b",
+			"LINKSTATUS": "2", "DOMAINID": "3"}, "", true},
+	}
+
+	for _, c := range cases {
+		local := &fakeMetroClient{fakeClient: newFakeClient(), vStoreName: "vstore-a",
+			vStorePairs: map[string]map[string]interface{}{}}
+		if c.pair != nil {
+			local.vStorePairs["1"] = c.pair
+		}
+		remote := &fakeMetroClient{fakeClient: newFakeClient(), vStoreName: "vstore-b"}
+		san := NewSAN(local, remote, nil, "DoradoV6")
+
+		domainID, err := san.getvStorePairDomainID(ctx, "1")
+		assert.Equal(t, c.wantErr, err != nil, c.name)
+		assert.Equal(t, c.domainID, domainID, c.name)
+	}
+}
+
+func TestCreateHyperMetroInvStorePair(t *testing.T) {
+	cases := []struct {
+		name         string
+		taskResult   map[string]interface{}
+		vStorePairID interface{}
+	}{
+		{"vStore pair", map[string]interface{}{"vStorePairID": "1"}, "1"},
+		{"Domain", map[string]interface{}{}, nil},
+	}
+
+	for _, c := range cases {
+		cli := &fakeMetroClient{fakeClient: newFakeClient()}
+		san := NewSAN(cli, nil, nil, "DoradoV6")
+		c.taskResult["metroDomainID"] = "3"
+		c.taskResult["localLunID"] = "1"
+		c.taskResult["remoteLunID"] = "2"
+
+		result, err := san.createHyperMetro(ctx, map[string]interface{}{}, c.taskResult)
+		assert.NoError(t, err, c.name)
+		assert.Equal(t, "pair-1", result["hyperMetroPairID"], c.name)
+		assert.Equal(t, "3", cli.createdPair["DOMAINID"], c.name)
+		assert.Equal(t, c.vStorePairID, cli.createdPair["VSTOREPAIRID"], c.name)
+	}
+}
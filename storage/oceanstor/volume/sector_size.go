@@ -0,0 +1,85 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"huawei-csi-driver/utils"
+)
+
+// fsSectorSizes are the SECTORSIZE in bytes of the filesystems by the sectorSize StorageClass parameter
+var fsSectorSizes = map[string]int64{
+	"4K":  4 * 1024,
+	"8K":  8 * 1024,
+	"16K": 16 * 1024,
+	"32K": 32 * 1024,
+	"64K": 64 * 1024,
+}
+
+// doradoV6FSSectorSizes are the sector sizes of the filesystems of OceanStor Dorado V6, which doesn't
+// support 64K
+var doradoV6FSSectorSizes = []string{"4K", "8K", "16K", "32K"}
+
+// ParseFSSectorSize returns the sector size in bytes of the sectorSize parameter, such as 8K
+func ParseFSSectorSize(sectorSize string) (int64, error) {
+	size, exist := fsSectorSizes[strings.ToUpper(sectorSize)]
+	if !exist {
+		return 0, fmt.Errorf("parameter sectorSize [%s] in sc must be one of 4K, 8K, 16K, 32K and 64K",
+			sectorSize)
+	}
+	return size, nil
+}
+
+// parseSectorSize converts the sectorsize parameter to the sector size in bytes of the filesystem, which
+// must be supported by the product
+func (p *NAS) parseSectorSize(ctx context.Context, params map[string]interface{}) error {
+	sectorSize, exist := params["sectorsize"].(string)
+	if !exist {
+		return nil
+	}
+
+	size, err := ParseFSSectorSize(sectorSize)
+	if err != nil {
+		return utils.Errorln(ctx, err.Error())
+	}
+	if p.product == utils.OceanStorDoradoV6 && !utils.IsContain(strings.ToUpper(sectorSize), doradoV6FSSectorSizes) {
+		return utils.Errorf(ctx, "parameter sectorSize [%s] in sc must be one of %v on %s", sectorSize,
+			doradoV6FSSectorSizes, p.product)
+	}
+
+	params["sectorsize"] = size
+	return nil
+}
+
+// checkCloneSectorSize checks the sector size of the clone source is the one requested, which the clone
+// filesystem always takes
+func checkCloneSectorSize(ctx context.Context, srcFS, params map[string]interface{}) error {
+	size, exist := params["sectorsize"].(int64)
+	if !exist {
+		return nil
+	}
+
+	srcSize := fmt.Sprint(srcFS["SECTORSIZE"])
+	if srcSize != fmt.Sprint(size) {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition, "Sector size %s of the clone source filesystem "+
+			"%s is not the requested %d", srcSize, srcFS["NAME"], size)
+	}
+	return nil
+}
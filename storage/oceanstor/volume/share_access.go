@@ -0,0 +1,129 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"huawei-csi-driver/storage/oceanstor/client"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// UpdateShareAccess sets the NFS clients allowed to access the share of the filesystem to the authClient
+// separated by ";", the clients no longer allowed are removed. The new clients take the squash and security
+// options of the current ones.
+func (p *NAS) UpdateShareAccess(ctx context.Context, name, authClient string) error {
+	if p.shareProtocol == ShareProtocolCIFS {
+		return utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"Filesystem %s is shared by CIFS, its NFS clients can't be set", name)
+	}
+
+	fsName := utils.GetFileSystemName(name)
+	fs, err := p.cli.GetFileSystemByName(ctx, fsName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get filesystem %s error: %v", fsName, err)
+		return err
+	} else if fs == nil {
+		return utils.KindErrorf(ctx, utils.ErrNotFound, "Filesystem %s to update share access does not exist",
+			fsName)
+	}
+
+	vStoreID, _ := fs["vstoreId"].(string)
+	taskResult := map[string]interface{}{"localVStoreID": vStoreID}
+	var hyperMetroIDs []string
+	hyperMetroIDStr, _ := fs["HYPERMETROPAIRIDS"].(string)
+	_ = json.Unmarshal([]byte(hyperMetroIDStr), &hyperMetroIDs)
+	if len(hyperMetroIDs) > 0 {
+		taskResult, err = p.setActiveClient(ctx, nil, nil)
+		if err != nil {
+			return err
+		}
+		if taskResult == nil {
+			taskResult = map[string]interface{}{"localVStoreID": vStoreID}
+		}
+	}
+
+	activeClient := p.getActiveClient(taskResult)
+	sharePath := utils.GetSharePath(name)
+	share, err := activeClient.GetNfsShareByPath(ctx, sharePath, p.getVStoreID(taskResult))
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get nfs share by path %s error: %v", sharePath, err)
+		return err
+	} else if share == nil {
+		return utils.KindErrorf(ctx, utils.ErrNotFound, "Nfs share %s of filesystem %s does not exist",
+			sharePath, fsName)
+	}
+
+	shareID, err := utils.GetStringField(share, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of nfs share %s error: %v", sharePath, err)
+	}
+	taskResult["shareID"] = shareID
+
+	params, err := p.getShareSquash(ctx, shareID, p.getVStoreID(taskResult), activeClient)
+	if err != nil {
+		return err
+	}
+
+	params["authclient"] = authClient
+	_, err = p.allowShareAccess(ctx, params, taskResult)
+	return err
+}
+
+// getShareSquash returns the all_squash, root_squash, anonymous IDs and security flavors of the current
+// clients of the share
+// as the parameters of the share access, or the defaults of the storage class without any client
+func (p *NAS) getShareSquash(ctx context.Context, shareID, vStoreID string,
+	cli client.BaseClientInterface) (map[string]interface{}, error) {
+	accesses, err := p.getCurrentShareAccess(ctx, shareID, vStoreID, cli)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get current access of share %s error: %v", shareID, err)
+		return nil, err
+	}
+
+	for _, i := range accesses {
+		access, _ := i.(map[string]interface{})
+		allSquashValue, allErr := strconv.Atoi(fmt.Sprint(access["ALLSQUASH"]))
+		rootSquashValue, rootErr := strconv.Atoi(fmt.Sprint(access["ROOTSQUASH"]))
+		if allErr != nil || rootErr != nil {
+			continue
+		}
+
+		params := map[string]interface{}{
+			"allsquash":  allSquashValue,
+			"rootsquash": rootSquashValue,
+		}
+		if anonUID, _ := access["ANONYMOUSUSERID"].(string); anonUID != "" {
+			params["anonuid"] = anonUID
+		}
+		if anonGID, _ := access["ANONYMOUSGROUPID"].(string); anonGID != "" {
+			params["anongid"] = anonGID
+		}
+		if flavors, _ := access["SECURITYTYPE"].(string); flavors != "" {
+			params["nfssecurityflavors"] = flavors
+		}
+		return params, nil
+	}
+	return map[string]interface{}{
+		"allsquash":  noAllSquash,
+		"rootsquash": noRootSquash,
+	}, nil
+}
@@ -0,0 +1,83 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"strconv"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// SmartTierPolicies are the relocation policies of SmartTier by the smarttier parameter, in the values of
+// DATATRANSFERPOLICY of the LUNs
+var SmartTierPolicies = map[string]int{
+	"none":      0,
+	"automatic": 1,
+	"highest":   2,
+	"lowest":    3,
+}
+
+// setSmartTierPolicy converts the smarttier parameter to the DATATRANSFERPOLICY of the LUN
+func (p *SAN) setSmartTierPolicy(ctx context.Context, params map[string]interface{}) error {
+	policy, exist := params["smarttier"].(string)
+	if !exist || policy == "" {
+		return nil
+	}
+
+	value, exist := SmartTierPolicies[policy]
+	if !exist {
+		return utils.Errorf(ctx, "invalid smarttier %s", policy)
+	}
+
+	params["datatransferpolicy"] = value
+	return nil
+}
+
+// UpdateSmartTier sets the SmartTier relocation policy of the LUN if it is different
+func (p *SAN) UpdateSmartTier(ctx context.Context, name, policy string) error {
+	value, exist := SmartTierPolicies[policy]
+	if !exist {
+		return utils.Errorf(ctx, "invalid smarttier %s", policy)
+	}
+
+	lunName := utils.GetLunName(name)
+	lun, err := p.cli.GetLunByName(ctx, lunName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun by name %s error: %v", lunName, err)
+		return err
+	}
+	if lun == nil {
+		return utils.KindErrorf(ctx, utils.ErrNotFound, "Lun %s to update SmartTier policy does not exist", lunName)
+	}
+
+	if lun["DATATRANSFERPOLICY"] == strconv.Itoa(value) {
+		return nil
+	}
+
+	lunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return utils.Errorf(ctx, "Get ID of lun %s error: %v", lunName, err)
+	}
+
+	err = p.cli.UpdateLun(ctx, lunID, map[string]interface{}{"DATATRANSFERPOLICY": value})
+	if err != nil {
+		return utils.Errorf(ctx, "Update SmartTier policy of lun %s to %s error: %v", lunName, policy, err)
+	}
+	return nil
+}
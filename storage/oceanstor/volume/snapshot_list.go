@@ -0,0 +1,229 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"strings"
+
+	"huawei-csi-driver/storage/oceanstor/client"
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// MaxSnapshotsPage is the max number of snapshots queried from storage at a time
+const MaxSnapshotsPage = 100
+
+// hyperCDPOffsetBase is the offset of the first HyperCDP object, which are listed after the LUN snapshots
+const hyperCDPOffsetBase = 1 << 30
+
+func getPageRange(offset, limit int) (int, int) {
+	if limit <= 0 || limit > MaxSnapshotsPage {
+		limit = MaxSnapshotsPage
+	}
+	return offset, offset + limit
+}
+
+// getNextOffset returns the offset of the next page, 0 if the page is the last one
+func getNextOffset(start, end, count int) int {
+	if count < end-start {
+		return 0
+	}
+	return end
+}
+
+// ListSnapshots returns a page of the snapshots created by the driver from the offset, of the LUN if lunName
+// is not empty or of all the LUNs otherwise, and the offset of the next page, 0 if no more snapshot exists.
+// The page may have fewer snapshots than the limit, as the snapshots not created by the driver are skipped.
+// On Dorado V6 the HyperCDP objects are listed after the LUN snapshots, from hyperCDPOffsetBase.
+func (p *SAN) ListSnapshots(ctx context.Context, lunName string, offset, limit int) (
+	[]map[string]interface{}, int, error) {
+	var parentID string
+	parents := make(map[string]map[string]interface{})
+	if lunName != "" {
+		lun, err := p.cli.GetLunByName(ctx, lunName)
+		if err != nil {
+			log.AddContext(ctx).Errorf("Get lun by name %s error: %v", lunName, err)
+			return nil, 0, err
+		}
+		if lun == nil {
+			log.AddContext(ctx).Infof("Lun %s does not exist, it has no snapshot", lunName)
+			return nil, 0, nil
+		}
+
+		parentID, err = utils.GetStringField(lun, "ID")
+		if err != nil {
+			return nil, 0, utils.Errorf(ctx, "Get ID of lun %s error: %v", lunName, err)
+		}
+		parents[parentID] = lun
+	}
+
+	if offset >= hyperCDPOffsetBase {
+		return p.listHyperCDPs(ctx, lunName, parentID, parents, offset, limit)
+	}
+
+	start, end := getPageRange(offset, limit)
+	snapshots, err := p.cli.GetLunSnapshotsByRange(ctx, parentID, start, end)
+	if err != nil {
+		log.AddContext(ctx).Errorf("List snapshots of lun %s error: %v", lunName, err)
+		return nil, 0, err
+	}
+
+	var infos []map[string]interface{}
+	for _, i := range snapshots {
+		snapshot, ok := i.(map[string]interface{})
+		if !ok || snapshot["DESCRIPTION"] != client.CSIDescription {
+			continue
+		}
+
+		snapshotParentID, _ := snapshot["PARENTID"].(string)
+		lun, err := p.getParentLun(ctx, parents, snapshotParentID)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		snapshotSize, err := utils.ParseSectors(snapshot, "USERCAPACITY")
+		if err != nil {
+			return nil, 0, utils.Errorf(ctx, "Get capacity of snapshot %s error: %v", snapshot["NAME"], err)
+		}
+		info := p.getSnapshotReturnInfo(snapshot, snapshotSize)
+		info["ParentName"], _ = snapshot["PARENTNAME"].(string)
+		info["ParentVolume"] = getParentVolume(lun)
+		info["Name"], _ = snapshot["NAME"].(string)
+		infos = append(infos, info)
+	}
+
+	next := getNextOffset(start, end, len(snapshots))
+	if next == 0 && p.product == utils.OceanStorDoradoV6 {
+		next = hyperCDPOffsetBase
+	}
+	return infos, next, nil
+}
+
+// getParentLun returns the parent LUN of the ID, which is queried once for the snapshots of a page
+func (p *SAN) getParentLun(ctx context.Context, parents map[string]map[string]interface{},
+	parentID string) (map[string]interface{}, error) {
+	if lun, exist := parents[parentID]; exist {
+		return lun, nil
+	}
+
+	lun, err := p.cli.GetLunByID(ctx, parentID)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get parent lun %s of snapshots error: %v", parentID, err)
+		return nil, err
+	}
+	parents[parentID] = lun
+	return lun, nil
+}
+
+// getParentVolume returns the name of the volume the parent LUN or filesystem was created for, which is
+// recorded in its description, empty if it was created without it
+func getParentVolume(parent map[string]interface{}) string {
+	description, _ := parent["DESCRIPTION"].(string)
+	return utils.GetVolumeNameOfDescription(description)
+}
+
+// listHyperCDPs returns a page of the HyperCDP objects created by the driver from the offset, whose
+// capacity and parent name are the ones of the parent LUN
+func (p *SAN) listHyperCDPs(ctx context.Context, lunName, parentID string,
+	parents map[string]map[string]interface{}, offset, limit int) ([]map[string]interface{}, int, error) {
+	start, end := getPageRange(offset-hyperCDPOffsetBase, limit)
+	hyperCDPs, err := p.cli.GetHyperCDPsByRange(ctx, parentID, start, end)
+	if err != nil {
+		log.AddContext(ctx).Errorf("List HyperCDP objects of lun %s error: %v", lunName, err)
+		return nil, 0, err
+	}
+
+	var infos []map[string]interface{}
+	for _, i := range hyperCDPs {
+		hyperCDP, ok := i.(map[string]interface{})
+		if !ok || hyperCDP["DESCRIPTION"] != client.CSIDescription {
+			continue
+		}
+
+		hyperCDPParentID, _ := hyperCDP["PARENTID"].(string)
+		lun, err := p.getParentLun(ctx, parents, hyperCDPParentID)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		snapshotSize, err := utils.ParseSectors(lun, "CAPACITY")
+		if err != nil {
+			return nil, 0, utils.Errorf(ctx, "Get capacity of lun %s error: %v", hyperCDPParentID, err)
+		}
+		info := p.getSnapshotReturnInfo(hyperCDP, snapshotSize)
+		info["ParentName"], _ = lun["NAME"].(string)
+		info["ParentVolume"] = getParentVolume(lun)
+		info["Name"], _ = hyperCDP["NAME"].(string)
+		infos = append(infos, info)
+	}
+
+	next := getNextOffset(start, end, len(hyperCDPs))
+	if next != 0 {
+		next += hyperCDPOffsetBase
+	}
+	return infos, next, nil
+}
+
+// ListSnapshots returns a page of the snapshots created by the driver of the filesystem from the offset, and
+// the offset of the next page, 0 if no more snapshot exists. The names of the snapshots are converted back to
+// the names they were requested with.
+func (p *NAS) ListSnapshots(ctx context.Context, fsName string, offset, limit int) (
+	[]map[string]interface{}, int, error) {
+	fs, err := p.cli.GetFileSystemByName(ctx, fsName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get filesystem by name %s error: %v", fsName, err)
+		return nil, 0, err
+	}
+	if fs == nil {
+		log.AddContext(ctx).Infof("Filesystem %s does not exist, it has no snapshot", fsName)
+		return nil, 0, nil
+	}
+
+	fsID, err := utils.GetStringField(fs, "ID")
+	if err != nil {
+		return nil, 0, utils.Errorf(ctx, "Get ID of filesystem %s error: %v", fsName, err)
+	}
+
+	start, end := getPageRange(offset, limit)
+	snapshots, err := p.cli.GetFSSnapshotsByRange(ctx, fsID, start, end)
+	if err != nil {
+		log.AddContext(ctx).Errorf("List snapshots of filesystem %s error: %v", fsName, err)
+		return nil, 0, err
+	}
+
+	snapshotSize, err := utils.ParseSectors(fs, "CAPACITY")
+	if err != nil {
+		return nil, 0, utils.Errorf(ctx, "Get capacity of filesystem %s error: %v", fsName, err)
+	}
+	var infos []map[string]interface{}
+	for _, i := range snapshots {
+		snapshot, ok := i.(map[string]interface{})
+		if !ok || snapshot["DESCRIPTION"] != client.CSIDescription {
+			continue
+		}
+
+		name, _ := snapshot["NAME"].(string)
+		info := p.getSnapshotReturnInfo(snapshot, snapshotSize)
+		info["ParentName"], _ = snapshot["PARENTNAME"].(string)
+		info["ParentVolume"] = getParentVolume(fs)
+		info["Name"] = strings.Replace(name, "_", "-", -1)
+		infos = append(infos, info)
+	}
+
+	return infos, getNextOffset(start, end, len(snapshots)), nil
+}
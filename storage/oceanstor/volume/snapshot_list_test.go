@@ -0,0 +1,53 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"huawei-csi-driver/storage/oceanstor/client"
+	"huawei-csi-driver/utils"
+)
+
+func TestListSnapshotsParentVolume(t *testing.T) {
+	volName := "pvc-6f2b1a7c-8e1d-4c3b-9a5f-0d2e4b6c8a10"
+	cli := newFakeClient()
+	cli.luns["1"] = map[string]interface{}{"ID": "1", "NAME": utils.GetLunName(volName),
+		"DESCRIPTION": utils.GetVolumeDescription(volName)}
+	cli.luns["2"] = map[string]interface{}{"ID": "2", "NAME": "lun02", "DESCRIPTION": "Created from Kubernetes CSI"}
+	cli.lunSnapshots = []interface{}{
+		map[string]interface{}{"NAME": "snapshot-1", "PARENTID": "1", "PARENTNAME": utils.GetLunName(volName),
+			"USERCAPACITY": "2048", "DESCRIPTION": client.CSIDescription},
+		map[string]interface{}{"NAME": "snapshot-2", "PARENTID": "2", "PARENTNAME": "lun02",
+			"USERCAPACITY": "2048", "DESCRIPTION": client.CSIDescription},
+	}
+	san := NewSAN(cli, nil, nil, "")
+
+	snapshots, next, err := san.ListSnapshots(ctx, "", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, next)
+	assert.Len(t, snapshots, 2)
+	assert.Equal(t, volName, snapshots[0]["ParentVolume"])
+	assert.Equal(t, "", snapshots[1]["ParentVolume"])
+
+	snapshots, _, err = san.ListSnapshots(ctx, utils.GetLunName(volName), 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 1)
+	assert.Equal(t, volName, snapshots[0]["ParentVolume"])
+}
@@ -0,0 +1,185 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"encoding/json"
+
+	"huawei-csi-driver/utils"
+	"huawei-csi-driver/utils/log"
+)
+
+// RollbackSnapshot starts rolling the LUN back to its snapshot in place, and returns the capacity
+// the LUN had when the snapshot was taken. The LUNs of HyperMetro and replication pairs
+// are refused, as the rollback would make the pairs inconsistent.
+func (p *SAN) RollbackSnapshot(ctx context.Context, lunName, parentID, snapshotName string, speed int) (
+	utils.Capacity, error) {
+	lun, err := p.cli.GetLunByName(ctx, lunName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun by name %s error: %v", lunName, err)
+		return 0, err
+	}
+	if lun == nil {
+		return 0, utils.KindErrorf(ctx, utils.ErrNotFound, "Lun %s to roll back does not exist", lunName)
+	}
+
+	lunID, err := utils.GetStringField(lun, "ID")
+	if err != nil {
+		return 0, utils.Errorf(ctx, "Get ID of lun %s error: %v", lunName, err)
+	}
+	if lunID != parentID {
+		return 0, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"Snapshot %s is not a snapshot of lun %s", snapshotName, lunName)
+	}
+
+	var rss map[string]string
+	rssStr, _ := lun["HASRSSOBJECT"].(string)
+	_ = json.Unmarshal([]byte(rssStr), &rss)
+	if rss["HyperMetro"] == "TRUE" || rss["RemoteReplication"] == "TRUE" {
+		return 0, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"Lun %s is in HyperMetro or replication pairs, it cannot be rolled back in place", lunName)
+	}
+
+	snapshot, err := p.cli.GetLunSnapshotByName(ctx, snapshotName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun snapshot by name %s error: %v", snapshotName, err)
+		return 0, err
+	}
+	if snapshot == nil || snapshot["PARENTID"] != lunID {
+		return 0, utils.KindErrorf(ctx, utils.ErrNotFound,
+			"Snapshot %s of lun %s to roll back to does not exist", snapshotName, lunName)
+	}
+
+	snapshotID, err := utils.GetStringField(snapshot, "ID")
+	if err != nil {
+		return 0, utils.Errorf(ctx, "Get ID of lun snapshot %s error: %v", snapshotName, err)
+	}
+	snapshotSize, err := utils.ParseSectors(snapshot, "USERCAPACITY")
+	if err != nil {
+		return 0, utils.Errorf(ctx, "Get capacity of snapshot %s error: %v", snapshotName, err)
+	}
+
+	if snapshot["RUNNINGSTATUS"] == snapshotRunningStatusRollingBack {
+		log.AddContext(ctx).Infof("Lun %s is already rolling back to snapshot %s", lunName, snapshotName)
+		return snapshotSize, nil
+	}
+
+	err = p.cli.RollbackLunSnapshot(ctx, snapshotID, speed)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Roll back lun %s to snapshot %s error: %v", lunName, snapshotName, err)
+		return 0, err
+	}
+
+	log.AddContext(ctx).Infof("Lun %s starts rolling back to snapshot %s", lunName, snapshotName)
+	return snapshotSize, nil
+}
+
+// IsRollingBack returns whether a LUN is still rolling back to the snapshot
+func (p *SAN) IsRollingBack(ctx context.Context, snapshotName string) (bool, error) {
+	snapshot, err := p.cli.GetLunSnapshotByName(ctx, snapshotName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get lun snapshot by name %s error: %v", snapshotName, err)
+		return false, err
+	}
+	if snapshot == nil {
+		return false, utils.KindErrorf(ctx, utils.ErrNotFound, "Snapshot %s rolling back does not exist", snapshotName)
+	}
+
+	return snapshot["RUNNINGSTATUS"] == snapshotRunningStatusRollingBack, nil
+}
+
+// RollbackSnapshot starts rolling the filesystem back to its snapshot in place, and returns the
+// capacity of the filesystem, which the rollback keeps. The filesystems of HyperMetro and
+// replication pairs are refused, as the rollback would make the pairs inconsistent.
+func (p *NAS) RollbackSnapshot(ctx context.Context, fsName, parentID, snapshotName string, speed int) (
+	utils.Capacity, error) {
+	fs, err := p.cli.GetFileSystemByName(ctx, fsName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get filesystem by name %s error: %v", fsName, err)
+		return 0, err
+	}
+	if fs == nil {
+		return 0, utils.KindErrorf(ctx, utils.ErrNotFound, "Filesystem %s to roll back does not exist", fsName)
+	}
+
+	fsID, err := utils.GetStringField(fs, "ID")
+	if err != nil {
+		return 0, utils.Errorf(ctx, "Get ID of filesystem %s error: %v", fsName, err)
+	}
+	if fsID != parentID {
+		return 0, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+			"Snapshot %s is not a snapshot of filesystem %s", snapshotName, fsName)
+	}
+
+	for _, pairField := range []string{"HYPERMETROPAIRIDS", "REMOTEREPLICATIONIDS"} {
+		var pairIDs []string
+		pairIDStr, _ := fs[pairField].(string)
+		_ = json.Unmarshal([]byte(pairIDStr), &pairIDs)
+		if len(pairIDs) > 0 {
+			return 0, utils.KindErrorf(ctx, utils.ErrFailedPrecondition,
+				"Filesystem %s is in pairs %v, it cannot be rolled back in place", fsName, pairIDs)
+		}
+	}
+
+	snapshot, err := p.cli.GetFSSnapshotByName(ctx, fsID, snapshotName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get filesystem snapshot by name %s error: %v", snapshotName, err)
+		return 0, err
+	}
+	if snapshot == nil {
+		return 0, utils.KindErrorf(ctx, utils.ErrNotFound,
+			"Snapshot %s of filesystem %s to roll back to does not exist", snapshotName, fsName)
+	}
+
+	snapshotID, err := utils.GetStringField(snapshot, "ID")
+	if err != nil {
+		return 0, utils.Errorf(ctx, "Get ID of filesystem snapshot %s error: %v", snapshotName, err)
+	}
+	fsSize, err := utils.ParseSectors(fs, "CAPACITY")
+	if err != nil {
+		return 0, utils.Errorf(ctx, "Get capacity of filesystem %s error: %v", fsName, err)
+	}
+
+	if snapshot["RUNNINGSTATUS"] == snapshotRunningStatusRollingBack {
+		log.AddContext(ctx).Infof("Filesystem %s is already rolling back to snapshot %s", fsName, snapshotName)
+		return fsSize, nil
+	}
+
+	err = p.cli.RollbackFSSnapshot(ctx, snapshotID, speed)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Roll back filesystem %s to snapshot %s error: %v", fsName, snapshotName, err)
+		return 0, err
+	}
+
+	log.AddContext(ctx).Infof("Filesystem %s starts rolling back to snapshot %s", fsName, snapshotName)
+	return fsSize, nil
+}
+
+// IsRollingBack returns whether the filesystem is still rolling back to the snapshot
+func (p *NAS) IsRollingBack(ctx context.Context, parentID, snapshotName string) (bool, error) {
+	snapshot, err := p.cli.GetFSSnapshotByName(ctx, parentID, snapshotName)
+	if err != nil {
+		log.AddContext(ctx).Errorf("Get filesystem snapshot by name %s error: %v", snapshotName, err)
+		return false, err
+	}
+	if snapshot == nil {
+		return false, utils.KindErrorf(ctx, utils.ErrNotFound, "Snapshot %s rolling back does not exist", snapshotName)
+	}
+
+	return snapshot["RUNNINGSTATUS"] == snapshotRunningStatusRollingBack, nil
+}
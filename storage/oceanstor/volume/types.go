@@ -17,6 +17,8 @@
 // Package volume defines the required struct
 package volume
 
+import "huawei-csi-driver/utils"
+
 type CloneFilesystemRequest struct {
 	FsName               string
 	VStoreId             string
@@ -24,7 +26,7 @@ type CloneFilesystemRequest struct {
 	ParentSnapshotID     string
 	AllocType            int
 	CloneSpeed           int
-	CloneFsCapacity      int64
-	SrcCapacity          int64
+	CloneFsCapacity      utils.Capacity
+	SrcCapacity          utils.Capacity
 	DeleteParentSnapshot bool
 }
@@ -0,0 +1,120 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package volume
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"huawei-csi-driver/storage/oceanstor/client"
+	"huawei-csi-driver/utils/log"
+)
+
+const (
+	logName = "volumeTest.log"
+	logDir  = "/var/log/huawei"
+)
+
+var ctx = context.Background()
+
+// fakeClient overrides the client methods used by a test, calling any other method panics
+type fakeClient struct {
+	client.BaseClientInterface
+	luns         map[string]map[string]interface{}
+	clonePairs   map[string]map[string]interface{}
+	snapshots    map[string]map[string]interface{}
+	lunSnapshots []interface{}
+	deletedLuns  []string
+	updatedLuns  map[string]map[string]interface{}
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		luns:        map[string]map[string]interface{}{},
+		clonePairs:  map[string]map[string]interface{}{},
+		snapshots:   map[string]map[string]interface{}{},
+		updatedLuns: map[string]map[string]interface{}{},
+	}
+}
+
+func (c *fakeClient) GetLunByName(_ context.Context, name string) (map[string]interface{}, error) {
+	for _, lun := range c.luns {
+		if lun["NAME"] == name {
+			return lun, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *fakeClient) GetLunByID(_ context.Context, id string) (map[string]interface{}, error) {
+	return c.luns[id], nil
+}
+
+func (c *fakeClient) DeleteLun(_ context.Context, id string) error {
+	delete(c.luns, id)
+	c.deletedLuns = append(c.deletedLuns, id)
+	return nil
+}
+
+func (c *fakeClient) UpdateLun(_ context.Context, id string, params map[string]interface{}) error {
+	c.updatedLuns[id] = params
+	return nil
+}
+
+func (c *fakeClient) GetClonePairInfo(_ context.Context, id string) (map[string]interface{}, error) {
+	return c.clonePairs[id], nil
+}
+
+func (c *fakeClient) GetLunSnapshotByName(_ context.Context, name string) (map[string]interface{}, error) {
+	return c.snapshots[name], nil
+}
+
+func (c *fakeClient) GetLunSnapshotsByRange(_ context.Context, parentID string, start, end int) (
+	[]interface{}, error) {
+	var snapshots []interface{}
+	for _, i := range c.lunSnapshots {
+		snapshot, _ := i.(map[string]interface{})
+		if parentID == "" || snapshot["PARENTID"] == parentID {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	if start >= len(snapshots) {
+		return nil, nil
+	}
+	if end > len(snapshots) {
+		end = len(snapshots)
+	}
+	return snapshots[start:end], nil
+}
+
+func TestMain(m *testing.M) {
+	if err := log.InitLogging(logName); err != nil {
+		log.Errorf("Init logging: %s failed. error: %v", logName, err)
+		os.Exit(1)
+	}
+
+	logFile := path.Join(logDir, logName)
+	defer func() {
+		if err := os.RemoveAll(logFile); err != nil {
+			log.Errorf("Remove file: %s failed. error: %s", logFile, err)
+		}
+	}()
+
+	m.Run()
+}
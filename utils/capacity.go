@@ -0,0 +1,80 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package utils
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// SectorSize is the size in bytes of the sectors in which storage counts the capacities
+const SectorSize int64 = 512
+
+// Capacity is a size in bytes. The capacities of storage objects are in sectors, which are converted
+// by Capacity only, so that they are rounded the same way everywhere.
+type Capacity int64
+
+// CapacityFromSectors returns the capacity of a number of sectors
+func CapacityFromSectors(sectors int64) Capacity {
+	return Capacity(sectors * SectorSize)
+}
+
+// ParseSectors returns the capacity of a field in sectors of a storage object. A missing or invalid
+// field is an error rather than a capacity of 0.
+func ParseSectors(obj map[string]interface{}, field string) (Capacity, error) {
+	value, exist := obj[field]
+	if !exist || value == nil {
+		return 0, fmt.Errorf("capacity field %s doesn't exist", field)
+	}
+
+	var sectors int64
+	var err error
+	switch v := value.(type) {
+	case float64:
+		// the numbers in the responses are decoded as float64, which fmt prints with an exponent
+		sectors = int64(v)
+		if float64(sectors) != v {
+			err = fmt.Errorf("%v is not a whole number", v)
+		}
+	default:
+		sectors, err = strconv.ParseInt(fmt.Sprint(v), 10, 64)
+	}
+	if err != nil || sectors < 0 {
+		return 0, fmt.Errorf("capacity field %s is %v, not a number of sectors", field, value)
+	}
+	return CapacityFromSectors(sectors), nil
+}
+
+// Bytes returns the capacity in bytes
+func (c Capacity) Bytes() int64 {
+	return int64(c)
+}
+
+// Sectors returns the capacity in sectors, a partial sector is rounded up to a whole one
+func (c Capacity) Sectors() int64 {
+	return RoundUpSize(int64(c), SectorSize)
+}
+
+// IsSectorAligned returns whether the capacity is a whole number of sectors
+func (c Capacity) IsSectorAligned() bool {
+	return IsCapacityAvailable(int64(c), SectorSize)
+}
+
+// RoundUp returns the capacity rounded up to a multiple of the allocation unit in bytes
+func (c Capacity) RoundUp(unit int64) Capacity {
+	return Capacity(RoundUpSize(int64(c), unit) * unit)
+}
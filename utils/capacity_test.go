@@ -0,0 +1,54 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSectors(t *testing.T) {
+	capacity, err := ParseSectors(map[string]interface{}{"CAPACITY": "2097152"}, "CAPACITY")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024*1024*1024), capacity.Bytes())
+	assert.Equal(t, int64(2097152), capacity.Sectors())
+
+	capacity, err = ParseSectors(map[string]interface{}{"CAPACITY": float64(2097152)}, "CAPACITY")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024*1024*1024), capacity.Bytes())
+
+	for _, obj := range []map[string]interface{}{
+		{},
+		{"CAPACITY": nil},
+		{"CAPACITY": ""},
+		{"CAPACITY": "1G"},
+		{"CAPACITY": "-1"},
+		{"CAPACITY": 1.5},
+	} {
+		_, err = ParseSectors(obj, "CAPACITY")
+		assert.Error(t, err, "%v", obj)
+	}
+}
+
+func TestCapacityRounding(t *testing.T) {
+	assert.Equal(t, int64(3), Capacity(1025).Sectors())
+	assert.False(t, Capacity(1025).IsSectorAligned())
+	assert.True(t, Capacity(1024).IsSectorAligned())
+	assert.Equal(t, Capacity(8192), Capacity(4097).RoundUp(4096))
+	assert.Equal(t, Capacity(4096), Capacity(4096).RoundUp(4096))
+}
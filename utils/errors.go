@@ -0,0 +1,58 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2022-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"huawei-csi-driver/utils/log"
+)
+
+// The kinds of errors which are translated to specific gRPC codes by the CSI driver,
+// an error is of a kind if it wraps one of them, check it with errors.Is
+var (
+	// ErrVolumeConflict indicates a volume with the same name already exists but is incompatible
+	// with the requested parameters
+	ErrVolumeConflict = errors.New("volume already exists with incompatible parameters")
+	// ErrNotFound indicates the object to operate on does not exist
+	ErrNotFound = errors.New("object not found")
+	// ErrResourceExhausted indicates there is no enough capacity or quota on storage
+	ErrResourceExhausted = errors.New("resource exhausted")
+	// ErrFailedPrecondition indicates the object is not in a state the operation requires
+	ErrFailedPrecondition = errors.New("failed precondition")
+	// ErrTimeout indicates waiting for an operation on storage timed out
+	ErrTimeout = errors.New("operation timeout")
+	// ErrPoolReserveReached indicates the storage pools have capacity, but it is kept free by the
+	// configured reserve
+	ErrPoolReserveReached = errors.New("pool reserve reached")
+	// ErrCopyInProgress indicates the volume is created, but its data is still being copied from its source
+	ErrCopyInProgress = errors.New("copy in progress")
+)
+
+// KindErrorf used to log and return an error of the given kind
+func KindErrorf(ctx context.Context, kind error, format string, a ...interface{}) error {
+	msg := fmt.Sprintf(format, a...)
+	log.AddContext(ctx).Errorln(msg)
+	return fmt.Errorf("%w: %s", kind, msg)
+}
+
+// VolumeConflictf used to log and return an error wrapping ErrVolumeConflict
+func VolumeConflictf(ctx context.Context, format string, a ...interface{}) error {
+	return KindErrorf(ctx, ErrVolumeConflict, format, a...)
+}
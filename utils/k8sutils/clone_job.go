@@ -0,0 +1,145 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package k8sutils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// cloneJobPath is the API path of the clone jobs, which are cluster scoped
+const cloneJobPath = "/apis/csi.huawei.com/v1/clonejobs"
+
+const (
+	// CloneJobCopying is the phase of a clone job whose data is being copied
+	CloneJobCopying = "Copying"
+	// CloneJobFailed is the phase of a clone job whose copy failed at the last check
+	CloneJobFailed = "Failed"
+)
+
+// CloneJob is a copy from a volume or snapshot to a new volume, which is tracked by the controller
+// until the data is copied, so that it survives restarts of the controller
+type CloneJob struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec   CloneJobSpec   `json:"spec"`
+	Status CloneJobStatus `json:"status,omitempty"`
+}
+
+// CloneJobSpec is the volume being copied and the PVC it is created for
+type CloneJobSpec struct {
+	// VolumeHandle is the handle of the volume the data is copied to
+	VolumeHandle string `json:"volumeHandle"`
+	// ClaimNamespace and ClaimName are of the PVC the volume is created for, empty if unknown
+	ClaimNamespace string `json:"claimNamespace,omitempty"`
+	ClaimName      string `json:"claimName,omitempty"`
+	// FastClone is true if the volume was returned before its data is copied
+	FastClone bool `json:"fastClone,omitempty"`
+}
+
+// CloneJobStatus is the progress of a clone job
+type CloneJobStatus struct {
+	// Phase is Copying or Failed, empty before the first check
+	Phase string `json:"phase,omitempty"`
+	// Message is the error of the last check
+	Message string `json:"message,omitempty"`
+}
+
+// CloneJobName returns the name of the clone job of the volume handle, the handle is hashed as it may
+// not be a valid object name
+func CloneJobName(volumeHandle string) string {
+	return fmt.Sprintf("clone-%x", sha256.Sum256([]byte(volumeHandle)))[:38]
+}
+
+// ListCloneJobs returns the clone jobs
+func (k *kubeClient) ListCloneJobs(ctx context.Context) ([]CloneJob, error) {
+	data, err := k.clientSet.RESTClient().Get().AbsPath(cloneJobPath).DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clone jobs. %s", err)
+	}
+
+	var list struct {
+		Items []CloneJob `json:"items"`
+	}
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse clone jobs. %s", err)
+	}
+
+	return list.Items, nil
+}
+
+// CreateCloneJob creates the clone job of the volume handle, an existing one is kept
+func (k *kubeClient) CreateCloneJob(ctx context.Context, spec CloneJobSpec) error {
+	job := CloneJob{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "csi.huawei.com/v1", Kind: "CloneJob"},
+		ObjectMeta: metav1.ObjectMeta{Name: CloneJobName(spec.VolumeHandle)},
+		Spec:       spec,
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode clone job of volume %s. %s", spec.VolumeHandle, err)
+	}
+
+	_, err = k.clientSet.RESTClient().Post().
+		AbsPath(cloneJobPath).
+		SetHeader("Content-Type", "application/json").
+		Body(data).
+		DoRaw(ctx)
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create clone job of volume %s. %s", spec.VolumeHandle, err)
+	}
+
+	return nil
+}
+
+// UpdateCloneJobStatus updates the status of the clone job
+func (k *kubeClient) UpdateCloneJobStatus(ctx context.Context, job *CloneJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode clone job %s. %s", job.Name, err)
+	}
+
+	data, err = k.clientSet.RESTClient().Put().
+		AbsPath(cloneJobPath, job.Name, "status").
+		SetHeader("Content-Type", "application/json").
+		Body(data).
+		DoRaw(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update clone job %s. %s", job.Name, err)
+	}
+
+	return json.Unmarshal(data, job)
+}
+
+// DeleteCloneJob deletes the clone job of the volume handle, it is not an error if it doesn't exist
+func (k *kubeClient) DeleteCloneJob(ctx context.Context, volumeHandle string) error {
+	_, err := k.clientSet.RESTClient().Delete().
+		AbsPath(cloneJobPath, CloneJobName(volumeHandle)).
+		DoRaw(ctx)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete clone job of volume %s. %s", volumeHandle, err)
+	}
+
+	return nil
+}
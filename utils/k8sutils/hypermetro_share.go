@@ -0,0 +1,92 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package k8sutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// hyperMetroSharePath is the API path of the HyperMetro shares
+const hyperMetroSharePath = "/apis/csi.huawei.com/v1/hypermetroshares"
+
+const (
+	// SingleWriterPolicy allows a single cluster to stage the volume writable at a time
+	SingleWriterPolicy = "SingleWriter"
+	// DRPassivePolicy allows only the cluster of the Active role to stage the volume writable, the
+	// cluster of the Passive role stages it read-only
+	DRPassivePolicy = "DRPassive"
+
+	// ActiveRole is the role of the cluster running the application on a DRPassive volume
+	ActiveRole = "Active"
+	// PassiveRole is the role of the standby cluster on a DRPassive volume
+	PassiveRole = "Passive"
+)
+
+// HyperMetroShare coordinates a HyperMetro volume exposed to the clusters of both sites. Each cluster
+// creates one for its PV of the volume.
+type HyperMetroShare struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec HyperMetroShareSpec `json:"spec"`
+}
+
+// HyperMetroShareSpec is the sharing policy of a HyperMetro volume
+type HyperMetroShareSpec struct {
+	// VolumeHandle is the volume handle of the PV of the volume in this cluster
+	VolumeHandle string `json:"volumeHandle"`
+	// Policy is SingleWriter or DRPassive
+	Policy string `json:"policy"`
+	// Role is the role of this cluster on a DRPassive volume, Active or Passive
+	Role string `json:"role,omitempty"`
+}
+
+// GetHyperMetroShare returns the HyperMetro share of the volume handle, nil if the volume is not shared
+func (k *kubeClient) GetHyperMetroShare(ctx context.Context, volumeHandle string) (*HyperMetroShare, error) {
+	data, err := k.clientSet.RESTClient().Get().AbsPath(hyperMetroSharePath).DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hypermetro shares. %s", err)
+	}
+
+	var list struct {
+		Items []HyperMetroShare `json:"items"`
+	}
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse hypermetro shares. %s", err)
+	}
+
+	for i := range list.Items {
+		if list.Items[i].Spec.VolumeHandle == volumeHandle {
+			return &list.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// IsClusterHost returns whether the host is a node of this cluster
+func (k *kubeClient) IsClusterHost(ctx context.Context, hostName string) (bool, error) {
+	node, err := k.getNodeByHostName(ctx, hostName)
+	if err != nil {
+		return false, err
+	}
+	return node != nil, nil
+}
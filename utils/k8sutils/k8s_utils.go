@@ -19,6 +19,7 @@ package k8sutils
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"regexp"
@@ -26,6 +27,7 @@ import (
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -42,6 +44,7 @@ const (
 	topologyRegx           = TopologyPrefix + "/.*"
 	// Interval (in miliseconds) between pod get retry with k8s
 	podRetryInterval = 10
+	hostNameLabel    = "kubernetes.io/hostname"
 )
 
 // Interface is a kubernetes utility interface required by CSI plugin to interact with Kubernetes
@@ -54,6 +57,115 @@ type Interface interface {
 
 	// GetVolumeAttributes returns volume attributes of PV
 	GetVolumeAttributes(ctx context.Context, pvName string) (map[string]string, error)
+
+	// GetNodeNotReadyTime returns since when the node has not been ready, nil if the node is ready
+	GetNodeNotReadyTime(ctx context.Context, hostName string) (*time.Time, error)
+
+	// ListBoundVolumes returns the bound PVs provisioned by the driver
+	ListBoundVolumes(ctx context.Context, driverName string) ([]PVInfo, error)
+
+	// GetNetworkAttachmentMaster returns the node interface of a network attachment definition
+	GetNetworkAttachmentMaster(ctx context.Context, namespace, name string) (string, error)
+
+	// GetClaimVolumeHandle returns the volume handle of the PV bound to the PVC
+	GetClaimVolumeHandle(ctx context.Context, driverName, namespace, claimName string) (string, error)
+
+	// ListProtectionGroups returns the protection groups of all namespaces
+	ListProtectionGroups(ctx context.Context) ([]ProtectionGroup, error)
+
+	// UpdateProtectionGroup updates the protection group, and its status if status is true
+	UpdateProtectionGroup(ctx context.Context, group *ProtectionGroup, status bool) error
+
+	// GetClaim returns the PVC
+	GetClaim(ctx context.Context, namespace, claimName string) (*corev1.PersistentVolumeClaim, error)
+
+	// RecordClaimEvent records an event of the PVC
+	RecordClaimEvent(ctx context.Context, namespace, claimName, eventType, reason, message string) error
+
+	// RecordNodeEvent records an event of the node
+	RecordNodeEvent(ctx context.Context, nodeName, eventType, reason, message string) error
+
+	// GetSnapshotAnnotations returns the annotations of the VolumeSnapshot
+	GetSnapshotAnnotations(ctx context.Context, namespace, snapshotName string) (map[string]string, error)
+
+	// GetHyperMetroShare returns the HyperMetro share of the volume handle, nil if the volume is not shared
+	GetHyperMetroShare(ctx context.Context, volumeHandle string) (*HyperMetroShare, error)
+
+	// IsClusterHost returns whether the host is a node of this cluster
+	IsClusterHost(ctx context.Context, hostName string) (bool, error)
+
+	// ListVolumeShrinks returns the volume shrinks of all namespaces
+	ListVolumeShrinks(ctx context.Context) ([]VolumeShrink, error)
+
+	// UpdateVolumeShrinkStatus updates the status of the volume shrink
+	UpdateVolumeShrinkStatus(ctx context.Context, shrink *VolumeShrink) error
+
+	// ListVolumeFailovers returns the volume failovers of all namespaces
+	ListVolumeFailovers(ctx context.Context) ([]VolumeFailover, error)
+
+	// UpdateVolumeFailoverStatus updates the status of the volume failover
+	UpdateVolumeFailoverStatus(ctx context.Context, failover *VolumeFailover) error
+
+	// UpdateClaimVolumeCapacity sets the capacity in the spec of the PV bound to the PVC
+	UpdateClaimVolumeCapacity(ctx context.Context, namespace, claimName string, capacity int64) error
+
+	// GetClaimVolumeAttributes returns the volume attributes of the PV bound to the PVC
+	GetClaimVolumeAttributes(ctx context.Context, namespace, claimName string) (map[string]string, error)
+
+	// ListVolumeRestores returns the volume restores of all namespaces
+	ListVolumeRestores(ctx context.Context) ([]VolumeRestore, error)
+
+	// UpdateVolumeRestoreStatus updates the status of the volume restore
+	UpdateVolumeRestoreStatus(ctx context.Context, restore *VolumeRestore) error
+
+	// GetSnapshotHandle returns the snapshot handle of the VolumeSnapshot
+	GetSnapshotHandle(ctx context.Context, namespace, snapshotName string) (string, error)
+
+	// GetWorkloadReplicas returns the replicas of the workload
+	GetWorkloadReplicas(ctx context.Context, namespace string, workload WorkloadReference) (int32, error)
+
+	// ScaleWorkload sets the replicas of the workload, and returns the replicas before
+	ScaleWorkload(ctx context.Context, namespace string, workload WorkloadReference, replicas int32) (int32, error)
+
+	// IsWorkloadUsingClaim returns whether the pods of the workload use the PVC
+	IsWorkloadUsingClaim(ctx context.Context, namespace string, workload WorkloadReference, claimName string) (
+		bool, error)
+
+	// IsClaimVolumeAttached returns whether the PV bound to the PVC is attached to any node
+	IsClaimVolumeAttached(ctx context.Context, namespace, claimName string) (bool, error)
+
+	// ListCloneJobs returns the clone jobs
+	ListCloneJobs(ctx context.Context) ([]CloneJob, error)
+
+	// CreateCloneJob creates the clone job of the volume, an existing one is kept
+	CreateCloneJob(ctx context.Context, spec CloneJobSpec) error
+
+	// UpdateCloneJobStatus updates the status of the clone job
+	UpdateCloneJobStatus(ctx context.Context, job *CloneJob) error
+
+	// DeleteCloneJob deletes the clone job of the volume handle if it exists
+	DeleteCloneJob(ctx context.Context, volumeHandle string) error
+
+	// ListVolumeMigrations returns the volume migrations of all namespaces
+	ListVolumeMigrations(ctx context.Context) ([]VolumeMigration, error)
+
+	// UpdateVolumeMigrationStatus updates the status of the volume migration
+	UpdateVolumeMigrationStatus(ctx context.Context, migration *VolumeMigration) error
+
+	// UpdateClaimVolumeAnnotation sets the annotation of the PV bound to the PVC
+	UpdateClaimVolumeAnnotation(ctx context.Context, namespace, claimName, key, value string) error
+}
+
+// PVInfo is the CSI related information of a PV
+type PVInfo struct {
+	Name         string
+	VolumeHandle string
+	// Capacity is the capacity in bytes in the PV spec
+	Capacity   int64
+	Attributes map[string]string
+	// ClaimNamespace and ClaimName are of the PVC the PV is bound to
+	ClaimNamespace string
+	ClaimName      string
 }
 
 type kubeClient struct {
@@ -188,6 +300,109 @@ func (k *kubeClient) getPods(ctx context.Context, nodeName string) (*corev1.PodL
 	return podList, err
 }
 
+// GetClaim returns the PVC
+func (k *kubeClient) GetClaim(ctx context.Context, namespace, claimName string) (
+	*corev1.PersistentVolumeClaim, error) {
+	pvc, err := k.clientSet.CoreV1().
+		PersistentVolumeClaims(namespace).
+		Get(ctx, claimName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve pvc %s/%s. %s", namespace, claimName, err)
+	}
+	return pvc, nil
+}
+
+// GetSnapshotAnnotations returns the annotations of the VolumeSnapshot
+func (k *kubeClient) GetSnapshotAnnotations(ctx context.Context, namespace, snapshotName string) (
+	map[string]string, error) {
+	data, err := k.clientSet.RESTClient().Get().
+		AbsPath(fmt.Sprintf(volumeSnapshotPath, namespace, snapshotName)).
+		DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get volume snapshot %s/%s. %s", namespace, snapshotName, err)
+	}
+
+	var snapshot struct {
+		metav1.ObjectMeta `json:"metadata"`
+	}
+	err = json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse volume snapshot %s/%s. %s", namespace, snapshotName, err)
+	}
+	return snapshot.Annotations, nil
+}
+
+// RecordClaimEvent records an event of the PVC, which is shown by kubectl describe
+func (k *kubeClient) RecordClaimEvent(ctx context.Context, namespace, claimName, eventType, reason,
+	message string) error {
+	pvc, err := k.GetClaim(ctx, namespace, claimName)
+	if err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: claimName + ".",
+			Namespace:    namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:            "PersistentVolumeClaim",
+			Namespace:       namespace,
+			Name:            claimName,
+			UID:             pvc.UID,
+			APIVersion:      "v1",
+			ResourceVersion: pvc.ResourceVersion,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         corev1.EventSource{Component: eventComponent},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	_, err = k.clientSet.CoreV1().Events(namespace).Create(ctx, event, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to record event %s of pvc %s/%s. %s", reason, namespace, claimName, err)
+	}
+	return nil
+}
+
+// RecordNodeEvent records an event of the node, which is shown by kubectl describe
+func (k *kubeClient) RecordNodeEvent(ctx context.Context, nodeName, eventType, reason, message string) error {
+	k8sNode, err := k.getNode(ctx, nodeName)
+	if err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: nodeName + ".",
+			Namespace:    metav1.NamespaceDefault,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:       "Node",
+			Name:       nodeName,
+			UID:        k8sNode.UID,
+			APIVersion: "v1",
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         corev1.EventSource{Component: eventComponent, Host: nodeName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	_, err = k.clientSet.CoreV1().Events(metav1.NamespaceDefault).Create(ctx, event, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to record event %s of node %s. %s", reason, nodeName, err)
+	}
+	return nil
+}
+
 func (k *kubeClient) getPVByPVCName(ctx context.Context, namespace string,
 	claimName string) (*corev1.PersistentVolume, error) {
 	pvc, err := k.clientSet.CoreV1().
@@ -224,3 +439,152 @@ func (k *kubeClient) GetVolumeAttributes(ctx context.Context, pvName string) (ma
 
 	return pv.Spec.CSI.VolumeAttributes, nil
 }
+
+// GetNodeNotReadyTime looks up the node by name or by its hostname label, and returns the time its
+// Ready condition turned to False or Unknown. A node which no longer exists is regarded as lost
+// since ever, and a zero time is returned.
+func (k *kubeClient) GetNodeNotReadyTime(ctx context.Context, hostName string) (*time.Time, error) {
+	k8sNode, err := k.getNodeByHostName(ctx, hostName)
+	if err != nil {
+		return nil, err
+	}
+	if k8sNode == nil {
+		log.AddContext(ctx).Warningf("Node with hostname %s doesn't exist", hostName)
+		return &time.Time{}, nil
+	}
+
+	for _, condition := range k8sNode.Status.Conditions {
+		if condition.Type != corev1.NodeReady {
+			continue
+		}
+		if condition.Status == corev1.ConditionTrue {
+			return nil, nil
+		}
+
+		notReadyTime := condition.LastTransitionTime.Time
+		return &notReadyTime, nil
+	}
+
+	// The Ready condition is not reported yet, the node is regarded as ready
+	return nil, nil
+}
+
+func (k *kubeClient) getNodeByHostName(ctx context.Context, hostName string) (*corev1.Node, error) {
+	k8sNode, err := k.getNode(ctx, hostName)
+	if err == nil {
+		return k8sNode, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	nodes, err := k.clientSet.CoreV1().Nodes().List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", hostNameLabel, hostName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes.Items) == 0 {
+		return nil, nil
+	}
+
+	return &nodes.Items[0], nil
+}
+
+// ListBoundVolumes returns the bound PVs provisioned by the driver, the PVs being deleted are skipped
+func (k *kubeClient) ListBoundVolumes(ctx context.Context, driverName string) ([]PVInfo, error) {
+	pvList, err := k.clientSet.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve pv list. %s", err)
+	}
+
+	var volumes []PVInfo
+	for _, pv := range pvList.Items {
+		csiSource := pv.Spec.PersistentVolumeSource.CSI
+		if csiSource == nil || csiSource.Driver != driverName ||
+			pv.Status.Phase != corev1.VolumeBound || pv.DeletionTimestamp != nil {
+			continue
+		}
+
+		capacity := pv.Spec.Capacity[corev1.ResourceStorage]
+		info := PVInfo{
+			Name:         pv.Name,
+			VolumeHandle: csiSource.VolumeHandle,
+			Capacity:     capacity.Value(),
+			Attributes:   csiSource.VolumeAttributes,
+		}
+		if pv.Spec.ClaimRef != nil {
+			info.ClaimNamespace, info.ClaimName = pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name
+		}
+		volumes = append(volumes, info)
+	}
+
+	return volumes, nil
+}
+
+// networkAttachmentPath is the API path of the Multus network attachment definitions
+const networkAttachmentPath = "/apis/k8s.cni.cncf.io/v1/namespaces/%s/network-attachment-definitions/%s"
+
+// eventComponent is the source component of the events recorded by the driver
+const eventComponent = "huawei-csi"
+
+// volumeSnapshotPath is the API path of the VolumeSnapshots
+const volumeSnapshotPath = "/apis/snapshot.storage.k8s.io/v1/namespaces/%s/volumesnapshots/%s"
+
+// networkConfig is the part of a CNI config which names the node interface, a config list
+// names it in one of its plugins
+type networkConfig struct {
+	Master  string          `json:"master"`
+	Device  string          `json:"device"`
+	Plugins []networkConfig `json:"plugins"`
+}
+
+func (c networkConfig) getMaster() string {
+	if c.Master != "" {
+		return c.Master
+	}
+	if c.Device != "" {
+		return c.Device
+	}
+
+	for _, plugin := range c.Plugins {
+		if master := plugin.getMaster(); master != "" {
+			return master
+		}
+	}
+	return ""
+}
+
+// GetNetworkAttachmentMaster returns the node interface which the macvlan, ipvlan or
+// host-device network of a Multus network attachment definition is on
+func (k *kubeClient) GetNetworkAttachmentMaster(ctx context.Context, namespace, name string) (string, error) {
+	data, err := k.clientSet.RESTClient().Get().
+		AbsPath(fmt.Sprintf(networkAttachmentPath, namespace, name)).
+		DoRaw(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get network attachment definition %s/%s. %s", namespace, name, err)
+	}
+
+	var definition struct {
+		Spec struct {
+			Config string `json:"config"`
+		} `json:"spec"`
+	}
+	err = json.Unmarshal(data, &definition)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse network attachment definition %s/%s. %s", namespace, name, err)
+	}
+
+	var config networkConfig
+	err = json.Unmarshal([]byte(definition.Spec.Config), &config)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the config of network attachment definition %s/%s. %s",
+			namespace, name, err)
+	}
+
+	master := config.getMaster()
+	if master == "" {
+		return "", fmt.Errorf("network attachment definition %s/%s has no node interface", namespace, name)
+	}
+	return master, nil
+}
@@ -0,0 +1,131 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package k8sutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// protectionGroupPath is the API path of the protection groups of all namespaces
+const protectionGroupPath = "/apis/csi.huawei.com/v1/protectiongroups"
+
+// ProtectionGroup is a group of PVCs in a namespace which are snapshotted and replicated as a unit
+type ProtectionGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec   ProtectionGroupSpec   `json:"spec"`
+	Status ProtectionGroupStatus `json:"status,omitempty"`
+}
+
+// ProtectionGroupSpec is the desired state of a protection group
+type ProtectionGroupSpec struct {
+	// PersistentVolumeClaims are the names of the member PVCs, in the namespace of the group
+	PersistentVolumeClaims []string `json:"persistentVolumeClaims"`
+	// Replication is whether to replicate the members as a consistency group, the members
+	// have to be replicated volumes
+	Replication bool `json:"replication,omitempty"`
+	// Snapshots are the names of the group snapshots to keep
+	Snapshots []string `json:"snapshots,omitempty"`
+}
+
+// ProtectionGroupStatus is the state of a protection group on storage
+type ProtectionGroupStatus struct {
+	// Backend is the backend of the members
+	Backend string `json:"backend,omitempty"`
+	// GroupName is the name of the protection group on storage
+	GroupName string `json:"groupName,omitempty"`
+	// Members are the volume names of the members on the backend, by PVC name
+	Members map[string]string `json:"members,omitempty"`
+	// Replicated is whether the members are replicated as a consistency group
+	Replicated bool `json:"replicated,omitempty"`
+	// Snapshots are the group snapshots taken
+	Snapshots []GroupSnapshotStatus `json:"snapshots,omitempty"`
+	// Message is the error of the last reconcile, empty if the group is in sync
+	Message string `json:"message,omitempty"`
+}
+
+// GroupSnapshotStatus is a group snapshot taken
+type GroupSnapshotStatus struct {
+	Name         string      `json:"name"`
+	CreationTime metav1.Time `json:"creationTime"`
+	// SnapshotHandles are the handles of the member snapshots by PVC name, which pre-provisioned
+	// VolumeSnapshotContents restore the members from
+	SnapshotHandles map[string]string `json:"snapshotHandles"`
+}
+
+// ListProtectionGroups returns the protection groups of all namespaces
+func (k *kubeClient) ListProtectionGroups(ctx context.Context) ([]ProtectionGroup, error) {
+	data, err := k.clientSet.RESTClient().Get().AbsPath(protectionGroupPath).DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list protection groups. %s", err)
+	}
+
+	var list struct {
+		Items []ProtectionGroup `json:"items"`
+	}
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse protection groups. %s", err)
+	}
+
+	return list.Items, nil
+}
+
+// UpdateProtectionGroup updates the metadata and spec of the protection group, and the status as well
+// if status is true
+func (k *kubeClient) UpdateProtectionGroup(ctx context.Context, group *ProtectionGroup, status bool) error {
+	data, err := json.Marshal(group)
+	if err != nil {
+		return fmt.Errorf("failed to encode protection group %s/%s. %s", group.Namespace, group.Name, err)
+	}
+
+	path := fmt.Sprintf("/apis/csi.huawei.com/v1/namespaces/%s/protectiongroups/%s", group.Namespace, group.Name)
+	if status {
+		path += "/status"
+	}
+
+	data, err = k.clientSet.RESTClient().Put().
+		AbsPath(path).
+		SetHeader("Content-Type", "application/json").
+		Body(data).
+		DoRaw(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update protection group %s/%s. %s", group.Namespace, group.Name, err)
+	}
+
+	return json.Unmarshal(data, group)
+}
+
+// GetClaimVolumeHandle returns the volume handle of the PV bound to the PVC, which has to be
+// provisioned by the driver
+func (k *kubeClient) GetClaimVolumeHandle(ctx context.Context, driverName, namespace, claimName string) (
+	string, error) {
+	pv, err := k.getPVByPVCName(ctx, namespace, claimName)
+	if err != nil {
+		return "", err
+	}
+
+	if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != driverName {
+		return "", fmt.Errorf("pvc %s/%s is not provisioned by %s", namespace, claimName, driverName)
+	}
+	return pv.Spec.CSI.VolumeHandle, nil
+}
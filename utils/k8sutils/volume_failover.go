@@ -0,0 +1,96 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package k8sutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// volumeFailoverPath is the API path of the volume failovers of all namespaces
+const volumeFailoverPath = "/apis/csi.huawei.com/v1/volumefailovers"
+
+const (
+	// VolumeFailoverSucceeded is the phase of a volume failover whose replica can be written
+	VolumeFailoverSucceeded = "Succeeded"
+	// VolumeFailoverFailed is the phase of a volume failover refused by storage, which is not retried
+	VolumeFailoverFailed = "Failed"
+)
+
+// VolumeFailover is a request to let the replica of the volume of a PVC be written on the replication storage
+type VolumeFailover struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec   VolumeFailoverSpec   `json:"spec"`
+	Status VolumeFailoverStatus `json:"status,omitempty"`
+}
+
+// VolumeFailoverSpec is the volume to fail over
+type VolumeFailoverSpec struct {
+	// PersistentVolumeClaim is the name of the PVC, in the namespace of the failover
+	PersistentVolumeClaim string `json:"persistentVolumeClaim"`
+}
+
+// VolumeFailoverStatus is the result of a volume failover
+type VolumeFailoverStatus struct {
+	// Phase is Succeeded or Failed once the failover is done, empty while it is pending
+	Phase string `json:"phase,omitempty"`
+	// Message is the reason of the refusal or the error of the last attempt
+	Message string `json:"message,omitempty"`
+}
+
+// ListVolumeFailovers returns the volume failovers of all namespaces
+func (k *kubeClient) ListVolumeFailovers(ctx context.Context) ([]VolumeFailover, error) {
+	data, err := k.clientSet.RESTClient().Get().AbsPath(volumeFailoverPath).DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volume failovers. %s", err)
+	}
+
+	var list struct {
+		Items []VolumeFailover `json:"items"`
+	}
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse volume failovers. %s", err)
+	}
+
+	return list.Items, nil
+}
+
+// UpdateVolumeFailoverStatus updates the status of the volume failover
+func (k *kubeClient) UpdateVolumeFailoverStatus(ctx context.Context, failover *VolumeFailover) error {
+	data, err := json.Marshal(failover)
+	if err != nil {
+		return fmt.Errorf("failed to encode volume failover %s/%s. %s", failover.Namespace, failover.Name, err)
+	}
+
+	data, err = k.clientSet.RESTClient().Put().
+		AbsPath(fmt.Sprintf("/apis/csi.huawei.com/v1/namespaces/%s/volumefailovers/%s/status",
+			failover.Namespace, failover.Name)).
+		SetHeader("Content-Type", "application/json").
+		Body(data).
+		DoRaw(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update volume failover %s/%s. %s", failover.Namespace, failover.Name, err)
+	}
+
+	return json.Unmarshal(data, failover)
+}
@@ -0,0 +1,123 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package k8sutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// volumeMigrationPath is the API path of the volume migrations of all namespaces
+const volumeMigrationPath = "/apis/csi.huawei.com/v1/volumemigrations"
+
+const (
+	// VolumeMigrationMigrating is the phase of a volume migration whose volume is moving to the pool
+	VolumeMigrationMigrating = "Migrating"
+	// VolumeMigrationSucceeded is the phase of a volume migration whose volume is in the pool
+	VolumeMigrationSucceeded = "Succeeded"
+	// VolumeMigrationFailed is the phase of a volume migration refused by storage, which is not retried
+	VolumeMigrationFailed = "Failed"
+)
+
+// VolumeMigration is a request to move the volume of a PVC to another storage pool of its backend
+type VolumeMigration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec   VolumeMigrationSpec   `json:"spec"`
+	Status VolumeMigrationStatus `json:"status,omitempty"`
+}
+
+// VolumeMigrationSpec is the PVC to migrate and the storage pool to migrate it to
+type VolumeMigrationSpec struct {
+	// PersistentVolumeClaim is the name of the PVC, in the namespace of the migration
+	PersistentVolumeClaim string `json:"persistentVolumeClaim"`
+	// StoragePool is the pool of the backend of the volume to move the volume to
+	StoragePool string `json:"storagePool"`
+	// MigrationSpeed is the speed from 1 to 4 of the migration on storage, 2 if it is not set
+	MigrationSpeed int `json:"migrationSpeed,omitempty"`
+}
+
+// VolumeMigrationStatus is the progress of a volume migration
+type VolumeMigrationStatus struct {
+	// Phase is Migrating while the volume moves, Succeeded or Failed once the migration is done, and
+	// empty while it is pending
+	Phase string `json:"phase,omitempty"`
+	// Message is the reason of the refusal or the error of the last attempt
+	Message string `json:"message,omitempty"`
+}
+
+// ListVolumeMigrations returns the volume migrations of all namespaces
+func (k *kubeClient) ListVolumeMigrations(ctx context.Context) ([]VolumeMigration, error) {
+	data, err := k.clientSet.RESTClient().Get().AbsPath(volumeMigrationPath).DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volume migrations. %s", err)
+	}
+
+	var list struct {
+		Items []VolumeMigration `json:"items"`
+	}
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse volume migrations. %s", err)
+	}
+
+	return list.Items, nil
+}
+
+// UpdateVolumeMigrationStatus updates the status of the volume migration
+func (k *kubeClient) UpdateVolumeMigrationStatus(ctx context.Context, migration *VolumeMigration) error {
+	data, err := json.Marshal(migration)
+	if err != nil {
+		return fmt.Errorf("failed to encode volume migration %s/%s. %s", migration.Namespace, migration.Name, err)
+	}
+
+	data, err = k.clientSet.RESTClient().Put().
+		AbsPath(fmt.Sprintf("/apis/csi.huawei.com/v1/namespaces/%s/volumemigrations/%s/status",
+			migration.Namespace, migration.Name)).
+		SetHeader("Content-Type", "application/json").
+		Body(data).
+		DoRaw(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update volume migration %s/%s. %s", migration.Namespace, migration.Name, err)
+	}
+
+	return json.Unmarshal(data, migration)
+}
+
+// UpdateClaimVolumeAnnotation sets the annotation of the PV bound to the PVC, as the attributes of
+// the PV cannot be changed once it is created
+func (k *kubeClient) UpdateClaimVolumeAnnotation(ctx context.Context, namespace, claimName, key,
+	value string) error {
+	pv, err := k.getPVByPVCName(ctx, namespace, claimName)
+	if err != nil {
+		return err
+	}
+
+	if pv.Annotations == nil {
+		pv.Annotations = map[string]string{}
+	}
+	pv.Annotations[key] = value
+	_, err = k.clientSet.CoreV1().PersistentVolumes().Update(ctx, pv, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update annotation %s of volume %s. %s", key, pv.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package k8sutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// volumeRestorePath is the API path of the volume restores of all namespaces
+const volumeRestorePath = "/apis/csi.huawei.com/v1/volumerestores"
+
+// volumeSnapshotContentPath is the API path of the VolumeSnapshotContents
+const volumeSnapshotContentPath = "/apis/snapshot.storage.k8s.io/v1/volumesnapshotcontents/%s"
+
+const (
+	// VolumeRestoreScalingDown is the phase of a volume restore waiting for the volume to be detached
+	VolumeRestoreScalingDown = "ScalingDown"
+	// VolumeRestoreRollingBack is the phase of a volume restore whose volume is rolling back
+	VolumeRestoreRollingBack = "RollingBack"
+	// VolumeRestoreSucceeded is the phase of a volume restore whose volume is restored
+	VolumeRestoreSucceeded = "Succeeded"
+	// VolumeRestoreFailed is the phase of a volume restore which is refused or aborted
+	VolumeRestoreFailed = "Failed"
+
+	// DeploymentKind is the kind of the Deployment workloads
+	DeploymentKind = "Deployment"
+	// StatefulSetKind is the kind of the StatefulSet workloads
+	StatefulSetKind = "StatefulSet"
+)
+
+// VolumeRestore is a request to restore the volume of a PVC from a VolumeSnapshot in place
+type VolumeRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec   VolumeRestoreSpec   `json:"spec"`
+	Status VolumeRestoreStatus `json:"status,omitempty"`
+}
+
+// VolumeRestoreSpec is the PVC to restore, the snapshot to restore it from, and the workload using it
+type VolumeRestoreSpec struct {
+	// PersistentVolumeClaim is the name of the PVC, in the namespace of the restore
+	PersistentVolumeClaim string `json:"persistentVolumeClaim"`
+	// VolumeSnapshot is the name of the VolumeSnapshot of the PVC, in the namespace of the restore
+	VolumeSnapshot string `json:"volumeSnapshot"`
+	// Workload is the Deployment or StatefulSet using the PVC, which is scaled down during the restore
+	Workload WorkloadReference `json:"workload"`
+	// RollbackSpeed is the speed from 1 to 4 of the rollback on storage, 2 if it is not set
+	RollbackSpeed int `json:"rollbackSpeed,omitempty"`
+}
+
+// WorkloadReference is a workload in the namespace of the restore
+type WorkloadReference struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// VolumeRestoreStatus is the progress of a volume restore
+type VolumeRestoreStatus struct {
+	// Phase is ScalingDown, RollingBack, Succeeded or Failed, empty before the restore starts
+	Phase string `json:"phase,omitempty"`
+	// Replicas is the replicas of the workload before it was scaled down
+	Replicas *int32 `json:"replicas,omitempty"`
+	// RestoreSize is the size in bytes the volume had when the snapshot was taken
+	RestoreSize int64 `json:"restoreSize,omitempty"`
+	// Message is the reason of the failure or the error of the last attempt
+	Message string `json:"message,omitempty"`
+}
+
+// ListVolumeRestores returns the volume restores of all namespaces
+func (k *kubeClient) ListVolumeRestores(ctx context.Context) ([]VolumeRestore, error) {
+	data, err := k.clientSet.RESTClient().Get().AbsPath(volumeRestorePath).DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volume restores. %s", err)
+	}
+
+	var list struct {
+		Items []VolumeRestore `json:"items"`
+	}
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse volume restores. %s", err)
+	}
+
+	return list.Items, nil
+}
+
+// UpdateVolumeRestoreStatus updates the status of the volume restore
+func (k *kubeClient) UpdateVolumeRestoreStatus(ctx context.Context, restore *VolumeRestore) error {
+	data, err := json.Marshal(restore)
+	if err != nil {
+		return fmt.Errorf("failed to encode volume restore %s/%s. %s", restore.Namespace, restore.Name, err)
+	}
+
+	data, err = k.clientSet.RESTClient().Put().
+		AbsPath(fmt.Sprintf("/apis/csi.huawei.com/v1/namespaces/%s/volumerestores/%s/status",
+			restore.Namespace, restore.Name)).
+		SetHeader("Content-Type", "application/json").
+		Body(data).
+		DoRaw(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update volume restore %s/%s. %s", restore.Namespace, restore.Name, err)
+	}
+
+	return json.Unmarshal(data, restore)
+}
+
+// GetSnapshotHandle returns the snapshot handle of the VolumeSnapshot, which has to be ready to use
+func (k *kubeClient) GetSnapshotHandle(ctx context.Context, namespace, snapshotName string) (string, error) {
+	data, err := k.clientSet.RESTClient().Get().
+		AbsPath(fmt.Sprintf(volumeSnapshotPath, namespace, snapshotName)).
+		DoRaw(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get volume snapshot %s/%s. %s", namespace, snapshotName, err)
+	}
+
+	var snapshot struct {
+		Status struct {
+			BoundVolumeSnapshotContentName string `json:"boundVolumeSnapshotContentName"`
+			ReadyToUse                     bool   `json:"readyToUse"`
+		} `json:"status"`
+	}
+	err = json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse volume snapshot %s/%s. %s", namespace, snapshotName, err)
+	}
+	if !snapshot.Status.ReadyToUse || snapshot.Status.BoundVolumeSnapshotContentName == "" {
+		return "", fmt.Errorf("volume snapshot %s/%s is not ready to use", namespace, snapshotName)
+	}
+
+	contentName := snapshot.Status.BoundVolumeSnapshotContentName
+	data, err = k.clientSet.RESTClient().Get().
+		AbsPath(fmt.Sprintf(volumeSnapshotContentPath, contentName)).
+		DoRaw(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get volume snapshot content %s. %s", contentName, err)
+	}
+
+	var content struct {
+		Status struct {
+			SnapshotHandle string `json:"snapshotHandle"`
+		} `json:"status"`
+	}
+	err = json.Unmarshal(data, &content)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse volume snapshot content %s. %s", contentName, err)
+	}
+	return content.Status.SnapshotHandle, nil
+}
+
+// getWorkloadScale returns the scale subresource of the workload
+func (k *kubeClient) getWorkloadScale(ctx context.Context, namespace string, workload WorkloadReference) (
+	*autoscalingv1.Scale, error) {
+	var scale *autoscalingv1.Scale
+	var err error
+	switch workload.Kind {
+	case DeploymentKind:
+		scale, err = k.clientSet.AppsV1().Deployments(namespace).GetScale(ctx, workload.Name, metav1.GetOptions{})
+	case StatefulSetKind:
+		scale, err = k.clientSet.AppsV1().StatefulSets(namespace).GetScale(ctx, workload.Name, metav1.GetOptions{})
+	default:
+		return nil, fmt.Errorf("workload kind %s is not supported", workload.Kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scale of %s %s/%s. %s", workload.Kind, namespace, workload.Name, err)
+	}
+	return scale, nil
+}
+
+// GetWorkloadReplicas returns the replicas of the workload
+func (k *kubeClient) GetWorkloadReplicas(ctx context.Context, namespace string, workload WorkloadReference) (
+	int32, error) {
+	scale, err := k.getWorkloadScale(ctx, namespace, workload)
+	if err != nil {
+		return 0, err
+	}
+	return scale.Spec.Replicas, nil
+}
+
+// ScaleWorkload sets the replicas of the workload, and returns the replicas before
+func (k *kubeClient) ScaleWorkload(ctx context.Context, namespace string, workload WorkloadReference,
+	replicas int32) (int32, error) {
+	scale, err := k.getWorkloadScale(ctx, namespace, workload)
+	if err != nil {
+		return 0, err
+	}
+
+	previous := scale.Spec.Replicas
+	if previous == replicas {
+		return previous, nil
+	}
+
+	scale.Spec.Replicas = replicas
+	if workload.Kind == DeploymentKind {
+		_, err = k.clientSet.AppsV1().Deployments(namespace).UpdateScale(ctx, workload.Name, scale,
+			metav1.UpdateOptions{})
+	} else {
+		_, err = k.clientSet.AppsV1().StatefulSets(namespace).UpdateScale(ctx, workload.Name, scale,
+			metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to scale %s %s/%s. %s", workload.Kind, namespace, workload.Name, err)
+	}
+	return previous, nil
+}
+
+// IsWorkloadUsingClaim returns whether the pods of the workload use the PVC
+func (k *kubeClient) IsWorkloadUsingClaim(ctx context.Context, namespace string, workload WorkloadReference,
+	claimName string) (bool, error) {
+	var volumes []corev1.Volume
+	switch workload.Kind {
+	case DeploymentKind:
+		deployment, err := k.clientSet.AppsV1().Deployments(namespace).Get(ctx, workload.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to get deployment %s/%s. %s", namespace, workload.Name, err)
+		}
+		volumes = deployment.Spec.Template.Spec.Volumes
+	case StatefulSetKind:
+		statefulSet, err := k.clientSet.AppsV1().StatefulSets(namespace).Get(ctx, workload.Name,
+			metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to get statefulset %s/%s. %s", namespace, workload.Name, err)
+		}
+		volumes = statefulSet.Spec.Template.Spec.Volumes
+		// The PVCs of a claim template are named <template>-<statefulset>-<ordinal>
+		for _, template := range statefulSet.Spec.VolumeClaimTemplates {
+			if strings.HasPrefix(claimName, template.Name+"-"+workload.Name+"-") {
+				return true, nil
+			}
+		}
+	default:
+		return false, fmt.Errorf("workload kind %s is not supported", workload.Kind)
+	}
+
+	for _, volume := range volumes {
+		if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == claimName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// IsClaimVolumeAttached returns whether the PV bound to the PVC is attached to any node
+func (k *kubeClient) IsClaimVolumeAttached(ctx context.Context, namespace, claimName string) (bool, error) {
+	pv, err := k.getPVByPVCName(ctx, namespace, claimName)
+	if err != nil {
+		return false, err
+	}
+
+	attachments, err := k.clientSet.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to list volume attachments. %s", err)
+	}
+	for _, attachment := range attachments.Items {
+		source := attachment.Spec.Source.PersistentVolumeName
+		if source != nil && *source == pv.Name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,137 @@
+/*
+ *  Copyright (c) Huawei Technologies Co., Ltd. 2020-2022. All rights reserved.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ */
+
+package k8sutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// volumeShrinkPath is the API path of the volume shrinks of all namespaces
+const volumeShrinkPath = "/apis/csi.huawei.com/v1/volumeshrinks"
+
+const (
+	// VolumeShrinkSucceeded is the phase of a volume shrink whose volume is shrunk
+	VolumeShrinkSucceeded = "Succeeded"
+	// VolumeShrinkFailed is the phase of a volume shrink refused by storage, which is not retried
+	VolumeShrinkFailed = "Failed"
+)
+
+// VolumeShrink is a request to reduce the capacity of the volume of a PVC
+type VolumeShrink struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec   VolumeShrinkSpec   `json:"spec"`
+	Status VolumeShrinkStatus `json:"status,omitempty"`
+}
+
+// VolumeShrinkSpec is the desired capacity of the volume
+type VolumeShrinkSpec struct {
+	// PersistentVolumeClaim is the name of the PVC, in the namespace of the shrink
+	PersistentVolumeClaim string `json:"persistentVolumeClaim"`
+	// Capacity is the quantity to shrink the volume to, such as 10Gi
+	Capacity string `json:"capacity"`
+}
+
+// VolumeShrinkStatus is the result of a volume shrink
+type VolumeShrinkStatus struct {
+	// Phase is Succeeded or Failed once the shrink is done, empty while it is pending
+	Phase string `json:"phase,omitempty"`
+	// Message is the reason of the refusal or the error of the last attempt
+	Message string `json:"message,omitempty"`
+	// Capacity is the capacity of the volume after the shrink
+	Capacity string `json:"capacity,omitempty"`
+}
+
+// ListVolumeShrinks returns the volume shrinks of all namespaces
+func (k *kubeClient) ListVolumeShrinks(ctx context.Context) ([]VolumeShrink, error) {
+	data, err := k.clientSet.RESTClient().Get().AbsPath(volumeShrinkPath).DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volume shrinks. %s", err)
+	}
+
+	var list struct {
+		Items []VolumeShrink `json:"items"`
+	}
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse volume shrinks. %s", err)
+	}
+
+	return list.Items, nil
+}
+
+// UpdateVolumeShrinkStatus updates the status of the volume shrink
+func (k *kubeClient) UpdateVolumeShrinkStatus(ctx context.Context, shrink *VolumeShrink) error {
+	data, err := json.Marshal(shrink)
+	if err != nil {
+		return fmt.Errorf("failed to encode volume shrink %s/%s. %s", shrink.Namespace, shrink.Name, err)
+	}
+
+	data, err = k.clientSet.RESTClient().Put().
+		AbsPath(fmt.Sprintf("/apis/csi.huawei.com/v1/namespaces/%s/volumeshrinks/%s/status",
+			shrink.Namespace, shrink.Name)).
+		SetHeader("Content-Type", "application/json").
+		Body(data).
+		DoRaw(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update volume shrink %s/%s. %s", shrink.Namespace, shrink.Name, err)
+	}
+
+	return json.Unmarshal(data, shrink)
+}
+
+// UpdateClaimVolumeCapacity sets the capacity in the spec of the PV bound to the PVC. The PVC is
+// left as it is, because its request cannot be reduced, and the external-resizer would expand the
+// volume back to the request if the capacity in the PVC status were lower than it.
+func (k *kubeClient) UpdateClaimVolumeCapacity(ctx context.Context, namespace, claimName string,
+	capacity int64) error {
+	pv, err := k.getPVByPVCName(ctx, namespace, claimName)
+	if err != nil {
+		return err
+	}
+
+	if pv.Spec.Capacity == nil {
+		pv.Spec.Capacity = corev1.ResourceList{}
+	}
+	pv.Spec.Capacity[corev1.ResourceStorage] = *resource.NewQuantity(capacity, resource.BinarySI)
+	_, err = k.clientSet.CoreV1().PersistentVolumes().Update(ctx, pv, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update capacity of volume %s. %s", pv.Name, err)
+	}
+	return nil
+}
+
+// GetClaimVolumeAttributes returns the volume attributes of the PV bound to the PVC
+func (k *kubeClient) GetClaimVolumeAttributes(ctx context.Context, namespace, claimName string) (
+	map[string]string, error) {
+	pv, err := k.getPVByPVCName(ctx, namespace, claimName)
+	if err != nil {
+		return nil, err
+	}
+
+	if pv.Spec.CSI == nil {
+		return nil, fmt.Errorf("volume %s of pvc %s/%s is not a CSI volume", pv.Name, namespace, claimName)
+	}
+	return pv.Spec.CSI.VolumeAttributes, nil
+}
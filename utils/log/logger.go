@@ -110,8 +110,8 @@ type loggerImpl struct {
 
 var _ LoggingInterface = &loggerImpl{}
 
-func parseLogLevel() (logrus.Level, error) {
-	switch *logLevel {
+func parseLogLevel(logLevel string) (logrus.Level, error) {
+	switch logLevel {
 	case "debug":
 		return logrus.DebugLevel, nil
 	case "info":
@@ -139,7 +139,7 @@ func InitLogging(logName string) error {
 	tmpLogger.Logger.SetOutput(ioutil.Discard)
 
 	// set logging level
-	level, err := parseLogLevel()
+	level, err := parseLogLevel(*logLevel)
 	if err != nil {
 		return err
 	}
@@ -180,6 +180,26 @@ func InitLogging(logName string) error {
 	return nil
 }
 
+// SetLevel changes the logging level at runtime, the level is debug, info, warning, error or fatal
+func SetLevel(level string) error {
+	logrusLevel, err := parseLogLevel(level)
+	if err != nil {
+		return err
+	}
+
+	impl, ok := logger.(*loggerImpl)
+	if ok && impl.Logger.GetLevel() != logrusLevel {
+		impl.Infof("Change logging level from %s to %s", impl.Logger.GetLevel(), logrusLevel)
+		impl.Logger.SetLevel(logrusLevel)
+	}
+	return nil
+}
+
+// GetLevel returns the logging level configured by the flag
+func GetLevel() string {
+	return *logLevel
+}
+
 // PlainTextFormatter is a formatter to ensure formatted logging output
 type PlainTextFormatter struct {
 	// TimestampFormat to use for display when a full timestamp is printed
@@ -18,6 +18,7 @@
 package utils
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -85,6 +86,10 @@ const (
 
 var maskObject = []string{"user", "password", "iqn", "tgt", "tgtname", "initiatorname"}
 
+// maskValuePattern matches the values of the password settings in the commands, such as the CHAP
+// passwords written into the iscsiadm node records
+var maskValuePattern = regexp.MustCompile(`(?i)(password\S*\s+-v\s+)('[^']*'|\S+)`)
+
 type VolumeMetrics struct {
 	Available  *resource.Quantity
 	Capacity   *resource.Quantity
@@ -110,6 +115,7 @@ var PathExist = func(path string) (bool, error) {
 func MaskSensitiveInfo(info interface{}) string {
 	message := fmt.Sprintf("%s", info)
 	substitute := "***"
+	message = maskValuePattern.ReplaceAllString(message, "${1}"+substitute)
 
 	for _, value := range maskObject {
 		if strings.Contains(strings.ToLower(message), strings.ToLower(value)) {
@@ -220,6 +226,24 @@ func execShellCmd(ctx context.Context, format string, logFilter bool, args ...in
 	return string(output), timeOut, nil
 }
 
+// volumeDescriptionPrefix prefixes the volume name recorded in the description of the volumes on storage
+const volumeDescriptionPrefix = "Created from Kubernetes CSI volume "
+
+// GetVolumeDescription returns the description of the volume on storage, which records the volume name as
+// the name on storage may be truncated or converted
+func GetVolumeDescription(name string) string {
+	return volumeDescriptionPrefix + name
+}
+
+// GetVolumeNameOfDescription returns the volume name recorded in the description of the volume on storage,
+// empty if the volume was created without it
+func GetVolumeNameOfDescription(description string) string {
+	if !strings.HasPrefix(description, volumeDescriptionPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(description, volumeDescriptionPrefix)
+}
+
 func GetLunName(name string) string {
 	if len(name) <= 31 {
 		return name
@@ -258,6 +282,22 @@ func GetFSSnapshotName(name string) string {
 	return strings.Replace(name, "-", "_", -1)
 }
 
+// nfsVersionProtocols maps the NFS versions to the protocols in the pool capabilities, the version 3.0 is
+// rejected by the mount.nfs
+var nfsVersionProtocols = map[string]string{
+	"3":   "nfs3",
+	"4":   "nfs4",
+	"4.0": "nfs4",
+	"4.1": "nfs41",
+	"4.2": "nfs42",
+}
+
+// GetNFSProtocol returns the protocol in the pool capabilities of the NFS version
+func GetNFSProtocol(version string) (string, bool) {
+	protocol, exist := nfsVersionProtocols[version]
+	return protocol, exist
+}
+
 func GetSharePath(name string) string {
 	return "/" + strings.Replace(name, "-", "_", -1) + "/"
 }
@@ -323,7 +363,7 @@ func WaitUntil(f func() (bool, error), timeout time.Duration, interval time.Dura
 
 			select {
 			case <-timeout:
-				done <- fmt.Errorf("Wait timeout")
+				done <- fmt.Errorf("Wait timeout: %w", ErrTimeout)
 				return
 			default:
 				time.Sleep(interval)
@@ -448,7 +488,8 @@ func GetAlua(ctx context.Context, alua map[string]interface{}, host string) map[
 		}
 	}
 
-	return alua["*"].(map[string]interface{})
+	defaultAlua, _ := alua["*"].(map[string]interface{})
+	return defaultAlua
 }
 
 func fsInfo(path string) (int64, int64, int64, int64, int64, int64, error) {
@@ -571,7 +612,8 @@ func TransToIntStrict(ctx context.Context, val interface{}) (int, error) {
 	return int(floatVal), nil
 }
 
-func getBackendProtocols(ctx context.Context, backendConfigs []map[string]interface{}) []string {
+// GetBackendProtocols returns the distinct protocols configured in the backends
+func GetBackendProtocols(ctx context.Context, backendConfigs []map[string]interface{}) []string {
 	var protocols []string
 	for _, config := range backendConfigs {
 		parameters, exist := config["parameters"].(map[string]interface{})
@@ -589,6 +631,14 @@ func getBackendProtocols(ctx context.Context, backendConfigs []map[string]interf
 		if !IsContain(protocol, protocols) {
 			protocols = append(protocols, protocol)
 		}
+
+		fallbacks, _ := parameters["fallbackProtocols"].([]interface{})
+		for _, fallback := range fallbacks {
+			fallbackProtocol, ok := fallback.(string)
+			if ok && !IsContain(fallbackProtocol, protocols) {
+				protocols = append(protocols, fallbackProtocol)
+			}
+		}
 	}
 	return protocols
 }
@@ -668,8 +718,13 @@ func GetRequiredMultipath(ctx context.Context,
 			storages, scsiMultipathType)
 	}
 
-	protocols := getBackendProtocols(ctx, backendConfigs)
+	disabledProtocols, _ := multipathConfig["disabledProtocols"].([]string)
+	protocols := GetBackendProtocols(ctx, backendConfigs)
 	for _, protocol := range protocols {
+		if IsContain(protocol, disabledProtocols) {
+			continue
+		}
+
 		var relatedServices []string
 		if protocol == iSCSIProtocol || protocol == fcProtocol {
 			relatedServices, exist = serviceMap[scsiMultipathType]
@@ -707,6 +762,48 @@ func Errorf(ctx context.Context, format string, a ...interface{}) error {
 	return errors.New(msg)
 }
 
+// GetStringField used to get a string field of an object returned by storage, an error is returned
+// if the field is missing or has an unexpected type instead of panicking
+func GetStringField(obj map[string]interface{}, key string) (string, error) {
+	value, exist := obj[key]
+	if !exist {
+		return "", fmt.Errorf("field %s does not exist in %v", key, obj)
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("field %s of %v is %T, not string", key, obj, value)
+	}
+
+	return str, nil
+}
+
+// GetInt64Field used to get a numeric field of an object returned by storage, JSON numbers are
+// decoded as float64 so they are converted to int64 here
+func GetInt64Field(obj map[string]interface{}, key string) (int64, error) {
+	value, exist := obj[key]
+	if !exist {
+		return 0, fmt.Errorf("field %s does not exist in %v", key, obj)
+	}
+
+	number, ok := value.(float64)
+	if !ok {
+		return 0, fmt.Errorf("field %s of %v is %T, not number", key, obj, value)
+	}
+
+	return int64(number), nil
+}
+
+// ToObject used to convert an item of a list returned by storage to map[string]interface{}
+func ToObject(item interface{}) (map[string]interface{}, error) {
+	obj, ok := item.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("the format of %v is %T, not map[string]interface{}", item, item)
+	}
+
+	return obj, nil
+}
+
 // GetValueByRegexp used to get value by regular expression
 func GetValueByRegexp(sourceString string, patternString string, valueIndex int) string {
 	for _, line := range strings.Split(sourceString, "\n") {
@@ -808,3 +905,37 @@ func IsDebugLog(method, url string, debugLogMap map[string]map[string]bool) bool
 	ret, exist := debugLogMap[method]
 	return exist && ret[url]
 }
+
+// LimitedBuffer keeps at most limit bytes written to it and silently discards the rest,
+// it is used to log the prefix of a large response body while decoding it as a stream
+type LimitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// NewLimitedBuffer used to create a LimitedBuffer with the given limit
+func NewLimitedBuffer(limit int) *LimitedBuffer {
+	return &LimitedBuffer{limit: limit}
+}
+
+// Write implements io.Writer, it never fails even if the data exceeds the limit
+func (b *LimitedBuffer) Write(p []byte) (int, error) {
+	if remain := b.limit - b.buf.Len(); remain < len(p) {
+		b.truncated = true
+		if remain > 0 {
+			b.buf.Write(p[:remain])
+		}
+		return len(p), nil
+	}
+
+	return b.buf.Write(p)
+}
+
+// String returns the kept content, with a truncated mark if any data was discarded
+func (b *LimitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "...(truncated)"
+	}
+	return b.buf.String()
+}
@@ -18,6 +18,7 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path"
 	"testing"
@@ -80,6 +81,12 @@ func TestMaskSensitiveInfo(t *testing.T) {
 			"iscsiadm -m node -T iqn.2003-01.io.k8s:e2e.volume -p 192.168.0.2 --interface default --op new",
 			"iscsiadm -m node -T *** 192.168.0.2 --interface default --op new",
 		},
+		{
+			"chapPasswordMaskInfo",
+			"iscsiadm -m node -T iqn.2003-01.io.k8s:e2e.volume -p 192.168.0.2 " +
+				"--op update -n node.session.auth.password_in -v 'chap Secret'",
+			"iscsiadm -m node -T *** 192.168.0.2 --op update -n node.session.auth.password_in -v ***",
+		},
 	}
 	for _, c := range testCases {
 		maskInfo := MaskSensitiveInfo(c.info)
@@ -95,6 +102,12 @@ func TestGetLunName(t *testing.T) {
 	assert.Equal(t, "pvc-331a3fcd-6380-4de5-9bc0-be9", longName)
 }
 
+func TestGetVolumeNameOfDescription(t *testing.T) {
+	description := GetVolumeDescription("pvc-331a3fcd-6380-4de5-9bc0-be95c801edeb")
+	assert.Equal(t, "pvc-331a3fcd-6380-4de5-9bc0-be95c801edeb", GetVolumeNameOfDescription(description))
+	assert.Equal(t, "", GetVolumeNameOfDescription("Created from Kubernetes CSI"))
+}
+
 func TestGetSnapshotName(t *testing.T) {
 	shortName := GetSnapshotName("TestShortName")
 	assert.Equal(t, "TestShortName", shortName)
@@ -156,6 +169,61 @@ func TestGetHostName(t *testing.T) {
 		"case name is testGetHostName, result: %v, error: %v", expectedHost, err)
 }
 
+func TestLimitedBuffer(t *testing.T) {
+	buf := NewLimitedBuffer(8)
+	n, err := buf.Write([]byte("12345"))
+	assert.Equal(t, 5, n)
+	assert.NoError(t, err)
+	assert.Equal(t, "12345", buf.String())
+
+	n, err = buf.Write([]byte("67890"))
+	assert.Equal(t, 5, n)
+	assert.NoError(t, err)
+	assert.Equal(t, "12345678...(truncated)", buf.String())
+}
+
+func TestVolumeConflictf(t *testing.T) {
+	err := VolumeConflictf(context.Background(), "volume %s conflict", "pvc-1")
+	assert.True(t, errors.Is(err, ErrVolumeConflict))
+	assert.Contains(t, err.Error(), "volume pvc-1 conflict")
+}
+
+func TestGetStringField(t *testing.T) {
+	obj := map[string]interface{}{"ID": "1", "CAPACITY": float64(2)}
+	id, err := GetStringField(obj, "ID")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", id)
+
+	_, err = GetStringField(obj, "CAPACITY")
+	assert.Error(t, err)
+	_, err = GetStringField(obj, "NAME")
+	assert.Error(t, err)
+}
+
+func TestGetInt64Field(t *testing.T) {
+	obj := map[string]interface{}{"id": float64(10), "name": "fs"}
+	id, err := GetInt64Field(obj, "id")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), id)
+
+	_, err = GetInt64Field(obj, "name")
+	assert.Error(t, err)
+}
+
+func TestGetAlua(t *testing.T) {
+	ctx := context.Background()
+	nodeAlua := map[string]interface{}{"accessMode": "1"}
+	defaultAlua := map[string]interface{}{"accessMode": "0"}
+
+	alua := map[string]interface{}{"node-.*": nodeAlua, "*": defaultAlua}
+	assert.Equal(t, nodeAlua, GetAlua(ctx, alua, "k8s_node-1"))
+	assert.Equal(t, defaultAlua, GetAlua(ctx, alua, "k8s_master"))
+
+	// no default ALUA is configured
+	assert.Nil(t, GetAlua(ctx, map[string]interface{}{"node-.*": nodeAlua}, "k8s_master"))
+	assert.Nil(t, GetAlua(ctx, nil, "k8s_master"))
+}
+
 func TestMain(m *testing.M) {
 	if err := log.InitLogging(logName); err != nil {
 		log.Errorf("init logging: %s failed. error: %v", logName, err)